@@ -66,7 +66,9 @@ func (c *Configuration) ConvertTo(hub conversion.Hub) error {
 		out.Spec.DependsOn[i] = v1.Dependency{
 			Provider:      c.Spec.DependsOn[i].Provider,
 			Configuration: c.Spec.DependsOn[i].Configuration,
+			Function:      c.Spec.DependsOn[i].Function,
 			Version:       c.Spec.DependsOn[i].Version,
+			Optional:      c.Spec.DependsOn[i].Optional,
 		}
 	}
 
@@ -95,7 +97,9 @@ func (c *Configuration) ConvertFrom(hub conversion.Hub) error {
 		c.Spec.DependsOn[i] = Dependency{
 			Provider:      in.Spec.DependsOn[i].Provider,
 			Configuration: in.Spec.DependsOn[i].Configuration,
+			Function:      in.Spec.DependsOn[i].Function,
 			Version:       in.Spec.DependsOn[i].Version,
+			Optional:      in.Spec.DependsOn[i].Optional,
 		}
 	}
 
@@ -31,7 +31,7 @@ type CrossplaneConstraints struct {
 	Version string `json:"version"`
 }
 
-// Dependency is a dependency on another package. One of Provider or Configuration may be supplied.
+// Dependency is a dependency on another package. One of Provider, Configuration, or Function may be supplied.
 type Dependency struct {
 	// Provider is the name of a Provider package image.
 	Provider *string `json:"provider,omitempty"`
@@ -39,6 +39,16 @@ type Dependency struct {
 	// Configuration is the name of a Configuration package image.
 	Configuration *string `json:"configuration,omitempty"`
 
+	// Function is the name of a Function package image.
+	Function *string `json:"function,omitempty"`
+
 	// Version is the semantic version constraints of the dependency image.
 	Version string `json:"version"`
+
+	// Optional dependencies are never installed automatically. They are
+	// only validated against their version constraints if they happen to
+	// be installed, and a package is not considered unhealthy if they are
+	// missing or don't satisfy them.
+	// +optional
+	Optional bool `json:"optional,omitempty"`
 }
@@ -90,7 +90,9 @@ func (p *Provider) ConvertTo(hub conversion.Hub) error {
 		out.Spec.DependsOn[i] = v1.Dependency{
 			Provider:      p.Spec.DependsOn[i].Provider,
 			Configuration: p.Spec.DependsOn[i].Configuration,
+			Function:      p.Spec.DependsOn[i].Function,
 			Version:       p.Spec.DependsOn[i].Version,
+			Optional:      p.Spec.DependsOn[i].Optional,
 		}
 	}
 
@@ -126,7 +128,9 @@ func (p *Provider) ConvertFrom(hub conversion.Hub) error {
 		p.Spec.DependsOn[i] = Dependency{
 			Provider:      in.Spec.DependsOn[i].Provider,
 			Configuration: in.Spec.DependsOn[i].Configuration,
+			Function:      in.Spec.DependsOn[i].Function,
 			Version:       in.Spec.DependsOn[i].Version,
+			Optional:      in.Spec.DependsOn[i].Optional,
 		}
 	}
 
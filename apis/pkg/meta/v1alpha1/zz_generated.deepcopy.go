@@ -117,6 +117,11 @@ func (in *Dependency) DeepCopyInto(out *Dependency) {
 		*out = new(string)
 		**out = **in
 	}
+	if in.Function != nil {
+		in, out := &in.Function, &out.Function
+		*out = new(string)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Dependency.
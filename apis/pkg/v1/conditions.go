@@ -34,12 +34,13 @@ const (
 
 // Reasons a package is or is not installed.
 const (
-	ReasonUnpacking     xpv1.ConditionReason = "UnpackingPackage"
-	ReasonInactive      xpv1.ConditionReason = "InactivePackageRevision"
-	ReasonActive        xpv1.ConditionReason = "ActivePackageRevision"
-	ReasonUnhealthy     xpv1.ConditionReason = "UnhealthyPackageRevision"
-	ReasonHealthy       xpv1.ConditionReason = "HealthyPackageRevision"
-	ReasonUnknownHealth xpv1.ConditionReason = "UnknownPackageRevisionHealth"
+	ReasonUnpacking              xpv1.ConditionReason = "UnpackingPackage"
+	ReasonInactive               xpv1.ConditionReason = "InactivePackageRevision"
+	ReasonActive                 xpv1.ConditionReason = "ActivePackageRevision"
+	ReasonUnhealthy              xpv1.ConditionReason = "UnhealthyPackageRevision"
+	ReasonHealthy                xpv1.ConditionReason = "HealthyPackageRevision"
+	ReasonUnknownHealth          xpv1.ConditionReason = "UnknownPackageRevisionHealth"
+	ReasonUnresolvedDependencies xpv1.ConditionReason = "UnresolvedDependencies"
 )
 
 // Unpacking indicates that the package manager is waiting for a package
@@ -85,6 +86,17 @@ func Unhealthy() xpv1.Condition {
 	}
 }
 
+// UnresolvedDependencies indicates that the current revision is unhealthy
+// because its dependencies are not yet resolved.
+func UnresolvedDependencies() xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypeHealthy,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonUnresolvedDependencies,
+	}
+}
+
 // Healthy indicates that the current revision is healthy.
 func Healthy() xpv1.Condition {
 	return xpv1.Condition{
@@ -28,6 +28,12 @@ const (
 	// LabelParentPackage is used as key for the owner package label we add to the
 	// revisions. Its corresponding value should be the name of the owner package.
 	LabelParentPackage = "pkg.crossplane.io/package"
+
+	// AnnotationRequiredBy is a comma separated list of the sources of the
+	// packages in the Lock that required a package when it was installed to
+	// satisfy their dependencies. It is added to packages by the dependency
+	// resolver, and propagated from packages to their revisions.
+	AnnotationRequiredBy = "pkg.crossplane.io/required-by"
 )
 
 // RevisionActivationPolicy indicates how a package should activate its
@@ -0,0 +1,269 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+
+	v1 "github.com/crossplane/crossplane/apis/pkg/v1"
+)
+
+var _ v1.Package = &Function{}
+var _ v1.PackageRevision = &FunctionRevision{}
+var _ v1.PackageRevisionList = &FunctionRevisionList{}
+
+// GetCondition of this Function.
+func (p *Function) GetCondition(ct xpv1.ConditionType) xpv1.Condition {
+	return p.Status.GetCondition(ct)
+}
+
+// SetConditions of this Function.
+func (p *Function) SetConditions(c ...xpv1.Condition) {
+	p.Status.SetConditions(c...)
+}
+
+// GetSource of this Function.
+func (p *Function) GetSource() string {
+	return p.Spec.Package
+}
+
+// SetSource of this Function.
+func (p *Function) SetSource(s string) {
+	p.Spec.Package = s
+}
+
+// GetActivationPolicy of this Function.
+func (p *Function) GetActivationPolicy() *v1.RevisionActivationPolicy {
+	return p.Spec.RevisionActivationPolicy
+}
+
+// SetActivationPolicy of this Function.
+func (p *Function) SetActivationPolicy(a *v1.RevisionActivationPolicy) {
+	p.Spec.RevisionActivationPolicy = a
+}
+
+// GetPackagePullSecrets of this Function.
+func (p *Function) GetPackagePullSecrets() []corev1.LocalObjectReference {
+	return p.Spec.PackagePullSecrets
+}
+
+// SetPackagePullSecrets of this Function.
+func (p *Function) SetPackagePullSecrets(s []corev1.LocalObjectReference) {
+	p.Spec.PackagePullSecrets = s
+}
+
+// GetPackagePullPolicy of this Function.
+func (p *Function) GetPackagePullPolicy() *corev1.PullPolicy {
+	return p.Spec.PackagePullPolicy
+}
+
+// SetPackagePullPolicy of this Function.
+func (p *Function) SetPackagePullPolicy(i *corev1.PullPolicy) {
+	p.Spec.PackagePullPolicy = i
+}
+
+// GetRevisionHistoryLimit of this Function.
+func (p *Function) GetRevisionHistoryLimit() *int64 {
+	return p.Spec.RevisionHistoryLimit
+}
+
+// SetRevisionHistoryLimit of this Function.
+func (p *Function) SetRevisionHistoryLimit(l *int64) {
+	p.Spec.RevisionHistoryLimit = l
+}
+
+// GetIgnoreCrossplaneConstraints of this Function.
+func (p *Function) GetIgnoreCrossplaneConstraints() *bool {
+	return p.Spec.IgnoreCrossplaneConstraints
+}
+
+// SetIgnoreCrossplaneConstraints of this Function.
+func (p *Function) SetIgnoreCrossplaneConstraints(b *bool) {
+	p.Spec.IgnoreCrossplaneConstraints = b
+}
+
+// GetControllerConfigRef of this Function.
+func (p *Function) GetControllerConfigRef() *xpv1.Reference {
+	return nil
+}
+
+// SetControllerConfigRef of this Function.
+func (p *Function) SetControllerConfigRef(r *xpv1.Reference) {}
+
+// GetCurrentRevision of this Function.
+func (p *Function) GetCurrentRevision() string {
+	return p.Status.CurrentRevision
+}
+
+// SetCurrentRevision of this Function.
+func (p *Function) SetCurrentRevision(s string) {
+	p.Status.CurrentRevision = s
+}
+
+// GetSkipDependencyResolution of this Function.
+func (p *Function) GetSkipDependencyResolution() *bool {
+	return p.Spec.SkipDependencyResolution
+}
+
+// SetSkipDependencyResolution of this Function.
+func (p *Function) SetSkipDependencyResolution(b *bool) {
+	p.Spec.SkipDependencyResolution = b
+}
+
+// GetCurrentIdentifier of this Function.
+func (p *Function) GetCurrentIdentifier() string {
+	return p.Status.CurrentIdentifier
+}
+
+// SetCurrentIdentifier of this Function.
+func (p *Function) SetCurrentIdentifier(s string) {
+	p.Status.CurrentIdentifier = s
+}
+
+// GetCondition of this FunctionRevision.
+func (p *FunctionRevision) GetCondition(ct xpv1.ConditionType) xpv1.Condition {
+	return p.Status.GetCondition(ct)
+}
+
+// SetConditions of this FunctionRevision.
+func (p *FunctionRevision) SetConditions(c ...xpv1.Condition) {
+	p.Status.SetConditions(c...)
+}
+
+// GetObjects of this FunctionRevision.
+func (p *FunctionRevision) GetObjects() []xpv1.TypedReference {
+	return p.Status.ObjectRefs
+}
+
+// SetObjects of this FunctionRevision.
+func (p *FunctionRevision) SetObjects(c []xpv1.TypedReference) {
+	p.Status.ObjectRefs = c
+}
+
+// GetControllerReference of this FunctionRevision.
+func (p *FunctionRevision) GetControllerReference() xpv1.Reference {
+	return p.Status.ControllerRef
+}
+
+// SetControllerReference of this FunctionRevision.
+func (p *FunctionRevision) SetControllerReference(c xpv1.Reference) {
+	p.Status.ControllerRef = c
+}
+
+// GetSource of this FunctionRevision.
+func (p *FunctionRevision) GetSource() string {
+	return p.Spec.Package
+}
+
+// SetSource of this FunctionRevision.
+func (p *FunctionRevision) SetSource(s string) {
+	p.Spec.Package = s
+}
+
+// GetPackagePullSecrets of this FunctionRevision.
+func (p *FunctionRevision) GetPackagePullSecrets() []corev1.LocalObjectReference {
+	return p.Spec.PackagePullSecrets
+}
+
+// SetPackagePullSecrets of this FunctionRevision.
+func (p *FunctionRevision) SetPackagePullSecrets(s []corev1.LocalObjectReference) {
+	p.Spec.PackagePullSecrets = s
+}
+
+// GetPackagePullPolicy of this FunctionRevision.
+func (p *FunctionRevision) GetPackagePullPolicy() *corev1.PullPolicy {
+	return p.Spec.PackagePullPolicy
+}
+
+// SetPackagePullPolicy of this FunctionRevision.
+func (p *FunctionRevision) SetPackagePullPolicy(i *corev1.PullPolicy) {
+	p.Spec.PackagePullPolicy = i
+}
+
+// GetDesiredState of this FunctionRevision.
+func (p *FunctionRevision) GetDesiredState() v1.PackageRevisionDesiredState {
+	return p.Spec.DesiredState
+}
+
+// SetDesiredState of this FunctionRevision.
+func (p *FunctionRevision) SetDesiredState(s v1.PackageRevisionDesiredState) {
+	p.Spec.DesiredState = s
+}
+
+// GetRevision of this FunctionRevision.
+func (p *FunctionRevision) GetRevision() int64 {
+	return p.Spec.Revision
+}
+
+// SetRevision of this FunctionRevision.
+func (p *FunctionRevision) SetRevision(r int64) {
+	p.Spec.Revision = r
+}
+
+// GetDependencyStatus of this FunctionRevision.
+func (p *FunctionRevision) GetDependencyStatus() (found, installed, invalid int64) {
+	return p.Status.FoundDependencies, p.Status.InstalledDependencies, p.Status.InvalidDependencies
+}
+
+// SetDependencyStatus of this FunctionRevision.
+func (p *FunctionRevision) SetDependencyStatus(found, installed, invalid int64) {
+	p.Status.FoundDependencies = found
+	p.Status.InstalledDependencies = installed
+	p.Status.InvalidDependencies = invalid
+}
+
+// GetIgnoreCrossplaneConstraints of this FunctionRevision.
+func (p *FunctionRevision) GetIgnoreCrossplaneConstraints() *bool {
+	return p.Spec.IgnoreCrossplaneConstraints
+}
+
+// SetIgnoreCrossplaneConstraints of this FunctionRevision.
+func (p *FunctionRevision) SetIgnoreCrossplaneConstraints(b *bool) {
+	p.Spec.IgnoreCrossplaneConstraints = b
+}
+
+// GetControllerConfigRef of this FunctionRevision.
+func (p *FunctionRevision) GetControllerConfigRef() *xpv1.Reference {
+	return p.Spec.ControllerConfigReference
+}
+
+// SetControllerConfigRef of this FunctionRevision.
+func (p *FunctionRevision) SetControllerConfigRef(r *xpv1.Reference) {
+	p.Spec.ControllerConfigReference = r
+}
+
+// GetSkipDependencyResolution of this FunctionRevision.
+func (p *FunctionRevision) GetSkipDependencyResolution() *bool {
+	return p.Spec.SkipDependencyResolution
+}
+
+// SetSkipDependencyResolution of this FunctionRevision.
+func (p *FunctionRevision) SetSkipDependencyResolution(b *bool) {
+	p.Spec.SkipDependencyResolution = b
+}
+
+// GetRevisions of this FunctionRevisionList.
+func (p *FunctionRevisionList) GetRevisions() []v1.PackageRevision {
+	prs := make([]v1.PackageRevision, len(p.Items))
+	for i, r := range p.Items {
+		r := r // Pin range variable so we can take its address.
+		prs[i] = &r
+	}
+	return prs
+}
@@ -30,6 +30,20 @@ const (
 
 	// A TypeHealthy indicates whether a package is healthy.
 	TypeHealthy xpv1.ConditionType = "Healthy"
+
+	// A TypeDependenciesResolved indicates whether all dependencies of the
+	// packages in a Lock have been resolved.
+	TypeDependenciesResolved xpv1.ConditionType = "DependenciesResolved"
+
+	// A TypeDependenciesSatisfied indicates whether all dependencies of the
+	// packages in a Lock have been resolved, and every package in the Lock
+	// is backed by a package revision.
+	TypeDependenciesSatisfied xpv1.ConditionType = "DependenciesSatisfied"
+
+	// A TypeDependenciesChecked indicates whether the dependencies most
+	// recently installed for the packages in a Lock could be checked before
+	// they were installed.
+	TypeDependenciesChecked xpv1.ConditionType = "DependenciesChecked"
 )
 
 // Reasons a package is or is not installed.
@@ -42,6 +56,48 @@ const (
 	ReasonUnknownHealth xpv1.ConditionReason = "UnknownPackageRevisionHealth"
 )
 
+// Reasons the dependencies of the packages in a Lock are or are not resolved.
+const (
+	ReasonResolved          xpv1.ConditionReason = "Resolved"
+	ReasonMissingDependency xpv1.ConditionReason = "MissingDependency"
+	ReasonInvalidDependency xpv1.ConditionReason = "InvalidDependency"
+	ReasonInvalidConstraint xpv1.ConditionReason = "InvalidConstraint"
+	ReasonUnsupportedType   xpv1.ConditionReason = "UnsupportedDependencyType"
+	ReasonNoValidVersion    xpv1.ConditionReason = "NoValidVersion"
+	ReasonFetchError        xpv1.ConditionReason = "FetchError"
+	ReasonCycle             xpv1.ConditionReason = "Cycle"
+	ReasonVersionConflict   xpv1.ConditionReason = "VersionConflict"
+	ReasonTypeConflict      xpv1.ConditionReason = "TypeConflict"
+	ReasonDigestMismatch    xpv1.ConditionReason = "DigestMismatch"
+	ReasonRejected          xpv1.ConditionReason = "Rejected"
+	ReasonLimitReached      xpv1.ConditionReason = "AutoInstallLimitReached"
+	ReasonBlocked           xpv1.ConditionReason = "BlockedOnUnhealthyDependency"
+	ReasonBlockedByPolicy   xpv1.ConditionReason = "BlockedByPolicy"
+	ReasonDuplicatePackages xpv1.ConditionReason = "DuplicatePackages"
+	ReasonAwaitingApproval  xpv1.ConditionReason = "AwaitingApproval"
+	ReasonStalePackages     xpv1.ConditionReason = "StalePackages"
+	ReasonIncompatible      xpv1.ConditionReason = "IncompatibleCrossplaneVersion"
+	ReasonUnverified        xpv1.ConditionReason = "UnverifiedDigests"
+	ReasonDowngradeRefused  xpv1.ConditionReason = "DowngradeRefused"
+	ReasonUnauthorized      xpv1.ConditionReason = "RegistryUnauthorized"
+	ReasonOverridden        xpv1.ConditionReason = "OverriddenVersions"
+	ReasonPaused            xpv1.ConditionReason = "Paused"
+)
+
+// Reasons the dependencies of the packages in a Lock are or are not satisfied.
+const (
+	ReasonSatisfied         xpv1.ConditionReason = "Satisfied"
+	ReasonUnresolved        xpv1.ConditionReason = "UnresolvedDependencies"
+	ReasonAwaitingRevisions xpv1.ConditionReason = "AwaitingPackageRevisions"
+)
+
+// Reasons the dependencies of the packages in a Lock could or could not be
+// checked before they were installed.
+const (
+	ReasonChecked         xpv1.ConditionReason = "Checked"
+	ReasonUnknownMetadata xpv1.ConditionReason = "UnknownDependencyMetadata"
+)
+
 // Unpacking indicates that the package manager is waiting for a package
 // revision to be unpacked.
 func Unpacking() xpv1.Condition {
@@ -104,3 +160,335 @@ func UnknownHealth() xpv1.Condition {
 		Reason:             ReasonUnknownHealth,
 	}
 }
+
+// DependenciesResolved indicates that every dependency of the packages in a
+// Lock is present in the Lock.
+func DependenciesResolved() xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypeDependenciesResolved,
+		Status:             corev1.ConditionTrue,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonResolved,
+	}
+}
+
+// DependenciesUnverified indicates that every dependency of the packages in a
+// Lock is present in the Lock, but that some were installed by digest, so
+// whether they satisfy the version constraints on them cannot be verified.
+func DependenciesUnverified() xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypeDependenciesResolved,
+		Status:             corev1.ConditionTrue,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonUnverified,
+	}
+}
+
+// DependenciesOverridden indicates that every dependency of the packages in a
+// Lock is present in the Lock, but that the Lock overrides the versions of
+// some of them such that they violate the version constraints on them.
+func DependenciesOverridden() xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypeDependenciesResolved,
+		Status:             corev1.ConditionTrue,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonOverridden,
+	}
+}
+
+// MissingDependency indicates that one or more dependencies of the packages
+// in a Lock are not yet present in the Lock.
+func MissingDependency() xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypeDependenciesResolved,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonMissingDependency,
+	}
+}
+
+// InvalidDependency indicates that a dependency could not be resolved because
+// its package reference or type is invalid.
+func InvalidDependency() xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypeDependenciesResolved,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonInvalidDependency,
+	}
+}
+
+// InvalidConstraint indicates that a dependency could not be resolved because
+// its version constraint is invalid.
+func InvalidConstraint() xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypeDependenciesResolved,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonInvalidConstraint,
+	}
+}
+
+// UnsupportedDependencyType indicates that a dependency could not be resolved
+// because it is a type of package that cannot be installed as a dependency.
+func UnsupportedDependencyType() xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypeDependenciesResolved,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonUnsupportedType,
+	}
+}
+
+// NoValidVersion indicates that a dependency could not be resolved because no
+// available version satisfies its version constraint.
+func NoValidVersion() xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypeDependenciesResolved,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonNoValidVersion,
+	}
+}
+
+// FetchError indicates that a dependency could not be resolved because its
+// available versions could not be fetched.
+func FetchError() xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypeDependenciesResolved,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonFetchError,
+	}
+}
+
+// RegistryUnauthorized indicates that a dependency could not be resolved
+// because its registry refused to list its available versions without
+// credentials.
+func RegistryUnauthorized() xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypeDependenciesResolved,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonUnauthorized,
+	}
+}
+
+// DependencyCycle indicates that dependencies cannot be resolved because the
+// packages in a Lock depend on each other cyclically.
+func DependencyCycle() xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypeDependenciesResolved,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonCycle,
+	}
+}
+
+// VersionConflict indicates that the installed version of one or more
+// packages in a Lock does not satisfy the version constraints of the packages
+// that depend on them.
+func VersionConflict() xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypeDependenciesResolved,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonVersionConflict,
+	}
+}
+
+// TypeConflict indicates that one or more packages in a Lock are not the type
+// of package that the packages that depend on them declared.
+func TypeConflict() xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypeDependenciesResolved,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonTypeConflict,
+	}
+}
+
+// DigestMismatch indicates that the tag selected for one or more missing
+// dependencies no longer points at the digest recorded when it was published.
+func DigestMismatch() xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypeDependenciesResolved,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonDigestMismatch,
+	}
+}
+
+// DependencyRejected indicates that the package that would satisfy a
+// dependency could not be created, and that retrying will not succeed until
+// something changes, e.g. because an admission webhook denied it.
+func DependencyRejected() xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypeDependenciesResolved,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonRejected,
+	}
+}
+
+// IncompatibleCrossplaneVersion indicates that the package that would satisfy
+// a dependency was not created because it requires a version of Crossplane
+// other than the one that is running.
+func IncompatibleCrossplaneVersion() xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypeDependenciesResolved,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonIncompatible,
+	}
+}
+
+// DowngradeRefused indicates that the packages that depend on an
+// auto-installed package require an older version than is installed, and that
+// the package was not downgraded because downgrades are not allowed.
+func DowngradeRefused() xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypeDependenciesResolved,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonDowngradeRefused,
+	}
+}
+
+// AutoInstallLimitReached indicates that a missing dependency was not
+// installed because the limit on the number of packages that may be installed
+// automatically was reached.
+func AutoInstallLimitReached() xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypeDependenciesResolved,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonLimitReached,
+	}
+}
+
+// DependencyBlocked indicates that missing dependencies are not being
+// installed because the packages that depend on them are not yet healthy.
+func DependencyBlocked() xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypeDependenciesResolved,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonBlocked,
+	}
+}
+
+// BlockedByPolicy indicates that a missing dependency was not installed, or
+// an auto-installed dependency was not upgraded, because the package source
+// policy doesn't allow packages to be installed from its repository.
+func BlockedByPolicy() xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypeDependenciesResolved,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonBlockedByPolicy,
+	}
+}
+
+// AwaitingApproval indicates that missing dependencies are not being
+// installed because they have not yet been approved.
+func AwaitingApproval() xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypeDependenciesResolved,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonAwaitingApproval,
+	}
+}
+
+// StalePackages indicates that the Lock contains packages whose package
+// revisions no longer exist.
+func StalePackages() xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypeDependenciesResolved,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonStalePackages,
+	}
+}
+
+// DuplicatePackages indicates that dependencies cannot be resolved because
+// the Lock contains more than one package from the same repository.
+func DuplicatePackages() xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypeDependenciesResolved,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonDuplicatePackages,
+	}
+}
+
+// ResolutionPaused indicates that the dependencies of the packages in a Lock
+// are not being resolved because resolution was paused.
+func ResolutionPaused() xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypeDependenciesResolved,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonPaused,
+	}
+}
+
+// DependenciesSatisfied indicates that every dependency of the packages in a
+// Lock is present in the Lock, and that every package in the Lock is backed by
+// a package revision.
+func DependenciesSatisfied() xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypeDependenciesSatisfied,
+		Status:             corev1.ConditionTrue,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonSatisfied,
+	}
+}
+
+// DependenciesUnsatisfied indicates that the dependencies of the packages in
+// a Lock are not yet resolved.
+func DependenciesUnsatisfied() xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypeDependenciesSatisfied,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonUnresolved,
+	}
+}
+
+// AwaitingPackageRevisions indicates that the dependencies of the packages in
+// a Lock are resolved, but that some packages in the Lock are not backed by a
+// package revision.
+func AwaitingPackageRevisions() xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypeDependenciesSatisfied,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonAwaitingRevisions,
+	}
+}
+
+// DependenciesChecked indicates that the metadata of every dependency most
+// recently installed for the packages in a Lock told the package manager what
+// it checks before installing a dependency.
+func DependenciesChecked() xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypeDependenciesChecked,
+		Status:             corev1.ConditionTrue,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonChecked,
+	}
+}
+
+// DependencyMetadataUnknown indicates that some dependencies most recently
+// installed for the packages in a Lock were installed without being checked,
+// because their metadata didn't tell the package manager what it checks.
+func DependencyMetadataUnknown() xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypeDependenciesChecked,
+		Status:             corev1.ConditionUnknown,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonUnknownMetadata,
+	}
+}
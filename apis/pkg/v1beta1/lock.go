@@ -19,7 +19,10 @@ package v1beta1
 import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+
 	"github.com/crossplane/crossplane/internal/dag"
+	"github.com/crossplane/crossplane/internal/xpkg/canonical"
 )
 
 var _ dag.Node = &Dependency{}
@@ -32,6 +35,7 @@ type PackageType string
 const (
 	ConfigurationPackageType PackageType = "Configuration"
 	ProviderPackageType      PackageType = "Provider"
+	FunctionPackageType      PackageType = "Function"
 )
 
 // LockPackage is a package that is in the lock.
@@ -39,7 +43,8 @@ type LockPackage struct {
 	// Name corresponds to the name of the package revision for this package.
 	Name string `json:"name"`
 
-	// Type is the type of package. Can be either Configuration or Provider.
+	// Type is the type of package. Can be Configuration, Provider, or
+	// Function.
 	Type PackageType `json:"type"`
 
 	// Source is the OCI image name without a tag or digest.
@@ -51,6 +56,46 @@ type LockPackage struct {
 	// Dependencies are the list of dependencies of this package. The order of
 	// the dependencies will dictate the order in which they are resolved.
 	Dependencies []Dependency `json:"dependencies"`
+
+	// Origin records whether this package was installed by a user, or
+	// installed to satisfy the dependencies of other packages. Packages
+	// without an origin were installed by a user.
+	// +optional
+	Origin *PackageOrigin `json:"origin,omitempty"`
+}
+
+// An OriginType is the way in which a package came to be installed.
+type OriginType string
+
+// Origin types.
+const (
+	// OriginUser packages were installed by a user.
+	OriginUser OriginType = "User"
+
+	// OriginDependency packages were installed to satisfy the dependencies
+	// of other packages.
+	OriginDependency OriginType = "Dependency"
+)
+
+// A PackageOrigin records how a package in the lock came to be installed.
+type PackageOrigin struct {
+	// Type of origin. Can be either User or Dependency.
+	Type OriginType `json:"type"`
+
+	// Parents are the sources of the packages that required this package
+	// when it was installed, if it was installed as a dependency.
+	// +optional
+	Parents []string `json:"parents,omitempty"`
+}
+
+// GetOrigin returns the origin of a LockPackage. A LockPackage without an
+// origin, for example one added to the lock before origins were recorded, was
+// installed by a user.
+func (l *LockPackage) GetOrigin() PackageOrigin {
+	if l.Origin == nil || l.Origin.Type == "" {
+		return PackageOrigin{Type: OriginUser}
+	}
+	return *l.Origin
 }
 
 // ToNodes converts LockPackages to DAG nodes.
@@ -63,9 +108,11 @@ func ToNodes(pkgs ...LockPackage) []dag.Node {
 	return nodes
 }
 
-// Identifier returns the source of a LockPackage.
+// Identifier returns the canonical source of a LockPackage, so that a package
+// is the same node in the dependency graph regardless of which alias of its
+// repository it was installed from.
 func (l *LockPackage) Identifier() string {
-	return l.Source
+	return canonical.Source(l.Source)
 }
 
 // Neighbors returns dependencies of a LockPackage.
@@ -90,17 +137,23 @@ type Dependency struct {
 	// Package is the OCI image name without a tag or digest.
 	Package string `json:"package"`
 
-	// Type is the type of package. Can be either Configuration or Provider.
+	// Type is the type of package. Can be Configuration, Provider, or
+	// Function.
 	Type PackageType `json:"type"`
 
 	// Constraints is a valid semver range, which will be used to select a valid
 	// dependency version.
 	Constraints string `json:"constraints"`
+
+	// Optional dependencies are never installed automatically. They are
+	// only validated against their constraints if they are installed.
+	// +optional
+	Optional bool `json:"optional,omitempty"`
 }
 
-// Identifier returns a dependency's source.
+// Identifier returns a dependency's canonical source.
 func (d *Dependency) Identifier() string {
-	return d.Package
+	return canonical.Source(d.Package)
 }
 
 // Neighbors in is a no-op for dependencies because we are not yet aware of its
@@ -115,6 +168,371 @@ func (d *Dependency) AddNeighbors(...dag.Node) error {
 	return nil
 }
 
+// GetConstraints returns the version constraints of a dependency, so that the
+// edge of the dependency graph it forms carries them.
+func (d *Dependency) GetConstraints() string {
+	return d.Constraints
+}
+
+// IsOptional returns true if the dependency is optional, so that the edge of
+// the dependency graph it forms never implies a missing package.
+func (d *Dependency) IsOptional() bool {
+	return d.Optional
+}
+
+// A DependencyUpgradePolicy determines whether packages installed as
+// dependencies are upgraded when they no longer satisfy the packages that
+// depend on them.
+type DependencyUpgradePolicy string
+
+// Dependency upgrade policies.
+const (
+	// UpgradePolicyNone never upgrades packages installed as dependencies.
+	// Conflicts are reported, and must be resolved manually.
+	UpgradePolicyNone DependencyUpgradePolicy = "None"
+
+	// UpgradePolicyAutomatic upgrades packages installed as dependencies to
+	// the lowest version that satisfies the packages that depend on them.
+	UpgradePolicyAutomatic DependencyUpgradePolicy = "Automatic"
+)
+
+// A DependencyVersionSelection determines which of the versions that satisfy
+// a missing dependency's constraints is installed.
+type DependencyVersionSelection string
+
+// Dependency version selections.
+const (
+	// VersionSelectionHighest installs the highest version that satisfies
+	// a missing dependency's constraints.
+	VersionSelectionHighest DependencyVersionSelection = "Highest"
+
+	// VersionSelectionLowest installs the lowest version that satisfies a
+	// missing dependency's constraints.
+	VersionSelectionLowest DependencyVersionSelection = "Lowest"
+)
+
+// A DependencyRecheckPolicy determines when the package manager consults the
+// registry again about a dependency that is already installed.
+type DependencyRecheckPolicy string
+
+// Dependency recheck policies.
+const (
+	// RecheckNever never consults the registry about an installed
+	// dependency that satisfies the packages that depend on it. Conflicting
+	// dependencies, and dependencies that track a tag, are checked whenever
+	// the Lock's dependencies are resolved.
+	RecheckNever DependencyRecheckPolicy = "Never"
+
+	// RecheckOnChange consults the registry about an installed dependency
+	// only when the packages that depend on it, or the constraints they
+	// place on it, change.
+	RecheckOnChange DependencyRecheckPolicy = "OnChange"
+
+	// RecheckPeriodic consults the registry about an installed dependency
+	// when the packages that depend on it or their constraints change, and
+	// whenever the recheck interval has elapsed since it was last checked.
+	RecheckPeriodic DependencyRecheckPolicy = "Periodic"
+)
+
+// A DependencyRecheck specifies when the package manager consults the
+// registry again about dependencies that are already installed, e.g. to find
+// a newer version that satisfies an open-ended constraint. Dependencies are
+// only rechecked if they may be upgraded.
+type DependencyRecheck struct {
+	// Policy determines when installed dependencies are rechecked.
+	// +kubebuilder:validation:Enum=Never;OnChange;Periodic
+	// +kubebuilder:default=Never
+	Policy DependencyRecheckPolicy `json:"policy"`
+
+	// Interval between checks of each installed dependency when the policy
+	// is Periodic. A Periodic policy without an interval behaves like
+	// OnChange.
+	// +optional
+	Interval *metav1.Duration `json:"interval,omitempty"`
+}
+
+// LockSpec specifies how the Lock's dependencies are resolved. Fields that
+// are not set default to the package manager's configuration.
+type LockSpec struct {
+	// SkipDependencyInstallation specifies that missing dependencies should
+	// not be installed automatically. Missing and conflicting dependencies
+	// are still reported, but must be installed manually.
+	// +optional
+	SkipDependencyInstallation bool `json:"skipDependencyInstallation,omitempty"`
+
+	// UpgradePolicy specifies whether packages installed as dependencies
+	// are upgraded when they no longer satisfy the packages that depend on
+	// them. Defaults to None. It replaces the deprecated
+	// pkg.crossplane.io/upgrade-dependencies annotation; a Lock that sets no
+	// UpgradePolicy but is annotated "true" is treated as if its policy were
+	// Automatic.
+	// +optional
+	// +kubebuilder:validation:Enum=None;Automatic
+	UpgradePolicy *DependencyUpgradePolicy `json:"upgradePolicy,omitempty"`
+
+	// VersionSelection specifies which of the versions that satisfy a
+	// missing dependency's constraints is installed.
+	// +optional
+	// +kubebuilder:validation:Enum=Highest;Lowest
+	VersionSelection *DependencyVersionSelection `json:"versionSelection,omitempty"`
+
+	// MaxAutoInstall is the maximum number of packages that may be
+	// installed automatically to satisfy missing dependencies. 0 means
+	// unlimited.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	MaxAutoInstall *int64 `json:"maxAutoInstall,omitempty"`
+
+	// Recheck specifies when the registry is consulted again about
+	// dependencies that are already installed. Defaults to the Never
+	// policy.
+	// +optional
+	Recheck *DependencyRecheck `json:"recheck,omitempty"`
+
+	// Overrides force the version of dependencies, regardless of the
+	// constraints the packages that depend on them place on their versions.
+	// A dependency installed at an overridden version that violates those
+	// constraints is reported, but is not considered to conflict with them.
+	// +optional
+	Overrides []DependencyOverride `json:"overrides,omitempty"`
+}
+
+// A DependencyOverride forces the version of a dependency.
+type DependencyOverride struct {
+	// Package is the OCI image name of the dependency, without a tag or
+	// digest.
+	Package string `json:"package"`
+
+	// Version of the dependency to install. It may be an exact version, or
+	// a semantic version constraint, in which case a version is selected as
+	// if it were the only constraint on the dependency.
+	Version string `json:"version"`
+}
+
+// LockStatus represents the status of the Lock.
+type LockStatus struct {
+	xpv1.ConditionedStatus `json:",inline"`
+
+	// MissingDependencies is the number of dependencies that are declared by
+	// packages in the Lock but are not yet present in the Lock.
+	MissingDependencies int64 `json:"missingDependencies,omitempty"`
+
+	// Blocked lists the missing dependencies that are not being installed
+	// because a package that depends on them is not yet healthy. It is only
+	// populated when the package manager waits for the dependency packages
+	// it installs to become healthy before installing their dependencies.
+	// +optional
+	Blocked []BlockedDependency `json:"blocked,omitempty"`
+
+	// PendingApproval lists the missing dependencies that are not being
+	// installed because they have not yet been approved. It is only
+	// populated when the package manager requires dependency installs to be
+	// approved using the pkg.crossplane.io/approve-dependencies annotation.
+	// +optional
+	PendingApproval []PendingDependency `json:"pendingApproval,omitempty"`
+
+	// DependencyResolution describes each missing or conflicting dependency
+	// as of the most recent attempt to resolve the Lock's dependencies. The
+	// list is replaced on every attempt, and includes at most 50 entries.
+	// +optional
+	// +kubebuilder:validation:MaxItems=50
+	DependencyResolution []DependencyResolution `json:"dependencyResolution,omitempty"`
+
+	// OmittedDependencyResolutions is the number of missing or conflicting
+	// dependencies that were omitted from DependencyResolution because it was
+	// full, or because the Lock was too large to record them.
+	// +optional
+	OmittedDependencyResolutions int64 `json:"omittedDependencyResolutions,omitempty"`
+
+	// ResolutionStartedAt is when the package manager first observed that
+	// packages in the Lock were missing dependencies, since their
+	// dependencies were last resolved. It is cleared once every package in
+	// the Lock is installed.
+	// +optional
+	ResolutionStartedAt *metav1.Time `json:"resolutionStartedAt,omitempty"`
+
+	// ResolutionInstalled is the number of dependency packages the package
+	// manager has installed since ResolutionStartedAt.
+	// +optional
+	ResolutionInstalled int64 `json:"resolutionInstalled,omitempty"`
+
+	// ResolvingLayer is the layer of missing dependencies the package
+	// manager is installing. A missing dependency's layer is the length of
+	// the longest chain of packages in the Lock that depend on it. The
+	// package manager installs one layer at a time, starting with the
+	// shallowest, so that each package it installs can declare its own
+	// constraints before their dependencies are installed.
+	// +optional
+	ResolvingLayer int64 `json:"resolvingLayer,omitempty"`
+
+	// DependencyLayers is the deepest layer of missing dependencies. It may
+	// grow as the packages the package manager installs declare their own
+	// dependencies.
+	// +optional
+	DependencyLayers int64 `json:"dependencyLayers,omitempty"`
+
+	// Dependents describes whether the dependencies of each package in the
+	// Lock that declares any were resolved, as of the most recent attempt to
+	// resolve them. Package revisions may consult it to report themselves
+	// unhealthy until their dependencies are resolved.
+	// +optional
+	Dependents []DependentResolution `json:"dependents,omitempty"`
+
+	// Checked records when the registry was last consulted about each
+	// installed dependency, and the constraints packages placed on it at the
+	// time. It is only populated when the Lock's recheck policy is OnChange
+	// or Periodic.
+	// +optional
+	Checked []DependencyCheck `json:"checked,omitempty"`
+}
+
+// A DependencyCheck records when the registry was last consulted about an
+// installed dependency.
+type DependencyCheck struct {
+	// Package is the source of the dependency, without a tag or digest.
+	Package string `json:"package"`
+
+	// Constraints the packages that depend on the dependency placed on it
+	// when it was checked.
+	// +optional
+	Constraints []DependentConstraint `json:"constraints,omitempty"`
+
+	// LastCheckedTime is the time at which the registry was last consulted
+	// about the dependency.
+	LastCheckedTime metav1.Time `json:"lastCheckedTime"`
+}
+
+// A DependentResolution describes whether the dependencies of a package in the
+// Lock were resolved.
+type DependentResolution struct {
+	// Package is the source of the package in the Lock, without a tag or
+	// digest.
+	Package string `json:"package"`
+
+	// Version of the package whose dependencies were resolved.
+	Version string `json:"version"`
+
+	// Unresolved dependencies of the package, i.e. those that are missing,
+	// conflicting, or invalid. The package's dependencies are resolved if
+	// there are none.
+	// +optional
+	Unresolved []string `json:"unresolved,omitempty"`
+}
+
+// MaxDependencyResolutions is the maximum number of entries in a Lock's
+// DependencyResolution status.
+const MaxDependencyResolutions = 50
+
+// A DependencyResolutionState is the state of a dependency that is not yet
+// satisfied.
+type DependencyResolutionState string
+
+// Dependency resolution states.
+const (
+	// DependencyMissing indicates no package in the Lock satisfies the
+	// dependency.
+	DependencyMissing DependencyResolutionState = "Missing"
+
+	// DependencyConflicting indicates the package in the Lock that satisfies
+	// the dependency does not satisfy the constraints of every package that
+	// depends on it.
+	DependencyConflicting DependencyResolutionState = "Conflicting"
+
+	// DependencyOptional indicates an optional dependency that is either not
+	// installed, or installed at a version that doesn't satisfy the
+	// constraints of every package that depends on it. Optional dependencies
+	// are never installed automatically.
+	DependencyOptional DependencyResolutionState = "Optional"
+)
+
+// A DependencyResolution describes a missing or conflicting dependency, as of
+// the most recent attempt to resolve it.
+type DependencyResolution struct {
+	// Package is the OCI image name of the dependency, without a tag or
+	// digest.
+	Package string `json:"package"`
+
+	// State of the dependency.
+	State DependencyResolutionState `json:"state"`
+
+	// Constraints the packages that depend on the dependency place on its
+	// version.
+	// +optional
+	Constraints []DependentConstraint `json:"constraints,omitempty"`
+
+	// LastAttemptTime is the time at which the dependency was most recently
+	// resolved.
+	// +optional
+	LastAttemptTime *metav1.Time `json:"lastAttemptTime,omitempty"`
+
+	// Reason the dependency could not be resolved, if it could not.
+	// +optional
+	Reason xpv1.ConditionReason `json:"reason,omitempty"`
+
+	// Message is a human-readable explanation of why the dependency could
+	// not be resolved, if it could not.
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// Version of the dependency that would be installed if it is missing, or
+	// that is installed if it is conflicting.
+	// +optional
+	Version string `json:"version,omitempty"`
+
+	// NextRetryTime is the time at which the dependency will next be
+	// resolved, if no version of it satisfied its constraints.
+	// +optional
+	NextRetryTime *metav1.Time `json:"nextRetryTime,omitempty"`
+}
+
+// A DependentConstraint is the version constraint a package places on one of
+// its dependencies.
+type DependentConstraint struct {
+	// Dependent is the source of the package that depends on the dependency.
+	Dependent string `json:"dependent"`
+
+	// Constraints the dependent places on the dependency's version.
+	Constraints string `json:"constraints"`
+}
+
+// A BlockedDependency is a missing dependency that is not being installed
+// because a package that depends on it is not yet healthy.
+type BlockedDependency struct {
+	// Package is the OCI image name of the missing dependency, without a tag
+	// or digest.
+	Package string `json:"package"`
+
+	// BlockedOn is the source of the package that depends on the missing
+	// dependency, and is not yet healthy.
+	BlockedOn string `json:"blockedOn"`
+
+	// Reason the package that depends on the missing dependency is not yet
+	// healthy.
+	Reason xpv1.ConditionReason `json:"reason"`
+
+	// Message is a human-readable explanation of why the missing dependency
+	// is not being installed.
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// A PendingDependency is a missing dependency that is not being installed
+// because it has not yet been approved.
+type PendingDependency struct {
+	// Package is the OCI image name of the missing dependency, without a tag
+	// or digest. Approve the dependency by adding it to the Lock's
+	// pkg.crossplane.io/approve-dependencies annotation.
+	Package string `json:"package"`
+
+	// Type of the package that would be installed.
+	Type PackageType `json:"type"`
+
+	// Source of the package that would be installed, including its tag or
+	// digest.
+	// +optional
+	Source string `json:"source,omitempty"`
+}
+
 // +kubebuilder:object:root=true
 // +genclient
 // +genclient:nonNamespaced
@@ -122,6 +540,9 @@ func (d *Dependency) AddNeighbors(...dag.Node) error {
 // Lock is the CRD type that tracks package dependencies.
 // +kubebuilder:storageversion
 // +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="RESOLVED",type="string",JSONPath=".status.conditions[?(@.type=='DependenciesResolved')].status"
+// +kubebuilder:printcolumn:name="SATISFIED",type="string",JSONPath=".status.conditions[?(@.type=='DependenciesSatisfied')].status"
+// +kubebuilder:printcolumn:name="MISSING",type="string",JSONPath=".status.missingDependencies"
 // +kubebuilder:printcolumn:name="AGE",type="date",JSONPath=".metadata.creationTimestamp"
 // +kubebuilder:resource:scope=Cluster
 type Lock struct {
@@ -129,6 +550,20 @@ type Lock struct {
 	metav1.ObjectMeta `json:"metadata,omitempty"`
 
 	Packages []LockPackage `json:"packages,omitempty"`
+
+	Spec LockSpec `json:"spec,omitempty"`
+
+	Status LockStatus `json:"status,omitempty"`
+}
+
+// GetCondition of this Lock.
+func (l *Lock) GetCondition(ct xpv1.ConditionType) xpv1.Condition {
+	return l.Status.GetCondition(ct)
+}
+
+// SetConditions of this Lock.
+func (l *Lock) SetConditions(c ...xpv1.Condition) {
+	l.Status.SetConditions(c...)
 }
 
 // +kubebuilder:object:root=true
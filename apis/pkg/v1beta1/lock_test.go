@@ -0,0 +1,144 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestLockPackageOrigin(t *testing.T) {
+	cases := map[string]struct {
+		reason string
+		data   string
+		want   PackageOrigin
+	}{
+		"Unknown": {
+			reason: "A package added to the lock before origins were recorded should have been installed by a user.",
+			data:   `{"name":"provider-aws","type":"Provider","source":"crossplane/provider-aws","version":"v0.20.0","dependencies":[]}`,
+			want:   PackageOrigin{Type: OriginUser},
+		},
+		"EmptyType": {
+			reason: "A package whose origin has no type should have been installed by a user.",
+			data:   `{"name":"provider-aws","source":"crossplane/provider-aws","origin":{}}`,
+			want:   PackageOrigin{Type: OriginUser},
+		},
+		"User": {
+			reason: "We should return the origin of a package a user installed.",
+			data:   `{"name":"provider-aws","source":"crossplane/provider-aws","origin":{"type":"User"}}`,
+			want:   PackageOrigin{Type: OriginUser},
+		},
+		"Dependency": {
+			reason: "We should return the origin of a package installed as a dependency, including its parents.",
+			data:   `{"name":"provider-aws","source":"crossplane/provider-aws","origin":{"type":"Dependency","parents":["cool/config-a","cool/config-b"]}}`,
+			want:   PackageOrigin{Type: OriginDependency, Parents: []string{"cool/config-a", "cool/config-b"}},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			lp := &LockPackage{}
+			if err := json.Unmarshal([]byte(tc.data), lp); err != nil {
+				t.Fatalf("\n%s\njson.Unmarshal(...): %s", tc.reason, err)
+			}
+			if diff := cmp.Diff(tc.want, lp.GetOrigin()); diff != "" {
+				t.Errorf("\n%s\nGetOrigin(): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestLockPackageOriginOmitted(t *testing.T) {
+	// Locks written before origins were recorded must round trip unchanged.
+	b, err := json.Marshal(LockPackage{Name: "provider-aws", Type: ProviderPackageType, Source: "crossplane/provider-aws", Version: "v0.20.0"})
+	if err != nil {
+		t.Fatalf("json.Marshal(...): %s", err)
+	}
+	want := `{"name":"provider-aws","type":"Provider","source":"crossplane/provider-aws","version":"v0.20.0","dependencies":null}`
+	if diff := cmp.Diff(want, string(b)); diff != "" {
+		t.Errorf("json.Marshal(...): -want, +got:\n%s", diff)
+	}
+}
+
+func TestLockStatusDependencyResolution(t *testing.T) {
+	at := metav1.NewTime(time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC))
+
+	cases := map[string]struct {
+		reason string
+		status LockStatus
+		data   string
+	}{
+		"Empty": {
+			reason: "A Lock with no missing or conflicting dependencies should omit its dependency resolution status.",
+			status: LockStatus{MissingDependencies: 0},
+			data:   `{}`,
+		},
+		"Missing": {
+			reason: "We should round trip a missing dependency, including the constraint each dependent places on it.",
+			status: LockStatus{
+				MissingDependencies: 1,
+				DependencyResolution: []DependencyResolution{{
+					Package:         "crossplane/provider-aws",
+					State:           DependencyMissing,
+					Constraints:     []DependentConstraint{{Dependent: "cool/config", Constraints: ">=v0.20.0"}},
+					LastAttemptTime: &at,
+					Reason:          ReasonNoValidVersion,
+					Message:         "no valid version",
+				}},
+			},
+			data: `{"missingDependencies":1,"dependencyResolution":[{"package":"crossplane/provider-aws","state":"Missing","constraints":[{"dependent":"cool/config","constraints":"\u003e=v0.20.0"}],"lastAttemptTime":"2021-06-01T00:00:00Z","reason":"NoValidVersion","message":"no valid version"}]}`,
+		},
+		"Truncated": {
+			reason: "We should round trip a conflicting dependency, and the number of dependencies omitted.",
+			status: LockStatus{
+				DependencyResolution: []DependencyResolution{{
+					Package: "crossplane/provider-aws",
+					State:   DependencyConflicting,
+					Version: "v0.20.0",
+				}},
+				OmittedDependencyResolutions: 2,
+			},
+			data: `{"dependencyResolution":[{"package":"crossplane/provider-aws","state":"Conflicting","version":"v0.20.0"}],"omittedDependencyResolutions":2}`,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			b, err := json.Marshal(tc.status)
+			if err != nil {
+				t.Fatalf("\n%s\njson.Marshal(...): %s", tc.reason, err)
+			}
+			if diff := cmp.Diff(tc.data, string(b)); diff != "" {
+				t.Errorf("\n%s\njson.Marshal(...): -want, +got:\n%s", tc.reason, diff)
+			}
+			got := LockStatus{}
+			if err := json.Unmarshal(b, &got); err != nil {
+				t.Fatalf("\n%s\njson.Unmarshal(...): %s", tc.reason, err)
+			}
+			if diff := cmp.Diff(tc.status, got); diff != "" {
+				t.Errorf("\n%s\njson.Unmarshal(...): -want, +got:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.status, *tc.status.DeepCopy()); diff != "" {
+				t.Errorf("\n%s\nDeepCopy(): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
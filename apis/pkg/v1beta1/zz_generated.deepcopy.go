@@ -24,9 +24,25 @@ import (
 	commonv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
 	"k8s.io/api/core/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BlockedDependency) DeepCopyInto(out *BlockedDependency) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BlockedDependency.
+func (in *BlockedDependency) DeepCopy() *BlockedDependency {
+	if in == nil {
+		return nil
+	}
+	out := new(BlockedDependency)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Configuration) DeepCopyInto(out *Configuration) {
 	*out = *in
@@ -193,6 +209,125 @@ func (in *Dependency) DeepCopy() *Dependency {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DependencyCheck) DeepCopyInto(out *DependencyCheck) {
+	*out = *in
+	if in.Constraints != nil {
+		in, out := &in.Constraints, &out.Constraints
+		*out = make([]DependentConstraint, len(*in))
+		copy(*out, *in)
+	}
+	in.LastCheckedTime.DeepCopyInto(&out.LastCheckedTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DependencyCheck.
+func (in *DependencyCheck) DeepCopy() *DependencyCheck {
+	if in == nil {
+		return nil
+	}
+	out := new(DependencyCheck)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DependencyOverride) DeepCopyInto(out *DependencyOverride) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DependencyOverride.
+func (in *DependencyOverride) DeepCopy() *DependencyOverride {
+	if in == nil {
+		return nil
+	}
+	out := new(DependencyOverride)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DependencyRecheck) DeepCopyInto(out *DependencyRecheck) {
+	*out = *in
+	if in.Interval != nil {
+		in, out := &in.Interval, &out.Interval
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DependencyRecheck.
+func (in *DependencyRecheck) DeepCopy() *DependencyRecheck {
+	if in == nil {
+		return nil
+	}
+	out := new(DependencyRecheck)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DependencyResolution) DeepCopyInto(out *DependencyResolution) {
+	*out = *in
+	if in.Constraints != nil {
+		in, out := &in.Constraints, &out.Constraints
+		*out = make([]DependentConstraint, len(*in))
+		copy(*out, *in)
+	}
+	if in.LastAttemptTime != nil {
+		in, out := &in.LastAttemptTime, &out.LastAttemptTime
+		*out = (*in).DeepCopy()
+	}
+	if in.NextRetryTime != nil {
+		in, out := &in.NextRetryTime, &out.NextRetryTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DependencyResolution.
+func (in *DependencyResolution) DeepCopy() *DependencyResolution {
+	if in == nil {
+		return nil
+	}
+	out := new(DependencyResolution)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DependentConstraint) DeepCopyInto(out *DependentConstraint) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DependentConstraint.
+func (in *DependentConstraint) DeepCopy() *DependentConstraint {
+	if in == nil {
+		return nil
+	}
+	out := new(DependentConstraint)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DependentResolution) DeepCopyInto(out *DependentResolution) {
+	*out = *in
+	if in.Unresolved != nil {
+		in, out := &in.Unresolved, &out.Unresolved
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DependentResolution.
+func (in *DependentResolution) DeepCopy() *DependentResolution {
+	if in == nil {
+		return nil
+	}
+	out := new(DependentResolution)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Lock) DeepCopyInto(out *Lock) {
 	*out = *in
@@ -205,6 +340,8 @@ func (in *Lock) DeepCopyInto(out *Lock) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Lock.
@@ -265,6 +402,11 @@ func (in *LockPackage) DeepCopyInto(out *LockPackage) {
 		*out = make([]Dependency, len(*in))
 		copy(*out, *in)
 	}
+	if in.Origin != nil {
+		in, out := &in.Origin, &out.Origin
+		*out = new(PackageOrigin)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LockPackage.
@@ -277,6 +419,117 @@ func (in *LockPackage) DeepCopy() *LockPackage {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LockSpec) DeepCopyInto(out *LockSpec) {
+	*out = *in
+	if in.UpgradePolicy != nil {
+		in, out := &in.UpgradePolicy, &out.UpgradePolicy
+		*out = new(DependencyUpgradePolicy)
+		**out = **in
+	}
+	if in.VersionSelection != nil {
+		in, out := &in.VersionSelection, &out.VersionSelection
+		*out = new(DependencyVersionSelection)
+		**out = **in
+	}
+	if in.MaxAutoInstall != nil {
+		in, out := &in.MaxAutoInstall, &out.MaxAutoInstall
+		*out = new(int64)
+		**out = **in
+	}
+	if in.Recheck != nil {
+		in, out := &in.Recheck, &out.Recheck
+		*out = new(DependencyRecheck)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Overrides != nil {
+		in, out := &in.Overrides, &out.Overrides
+		*out = make([]DependencyOverride, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LockSpec.
+func (in *LockSpec) DeepCopy() *LockSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(LockSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LockStatus) DeepCopyInto(out *LockStatus) {
+	*out = *in
+	in.ConditionedStatus.DeepCopyInto(&out.ConditionedStatus)
+	if in.Blocked != nil {
+		in, out := &in.Blocked, &out.Blocked
+		*out = make([]BlockedDependency, len(*in))
+		copy(*out, *in)
+	}
+	if in.PendingApproval != nil {
+		in, out := &in.PendingApproval, &out.PendingApproval
+		*out = make([]PendingDependency, len(*in))
+		copy(*out, *in)
+	}
+	if in.DependencyResolution != nil {
+		in, out := &in.DependencyResolution, &out.DependencyResolution
+		*out = make([]DependencyResolution, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ResolutionStartedAt != nil {
+		in, out := &in.ResolutionStartedAt, &out.ResolutionStartedAt
+		*out = (*in).DeepCopy()
+	}
+	if in.Dependents != nil {
+		in, out := &in.Dependents, &out.Dependents
+		*out = make([]DependentResolution, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Checked != nil {
+		in, out := &in.Checked, &out.Checked
+		*out = make([]DependencyCheck, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LockStatus.
+func (in *LockStatus) DeepCopy() *LockStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(LockStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PackageOrigin) DeepCopyInto(out *PackageOrigin) {
+	*out = *in
+	if in.Parents != nil {
+		in, out := &in.Parents, &out.Parents
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PackageOrigin.
+func (in *PackageOrigin) DeepCopy() *PackageOrigin {
+	if in == nil {
+		return nil
+	}
+	out := new(PackageOrigin)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PackageRevisionSpec) DeepCopyInto(out *PackageRevisionSpec) {
 	*out = *in
@@ -406,6 +659,21 @@ func (in *PackageStatus) DeepCopy() *PackageStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PendingDependency) DeepCopyInto(out *PendingDependency) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PendingDependency.
+func (in *PendingDependency) DeepCopy() *PendingDependency {
+	if in == nil {
+		return nil
+	}
+	out := new(PendingDependency)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Provider) DeepCopyInto(out *Provider) {
 	*out = *in
@@ -17,6 +17,7 @@ limitations under the License.
 package core
 
 import (
+	"strings"
 	"time"
 
 	"github.com/alecthomas/kong"
@@ -31,7 +32,11 @@ import (
 
 	"github.com/crossplane/crossplane/internal/controller/apiextensions"
 	"github.com/crossplane/crossplane/internal/controller/pkg"
+	"github.com/crossplane/crossplane/internal/controller/pkg/resolver"
 	"github.com/crossplane/crossplane/internal/feature"
+	"github.com/crossplane/crossplane/internal/version"
+	"github.com/crossplane/crossplane/internal/webhook/dependency"
+	"github.com/crossplane/crossplane/internal/webhook/lock"
 	"github.com/crossplane/crossplane/internal/xpkg"
 )
 
@@ -45,6 +50,7 @@ type Command struct {
 // required for the Registry default variable interpolation.
 var KongVars = kong.Vars{
 	"default_registry": name.DefaultRegistry,
+	"default_gc_grace": resolver.DefaultOrphanGracePeriod.String(),
 }
 
 // Run is the no-op method required for kong call tree
@@ -61,7 +67,47 @@ type startCommand struct {
 	Registry       string        `short:"r" help:"Default registry used to fetch packages when not specified in tag." default:"${default_registry}" env:"REGISTRY"`
 	Sync           time.Duration `short:"s" help:"Controller manager sync period duration such as 300ms, 1.5h or 2h45m" default:"1h"`
 
-	EnableCompositionRevisions bool `group:"Alpha Features:" help:"Enable support for CompositionRevisions."`
+	RegistryRewrites       []string          `help:"Rewrite the registry of dependency packages, e.g. xpkg.upbound.io=>registry.example.org/mirror. The most specific rule wins." env:"REGISTRY_REWRITES"`
+	MaxAutoInstall         int               `help:"Maximum number of packages the package manager may install to satisfy dependencies. 0 means unlimited." default:"0" env:"MAX_AUTO_INSTALL"`
+	VersionChannel         string            `help:"Name of a ConfigMap in the namespace that lists the versions dependency packages may be installed at, by repository. Repository tags are used if unset." env:"VERSION_CHANNEL"`
+	StrictChannel          bool              `help:"Don't install dependency packages whose repository the version channel doesn't list, rather than selecting from the repository's tags." default:"false" env:"STRICT_CHANNEL"`
+	VersionDenylist        string            `help:"Name of a ConfigMap in the namespace that lists the versions dependency packages must never be installed or upgraded to, by repository, e.g. versions that were yanked." env:"VERSION_DENYLIST"`
+	PackageAliases         string            `help:"Name of a ConfigMap in the namespace that maps the repositories dependency packages are declared against to the repositories that satisfy them, e.g. forks." env:"PACKAGE_ALIASES"`
+	StrictDigests          bool              `help:"Treat packages installed by digest as conflicting with the version constraints of packages that depend on them, rather than assuming the constraints are satisfied." default:"false" env:"STRICT_DIGESTS"`
+	LockStatusBudget       int               `help:"Maximum size in bytes of the package Lock, beyond which its status is compacted to a summary and details are recorded as events. 0 means unlimited." default:"1048576" env:"LOCK_STATUS_BUDGET"`
+	VersionGroups          map[string]string `help:"Repository prefixes of package families whose dependency packages must all be installed at the same major.minor version, mapped to the name of the family, e.g. xpkg.upbound.io/upbound/provider-aws-=aws." env:"VERSION_GROUPS"`
+	AllowedSources         []string          `help:"Patterns matching the only repositories dependency packages may be installed from, e.g. xpkg.upbound.io/crossplane/*. Any path segment may contain wildcards, and ** matches any number of segments. Every repository is allowed if unset." env:"ALLOWED_PACKAGE_SOURCES"`
+	DeniedSources          []string          `help:"Patterns matching repositories dependency packages must never be installed from, e.g. xpkg.upbound.io/example/*. Takes precedence over allowed sources." env:"DENIED_PACKAGE_SOURCES"`
+	RollbackWindow         time.Duration     `help:"How long after the package manager upgrades a dependency package to watch the packages that depend on it, and roll the upgrade back to the last version they were all healthy with if any of them becomes unhealthy. 0 disables rollback." default:"0" env:"UPGRADE_ROLLBACK_WINDOW"`
+	DependencyDryRun       bool              `help:"Report the dependency packages the package manager would install, upgrade, or remove in the status and events of the package Lock, without writing any package." default:"false" env:"DEPENDENCY_DRY_RUN"`
+	StrictDependencyHealth bool              `help:"Report a package revision that declares dependencies healthy only once all of its dependencies are resolved." default:"false" env:"STRICT_DEPENDENCY_HEALTH"`
+	DependencyGCPolicy     string            `help:"What to do with a dependency package the package manager installed once no package requires it. Orphan labels it, Delete deletes it once it has been orphaned for the grace period." default:"Orphan" enum:"Orphan,Delete" env:"DEPENDENCY_GC_POLICY"`
+	DependencyGCGrace      time.Duration     `help:"How long a dependency package must be required by no package before it is deleted, when the dependency GC policy is Delete. The default spans several default sync periods, giving the package manager several chances to observe that the package is required again, e.g. after the package requiring it is upgraded." default:"${default_gc_grace}" env:"DEPENDENCY_GC_GRACE_PERIOD"`
+	DependencyAuditLog     string            `help:"Name of a ConfigMap in the namespace in which to record the most recent dependency packages the package manager installed, upgraded, rolled back, blocked, or deleted. Nothing is recorded if unset." env:"DEPENDENCY_AUDIT_LOG"`
+	DependencyCompatCheck  bool              `help:"Refuse to install a dependency package whose metadata declares that it requires a version of Crossplane other than the one that is running." default:"false" env:"DEPENDENCY_COMPATIBILITY_CHECK"`
+
+	CABundlePath            string        `help:"Path to a bundle of PEM encoded CAs to trust, in addition to the system's CAs, when fetching the tags of dependency packages." env:"CA_BUNDLE_PATH"`
+	RegistryCABundles       []string      `help:"Paths to bundles of PEM encoded CAs to trust when fetching the tags of dependency packages from a particular registry, e.g. registry.example.org:5000=/certs/ca.crt." env:"REGISTRY_CA_BUNDLES"`
+	InsecureRegistries      []string      `help:"Registries to connect to using plain HTTP when fetching the tags of dependency packages." env:"INSECURE_REGISTRIES"`
+	RegistryConnectTimeout  time.Duration `help:"How long to wait to connect to a registry when fetching the tags of dependency packages. Connections honor the HTTPS_PROXY, HTTP_PROXY, and NO_PROXY environment variables." env:"REGISTRY_CONNECT_TIMEOUT"`
+	RegistryKeychain        []string      `help:"Sources of the credentials presented to registries when fetching the tags of dependency packages, in the order they are consulted. Ambient credentials are obtained from the cloud environment, e.g. IAM Roles for Service Accounts on EKS or Workload Identity on GKE, by the credential providers Kubernetes uses to pull images. PullSecrets are those of Crossplane's service account and the configured dependency pull secrets, and never include ambient credentials." default:"Ambient,PullSecrets,Anonymous" env:"REGISTRY_KEYCHAIN"`
+	DependencyPullSecrets   []string      `help:"Pull secrets in Crossplane's namespace to use when fetching the tags of dependency packages, and to add to every dependency package that is installed automatically." env:"DEPENDENCY_PULL_SECRETS"`
+	DependencyRuntimeConfig string        `help:"Name of a ControllerConfig to reference from every Provider that is installed automatically as a dependency." env:"DEPENDENCY_RUNTIME_CONFIG"`
+
+	WebhookTLSCertDir       string `help:"Directory containing the TLS certificate and key the admission webhook serves with." env:"WEBHOOK_TLS_CERT_DIR"`
+	WebhookPort             int    `help:"Port on which the admission webhooks are served." default:"9443" env:"WEBHOOK_PORT"`
+	DependencyAdmissionMode string `help:"What to do with a Configuration or Provider whose dependencies cannot be satisfied when dependency admission is enabled." enum:"Warn,Reject" default:"Warn" env:"DEPENDENCY_ADMISSION_MODE"`
+
+	EnableCompositionRevisions        bool `group:"Alpha Features:" help:"Enable support for CompositionRevisions."`
+	EnableDependencyHealthGating      bool `group:"Alpha Features:" help:"Wait for dependency packages to become healthy before installing their dependencies."`
+	EnableLockRepair                  bool `group:"Alpha Features:" help:"Remove stale duplicate packages from the package Lock."`
+	EnableDependencyAdmission         bool `group:"Alpha Features:" help:"Validate that the dependencies of a Configuration or Provider can be satisfied when it is created or its package changes."`
+	EnableDigestPinning               bool `group:"Alpha Features:" help:"Install dependency packages at the digest their selected tag points at, verifying it against the digest the version channel records."`
+	EnableDependencyApproval          bool `group:"Alpha Features:" help:"Require that dependency packages be approved using an annotation on the package Lock before they are installed."`
+	EnableLockVerification            bool `group:"Alpha Features:" help:"Treat packages in the package Lock whose package revision no longer exists as missing dependencies."`
+	EnableLockCompaction              bool `group:"Alpha Features:" help:"Remove packages from the package Lock that were installed as dependencies, that no user-installed package depends on, and that have no healthy package revision."`
+	EnableDependencyConflictDetection bool `group:"Alpha Features:" help:"Refuse to install a dependency provider that serves an API group served by an installed provider from another repository."`
+	EnableLockAdmission               bool `group:"Alpha Features:" help:"Reject changes to the package Lock that introduce unparseable package sources, packages from the same repository, or dependency cycles."`
 }
 
 // Run core Crossplane controllers.
@@ -77,6 +123,8 @@ func (c *startCommand) Run(s *runtime.Scheme, log logging.Logger) error {
 		LeaderElection:   c.LeaderElection,
 		LeaderElectionID: "crossplane-leader-election-core",
 		SyncPeriod:       &c.Sync,
+		CertDir:          c.WebhookTLSCertDir,
+		Port:             c.WebhookPort,
 	})
 	if err != nil {
 		return errors.Wrap(err, "Cannot create manager")
@@ -87,6 +135,42 @@ func (c *startCommand) Run(s *runtime.Scheme, log logging.Logger) error {
 		f.Enable(feature.FlagEnableAlphaCompositionRevisions)
 		log.Info("Alpha feature enabled", "flag", feature.FlagEnableAlphaCompositionRevisions.String())
 	}
+	if c.EnableDependencyHealthGating {
+		f.Enable(feature.FlagEnableAlphaDependencyHealthGating)
+		log.Info("Alpha feature enabled", "flag", feature.FlagEnableAlphaDependencyHealthGating.String())
+	}
+	if c.EnableLockRepair {
+		f.Enable(feature.FlagEnableAlphaLockRepair)
+		log.Info("Alpha feature enabled", "flag", feature.FlagEnableAlphaLockRepair.String())
+	}
+	if c.EnableDependencyAdmission {
+		f.Enable(feature.FlagEnableAlphaDependencyAdmission)
+		log.Info("Alpha feature enabled", "flag", feature.FlagEnableAlphaDependencyAdmission.String())
+	}
+	if c.EnableDigestPinning {
+		f.Enable(feature.FlagEnableAlphaDigestPinning)
+		log.Info("Alpha feature enabled", "flag", feature.FlagEnableAlphaDigestPinning.String())
+	}
+	if c.EnableDependencyApproval {
+		f.Enable(feature.FlagEnableAlphaDependencyApproval)
+		log.Info("Alpha feature enabled", "flag", feature.FlagEnableAlphaDependencyApproval.String())
+	}
+	if c.EnableLockVerification {
+		f.Enable(feature.FlagEnableAlphaLockVerification)
+		log.Info("Alpha feature enabled", "flag", feature.FlagEnableAlphaLockVerification.String())
+	}
+	if c.EnableLockCompaction {
+		f.Enable(feature.FlagEnableAlphaLockCompaction)
+		log.Info("Alpha feature enabled", "flag", feature.FlagEnableAlphaLockCompaction.String())
+	}
+	if c.EnableDependencyConflictDetection {
+		f.Enable(feature.FlagEnableAlphaDependencyConflictDetection)
+		log.Info("Alpha feature enabled", "flag", feature.FlagEnableAlphaDependencyConflictDetection.String())
+	}
+	if c.EnableLockAdmission {
+		f.Enable(feature.FlagEnableAlphaLockAdmission)
+		log.Info("Alpha feature enabled", "flag", feature.FlagEnableAlphaLockAdmission.String())
+	}
 
 	if err := apiextensions.Setup(mgr, log, f); err != nil {
 		return errors.Wrap(err, "Cannot setup API extension controllers")
@@ -94,9 +178,129 @@ func (c *startCommand) Run(s *runtime.Scheme, log logging.Logger) error {
 
 	pkgCache := xpkg.NewImageCache(c.CacheDir, afero.NewOsFs())
 
-	if err := pkg.Setup(mgr, log, pkgCache, c.Namespace, c.Registry); err != nil {
+	ropts, err := c.resolverOptions(mgr)
+	if err != nil {
+		return err
+	}
+
+	o := pkg.Options{
+		Namespace:              c.Namespace,
+		Registry:               c.Registry,
+		StrictDependencyHealth: c.StrictDependencyHealth,
+		Resolver:               ropts,
+	}
+	if err := pkg.Setup(mgr, log, f, pkgCache, o); err != nil {
 		return errors.Wrap(err, "Cannot add packages controllers to manager")
 	}
 
+	if f.Enabled(feature.FlagEnableAlphaDependencyAdmission) {
+		if err := dependency.Setup(mgr, log, c.Namespace, c.Registry, dependency.Mode(c.DependencyAdmissionMode)); err != nil {
+			return errors.Wrap(err, "Cannot add dependency admission webhook to manager")
+		}
+	}
+
+	if f.Enabled(feature.FlagEnableAlphaLockAdmission) {
+		if err := lock.Setup(mgr, log); err != nil {
+			return errors.Wrap(err, "Cannot add package Lock admission webhook to manager")
+		}
+	}
+
 	return errors.Wrap(mgr.Start(ctrl.SetupSignalHandler()), "Cannot start controller manager")
 }
+
+// resolverOptions returns the options that configure how the dependencies of
+// packages are resolved.
+func (c *startCommand) resolverOptions(mgr ctrl.Manager) ([]resolver.ReconcilerOption, error) {
+	rewrites, err := resolver.ParseRegistryRewrites(c.RegistryRewrites)
+	if err != nil {
+		return nil, errors.Wrap(err, "Cannot parse registry rewrites")
+	}
+
+	fopts, err := c.registryTransport()
+	if err != nil {
+		return nil, errors.Wrap(err, "Cannot parse registry transport settings")
+	}
+
+	if err := (xpkg.SourcePolicy{Allow: c.AllowedSources, Deny: c.DeniedSources}).Validate(); err != nil {
+		return nil, errors.Wrap(err, "Cannot parse package source policy")
+	}
+
+	opts := []resolver.ReconcilerOption{
+		resolver.WithRegistryRewrites(rewrites...),
+		resolver.WithMaxAutoInstall(c.MaxAutoInstall),
+		resolver.WithFetcherOptions(fopts...),
+		resolver.WithDefaultPullSecrets(c.DependencyPullSecrets),
+		resolver.WithPackageAliases(c.PackageAliases),
+		resolver.WithStatusBudget(c.LockStatusBudget),
+		resolver.WithDependencyOwnership(resolver.DependencyGCPolicy(c.DependencyGCPolicy)),
+		resolver.WithOrphanGracePeriod(c.DependencyGCGrace),
+		resolver.WithDryRun(c.DependencyDryRun),
+	}
+	if c.VersionChannel != "" {
+		opts = append(opts, resolver.WithVersionSource(resolver.NewChannelVersionSource(mgr.GetClient(), c.Namespace, c.VersionChannel, c.Registry)))
+	}
+	if c.VersionChannel != "" && !c.StrictChannel {
+		opts = append(opts, resolver.WithTagFallback())
+	}
+	if c.StrictDigests {
+		opts = append(opts, resolver.WithStrictDigests())
+	}
+	if c.DependencyRuntimeConfig != "" {
+		opts = append(opts, resolver.WithDefaultRuntimeConfigRef(c.DependencyRuntimeConfig))
+	}
+	if len(c.VersionGroups) > 0 {
+		opts = append(opts, resolver.WithVersionGroups(c.VersionGroups))
+	}
+	if len(c.AllowedSources) > 0 || len(c.DeniedSources) > 0 {
+		opts = append(opts, resolver.WithPackageSourcePolicy(c.AllowedSources, c.DeniedSources))
+	}
+	if c.RollbackWindow > 0 {
+		opts = append(opts, resolver.WithUpgradeRollback(c.RollbackWindow))
+	}
+	if c.DependencyAuditLog != "" {
+		opts = append(opts, resolver.WithAuditSink(resolver.NewConfigMapAuditSink(mgr.GetClient(), c.Namespace, c.DependencyAuditLog, resolver.DefaultAuditEntries)))
+	}
+	if c.VersionDenylist != "" {
+		opts = append(opts, resolver.WithVersionDenylist(resolver.NewConfigMapDenylist(mgr.GetClient(), c.Namespace, c.VersionDenylist, c.Registry)))
+	}
+	if c.DependencyCompatCheck {
+		opts = append(opts, resolver.WithCrossplaneVersion(version.New().GetVersionString()))
+	}
+	return opts, nil
+}
+
+// registryTransport returns the options that configure how dependency package
+// tags are fetched from registries.
+func (c *startCommand) registryTransport() ([]xpkg.FetcherOption, error) {
+	opts := []xpkg.FetcherOption{xpkg.WithInsecureRegistries(c.InsecureRegistries...)}
+	keychain, err := xpkg.ParseKeychainOrder(c.RegistryKeychain)
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts, xpkg.WithKeychainOrder(keychain...))
+	if c.RegistryConnectTimeout > 0 {
+		opts = append(opts, xpkg.WithConnectTimeout(c.RegistryConnectTimeout))
+	}
+	if c.CABundlePath != "" {
+		pool, err := xpkg.ParseCertificatesFromPath(c.CABundlePath)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, xpkg.WithCustomCA(pool))
+	}
+	for _, rc := range c.RegistryCABundles {
+		host, path := rc, ""
+		if i := strings.Index(rc, "="); i > 0 {
+			host, path = rc[:i], rc[i+1:]
+		}
+		if path == "" {
+			return nil, errors.Errorf("registry CA bundle %q must be of the form registry=path", rc)
+		}
+		pool, err := xpkg.ParseCertificatesFromPath(path)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot parse CA bundle for registry %s", host)
+		}
+		opts = append(opts, xpkg.WithRegistryCA(host, pool))
+	}
+	return opts, nil
+}
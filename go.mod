@@ -15,7 +15,13 @@ require (
 	github.com/google/go-containerregistry/pkg/authn/k8schain v0.0.0-20210330174036-3259211c1f24
 	github.com/imdario/mergo v0.3.12
 	github.com/pkg/errors v0.9.1
+	github.com/prometheus/client_golang v1.11.0
 	github.com/spf13/afero v1.6.0
+	github.com/vdemeester/k8s-pkg-credentialprovider v1.19.7
+	go.opentelemetry.io/otel v1.0.1
+	go.opentelemetry.io/otel/sdk v1.0.1
+	go.opentelemetry.io/otel/trace v1.0.1
+	golang.org/x/sync v0.0.0-20210220032951-036812b2e83c
 	golang.org/x/tools v0.1.5
 	k8s.io/api v0.21.3
 	k8s.io/apiextensions-apiserver v0.21.3
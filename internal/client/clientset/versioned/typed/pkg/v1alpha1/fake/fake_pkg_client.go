@@ -32,6 +32,14 @@ func (c *FakePkgV1alpha1) ControllerConfigs() v1alpha1.ControllerConfigInterface
 	return &FakeControllerConfigs{c}
 }
 
+func (c *FakePkgV1alpha1) Functions() v1alpha1.FunctionInterface {
+	return &FakeFunctions{c}
+}
+
+func (c *FakePkgV1alpha1) FunctionRevisions() v1alpha1.FunctionRevisionInterface {
+	return &FakeFunctionRevisions{c}
+}
+
 func (c *FakePkgV1alpha1) Locks() v1alpha1.LockInterface {
 	return &FakeLocks{c}
 }
@@ -20,4 +20,8 @@ package v1alpha1
 
 type ControllerConfigExpansion interface{}
 
+type FunctionExpansion interface{}
+
+type FunctionRevisionExpansion interface{}
+
 type LockExpansion interface{}
@@ -27,6 +27,8 @@ import (
 type PkgV1alpha1Interface interface {
 	RESTClient() rest.Interface
 	ControllerConfigsGetter
+	FunctionsGetter
+	FunctionRevisionsGetter
 	LocksGetter
 }
 
@@ -39,6 +41,14 @@ func (c *PkgV1alpha1Client) ControllerConfigs() ControllerConfigInterface {
 	return newControllerConfigs(c)
 }
 
+func (c *PkgV1alpha1Client) Functions() FunctionInterface {
+	return newFunctions(c)
+}
+
+func (c *PkgV1alpha1Client) FunctionRevisions() FunctionRevisionInterface {
+	return newFunctionRevisions(c)
+}
+
 func (c *PkgV1alpha1Client) Locks() LockInterface {
 	return newLocks(c)
 }
@@ -96,6 +96,17 @@ func (c *FakeLocks) Update(ctx context.Context, lock *v1beta1.Lock, opts v1.Upda
 	return obj.(*v1beta1.Lock), err
 }
 
+// UpdateStatus was generated because the type contains a Status member.
+// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+func (c *FakeLocks) UpdateStatus(ctx context.Context, lock *v1beta1.Lock, opts v1.UpdateOptions) (*v1beta1.Lock, error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewRootUpdateSubresourceAction(locksResource, "status", lock), &v1beta1.Lock{})
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1beta1.Lock), err
+}
+
 // Delete takes name of the lock and deletes it. Returns an error if one occurs.
 func (c *FakeLocks) Delete(ctx context.Context, name string, opts v1.DeleteOptions) error {
 	_, err := c.Fake.
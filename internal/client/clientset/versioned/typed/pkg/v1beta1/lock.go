@@ -40,6 +40,7 @@ type LocksGetter interface {
 type LockInterface interface {
 	Create(ctx context.Context, lock *v1beta1.Lock, opts v1.CreateOptions) (*v1beta1.Lock, error)
 	Update(ctx context.Context, lock *v1beta1.Lock, opts v1.UpdateOptions) (*v1beta1.Lock, error)
+	UpdateStatus(ctx context.Context, lock *v1beta1.Lock, opts v1.UpdateOptions) (*v1beta1.Lock, error)
 	Delete(ctx context.Context, name string, opts v1.DeleteOptions) error
 	DeleteCollection(ctx context.Context, opts v1.DeleteOptions, listOpts v1.ListOptions) error
 	Get(ctx context.Context, name string, opts v1.GetOptions) (*v1beta1.Lock, error)
@@ -128,6 +129,21 @@ func (c *locks) Update(ctx context.Context, lock *v1beta1.Lock, opts v1.UpdateOp
 	return
 }
 
+// UpdateStatus was generated because the type contains a Status member.
+// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+func (c *locks) UpdateStatus(ctx context.Context, lock *v1beta1.Lock, opts v1.UpdateOptions) (result *v1beta1.Lock, err error) {
+	result = &v1beta1.Lock{}
+	err = c.client.Put().
+		Resource("locks").
+		Name(lock.Name).
+		SubResource("status").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(lock).
+		Do(ctx).
+		Into(result)
+	return
+}
+
 // Delete takes name of the lock and deletes it. Returns an error if one occurs.
 func (c *locks) Delete(ctx context.Context, name string, opts v1.DeleteOptions) error {
 	return c.client.Delete().
@@ -334,6 +334,11 @@ func (r *Reconciler) Reconcile(ctx context.Context, req reconcile.Request) (reco
 	// Create the non-existent package revision.
 	pr.SetName(revisionName)
 	pr.SetLabels(map[string]string{v1.LabelParentPackage: p.GetName()})
+	if rb := p.GetAnnotations()[v1.AnnotationRequiredBy]; rb != "" {
+		// The revision records that its package was installed as a
+		// dependency when it adds the package to the Lock.
+		meta.AddAnnotations(pr, map[string]string{v1.AnnotationRequiredBy: rb})
+	}
 	pr.SetSource(p.GetSource())
 	pr.SetPackagePullPolicy(p.GetPackagePullPolicy())
 	pr.SetPackagePullSecrets(p.GetPackagePullSecrets())
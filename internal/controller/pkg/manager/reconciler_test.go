@@ -173,6 +173,59 @@ func TestReconcile(t *testing.T) {
 				r: reconcile.Result{},
 			},
 		},
+		"SuccessfulNoExistingRevisionsRequiredBy": {
+			reason: "We should record which packages a package was installed as a dependency of on its revision.",
+			args: args{
+				req: reconcile.Request{NamespacedName: types.NamespacedName{Name: "test"}},
+				rec: &Reconciler{
+					newPackage:             func() v1.Package { return &v1.Configuration{} },
+					newPackageRevision:     func() v1.PackageRevision { return &v1.ConfigurationRevision{} },
+					newPackageRevisionList: func() v1.PackageRevisionList { return &v1.ConfigurationRevisionList{} },
+					client: resource.ClientApplicator{
+						Client: &test.MockClient{
+							MockGet: test.NewMockGetFn(nil, func(o client.Object) error {
+								p := o.(*v1.Configuration)
+								p.SetName("test")
+								p.SetGroupVersionKind(v1.ConfigurationGroupVersionKind)
+								p.SetActivationPolicy(&v1.AutomaticActivation)
+								p.SetAnnotations(map[string]string{v1.AnnotationRequiredBy: "cool/config"})
+								return nil
+							}),
+							MockList: test.NewMockListFn(kerrors.NewNotFound(schema.GroupResource{}, "")),
+							MockStatusUpdate: test.NewMockStatusUpdateFn(nil, func(o client.Object) error {
+								want := &v1.Configuration{}
+								want.SetName("test")
+								want.SetGroupVersionKind(v1.ConfigurationGroupVersionKind)
+								want.SetCurrentRevision("test-1234567")
+								want.SetActivationPolicy(&v1.AutomaticActivation)
+								want.SetAnnotations(map[string]string{v1.AnnotationRequiredBy: "cool/config"})
+								want.SetConditions(v1.UnknownHealth())
+								want.SetConditions(v1.Active())
+								if diff := cmp.Diff(want, o); diff != "" {
+									t.Errorf("-want, +got:\n%s", diff)
+								}
+								return nil
+							}),
+						},
+						Applicator: resource.ApplyFn(func(_ context.Context, o client.Object, _ ...resource.ApplyOption) error {
+							want := map[string]string{v1.AnnotationRequiredBy: "cool/config"}
+							if diff := cmp.Diff(want, o.GetAnnotations()); diff != "" {
+								t.Errorf("-want annotations, +got annotations:\n%s", diff)
+							}
+							return nil
+						}),
+					},
+					pkg: &MockRevisioner{
+						MockRevision: NewMockRevisionFn("test-1234567", nil),
+					},
+					log:    logging.NewNopLogger(),
+					record: event.NewNopRecorder(),
+				},
+			},
+			want: want{
+				r: reconcile.Result{},
+			},
+		},
 		"SuccessfulNoExistingRevisionsAutoActivatePullAlways": {
 			reason: "We should be active and requeue after wait on successful creation of the first revision with auto activation and package pull policy Always.",
 			args: args{
@@ -23,27 +23,49 @@ import (
 	"github.com/crossplane/crossplane/internal/controller/pkg/manager"
 	"github.com/crossplane/crossplane/internal/controller/pkg/resolver"
 	"github.com/crossplane/crossplane/internal/controller/pkg/revision"
+	"github.com/crossplane/crossplane/internal/feature"
 	"github.com/crossplane/crossplane/internal/xpkg"
 )
 
+// Options configure the package controllers.
+type Options struct {
+	// Namespace in which packages are unpacked and run.
+	Namespace string
+
+	// Registry from which packages are fetched if their source doesn't
+	// specify one.
+	Registry string
+
+	// StrictDependencyHealth reports a package revision that declares
+	// dependencies healthy only once all of its dependencies are resolved.
+	StrictDependencyHealth bool
+
+	// Resolver configures how the dependencies of packages are resolved.
+	Resolver []resolver.ReconcilerOption
+}
+
 // Setup package controllers.
-func Setup(mgr ctrl.Manager, l logging.Logger, c xpkg.Cache, namespace, registry string) error {
+func Setup(mgr ctrl.Manager, l logging.Logger, f *feature.Flags, c xpkg.Cache, o Options) error {
 	for _, setup := range []func(ctrl.Manager, logging.Logger, string, string) error{
 		manager.SetupConfiguration,
 		manager.SetupProvider,
 	} {
-		if err := setup(mgr, l, namespace, registry); err != nil {
+		if err := setup(mgr, l, o.Namespace, o.Registry); err != nil {
 			return err
 		}
 	}
-	if err := resolver.Setup(mgr, l, namespace); err != nil {
+	ropts := append([]resolver.ReconcilerOption{resolver.WithDefaultRegistry(o.Registry)}, o.Resolver...)
+	if o.StrictDependencyHealth {
+		ropts = append(ropts, resolver.WithDependentReporting())
+	}
+	if err := resolver.Setup(mgr, l, f, o.Namespace, ropts...); err != nil {
 		return err
 	}
-	for _, setup := range []func(ctrl.Manager, logging.Logger, xpkg.Cache, string, string) error{
+	for _, setup := range []func(ctrl.Manager, logging.Logger, xpkg.Cache, string, string, bool) error{
 		revision.SetupConfigurationRevision,
 		revision.SetupProviderRevision,
 	} {
-		if err := setup(mgr, l, c, namespace, registry); err != nil {
+		if err := setup(mgr, l, c, o.Namespace, o.Registry, o.StrictDependencyHealth); err != nil {
 			return err
 		}
 	}
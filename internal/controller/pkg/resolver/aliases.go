@@ -0,0 +1,116 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/yaml"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+	"github.com/crossplane/crossplane/internal/xpkg/canonical"
+)
+
+const (
+	// AliasesKey is the key of the aliases ConfigMap's data that maps the
+	// repositories dependencies are declared against to the repositories
+	// that satisfy them, e.g.
+	//
+	//   crossplane/provider-aws: registry.example.org/platform/provider-aws
+	//
+	// This allows a fork of a package to satisfy dependencies on the package
+	// it was forked from.
+	AliasesKey = "aliases.yaml"
+
+	errGetAliases   = "cannot get package aliases"
+	errParseAliases = "cannot parse package aliases"
+
+	msgAliasFmt = "dependency %s is satisfied by alias %s"
+)
+
+// WithPackageAliases specifies the name of a ConfigMap in Crossplane's
+// namespace that maps the repositories dependencies are declared against to
+// the repositories that satisfy them. A dependency on an aliased repository
+// is resolved as a dependency on the repository it's aliased to; its
+// constraints must be satisfied by the version of that repository that's
+// installed, or that will be installed if it's missing.
+func WithPackageAliases(name string) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.aliases = name
+	}
+}
+
+// packageAliases returns the repositories each canonical repository is
+// aliased to. There are no aliases if the aliases ConfigMap doesn't exist.
+func (r *Reconciler) packageAliases(ctx context.Context) (map[string]string, error) {
+	if r.aliases == "" {
+		return nil, nil
+	}
+	cm := &corev1.ConfigMap{}
+	if err := r.client.Get(ctx, types.NamespacedName{Namespace: r.namespace, Name: r.aliases}, cm); err != nil {
+		if kerrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrap(err, errGetAliases)
+	}
+
+	aliases := map[string]string{}
+	if err := yaml.Unmarshal([]byte(cm.Data[AliasesKey]), &aliases); err != nil {
+		return nil, errors.Wrap(err, errParseAliases)
+	}
+	out := make(map[string]string, len(aliases))
+	for from, to := range aliases {
+		out[canonical.Source(qualify(r.registry, from))] = qualify(r.registry, to)
+	}
+	return out, nil
+}
+
+// alias rewrites each dependency of the packages in the supplied Lock that is
+// declared against an aliased repository to the repository it's aliased to.
+// Like normalize it never updates the Lock, and must be called before we
+// determine which dependencies are missing. It returns a message describing
+// each alias that was applied, sorted.
+func alias(log logging.Logger, lock *v1beta1.Lock, aliases map[string]string) []string {
+	applied := map[string]string{}
+	for i := range lock.Packages {
+		p := &lock.Packages[i]
+		for j := range p.Dependencies {
+			d := &p.Dependencies[j]
+			to, ok := aliases[canonical.Source(d.Package)]
+			if !ok || canonical.Equal(to, d.Package) {
+				continue
+			}
+			log.Debug("Applied package alias", "from", d.Package, "to", to, "dependent", p.Source)
+			applied[d.Package] = to
+			d.Package = to
+		}
+	}
+	msgs := make([]string, 0, len(applied))
+	for from, to := range applied {
+		msgs = append(msgs, fmt.Sprintf(msgAliasFmt, from, to))
+	}
+	sort.Strings(msgs)
+	return msgs
+}
@@ -0,0 +1,237 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/fake"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	v1 "github.com/crossplane/crossplane/apis/pkg/v1"
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+	"github.com/crossplane/crossplane/internal/dag"
+	fakexpkg "github.com/crossplane/crossplane/internal/xpkg/fake"
+)
+
+func TestReconcilePackageAliases(t *testing.T) {
+	errBoom := errors.New("boom")
+
+	config := v1beta1.LockPackage{
+		Source: "cool/config",
+		Dependencies: []v1beta1.Dependency{
+			{Package: "crossplane/provider-aws", Type: v1beta1.ProviderPackageType, Constraints: ">=v0.20.0"},
+		},
+	}
+	fork := func(version string) v1beta1.LockPackage {
+		return v1beta1.LockPackage{Name: "platform-provider-aws", Source: "example.org/platform/provider-aws", Type: v1beta1.ProviderPackageType, Version: version}
+	}
+	aliases := func(data string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{Data: map[string]string{AliasesKey: data}}
+	}
+
+	type args struct {
+		lock    []v1beta1.LockPackage
+		aliases *corev1.ConfigMap
+		getErr  error
+	}
+	type want struct {
+		r        reconcile.Result
+		created  []string
+		events   []event.Reason
+		messages []string
+		reason   xpv1.ConditionReason
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"AliasHit": {
+			reason: "We should treat a dependency as satisfied by the installed package its repository is aliased to.",
+			args: args{
+				lock:    []v1beta1.LockPackage{config, fork("v0.21.0")},
+				aliases: aliases("crossplane/provider-aws: example.org/platform/provider-aws"),
+			},
+			want: want{
+				events:   []event.Reason{reasonPackageAlias},
+				messages: []string{"dependency crossplane/provider-aws is satisfied by alias example.org/platform/provider-aws"},
+				reason:   v1beta1.ReasonResolved,
+			},
+		},
+		"AliasMiss": {
+			reason: "We should install a dependency from the repository it declares if its repository isn't aliased.",
+			args: args{
+				lock:    []v1beta1.LockPackage{config, fork("v0.21.0")},
+				aliases: aliases("crossplane/provider-gcp: example.org/platform/provider-gcp"),
+			},
+			want: want{
+				created: []string{"crossplane/provider-aws:v0.20.0"},
+				events:  []event.Reason{reasonInstallDependency},
+				reason:  v1beta1.ReasonMissingDependency,
+			},
+		},
+		"AliasNotInstalled": {
+			reason: "We should install a missing dependency from the repository its repository is aliased to.",
+			args: args{
+				lock:    []v1beta1.LockPackage{config},
+				aliases: aliases("crossplane/provider-aws: example.org/platform/provider-aws"),
+			},
+			want: want{
+				created: []string{"example.org/platform/provider-aws:v0.21.0"},
+				events:  []event.Reason{reasonPackageAlias, reasonInstallDependency},
+				messages: []string{
+					"dependency crossplane/provider-aws is satisfied by alias example.org/platform/provider-aws",
+					"Installed automatically as a dependency of cool/config to satisfy constraint >=v0.20.0, selected version v0.21.0 from 1 available tags",
+				},
+				reason: v1beta1.ReasonMissingDependency,
+			},
+		},
+		"AliasTargetConstraint": {
+			reason: "We should check a dependency's constraints against the installed version of the package its repository is aliased to.",
+			args: args{
+				lock:    []v1beta1.LockPackage{config, fork("v0.19.0")},
+				aliases: aliases("crossplane/provider-aws: example.org/platform/provider-aws"),
+			},
+			want: want{
+				events: []event.Reason{reasonPackageAlias, reasonVersionConflict},
+				reason: v1beta1.ReasonVersionConflict,
+			},
+		},
+		"NoAliases": {
+			reason: "We should install a dependency from the repository it declares if the aliases ConfigMap doesn't exist.",
+			args: args{
+				lock: []v1beta1.LockPackage{config},
+			},
+			want: want{
+				created: []string{"crossplane/provider-aws:v0.20.0"},
+				events:  []event.Reason{reasonInstallDependency},
+				reason:  v1beta1.ReasonMissingDependency,
+			},
+		},
+		"ErrGetAliases": {
+			reason: "We should requeue without resolving dependencies if we cannot get the aliases ConfigMap.",
+			args: args{
+				lock:   []v1beta1.LockPackage{config},
+				getErr: errBoom,
+			},
+			want: want{
+				r:        reconcile.Result{RequeueAfter: shortWait},
+				events:   []event.Reason{reasonPackageAlias},
+				messages: []string{errors.Wrap(errBoom, errGetAliases).Error()},
+			},
+		},
+		"ErrParseAliases": {
+			reason: "We should requeue without resolving dependencies if we cannot parse the aliases ConfigMap.",
+			args: args{
+				lock:    []v1beta1.LockPackage{config},
+				aliases: aliases("- not a map"),
+			},
+			want: want{
+				r:      reconcile.Result{RequeueAfter: shortWait},
+				events: []event.Reason{reasonPackageAlias},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var created []string
+			var got xpv1.ConditionReason
+			mgr := &fake.Manager{
+				Client: &test.MockClient{
+					MockGet: func(_ context.Context, key client.ObjectKey, o client.Object) error {
+						switch obj := o.(type) {
+						case *v1beta1.Lock:
+							// The resolver aliases the dependencies of
+							// the Lock it gets, which must not modify our
+							// test cases.
+							for _, p := range tc.args.lock {
+								obj.Packages = append(obj.Packages, *p.DeepCopy())
+							}
+							return nil
+						case *corev1.ConfigMap:
+							if tc.args.getErr != nil {
+								return tc.args.getErr
+							}
+							if tc.args.aliases != nil && key.Name == "aliases" && key.Namespace == "crossplane-system" {
+								tc.args.aliases.DeepCopyInto(obj)
+								return nil
+							}
+						}
+						return kerrors.NewNotFound(schema.GroupResource{}, key.Name)
+					},
+					MockList: test.NewMockListFn(nil),
+					MockCreate: func(_ context.Context, o client.Object, _ ...client.CreateOption) error {
+						created = append(created, o.(*v1.Provider).Spec.Package)
+						return nil
+					},
+					MockUpdate: test.NewMockUpdateFn(nil),
+					MockStatusUpdate: test.NewMockStatusUpdateFn(nil, func(o client.Object) error {
+						got = o.(*v1beta1.Lock).GetCondition(v1beta1.TypeDependenciesResolved).Reason
+						return nil
+					}),
+				},
+			}
+			rec := &recorder{}
+			r := NewReconciler(mgr,
+				WithRecorder(rec),
+				WithFetcher(fakexpkg.NewTagFetcher(map[string][]string{
+					"crossplane/provider-aws":           {"v0.20.0"},
+					"example.org/platform/provider-aws": {"v0.21.0"},
+				})),
+				WithNamespace("crossplane-system"),
+				WithPackageAliases("aliases"),
+				WithNewDagFn(dag.NewMapDag),
+			)
+
+			res, err := r.Reconcile(context.Background(), reconcile.Request{})
+			if err != nil {
+				t.Fatalf("\n%s\nr.Reconcile(...): %s", tc.reason, err)
+			}
+			if diff := cmp.Diff(tc.want.r, res); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want, +got:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.created, created); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want created, +got created:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.events, rec.reasons); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want events, +got events:\n%s", tc.reason, diff)
+			}
+			if tc.want.messages != nil {
+				if diff := cmp.Diff(tc.want.messages, rec.messages); diff != "" {
+					t.Errorf("\n%s\nr.Reconcile(...): -want event messages, +got event messages:\n%s", tc.reason, diff)
+				}
+			}
+			if diff := cmp.Diff(tc.want.reason, got); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want condition reason, +got condition reason:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
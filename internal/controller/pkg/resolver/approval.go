@@ -0,0 +1,101 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+	"github.com/crossplane/crossplane/internal/xpkg"
+)
+
+// AnnotationApproveDependencies may be set on the Lock to a comma separated
+// list of the packages the resolver may install, e.g.
+// "crossplane/provider-aws,crossplane/provider-gcp", or to "*" to approve any
+// package. It is only consulted when the Reconciler is configured using
+// WithManualApproval. Approved packages that are no longer missing are
+// ignored.
+const AnnotationApproveDependencies = "pkg.crossplane.io/approve-dependencies"
+
+const (
+	approveAll = "*"
+
+	msgPendingApprovalFmt = "%d missing dependencies are awaiting approval: %s"
+)
+
+// WithManualApproval specifies that the Reconciler should only install a
+// missing dependency once it has been approved using the Lock's
+// pkg.crossplane.io/approve-dependencies annotation. Missing dependencies that
+// are awaiting approval are recorded in the Lock's status.
+func WithManualApproval() ReconcilerOption {
+	return func(r *Reconciler) {
+		r.approval = true
+	}
+}
+
+// approve returns the supplied planned installs that the supplied Lock
+// approves, and the missing dependencies that are awaiting approval. Approval
+// is read from the Lock on every reconcile, so it survives restarts. Planned
+// installs that can't be installed don't need approval, so that we surface
+// why.
+func (r *Reconciler) approve(log logging.Logger, lock *v1beta1.Lock, plan []xpkg.PlannedInstall) ([]xpkg.PlannedInstall, []v1beta1.PendingDependency) {
+	approved := r.approved(lock)
+
+	allowed := make([]xpkg.PlannedInstall, 0, len(plan))
+	var pending []v1beta1.PendingDependency
+	for _, p := range plan {
+		if p.Err != nil || approved[approveAll] || approved[r.sourceRepository(p.Dependency.Package)] {
+			allowed = append(allowed, p)
+			continue
+		}
+		log.Debug("Missing dependency is awaiting approval", "dependency", p.Dependency.Package)
+		pending = append(pending, v1beta1.PendingDependency{Package: p.Dependency.Package, Type: p.Dependency.Type, Source: p.Source})
+	}
+	sort.Slice(pending, func(i, j int) bool { return pending[i].Package < pending[j].Package })
+	return allowed, pending
+}
+
+// approved returns the canonical repositories of the packages the supplied
+// Lock approves, or approveAll if it approves any package.
+func (r *Reconciler) approved(lock *v1beta1.Lock) map[string]bool {
+	out := map[string]bool{}
+	for _, id := range strings.Split(lock.GetAnnotations()[AnnotationApproveDependencies], ",") {
+		id = strings.TrimSpace(id)
+		switch id {
+		case "":
+			continue
+		case approveAll:
+			out[approveAll] = true
+		default:
+			out[r.sourceRepository(id)] = true
+		}
+	}
+	return out
+}
+
+// pendingMessage summarizes the supplied pending dependencies.
+func pendingMessage(pending []v1beta1.PendingDependency) string {
+	pkgs := make([]string, len(pending))
+	for i, p := range pending {
+		pkgs[i] = p.Package
+	}
+	return fmt.Sprintf(msgPendingApprovalFmt, len(pending), strings.Join(pkgs, ", "))
+}
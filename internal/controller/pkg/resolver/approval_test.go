@@ -0,0 +1,253 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/fake"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+	"github.com/crossplane/crossplane/internal/dag"
+	fakexpkg "github.com/crossplane/crossplane/internal/xpkg/fake"
+)
+
+// approvalLock returns a Lock in which config-a depends on provider-aws and
+// provider-gcp, both of which are missing.
+func approvalLock() []v1beta1.LockPackage {
+	return []v1beta1.LockPackage{{
+		Source:  "crossplane/config-a",
+		Type:    v1beta1.ConfigurationPackageType,
+		Version: "v1.0.0",
+		Dependencies: []v1beta1.Dependency{
+			{Package: "crossplane/provider-aws", Type: v1beta1.ProviderPackageType, Constraints: ">=v0.20.0"},
+			{Package: "crossplane/provider-gcp", Type: v1beta1.ProviderPackageType, Constraints: ">=v0.20.0"},
+		},
+	}}
+}
+
+func TestReconcileManualApproval(t *testing.T) {
+	aws := v1beta1.PendingDependency{Package: "crossplane/provider-aws", Type: v1beta1.ProviderPackageType, Source: "crossplane/provider-aws:v0.20.0"}
+	gcp := v1beta1.PendingDependency{Package: "crossplane/provider-gcp", Type: v1beta1.ProviderPackageType, Source: "crossplane/provider-gcp:v0.20.0"}
+
+	type args struct {
+		approval bool
+		approve  string
+	}
+	type want struct {
+		created []string
+		pending []v1beta1.PendingDependency
+		reason  xpv1.ConditionReason
+		message string
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"Disabled": {
+			reason: "We should install missing dependencies without approval unless manual approval is enabled.",
+			args:   args{},
+			want: want{
+				created: []string{"crossplane-provider-aws", "crossplane-provider-gcp"},
+				reason:  v1beta1.ReasonMissingDependency,
+				message: "waiting for 2 missing dependencies to be installed",
+			},
+		},
+		"NotApproved": {
+			reason: "We should not install missing dependencies that have not been approved, and should report them as pending approval.",
+			args:   args{approval: true},
+			want: want{
+				pending: []v1beta1.PendingDependency{aws, gcp},
+				reason:  v1beta1.ReasonAwaitingApproval,
+				message: "2 missing dependencies are awaiting approval: crossplane/provider-aws, crossplane/provider-gcp",
+			},
+		},
+		"SomeApproved": {
+			reason: "We should only install the missing dependencies that have been approved.",
+			args:   args{approval: true, approve: "crossplane/provider-aws"},
+			want: want{
+				created: []string{"crossplane-provider-aws"},
+				pending: []v1beta1.PendingDependency{gcp},
+				reason:  v1beta1.ReasonAwaitingApproval,
+				message: "1 missing dependencies are awaiting approval: crossplane/provider-gcp",
+			},
+		},
+		"AllApproved": {
+			reason: "We should install every missing dependency if any package is approved.",
+			args:   args{approval: true, approve: "*"},
+			want: want{
+				created: []string{"crossplane-provider-aws", "crossplane-provider-gcp"},
+				reason:  v1beta1.ReasonMissingDependency,
+				message: "waiting for 2 missing dependencies to be installed",
+			},
+		},
+		"NoLongerRequired": {
+			reason: "We should ignore the approval of a package that is not a missing dependency.",
+			args:   args{approval: true, approve: "crossplane/provider-azure, index.docker.io/crossplane/provider-aws, crossplane/provider-gcp"},
+			want: want{
+				created: []string{"crossplane-provider-aws", "crossplane-provider-gcp"},
+				reason:  v1beta1.ReasonMissingDependency,
+				message: "waiting for 2 missing dependencies to be installed",
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var created []string
+			var status *v1beta1.Lock
+			mgr := &fake.Manager{
+				Client: &test.MockClient{
+					MockGet: test.NewMockGetFn(nil, func(o client.Object) error {
+						l, ok := o.(*v1beta1.Lock)
+						if !ok {
+							return kerrors.NewNotFound(schema.GroupResource{}, o.GetName())
+						}
+						if tc.args.approve != "" {
+							l.SetAnnotations(map[string]string{AnnotationApproveDependencies: tc.args.approve})
+						}
+						l.Packages = approvalLock()
+						return nil
+					}),
+					MockList: test.NewMockListFn(nil),
+					MockCreate: func(_ context.Context, o client.Object, _ ...client.CreateOption) error {
+						created = append(created, o.GetName())
+						return nil
+					},
+					MockUpdate: test.NewMockUpdateFn(nil),
+					MockStatusUpdate: test.NewMockStatusUpdateFn(nil, func(o client.Object) error {
+						status = o.(*v1beta1.Lock)
+						return nil
+					}),
+				},
+			}
+			opts := []ReconcilerOption{
+				WithRecorder(&recorder{}),
+				WithFetcher(&fakexpkg.MockFetcher{MockTags: fakexpkg.NewMockTagsFn([]string{"v0.20.0"}, nil)}),
+				WithNewDagFn(dag.NewMapDag),
+			}
+			if tc.args.approval {
+				opts = append(opts, WithManualApproval())
+			}
+			r := NewReconciler(mgr, opts...)
+			if _, err := r.Reconcile(context.Background(), reconcile.Request{}); err != nil {
+				t.Fatalf("\n%s\nr.Reconcile(...): %s", tc.reason, err)
+			}
+
+			if diff := cmp.Diff(tc.want.created, created); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want created, +got created:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.pending, status.Status.PendingApproval); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want pending, +got pending:\n%s", tc.reason, diff)
+			}
+			c := status.GetCondition(v1beta1.TypeDependenciesResolved)
+			if diff := cmp.Diff(tc.want.reason, c.Reason); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want condition reason, +got condition reason:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.message, c.Message); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want condition message, +got condition message:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+// TestReconcileManualApprovalFlow reconciles the same Lock several times, as a
+// dependency moves from pending approval, to approved, to created, to present
+// in the Lock. All state lives on the Lock, so each pass uses a new
+// Reconciler, as if the resolver had restarted.
+func TestReconcileManualApprovalFlow(t *testing.T) {
+	stored := &v1beta1.Lock{}
+	stored.Packages = approvalLock()[:1]
+	stored.Packages[0].Dependencies = stored.Packages[0].Dependencies[:1]
+
+	var created []string
+	mgr := &fake.Manager{
+		Client: &test.MockClient{
+			MockGet: test.NewMockGetFn(nil, func(o client.Object) error {
+				l, ok := o.(*v1beta1.Lock)
+				if !ok {
+					return kerrors.NewNotFound(schema.GroupResource{}, o.GetName())
+				}
+				stored.DeepCopyInto(l)
+				return nil
+			}),
+			MockList: test.NewMockListFn(nil),
+			MockCreate: func(_ context.Context, o client.Object, _ ...client.CreateOption) error {
+				created = append(created, o.GetName())
+				return nil
+			},
+			MockUpdate: test.NewMockUpdateFn(nil),
+			MockStatusUpdate: test.NewMockStatusUpdateFn(nil, func(o client.Object) error {
+				o.(*v1beta1.Lock).Status.DeepCopyInto(&stored.Status)
+				return nil
+			}),
+		},
+	}
+	reconcileOnce := func(step string) {
+		r := NewReconciler(mgr,
+			WithRecorder(&recorder{}),
+			WithFetcher(&fakexpkg.MockFetcher{MockTags: fakexpkg.NewMockTagsFn([]string{"v0.20.0"}, nil)}),
+			WithNewDagFn(dag.NewMapDag),
+			WithManualApproval(),
+		)
+		if _, err := r.Reconcile(context.Background(), reconcile.Request{}); err != nil {
+			t.Fatalf("%s: r.Reconcile(...): %s", step, err)
+		}
+	}
+
+	// The missing dependency is pending until it's approved.
+	reconcileOnce("Pending")
+	if len(created) != 0 {
+		t.Errorf("Pending: r.Reconcile(...): created %v before approval", created)
+	}
+	want := []v1beta1.PendingDependency{{Package: "crossplane/provider-aws", Type: v1beta1.ProviderPackageType, Source: "crossplane/provider-aws:v0.20.0"}}
+	if diff := cmp.Diff(want, stored.Status.PendingApproval); diff != "" {
+		t.Errorf("Pending: r.Reconcile(...): -want pending, +got pending:\n%s", diff)
+	}
+
+	// Once approved the dependency is created and is no longer pending.
+	stored.SetAnnotations(map[string]string{AnnotationApproveDependencies: "crossplane/provider-aws"})
+	reconcileOnce("Approved")
+	if diff := cmp.Diff([]string{"crossplane-provider-aws"}, created); diff != "" {
+		t.Errorf("Approved: r.Reconcile(...): -want created, +got created:\n%s", diff)
+	}
+	if diff := cmp.Diff([]v1beta1.PendingDependency(nil), stored.Status.PendingApproval); diff != "" {
+		t.Errorf("Approved: r.Reconcile(...): -want pending, +got pending:\n%s", diff)
+	}
+
+	// Once the created dependency adds itself to the Lock its approval has
+	// no further effect.
+	stored.Packages = append(stored.Packages, v1beta1.LockPackage{Source: "crossplane/provider-aws", Type: v1beta1.ProviderPackageType, Version: "v0.20.0"})
+	reconcileOnce("Created")
+	if diff := cmp.Diff([]string{"crossplane-provider-aws"}, created); diff != "" {
+		t.Errorf("Created: r.Reconcile(...): -want created, +got created:\n%s", diff)
+	}
+	if c := stored.GetCondition(v1beta1.TypeDependenciesResolved); c.Reason != v1beta1.ReasonResolved {
+		t.Errorf("Created: r.Reconcile(...): want condition reason %q, got %q", v1beta1.ReasonResolved, c.Reason)
+	}
+}
@@ -0,0 +1,71 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/crossplane/crossplane/internal/xpkg"
+)
+
+const (
+	msgInstalledFmt            = "Installed automatically as a dependency of %s, selected version %s"
+	msgUpgradedDependencyFmt   = "Upgraded automatically from version %s to %s as a dependency of %s"
+	msgDowngradedDependencyFmt = "Downgraded automatically from version %s to %s as a dependency of %s"
+	msgRequirementFmt          = "%s to satisfy constraint %s"
+	msgCandidatesFmt           = " from %d available tags"
+	msgUnknownRequirements     = "packages in the Lock"
+)
+
+// installedMessage describes why the resolver installed the supplied planned
+// install, so that the package it created records how it came to exist.
+func installedMessage(p xpkg.PlannedInstall) string {
+	msg := fmt.Sprintf(msgInstalledFmt, requirements(p.Requirements), p.Version)
+	if p.Candidates > 0 {
+		msg += fmt.Sprintf(msgCandidatesFmt, p.Candidates)
+	}
+	return msg
+}
+
+// upgradedMessage describes why the resolver upgraded the package in the
+// supplied conflict to the supplied version, selected from the supplied number
+// of available tags.
+func upgradedMessage(c xpkg.Conflict, version string, candidates int) string {
+	format := msgUpgradedDependencyFmt
+	if xpkg.IsDowngrade(c.Version, version) {
+		format = msgDowngradedDependencyFmt
+	}
+	msg := fmt.Sprintf(format, c.Version, version, requirements(c.Requirements))
+	if candidates > 0 {
+		msg += fmt.Sprintf(msgCandidatesFmt, candidates)
+	}
+	return msg
+}
+
+// requirements describes the packages that require a dependency, and the
+// constraint each places on it.
+func requirements(reqs []xpkg.Requirement) string {
+	if len(reqs) == 0 {
+		return msgUnknownRequirements
+	}
+	s := make([]string, len(reqs))
+	for i, r := range reqs {
+		s[i] = fmt.Sprintf(msgRequirementFmt, r.Parent, r.Constraint)
+	}
+	return strings.Join(s, " and of ")
+}
@@ -0,0 +1,66 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/crossplane/crossplane/internal/xpkg"
+)
+
+func TestInstalledMessage(t *testing.T) {
+	cases := map[string]struct {
+		reason string
+		p      xpkg.PlannedInstall
+		want   string
+	}{
+		"Selected": {
+			reason: "We should describe the constraint a version was selected to satisfy, and how many tags it was selected from.",
+			p: xpkg.PlannedInstall{
+				Version:      "v0.21.0",
+				Candidates:   4,
+				Requirements: []xpkg.Requirement{{Parent: "cool/config", Constraint: ">=v0.20.0"}},
+			},
+			want: "Installed automatically as a dependency of cool/config to satisfy constraint >=v0.20.0, selected version v0.21.0 from 4 available tags",
+		},
+		"Pinned": {
+			reason: "We should not describe available tags if a pinned dependency's versions were not listed.",
+			p: xpkg.PlannedInstall{
+				Version:      "v0.21.0",
+				Pinned:       true,
+				Requirements: []xpkg.Requirement{{Parent: "cool/config", Constraint: "v0.21.0"}},
+			},
+			want: "Installed automatically as a dependency of cool/config to satisfy constraint v0.21.0, selected version v0.21.0",
+		},
+		"NoRequirements": {
+			reason: "We should still describe an install whose requirements are unknown.",
+			p:      xpkg.PlannedInstall{Version: "v0.21.0", Candidates: 1},
+			want:   "Installed automatically as a dependency of packages in the Lock, selected version v0.21.0 from 1 available tags",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := installedMessage(tc.p)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\ninstalledMessage(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,281 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+	"github.com/crossplane/crossplane/internal/xpkg"
+)
+
+const (
+	// AuditLogKey is the key of the audit log ConfigMap's data that holds
+	// the audit log, one JSON encoded AuditRecord per line, oldest first.
+	AuditLogKey = "audit.jsonl"
+
+	// DefaultAuditEntries is the number of records a ConfigMap audit log
+	// keeps by default.
+	DefaultAuditEntries = 500
+
+	// maxAuditBytes is the size beyond which a ConfigMap audit log drops
+	// its oldest records, well below the 1MiB limit of a ConfigMap.
+	maxAuditBytes = 512 * 1024
+
+	errGetAuditLog    = "cannot get audit log"
+	errWriteAuditLog  = "cannot write audit log"
+	errEncodeAuditLog = "cannot encode audit record"
+	errRecordAudit    = "cannot record dependency decision in audit log"
+
+	reasonAudit event.Reason = "AuditDependencyDecision"
+)
+
+// An AuditAction is a mutating decision the Reconciler made.
+type AuditAction string
+
+// Audited actions.
+const (
+	// AuditCreated records that a package was installed to satisfy a
+	// missing dependency.
+	AuditCreated AuditAction = "Created"
+
+	// AuditUpgraded records that an auto-installed package was upgraded or
+	// downgraded, including to the digest a tracked tag moved to.
+	AuditUpgraded AuditAction = "Upgraded"
+
+	// AuditRolledBack records that an upgrade was rolled back.
+	AuditRolledBack AuditAction = "RolledBack"
+
+	// AuditBlocked records that a dependency was not installed because the
+	// package source policy blocked it.
+	AuditBlocked AuditAction = "BlockedByPolicy"
+
+	// AuditDeleted records that an orphaned auto-installed package was
+	// deleted.
+	AuditDeleted AuditAction = "Deleted"
+)
+
+// An AuditRecord records a mutating decision the Reconciler made about a
+// dependency.
+type AuditRecord struct {
+	// Time at which the decision was made.
+	Time time.Time `json:"time"`
+
+	// Lock the decision was made for.
+	Lock string `json:"lock"`
+
+	// Action that was decided.
+	Action AuditAction `json:"action"`
+
+	// Source of the dependency the decision was about.
+	Source string `json:"source"`
+
+	// Package that was written, if any.
+	Package string `json:"package,omitempty"`
+
+	// Version the package was installed, upgraded, or rolled back to.
+	Version string `json:"version,omitempty"`
+
+	// From is the version the package was upgraded or rolled back from.
+	From string `json:"from,omitempty"`
+
+	// Dependents are the sources of the packages that required the
+	// dependency.
+	Dependents []string `json:"dependents,omitempty"`
+
+	// Reason for the decision, if it isn't implied by its action.
+	Reason string `json:"reason,omitempty"`
+}
+
+// An AuditSink records the mutating decisions the Reconciler makes, so that
+// they can be reviewed after the events describing them have expired.
+type AuditSink interface {
+	// Record the supplied audit records.
+	Record(ctx context.Context, rs ...AuditRecord) error
+}
+
+// An AuditSinkFn is a function that satisfies AuditSink.
+type AuditSinkFn func(ctx context.Context, rs ...AuditRecord) error
+
+// Record the supplied audit records.
+func (fn AuditSinkFn) Record(ctx context.Context, rs ...AuditRecord) error {
+	return fn(ctx, rs...)
+}
+
+// WithAuditSink specifies where the Reconciler should record the mutating
+// decisions it makes. Failing to record a decision never fails a reconcile;
+// a warning event is recorded instead. Nothing is recorded in dry run mode.
+func WithAuditSink(s AuditSink) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.audit = s
+	}
+}
+
+// recordAudit records the supplied decision about a dependency of the
+// supplied Lock in the Reconciler's audit sink, if it has one.
+func (r *Reconciler) recordAudit(ctx context.Context, lock *v1beta1.Lock, rec AuditRecord) {
+	if r.audit == nil || r.dryRun {
+		return
+	}
+	rec.Time = r.now()
+	rec.Lock = lock.GetName()
+	if err := r.audit.Record(ctx, rec); err != nil {
+		r.log.Debug(errRecordAudit, "error", err)
+		r.record.Event(lock, event.Warning(reasonAudit, errors.Wrap(err, errRecordAudit)))
+	}
+}
+
+// dependentsOf returns the packages that place the supplied requirements.
+func dependentsOf(reqs []xpkg.Requirement) []string {
+	out := make([]string, 0, len(reqs))
+	for _, rq := range reqs {
+		if rq.Parent != "" {
+			out = append(out, rq.Parent)
+		}
+	}
+	return out
+}
+
+// A ConfigMapAuditSink records audit records in a ConfigMap, one JSON encoded
+// record per line. It keeps only the most recent records, dropping the oldest
+// once it holds more than its limit or approaches the size limit of a
+// ConfigMap. A record that differs only in time from the most recent record
+// about the same dependency is not recorded again, so that a decision that is
+// made every reconcile, e.g. to block a dependency, is recorded once.
+type ConfigMapAuditSink struct {
+	client  client.Client
+	name    types.NamespacedName
+	entries int
+}
+
+// NewConfigMapAuditSink returns an AuditSink that records the supplied number
+// of the most recent audit records in the supplied ConfigMap, creating it if
+// it doesn't exist. DefaultAuditEntries are kept if entries is not positive.
+func NewConfigMapAuditSink(c client.Client, namespace, name string, entries int) *ConfigMapAuditSink {
+	if entries <= 0 {
+		entries = DefaultAuditEntries
+	}
+	return &ConfigMapAuditSink{client: c, name: types.NamespacedName{Namespace: namespace, Name: name}, entries: entries}
+}
+
+// Record the supplied audit records.
+func (s *ConfigMapAuditSink) Record(ctx context.Context, rs ...AuditRecord) error {
+	cm := &corev1.ConfigMap{}
+	err := s.client.Get(ctx, s.name, cm)
+	create := kerrors.IsNotFound(err)
+	if err != nil && !create {
+		return errors.Wrap(err, errGetAuditLog)
+	}
+
+	records := parseAuditLog(cm.Data[AuditLogKey])
+	for _, rec := range rs {
+		if repeated(records, rec) {
+			continue
+		}
+		records = append(records, rec)
+	}
+
+	data, err := s.encode(records)
+	if err != nil {
+		return err
+	}
+	if !create && data == cm.Data[AuditLogKey] {
+		return nil
+	}
+
+	if cm.Data == nil {
+		cm.Data = map[string]string{}
+	}
+	cm.Data[AuditLogKey] = data
+	if create {
+		cm.SetNamespace(s.name.Namespace)
+		cm.SetName(s.name.Name)
+		return errors.Wrap(s.client.Create(ctx, cm), errWriteAuditLog)
+	}
+	return errors.Wrap(s.client.Update(ctx, cm), errWriteAuditLog)
+}
+
+// encode the most recent of the supplied records that fit within the sink's
+// limits.
+func (s *ConfigMapAuditSink) encode(records []AuditRecord) (string, error) {
+	if len(records) > s.entries {
+		records = records[len(records)-s.entries:]
+	}
+	lines := make([]string, len(records))
+	size := 0
+	for i := len(records) - 1; i >= 0; i-- {
+		b, err := json.Marshal(records[i])
+		if err != nil {
+			return "", errors.Wrap(err, errEncodeAuditLog)
+		}
+		if size+len(b)+1 > maxAuditBytes {
+			lines = lines[i+1:]
+			break
+		}
+		size += len(b) + 1
+		lines[i] = string(b)
+	}
+	if len(lines) == 0 {
+		return "", nil
+	}
+	return strings.Join(lines, "\n") + "\n", nil
+}
+
+// parseAuditLog parses the supplied audit log. Lines that can't be parsed,
+// e.g. because a user edited them, are dropped.
+func parseAuditLog(data string) []AuditRecord {
+	var records []AuditRecord
+	for _, line := range bytes.Split([]byte(data), []byte("\n")) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		rec := AuditRecord{}
+		if err := json.Unmarshal(line, &rec); err != nil {
+			continue
+		}
+		records = append(records, rec)
+	}
+	return records
+}
+
+// repeated returns true if the most recent of the supplied records about the
+// same dependency as the supplied record differs from it only in time.
+func repeated(records []AuditRecord, rec AuditRecord) bool {
+	for i := len(records) - 1; i >= 0; i-- {
+		if records[i].Lock != rec.Lock || records[i].Source != rec.Source {
+			continue
+		}
+		prev := records[i]
+		prev.Time = rec.Time
+		a, aerr := json.Marshal(prev)
+		b, berr := json.Marshal(rec)
+		return aerr == nil && berr == nil && bytes.Equal(a, b)
+	}
+	return false
+}
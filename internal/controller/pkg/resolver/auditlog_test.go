@@ -0,0 +1,282 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/fake"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+	"github.com/crossplane/crossplane/internal/dag"
+	fakexpkg "github.com/crossplane/crossplane/internal/xpkg/fake"
+)
+
+func TestConfigMapAuditSink(t *testing.T) {
+	errBoom := errors.New("boom")
+	now := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	created := func(source, version string) AuditRecord {
+		return AuditRecord{Time: now, Lock: "lock", Action: AuditCreated, Source: source, Package: strings.ReplaceAll(source, "/", "-"), Version: version, Dependents: []string{"cool/config"}}
+	}
+	encode := func(rs ...AuditRecord) string {
+		data := ""
+		for _, r := range rs {
+			b, _ := json.Marshal(r)
+			data += string(b) + "\n"
+		}
+		return data
+	}
+
+	type args struct {
+		entries  int
+		existing *string
+		get      error
+		write    error
+		records  []AuditRecord
+	}
+	type want struct {
+		err     error
+		written *string
+		create  bool
+	}
+
+	existing := encode(created("crossplane/provider-aws", "v0.20.0"))
+	// Records large enough that only seven fit within the size cap.
+	large := make([]AuditRecord, 10)
+	for i := range large {
+		large[i] = created("crossplane/provider-aws", "v0.20.0")
+		large[i].Reason = strings.Repeat("x", 64*1024)
+		large[i].Version = string(rune('a' + i))
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"Create": {
+			reason: "We should create the audit log if it doesn't exist.",
+			args: args{
+				get:     kerrors.NewNotFound(schema.GroupResource{}, "audit"),
+				records: []AuditRecord{created("crossplane/provider-aws", "v0.20.0")},
+			},
+			want: want{
+				written: &existing,
+				create:  true,
+			},
+		},
+		"Append": {
+			reason: "We should append new records after the existing records, as one compact JSON object per line.",
+			args: args{
+				existing: &existing,
+				records:  []AuditRecord{created("crossplane/provider-gcp", "v0.18.0")},
+			},
+			want: want{
+				written: func() *string {
+					s := `{"time":"2021-06-01T12:00:00Z","lock":"lock","action":"Created","source":"crossplane/provider-aws","package":"crossplane-provider-aws","version":"v0.20.0","dependents":["cool/config"]}` + "\n" +
+						`{"time":"2021-06-01T12:00:00Z","lock":"lock","action":"Created","source":"crossplane/provider-gcp","package":"crossplane-provider-gcp","version":"v0.18.0","dependents":["cool/config"]}` + "\n"
+					return &s
+				}(),
+			},
+		},
+		"Repeated": {
+			reason: "We should not write a record that differs only in time from the most recent record about the same dependency.",
+			args: args{
+				existing: &existing,
+				records: func() []AuditRecord {
+					r := created("crossplane/provider-aws", "v0.20.0")
+					r.Time = now.Add(time.Hour)
+					return []AuditRecord{r}
+				}(),
+			},
+			want: want{},
+		},
+		"RotateAtEntryCap": {
+			reason: "We should drop the oldest records once we hold more than our limit.",
+			args: args{
+				entries:  2,
+				existing: func() *string { s := encode(created("a/a", "v1"), created("b/b", "v1")); return &s }(),
+				records:  []AuditRecord{created("c/c", "v1")},
+			},
+			want: want{
+				written: func() *string { s := encode(created("b/b", "v1"), created("c/c", "v1")); return &s }(),
+			},
+		},
+		"RotateAtSizeCap": {
+			reason: "We should drop the oldest records once the audit log approaches the size limit of a ConfigMap.",
+			args: args{
+				existing: func() *string { s := encode(large[:len(large)-1]...); return &s }(),
+				records:  large[len(large)-1:],
+			},
+			want: want{
+				written: func() *string { s := encode(large[3:]...); return &s }(),
+			},
+		},
+		"ErrGet": {
+			reason: "We should return any error encountered getting the audit log.",
+			args: args{
+				get:     errBoom,
+				records: []AuditRecord{created("crossplane/provider-aws", "v0.20.0")},
+			},
+			want: want{
+				err: errors.Wrap(errBoom, errGetAuditLog),
+			},
+		},
+		"ErrWrite": {
+			reason: "We should return any error encountered writing the audit log.",
+			args: args{
+				existing: &existing,
+				write:    errBoom,
+				records:  []AuditRecord{created("crossplane/provider-gcp", "v0.18.0")},
+			},
+			want: want{
+				err: errors.Wrap(errBoom, errWriteAuditLog),
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var written *string
+			create := false
+			write := func(o client.Object) error {
+				if tc.args.write == nil {
+					s := o.(*corev1.ConfigMap).Data[AuditLogKey]
+					written = &s
+				}
+				return tc.args.write
+			}
+			c := &test.MockClient{
+				MockGet: test.NewMockGetFn(tc.args.get, func(o client.Object) error {
+					if tc.args.existing != nil {
+						o.(*corev1.ConfigMap).Data = map[string]string{AuditLogKey: *tc.args.existing}
+					}
+					return nil
+				}),
+				MockCreate: func(_ context.Context, o client.Object, _ ...client.CreateOption) error {
+					create = true
+					return write(o)
+				},
+				MockUpdate: func(_ context.Context, o client.Object, _ ...client.UpdateOption) error {
+					return write(o)
+				},
+			}
+			s := NewConfigMapAuditSink(c, "crossplane-system", "audit", tc.args.entries)
+
+			err := s.Record(context.Background(), tc.args.records...)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\ns.Record(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.written, written); diff != "" {
+				t.Errorf("\n%s\ns.Record(...): -want written, +got written:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.create, create); diff != "" {
+				t.Errorf("\n%s\ns.Record(...): -want create, +got create:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestReconcileAudit(t *testing.T) {
+	errBoom := errors.New("boom")
+	deps := []v1beta1.Dependency{{Package: "crossplane/provider-aws", Type: v1beta1.ProviderPackageType, Constraints: ">=v0.20.0"}}
+
+	type want struct {
+		err     error
+		records []AuditRecord
+		warned  bool
+	}
+
+	cases := map[string]struct {
+		reason string
+		sink   error
+		want   want
+	}{
+		"Recorded": {
+			reason: "We should record the packages we create in our audit sink.",
+			want: want{
+				records: []AuditRecord{{Lock: "lock", Action: AuditCreated, Source: "crossplane/provider-aws", Package: "crossplane-provider-aws", Version: "v0.20.0", Dependents: []string{"cool/config"}}},
+			},
+		},
+		"SinkFailed": {
+			reason: "We should not fail a reconcile because we couldn't record a decision in our audit sink, but should warn that we couldn't.",
+			sink:   errBoom,
+			want: want{
+				records: []AuditRecord{{Lock: "lock", Action: AuditCreated, Source: "crossplane/provider-aws", Package: "crossplane-provider-aws", Version: "v0.20.0", Dependents: []string{"cool/config"}}},
+				warned:  true,
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var records []AuditRecord
+			rec := &recorder{}
+			mgr := &fake.Manager{
+				Client: &test.MockClient{
+					MockGet: test.NewMockGetFn(nil, func(o client.Object) error {
+						o.SetName("lock")
+						o.(*v1beta1.Lock).Packages = []v1beta1.LockPackage{{Source: "cool/config", Dependencies: deps}}
+						return nil
+					}),
+					MockList:         test.NewMockListFn(nil),
+					MockCreate:       test.NewMockCreateFn(nil),
+					MockUpdate:       test.NewMockUpdateFn(nil),
+					MockStatusUpdate: test.NewMockStatusUpdateFn(nil),
+				},
+			}
+			r := NewReconciler(mgr,
+				WithAuditSink(AuditSinkFn(func(_ context.Context, rs ...AuditRecord) error {
+					records = append(records, rs...)
+					return tc.sink
+				})),
+				WithRecorder(rec),
+				WithFetcher(&fakexpkg.MockFetcher{MockTags: fakexpkg.NewMockTagsFn([]string{"v0.20.0"}, nil)}),
+				WithNewDagFn(dag.NewMapDag),
+			)
+			_, err := r.Reconcile(context.Background(), reconcile.Request{})
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.records, records, cmpopts.IgnoreFields(AuditRecord{}, "Time")); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want audit records, +got audit records:\n%s", tc.reason, diff)
+			}
+			warned := false
+			for _, reason := range rec.reasons {
+				warned = warned || reason == reasonAudit
+			}
+			if diff := cmp.Diff(tc.want.warned, warned); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want audit warning, +got audit warning:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
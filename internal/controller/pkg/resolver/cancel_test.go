@@ -0,0 +1,144 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/fake"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	v1 "github.com/crossplane/crossplane/apis/pkg/v1"
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+	"github.com/crossplane/crossplane/internal/dag"
+	fakexpkg "github.com/crossplane/crossplane/internal/xpkg/fake"
+)
+
+func TestReconcileCancelledAfterCreate(t *testing.T) {
+	stored := &v1beta1.Lock{}
+	stored.Packages = []v1beta1.LockPackage{{
+		Source: "cool/config",
+		Dependencies: []v1beta1.Dependency{
+			{Package: "crossplane/provider-aws", Type: v1beta1.ProviderPackageType, Constraints: ">=v0.20.0"},
+			{Package: "crossplane/provider-gcp", Type: v1beta1.ProviderPackageType, Constraints: ">=v0.20.0"},
+		},
+	}}
+
+	// Providers that were created, by name, as the API server would store
+	// them.
+	providers := map[string]*v1.Provider{}
+	var created []string
+
+	// cancel cancels the reconcile that is in progress, if any.
+	var cancel context.CancelFunc
+
+	mgr := &fake.Manager{
+		Client: &test.MockClient{
+			MockGet: test.NewMockGetFn(nil, func(o client.Object) error {
+				switch obj := o.(type) {
+				case *v1beta1.Lock:
+					stored.DeepCopyInto(obj)
+					return nil
+				case *v1.Provider:
+					if p, ok := providers[obj.GetName()]; ok {
+						p.DeepCopyInto(obj)
+						return nil
+					}
+				}
+				return kerrors.NewNotFound(schema.GroupResource{}, o.GetName())
+			}),
+			MockList: func(_ context.Context, l client.ObjectList, _ ...client.ListOption) error {
+				if pl, ok := l.(*v1.ProviderList); ok {
+					for _, p := range providers {
+						pl.Items = append(pl.Items, *p)
+					}
+				}
+				return nil
+			},
+			MockCreate: func(_ context.Context, o client.Object, _ ...client.CreateOption) error {
+				if _, ok := providers[o.GetName()]; ok {
+					return kerrors.NewAlreadyExists(schema.GroupResource{}, o.GetName())
+				}
+				providers[o.GetName()] = o.(*v1.Provider).DeepCopy()
+				created = append(created, o.GetName())
+
+				// We're cancelled once the first package is created,
+				// e.g. because Crossplane is shutting down.
+				cancel()
+				return nil
+			},
+			MockUpdate: test.NewMockUpdateFn(nil),
+			MockStatusUpdate: func(ctx context.Context, o client.Object, _ ...client.UpdateOption) error {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+				o.(*v1beta1.Lock).Status.DeepCopyInto(&stored.Status)
+				return nil
+			},
+		},
+	}
+	reconcileOnce := func(step string) *recorder {
+		rec := &recorder{}
+		r := NewReconciler(mgr,
+			WithRecorder(rec),
+			WithFetcher(&fakexpkg.MockFetcher{MockTags: fakexpkg.NewMockTagsFn([]string{"v0.20.0"}, nil)}),
+			WithNewDagFn(dag.NewMapDag),
+		)
+		var ctx context.Context
+		ctx, cancel = context.WithCancel(context.Background())
+		defer cancel()
+		if _, err := r.Reconcile(ctx, reconcile.Request{}); err != nil {
+			t.Fatalf("%s: r.Reconcile(...): %s", step, err)
+		}
+		return rec
+	}
+
+	// We stop creating packages once we're cancelled, but still record the
+	// package we created.
+	reconcileOnce("Cancelled")
+	if len(created) != 1 {
+		t.Fatalf("Cancelled: r.Reconcile(...): want 1 package created, got %v", created)
+	}
+	c := stored.GetCondition(v1beta1.TypeDependenciesResolved)
+	if diff := cmp.Diff(errInterrupted+": context canceled", c.Message); diff != "" {
+		t.Errorf("Cancelled: r.Reconcile(...): -want message, +got message:\n%s", diff)
+	}
+	first := created[0]
+
+	// The next pass finds the package we created by its repository label,
+	// and creates only the package we didn't get to.
+	rec := reconcileOnce("Restarted")
+	if len(created) != 2 || created[0] != first {
+		t.Fatalf("Restarted: r.Reconcile(...): want 2 packages created once each, got %v", created)
+	}
+	want := []event.Reason{reasonInstallDependency}
+	if diff := cmp.Diff(want, rec.reasons); diff != "" {
+		t.Errorf("Restarted: r.Reconcile(...): -want events, +got events:\n%s", diff)
+	}
+	c = stored.GetCondition(v1beta1.TypeDependenciesResolved)
+	if diff := cmp.Diff("waiting for 2 missing dependencies to be installed", c.Message); diff != "" {
+		t.Errorf("Restarted: r.Reconcile(...): -want message, +got message:\n%s", diff)
+	}
+}
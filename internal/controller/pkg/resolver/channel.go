@@ -0,0 +1,154 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"context"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+
+	"github.com/crossplane/crossplane/internal/xpkg"
+	"github.com/crossplane/crossplane/internal/xpkg/canonical"
+)
+
+const (
+	// ChannelKey is the key of the channel ConfigMap's data that lists the
+	// blessed versions of each package repository, e.g.
+	//
+	//   crossplane/provider-aws:
+	//   - v0.20.0
+	//   - v0.21.0
+	//
+	// A version may record the digest it pointed at when it was published,
+	// e.g. v0.21.0@sha256:..., which is verified if digests are pinned.
+	ChannelKey = "versions.yaml"
+
+	errGetChannel   = "cannot get version channel"
+	errParseChannel = "cannot parse version channel"
+)
+
+// WithVersionSource specifies where the Reconciler should list the versions
+// dependency packages may be installed at. By default it lists the tags of
+// their repositories.
+func WithVersionSource(s xpkg.VersionSource) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.source = s
+	}
+}
+
+// WithTagFallback specifies that the Reconciler should list the tags of a
+// dependency's repository if its version source doesn't list the repository.
+// Otherwise the dependency is reported as missing until it's listed.
+func WithTagFallback() ReconcilerOption {
+	return func(r *Reconciler) {
+		r.fallback = true
+	}
+}
+
+// A ChannelVersionSource lists the versions of package repositories that a
+// ConfigMap blesses, i.e. a channel. Repositories are matched canonically, so
+// a channel may list crossplane/provider-aws rather than
+// index.docker.io/crossplane/provider-aws.
+type ChannelVersionSource struct {
+	client   client.Reader
+	name     types.NamespacedName
+	registry string
+}
+
+// NewChannelVersionSource returns a VersionSource that lists the versions the
+// supplied ConfigMap blesses. Repositories the channel lists without a
+// registry are assumed to be in the supplied default registry.
+func NewChannelVersionSource(c client.Reader, namespace, name, registry string) *ChannelVersionSource {
+	return &ChannelVersionSource{client: c, name: types.NamespacedName{Namespace: namespace, Name: name}, registry: registry}
+}
+
+// ListVersions returns the versions the channel blesses for the supplied
+// reference's repository. No repository is listed if the channel ConfigMap
+// doesn't exist.
+func (s *ChannelVersionSource) ListVersions(ctx context.Context, ref name.Reference) ([]string, error) {
+	entries, err := s.entries(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	versions := make([]string, len(entries))
+	for i, e := range entries {
+		versions[i], _ = splitDigest(e)
+	}
+	return versions, nil
+}
+
+// RecordedDigest returns the digest the channel records for the supplied
+// version of the supplied reference's repository, if any. No digest is
+// recorded for a repository the channel doesn't list.
+func (s *ChannelVersionSource) RecordedDigest(ctx context.Context, ref name.Reference, version string) (string, error) {
+	entries, err := s.entries(ctx, ref)
+	if xpkg.IsNotListed(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	for _, e := range entries {
+		if v, d := splitDigest(e); v == version {
+			return d, nil
+		}
+	}
+	return "", nil
+}
+
+// entries returns the channel's entries for the supplied reference's
+// repository, each of which is a version and optionally a digest.
+func (s *ChannelVersionSource) entries(ctx context.Context, ref name.Reference) ([]string, error) {
+	repo := canonical.Source(ref.Context().Name())
+
+	cm := &corev1.ConfigMap{}
+	if err := s.client.Get(ctx, s.name, cm); err != nil {
+		if kerrors.IsNotFound(err) {
+			return nil, &xpkg.NotListedError{Repository: ref.Context().Name()}
+		}
+		return nil, errors.Wrap(err, errGetChannel)
+	}
+
+	channel := map[string][]string{}
+	if err := yaml.Unmarshal([]byte(cm.Data[ChannelKey]), &channel); err != nil {
+		return nil, errors.Wrap(err, errParseChannel)
+	}
+	for r, entries := range channel {
+		if canonical.Source(qualify(s.registry, r)) == repo {
+			return entries, nil
+		}
+	}
+	return nil, &xpkg.NotListedError{Repository: ref.Context().Name()}
+}
+
+// splitDigest splits a channel entry into its version and recorded digest,
+// e.g. v0.21.0@sha256:... into v0.21.0 and sha256:....
+func splitDigest(entry string) (string, string) {
+	i := strings.Index(entry, "@")
+	if i < 0 {
+		return entry, ""
+	}
+	return entry[:i], entry[i+1:]
+}
@@ -0,0 +1,220 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-containerregistry/pkg/name"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	"github.com/crossplane/crossplane/internal/xpkg"
+)
+
+func TestChannelVersionSource(t *testing.T) {
+	errBoom := errors.New("boom")
+	malformed := "crossplane/provider-aws: v0.20.0"
+	errMalformed := yaml.Unmarshal([]byte(malformed), &map[string][]string{})
+	channel := func(data string) test.MockGetFn {
+		return test.NewMockGetFn(nil, func(o client.Object) error {
+			o.(*corev1.ConfigMap).Data = map[string]string{ChannelKey: data}
+			return nil
+		})
+	}
+
+	type args struct {
+		get      test.MockGetFn
+		registry string
+		ref      string
+	}
+	type want struct {
+		versions []string
+		err      error
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"Listed": {
+			reason: "We should return the versions the channel lists for a repository.",
+			args: args{
+				get: channel("crossplane/provider-aws: [v0.20.0, v0.21.0]\ncrossplane/provider-gcp: [v0.18.0]"),
+				ref: "crossplane/provider-aws",
+			},
+			want: want{versions: []string{"v0.20.0", "v0.21.0"}},
+		},
+		"ListedDigest": {
+			reason: "We should return the versions the channel lists for a repository without the digests it records.",
+			args: args{
+				get: channel("crossplane/provider-aws: [v0.20.0, 'v0.21.0@sha256:abc']"),
+				ref: "crossplane/provider-aws",
+			},
+			want: want{versions: []string{"v0.20.0", "v0.21.0"}},
+		},
+		"ListedAlias": {
+			reason: "We should match a repository the channel lists using an alias of its registry.",
+			args: args{
+				get: channel("docker.io/crossplane/provider-aws: [v0.20.0]"),
+				ref: "index.docker.io/crossplane/provider-aws:v0.19.0",
+			},
+			want: want{versions: []string{"v0.20.0"}},
+		},
+		"ListedDefaultRegistry": {
+			reason: "We should assume a repository the channel lists without a registry is in the default registry.",
+			args: args{
+				get:      channel("crossplane/provider-aws: [v0.20.0]"),
+				registry: "registry.upbound.io",
+				ref:      "registry.upbound.io/crossplane/provider-aws",
+			},
+			want: want{versions: []string{"v0.20.0"}},
+		},
+		"NotListed": {
+			reason: "We should report that the channel doesn't list a repository.",
+			args: args{
+				get: channel("crossplane/provider-gcp: [v0.18.0]"),
+				ref: "crossplane/provider-aws",
+			},
+			want: want{err: &xpkg.NotListedError{Repository: "index.docker.io/crossplane/provider-aws"}},
+		},
+		"NoChannel": {
+			reason: "We should report that the channel doesn't list a repository if the channel doesn't exist.",
+			args: args{
+				get: test.NewMockGetFn(kerrors.NewNotFound(schema.GroupResource{}, "")),
+				ref: "crossplane/provider-aws",
+			},
+			want: want{err: &xpkg.NotListedError{Repository: "index.docker.io/crossplane/provider-aws"}},
+		},
+		"GetError": {
+			reason: "We should return any error encountered getting the channel.",
+			args: args{
+				get: test.NewMockGetFn(errBoom),
+				ref: "crossplane/provider-aws",
+			},
+			want: want{err: errors.Wrap(errBoom, errGetChannel)},
+		},
+		"ParseError": {
+			reason: "We should return an error if the channel cannot be parsed.",
+			args: args{
+				get: channel(malformed),
+				ref: "crossplane/provider-aws",
+			},
+			want: want{err: errors.Wrap(errMalformed, errParseChannel)},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			s := NewChannelVersionSource(&test.MockClient{MockGet: tc.args.get}, "crossplane-system", "channel", tc.args.registry)
+			versions, err := s.ListVersions(context.Background(), mustParseReference(t, tc.args.ref))
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nListVersions(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.versions, versions); diff != "" {
+				t.Errorf("\n%s\nListVersions(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestChannelRecordedDigest(t *testing.T) {
+	errBoom := errors.New("boom")
+	channel := func(data string) test.MockGetFn {
+		return test.NewMockGetFn(nil, func(o client.Object) error {
+			o.(*corev1.ConfigMap).Data = map[string]string{ChannelKey: data}
+			return nil
+		})
+	}
+
+	type args struct {
+		get     test.MockGetFn
+		version string
+	}
+	type want struct {
+		digest string
+		err    error
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"Recorded": {
+			reason: "We should return the digest the channel records for a version.",
+			args: args{
+				get:     channel("crossplane/provider-aws: [v0.20.0, 'v0.21.0@sha256:abc']"),
+				version: "v0.21.0",
+			},
+			want: want{digest: "sha256:abc"},
+		},
+		"NotRecorded": {
+			reason: "We should return an empty digest if the channel records none for a version.",
+			args: args{
+				get:     channel("crossplane/provider-aws: [v0.20.0, 'v0.21.0@sha256:abc']"),
+				version: "v0.20.0",
+			},
+		},
+		"NotListed": {
+			reason: "We should return an empty digest if the channel doesn't list the repository.",
+			args: args{
+				get:     channel("crossplane/provider-gcp: ['v0.21.0@sha256:abc']"),
+				version: "v0.21.0",
+			},
+		},
+		"GetError": {
+			reason: "We should return any error encountered getting the channel.",
+			args: args{
+				get:     test.NewMockGetFn(errBoom),
+				version: "v0.21.0",
+			},
+			want: want{err: errors.Wrap(errBoom, errGetChannel)},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			s := NewChannelVersionSource(&test.MockClient{MockGet: tc.args.get}, "crossplane-system", "channel", "")
+			d, err := s.RecordedDigest(context.Background(), mustParseReference(t, "crossplane/provider-aws"), tc.args.version)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nRecordedDigest(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.digest, d); diff != "" {
+				t.Errorf("\n%s\nRecordedDigest(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func mustParseReference(t *testing.T, s string) name.Reference {
+	t.Helper()
+	ref, err := name.ParseReference(s)
+	if err != nil {
+		t.Fatalf("name.ParseReference(%q): %s", s, err)
+	}
+	return ref
+}
@@ -0,0 +1,122 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"net/http"
+
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+
+	"github.com/crossplane/crossplane/internal/dag"
+	"github.com/crossplane/crossplane/internal/xpkg"
+)
+
+// A Failure classifies an error the Reconciler encountered while resolving
+// dependencies by whether retrying could fix it.
+type Failure string
+
+// Failure classes.
+const (
+	// FailureTransient errors may be fixed by retrying, e.g. a registry or
+	// the API server being briefly unavailable. The Reconciler requeues
+	// the Lock, backing off if the error persists.
+	FailureTransient Failure = "Transient"
+
+	// FailurePermanent errors won't be fixed by retrying, e.g. an invalid
+	// version constraint or a package rejected by an admission webhook. The
+	// Reconciler surfaces them as a condition on the Lock and doesn't
+	// requeue it. It's queued again when the Lock or a package changes.
+	FailurePermanent Failure = "Permanent"
+)
+
+// A permanentError won't be fixed by retrying.
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
+
+// permanent marks the supplied error as one that retrying won't fix.
+func permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// classify returns the class of the supplied error. Errors are transient
+// unless they're known to be permanent.
+func classify(err error) Failure {
+	var perr *permanentError
+	if errors.As(err, &perr) {
+		return FailurePermanent
+	}
+
+	var cerr *dag.CyclicError
+	if errors.As(err, &cerr) {
+		return FailurePermanent
+	}
+
+	// Only failing to fetch tags or digests, or a version source that
+	// doesn't yet list a dependency, can be fixed by retrying. Invalid dependencies and
+	// constraints must be fixed by the package author, and we'll be queued
+	// if a new version of a package changes them.
+	var derr *xpkg.DependencyError
+	if errors.As(err, &derr) {
+		switch derr.Reason {
+		case xpkg.DependencyFetchTags, xpkg.DependencyFetchDigest, xpkg.DependencyNotListed:
+			return FailureTransient
+		}
+		return FailurePermanent
+	}
+
+	// The API server rejects requests that are invalid, or that an
+	// admission webhook denies, the same way no matter how many times
+	// they're retried. Webhooks often deny requests without a reason, so
+	// we consider the status code too.
+	switch {
+	case kerrors.IsInvalid(err), kerrors.IsForbidden(err), kerrors.IsBadRequest(err), kerrors.IsMethodNotSupported(err):
+		return FailurePermanent
+	}
+	var serr kerrors.APIStatus
+	if errors.As(err, &serr) {
+		switch serr.Status().Code {
+		case http.StatusBadRequest, http.StatusForbidden, http.StatusMethodNotAllowed, http.StatusUnprocessableEntity:
+			return FailurePermanent
+		}
+	}
+
+	return FailureTransient
+}
+
+// retry returns the supplied reason to requeue, unless the supplied error is
+// permanent.
+func retry(rr RequeueReason, err error) RequeueReason {
+	if classify(err) == FailurePermanent {
+		return ""
+	}
+	return rr
+}
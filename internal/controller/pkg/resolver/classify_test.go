@@ -0,0 +1,194 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/fake"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+	"github.com/crossplane/crossplane/internal/dag"
+	"github.com/crossplane/crossplane/internal/xpkg"
+	fakexpkg "github.com/crossplane/crossplane/internal/xpkg/fake"
+)
+
+// denied returns the error the API server returns when an admission webhook
+// denies a request without specifying a reason.
+func denied() error {
+	return &kerrors.StatusError{ErrStatus: metav1.Status{
+		Status:  metav1.StatusFailure,
+		Code:    http.StatusForbidden,
+		Message: `admission webhook "policy.example.org" denied the request: providers are not allowed`,
+	}}
+}
+
+func TestClassify(t *testing.T) {
+	errBoom := errors.New("boom")
+	gr := schema.GroupResource{Group: "pkg.crossplane.io", Resource: "providers"}
+
+	cases := map[string]struct {
+		reason string
+		err    error
+		want   Failure
+	}{
+		"Unknown": {
+			reason: "We should treat errors we know nothing about as transient.",
+			err:    errBoom,
+			want:   FailureTransient,
+		},
+		"Permanent": {
+			reason: "We should treat errors marked permanent as permanent, even when wrapped.",
+			err:    errors.Wrap(permanent(errBoom), "wrapped"),
+			want:   FailurePermanent,
+		},
+		"Cycle": {
+			reason: "We should treat a dependency cycle as permanent; only a user can break it.",
+			err:    &dag.CyclicError{},
+			want:   FailurePermanent,
+		},
+		"FetchTags": {
+			reason: "We should treat failing to fetch tags as transient.",
+			err:    &xpkg.DependencyError{Reason: xpkg.DependencyFetchTags},
+			want:   FailureTransient,
+		},
+		"FetchDigest": {
+			reason: "We should treat failing to fetch a digest as transient.",
+			err:    &xpkg.DependencyError{Reason: xpkg.DependencyFetchDigest},
+			want:   FailureTransient,
+		},
+		"DigestMismatch": {
+			reason: "We should treat a tag that no longer points at its recorded digest as permanent.",
+			err:    &xpkg.DependencyError{Reason: xpkg.DependencyDigestMismatch},
+			want:   FailurePermanent,
+		},
+		"NotListed": {
+			reason: "We should treat a dependency that our version source doesn't list as transient, since it may be listed later.",
+			err:    &xpkg.DependencyError{Reason: xpkg.DependencyNotListed},
+			want:   FailureTransient,
+		},
+		"InvalidConstraint": {
+			reason: "We should treat an invalid version constraint as permanent.",
+			err:    &xpkg.DependencyError{Reason: xpkg.DependencyInvalidConstraint},
+			want:   FailurePermanent,
+		},
+		"InvalidDependency": {
+			reason: "We should treat an invalid dependency as permanent.",
+			err:    &xpkg.DependencyError{Reason: xpkg.DependencyInvalid},
+			want:   FailurePermanent,
+		},
+		"NoValidVersion": {
+			reason: "We should treat a dependency without a valid version as permanent.",
+			err:    &xpkg.DependencyError{Reason: xpkg.DependencyNoValidVersion},
+			want:   FailurePermanent,
+		},
+		"UnsupportedType": {
+			reason: "We should treat a dependency on an unsupported type of package as permanent.",
+			err:    &xpkg.DependencyError{Reason: xpkg.DependencyUnsupportedType},
+			want:   FailurePermanent,
+		},
+		"WebhookDenied": {
+			reason: "We should treat a request denied by an admission webhook as permanent.",
+			err:    errors.Wrap(denied(), errCreateDependency),
+			want:   FailurePermanent,
+		},
+		"Invalid": {
+			reason: "We should treat a request the API server finds invalid as permanent.",
+			err:    kerrors.NewInvalid(schema.GroupKind{Group: gr.Group, Kind: "Provider"}, "cool", field.ErrorList{field.Required(field.NewPath("spec"), "")}),
+			want:   FailurePermanent,
+		},
+		"Forbidden": {
+			reason: "We should treat a forbidden request as permanent.",
+			err:    kerrors.NewForbidden(gr, "cool", errBoom),
+			want:   FailurePermanent,
+		},
+		"Conflict": {
+			reason: "We should treat a conflicting update as transient.",
+			err:    kerrors.NewConflict(gr, "cool", errBoom),
+			want:   FailureTransient,
+		},
+		"Unavailable": {
+			reason: "We should treat the API server being unavailable as transient.",
+			err:    kerrors.NewServiceUnavailable("boom"),
+			want:   FailureTransient,
+		},
+		"Timeout": {
+			reason: "We should treat a timeout as transient.",
+			err:    kerrors.NewServerTimeout(gr, "create", 1),
+			want:   FailureTransient,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if diff := cmp.Diff(tc.want, classify(tc.err)); diff != "" {
+				t.Errorf("\n%s\nclassify(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestReconcileRejected(t *testing.T) {
+	var got *v1beta1.Lock
+	mgr := &fake.Manager{
+		Client: &test.MockClient{
+			MockGet: test.NewMockGetFn(nil, func(o client.Object) error {
+				o.(*v1beta1.Lock).Packages = []v1beta1.LockPackage{{
+					Source:       "cool/config",
+					Dependencies: []v1beta1.Dependency{{Package: "crossplane/provider-aws", Type: v1beta1.ProviderPackageType, Constraints: ">=v0.20.0"}},
+				}}
+				return nil
+			}),
+			MockList:   test.NewMockListFn(nil),
+			MockCreate: test.NewMockCreateFn(denied()),
+			MockUpdate: test.NewMockUpdateFn(nil),
+			MockStatusUpdate: test.NewMockStatusUpdateFn(nil, func(o client.Object) error {
+				got = o.(*v1beta1.Lock)
+				return nil
+			}),
+		},
+	}
+	r := NewReconciler(mgr,
+		WithFetcher(&fakexpkg.MockFetcher{MockTags: fakexpkg.NewMockTagsFn([]string{"v0.20.0"}, nil)}),
+		WithNewDagFn(dag.NewMapDag),
+	)
+	res, _ := r.Reconcile(context.Background(), reconcile.Request{})
+
+	// Retrying a rejected request won't help, so we wait for something to
+	// change rather than requeueing.
+	if diff := cmp.Diff(reconcile.Result{}, res); diff != "" {
+		t.Errorf("r.Reconcile(...): -want, +got:\n%s", diff)
+	}
+	if got == nil {
+		t.Fatal("r.Reconcile(...): expected the Lock's status to be updated")
+	}
+	if diff := cmp.Diff(v1beta1.ReasonRejected, got.GetCondition(v1beta1.TypeDependenciesResolved).Reason); diff != "" {
+		t.Errorf("r.Reconcile(...): -want condition reason, +got condition reason:\n%s", diff)
+	}
+}
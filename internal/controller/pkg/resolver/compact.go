@@ -0,0 +1,134 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"context"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+
+	v1 "github.com/crossplane/crossplane/apis/pkg/v1"
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+)
+
+const (
+	errCompactLock        = "cannot compact lock"
+	errBuildLockGraph     = "cannot build dependency graph of lock"
+	errTraverseLockGraph  = "cannot find packages that user-installed packages depend on"
+	errGetCompactRevision = "cannot get package revision of unreachable package"
+
+	msgCompactedFmt = "removed %d packages that no user-installed package depends on: %s"
+)
+
+// WithLockCompaction specifies that the Reconciler should remove packages
+// from the Lock that were installed as dependencies, but that no package a
+// user installed depends on, directly or transitively. Packages whose
+// revision exists and is healthy are never removed.
+func WithLockCompaction() ReconcilerOption {
+	return func(r *Reconciler) {
+		r.compact = true
+	}
+}
+
+// compactLock removes packages from the supplied Lock that aren't reachable
+// from any package a user installed, and whose revision is missing or
+// unhealthy. The Lock is patched once, and only if packages were removed. It
+// returns the number of packages it removed and a description of them. A
+// package is reachable via the supplied aliases.
+func (r *Reconciler) compactLock(ctx context.Context, log logging.Logger, lock *v1beta1.Lock, aliases map[string]string) (int, string, error) {
+	// Packages depend on each other using normalized, aliased sources, but
+	// we persist the Lock as it was.
+	n := lock.DeepCopy()
+	r.normalize(log, n)
+	alias(log, n, aliases)
+
+	d := r.newDag()
+	if _, err := d.Init(v1beta1.ToNodes(n.Packages...)); err != nil {
+		return 0, "", errors.Wrap(err, errBuildLockGraph)
+	}
+	roots := []string{}
+	for _, p := range n.Packages {
+		if p.GetOrigin().Type == v1beta1.OriginUser {
+			roots = append(roots, p.Identifier())
+		}
+	}
+	reached, err := d.TraverseFrom(roots...)
+	if err != nil {
+		return 0, "", errors.Wrap(err, errTraverseLockGraph)
+	}
+
+	prune := map[int]bool{}
+	for i, p := range n.Packages {
+		if reached[p.Identifier()] {
+			continue
+		}
+		ok, err := r.healthy(ctx, p)
+		if err != nil {
+			return 0, "", err
+		}
+		if ok {
+			log.Debug("Keeping unreachable package with a healthy revision", "source", p.Source, "revision", p.Name)
+			continue
+		}
+		prune[i] = true
+	}
+	if len(prune) == 0 {
+		return 0, "", nil
+	}
+
+	orig := lock.DeepCopy()
+	removed := make([]string, 0, len(prune))
+	kept := make([]v1beta1.LockPackage, 0, len(lock.Packages)-len(prune))
+	for i, p := range lock.Packages {
+		if prune[i] {
+			removed = append(removed, p.Source+" ("+describeLockPackage(p)+")")
+			continue
+		}
+		kept = append(kept, p)
+	}
+	lock.Packages = kept
+
+	// Packages add themselves to the Lock concurrently, so we must not
+	// overwrite a Lock that changed since we read it.
+	err = r.client.Patch(ctx, lock, client.MergeFromWithOptions(orig, client.MergeFromWithOptimisticLock{}))
+	return len(removed), strings.Join(removed, ", "), errors.Wrap(err, errCompactLock)
+}
+
+// healthy returns true if the revision of the supplied package exists and is
+// healthy. Revisions are read from the API server rather than the cache, so
+// that a revision that was created very recently doesn't appear to be
+// missing.
+func (r *Reconciler) healthy(ctx context.Context, p v1beta1.LockPackage) (bool, error) {
+	rev, ok := newPackageRevision(p.Type)
+	if !ok {
+		return false, nil
+	}
+	if err := r.reader.Get(ctx, types.NamespacedName{Name: p.Name}, rev); err != nil {
+		if kerrors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, errors.Wrap(err, errGetCompactRevision)
+	}
+	return rev.GetCondition(v1.TypeHealthy).Status == corev1.ConditionTrue, nil
+}
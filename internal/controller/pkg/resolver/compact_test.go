@@ -0,0 +1,234 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/fake"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	v1 "github.com/crossplane/crossplane/apis/pkg/v1"
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+	"github.com/crossplane/crossplane/internal/dag"
+	fakexpkg "github.com/crossplane/crossplane/internal/xpkg/fake"
+)
+
+// lockPackage returns a package in the Lock that was installed with the
+// supplied origin, and that depends on the supplied packages. An empty origin
+// means the package has none.
+func lockPackage(source string, t v1beta1.PackageType, origin v1beta1.OriginType, deps ...string) v1beta1.LockPackage {
+	p := v1beta1.LockPackage{Name: source[len("crossplane/"):], Source: source, Type: t, Version: "v1.0.0"}
+	if origin != "" {
+		p.Origin = &v1beta1.PackageOrigin{Type: origin}
+	}
+	for _, d := range deps {
+		p.Dependencies = append(p.Dependencies, v1beta1.Dependency{Package: d, Type: v1beta1.ConfigurationPackageType, Constraints: ">=v1.0.0"})
+	}
+	return p
+}
+
+func TestReconcileLockCompaction(t *testing.T) {
+	errBoom := errors.New("boom")
+
+	user, dep := v1beta1.OriginUser, v1beta1.OriginDependency
+
+	// config-a depends on config-c, which was installed for it. config-b and
+	// the config-d it depends on were installed as dependencies of a package
+	// that has since been uninstalled.
+	subtree := []v1beta1.LockPackage{
+		lockPackage("crossplane/config-a", v1beta1.ConfigurationPackageType, user, "crossplane/config-c"),
+		lockPackage("crossplane/config-b", v1beta1.ConfigurationPackageType, dep, "crossplane/config-d"),
+		lockPackage("crossplane/config-c", v1beta1.ConfigurationPackageType, dep),
+		lockPackage("crossplane/config-d", v1beta1.ConfigurationPackageType, dep),
+	}
+
+	// config-a depends on config-b and config-c, which both depend on
+	// config-d.
+	diamond := []v1beta1.LockPackage{
+		lockPackage("crossplane/config-a", v1beta1.ConfigurationPackageType, user, "crossplane/config-b", "crossplane/config-c"),
+		lockPackage("crossplane/config-b", v1beta1.ConfigurationPackageType, dep, "crossplane/config-d"),
+		lockPackage("crossplane/config-c", v1beta1.ConfigurationPackageType, dep, "crossplane/config-d"),
+		lockPackage("crossplane/config-d", v1beta1.ConfigurationPackageType, dep),
+	}
+
+	// config-b has no origin, so it was installed by a user.
+	noOrigin := []v1beta1.LockPackage{
+		lockPackage("crossplane/config-b", v1beta1.ConfigurationPackageType, "", "crossplane/config-d"),
+		lockPackage("crossplane/config-d", v1beta1.ConfigurationPackageType, dep),
+	}
+
+	revision := func(s corev1.ConditionStatus) *v1.ConfigurationRevision {
+		r := &v1.ConfigurationRevision{}
+		r.SetConditions(xpv1.Condition{Type: v1.TypeHealthy, Status: s})
+		return r
+	}
+
+	type args struct {
+		compact   bool
+		lock      []v1beta1.LockPackage
+		revisions map[string]*v1.ConfigurationRevision
+		patchErr  error
+	}
+	type want struct {
+		r        reconcile.Result
+		patched  []string
+		events   []event.Reason
+		messages []string
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"NotCompacted": {
+			reason: "We should never modify the Lock unless compaction is enabled.",
+			args:   args{lock: subtree},
+			want:   want{},
+		},
+		"UnreachableSubtree": {
+			reason: "We should remove every package that no user-installed package depends on in a single patch.",
+			args:   args{compact: true, lock: subtree},
+			want: want{
+				patched:  []string{"crossplane/config-a", "crossplane/config-c"},
+				events:   []event.Reason{reasonCompactLock},
+				messages: []string{"removed 2 packages that no user-installed package depends on: crossplane/config-b (revision config-b at v1.0.0), crossplane/config-d (revision config-d at v1.0.0)"},
+			},
+		},
+		"Diamond": {
+			reason: "We should not remove packages that a user-installed package depends on via more than one path.",
+			args:   args{compact: true, lock: diamond},
+			want:   want{},
+		},
+		"NoOrigin": {
+			reason: "We should treat a package without an origin as installed by a user.",
+			args:   args{compact: true, lock: noOrigin},
+			want:   want{},
+		},
+		"HealthyRevision": {
+			reason: "We should not remove an unreachable package whose revision exists and is healthy.",
+			args: args{
+				compact:   true,
+				lock:      subtree,
+				revisions: map[string]*v1.ConfigurationRevision{"config-d": revision(corev1.ConditionTrue)},
+			},
+			want: want{
+				patched:  []string{"crossplane/config-a", "crossplane/config-c", "crossplane/config-d"},
+				events:   []event.Reason{reasonCompactLock},
+				messages: []string{"removed 1 packages that no user-installed package depends on: crossplane/config-b (revision config-b at v1.0.0)"},
+			},
+		},
+		"UnhealthyRevision": {
+			reason: "We should remove an unreachable package whose revision is unhealthy.",
+			args: args{
+				compact: true,
+				lock:    subtree,
+				revisions: map[string]*v1.ConfigurationRevision{
+					"config-b": revision(corev1.ConditionFalse),
+					"config-d": revision(corev1.ConditionUnknown),
+				},
+			},
+			want: want{
+				patched:  []string{"crossplane/config-a", "crossplane/config-c"},
+				events:   []event.Reason{reasonCompactLock},
+				messages: []string{"removed 2 packages that no user-installed package depends on: crossplane/config-b (revision config-b at v1.0.0), crossplane/config-d (revision config-d at v1.0.0)"},
+			},
+		},
+		"ErrPatchLock": {
+			reason: "We should requeue without resolving dependencies if we cannot patch the Lock.",
+			args:   args{compact: true, lock: subtree, patchErr: errBoom},
+			want: want{
+				r:        reconcile.Result{RequeueAfter: shortWait},
+				patched:  []string{"crossplane/config-a", "crossplane/config-c"},
+				events:   []event.Reason{reasonCompactLock},
+				messages: []string{errors.Wrap(errBoom, errCompactLock).Error()},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var patched []string
+			mgr := &fake.Manager{
+				Client: &test.MockClient{
+					MockGet: func(_ context.Context, key client.ObjectKey, o client.Object) error {
+						switch obj := o.(type) {
+						case *v1beta1.Lock:
+							obj.Packages = append([]v1beta1.LockPackage{}, tc.args.lock...)
+							return nil
+						case *v1.ConfigurationRevision:
+							if r, ok := tc.args.revisions[key.Name]; ok {
+								r.DeepCopyInto(obj)
+								return nil
+							}
+						}
+						return kerrors.NewNotFound(schema.GroupResource{}, key.Name)
+					},
+					MockList: test.NewMockListFn(nil),
+					MockPatch: func(_ context.Context, o client.Object, _ client.Patch, _ ...client.PatchOption) error {
+						for _, p := range o.(*v1beta1.Lock).Packages {
+							patched = append(patched, p.Source)
+						}
+						return tc.args.patchErr
+					},
+					MockCreate:       test.NewMockCreateFn(nil),
+					MockUpdate:       test.NewMockUpdateFn(nil),
+					MockStatusUpdate: test.NewMockStatusUpdateFn(nil),
+				},
+			}
+			rec := &recorder{}
+			opts := []ReconcilerOption{
+				WithRecorder(rec),
+				WithFetcher(&fakexpkg.MockFetcher{MockTags: fakexpkg.NewMockTagsFn([]string{"v1.0.0"}, nil)}),
+				WithNewDagFn(dag.NewMapDag),
+			}
+			if tc.args.compact {
+				opts = append(opts, WithLockCompaction())
+			}
+			r := NewReconciler(mgr, opts...)
+			got, err := r.Reconcile(context.Background(), reconcile.Request{})
+			if err != nil {
+				t.Fatalf("\n%s\nr.Reconcile(...): %s", tc.reason, err)
+			}
+
+			if diff := cmp.Diff(tc.want.r, got); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want, +got:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.patched, patched); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want patched packages, +got patched packages:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.events, rec.reasons); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want events, +got events:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.messages, rec.messages); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want event messages, +got event messages:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
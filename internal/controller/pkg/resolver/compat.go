@@ -0,0 +1,123 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Masterminds/semver"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+
+	v1 "github.com/crossplane/crossplane/apis/pkg/v1"
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+	"github.com/crossplane/crossplane/internal/xpkg"
+)
+
+const (
+	errCheckCompatibleFmt = "cannot determine whether dependency package %s is compatible with Crossplane %s; installing it anyway"
+	errUnknownCrossplane  = "package metadata does not declare which versions of Crossplane it requires"
+	errIncompatibleFmt    = "package requires Crossplane version %s, but Crossplane %s is running"
+)
+
+// WithCrossplaneVersion specifies the version of Crossplane that is running.
+// The Reconciler refuses to install a dependency whose package metadata
+// declares that it requires a different version of Crossplane.
+func WithCrossplaneVersion(v string) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.crossplane = v
+	}
+}
+
+// incompatible returns the range of Crossplane versions that the supplied
+// dependency package requires if the running version of Crossplane is not
+// within it, or an empty string if it is. A package that declares no range is
+// compatible with every version. We fail open, recording the dependency as
+// unchecked, if its metadata doesn't tell us the range; the package manager
+// still refuses to install an incompatible package revision.
+func (r *Reconciler) incompatible(ctx context.Context, log logging.Logger, lock *v1beta1.Lock, pack v1.Package, res *resolutionResult) string {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	// There's nothing to check if we already installed this dependency.
+	if n, err := r.created(ctx, pack, r.sourceRepository(pack.GetSource())); err != nil || n != "" {
+		return ""
+	}
+
+	required, ok, err := r.compatible(ctx, pack)
+	if err != nil {
+		err = errors.Wrapf(err, errCheckCompatibleFmt, pack.GetSource(), r.crossplane)
+		log.Debug("Cannot check dependency for Crossplane compatibility", "error", err)
+		r.record.Event(lock, event.Warning(reasonCheckCompatible, err))
+		res.unchecked(err)
+		return ""
+	}
+	if ok {
+		return ""
+	}
+	return required
+}
+
+// compatible returns the range of Crossplane versions that the supplied
+// package requires, and whether the running version is within it.
+func (r *Reconciler) compatible(ctx context.Context, pack v1.Package) (string, bool, error) {
+	m, err := r.meta(ctx, pack)
+	if err != nil {
+		return "", false, err
+	}
+	if !m.KnowsCrossplane() {
+		return "", false, errors.New(errUnknownCrossplane)
+	}
+	if m.Crossplane == "" {
+		return "", true, nil
+	}
+	c, err := semver.NewConstraint(m.Crossplane)
+	if err != nil {
+		return "", false, err
+	}
+	v, err := semver.NewVersion(r.crossplane)
+	if err != nil {
+		return "", false, err
+	}
+	return m.Crossplane, c.Check(v), nil
+}
+
+// rejectIncompatible reports that the supplied dependency was not installed
+// because it requires the supplied range of Crossplane versions. Only
+// upgrading Crossplane or changing the dependency's constraints can resolve
+// this, so we don't requeue.
+func (r *Reconciler) rejectIncompatible(log logging.Logger, lock *v1beta1.Lock, p xpkg.PlannedInstall, required string) (xpv1.Condition, RequeueReason, error) {
+	err := errors.Errorf(errDependencyFmt, fmt.Sprintf(errIncompatibleFmt, required, r.crossplane), p.Dependency.Package, p.Dependency.Constraints)
+	log.Debug(errInstallDependency, "error", err)
+	r.record.Event(lock, event.Warning(reasonIncompatible, err))
+	r.metrics.failures.WithLabelValues(failureIncompatible).Inc()
+	return v1beta1.IncompatibleCrossplaneVersion(), "", err
+}
+
+// checked returns a condition describing whether the dependencies installed
+// while producing the supplied result were checked before they were installed.
+func checked(res *resolutionResult) xpv1.Condition {
+	if len(res.unknown) == 0 {
+		return v1beta1.DependenciesChecked()
+	}
+	return v1beta1.DependencyMetadataUnknown().WithMessage(strings.Join(res.unknown, "; "))
+}
@@ -0,0 +1,220 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/fake"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+	"github.com/crossplane/crossplane/internal/dag"
+	"github.com/crossplane/crossplane/internal/xpkg"
+	fakexpkg "github.com/crossplane/crossplane/internal/xpkg/fake"
+)
+
+func TestReconcileCrossplaneVersion(t *testing.T) {
+	errBoom := errors.New("boom")
+
+	requires := fakexpkg.NewMockFetchMetaFn(&xpkg.PackageMeta{Crossplane: ">=v1.4.0", Complete: true}, nil)
+	unknown := "cannot determine whether dependency package crossplane/provider-aws:v0.20.0 is compatible with Crossplane v1.3.0; installing it anyway: " + errUnknownCrossplane
+
+	type args struct {
+		version string
+		meta    func() (*xpkg.PackageMeta, error)
+	}
+	type want struct {
+		created  []string
+		events   []event.Reason
+		reason   xpv1.ConditionReason
+		message  string
+		messages []string
+		checked  xpv1.Condition
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"NoRunningVersion": {
+			reason: "We should not check compatibility unless we know which version of Crossplane is running.",
+			args:   args{meta: requires},
+			want: want{
+				created: []string{"crossplane-provider-aws"},
+				events:  []event.Reason{reasonInstallDependency},
+				reason:  v1beta1.ReasonMissingDependency,
+			},
+		},
+		"Compatible": {
+			reason: "We should install a dependency that requires a range of Crossplane versions the running version is within.",
+			args:   args{version: "v1.5.0", meta: requires},
+			want: want{
+				created: []string{"crossplane-provider-aws"},
+				events:  []event.Reason{reasonInstallDependency},
+				reason:  v1beta1.ReasonMissingDependency,
+				checked: v1beta1.DependenciesChecked(),
+			},
+		},
+		"Incompatible": {
+			reason: "We should refuse to install a dependency that requires a range of Crossplane versions the running version is not within.",
+			args:   args{version: "v1.3.0", meta: requires},
+			want: want{
+				events:   []event.Reason{reasonIncompatible},
+				reason:   v1beta1.ReasonIncompatible,
+				message:  `package requires Crossplane version >=v1.4.0, but Crossplane v1.3.0 is running: dependency crossplane/provider-aws with constraints ">=v0.20.0"`,
+				messages: []string{`package requires Crossplane version >=v1.4.0, but Crossplane v1.3.0 is running: dependency crossplane/provider-aws with constraints ">=v0.20.0"`},
+				checked:  v1beta1.DependenciesChecked(),
+			},
+		},
+		"NoConstraints": {
+			reason: "We should install a dependency whose complete metadata declares no required range of Crossplane versions.",
+			args:   args{version: "v1.3.0", meta: fakexpkg.NewMockFetchMetaFn(&xpkg.PackageMeta{Complete: true}, nil)},
+			want: want{
+				created: []string{"crossplane-provider-aws"},
+				events:  []event.Reason{reasonInstallDependency},
+				reason:  v1beta1.ReasonMissingDependency,
+				checked: v1beta1.DependenciesChecked(),
+			},
+		},
+		"UnknownConstraints": {
+			reason: "We should warn, install the dependency anyway, and report that it was unchecked if its metadata doesn't tell us which versions of Crossplane it requires.",
+			args:   args{version: "v1.3.0", meta: fakexpkg.NewMockFetchMetaFn(&xpkg.PackageMeta{}, nil)},
+			want: want{
+				created: []string{"crossplane-provider-aws"},
+				events:  []event.Reason{reasonCheckCompatible, reasonInstallDependency},
+				reason:  v1beta1.ReasonMissingDependency,
+				messages: []string{
+					unknown,
+					"Installed automatically as a dependency of cool/config to satisfy constraint >=v0.20.0, selected version v0.20.0 from 1 available tags",
+				},
+				checked: v1beta1.DependencyMetadataUnknown().WithMessage(unknown),
+			},
+		},
+		"ErrFetchMetadata": {
+			reason: "We should warn, install the dependency anyway, and report that it was unchecked if we cannot fetch its metadata.",
+			args:   args{version: "v1.3.0", meta: fakexpkg.NewMockFetchMetaFn(nil, errBoom)},
+			want: want{
+				created: []string{"crossplane-provider-aws"},
+				events:  []event.Reason{reasonCheckCompatible, reasonInstallDependency},
+				reason:  v1beta1.ReasonMissingDependency,
+				messages: []string{
+					errors.Wrapf(errBoom, errCheckCompatibleFmt, "crossplane/provider-aws:v0.20.0", "v1.3.0").Error(),
+					"Installed automatically as a dependency of cool/config to satisfy constraint >=v0.20.0, selected version v0.20.0 from 1 available tags",
+				},
+				checked: v1beta1.DependencyMetadataUnknown().WithMessage(errors.Wrapf(errBoom, errCheckCompatibleFmt, "crossplane/provider-aws:v0.20.0", "v1.3.0").Error()),
+			},
+		},
+		"InvalidRunningVersion": {
+			reason: "We should warn, then install the dependency anyway, if the running version of Crossplane is not a semantic version.",
+			args:   args{version: "master", meta: requires},
+			want: want{
+				created: []string{"crossplane-provider-aws"},
+				events:  []event.Reason{reasonCheckCompatible, reasonInstallDependency},
+				reason:  v1beta1.ReasonMissingDependency,
+				checked: v1beta1.DependencyMetadataUnknown(),
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var created []string
+			var got *v1beta1.Lock
+			mgr := &fake.Manager{
+				Client: &test.MockClient{
+					MockGet: func(_ context.Context, key client.ObjectKey, o client.Object) error {
+						if l, ok := o.(*v1beta1.Lock); ok {
+							l.Packages = []v1beta1.LockPackage{{
+								Source: "cool/config",
+								Dependencies: []v1beta1.Dependency{
+									{Package: "crossplane/provider-aws", Type: v1beta1.ProviderPackageType, Constraints: ">=v0.20.0"},
+								},
+							}}
+							return nil
+						}
+						return kerrors.NewNotFound(schema.GroupResource{}, key.Name)
+					},
+					MockList: test.NewMockListFn(nil),
+					MockCreate: func(_ context.Context, o client.Object, _ ...client.CreateOption) error {
+						created = append(created, o.GetName())
+						return nil
+					},
+					MockUpdate: test.NewMockUpdateFn(nil),
+					MockStatusUpdate: test.NewMockStatusUpdateFn(nil, func(o client.Object) error {
+						got = o.(*v1beta1.Lock)
+						return nil
+					}),
+				},
+			}
+			rec := &recorder{}
+			r := NewReconciler(mgr,
+				WithRecorder(rec),
+				WithFetcher(&fakexpkg.MockFetcher{
+					MockFetchMeta: tc.args.meta,
+					MockTags:      fakexpkg.NewMockTagsFn([]string{"v0.20.0"}, nil),
+				}),
+				WithNewDagFn(dag.NewMapDag),
+				WithCrossplaneVersion(tc.args.version),
+			)
+
+			if _, err := r.Reconcile(context.Background(), reconcile.Request{}); err != nil {
+				t.Fatalf("\n%s\nr.Reconcile(...): %s", tc.reason, err)
+			}
+			if diff := cmp.Diff(tc.want.created, created); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want created, +got created:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.events, rec.reasons); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want events, +got events:\n%s", tc.reason, diff)
+			}
+			c := got.GetCondition(v1beta1.TypeDependenciesResolved)
+			if diff := cmp.Diff(tc.want.reason, c.Reason); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want condition reason, +got condition reason:\n%s", tc.reason, diff)
+			}
+			if tc.want.message != "" {
+				if diff := cmp.Diff(tc.want.message, c.Message); diff != "" {
+					t.Errorf("\n%s\nr.Reconcile(...): -want condition message, +got condition message:\n%s", tc.reason, diff)
+				}
+			}
+			if tc.want.messages != nil {
+				if diff := cmp.Diff(tc.want.messages, rec.messages); diff != "" {
+					t.Errorf("\n%s\nr.Reconcile(...): -want event messages, +got event messages:\n%s", tc.reason, diff)
+				}
+			}
+			checked := got.GetCondition(v1beta1.TypeDependenciesChecked)
+			if diff := cmp.Diff(tc.want.checked.Reason, checked.Reason); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want checked reason, +got checked reason:\n%s", tc.reason, diff)
+			}
+			if tc.want.checked.Message != "" {
+				if diff := cmp.Diff(tc.want.checked.Message, checked.Message); diff != "" {
+					t.Errorf("\n%s\nr.Reconcile(...): -want checked message, +got checked message:\n%s", tc.reason, diff)
+				}
+			}
+		})
+	}
+}
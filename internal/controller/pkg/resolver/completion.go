@@ -0,0 +1,112 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+)
+
+const (
+	msgDependenciesInstalledFmt = "All %d packages in the Lock are installed, %d of them automatically, %s after missing dependencies were first observed"
+	msgAwaitingRevisionsFmt     = "waiting for the package revisions of %s"
+)
+
+// startResolution records that the supplied Lock is missing dependencies, if
+// we haven't already since its dependencies were last resolved.
+func (r *Reconciler) startResolution(lock *v1beta1.Lock) {
+	if lock.Status.ResolutionStartedAt != nil {
+		return
+	}
+	t := metav1.NewTime(r.now())
+	lock.Status.ResolutionStartedAt = &t
+	lock.Status.ResolutionInstalled = 0
+}
+
+// completeResolution returns a message summarizing the resolution of the
+// supplied Lock's dependencies, and clears the record of it, if the Lock was
+// missing dependencies and every package in it is now backed by a package
+// revision. It returns an empty message otherwise. The Lock must not be
+// missing any dependencies. It also sets the Lock's DependenciesSatisfied
+// condition, unless it can't tell whether every package is backed.
+func (r *Reconciler) completeResolution(ctx context.Context, lock *v1beta1.Lock) (string, error) {
+	// Every package adds itself to the Lock once its revision exists, so
+	// we trust that a Lock that was never missing dependencies is backed.
+	started := lock.Status.ResolutionStartedAt
+	if started == nil {
+		satisfy(lock, nil)
+		return "", nil
+	}
+
+	// A package adds itself to the Lock once its revision exists, but the
+	// revision may since have been deleted. We read revisions from the
+	// cache rather than list them from the API server every time we
+	// reconcile a Lock that's waiting for them. If the cache is stale
+	// we'll be queued again when it learns about the revision.
+	_, stale, err := r.backed(ctx, r.client, lock.Packages)
+	if err != nil {
+		return "", err
+	}
+	satisfy(lock, stale)
+	if len(stale) > 0 {
+		return "", nil
+	}
+
+	elapsed := r.now().Sub(started.Time).Round(time.Second)
+	msg := fmt.Sprintf(msgDependenciesInstalledFmt, len(lock.Packages), lock.Status.ResolutionInstalled, elapsed)
+	lock.Status.ResolutionStartedAt = nil
+	lock.Status.ResolutionInstalled = 0
+	return msg, nil
+}
+
+// satisfy sets the DependenciesSatisfied condition of the supplied Lock. Its
+// dependencies are satisfied once they're resolved and none of the supplied
+// packages are stale. The condition only ever becomes True here, once a pass
+// has confirmed both, so that it doesn't flap while dependencies are being
+// installed.
+func satisfy(lock *v1beta1.Lock, stale []v1beta1.LockPackage) {
+	if unsatisfied(lock) {
+		return
+	}
+	if len(stale) > 0 {
+		lock.SetConditions(v1beta1.AwaitingPackageRevisions().WithMessage(fmt.Sprintf(msgAwaitingRevisionsFmt, describeStale(stale))))
+		return
+	}
+	lock.SetConditions(v1beta1.DependenciesSatisfied())
+}
+
+// unsatisfied sets the DependenciesSatisfied condition of the supplied Lock
+// to False, and returns true, if its DependenciesResolved condition isn't
+// True.
+func unsatisfied(lock *v1beta1.Lock) bool {
+	c := lock.GetCondition(v1beta1.TypeDependenciesResolved)
+	if c.Status == corev1.ConditionTrue {
+		return false
+	}
+	msg := string(c.Reason)
+	if c.Message != "" {
+		msg = fmt.Sprintf("%s: %s", c.Reason, c.Message)
+	}
+	lock.SetConditions(v1beta1.DependenciesUnsatisfied().WithMessage(msg))
+	return true
+}
@@ -0,0 +1,377 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/fake"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	v1 "github.com/crossplane/crossplane/apis/pkg/v1"
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+	"github.com/crossplane/crossplane/internal/dag"
+	fakexpkg "github.com/crossplane/crossplane/internal/xpkg/fake"
+)
+
+func TestReconcileDependenciesInstalled(t *testing.T) {
+	start := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) *metav1.Time {
+		t := metav1.NewTime(start.Add(d))
+		return &t
+	}
+
+	// A Configuration that depends on a provider, and on a Configuration
+	// that depends on another provider.
+	config := v1beta1.LockPackage{Name: "config", Type: v1beta1.ConfigurationPackageType, Source: "cool/config", Dependencies: []v1beta1.Dependency{
+		{Package: "crossplane/provider-aws", Type: v1beta1.ProviderPackageType, Constraints: ">=v0.20.0"},
+		{Package: "cool/config-base", Type: v1beta1.ConfigurationPackageType, Constraints: ">=v1.0.0"},
+	}}
+	base := v1beta1.LockPackage{Name: "config-base", Type: v1beta1.ConfigurationPackageType, Source: "cool/config-base", Version: "v1.0.0", Dependencies: []v1beta1.Dependency{
+		{Package: "crossplane/provider-gcp", Type: v1beta1.ProviderPackageType, Constraints: ">=v0.20.0"},
+	}}
+	aws := v1beta1.LockPackage{Name: "provider-aws", Type: v1beta1.ProviderPackageType, Source: "crossplane/provider-aws", Version: "v0.20.0"}
+	gcp := v1beta1.LockPackage{Name: "provider-gcp", Type: v1beta1.ProviderPackageType, Source: "crossplane/provider-gcp", Version: "v0.20.0"}
+
+	stored := &v1beta1.Lock{}
+	stored.SetName("lock")
+
+	// The package revisions that exist, and the packages that were created.
+	var revisions []v1beta1.LockPackage
+	var created []string
+	mgr := &fake.Manager{
+		Client: &test.MockClient{
+			MockGet: func(_ context.Context, key client.ObjectKey, o client.Object) error {
+				if l, ok := o.(*v1beta1.Lock); ok {
+					stored.DeepCopyInto(l)
+					return nil
+				}
+				return kerrors.NewNotFound(schema.GroupResource{}, key.Name)
+			},
+			MockList: test.NewMockListFn(nil, func(o client.ObjectList) error {
+				for _, p := range revisions {
+					switch l := o.(type) {
+					case *v1.ConfigurationRevisionList:
+						if p.Type == v1beta1.ConfigurationPackageType {
+							rev := v1.ConfigurationRevision{ObjectMeta: metav1.ObjectMeta{Name: p.Name}}
+							rev.SetSource(p.Source + ":" + p.Version)
+							l.Items = append(l.Items, rev)
+						}
+					case *v1.ProviderRevisionList:
+						if p.Type == v1beta1.ProviderPackageType {
+							rev := v1.ProviderRevision{ObjectMeta: metav1.ObjectMeta{Name: p.Name}}
+							rev.SetSource(p.Source + ":" + p.Version)
+							l.Items = append(l.Items, rev)
+						}
+					}
+				}
+				return nil
+			}),
+			MockCreate: func(_ context.Context, o client.Object, _ ...client.CreateOption) error {
+				created = append(created, o.(v1.Package).GetSource())
+				return nil
+			},
+			MockUpdate: test.NewMockUpdateFn(nil),
+			MockStatusUpdate: func(_ context.Context, o client.Object, _ ...client.UpdateOption) error {
+				o.(*v1beta1.Lock).Status.DeepCopyInto(&stored.Status)
+				return nil
+			},
+		},
+	}
+
+	rec := &recorder{}
+	now := start
+	r := NewReconciler(mgr,
+		WithRecorder(rec),
+		WithFetcher(fakexpkg.NewTagFetcher(map[string][]string{
+			"crossplane/provider-aws": {"v0.20.0"},
+			"crossplane/provider-gcp": {"v0.20.0"},
+			"cool/config-base":        {"v1.0.0"},
+		})),
+		WithNewDagFn(dag.NewMapDag),
+	)
+	r.now = func() time.Time { return now }
+
+	type want struct {
+		created   []string
+		started   *metav1.Time
+		installed int64
+	}
+
+	// Each step reconciles the Lock after the supplied time has passed since
+	// the first step. Steps run in order, and depend on the Lock status that
+	// was recorded by the steps before them.
+	steps := []struct {
+		name   string
+		reason string
+		after  time.Duration
+		change func()
+		want   want
+	}{
+		{
+			name:   "MissingDependencies",
+			reason: "We should record when we first observe missing dependencies, and count the packages we install.",
+			change: func() {
+				stored.Packages = []v1beta1.LockPackage{config}
+				revisions = []v1beta1.LockPackage{config}
+			},
+			want: want{
+				created:   []string{"crossplane/provider-aws:v0.20.0", "cool/config-base:v1.0.0"},
+				started:   at(0),
+				installed: 2,
+			},
+		},
+		{
+			name:   "TransitiveDependency",
+			reason: "We should keep counting the packages we install to satisfy dependencies of the packages we installed.",
+			after:  time.Minute,
+			change: func() {
+				stored.Packages = []v1beta1.LockPackage{config, aws, base}
+				revisions = []v1beta1.LockPackage{config, aws, base}
+			},
+			want: want{
+				created:   []string{"crossplane/provider-gcp:v0.20.0"},
+				started:   at(0),
+				installed: 3,
+			},
+		},
+		{
+			name:   "RevisionMissing",
+			reason: "We should not announce that resolution is complete while a package in the Lock has no revision.",
+			after:  2 * time.Minute,
+			change: func() {
+				stored.Packages = []v1beta1.LockPackage{config, aws, base, gcp}
+			},
+			want: want{
+				started:   at(0),
+				installed: 3,
+			},
+		},
+		{
+			name:   "Complete",
+			reason: "We should announce that resolution is complete, and clear our record of it, once every package has a revision.",
+			after:  3*time.Minute + 20*time.Second,
+			change: func() {
+				revisions = []v1beta1.LockPackage{config, aws, base, gcp}
+			},
+		},
+		{
+			name:   "StillComplete",
+			reason: "We should not announce that resolution is complete again.",
+			after:  4 * time.Minute,
+		},
+	}
+
+	for _, s := range steps {
+		if s.change != nil {
+			s.change()
+		}
+		created = nil
+		now = start.Add(s.after)
+
+		if _, err := r.Reconcile(context.Background(), reconcile.Request{}); err != nil {
+			t.Fatalf("\n%s: %s\nr.Reconcile(...): %s", s.name, s.reason, err)
+		}
+		if diff := cmp.Diff(s.want.created, created); diff != "" {
+			t.Errorf("\n%s: %s\nr.Reconcile(...): -want created, +got created:\n%s", s.name, s.reason, diff)
+		}
+		if diff := cmp.Diff(s.want.started, stored.Status.ResolutionStartedAt); diff != "" {
+			t.Errorf("\n%s: %s\nr.Reconcile(...): -want resolution started, +got resolution started:\n%s", s.name, s.reason, diff)
+		}
+		if diff := cmp.Diff(s.want.installed, stored.Status.ResolutionInstalled); diff != "" {
+			t.Errorf("\n%s: %s\nr.Reconcile(...): -want installed, +got installed:\n%s", s.name, s.reason, diff)
+		}
+	}
+
+	// We should have announced that resolution was complete exactly once.
+	var msgs []string
+	for i, reason := range rec.reasons {
+		if reason == reasonInstalled {
+			msgs = append(msgs, rec.messages[i])
+		}
+	}
+	wantMsgs := []string{"All 4 packages in the Lock are installed, 3 of them automatically, 3m20s after missing dependencies were first observed"}
+	if diff := cmp.Diff(wantMsgs, msgs); diff != "" {
+		t.Errorf("r.Reconcile(...): -want completion events, +got completion events:\n%s", diff)
+	}
+}
+
+func TestReconcileDependenciesSatisfied(t *testing.T) {
+	// A Configuration that depends on a Configuration that depends on a
+	// provider.
+	config := v1beta1.LockPackage{Name: "config", Type: v1beta1.ConfigurationPackageType, Source: "cool/config", Dependencies: []v1beta1.Dependency{
+		{Package: "cool/config-base", Type: v1beta1.ConfigurationPackageType, Constraints: ">=v1.0.0"},
+	}}
+	base := v1beta1.LockPackage{Name: "config-base", Type: v1beta1.ConfigurationPackageType, Source: "cool/config-base", Version: "v1.0.0", Dependencies: []v1beta1.Dependency{
+		{Package: "crossplane/provider-gcp", Type: v1beta1.ProviderPackageType, Constraints: ">=v0.20.0"},
+	}}
+	gcp := v1beta1.LockPackage{Name: "provider-gcp", Type: v1beta1.ProviderPackageType, Source: "crossplane/provider-gcp", Version: "v0.20.0"}
+
+	// A Configuration added once the first is installed.
+	app := v1beta1.LockPackage{Name: "app", Type: v1beta1.ConfigurationPackageType, Source: "cool/app", Dependencies: []v1beta1.Dependency{
+		{Package: "crossplane/provider-aws", Type: v1beta1.ProviderPackageType, Constraints: ">=v0.20.0"},
+	}}
+	aws := v1beta1.LockPackage{Name: "provider-aws", Type: v1beta1.ProviderPackageType, Source: "crossplane/provider-aws", Version: "v0.20.0"}
+
+	stored := &v1beta1.Lock{}
+	stored.SetName("lock")
+
+	// The package revisions that exist.
+	var revisions []v1beta1.LockPackage
+	mgr := &fake.Manager{
+		Client: &test.MockClient{
+			MockGet: func(_ context.Context, key client.ObjectKey, o client.Object) error {
+				if l, ok := o.(*v1beta1.Lock); ok {
+					stored.DeepCopyInto(l)
+					return nil
+				}
+				return kerrors.NewNotFound(schema.GroupResource{}, key.Name)
+			},
+			MockList: test.NewMockListFn(nil, func(o client.ObjectList) error {
+				for _, p := range revisions {
+					switch l := o.(type) {
+					case *v1.ConfigurationRevisionList:
+						if p.Type == v1beta1.ConfigurationPackageType {
+							rev := v1.ConfigurationRevision{ObjectMeta: metav1.ObjectMeta{Name: p.Name}}
+							rev.SetSource(p.Source + ":" + p.Version)
+							l.Items = append(l.Items, rev)
+						}
+					case *v1.ProviderRevisionList:
+						if p.Type == v1beta1.ProviderPackageType {
+							rev := v1.ProviderRevision{ObjectMeta: metav1.ObjectMeta{Name: p.Name}}
+							rev.SetSource(p.Source + ":" + p.Version)
+							l.Items = append(l.Items, rev)
+						}
+					}
+				}
+				return nil
+			}),
+			MockCreate: test.NewMockCreateFn(nil),
+			MockUpdate: test.NewMockUpdateFn(nil),
+			MockStatusUpdate: func(_ context.Context, o client.Object, _ ...client.UpdateOption) error {
+				o.(*v1beta1.Lock).Status.DeepCopyInto(&stored.Status)
+				return nil
+			},
+		},
+	}
+
+	r := NewReconciler(mgr,
+		WithFetcher(fakexpkg.NewTagFetcher(map[string][]string{
+			"crossplane/provider-aws": {"v0.20.0"},
+			"crossplane/provider-gcp": {"v0.20.0"},
+			"cool/config-base":        {"v1.0.0"},
+		})),
+		WithNewDagFn(dag.NewMapDag),
+	)
+
+	type want struct {
+		Status corev1.ConditionStatus
+		Reason xpv1.ConditionReason
+	}
+
+	// Steps run in order, and depend on the Lock status that was recorded by
+	// the steps before them.
+	steps := []struct {
+		name   string
+		reason string
+		change func()
+		want   want
+	}{
+		{
+			name:   "MissingDependencies",
+			reason: "Dependencies should be unsatisfied while the Lock is missing dependencies.",
+			change: func() {
+				stored.Packages = []v1beta1.LockPackage{config}
+				revisions = []v1beta1.LockPackage{config}
+			},
+			want: want{Status: corev1.ConditionFalse, Reason: v1beta1.ReasonUnresolved},
+		},
+		{
+			name:   "TransitiveDependency",
+			reason: "Dependencies should remain unsatisfied while the dependencies of installed dependencies are missing.",
+			change: func() {
+				stored.Packages = []v1beta1.LockPackage{config, base}
+				revisions = []v1beta1.LockPackage{config, base}
+			},
+			want: want{Status: corev1.ConditionFalse, Reason: v1beta1.ReasonUnresolved},
+		},
+		{
+			name:   "RevisionMissing",
+			reason: "Dependencies should remain unsatisfied while a package in the Lock has no revision.",
+			change: func() {
+				stored.Packages = []v1beta1.LockPackage{config, base, gcp}
+			},
+			want: want{Status: corev1.ConditionFalse, Reason: v1beta1.ReasonAwaitingRevisions},
+		},
+		{
+			name:   "Satisfied",
+			reason: "Dependencies should be satisfied once every package has a revision.",
+			change: func() {
+				revisions = []v1beta1.LockPackage{config, base, gcp}
+			},
+			want: want{Status: corev1.ConditionTrue, Reason: v1beta1.ReasonSatisfied},
+		},
+		{
+			name:   "StillSatisfied",
+			reason: "Dependencies should remain satisfied when nothing changes.",
+			want:   want{Status: corev1.ConditionTrue, Reason: v1beta1.ReasonSatisfied},
+		},
+		{
+			name:   "NewDependent",
+			reason: "Dependencies should be unsatisfied as soon as a package with missing dependencies is added.",
+			change: func() {
+				stored.Packages = []v1beta1.LockPackage{config, base, gcp, app}
+				revisions = []v1beta1.LockPackage{config, base, gcp, app}
+			},
+			want: want{Status: corev1.ConditionFalse, Reason: v1beta1.ReasonUnresolved},
+		},
+		{
+			name:   "SatisfiedAgain",
+			reason: "Dependencies should be satisfied again once the new package's dependencies are installed.",
+			change: func() {
+				stored.Packages = []v1beta1.LockPackage{config, base, gcp, app, aws}
+				revisions = []v1beta1.LockPackage{config, base, gcp, app, aws}
+			},
+			want: want{Status: corev1.ConditionTrue, Reason: v1beta1.ReasonSatisfied},
+		},
+	}
+
+	for _, s := range steps {
+		if s.change != nil {
+			s.change()
+		}
+
+		if _, err := r.Reconcile(context.Background(), reconcile.Request{}); err != nil {
+			t.Fatalf("\n%s: %s\nr.Reconcile(...): %s", s.name, s.reason, err)
+		}
+		c := stored.GetCondition(v1beta1.TypeDependenciesSatisfied)
+		if diff := cmp.Diff(s.want, want{Status: c.Status, Reason: c.Reason}); diff != "" {
+			t.Errorf("\n%s: %s\nr.Reconcile(...): -want condition, +got condition:\n%s", s.name, s.reason, diff)
+		}
+	}
+}
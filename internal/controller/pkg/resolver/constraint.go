@@ -0,0 +1,43 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+	"github.com/crossplane/crossplane/internal/xpkg"
+)
+
+const (
+	errEmptyConstraintFmt = "dependency %s of package %s has no version constraint; treating it as %q"
+)
+
+// warnEmptyConstraints records a warning for each dependency in the supplied
+// Lock that has no version constraint. Such dependencies are resolved as if
+// they were constrained to the latest version, which is rarely what a
+// package author intends.
+func (r *Reconciler) warnEmptyConstraints(lock *v1beta1.Lock) {
+	for _, p := range lock.Packages {
+		for _, d := range p.Dependencies {
+			if d.Constraints == "" {
+				r.record.Event(lock, event.Warning(reasonEmptyConstraint, errors.Errorf(errEmptyConstraintFmt, d.Package, p.Source, xpkg.ConstraintLatest)))
+			}
+		}
+	}
+}
@@ -0,0 +1,81 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/fake"
+
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+)
+
+func TestWarnEmptyConstraints(t *testing.T) {
+	type want struct {
+		reasons  []event.Reason
+		messages []string
+	}
+
+	cases := map[string]struct {
+		reason string
+		pkgs   []v1beta1.LockPackage
+		want   want
+	}{
+		"NoEmptyConstraints": {
+			reason: "We should not warn about dependencies that have a version constraint or alias.",
+			pkgs: []v1beta1.LockPackage{{
+				Source: "cool/config",
+				Dependencies: []v1beta1.Dependency{
+					{Package: "crossplane/provider-aws", Constraints: ">=v0.1.0"},
+					{Package: "crossplane/provider-gcp", Constraints: "latest"},
+				},
+			}},
+			want: want{},
+		},
+		"EmptyConstraint": {
+			reason: "We should warn about each dependency with no version constraint, naming the package that depends on it.",
+			pkgs: []v1beta1.LockPackage{{
+				Source: "cool/config",
+				Dependencies: []v1beta1.Dependency{
+					{Package: "crossplane/provider-aws", Constraints: ">=v0.1.0"},
+					{Package: "crossplane/provider-gcp"},
+				},
+			}},
+			want: want{
+				reasons:  []event.Reason{reasonEmptyConstraint},
+				messages: []string{`dependency crossplane/provider-gcp of package cool/config has no version constraint; treating it as "latest"`},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			rec := &recorder{}
+			r := NewReconciler(&fake.Manager{}, WithRecorder(rec))
+			r.warnEmptyConstraints(&v1beta1.Lock{Packages: tc.pkgs})
+			if diff := cmp.Diff(tc.want.reasons, rec.reasons); diff != "" {
+				t.Errorf("\n%s\nr.warnEmptyConstraints(...): -want reasons, +got reasons:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.messages, rec.messages); diff != "" {
+				t.Errorf("\n%s\nr.warnEmptyConstraints(...): -want messages, +got messages:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,180 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/crossplane/crossplane-runtime/pkg/resource/fake"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+	"github.com/crossplane/crossplane/internal/dag"
+	fakexpkg "github.com/crossplane/crossplane/internal/xpkg/fake"
+)
+
+func TestReconcileLockDeleted(t *testing.T) {
+	now := metav1.Now()
+	notFound := kerrors.NewNotFound(schema.GroupResource{}, "lock")
+	pkgs := []v1beta1.LockPackage{{
+		Source: "cool/config",
+		Dependencies: []v1beta1.Dependency{
+			{Package: "crossplane/provider-aws", Type: v1beta1.ProviderPackageType, Constraints: ">=v0.20.0"},
+		},
+	}}
+
+	type args struct {
+		// deleted is set as the Lock's deletion timestamp.
+		deleted *metav1.Time
+
+		// finalizers the Lock has when it is first read.
+		finalizers []string
+
+		// gone is the number of times the Lock may be read before it no
+		// longer exists. Zero means it always exists.
+		gone int
+
+		// Errors returned when the Lock or its status is updated.
+		update error
+		status error
+	}
+	type want struct {
+		r          reconcile.Result
+		err        error
+		created    int
+		finalizers []string
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"BeingDeletedWithPackages": {
+			reason: "We should remove the finalizer of a Lock that is being deleted even if it still has packages, and not install its dependencies.",
+			args: args{
+				deleted:    &now,
+				finalizers: []string{finalizer},
+			},
+			want: want{
+				finalizers: []string{},
+			},
+		},
+		"BeingDeletedWithoutFinalizer": {
+			reason: "We should do nothing for a Lock that is being deleted and has no finalizer.",
+			args: args{
+				deleted: &now,
+			},
+			want: want{},
+		},
+		"DeletedBeforeRemovingFinalizer": {
+			reason: "We should consider a Lock that was deleted before we could remove its finalizer finalized.",
+			args: args{
+				deleted:    &now,
+				finalizers: []string{finalizer},
+				update:     notFound,
+			},
+			want: want{},
+		},
+		"DeletedBeforeAddingFinalizer": {
+			reason: "We should stop reconciling a Lock that was deleted before we could add our finalizer.",
+			args: args{
+				update: notFound,
+			},
+			want: want{},
+		},
+		"DeletedBeforeCreate": {
+			reason: "We should not install dependencies of a Lock that was deleted while we were resolving them.",
+			args: args{
+				finalizers: []string{finalizer},
+				gone:       1,
+			},
+			want: want{},
+		},
+		"DeletedBeforeStatusUpdate": {
+			reason: "We should not return an error if a Lock was deleted before we could update its status.",
+			args: args{
+				finalizers: []string{finalizer},
+				status:     notFound,
+			},
+			want: want{created: 1},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			reads, created := 0, 0
+			var finalizers []string
+			mgr := &fake.Manager{
+				Client: &test.MockClient{
+					MockGet: test.NewMockGetFn(nil, func(o client.Object) error {
+						l, ok := o.(*v1beta1.Lock)
+						if !ok {
+							return notFound
+						}
+						reads++
+						if tc.args.gone > 0 && reads > tc.args.gone {
+							return notFound
+						}
+						l.SetName("lock")
+						l.SetDeletionTimestamp(tc.args.deleted)
+						l.SetFinalizers(tc.args.finalizers)
+						l.Packages = pkgs
+						return nil
+					}),
+					MockList: test.NewMockListFn(nil),
+					MockCreate: func(_ context.Context, _ client.Object, _ ...client.CreateOption) error {
+						created++
+						return nil
+					},
+					MockUpdate: func(_ context.Context, o client.Object, _ ...client.UpdateOption) error {
+						if tc.args.update == nil {
+							finalizers = o.GetFinalizers()
+						}
+						return tc.args.update
+					},
+					MockStatusUpdate: test.NewMockStatusUpdateFn(tc.args.status),
+				},
+			}
+			r := NewReconciler(mgr,
+				WithFetcher(&fakexpkg.MockFetcher{MockTags: fakexpkg.NewMockTagsFn([]string{"v0.20.0"}, nil)}),
+				WithNewDagFn(dag.NewMapDag),
+			)
+			got, err := r.Reconcile(context.Background(), reconcile.Request{})
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.r, got); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want result, +got result:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.created, created); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want created, +got created:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.finalizers, finalizers); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want finalizers, +got finalizers:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
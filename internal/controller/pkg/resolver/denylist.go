@@ -0,0 +1,141 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"context"
+	"sync"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+	"github.com/crossplane/crossplane/internal/xpkg"
+	"github.com/crossplane/crossplane/internal/xpkg/canonical"
+)
+
+const (
+	// DenylistKey is the key of the denylist ConfigMap's data that lists the
+	// versions of each package repository that must not be installed, e.g.
+	//
+	//   crossplane/provider-aws:
+	//   - v0.21.1
+	//   - ">=v0.22.0, <v0.22.3"
+	//
+	// An entry is either a version, or a semantic version constraint
+	// matching a range of versions.
+	DenylistKey = "denylist.yaml"
+
+	errGetDenylist            = "cannot get version denylist"
+	errParseDenylist          = "cannot parse version denylist"
+	errDenylistUnavailableFmt = "cannot consult version denylist for %s; no versions are excluded"
+)
+
+// WithVersionDenylist specifies the versions of dependency packages the
+// Reconciler must never install or upgrade to. Versions are not excluded if
+// the denylist can't be consulted, in which case a warning is recorded.
+func WithVersionDenylist(d xpkg.Denylist) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.denylist = d
+	}
+}
+
+// A ConfigMapDenylist denies the versions of package repositories that a
+// ConfigMap lists. Repositories are matched canonically, so a denylist may
+// list crossplane/provider-aws rather than
+// index.docker.io/crossplane/provider-aws.
+type ConfigMapDenylist struct {
+	client   client.Reader
+	name     types.NamespacedName
+	registry string
+}
+
+// NewConfigMapDenylist returns a Denylist that denies the versions the
+// supplied ConfigMap lists. Repositories the denylist lists without a registry
+// are assumed to be in the supplied default registry.
+func NewConfigMapDenylist(c client.Reader, namespace, name, registry string) *ConfigMapDenylist {
+	return &ConfigMapDenylist{client: c, name: types.NamespacedName{Namespace: namespace, Name: name}, registry: registry}
+}
+
+// DeniedVersions returns the versions the denylist lists for the supplied
+// reference's repository. No versions are denied if the denylist ConfigMap
+// doesn't exist.
+func (d *ConfigMapDenylist) DeniedVersions(ctx context.Context, ref name.Reference) ([]string, error) {
+	cm := &corev1.ConfigMap{}
+	if err := d.client.Get(ctx, d.name, cm); err != nil {
+		if kerrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrap(err, errGetDenylist)
+	}
+
+	denylist := map[string][]string{}
+	if err := yaml.Unmarshal([]byte(cm.Data[DenylistKey]), &denylist); err != nil {
+		return nil, errors.Wrap(err, errParseDenylist)
+	}
+	repo := canonical.Source(ref.Context().Name())
+	for r, versions := range denylist {
+		if canonical.Source(qualify(d.registry, r)) == repo {
+			return versions, nil
+		}
+	}
+	return nil, nil
+}
+
+// A warningDenylist records a warning rather than returning an error if its
+// Denylist can't be consulted, so that an unavailable denylist doesn't stop
+// dependencies from being installed. It may be consulted concurrently.
+type warningDenylist struct {
+	xpkg.Denylist
+
+	mu   *sync.Mutex
+	warn func(err error)
+}
+
+// DeniedVersions returns the denied versions of the supplied reference's
+// repository, or none if they can't be determined.
+func (d warningDenylist) DeniedVersions(ctx context.Context, ref name.Reference) ([]string, error) {
+	versions, err := d.Denylist.DeniedVersions(ctx, ref)
+	if err != nil {
+		d.mu.Lock()
+		d.warn(errors.Wrapf(err, errDenylistUnavailableFmt, ref.Context().Name()))
+		d.mu.Unlock()
+		return nil, nil
+	}
+	return versions, nil
+}
+
+// denylistFor returns the Reconciler's denylist, which records a warning
+// against the supplied Lock if it can't be consulted. It returns nil if the
+// Reconciler has no denylist.
+func (r *Reconciler) denylistFor(log logging.Logger, lock *v1beta1.Lock) xpkg.Denylist {
+	if r.denylist == nil {
+		return nil
+	}
+	return warningDenylist{Denylist: r.denylist, mu: &sync.Mutex{}, warn: func(err error) {
+		log.Debug("Cannot consult version denylist", "error", err)
+		r.record.Event(lock, event.Warning(reasonDenylist, err))
+	}}
+}
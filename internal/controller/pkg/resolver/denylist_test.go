@@ -0,0 +1,186 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-containerregistry/pkg/name"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/fake"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	v1 "github.com/crossplane/crossplane/apis/pkg/v1"
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+	"github.com/crossplane/crossplane/internal/dag"
+	"github.com/crossplane/crossplane/internal/xpkg"
+	fakexpkg "github.com/crossplane/crossplane/internal/xpkg/fake"
+)
+
+func TestConfigMapDenylist(t *testing.T) {
+	errBoom := errors.New("boom")
+	denylist := func(data string) test.MockGetFn {
+		return test.NewMockGetFn(nil, func(o client.Object) error {
+			o.(*corev1.ConfigMap).Data = map[string]string{DenylistKey: data}
+			return nil
+		})
+	}
+
+	type want struct {
+		versions []string
+		err      error
+	}
+
+	cases := map[string]struct {
+		reason string
+		get    test.MockGetFn
+		want   want
+	}{
+		"Listed": {
+			reason: "We should return the versions the denylist lists for a repository.",
+			get:    denylist("docker.io/crossplane/provider-aws: [v0.21.1, '>=v0.22.0, <v0.22.3']\ncrossplane/provider-gcp: [v0.18.0]"),
+			want:   want{versions: []string{"v0.21.1", ">=v0.22.0, <v0.22.3"}},
+		},
+		"NotListed": {
+			reason: "We should deny no versions of a repository the denylist doesn't list.",
+			get:    denylist("crossplane/provider-gcp: [v0.18.0]"),
+		},
+		"NoDenylist": {
+			reason: "We should deny no versions if the denylist doesn't exist.",
+			get:    test.NewMockGetFn(kerrors.NewNotFound(schema.GroupResource{}, "")),
+		},
+		"GetError": {
+			reason: "We should return any error encountered getting the denylist.",
+			get:    test.NewMockGetFn(errBoom),
+			want:   want{err: errors.Wrap(errBoom, errGetDenylist)},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			d := NewConfigMapDenylist(&test.MockClient{MockGet: tc.get}, "crossplane-system", "denylist", "")
+			versions, err := d.DeniedVersions(context.Background(), mustParseReference(t, "crossplane/provider-aws"))
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nDeniedVersions(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.versions, versions); diff != "" {
+				t.Errorf("\n%s\nDeniedVersions(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestReconcileVersionDenylist(t *testing.T) {
+	errBoom := errors.New("boom")
+	tags := []string{"v0.20.0", "v0.21.0", "v0.22.0"}
+	deny := func(versions []string, err error) xpkg.Denylist {
+		return xpkg.DenylistFn(func(_ context.Context, _ name.Reference) ([]string, error) { return versions, err })
+	}
+
+	type want struct {
+		source string
+		events []event.Reason
+		msgs   []string
+	}
+
+	cases := map[string]struct {
+		reason   string
+		denylist xpkg.Denylist
+		want     want
+	}{
+		"SkipDenied": {
+			reason:   "We should skip a denied version, and install the next version that satisfies the constraint.",
+			denylist: deny([]string{"v0.22.0"}, nil),
+			want: want{
+				source: "crossplane/provider-aws:v0.21.0",
+				events: []event.Reason{reasonInstallDependency},
+				msgs:   []string{"Installed automatically as a dependency of cool/config to satisfy constraint >=v0.21.0, selected version v0.21.0 from 2 available tags"},
+			},
+		},
+		"AllDenied": {
+			reason:   "We should report that no version is valid, noting the versions the denylist excluded, if every version that satisfies the constraint is denied.",
+			denylist: deny([]string{">=v0.21.0"}, nil),
+			want: want{
+				events: []event.Reason{reasonNoValidVersion},
+				msgs:   []string{"cannot find a valid version for package constraints: every version of dependency (crossplane/provider-aws) that satisfies its constraints is excluded by the version denylist: v0.21.0, v0.22.0"},
+			},
+		},
+		"DenylistUnavailable": {
+			reason:   "We should warn that the denylist can't be consulted, and install the version we would have without it.",
+			denylist: deny(nil, errBoom),
+			want: want{
+				source: "crossplane/provider-aws:v0.22.0",
+				events: []event.Reason{reasonDenylist, reasonInstallDependency},
+				msgs: []string{
+					"cannot consult version denylist for index.docker.io/crossplane/provider-aws; no versions are excluded: boom",
+					"Installed automatically as a dependency of cool/config to satisfy constraint >=v0.21.0, selected version v0.22.0 from 3 available tags",
+				},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			source := ""
+			mgr := &fake.Manager{
+				Client: &test.MockClient{
+					MockGet: test.NewMockGetFn(nil, func(o client.Object) error {
+						o.(*v1beta1.Lock).Packages = []v1beta1.LockPackage{{
+							Source:       "cool/config",
+							Dependencies: []v1beta1.Dependency{{Package: "crossplane/provider-aws", Type: v1beta1.ProviderPackageType, Constraints: ">=v0.21.0"}},
+						}}
+						return nil
+					}),
+					MockUpdate: test.NewMockUpdateFn(nil),
+					MockList:   test.NewMockListFn(nil),
+					MockCreate: func(_ context.Context, o client.Object, _ ...client.CreateOption) error {
+						source = o.(v1.Package).GetSource()
+						return nil
+					},
+					MockStatusUpdate: test.NewMockStatusUpdateFn(nil),
+				},
+			}
+			rec := &recorder{}
+			r := NewReconciler(mgr,
+				WithRecorder(rec),
+				WithVersionDenylist(tc.denylist),
+				WithFetcher(&fakexpkg.MockFetcher{MockTags: fakexpkg.NewMockTagsFn(tags, nil)}),
+				WithNewDagFn(dag.NewMapDag),
+			)
+			_, _ = r.Reconcile(context.Background(), reconcile.Request{})
+
+			if diff := cmp.Diff(tc.want.source, source); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want source, +got source:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.events, rec.reasons); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want events, +got events:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.msgs, rec.messages); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want messages, +got messages:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
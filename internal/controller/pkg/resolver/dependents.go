@@ -0,0 +1,76 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"sort"
+
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+	"github.com/crossplane/crossplane/internal/xpkg"
+	"github.com/crossplane/crossplane/internal/xpkg/canonical"
+)
+
+// WithDependentReporting specifies that the Reconciler should report whether
+// the dependencies of each package in the Lock were resolved in the Lock's
+// status, so that package revisions can wait for their dependencies to be
+// resolved before they report themselves healthy.
+func WithDependentReporting() ReconcilerOption {
+	return func(r *Reconciler) {
+		r.dependents = true
+	}
+}
+
+// resolvedDependents returns whether the dependencies of each package in the
+// supplied Lock that declares any were resolved. A package's dependencies are
+// unresolved if any is missing, i.e. planned to be installed, conflicting, or
+// was rejected as invalid. Rejected dependencies are described by the index of
+// the package that declared them in the Lock.
+func resolvedDependents(lock *v1beta1.Lock, plan []xpkg.PlannedInstall, cs []xpkg.Conflict, rejected map[int][]string) []v1beta1.DependentResolution {
+	unresolved := map[string]map[string]bool{}
+	add := func(parent, dependency string) {
+		k := canonical.Source(parent)
+		if unresolved[k] == nil {
+			unresolved[k] = map[string]bool{}
+		}
+		unresolved[k][dependency] = true
+	}
+	for _, p := range plan {
+		for _, rq := range p.Requirements {
+			add(rq.Parent, p.Dependency.Package)
+		}
+	}
+	for _, c := range cs {
+		for _, rq := range c.Requirements {
+			add(rq.Parent, c.Package)
+		}
+	}
+
+	var out []v1beta1.DependentResolution
+	for i, p := range lock.Packages {
+		if len(p.Dependencies) == 0 && len(rejected[i]) == 0 {
+			continue
+		}
+		d := v1beta1.DependentResolution{Package: p.Source, Version: p.Version}
+		for dep := range unresolved[canonical.Source(p.Source)] {
+			d.Unresolved = append(d.Unresolved, dep)
+		}
+		sort.Strings(d.Unresolved)
+		d.Unresolved = append(d.Unresolved, rejected[i]...)
+		out = append(out, d)
+	}
+	return out
+}
@@ -0,0 +1,88 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+	"github.com/crossplane/crossplane/internal/xpkg"
+)
+
+func TestResolvedDependents(t *testing.T) {
+	aws := v1beta1.Dependency{Package: "crossplane/provider-aws", Type: v1beta1.ProviderPackageType, Constraints: ">=v0.2.0"}
+	gcp := v1beta1.Dependency{Package: "crossplane/provider-gcp", Type: v1beta1.ProviderPackageType, Constraints: ">=v0.1.0"}
+	lock := &v1beta1.Lock{Packages: []v1beta1.LockPackage{
+		{Source: "cool/config-a", Version: "v1.0.0", Dependencies: []v1beta1.Dependency{aws, gcp}},
+		{Source: "cool/config-b", Version: "v1.0.0", Dependencies: []v1beta1.Dependency{aws}},
+		{Source: "crossplane/provider-aws", Version: "v0.1.0"},
+	}}
+
+	type args struct {
+		plan     []xpkg.PlannedInstall
+		cs       []xpkg.Conflict
+		rejected map[int][]string
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   []v1beta1.DependentResolution
+	}{
+		"Resolved": {
+			reason: "Packages whose dependencies are neither missing nor conflicting should be reported resolved. Packages without dependencies should not be reported.",
+			want: []v1beta1.DependentResolution{
+				{Package: "cool/config-a", Version: "v1.0.0"},
+				{Package: "cool/config-b", Version: "v1.0.0"},
+			},
+		},
+		"Unresolved": {
+			reason: "Packages should be reported unresolved for each missing, conflicting, or rejected dependency.",
+			args: args{
+				plan: []xpkg.PlannedInstall{{
+					Dependency:   gcp,
+					Requirements: []xpkg.Requirement{{Parent: "cool/config-a", Constraint: gcp.Constraints}},
+				}},
+				cs: []xpkg.Conflict{{
+					Reason:  xpkg.ConflictVersion,
+					Package: "crossplane/provider-aws",
+					Version: "v0.1.0",
+					Requirements: []xpkg.Requirement{
+						{Parent: "cool/config-a", Constraint: aws.Constraints},
+						{Parent: "index.docker.io/cool/config-b", Constraint: aws.Constraints},
+					},
+				}},
+				rejected: map[int][]string{0: {"invalid dependency 2"}},
+			},
+			want: []v1beta1.DependentResolution{
+				{Package: "cool/config-a", Version: "v1.0.0", Unresolved: []string{"crossplane/provider-aws", "crossplane/provider-gcp", "invalid dependency 2"}},
+				{Package: "cool/config-b", Version: "v1.0.0", Unresolved: []string{"crossplane/provider-aws"}},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := resolvedDependents(lock, tc.args.plan, tc.args.cs, tc.args.rejected)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nresolvedDependents(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"strings"
+
+	"github.com/crossplane/crossplane/internal/xpkg"
+)
+
+// WithStrictDigests specifies that the Reconciler should treat a package that
+// was installed by digest as conflicting with the packages that constrain its
+// semantic version, because it can't verify that the digest satisfies them. By
+// default such constraints are assumed to be satisfied, and reported as
+// unverified.
+func WithStrictDigests() ReconcilerOption {
+	return func(r *Reconciler) {
+		r.strictDigests = true
+	}
+}
+
+// unverified separates the supplied conflicts into those that we report as
+// conflicts and the unverifiable versions that we assume satisfy their
+// constraints. Unverifiable versions are only conflicts in strict mode.
+func (r *Reconciler) unverified(cs []xpkg.Conflict) ([]xpkg.Conflict, []xpkg.Conflict) {
+	if r.strictDigests {
+		return cs, nil
+	}
+	conflicts := make([]xpkg.Conflict, 0, len(cs))
+	var unverified []xpkg.Conflict
+	for _, c := range cs {
+		if c.Reason == xpkg.ConflictUnverifiable {
+			unverified = append(unverified, c)
+			continue
+		}
+		conflicts = append(conflicts, c)
+	}
+	return conflicts, unverified
+}
+
+// unverifiedMessage describes the supplied unverifiable versions.
+func unverifiedMessage(cs []xpkg.Conflict) string {
+	msgs := make([]string, len(cs))
+	for i, c := range cs {
+		msgs[i] = c.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
@@ -0,0 +1,177 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/fake"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+	"github.com/crossplane/crossplane/internal/dag"
+	fakexpkg "github.com/crossplane/crossplane/internal/xpkg/fake"
+)
+
+func TestReconcileDigestInstalled(t *testing.T) {
+	digest := "sha256:ecc25c121431dfc7058754427f97c034ecde26d4aafa0da16d6429d9f7d6cd9b"
+
+	// lock returns a Lock in which cool/config-base is installed at the
+	// supplied version, and cool/config-app constrains it.
+	lock := func(version, constraints string) []v1beta1.LockPackage {
+		return []v1beta1.LockPackage{
+			{Name: "config-base", Source: "cool/config-base", Type: v1beta1.ConfigurationPackageType, Version: version},
+			{Name: "config-app", Source: "cool/config-app", Type: v1beta1.ConfigurationPackageType, Version: "v1.0.0", Dependencies: []v1beta1.Dependency{
+				{Package: "cool/config-base", Type: v1beta1.ConfigurationPackageType, Constraints: constraints},
+			}},
+		}
+	}
+
+	type args struct {
+		strict bool
+		pkgs   []v1beta1.LockPackage
+	}
+	type want struct {
+		events  []event.Reason
+		status  corev1.ConditionStatus
+		reason  xpv1.ConditionReason
+		message string
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"Tag": {
+			reason: "We should resolve a dependency installed at a tag that satisfies its constraints.",
+			args:   args{pkgs: lock("v1.0.0", ">=v1.0.0")},
+			want: want{
+				status: corev1.ConditionTrue,
+				reason: v1beta1.ReasonResolved,
+			},
+		},
+		"PinnedDigest": {
+			reason: "We should resolve a dependency installed at the digest it is pinned to.",
+			args:   args{pkgs: lock(digest, digest)},
+			want: want{
+				status: corev1.ConditionTrue,
+				reason: v1beta1.ReasonResolved,
+			},
+		},
+		"SatisfiedLooking": {
+			reason: "We should report that we can't verify a semantic version constraint on a dependency installed by digest, rather than passing silently.",
+			args:   args{pkgs: lock(digest, ">=v1.0.0")},
+			want: want{
+				events:  []event.Reason{reasonUnverifiedDigest},
+				status:  corev1.ConditionTrue,
+				reason:  v1beta1.ReasonUnverified,
+				message: `cannot verify that package cool/config-base installed at digest ` + digest + ` satisfies ">=v1.0.0" required by cool/config-app`,
+			},
+		},
+		"Mismatched": {
+			reason: "We should report that we can't verify a semantic version constraint on a dependency installed by digest, rather than failing.",
+			args:   args{pkgs: lock(digest, "<v0.0.1")},
+			want: want{
+				events:  []event.Reason{reasonUnverifiedDigest},
+				status:  corev1.ConditionTrue,
+				reason:  v1beta1.ReasonUnverified,
+				message: `cannot verify that package cool/config-base installed at digest ` + digest + ` satisfies "<v0.0.1" required by cool/config-app`,
+			},
+		},
+		"StrictSatisfiedLooking": {
+			reason: "We should treat a semantic version constraint on a dependency installed by digest as a conflict in strict mode.",
+			args:   args{strict: true, pkgs: lock(digest, ">=v1.0.0")},
+			want: want{
+				events:  []event.Reason{reasonUnverifiedDigest},
+				status:  corev1.ConditionFalse,
+				reason:  v1beta1.ReasonVersionConflict,
+				message: `cannot verify that package cool/config-base installed at digest ` + digest + ` satisfies ">=v1.0.0" required by cool/config-app`,
+			},
+		},
+		"StrictMismatched": {
+			reason: "We should treat a semantic version constraint on a dependency installed by digest as a conflict in strict mode.",
+			args:   args{strict: true, pkgs: lock(digest, "<v0.0.1")},
+			want: want{
+				events:  []event.Reason{reasonUnverifiedDigest},
+				status:  corev1.ConditionFalse,
+				reason:  v1beta1.ReasonVersionConflict,
+				message: `cannot verify that package cool/config-base installed at digest ` + digest + ` satisfies "<v0.0.1" required by cool/config-app`,
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var got *v1beta1.Lock
+			mgr := &fake.Manager{
+				Client: &test.MockClient{
+					MockGet: func(_ context.Context, key client.ObjectKey, o client.Object) error {
+						if l, ok := o.(*v1beta1.Lock); ok {
+							l.Packages = tc.args.pkgs
+							return nil
+						}
+						return kerrors.NewNotFound(schema.GroupResource{}, key.Name)
+					},
+					MockList:   test.NewMockListFn(nil),
+					MockUpdate: test.NewMockUpdateFn(nil),
+					MockStatusUpdate: test.NewMockStatusUpdateFn(nil, func(o client.Object) error {
+						got = o.(*v1beta1.Lock)
+						return nil
+					}),
+				},
+			}
+			rec := &recorder{}
+			opts := []ReconcilerOption{
+				WithRecorder(rec),
+				WithFetcher(&fakexpkg.MockFetcher{MockTags: fakexpkg.NewMockTagsFn([]string{"v1.0.0"}, nil)}),
+				WithNewDagFn(dag.NewMapDag),
+			}
+			if tc.args.strict {
+				opts = append(opts, WithStrictDigests())
+			}
+			r := NewReconciler(mgr, opts...)
+
+			if _, err := r.Reconcile(context.Background(), reconcile.Request{}); err != nil {
+				t.Fatalf("\n%s\nr.Reconcile(...): %s", tc.reason, err)
+			}
+			if diff := cmp.Diff(tc.want.events, rec.reasons); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want events, +got events:\n%s", tc.reason, diff)
+			}
+			c := got.GetCondition(v1beta1.TypeDependenciesResolved)
+			if diff := cmp.Diff(tc.want.status, c.Status); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want condition status, +got condition status:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.reason, c.Reason); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want condition reason, +got condition reason:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.message, c.Message); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want condition message, +got condition message:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+
+	v1 "github.com/crossplane/crossplane/apis/pkg/v1"
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+	"github.com/crossplane/crossplane/internal/xpkg"
+)
+
+const (
+	msgDryRunPrefix  = "(dry run) "
+	msgPlannedFmt    = "Would install package %s from %s: %s"
+	msgPlannedStatus = msgDryRunPrefix + "would be installed at the selected version"
+)
+
+// WithDryRun specifies whether the Reconciler should only report what it
+// would do. In dry run mode the Reconciler plans and reports the packages it
+// would create, upgrade, or collect as usual, but never writes a package. The
+// Lock's finalizer and status are still written. Nothing a dry run reports is
+// persisted anywhere but the Lock's status, so the first pass after dry run
+// mode is disabled simply applies the plan.
+func WithDryRun(dryRun bool) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.dryRun = dryRun
+	}
+}
+
+// planned reports the supplied planned install, which a dry run never
+// creates, as if it had been created. It counts toward the supplied budget, so
+// that a dry run reports the auto-install limit as it would be reached.
+func (r *Reconciler) planned(log logging.Logger, lock *v1beta1.Lock, p xpkg.PlannedInstall, pack v1.Package, b *installBudget, res *resolutionResult) (xpv1.Condition, RequeueReason, error) {
+	b.used++
+	res.decide(p.Dependency.Package, outcomePlanned, pack.GetName())
+	msg := fmt.Sprintf(msgPlannedFmt, pack.GetName(), pack.GetSource(), installedMessage(p))
+	log.Debug(msg, "dependency", p.Dependency.Package)
+	r.record.Event(lock, event.Normal(reasonInstallDependency, msg))
+	return v1beta1.MissingDependency(), "", nil
+}
+
+// A dryRunClient never writes packages. Writes to any other kind of object,
+// including the Lock, are passed through.
+type dryRunClient struct {
+	client.Client
+}
+
+// Create the supplied object, unless it is a package.
+func (c dryRunClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	if _, ok := obj.(v1.Package); ok {
+		return nil
+	}
+	return c.Client.Create(ctx, obj, opts...)
+}
+
+// Update the supplied object, unless it is a package.
+func (c dryRunClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	if _, ok := obj.(v1.Package); ok {
+		return nil
+	}
+	return c.Client.Update(ctx, obj, opts...)
+}
+
+// Patch the supplied object, unless it is a package.
+func (c dryRunClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	if _, ok := obj.(v1.Package); ok {
+		return nil
+	}
+	return c.Client.Patch(ctx, obj, patch, opts...)
+}
+
+// Delete the supplied object, unless it is a package.
+func (c dryRunClient) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	if _, ok := obj.(v1.Package); ok {
+		return nil
+	}
+	return c.Client.Delete(ctx, obj, opts...)
+}
+
+// A dryRunRecorder prefixes every event it records, so that events describing
+// what a dry run would do can't be mistaken for what was done.
+type dryRunRecorder struct {
+	wrapped event.Recorder
+}
+
+// Event records the supplied event, with its message prefixed.
+func (r dryRunRecorder) Event(obj runtime.Object, e event.Event) {
+	e.Message = msgDryRunPrefix + e.Message
+	r.wrapped.Event(obj, e)
+}
+
+// WithAnnotations returns a new recorder that includes the supplied
+// annotations with all recorded events.
+func (r dryRunRecorder) WithAnnotations(keysAndValues ...string) event.Recorder {
+	return dryRunRecorder{wrapped: r.wrapped.WithAnnotations(keysAndValues...)}
+}
@@ -0,0 +1,162 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/fake"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	v1 "github.com/crossplane/crossplane/apis/pkg/v1"
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+	"github.com/crossplane/crossplane/internal/dag"
+	fakexpkg "github.com/crossplane/crossplane/internal/xpkg/fake"
+)
+
+func TestReconcileDryRun(t *testing.T) {
+	// The Lock is missing provider-aws, and the auto-installed provider-gcp
+	// is no longer required by any package in it.
+	deps := []v1beta1.Dependency{{Package: "crossplane/provider-aws", Type: v1beta1.ProviderPackageType, Constraints: ">=v0.20.0"}}
+	orphan := v1.Provider{ObjectMeta: metav1.ObjectMeta{
+		Name:   "crossplane-provider-gcp",
+		Labels: map[string]string{LabelAutoInstalled: "true", LabelLock: "lock"},
+	}}
+	orphan.SetSource("crossplane/provider-gcp:v0.20.0")
+
+	type want struct {
+		writes   []string
+		reason   xpv1.ConditionReason
+		message  string
+		prefixed bool
+	}
+
+	cases := map[string]struct {
+		reason string
+		dryRun bool
+		want   want
+	}{
+		"DryRun": {
+			reason: "We should report the packages we would create and collect without writing any package.",
+			dryRun: true,
+			want: want{
+				reason:   v1beta1.ReasonMissingDependency,
+				message:  msgPlannedStatus,
+				prefixed: true,
+			},
+		},
+		"Disabled": {
+			reason: "We should apply the plan a dry run reported once dry run mode is disabled.",
+			want: want{
+				writes: []string{"delete/crossplane-provider-gcp", "create/crossplane-provider-aws"},
+				reason: v1beta1.ReasonMissingDependency,
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var writes []string
+			var status *v1beta1.Lock
+			rec := &recorder{}
+			mgr := &fake.Manager{
+				Client: &test.MockClient{
+					MockGet: test.NewMockGetFn(nil, func(o client.Object) error {
+						o.SetName("lock")
+						o.(*v1beta1.Lock).Packages = []v1beta1.LockPackage{{Source: "cool/config", Dependencies: deps}}
+						return nil
+					}),
+					MockList: test.NewMockListFn(nil, func(o client.ObjectList) error {
+						if l, ok := o.(*v1.ProviderList); ok {
+							l.Items = []v1.Provider{*orphan.DeepCopy()}
+						}
+						return nil
+					}),
+					MockCreate: func(_ context.Context, o client.Object, _ ...client.CreateOption) error {
+						writes = append(writes, "create/"+o.GetName())
+						return nil
+					},
+					MockUpdate: func(_ context.Context, o client.Object, _ ...client.UpdateOption) error {
+						if _, ok := o.(v1.Package); ok {
+							writes = append(writes, "update/"+o.GetName())
+						}
+						return nil
+					},
+					MockPatch: func(_ context.Context, o client.Object, _ client.Patch, _ ...client.PatchOption) error {
+						if _, ok := o.(v1.Package); ok {
+							writes = append(writes, "patch/"+o.GetName())
+						}
+						return nil
+					},
+					MockDelete: func(_ context.Context, o client.Object, _ ...client.DeleteOption) error {
+						writes = append(writes, "delete/"+o.GetName())
+						return nil
+					},
+					MockStatusUpdate: test.NewMockStatusUpdateFn(nil, func(o client.Object) error {
+						status = o.(*v1beta1.Lock)
+						return nil
+					}),
+				},
+			}
+			r := NewReconciler(mgr,
+				WithDryRun(tc.dryRun),
+				WithDependencyOwnership(DependencyGCDelete),
+				WithOrphanGracePeriod(0),
+				WithRecorder(rec),
+				WithFetcher(&fakexpkg.MockFetcher{MockTags: fakexpkg.NewMockTagsFn([]string{"v0.20.0"}, nil)}),
+				WithNewDagFn(dag.NewMapDag),
+			)
+			if _, err := r.Reconcile(context.Background(), reconcile.Request{}); err != nil {
+				t.Fatalf("\n%s\nr.Reconcile(...): %s", tc.reason, err)
+			}
+
+			if diff := cmp.Diff(tc.want.writes, writes); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want package writes, +got package writes:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.reason, status.GetCondition(v1beta1.TypeDependenciesResolved).Reason); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want condition reason, +got condition reason:\n%s", tc.reason, diff)
+			}
+			want := []v1beta1.DependencyResolution{{
+				Package:     "crossplane/provider-aws",
+				State:       v1beta1.DependencyMissing,
+				Constraints: []v1beta1.DependentConstraint{{Dependent: "cool/config", Constraints: ">=v0.20.0"}},
+				Message:     tc.want.message,
+				Version:     "v0.20.0",
+			}}
+			if diff := cmp.Diff(want, status.Status.DependencyResolution, cmpopts.IgnoreFields(v1beta1.DependencyResolution{}, "LastAttemptTime")); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want dependency resolution, +got dependency resolution:\n%s", tc.reason, diff)
+			}
+			if len(rec.messages) == 0 {
+				t.Errorf("\n%s\nr.Reconcile(...): expected events describing the plan", tc.reason)
+			}
+			for _, msg := range rec.messages {
+				if got := strings.HasPrefix(msg, msgDryRunPrefix); got != tc.want.prefixed {
+					t.Errorf("\n%s\nr.Reconcile(...): event %q prefixed with %q: want %t, got %t", tc.reason, msg, msgDryRunPrefix, tc.want.prefixed, got)
+				}
+			}
+		})
+	}
+}
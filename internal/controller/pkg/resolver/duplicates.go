@@ -0,0 +1,169 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+
+	v1 "github.com/crossplane/crossplane/apis/pkg/v1"
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+)
+
+const (
+	errGetDuplicateRevision = "cannot get package revision of duplicate Lock package"
+	errRepairLock           = "cannot remove stale duplicate packages from Lock"
+
+	msgDuplicatesFmt = "lock contains duplicate packages: %s"
+	msgRepairedFmt   = "removed stale duplicate packages from lock: %s"
+)
+
+// WithLockRepair specifies that the Reconciler should remove stale duplicate
+// packages from the Lock. A package is a duplicate if the Lock contains more
+// than one package from the same repository. The package whose revision is
+// active is kept. The Reconciler otherwise never modifies the Lock; it only
+// reports duplicates.
+func WithLockRepair() ReconcilerOption {
+	return func(r *Reconciler) {
+		r.repair = true
+	}
+}
+
+// A duplicate is a set of packages in the Lock that are from the same
+// repository.
+type duplicate struct {
+	// Packages are the indexes of the duplicate packages in the Lock, in
+	// the order they appear.
+	Packages []int
+
+	// Keep is the index of the package whose revision is active, or -1 if
+	// no package's revision is active.
+	Keep int
+}
+
+// duplicates returns the sets of packages in the supplied Lock that are from
+// the same repository, in the order they first appear.
+func (r *Reconciler) duplicates(ctx context.Context, lock *v1beta1.Lock) ([]duplicate, error) {
+	order := []string{}
+	byRepo := map[string][]int{}
+	for i, p := range lock.Packages {
+		repo := r.sourceRepository(p.Source)
+		if _, ok := byRepo[repo]; !ok {
+			order = append(order, repo)
+		}
+		byRepo[repo] = append(byRepo[repo], i)
+	}
+
+	out := []duplicate{}
+	for _, repo := range order {
+		pkgs := byRepo[repo]
+		if len(pkgs) < 2 {
+			continue
+		}
+		d := duplicate{Packages: pkgs, Keep: -1}
+		for _, i := range pkgs {
+			active, err := r.active(ctx, lock.Packages[i])
+			if err != nil {
+				return nil, err
+			}
+			if active {
+				d.Keep = i
+				break
+			}
+		}
+		out = append(out, d)
+	}
+	return out, nil
+}
+
+// active returns true if the revision of the supplied package exists and is
+// active.
+func (r *Reconciler) active(ctx context.Context, p v1beta1.LockPackage) (bool, error) {
+	rev, ok := newPackageRevision(p.Type)
+	if !ok {
+		return false, nil
+	}
+	if err := r.client.Get(ctx, types.NamespacedName{Name: p.Name}, rev); err != nil {
+		if kerrors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, errors.Wrap(err, errGetDuplicateRevision)
+	}
+	return rev.GetDesiredState() == v1.PackageRevisionActive, nil
+}
+
+// repairable returns true if we know which package of each of the supplied
+// duplicates to keep.
+func repairable(dups []duplicate) bool {
+	for _, d := range dups {
+		if d.Keep < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// repairLock removes all but the package to keep of each of the supplied
+// duplicates from the supplied Lock. It returns a description of the packages
+// it removed.
+func (r *Reconciler) repairLock(ctx context.Context, lock *v1beta1.Lock, dups []duplicate) (string, error) {
+	stale := map[int]bool{}
+	for _, d := range dups {
+		for _, i := range d.Packages {
+			if i != d.Keep {
+				stale[i] = true
+			}
+		}
+	}
+
+	removed := make([]string, 0, len(stale))
+	kept := make([]v1beta1.LockPackage, 0, len(lock.Packages)-len(stale))
+	for i, p := range lock.Packages {
+		if stale[i] {
+			removed = append(removed, describeLockPackage(p))
+			continue
+		}
+		kept = append(kept, p)
+	}
+	lock.Packages = kept
+	return strings.Join(removed, ", "), errors.Wrap(r.client.Update(ctx, lock), errRepairLock)
+}
+
+// describeDuplicates returns a description of the supplied duplicates, e.g.
+// crossplane/provider-aws (revision provider-aws-a at v0.20.0, revision
+// provider-aws-b at v0.21.0).
+func describeDuplicates(lock *v1beta1.Lock, dups []duplicate) string {
+	ds := make([]string, len(dups))
+	for i, d := range dups {
+		revs := make([]string, len(d.Packages))
+		for j, p := range d.Packages {
+			revs[j] = describeLockPackage(lock.Packages[p])
+		}
+		ds[i] = fmt.Sprintf("%s (%s)", lock.Packages[d.Packages[0]].Source, strings.Join(revs, ", "))
+	}
+	return strings.Join(ds, "; ")
+}
+
+func describeLockPackage(p v1beta1.LockPackage) string {
+	return fmt.Sprintf("revision %s at %s", p.Name, p.Version)
+}
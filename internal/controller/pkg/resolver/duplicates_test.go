@@ -0,0 +1,225 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/fake"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	v1 "github.com/crossplane/crossplane/apis/pkg/v1"
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+	"github.com/crossplane/crossplane/internal/dag"
+	fakexpkg "github.com/crossplane/crossplane/internal/xpkg/fake"
+)
+
+func TestReconcileDuplicates(t *testing.T) {
+	errBoom := errors.New("boom")
+
+	config := v1beta1.LockPackage{
+		Name:    "cool-config-a1b2c3",
+		Source:  "cool/config",
+		Type:    v1beta1.ConfigurationPackageType,
+		Version: "v1.0.0",
+		Dependencies: []v1beta1.Dependency{
+			{Package: "crossplane/provider-aws", Type: v1beta1.ProviderPackageType, Constraints: ">=v0.20.0"},
+		},
+	}
+	stale := v1beta1.LockPackage{Name: "provider-aws-stale", Source: "crossplane/provider-aws", Type: v1beta1.ProviderPackageType, Version: "v0.20.0"}
+	current := v1beta1.LockPackage{Name: "provider-aws-current", Source: "docker.io/crossplane/provider-aws", Type: v1beta1.ProviderPackageType, Version: "v0.21.0"}
+	msg := "lock contains duplicate packages: crossplane/provider-aws (revision provider-aws-stale at v0.20.0, revision provider-aws-current at v0.21.0)"
+
+	// revisions returns a MockGetFn that gets the Lock, and the supplied
+	// revisions, which are active if true.
+	revisions := func(revs map[string]bool) test.MockGetFn {
+		return func(_ context.Context, key client.ObjectKey, o client.Object) error {
+			switch o := o.(type) {
+			case *v1beta1.Lock:
+				o.Packages = []v1beta1.LockPackage{config, stale, current}
+			case *v1.ProviderRevision:
+				active, ok := revs[key.Name]
+				if !ok {
+					return kerrors.NewNotFound(schema.GroupResource{}, key.Name)
+				}
+				o.SetDesiredState(v1.PackageRevisionInactive)
+				if active {
+					o.SetDesiredState(v1.PackageRevisionActive)
+				}
+			default:
+				return kerrors.NewNotFound(schema.GroupResource{}, key.Name)
+			}
+			return nil
+		}
+	}
+
+	type args struct {
+		repair bool
+		get    test.MockGetFn
+		update error
+	}
+	type want struct {
+		r        reconcile.Result
+		packages []v1beta1.LockPackage
+		reason   xpv1.ConditionReason
+		message  string
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"ReportOnly": {
+			reason: "We should report duplicate packages, but not remove them, if Lock repair is disabled.",
+			args: args{
+				get: revisions(map[string]bool{"provider-aws-stale": false, "provider-aws-current": true}),
+			},
+			want: want{
+				r:       reconcile.Result{RequeueAfter: longWait},
+				reason:  v1beta1.ReasonDuplicatePackages,
+				message: msg,
+			},
+		},
+		"Repair": {
+			reason: "We should remove the duplicate package whose revision is not active, then resolve dependencies using the package whose revision is.",
+			args: args{
+				repair: true,
+				get:    revisions(map[string]bool{"provider-aws-stale": false, "provider-aws-current": true}),
+			},
+			want: want{
+				packages: []v1beta1.LockPackage{config, current},
+				reason:   v1beta1.ReasonResolved,
+			},
+		},
+		"RepairMissingRevision": {
+			reason: "We should remove a duplicate package whose revision no longer exists.",
+			args: args{
+				repair: true,
+				get:    revisions(map[string]bool{"provider-aws-current": true}),
+			},
+			want: want{
+				packages: []v1beta1.LockPackage{config, current},
+				reason:   v1beta1.ReasonResolved,
+			},
+		},
+		"RepairAmbiguous": {
+			reason: "We should only report duplicate packages if we can't tell which is stale.",
+			args: args{
+				repair: true,
+				get:    revisions(map[string]bool{"provider-aws-stale": false, "provider-aws-current": false}),
+			},
+			want: want{
+				r:       reconcile.Result{RequeueAfter: longWait},
+				reason:  v1beta1.ReasonDuplicatePackages,
+				message: msg,
+			},
+		},
+		"RepairError": {
+			reason: "We should requeue if we can't remove stale duplicate packages from the Lock.",
+			args: args{
+				repair: true,
+				get:    revisions(map[string]bool{"provider-aws-stale": false, "provider-aws-current": true}),
+				update: errBoom,
+			},
+			want: want{
+				r:        reconcile.Result{RequeueAfter: shortWait},
+				packages: []v1beta1.LockPackage{config, current},
+			},
+		},
+		"GetRevisionError": {
+			reason: "We should requeue if we can't get the revision of a duplicate package.",
+			args: args{
+				repair: true,
+				get: func(_ context.Context, _ client.ObjectKey, o client.Object) error {
+					if l, ok := o.(*v1beta1.Lock); ok {
+						l.Packages = []v1beta1.LockPackage{config, stale, current}
+						return nil
+					}
+					return errBoom
+				},
+			},
+			want: want{
+				r: reconcile.Result{RequeueAfter: shortWait},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var updated []v1beta1.LockPackage
+			var status *v1beta1.Lock
+			mgr := &fake.Manager{
+				Client: &test.MockClient{
+					MockGet:  tc.args.get,
+					MockList: test.NewMockListFn(nil),
+					MockUpdate: func(_ context.Context, o client.Object, _ ...client.UpdateOption) error {
+						// The finalizer is added before any repair.
+						if l, ok := o.(*v1beta1.Lock); ok && len(l.Packages) != 3 {
+							updated = l.Packages
+							return tc.args.update
+						}
+						return nil
+					},
+					MockStatusUpdate: test.NewMockStatusUpdateFn(nil, func(o client.Object) error {
+						status = o.(*v1beta1.Lock)
+						return nil
+					}),
+				},
+			}
+			opts := []ReconcilerOption{
+				WithRecorder(&recorder{}),
+				WithFetcher(&fakexpkg.MockFetcher{MockTags: fakexpkg.NewMockTagsFn([]string{"v0.21.0"}, nil)}),
+				WithNewDagFn(dag.NewMapDag),
+			}
+			if tc.args.repair {
+				opts = append(opts, WithLockRepair())
+			}
+			r := NewReconciler(mgr, opts...)
+			got, err := r.Reconcile(context.Background(), reconcile.Request{})
+			if err != nil {
+				t.Fatalf("\n%s\nr.Reconcile(...): %s", tc.reason, err)
+			}
+
+			if diff := cmp.Diff(tc.want.r, got); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want, +got:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.packages, updated); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want updated packages, +got updated packages:\n%s", tc.reason, diff)
+			}
+			if tc.want.reason == "" {
+				return
+			}
+			c := status.GetCondition(v1beta1.TypeDependenciesResolved)
+			if diff := cmp.Diff(tc.want.reason, c.Reason); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want condition reason, +got condition reason:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.message, c.Message); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want condition message, +got condition message:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
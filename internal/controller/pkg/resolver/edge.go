@@ -0,0 +1,111 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+	"github.com/crossplane/crossplane/internal/xpkg"
+	"github.com/crossplane/crossplane/internal/xpkg/canonical"
+)
+
+const (
+	errCheckEdges          = "cannot check dependency edges"
+	errUnreportedEdgeFmt   = "%s, but no conflict was reported"
+	msgSkipSatisfied       = "Skipped dependency: already satisfied"
+	msgUnverifiableEdge    = "Dependency edge cannot be verified"
+	msgViolatedEdge        = "Dependency edge is violated"
+	msgUnreportedViolation = "Dependency edge is violated but no conflict was reported"
+	msgViolatedOptional    = "Optional dependency edge is violated"
+)
+
+// checkEdges checks whether the installed package at the end of each edge of
+// the supplied Lock's dependency graph satisfies the constraint of the
+// package the edge is from, and accounts for each edge in the supplied
+// result. Satisfied edges are only logged at debug level. Conflicts are
+// detected per installed package, so each violated or unverifiable edge
+// should be accounted for by one of the supplied conflicts. One that isn't
+// would otherwise be considered satisfied without anything surfacing the
+// mismatch, so we record a warning event for it. Optional dependencies never
+// conflict, so we record a warning event for each violated optional edge
+// instead.
+func (r *Reconciler) checkEdges(log logging.Logger, lock *v1beta1.Lock, cs []xpkg.Conflict, res *resolutionResult) {
+	d := r.newDag()
+	if _, err := d.Init(v1beta1.ToNodes(lock.Packages...)); err != nil {
+		log.Debug(errCheckEdges, "error", err)
+		return
+	}
+	report, err := xpkg.CheckEdges(d)
+	if err != nil {
+		log.Debug(errCheckEdges, "error", err)
+		return
+	}
+	res.edges(report)
+
+	for _, c := range report.Satisfied {
+		log.Debug(msgSkipSatisfied, "dependent", c.Dependent, "dependency", c.Dependency, "version", c.Version, "constraints", c.Constraint)
+	}
+	for _, c := range report.Unverifiable {
+		log.Debug(msgUnverifiableEdge, "dependent", c.Dependent, "dependency", c.Dependency, "version", c.Version, "constraints", c.Constraint)
+	}
+	required := make([]xpkg.EdgeCheck, 0, len(report.Violated)+len(report.Unverifiable))
+	for _, c := range report.Violated {
+		if c.Optional {
+			log.Debug(msgViolatedOptional, "dependent", c.Dependent, "dependency", c.Dependency, "version", c.Version, "constraints", c.Constraint)
+			r.record.Event(lock, event.Warning(reasonOptionalDependency, errors.New(c.String())))
+			continue
+		}
+		log.Debug(msgViolatedEdge, "dependent", c.Dependent, "dependency", c.Dependency, "version", c.Version, "constraints", c.Constraint)
+		required = append(required, c)
+	}
+	for _, c := range report.Unverifiable {
+		if !c.Optional {
+			required = append(required, c)
+		}
+	}
+	for _, c := range unreported(required, cs) {
+		log.Debug(msgUnreportedViolation, "dependent", c.Dependent, "dependency", c.Dependency, "version", c.Version, "constraints", c.Constraint)
+		r.record.Event(lock, event.Warning(reasonUnreportedViolation, errors.Errorf(errUnreportedEdgeFmt, c)))
+	}
+}
+
+// unreported returns the supplied edge checks that none of the supplied
+// conflicts account for, i.e. whose dependent isn't among the requirements of
+// a conflict of their dependency.
+func unreported(checks []xpkg.EdgeCheck, cs []xpkg.Conflict) []xpkg.EdgeCheck {
+	type edge struct {
+		dependent  string
+		dependency string
+	}
+	reported := map[edge]bool{}
+	for _, c := range cs {
+		for _, rq := range c.Requirements {
+			reported[edge{dependent: canonical.Source(rq.Parent), dependency: canonical.Source(c.Package)}] = true
+		}
+	}
+
+	var out []xpkg.EdgeCheck
+	for _, c := range checks {
+		if !reported[edge{dependent: canonical.Source(c.Dependent), dependency: canonical.Source(c.Dependency)}] {
+			out = append(out, c)
+		}
+	}
+	return out
+}
@@ -0,0 +1,130 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/fake"
+
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+	"github.com/crossplane/crossplane/internal/dag"
+	"github.com/crossplane/crossplane/internal/xpkg"
+)
+
+func TestCheckEdges(t *testing.T) {
+	digest := "sha256:ecc25c121431dfc7058754427f97c034ecde26d4aafa0da16d258090e0443904"
+	config := func(source, constraints string) v1beta1.LockPackage {
+		return v1beta1.LockPackage{Source: source, Type: v1beta1.ConfigurationPackageType, Version: "v1.0.0", Dependencies: []v1beta1.Dependency{
+			{Package: "crossplane/provider-aws", Type: v1beta1.ProviderPackageType, Constraints: constraints},
+		}}
+	}
+	aws := func(version string) v1beta1.LockPackage {
+		return v1beta1.LockPackage{Source: "crossplane/provider-aws", Type: v1beta1.ProviderPackageType, Version: version}
+	}
+	violated := xpkg.Conflict{
+		Reason:       xpkg.ConflictVersion,
+		Package:      "crossplane/provider-aws",
+		Version:      "v0.1.0",
+		Requirements: []xpkg.Requirement{{Parent: "cool/config-b", Constraint: ">=v0.2.0"}},
+	}
+
+	type want struct {
+		edges    map[xpkg.EdgeState]int
+		messages []string
+	}
+
+	cases := map[string]struct {
+		reason string
+		pkgs   []v1beta1.LockPackage
+		cs     []xpkg.Conflict
+		want   want
+	}{
+		"Satisfied": {
+			reason: "We should count satisfied edges without recording any events.",
+			pkgs:   []v1beta1.LockPackage{config("cool/config-a", ">=v0.1.0"), aws("v0.1.0")},
+			want: want{
+				edges: map[xpkg.EdgeState]int{xpkg.EdgeSatisfied: 1, xpkg.EdgeViolated: 0, xpkg.EdgeUnverifiable: 0},
+			},
+		},
+		"ViolatedAndReported": {
+			reason: "We should count a violated edge without recording an event if a conflict accounts for it.",
+			pkgs:   []v1beta1.LockPackage{config("cool/config-a", ">=v0.1.0"), config("cool/config-b", ">=v0.2.0"), aws("v0.1.0")},
+			cs:     []xpkg.Conflict{violated},
+			want: want{
+				edges: map[xpkg.EdgeState]int{xpkg.EdgeSatisfied: 1, xpkg.EdgeViolated: 1, xpkg.EdgeUnverifiable: 0},
+			},
+		},
+		"ViolatedAndUnreported": {
+			reason: "We should record an event for each violated edge that no conflict accounts for.",
+			pkgs:   []v1beta1.LockPackage{config("cool/config-a", ">=v0.2.0"), config("cool/config-b", ">=v0.2.0"), aws("v0.1.0")},
+			cs:     []xpkg.Conflict{violated},
+			want: want{
+				edges:    map[xpkg.EdgeState]int{xpkg.EdgeSatisfied: 0, xpkg.EdgeViolated: 2, xpkg.EdgeUnverifiable: 0},
+				messages: []string{`package cool/config-a requires crossplane/provider-aws ">=v0.2.0" but version v0.1.0 is installed, but no conflict was reported`},
+			},
+		},
+		"OptionalViolated": {
+			reason: "We should record an event for a violated optional edge, whether or not a conflict accounts for it.",
+			pkgs: []v1beta1.LockPackage{
+				{Source: "cool/config-a", Type: v1beta1.ConfigurationPackageType, Version: "v1.0.0", Dependencies: []v1beta1.Dependency{
+					{Package: "crossplane/provider-aws", Type: v1beta1.ProviderPackageType, Constraints: ">=v0.2.0", Optional: true},
+				}},
+				aws("v0.1.0"),
+			},
+			want: want{
+				edges:    map[xpkg.EdgeState]int{xpkg.EdgeSatisfied: 0, xpkg.EdgeViolated: 1, xpkg.EdgeUnverifiable: 0},
+				messages: []string{`package cool/config-a requires crossplane/provider-aws ">=v0.2.0" but version v0.1.0 is installed`},
+			},
+		},
+		"Unverifiable": {
+			reason: "We should count an edge to a package installed by digest as unverifiable, and record an event if no conflict accounts for it.",
+			pkgs:   []v1beta1.LockPackage{config("cool/config-a", ">=v0.1.0"), aws(digest)},
+			want: want{
+				edges:    map[xpkg.EdgeState]int{xpkg.EdgeSatisfied: 0, xpkg.EdgeViolated: 0, xpkg.EdgeUnverifiable: 1},
+				messages: []string{`package cool/config-a requires crossplane/provider-aws ">=v0.1.0" but it is installed at digest ` + digest + `, but no conflict was reported`},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			rec := &recorder{}
+			r := NewReconciler(&fake.Manager{}, WithRecorder(rec), WithNewDagFn(dag.NewMapDag))
+			lock := &v1beta1.Lock{Packages: tc.pkgs}
+			res := newResolutionResult(lock, nil, tc.cs, time.Time{}, 0)
+
+			r.checkEdges(logging.NewNopLogger(), lock, tc.cs, res)
+
+			if diff := cmp.Diff(tc.want.edges, res.edgeState); diff != "" {
+				t.Errorf("\n%s\nr.checkEdges(...): -want edges, +got edges:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.messages, rec.messages); diff != "" {
+				t.Errorf("\n%s\nr.checkEdges(...): -want messages, +got messages:\n%s", tc.reason, diff)
+			}
+			for _, reason := range rec.reasons {
+				if reason != reasonUnreportedViolation && reason != reasonOptionalDependency {
+					t.Errorf("\n%s\nr.checkEdges(...): unexpected event reason %s", tc.reason, reason)
+				}
+			}
+		})
+	}
+}
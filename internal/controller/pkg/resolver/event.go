@@ -0,0 +1,91 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+)
+
+// A dedupingRecorder suppresses events that are identical to an event
+// recorded for the same object within the supplied window. The Lock is
+// requeued frequently while a dependency cannot be resolved, so without
+// deduplication a permanently unresolvable dependency would emit the same
+// event on every reconcile.
+type dedupingRecorder struct {
+	wrapped event.Recorder
+	window  time.Duration
+	now     func() time.Time
+
+	mu   *sync.Mutex
+	seen map[string]time.Time
+}
+
+func newDedupingRecorder(r event.Recorder, window time.Duration) *dedupingRecorder {
+	return &dedupingRecorder{
+		wrapped: r,
+		window:  window,
+		now:     time.Now,
+		mu:      &sync.Mutex{},
+		seen:    map[string]time.Time{},
+	}
+}
+
+// Event records the supplied event unless an identical event was recorded
+// for the same object within the deduplication window. Messages are truncated,
+// so that a message that quotes a verbose dependency stays readable.
+func (r *dedupingRecorder) Event(obj runtime.Object, e event.Event) {
+	e.Message = truncate(e.Message, maxMessageLength)
+	key := string(e.Type) + "/" + string(e.Reason) + "/" + e.Message
+	if m, err := meta.Accessor(obj); err == nil {
+		key = string(m.GetUID()) + "/" + m.GetName() + "/" + key
+	}
+
+	now := r.now()
+	r.mu.Lock()
+	for k, t := range r.seen {
+		if now.Sub(t) >= r.window {
+			delete(r.seen, k)
+		}
+	}
+	if _, ok := r.seen[key]; ok {
+		r.mu.Unlock()
+		return
+	}
+	r.seen[key] = now
+	r.mu.Unlock()
+
+	r.wrapped.Event(obj, e)
+}
+
+// WithAnnotations returns a new recorder that includes the supplied
+// annotations with all recorded events. The returned recorder shares its
+// deduplication state with this one.
+func (r *dedupingRecorder) WithAnnotations(keysAndValues ...string) event.Recorder {
+	return &dedupingRecorder{
+		wrapped: r.wrapped.WithAnnotations(keysAndValues...),
+		window:  r.window,
+		now:     r.now,
+		mu:      r.mu,
+		seen:    r.seen,
+	}
+}
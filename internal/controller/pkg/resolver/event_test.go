@@ -0,0 +1,117 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+)
+
+func TestDedupingRecorder(t *testing.T) {
+	errBoom := errors.New("boom")
+	lock := &v1beta1.Lock{ObjectMeta: metav1.ObjectMeta{Name: "lock", UID: "a"}}
+	other := &v1beta1.Lock{ObjectMeta: metav1.ObjectMeta{Name: "other", UID: "b"}}
+	now := time.Now()
+
+	type step struct {
+		obj     *v1beta1.Lock
+		e       event.Event
+		advance time.Duration
+	}
+	cases := map[string]struct {
+		reason string
+		window time.Duration
+		steps  []step
+		want   []event.Reason
+	}{
+		"Distinct": {
+			window: defaultEventWindow,
+			reason: "Distinct events should all be recorded.",
+			steps: []step{
+				{obj: lock, e: event.Warning(reasonFetchTags, errBoom)},
+				{obj: lock, e: event.Warning(reasonCreateDependency, errBoom)},
+				{obj: other, e: event.Warning(reasonFetchTags, errBoom)},
+			},
+			want: []event.Reason{reasonFetchTags, reasonCreateDependency, reasonFetchTags},
+		},
+		"Duplicate": {
+			window: defaultEventWindow,
+			reason: "Identical events on the same object within the window should be suppressed.",
+			steps: []step{
+				{obj: lock, e: event.Warning(reasonFetchTags, errBoom)},
+				{obj: lock, e: event.Warning(reasonFetchTags, errBoom), advance: defaultEventWindow / 2},
+			},
+			want: []event.Reason{reasonFetchTags},
+		},
+		"WindowElapsed": {
+			window: defaultEventWindow,
+			reason: "Identical events should be recorded again once the window has elapsed.",
+			steps: []step{
+				{obj: lock, e: event.Warning(reasonFetchTags, errBoom)},
+				{obj: lock, e: event.Warning(reasonFetchTags, errBoom), advance: defaultEventWindow},
+			},
+			want: []event.Reason{reasonFetchTags, reasonFetchTags},
+		},
+		"Disabled": {
+			reason: "Identical events should all be recorded if the window is zero.",
+			steps: []step{
+				{obj: lock, e: event.Warning(reasonFetchTags, errBoom)},
+				{obj: lock, e: event.Warning(reasonFetchTags, errBoom)},
+			},
+			want: []event.Reason{reasonFetchTags, reasonFetchTags},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			rec := &recorder{}
+			r := newDedupingRecorder(rec, tc.window)
+			clock := now
+			r.now = func() time.Time { return clock }
+			for _, s := range tc.steps {
+				clock = clock.Add(s.advance)
+				r.Event(s.obj, s.e)
+			}
+			if diff := cmp.Diff(tc.want, rec.reasons); diff != "" {
+				t.Errorf("\n%s\nr.Event(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestDedupingRecorderTruncates(t *testing.T) {
+	lock := &v1beta1.Lock{ObjectMeta: metav1.ObjectMeta{Name: "lock", UID: "a"}}
+	rec := &recorder{}
+	r := newDedupingRecorder(rec, defaultEventWindow)
+	r.Event(lock, event.Warning(reasonFetchTags, errors.New(strings.Repeat("A", 100000))))
+
+	if len(rec.messages) != 1 {
+		t.Fatalf("r.Event(...): want 1 event, got %d", len(rec.messages))
+	}
+	if got := len(rec.messages[0]); got > maxMessageLength {
+		t.Errorf("r.Event(...): message is %d characters long, want at most %d", got, maxMessageLength)
+	}
+}
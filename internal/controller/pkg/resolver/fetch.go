@@ -0,0 +1,51 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+	"github.com/crossplane/crossplane/internal/xpkg"
+)
+
+const (
+	errRegistryUnauthorized = "registry refused to list dependency tags; add pull secrets for it to Crossplane's service account, or configure them as default pull secrets"
+)
+
+// fetchTagsFailed reports why the tags of a missing dependency could not be
+// fetched. A registry that refuses our credentials won't accept them next
+// time either, so we tell the user how to fix it. A registry that rate limits
+// us is backed off independently of one that is unavailable, and honors how
+// long the registry asked us to wait.
+func (r *Reconciler) fetchTagsFailed(log logging.Logger, lock *v1beta1.Lock, err *xpkg.DependencyError) (xpv1.Condition, RequeueReason, error) {
+	switch xpkg.ClassifyFetchError(err) {
+	case xpkg.FetchUnauthorized:
+		werr := errors.Wrap(err, errRegistryUnauthorized)
+		log.Debug("Registry refused to list dependency tags", "error", werr)
+		r.record.Event(lock, event.Warning(reasonRegistryAuth, werr))
+		return v1beta1.RegistryUnauthorized(), RequeueUnauthorized, werr
+	case xpkg.FetchRateLimited:
+		r.record.Event(lock, event.Warning(reasonRateLimited, err))
+		return v1beta1.FetchError(), RequeueRateLimited, err
+	}
+	r.record.Event(lock, event.Warning(reasonFetchTags, err))
+	return v1beta1.FetchError(), RequeueFetchError, err
+}
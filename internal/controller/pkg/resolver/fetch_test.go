@@ -0,0 +1,170 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kfake "k8s.io/client-go/kubernetes/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/fake"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+	"github.com/crossplane/crossplane/internal/dag"
+	"github.com/crossplane/crossplane/internal/xpkg"
+)
+
+func TestReconcileFetchTagsFailures(t *testing.T) {
+	// The registry fails to list tags with the status code and Retry-After
+	// header of the case.
+	var status int
+	var retryAfter string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v2/" {
+			return
+		}
+		if retryAfter != "" {
+			w.Header().Set("Retry-After", retryAfter)
+		}
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+	registry := strings.TrimPrefix(srv.URL, "http://")
+
+	ns := "crossplane-system"
+	clientset := kfake.NewSimpleClientset(&corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: "default"}})
+
+	// Each reason to requeue waits a distinct duration, so we can tell
+	// which reason we requeued for.
+	strategy := requeueStrategy{
+		RequeueFetchError:   1 * time.Minute,
+		RequeueRateLimited:  2 * time.Minute,
+		RequeueUnauthorized: 3 * time.Minute,
+	}
+
+	type want struct {
+		r      reconcile.Result
+		events []event.Reason
+		reason xpv1.ConditionReason
+	}
+
+	cases := map[string]struct {
+		reason     string
+		status     int
+		retryAfter string
+		want       want
+	}{
+		"Unauthorized": {
+			reason: "We should tell the user to add pull secrets if the registry refuses to list tags without them.",
+			status: http.StatusUnauthorized,
+			want: want{
+				r:      reconcile.Result{RequeueAfter: 3 * time.Minute},
+				events: []event.Reason{reasonRegistryAuth},
+				reason: v1beta1.ReasonUnauthorized,
+			},
+		},
+		"RateLimited": {
+			reason: "We should back off independently if the registry rate limits us.",
+			status: http.StatusTooManyRequests,
+			want: want{
+				r:      reconcile.Result{RequeueAfter: 2 * time.Minute},
+				events: []event.Reason{reasonRateLimited},
+				reason: v1beta1.ReasonFetchError,
+			},
+		},
+		"RateLimitedRetryAfter": {
+			reason:     "We should not requeue before the registry that rate limited us asked us to retry.",
+			status:     http.StatusTooManyRequests,
+			retryAfter: "600",
+			want: want{
+				r:      reconcile.Result{RequeueAfter: 10 * time.Minute},
+				events: []event.Reason{reasonRateLimited},
+				reason: v1beta1.ReasonFetchError,
+			},
+		},
+		"Unavailable": {
+			reason: "We should retry with the default backoff if the registry fails to list tags.",
+			status: http.StatusInternalServerError,
+			want: want{
+				r:      reconcile.Result{RequeueAfter: 1 * time.Minute},
+				events: []event.Reason{reasonFetchTags},
+				reason: v1beta1.ReasonFetchError,
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			status, retryAfter = tc.status, tc.retryAfter
+			var got xpv1.ConditionReason
+			mgr := &fake.Manager{
+				Client: &test.MockClient{
+					MockGet: test.NewMockGetFn(nil, func(o client.Object) error {
+						o.(*v1beta1.Lock).Packages = []v1beta1.LockPackage{{
+							Source: "cool/config",
+							Dependencies: []v1beta1.Dependency{
+								{Package: registry + "/cool/provider", Type: v1beta1.ProviderPackageType, Constraints: ">=v0.20.0"},
+							},
+						}}
+						return nil
+					}),
+					MockList:   test.NewMockListFn(nil),
+					MockUpdate: test.NewMockUpdateFn(nil),
+					MockStatusUpdate: test.NewMockStatusUpdateFn(nil, func(o client.Object) error {
+						got = o.(*v1beta1.Lock).GetCondition(v1beta1.TypeDependenciesResolved).Reason
+						return nil
+					}),
+				},
+			}
+			rec := &recorder{}
+			r := NewReconciler(mgr,
+				WithRecorder(rec),
+				WithFetcher(xpkg.NewK8sFetcher(clientset, ns, xpkg.WithInsecureRegistries(registry))),
+				WithNamespace(ns),
+				WithRequeueStrategy(strategy),
+				WithNewDagFn(dag.NewMapDag),
+			)
+
+			res, err := r.Reconcile(context.Background(), reconcile.Request{})
+			if err != nil {
+				t.Fatalf("\n%s\nr.Reconcile(...): %s", tc.reason, err)
+			}
+			if diff := cmp.Diff(tc.want.r, res); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want, +got:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.events, rec.reasons); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want events, +got events:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.reason, got); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want condition reason, +got condition reason:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
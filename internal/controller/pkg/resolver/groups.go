@@ -0,0 +1,160 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+
+	v1 "github.com/crossplane/crossplane/apis/pkg/v1"
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+	"github.com/crossplane/crossplane/internal/xpkg"
+)
+
+const (
+	errListProviderRevisions = "cannot list provider revisions"
+	errListProviders         = "cannot list providers"
+	errCheckAPIGroupsFmt     = "cannot determine the API groups that dependency package %s serves; installing it anyway"
+	errUnknownAPIGroups      = "package metadata does not declare the API groups it serves"
+	errAPIGroupConflictFmt   = "package would serve API groups that installed providers already serve: %s"
+	servedByFmt              = "%s (served by %s)"
+)
+
+// WithConflictDetection specifies that the Reconciler should refuse to
+// install a dependency Provider whose package metadata declares a CRD in an
+// API group that a provider revision from another repository already serves,
+// because the two providers would contend for ownership of its CRDs.
+func WithConflictDetection() ReconcilerOption {
+	return func(r *Reconciler) {
+		r.conflicts = true
+	}
+}
+
+// groupConflicts returns a description of the API groups that the supplied
+// dependency package would serve and that a provider revision from another
+// repository already serves, or an empty string if there are none. Other
+// versions of the same repository serve the same groups by design, so they
+// never conflict. The revisions of providers associated with another Lock are
+// ignored. We fail open, recording the dependency as unchecked, if its
+// metadata doesn't tell us the groups it serves; the package manager still
+// reports a conflict when the revision is installed.
+func (r *Reconciler) groupConflicts(ctx context.Context, log logging.Logger, lock *v1beta1.Lock, pack v1.Package, res *resolutionResult) (string, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	// There's nothing to check if we already installed this dependency.
+	repo := r.sourceRepository(pack.GetSource())
+	if n, err := r.created(ctx, pack, repo); err != nil || n != "" {
+		return "", nil
+	}
+
+	pl := &v1.ProviderList{}
+	if err := r.client.List(ctx, pl); err != nil {
+		return "", errors.Wrap(err, errListProviders)
+	}
+	others := map[string]bool{}
+	for i := range pl.Items {
+		others[pl.Items[i].GetName()] = lockOf(&pl.Items[i]) != lockOf(pack)
+	}
+
+	l := &v1.ProviderRevisionList{}
+	if err := r.client.List(ctx, l); err != nil {
+		return "", errors.Wrap(err, errListProviderRevisions)
+	}
+	served := map[string]string{}
+	for _, pr := range l.Items {
+		if r.sourceRepository(pr.GetSource()) == repo {
+			continue
+		}
+		if others[pr.GetLabels()[v1.LabelParentPackage]] {
+			continue
+		}
+		for _, ref := range pr.GetObjects() {
+			if ref.Kind != "CustomResourceDefinition" {
+				continue
+			}
+			// CRDs are named <plural>.<group>.
+			if i := strings.Index(ref.Name, "."); i > 0 {
+				served[ref.Name[i+1:]] = pr.GetName()
+			}
+		}
+	}
+	if len(served) == 0 {
+		return "", nil
+	}
+
+	groups, err := r.groups(ctx, pack)
+	if err != nil {
+		err = errors.Wrapf(err, errCheckAPIGroupsFmt, pack.GetSource())
+		log.Debug("Cannot check dependency for API group conflicts", "error", err)
+		r.record.Event(lock, event.Warning(reasonCheckAPIGroups, err))
+		res.unchecked(err)
+		return "", nil
+	}
+
+	conflicts := make([]string, 0)
+	for _, g := range groups {
+		if pr, ok := served[g]; ok {
+			conflicts = append(conflicts, fmt.Sprintf(servedByFmt, g, pr))
+		}
+	}
+	sort.Strings(conflicts)
+	return strings.Join(conflicts, ", "), nil
+}
+
+// groups returns the API groups of the CRDs in the supplied package, which
+// we read from the package's metadata.
+func (r *Reconciler) groups(ctx context.Context, pack v1.Package) ([]string, error) {
+	m, err := r.meta(ctx, pack)
+	if err != nil {
+		return nil, err
+	}
+	if !m.KnowsAPIGroups() {
+		return nil, errors.New(errUnknownAPIGroups)
+	}
+	return m.APIGroups, nil
+}
+
+// meta fetches the metadata of the supplied package without pulling its
+// image, presenting its pull secrets.
+func (r *Reconciler) meta(ctx context.Context, pack v1.Package) (*xpkg.PackageMeta, error) {
+	ref, err := name.ParseReference(pack.GetSource(), xpkg.DefaultRegistryOptions(r.registry)...)
+	if err != nil {
+		return nil, errors.Wrap(err, errParseSource)
+	}
+	return r.fetcher.FetchMeta(ctx, ref, v1.RefNames(pack.GetPackagePullSecrets())...)
+}
+
+// groupConflicted reports that the supplied dependency was not installed
+// because it would serve the supplied conflicting API groups. Only a user can
+// resolve the conflict, so we don't requeue.
+func (r *Reconciler) groupConflicted(log logging.Logger, lock *v1beta1.Lock, p xpkg.PlannedInstall, conflicts string) (xpv1.Condition, RequeueReason, error) {
+	err := errors.Errorf(errDependencyFmt, fmt.Sprintf(errAPIGroupConflictFmt, conflicts), p.Dependency.Package, p.Dependency.Constraints)
+	log.Debug(errInstallDependency, "error", err)
+	r.record.Event(lock, event.Warning(reasonAPIGroupConflict, err))
+	r.metrics.failures.WithLabelValues(failureAPIGroupConflict).Inc()
+	return v1beta1.DependencyRejected(), "", err
+}
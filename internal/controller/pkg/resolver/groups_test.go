@@ -0,0 +1,241 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/fake"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	v1 "github.com/crossplane/crossplane/apis/pkg/v1"
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+	"github.com/crossplane/crossplane/internal/dag"
+	"github.com/crossplane/crossplane/internal/xpkg"
+	fakexpkg "github.com/crossplane/crossplane/internal/xpkg/fake"
+)
+
+func TestReconcileConflictDetection(t *testing.T) {
+	errBoom := errors.New("boom")
+
+	aws := fakexpkg.NewMockFetchMetaFn(&xpkg.PackageMeta{APIGroups: []string{"s3.aws.crossplane.io", "ec2.aws.crossplane.io"}}, nil)
+
+	// revision returns a provider revision of the supplied package that
+	// serves the supplied CRDs.
+	revision := func(name, pkg string, crds ...string) v1.ProviderRevision {
+		pr := v1.ProviderRevision{}
+		pr.SetName(name)
+		pr.SetSource(pkg)
+		for _, c := range crds {
+			pr.Status.ObjectRefs = append(pr.Status.ObjectRefs, xpv1.TypedReference{APIVersion: "apiextensions.k8s.io/v1", Kind: "CustomResourceDefinition", Name: c})
+		}
+		return pr
+	}
+
+	type args struct {
+		enabled   bool
+		revisions []v1.ProviderRevision
+		meta      func() (*xpkg.PackageMeta, error)
+	}
+	type want struct {
+		created  []string
+		events   []event.Reason
+		reason   xpv1.ConditionReason
+		messages []string
+		checked  xpv1.ConditionReason
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"Disabled": {
+			reason: "We should not check for conflicts unless conflict detection is enabled.",
+			args: args{
+				revisions: []v1.ProviderRevision{revision("fork-aws-a1b2", "fork/provider-aws:v0.20.0", "buckets.s3.aws.crossplane.io")},
+				meta:      aws,
+			},
+			want: want{
+				created: []string{"crossplane-provider-aws"},
+				events:  []event.Reason{reasonInstallDependency},
+				reason:  v1beta1.ReasonMissingDependency,
+			},
+		},
+		"CleanInstall": {
+			reason: "We should install a dependency whose API groups no installed provider serves.",
+			args: args{
+				enabled:   true,
+				revisions: []v1.ProviderRevision{revision("provider-gcp-a1b2", "crossplane/provider-gcp:v0.18.0", "buckets.storage.gcp.crossplane.io")},
+				meta:      aws,
+			},
+			want: want{
+				created: []string{"crossplane-provider-aws"},
+				events:  []event.Reason{reasonInstallDependency},
+				reason:  v1beta1.ReasonMissingDependency,
+				checked: v1beta1.ReasonChecked,
+			},
+		},
+		"OverlappingGroups": {
+			reason: "We should refuse to install a dependency whose metadata says it would serve API groups a provider from another repository serves.",
+			args: args{
+				enabled: true,
+				revisions: []v1.ProviderRevision{
+					revision("fork-aws-a1b2", "fork/provider-aws:v0.20.0", "buckets.s3.aws.crossplane.io", "vpcs.ec2.aws.crossplane.io"),
+					revision("provider-gcp-a1b2", "crossplane/provider-gcp:v0.18.0", "buckets.storage.gcp.crossplane.io"),
+				},
+				meta: aws,
+			},
+			want: want{
+				events: []event.Reason{reasonAPIGroupConflict},
+				reason: v1beta1.ReasonRejected,
+				messages: []string{`package would serve API groups that installed providers already serve: ` +
+					`ec2.aws.crossplane.io (served by fork-aws-a1b2), s3.aws.crossplane.io (served by fork-aws-a1b2): ` +
+					`dependency crossplane/provider-aws with constraints ">=v0.20.0"`},
+				checked: v1beta1.ReasonChecked,
+			},
+		},
+		"SameRepository": {
+			reason: "We should not treat API groups served by another version of the same repository as a conflict.",
+			args: args{
+				enabled:   true,
+				revisions: []v1.ProviderRevision{revision("provider-aws-a1b2", "crossplane/provider-aws:v0.19.0", "buckets.s3.aws.crossplane.io")},
+				meta:      aws,
+			},
+			want: want{
+				created: []string{"crossplane-provider-aws"},
+				events:  []event.Reason{reasonInstallDependency},
+				reason:  v1beta1.ReasonMissingDependency,
+				checked: v1beta1.ReasonChecked,
+			},
+		},
+		"UnknownGroups": {
+			reason: "We should warn, install the dependency anyway, and report that it was unchecked if its metadata doesn't tell us the API groups it serves.",
+			args: args{
+				enabled:   true,
+				revisions: []v1.ProviderRevision{revision("fork-aws-a1b2", "fork/provider-aws:v0.20.0", "buckets.s3.aws.crossplane.io")},
+				meta:      fakexpkg.NewMockFetchMetaFn(&xpkg.PackageMeta{Complete: true}, nil),
+			},
+			want: want{
+				created: []string{"crossplane-provider-aws"},
+				events:  []event.Reason{reasonCheckAPIGroups, reasonInstallDependency},
+				reason:  v1beta1.ReasonMissingDependency,
+				messages: []string{
+					"cannot determine the API groups that dependency package crossplane/provider-aws:v0.20.0 serves; installing it anyway: " + errUnknownAPIGroups,
+					"Installed automatically as a dependency of cool/config to satisfy constraint >=v0.20.0, selected version v0.20.0 from 1 available tags",
+				},
+				checked: v1beta1.ReasonUnknownMetadata,
+			},
+		},
+		"ErrFetchMetadata": {
+			reason: "We should warn, install the dependency anyway, and report that it was unchecked if we cannot fetch its metadata.",
+			args: args{
+				enabled:   true,
+				revisions: []v1.ProviderRevision{revision("fork-aws-a1b2", "fork/provider-aws:v0.20.0", "buckets.s3.aws.crossplane.io")},
+				meta:      fakexpkg.NewMockFetchMetaFn(nil, errBoom),
+			},
+			want: want{
+				created: []string{"crossplane-provider-aws"},
+				events:  []event.Reason{reasonCheckAPIGroups, reasonInstallDependency},
+				reason:  v1beta1.ReasonMissingDependency,
+				checked: v1beta1.ReasonUnknownMetadata,
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var created []string
+			var got *v1beta1.Lock
+			mgr := &fake.Manager{
+				Client: &test.MockClient{
+					MockGet: func(_ context.Context, key client.ObjectKey, o client.Object) error {
+						if l, ok := o.(*v1beta1.Lock); ok {
+							l.Packages = []v1beta1.LockPackage{{
+								Source: "cool/config",
+								Dependencies: []v1beta1.Dependency{
+									{Package: "crossplane/provider-aws", Type: v1beta1.ProviderPackageType, Constraints: ">=v0.20.0"},
+								},
+							}}
+							return nil
+						}
+						return kerrors.NewNotFound(schema.GroupResource{}, key.Name)
+					},
+					MockList: func(_ context.Context, l client.ObjectList, _ ...client.ListOption) error {
+						if prl, ok := l.(*v1.ProviderRevisionList); ok {
+							prl.Items = tc.args.revisions
+						}
+						return nil
+					},
+					MockCreate: func(_ context.Context, o client.Object, _ ...client.CreateOption) error {
+						created = append(created, o.GetName())
+						return nil
+					},
+					MockUpdate: test.NewMockUpdateFn(nil),
+					MockStatusUpdate: test.NewMockStatusUpdateFn(nil, func(o client.Object) error {
+						got = o.(*v1beta1.Lock)
+						return nil
+					}),
+				},
+			}
+			rec := &recorder{}
+			opts := []ReconcilerOption{
+				WithRecorder(rec),
+				WithFetcher(&fakexpkg.MockFetcher{
+					MockFetchMeta: tc.args.meta,
+					MockTags:      fakexpkg.NewMockTagsFn([]string{"v0.20.0"}, nil),
+				}),
+				WithNewDagFn(dag.NewMapDag),
+			}
+			if tc.args.enabled {
+				opts = append(opts, WithConflictDetection())
+			}
+			r := NewReconciler(mgr, opts...)
+
+			if _, err := r.Reconcile(context.Background(), reconcile.Request{}); err != nil {
+				t.Fatalf("\n%s\nr.Reconcile(...): %s", tc.reason, err)
+			}
+			if diff := cmp.Diff(tc.want.created, created); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want created, +got created:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.events, rec.reasons); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want events, +got events:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.reason, got.GetCondition(v1beta1.TypeDependenciesResolved).Reason); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want condition reason, +got condition reason:\n%s", tc.reason, diff)
+			}
+			if tc.want.messages != nil {
+				if diff := cmp.Diff(tc.want.messages, rec.messages); diff != "" {
+					t.Errorf("\n%s\nr.Reconcile(...): -want event messages, +got event messages:\n%s", tc.reason, diff)
+				}
+			}
+			if diff := cmp.Diff(tc.want.checked, got.GetCondition(v1beta1.TypeDependenciesChecked).Reason); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want checked reason, +got checked reason:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
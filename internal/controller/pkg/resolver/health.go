@@ -0,0 +1,158 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+
+	v1 "github.com/crossplane/crossplane/apis/pkg/v1"
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+	"github.com/crossplane/crossplane/internal/xpkg"
+)
+
+const (
+	errCheckHealth = "cannot check health of auto-installed packages"
+
+	msgNotInstalledFmt = "waiting for %s to be installed"
+	msgUnhealthyFmt    = "waiting for %s revision to become healthy"
+)
+
+// WithHealthGating specifies that the Reconciler should wait for each package
+// it installs to be installed and healthy before it installs the package's
+// dependencies. Missing dependencies that are blocked by a package that is
+// not yet healthy are recorded in the Lock's status.
+func WithHealthGating() ReconcilerOption {
+	return func(r *Reconciler) {
+		r.gateHealth = true
+	}
+}
+
+// gate returns the supplied planned installs that are not blocked by an
+// unhealthy auto-installed package, and the missing dependencies that are.
+// A missing dependency is blocked only if every package that depends on it
+// is blocking, so that packages that are healthy or were installed manually
+// aren't held up. Planned installs that can't be installed aren't blocked,
+// so that we surface why.
+func (r *Reconciler) gate(ctx context.Context, log logging.Logger, lock *v1beta1.Lock, plan []xpkg.PlannedInstall) ([]xpkg.PlannedInstall, []v1beta1.BlockedDependency, error) {
+	blocking, err := r.blocking(ctx, lock)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(blocking) == 0 {
+		return plan, nil, nil
+	}
+
+	allowed := make([]xpkg.PlannedInstall, 0, len(plan))
+	var blocked []v1beta1.BlockedDependency
+	for _, p := range plan {
+		if p.Err != nil {
+			allowed = append(allowed, p)
+			continue
+		}
+		b, ok := r.blockedBy(p, blocking)
+		if !ok {
+			allowed = append(allowed, p)
+			continue
+		}
+		log.Debug("Missing dependency is blocked", "dependency", p.Dependency.Package, "blocked-on", b.BlockedOn, "reason", b.Reason)
+		blocked = append(blocked, b)
+	}
+	sort.Slice(blocked, func(i, j int) bool { return blocked[i].Package < blocked[j].Package })
+	return allowed, blocked, nil
+}
+
+// blockedBy returns the first package, sorted by source, that blocks the
+// supplied planned install. It returns false if any package that depends on
+// it is not blocking.
+func (r *Reconciler) blockedBy(p xpkg.PlannedInstall, blocking map[string]v1beta1.BlockedDependency) (v1beta1.BlockedDependency, bool) {
+	if len(p.Requirements) == 0 {
+		return v1beta1.BlockedDependency{}, false
+	}
+	var first *v1beta1.BlockedDependency
+	for _, rq := range p.Requirements {
+		if rq.Parent == "" {
+			return v1beta1.BlockedDependency{}, false
+		}
+		b, ok := blocking[r.sourceRepository(rq.Parent)]
+		if !ok {
+			return v1beta1.BlockedDependency{}, false
+		}
+		if first == nil {
+			b.BlockedOn = rq.Parent
+			first = &b
+		}
+	}
+	first.Package = p.Dependency.Package
+	return *first, true
+}
+
+// blocking returns why each package auto-installed for the supplied Lock that
+// is not yet installed or is unhealthy blocks its dependencies, keyed by its
+// canonical repository.
+func (r *Reconciler) blocking(ctx context.Context, lock *v1beta1.Lock) (map[string]v1beta1.BlockedDependency, error) {
+	pkgs, err := r.autoInstalled(ctx, lock)
+	if err != nil {
+		return nil, errors.Wrap(err, errCheckHealth)
+	}
+	out := map[string]v1beta1.BlockedDependency{}
+	for _, p := range pkgs {
+		if b, ok := blocks(p); ok {
+			out[r.sourceRepository(p.GetSource())] = b
+		}
+	}
+	return out, nil
+}
+
+// blocks returns why the supplied package blocks its dependencies from being
+// installed, if it does. A package whose health is unknown doesn't block its
+// dependencies, because its revision's health is unknown until they're
+// installed.
+func blocks(p v1.Package) (v1beta1.BlockedDependency, bool) {
+	if c := p.GetCondition(v1.TypeInstalled); c.Status != corev1.ConditionTrue {
+		return v1beta1.BlockedDependency{Reason: c.Reason, Message: detailed(fmt.Sprintf(msgNotInstalledFmt, p.GetName()), c.Message)}, true
+	}
+	if c := p.GetCondition(v1.TypeHealthy); c.Status == corev1.ConditionFalse {
+		return v1beta1.BlockedDependency{Reason: c.Reason, Message: detailed(fmt.Sprintf(msgUnhealthyFmt, p.GetName()), c.Message)}, true
+	}
+	return v1beta1.BlockedDependency{}, false
+}
+
+// detailed returns the supplied message, followed by the supplied detail if
+// there is one.
+func detailed(msg, detail string) string {
+	if detail == "" {
+		return msg
+	}
+	return msg + ": " + detail
+}
+
+// blockedMessage summarizes the supplied blocked dependencies.
+func blockedMessage(blocked []v1beta1.BlockedDependency) string {
+	msgs := make([]string, len(blocked))
+	for i, b := range blocked {
+		msgs[i] = fmt.Sprintf("dependency %s is blocked: %s", b.Package, b.Message)
+	}
+	return strings.Join(msgs, "; ")
+}
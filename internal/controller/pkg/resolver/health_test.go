@@ -0,0 +1,218 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/fake"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	v1 "github.com/crossplane/crossplane/apis/pkg/v1"
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+	"github.com/crossplane/crossplane/internal/dag"
+	fakexpkg "github.com/crossplane/crossplane/internal/xpkg/fake"
+)
+
+func TestReconcileHealthGating(t *testing.T) {
+	// config-a was installed manually and depends on config-b, which was
+	// auto-installed and depends on provider-aws, which is missing.
+	lock := []v1beta1.LockPackage{
+		{
+			Source:  "crossplane/config-a",
+			Type:    v1beta1.ConfigurationPackageType,
+			Version: "v1.0.0",
+			Dependencies: []v1beta1.Dependency{
+				{Package: "crossplane/config-b", Type: v1beta1.ConfigurationPackageType, Constraints: ">=v1.0.0"},
+			},
+		},
+		{
+			Source:  "crossplane/config-b",
+			Type:    v1beta1.ConfigurationPackageType,
+			Version: "v1.0.0",
+			Dependencies: []v1beta1.Dependency{
+				{Package: "crossplane/provider-aws", Type: v1beta1.ProviderPackageType, Constraints: ">=v0.20.0"},
+			},
+		},
+	}
+	configB := func(cs ...xpv1.Condition) v1.Configuration {
+		c := v1.Configuration{ObjectMeta: metav1.ObjectMeta{
+			Name:   "crossplane-config-b",
+			Labels: map[string]string{LabelAutoInstalled: "true"},
+		}}
+		c.Spec.Package = "crossplane/config-b:v1.0.0"
+		c.SetConditions(cs...)
+		return c
+	}
+
+	type args struct {
+		gate    bool
+		configB v1.Configuration
+	}
+	type want struct {
+		created []string
+		blocked []v1beta1.BlockedDependency
+		reason  xpv1.ConditionReason
+		message string
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"Disabled": {
+			reason: "We should install missing dependencies of unhealthy packages if health gating is disabled.",
+			args: args{
+				configB: configB(v1.Active(), v1.Unhealthy()),
+			},
+			want: want{
+				created: []string{"crossplane-provider-aws"},
+				reason:  v1beta1.ReasonMissingDependency,
+				message: "waiting for 1 missing dependencies to be installed",
+			},
+		},
+		"Healthy": {
+			reason: "We should install missing dependencies of healthy packages.",
+			args: args{
+				gate:    true,
+				configB: configB(v1.Active(), v1.Healthy()),
+			},
+			want: want{
+				created: []string{"crossplane-provider-aws"},
+				reason:  v1beta1.ReasonMissingDependency,
+				message: "waiting for 1 missing dependencies to be installed",
+			},
+		},
+		"UnknownHealth": {
+			reason: "We should install missing dependencies of packages whose health is unknown, because it won't be known until they're installed.",
+			args: args{
+				gate:    true,
+				configB: configB(v1.Active(), v1.UnknownHealth()),
+			},
+			want: want{
+				created: []string{"crossplane-provider-aws"},
+				reason:  v1beta1.ReasonMissingDependency,
+				message: "waiting for 1 missing dependencies to be installed",
+			},
+		},
+		"Unhealthy": {
+			reason: "We should not install missing dependencies of unhealthy packages, and should report why.",
+			args: args{
+				gate:    true,
+				configB: configB(v1.Active(), v1.Unhealthy().WithMessage("boom")),
+			},
+			want: want{
+				blocked: []v1beta1.BlockedDependency{{
+					Package:   "crossplane/provider-aws",
+					BlockedOn: "crossplane/config-b",
+					Reason:    v1.ReasonUnhealthy,
+					Message:   "waiting for crossplane-config-b revision to become healthy: boom",
+				}},
+				reason:  v1beta1.ReasonBlocked,
+				message: "dependency crossplane/provider-aws is blocked: waiting for crossplane-config-b revision to become healthy: boom",
+			},
+		},
+		"NotInstalled": {
+			reason: "We should not install missing dependencies of packages that are not yet installed, and should report why.",
+			args: args{
+				gate:    true,
+				configB: configB(v1.Unpacking()),
+			},
+			want: want{
+				blocked: []v1beta1.BlockedDependency{{
+					Package:   "crossplane/provider-aws",
+					BlockedOn: "crossplane/config-b",
+					Reason:    v1.ReasonUnpacking,
+					Message:   "waiting for crossplane-config-b to be installed",
+				}},
+				reason:  v1beta1.ReasonBlocked,
+				message: "dependency crossplane/provider-aws is blocked: waiting for crossplane-config-b to be installed",
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var created []string
+			var status *v1beta1.Lock
+			mgr := &fake.Manager{
+				Client: &test.MockClient{
+					MockGet: test.NewMockGetFn(nil, func(o client.Object) error {
+						l, ok := o.(*v1beta1.Lock)
+						if !ok {
+							return kerrors.NewNotFound(schema.GroupResource{}, o.GetName())
+						}
+						l.SetName("lock")
+						l.Packages = lock
+						return nil
+					}),
+					MockList: test.NewMockListFn(nil, func(o client.ObjectList) error {
+						if l, ok := o.(*v1.ConfigurationList); ok {
+							l.Items = []v1.Configuration{tc.args.configB}
+						}
+						return nil
+					}),
+					MockCreate: func(_ context.Context, o client.Object, _ ...client.CreateOption) error {
+						created = append(created, o.GetName())
+						return nil
+					},
+					MockUpdate: test.NewMockUpdateFn(nil),
+					MockStatusUpdate: test.NewMockStatusUpdateFn(nil, func(o client.Object) error {
+						status = o.(*v1beta1.Lock)
+						return nil
+					}),
+				},
+			}
+			opts := []ReconcilerOption{
+				WithRecorder(&recorder{}),
+				WithFetcher(&fakexpkg.MockFetcher{MockTags: fakexpkg.NewMockTagsFn([]string{"v0.20.0"}, nil)}),
+				WithNewDagFn(dag.NewMapDag),
+			}
+			if tc.args.gate {
+				opts = append(opts, WithHealthGating())
+			}
+			r := NewReconciler(mgr, opts...)
+			if _, err := r.Reconcile(context.Background(), reconcile.Request{}); err != nil {
+				t.Fatalf("\n%s\nr.Reconcile(...): %s", tc.reason, err)
+			}
+
+			if diff := cmp.Diff(tc.want.created, created); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want created, +got created:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.blocked, status.Status.Blocked); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want blocked, +got blocked:\n%s", tc.reason, diff)
+			}
+			c := status.GetCondition(v1beta1.TypeDependenciesResolved)
+			if diff := cmp.Diff(tc.want.reason, c.Reason); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want condition reason, +got condition reason:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.message, c.Message); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want condition message, +got condition message:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
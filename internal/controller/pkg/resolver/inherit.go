@@ -0,0 +1,152 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+
+	v1 "github.com/crossplane/crossplane/apis/pkg/v1"
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+)
+
+const (
+	errGetParentRevision = "cannot get package revision of parent package"
+	errGetParentPackage  = "cannot get parent package"
+
+	msgSettingDisagreement = "Parent packages disagree on dependency setting, using the most conservative"
+)
+
+// pullPolicies ranks package pull policies from the least to the most
+// frequent pulls.
+var pullPolicies = map[corev1.PullPolicy]int{
+	corev1.PullNever:        0,
+	corev1.PullIfNotPresent: 1,
+	corev1.PullAlways:       2,
+}
+
+// parents returns the packages in the Lock that declare the supplied
+// dependency. Packages that no longer exist are ignored.
+func (r *Reconciler) parents(ctx context.Context, lock *v1beta1.Lock, dep *v1beta1.Dependency) ([]v1.Package, error) { // nolint:gocyclo
+	out := []v1.Package{}
+	for _, p := range lock.Packages {
+		declared := false
+		for _, d := range p.Dependencies {
+			if d.Identifier() == dep.Identifier() {
+				declared = true
+				break
+			}
+		}
+		if !declared {
+			continue
+		}
+
+		k, ok := packageKinds[p.Type]
+		if !ok {
+			continue
+		}
+		rev, pack := k.newPackageRevision(), k.newPackage()
+
+		// The Lock refers to package revisions. The settings we want are on
+		// the package that owns the revision.
+		if err := r.client.Get(ctx, types.NamespacedName{Name: p.Name}, rev); err != nil {
+			if kerrors.IsNotFound(err) {
+				continue
+			}
+			return nil, errors.Wrap(err, errGetParentRevision)
+		}
+		name := rev.GetLabels()[v1.LabelParentPackage]
+		if name == "" {
+			continue
+		}
+		if err := r.client.Get(ctx, types.NamespacedName{Name: name}, pack); err != nil {
+			if kerrors.IsNotFound(err) {
+				continue
+			}
+			return nil, errors.Wrap(err, errGetParentPackage)
+		}
+		out = append(out, pack)
+	}
+	return out, nil
+}
+
+// inherit copies the package pull policy, revision activation policy, and
+// revision history limit of the supplied parent packages to the supplied
+// dependency package. Settings a parent does not specify are ignored. When
+// parents disagree the most conservative setting wins: Manual activation over
+// Automatic, the pull policy that pulls least often, and the revision history
+// limit that keeps the most revisions.
+func inherit(log logging.Logger, pack v1.Package, parents []v1.Package) { // nolint:gocyclo
+	var pull *corev1.PullPolicy
+	var activation *v1.RevisionActivationPolicy
+	var limit *int64
+	pullDiffers, activationDiffers, limitDiffers := false, false, false
+
+	for _, p := range parents {
+		if pp := p.GetPackagePullPolicy(); pp != nil {
+			if _, ok := pullPolicies[*pp]; ok {
+				pullDiffers = pullDiffers || (pull != nil && *pull != *pp)
+				if pull == nil || pullPolicies[*pp] < pullPolicies[*pull] {
+					pull = pp
+				}
+			}
+		}
+		if ap := p.GetActivationPolicy(); ap != nil {
+			activationDiffers = activationDiffers || (activation != nil && *activation != *ap)
+			if activation == nil || *ap == v1.ManualActivation {
+				activation = ap
+			}
+		}
+		if l := p.GetRevisionHistoryLimit(); l != nil {
+			limitDiffers = limitDiffers || (limit != nil && *limit != *l)
+			// A limit of zero disables cleanup, and thus keeps the most
+			// revisions.
+			if limit == nil || *limit != 0 && (*l == 0 || *l > *limit) {
+				limit = l
+			}
+		}
+	}
+
+	if pull != nil {
+		p := *pull
+		pack.SetPackagePullPolicy(&p)
+	}
+	if activation != nil {
+		a := *activation
+		pack.SetActivationPolicy(&a)
+	}
+	if limit != nil {
+		l := *limit
+		pack.SetRevisionHistoryLimit(&l)
+	}
+
+	if pullDiffers {
+		log.Debug(msgSettingDisagreement, "setting", "packagePullPolicy", "value", *pull)
+	}
+	if activationDiffers {
+		log.Debug(msgSettingDisagreement, "setting", "revisionActivationPolicy", "value", *activation)
+	}
+	if limitDiffers {
+		log.Debug(msgSettingDisagreement, "setting", "revisionHistoryLimit", "value", *limit)
+	}
+}
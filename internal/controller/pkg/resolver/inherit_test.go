@@ -0,0 +1,230 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	v1 "github.com/crossplane/crossplane/apis/pkg/v1"
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+)
+
+func TestParents(t *testing.T) {
+	errBoom := errors.New("boom")
+
+	lock := &v1beta1.Lock{
+		Packages: []v1beta1.LockPackage{
+			{
+				Name:         "config-nop-a-1234",
+				Type:         v1beta1.ConfigurationPackageType,
+				Source:       "hasheddan/config-nop-a",
+				Dependencies: []v1beta1.Dependency{{Package: "hasheddan/provider-nop-a"}},
+			},
+			{
+				Name:         "config-nop-b-1234",
+				Type:         v1beta1.ConfigurationPackageType,
+				Source:       "hasheddan/config-nop-b",
+				Dependencies: []v1beta1.Dependency{{Package: "hasheddan/provider-nop-b"}},
+			},
+		},
+	}
+	dep := &v1beta1.Dependency{Package: "hasheddan/provider-nop-a"}
+
+	type want struct {
+		names []string
+		err   error
+	}
+
+	cases := map[string]struct {
+		reason string
+		get    test.MockGetFn
+		want   want
+	}{
+		"Found": {
+			reason: "We should return the packages that own the revisions that declare the dependency.",
+			get: func(_ context.Context, key client.ObjectKey, o client.Object) error {
+				switch o.(type) {
+				case *v1.ConfigurationRevision:
+					if key.Name != "config-nop-a-1234" {
+						t.Errorf("unexpected revision %q", key.Name)
+					}
+					o.SetLabels(map[string]string{v1.LabelParentPackage: "config-nop-a"})
+				case *v1.Configuration:
+					o.SetName(key.Name)
+				}
+				return nil
+			},
+			want: want{names: []string{"config-nop-a"}},
+		},
+		"RevisionNotFound": {
+			reason: "We should ignore parent revisions that no longer exist.",
+			get:    test.NewMockGetFn(kerrors.NewNotFound(schema.GroupResource{}, "")),
+			want:   want{names: []string{}},
+		},
+		"NoParentLabel": {
+			reason: "We should ignore parent revisions that are not labelled with their package.",
+			get:    test.NewMockGetFn(nil),
+			want:   want{names: []string{}},
+		},
+		"ErrGetRevision": {
+			reason: "We should return an error if we cannot get a parent revision.",
+			get:    test.NewMockGetFn(errBoom),
+			want:   want{err: errors.Wrap(errBoom, errGetParentRevision)},
+		},
+		"ErrGetPackage": {
+			reason: "We should return an error if we cannot get a parent package.",
+			get: func(_ context.Context, _ client.ObjectKey, o client.Object) error {
+				if _, ok := o.(*v1.Configuration); ok {
+					return errBoom
+				}
+				o.SetLabels(map[string]string{v1.LabelParentPackage: "config-nop-a"})
+				return nil
+			},
+			want: want{err: errors.Wrap(errBoom, errGetParentPackage)},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			r := &Reconciler{client: &test.MockClient{MockGet: tc.get}}
+			got, err := r.parents(context.Background(), lock, dep)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nr.parents(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			var names []string
+			if got != nil {
+				names = []string{}
+				for _, p := range got {
+					names = append(names, p.GetName())
+				}
+			}
+			if diff := cmp.Diff(tc.want.names, names); diff != "" {
+				t.Errorf("\n%s\nr.parents(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestInherit(t *testing.T) {
+	always, ifNotPresent, never := corev1.PullAlways, corev1.PullIfNotPresent, corev1.PullNever
+	automatic, manual := v1.AutomaticActivation, v1.ManualActivation
+	zero, one, three := int64(0), int64(1), int64(3)
+
+	parent := func(pull *corev1.PullPolicy, activation *v1.RevisionActivationPolicy, limit *int64) v1.Package {
+		return &v1.Configuration{ObjectMeta: metav1.ObjectMeta{Name: "parent"}, Spec: v1.ConfigurationSpec{PackageSpec: v1.PackageSpec{
+			PackagePullPolicy:        pull,
+			RevisionActivationPolicy: activation,
+			RevisionHistoryLimit:     limit,
+		}}}
+	}
+	dependency := func(pull *corev1.PullPolicy, activation *v1.RevisionActivationPolicy, limit *int64) v1.Package {
+		return &v1.Provider{Spec: v1.ProviderSpec{PackageSpec: v1.PackageSpec{
+			PackagePullPolicy:        pull,
+			RevisionActivationPolicy: activation,
+			RevisionHistoryLimit:     limit,
+		}}}
+	}
+
+	cases := map[string]struct {
+		reason  string
+		parents []v1.Package
+		want    v1.Package
+	}{
+		"NoParents": {
+			reason: "We should leave the defaults in place if there are no parents.",
+			want:   dependency(nil, nil, nil),
+		},
+		"Unset": {
+			reason: "We should leave the defaults in place if the parents specify no settings.",
+			parents: []v1.Package{
+				parent(nil, nil, nil),
+			},
+			want: dependency(nil, nil, nil),
+		},
+		"SingleParent": {
+			reason: "We should copy each setting of a single parent.",
+			parents: []v1.Package{
+				parent(&always, &manual, &three),
+			},
+			want: dependency(&always, &manual, &three),
+		},
+		"PullPolicyConflict": {
+			reason: "We should prefer the pull policy that pulls least often.",
+			parents: []v1.Package{
+				parent(&always, nil, nil),
+				parent(&ifNotPresent, nil, nil),
+				parent(nil, nil, nil),
+			},
+			want: dependency(&ifNotPresent, nil, nil),
+		},
+		"PullPolicyNever": {
+			reason: "We should prefer never pulling to pulling.",
+			parents: []v1.Package{
+				parent(&ifNotPresent, nil, nil),
+				parent(&never, nil, nil),
+			},
+			want: dependency(&never, nil, nil),
+		},
+		"ActivationPolicyConflict": {
+			reason: "We should prefer Manual activation over Automatic activation.",
+			parents: []v1.Package{
+				parent(nil, &manual, nil),
+				parent(nil, &automatic, nil),
+			},
+			want: dependency(nil, &manual, nil),
+		},
+		"RevisionHistoryLimitConflict": {
+			reason: "We should prefer the revision history limit that keeps the most revisions.",
+			parents: []v1.Package{
+				parent(nil, nil, &one),
+				parent(nil, nil, &three),
+			},
+			want: dependency(nil, nil, &three),
+		},
+		"RevisionHistoryLimitDisabled": {
+			reason: "We should prefer a revision history limit of zero, which disables cleanup.",
+			parents: []v1.Package{
+				parent(nil, nil, &three),
+				parent(nil, nil, &zero),
+				parent(nil, nil, &one),
+			},
+			want: dependency(nil, nil, &zero),
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := &v1.Provider{}
+			inherit(logging.NewNopLogger(), got, tc.parents)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\ninherit(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
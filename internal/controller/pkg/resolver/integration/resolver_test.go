@@ -0,0 +1,167 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package integration
+
+import (
+	"context"
+	"testing"
+
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+
+	v1 "github.com/crossplane/crossplane/apis/pkg/v1"
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+)
+
+const finalizer = "lock.pkg.crossplane.io"
+
+func TestEmptyLockFinalizer(t *testing.T) {
+	c := setup(t)
+	ctx := context.Background()
+
+	setLock(ctx, t, c, []string{finalizer})
+
+	eventually(t, "Lock finalizer was not removed", func() (bool, error) {
+		l := &v1beta1.Lock{}
+		if err := c.Get(ctx, types.NamespacedName{Name: lockName}, l); err != nil {
+			return false, err
+		}
+		return len(l.GetFinalizers()) == 0, nil
+	})
+}
+
+func TestMissingDependency(t *testing.T) {
+	c := setup(t)
+	ctx := context.Background()
+
+	setLock(ctx, t, c, nil, v1beta1.LockPackage{
+		Name:    "cool-config",
+		Type:    v1beta1.ConfigurationPackageType,
+		Source:  "cool/config",
+		Version: "v0.1.0",
+		Dependencies: []v1beta1.Dependency{
+			{Package: "crossplane/provider-aws", Type: v1beta1.ProviderPackageType, Constraints: ">=v0.1.0"},
+		},
+	})
+
+	eventually(t, "missing dependency was not created", func() (bool, error) {
+		p := &v1.Provider{}
+		err := c.Get(ctx, types.NamespacedName{Name: "crossplane-provider-aws"}, p)
+		if kerrors.IsNotFound(err) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		if p.GetSource() != "crossplane/provider-aws:v0.2.0" {
+			t.Errorf("created Provider with package %q, want %q", p.GetSource(), "crossplane/provider-aws:v0.2.0")
+		}
+		return true, nil
+	})
+
+	l := &v1beta1.Lock{}
+	if err := c.Get(ctx, types.NamespacedName{Name: lockName}, l); err != nil {
+		t.Fatalf("cannot get Lock: %s", err)
+	}
+	if !contains(l.GetFinalizers(), finalizer) {
+		t.Errorf("Lock finalizers %v do not include %q", l.GetFinalizers(), finalizer)
+	}
+}
+
+func TestInvalidConstraint(t *testing.T) {
+	c := setup(t)
+	ctx := context.Background()
+
+	setLock(ctx, t, c, nil, v1beta1.LockPackage{
+		Name:    "invalid-config",
+		Type:    v1beta1.ConfigurationPackageType,
+		Source:  "cool/invalid-config",
+		Version: "v0.1.0",
+		Dependencies: []v1beta1.Dependency{
+			{Package: "crossplane/provider-gcp", Type: v1beta1.ProviderPackageType, Constraints: ">= not a constraint"},
+		},
+	})
+
+	eventually(t, "invalid constraint was not reported", lockReason(ctx, t, v1beta1.ReasonInvalidConstraint))
+
+	// The resolver records the dependency as unresolved in the same pass that
+	// declines to install it, so once it has done so the dependency must not
+	// exist.
+	eventually(t, "invalid constraint was not recorded", func() (bool, error) {
+		l := &v1beta1.Lock{}
+		if err := c.Get(ctx, types.NamespacedName{Name: lockName}, l); err != nil {
+			return false, err
+		}
+		for _, d := range l.Status.DependencyResolution {
+			if d.Package == "crossplane/provider-gcp" && d.Reason == v1beta1.ReasonInvalidConstraint {
+				return true, nil
+			}
+		}
+		return false, nil
+	})
+	err := c.Get(ctx, types.NamespacedName{Name: "crossplane-provider-gcp"}, &v1.Provider{})
+	if !kerrors.IsNotFound(err) {
+		t.Errorf("dependency with an invalid constraint was created, or could not be checked: %v", err)
+	}
+}
+
+func TestDependencyCycle(t *testing.T) {
+	c := setup(t)
+	ctx := context.Background()
+
+	setLock(ctx, t, c, nil,
+		v1beta1.LockPackage{
+			Name:         "cycle-a",
+			Type:         v1beta1.ConfigurationPackageType,
+			Source:       "cool/cycle-a",
+			Version:      "v0.1.0",
+			Dependencies: []v1beta1.Dependency{{Package: "cool/cycle-b", Type: v1beta1.ConfigurationPackageType, Constraints: ">=v0.1.0"}},
+		},
+		v1beta1.LockPackage{
+			Name:         "cycle-b",
+			Type:         v1beta1.ConfigurationPackageType,
+			Source:       "cool/cycle-b",
+			Version:      "v0.1.0",
+			Dependencies: []v1beta1.Dependency{{Package: "cool/cycle-a", Type: v1beta1.ConfigurationPackageType, Constraints: ">=v0.1.0"}},
+		},
+	)
+
+	eventually(t, "dependency cycle was not reported", lockReason(ctx, t, v1beta1.ReasonCycle))
+}
+
+// lockReason returns a function that reports whether the reason of the Lock's
+// DependenciesResolved condition is the supplied reason.
+func lockReason(ctx context.Context, t *testing.T, want xpv1.ConditionReason) func() (bool, error) {
+	return func() (bool, error) {
+		l := &v1beta1.Lock{}
+		if err := setup(t).Get(ctx, types.NamespacedName{Name: lockName}, l); err != nil {
+			return false, err
+		}
+		return l.GetCondition(v1beta1.TypeDependenciesResolved).Reason == want, nil
+	}
+}
+
+func contains(ss []string, s string) bool {
+	for _, o := range ss {
+		if o == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,173 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package integration runs the resolver controller against a real API server.
+// The API server and etcd binaries are found via KUBEBUILDER_ASSETS; the
+// suite is skipped if it is not set.
+package integration
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+
+	"github.com/crossplane/crossplane/apis"
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+	"github.com/crossplane/crossplane/internal/controller/pkg/resolver"
+	"github.com/crossplane/crossplane/internal/feature"
+	fakexpkg "github.com/crossplane/crossplane/internal/xpkg/fake"
+)
+
+const (
+	lockName = "lock"
+
+	timeout  = 30 * time.Second
+	interval = 100 * time.Millisecond
+)
+
+// tags are the tags of the repositories the resolver may fetch. Scenarios
+// depend on different repositories so that they don't interfere with each
+// other.
+var tags = map[string][]string{
+	"crossplane/provider-aws": {"v0.1.0", "v0.2.0", "v0.3.0-rc.0"},
+	"crossplane/provider-gcp": {"v0.1.0"},
+}
+
+// kube is a client of the test API server, or nil if the suite is skipped.
+var kube client.Client
+
+func TestMain(m *testing.M) {
+	if os.Getenv("KUBEBUILDER_ASSETS") == "" {
+		// Each test skips itself; see setup.
+		os.Exit(m.Run())
+	}
+	os.Exit(run(m))
+}
+
+// run the suite against a test API server with Crossplane's CRDs installed,
+// and a manager running the resolver controller.
+func run(m *testing.M) int {
+	env := &envtest.Environment{
+		CRDDirectoryPaths:     []string{filepath.Join("..", "..", "..", "..", "..", "cluster", "crds")},
+		ErrorIfCRDPathMissing: true,
+	}
+	cfg, err := env.Start()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "cannot start test API server: %s\n", err)
+		return 1
+	}
+	defer env.Stop() // nolint:errcheck
+
+	s := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(s); err != nil {
+		fmt.Fprintf(os.Stderr, "cannot add Kubernetes APIs to scheme: %s\n", err)
+		return 1
+	}
+	if err := apis.AddToScheme(s); err != nil {
+		fmt.Fprintf(os.Stderr, "cannot add Crossplane APIs to scheme: %s\n", err)
+		return 1
+	}
+
+	mgr, err := ctrl.NewManager(cfg, ctrl.Options{Scheme: s, MetricsBindAddress: "0"})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "cannot create manager: %s\n", err)
+		return 1
+	}
+	if err := resolver.Setup(mgr, logging.NewNopLogger(), &feature.Flags{}, "crossplane-system",
+		resolver.WithFetcher(fakexpkg.NewTagFetcher(tags)),
+	); err != nil {
+		fmt.Fprintf(os.Stderr, "cannot setup resolver controller: %s\n", err)
+		return 1
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		if err := mgr.Start(ctx); err != nil {
+			fmt.Fprintf(os.Stderr, "cannot start manager: %s\n", err)
+		}
+	}()
+
+	kube = mgr.GetClient()
+	return m.Run()
+}
+
+// setup skips the calling test if the suite is not running against a test
+// API server, and otherwise returns a client of it.
+func setup(t *testing.T) client.Client {
+	t.Helper()
+	if kube == nil {
+		t.Skip("KUBEBUILDER_ASSETS is not set; skipping resolver integration tests")
+	}
+	return kube
+}
+
+// setLock creates or updates the Lock so that it contains the supplied
+// packages, and has the supplied finalizers.
+func setLock(ctx context.Context, t *testing.T, c client.Client, finalizers []string, pkgs ...v1beta1.LockPackage) {
+	t.Helper()
+	err := wait.PollImmediate(interval, timeout, func() (bool, error) {
+		l := &v1beta1.Lock{}
+		err := c.Get(ctx, types.NamespacedName{Name: lockName}, l)
+		if kerrors.IsNotFound(err) {
+			l.SetName(lockName)
+			l.SetFinalizers(finalizers)
+			l.Packages = pkgs
+			return false, c.Create(ctx, l)
+		}
+		if err != nil {
+			return false, err
+		}
+		l.SetFinalizers(finalizers)
+		l.Packages = pkgs
+		err = c.Update(ctx, l)
+		// We may race the resolver, which also updates the Lock.
+		return err == nil, client.IgnoreNotFound(ignoreConflict(err))
+	})
+	if err != nil {
+		t.Fatalf("cannot set Lock: %s", err)
+	}
+}
+
+func ignoreConflict(err error) error {
+	if kerrors.IsConflict(err) {
+		return nil
+	}
+	return err
+}
+
+// eventually polls the supplied function until it returns true, failing the
+// calling test if it doesn't before the timeout.
+func eventually(t *testing.T, what string, fn func() (bool, error)) {
+	t.Helper()
+	if err := wait.PollImmediate(interval, timeout, fn); err != nil {
+		t.Fatalf("%s: %s", what, err)
+	}
+}
@@ -0,0 +1,69 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"fmt"
+
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+	"github.com/crossplane/crossplane/internal/xpkg"
+)
+
+const msgResolvingLayerFmt = "waiting for %d missing dependencies to be installed; resolving layer %d of %d"
+
+// layer returns the supplied planned installs that are in the shallowest
+// layer of missing dependencies, and those that are deferred until it is
+// installed. It records which layer is being resolved in the supplied Lock's
+// status. A planned install's layer is its depth. A package in a deeper layer
+// may be constrained by a package in a shallower layer that isn't installed
+// yet, so we don't install it until every shallower layer is installed.
+// Planned installs of unknown depth are never deferred.
+func layer(lock *v1beta1.Lock, plan []xpkg.PlannedInstall) (current, deferred []xpkg.PlannedInstall) {
+	shallowest, deepest := 0, 0
+	for _, p := range plan {
+		if p.Depth == 0 {
+			continue
+		}
+		if shallowest == 0 || p.Depth < shallowest {
+			shallowest = p.Depth
+		}
+		if p.Depth > deepest {
+			deepest = p.Depth
+		}
+	}
+	lock.Status.ResolvingLayer = int64(shallowest)
+	lock.Status.DependencyLayers = int64(deepest)
+
+	current = make([]xpkg.PlannedInstall, 0, len(plan))
+	for _, p := range plan {
+		if p.Depth > shallowest {
+			deferred = append(deferred, p)
+			continue
+		}
+		current = append(current, p)
+	}
+	return current, deferred
+}
+
+// layerMessage describes the missing dependencies of the supplied Lock, and
+// which layer of them is being resolved if any deeper layer was deferred.
+func layerMessage(lock *v1beta1.Lock) string {
+	if lock.Status.ResolvingLayer < lock.Status.DependencyLayers {
+		return fmt.Sprintf(msgResolvingLayerFmt, lock.Status.MissingDependencies, lock.Status.ResolvingLayer, lock.Status.DependencyLayers)
+	}
+	return fmt.Sprintf(msgMissingDependenciesFmt, lock.Status.MissingDependencies)
+}
@@ -0,0 +1,179 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/crossplane/crossplane-runtime/pkg/resource/fake"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	v1 "github.com/crossplane/crossplane/apis/pkg/v1"
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+	"github.com/crossplane/crossplane/internal/dag"
+	fakexpkg "github.com/crossplane/crossplane/internal/xpkg/fake"
+)
+
+func TestReconcileLayers(t *testing.T) {
+	// An application Configuration that depends on a network Configuration,
+	// which depends on a base network Configuration, which depends on
+	// provider-aws. The application also depends on a platform
+	// Configuration that isn't installed yet, and that constrains
+	// provider-aws more tightly than the base network Configuration does.
+	app := v1beta1.LockPackage{Name: "app", Type: v1beta1.ConfigurationPackageType, Source: "cool/app", Version: "v1.0.0", Dependencies: []v1beta1.Dependency{
+		{Package: "cool/network", Type: v1beta1.ConfigurationPackageType, Constraints: ">=v1.0.0"},
+		{Package: "cool/platform", Type: v1beta1.ConfigurationPackageType, Constraints: ">=v1.0.0"},
+	}}
+	network := v1beta1.LockPackage{Name: "network", Type: v1beta1.ConfigurationPackageType, Source: "cool/network", Version: "v1.0.0", Dependencies: []v1beta1.Dependency{
+		{Package: "cool/network-base", Type: v1beta1.ConfigurationPackageType, Constraints: ">=v1.0.0"},
+	}}
+	networkBase := v1beta1.LockPackage{Name: "network-base", Type: v1beta1.ConfigurationPackageType, Source: "cool/network-base", Version: "v1.0.0", Dependencies: []v1beta1.Dependency{
+		{Package: "crossplane/provider-aws", Type: v1beta1.ProviderPackageType, Constraints: ">=v0.20.0"},
+	}}
+	platform := v1beta1.LockPackage{Name: "platform", Type: v1beta1.ConfigurationPackageType, Source: "cool/platform", Version: "v1.0.0", Dependencies: []v1beta1.Dependency{
+		{Package: "cool/platform-base", Type: v1beta1.ConfigurationPackageType, Constraints: ">=v1.0.0"},
+		{Package: "crossplane/provider-aws", Type: v1beta1.ProviderPackageType, Constraints: "<v0.22.0"},
+	}}
+	platformBase := v1beta1.LockPackage{Name: "platform-base", Type: v1beta1.ConfigurationPackageType, Source: "cool/platform-base", Version: "v1.0.0", Dependencies: []v1beta1.Dependency{
+		{Package: "crossplane/provider-gcp", Type: v1beta1.ProviderPackageType, Constraints: ">=v0.20.0"},
+	}}
+
+	stored := &v1beta1.Lock{}
+	stored.SetName("lock")
+
+	var created []string
+	mgr := &fake.Manager{
+		Client: &test.MockClient{
+			MockGet: func(_ context.Context, key client.ObjectKey, o client.Object) error {
+				if l, ok := o.(*v1beta1.Lock); ok {
+					stored.DeepCopyInto(l)
+					return nil
+				}
+				return kerrors.NewNotFound(schema.GroupResource{}, key.Name)
+			},
+			MockList: test.NewMockListFn(nil),
+			MockCreate: func(_ context.Context, o client.Object, _ ...client.CreateOption) error {
+				created = append(created, o.(v1.Package).GetSource())
+				return nil
+			},
+			MockUpdate: test.NewMockUpdateFn(nil),
+			MockStatusUpdate: func(_ context.Context, o client.Object, _ ...client.UpdateOption) error {
+				o.(*v1beta1.Lock).Status.DeepCopyInto(&stored.Status)
+				return nil
+			},
+		},
+	}
+
+	r := NewReconciler(mgr,
+		WithFetcher(fakexpkg.NewTagFetcher(map[string][]string{
+			"cool/platform":           {"v1.0.0"},
+			"cool/platform-base":      {"v1.0.0"},
+			"crossplane/provider-aws": {"v0.20.0", "v0.21.0", "v0.22.0"},
+			"crossplane/provider-gcp": {"v0.20.0"},
+		})),
+		WithNewDagFn(dag.NewMapDag),
+	)
+
+	type want struct {
+		created []string
+		layer   int64
+		layers  int64
+		message string
+	}
+
+	// Each step reconciles the Lock after the packages created by the steps
+	// before it have added themselves to the Lock.
+	steps := []struct {
+		name   string
+		reason string
+		pkgs   []v1beta1.LockPackage
+		want   want
+	}{
+		{
+			name:   "FirstLayer",
+			reason: "We should only create the shallowest layer of missing dependencies.",
+			pkgs:   []v1beta1.LockPackage{app, network, networkBase},
+			want: want{
+				created: []string{"cool/platform:v1.0.0"},
+				layer:   1,
+				layers:  3,
+				message: "waiting for 2 missing dependencies to be installed; resolving layer 1 of 3",
+			},
+		},
+		{
+			name:   "SecondLayer",
+			reason: "We should create the next layer once the packages in the shallower layer have added themselves to the Lock.",
+			pkgs:   []v1beta1.LockPackage{app, network, networkBase, platform},
+			want: want{
+				created: []string{"cool/platform-base:v1.0.0"},
+				layer:   2,
+				layers:  3,
+				message: "waiting for 2 missing dependencies to be installed; resolving layer 2 of 3",
+			},
+		},
+		{
+			name:   "ThirdLayer",
+			reason: "We should create the deepest layer with versions that satisfy the constraints of every package in the shallower layers.",
+			pkgs:   []v1beta1.LockPackage{app, network, networkBase, platform, platformBase},
+			want: want{
+				created: []string{"crossplane/provider-aws:v0.21.0", "crossplane/provider-gcp:v0.20.0"},
+				layer:   3,
+				layers:  3,
+				message: "waiting for 2 missing dependencies to be installed",
+			},
+		},
+		{
+			name:   "Resolved",
+			reason: "We should not record a layer once no dependencies are missing.",
+			pkgs: []v1beta1.LockPackage{app, network, networkBase, platform, platformBase,
+				{Name: "provider-aws", Type: v1beta1.ProviderPackageType, Source: "crossplane/provider-aws", Version: "v0.21.0"},
+				{Name: "provider-gcp", Type: v1beta1.ProviderPackageType, Source: "crossplane/provider-gcp", Version: "v0.20.0"},
+			},
+		},
+	}
+
+	for _, s := range steps {
+		stored.Packages = s.pkgs
+		created = nil
+
+		if _, err := r.Reconcile(context.Background(), reconcile.Request{}); err != nil {
+			t.Fatalf("\n%s: %s\nr.Reconcile(...): %s", s.name, s.reason, err)
+		}
+		if diff := cmp.Diff(s.want.created, created); diff != "" {
+			t.Errorf("\n%s: %s\nr.Reconcile(...): -want created, +got created:\n%s", s.name, s.reason, diff)
+		}
+		if diff := cmp.Diff(s.want.layer, stored.Status.ResolvingLayer); diff != "" {
+			t.Errorf("\n%s: %s\nr.Reconcile(...): -want resolving layer, +got resolving layer:\n%s", s.name, s.reason, diff)
+		}
+		if diff := cmp.Diff(s.want.layers, stored.Status.DependencyLayers); diff != "" {
+			t.Errorf("\n%s: %s\nr.Reconcile(...): -want layers, +got layers:\n%s", s.name, s.reason, diff)
+		}
+		if s.want.message == "" {
+			continue
+		}
+		if diff := cmp.Diff(s.want.message, stored.Status.GetCondition(v1beta1.TypeDependenciesResolved).Message); diff != "" {
+			t.Errorf("\n%s: %s\nr.Reconcile(...): -want condition message, +got condition message:\n%s", s.name, s.reason, diff)
+		}
+	}
+}
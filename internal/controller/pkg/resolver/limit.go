@@ -0,0 +1,83 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"context"
+	"fmt"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+	"github.com/crossplane/crossplane/internal/xpkg"
+)
+
+const (
+	errCountAutoInstalled  = "cannot count auto-installed packages"
+	errAutoInstallLimitFmt = "auto-install limit reached: %d/%d"
+)
+
+// WithMaxAutoInstall specifies the maximum number of packages the Reconciler
+// may install to satisfy missing dependencies. Only packages labeled as
+// auto-installed count toward the limit, so packages installed manually don't
+// consume it. Once the limit is reached missing dependencies are reported,
+// but not installed. A limit of 0 means unlimited.
+func WithMaxAutoInstall(n int) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.maxAutoInstall = n
+	}
+}
+
+// An installBudget tracks how many more packages may be auto-installed.
+type installBudget struct {
+	used int
+	max  int
+}
+
+// exhausted returns true if no more packages may be auto-installed.
+func (b *installBudget) exhausted() bool {
+	return b.max > 0 && b.used >= b.max
+}
+
+// budget returns the number of packages the Reconciler may auto-install for
+// the supplied Lock, given the supplied limit. A limit of 0 means unlimited.
+func (r *Reconciler) budget(ctx context.Context, lock *v1beta1.Lock, max int) (*installBudget, error) {
+	b := &installBudget{max: max}
+	if b.max <= 0 {
+		return b, nil
+	}
+	pkgs, err := r.autoInstalled(ctx, lock)
+	if err != nil {
+		return nil, errors.Wrap(err, errCountAutoInstalled)
+	}
+	b.used = len(pkgs)
+	return b, nil
+}
+
+// limited reports that the supplied planned install was not installed because
+// the supplied budget is exhausted. We'll be queued when an auto-installed
+// package is deleted, so there's no need to requeue.
+func (r *Reconciler) limited(log logging.Logger, lock *v1beta1.Lock, p xpkg.PlannedInstall, b *installBudget) (xpv1.Condition, RequeueReason, error) {
+	err := errors.Errorf(errDependencyFmt, fmt.Sprintf(errAutoInstallLimitFmt, b.used, b.max), p.Dependency.Package, p.Dependency.Constraints)
+	log.Debug(errInstallDependency, "error", err)
+	r.record.Event(lock, event.Warning(reasonAutoInstallLimit, err))
+	r.metrics.failures.WithLabelValues(failureAutoInstallLimit).Inc()
+	return v1beta1.AutoInstallLimitReached(), "", err
+}
@@ -0,0 +1,180 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/fake"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	v1 "github.com/crossplane/crossplane/apis/pkg/v1"
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+	"github.com/crossplane/crossplane/internal/dag"
+	fakexpkg "github.com/crossplane/crossplane/internal/xpkg/fake"
+)
+
+func TestReconcileAutoInstallLimit(t *testing.T) {
+	// The Lock has three missing dependencies. They're installed in order of
+	// their identifiers, not the order in which they're declared.
+	deps := []v1beta1.Dependency{
+		{Package: "crossplane/provider-aws", Type: v1beta1.ProviderPackageType, Constraints: ">=v0.20.0"},
+		{Package: "crossplane/provider-gcp", Type: v1beta1.ProviderPackageType, Constraints: ">=v0.20.0"},
+		{Package: "crossplane/provider-azure", Type: v1beta1.ProviderPackageType, Constraints: ">=v0.20.0"},
+	}
+	autoInstalled := func(n int) []v1.Provider {
+		out := make([]v1.Provider, n)
+		for i := range out {
+			out[i] = v1.Provider{ObjectMeta: metav1.ObjectMeta{
+				Name:   fmt.Sprintf("provider-%d", i),
+				Labels: map[string]string{LabelAutoInstalled: "true"},
+			}}
+		}
+		return out
+	}
+
+	type args struct {
+		max      int
+		existing int
+	}
+	type want struct {
+		created []string
+		reason  xpv1.ConditionReason
+		limited []string
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"Unlimited": {
+			reason: "We should install every missing dependency if there is no limit.",
+			args:   args{max: 0, existing: 10},
+			want: want{
+				created: []string{"crossplane-provider-aws", "crossplane-provider-azure", "crossplane-provider-gcp"},
+				reason:  v1beta1.ReasonMissingDependency,
+			},
+		},
+		"UnderCap": {
+			reason: "We should install every missing dependency if doing so does not exceed the limit.",
+			args:   args{max: 5, existing: 2},
+			want: want{
+				created: []string{"crossplane-provider-aws", "crossplane-provider-azure", "crossplane-provider-gcp"},
+				reason:  v1beta1.ReasonMissingDependency,
+			},
+		},
+		"AtCap": {
+			reason: "We should not install any missing dependency if the limit has been reached.",
+			args:   args{max: 2, existing: 2},
+			want: want{
+				reason: v1beta1.ReasonLimitReached,
+				limited: []string{
+					`auto-install limit reached: 2/2: dependency crossplane/provider-aws with constraints ">=v0.20.0"`,
+					`auto-install limit reached: 2/2: dependency crossplane/provider-azure with constraints ">=v0.20.0"`,
+					`auto-install limit reached: 2/2: dependency crossplane/provider-gcp with constraints ">=v0.20.0"`,
+				},
+			},
+		},
+		"OverCap": {
+			reason: "We should install missing dependencies until the limit is reached, and report the rest.",
+			args:   args{max: 3, existing: 1},
+			want: want{
+				created: []string{"crossplane-provider-aws", "crossplane-provider-azure"},
+				reason:  v1beta1.ReasonLimitReached,
+				limited: []string{
+					`auto-install limit reached: 3/3: dependency crossplane/provider-gcp with constraints ">=v0.20.0"`,
+				},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var created []string
+			var status *v1beta1.Lock
+			rec := &recorder{}
+			mgr := &fake.Manager{
+				Client: &test.MockClient{
+					MockGet: test.NewMockGetFn(nil, func(o client.Object) error {
+						o.SetName("lock")
+						o.(*v1beta1.Lock).Packages = []v1beta1.LockPackage{{Source: "cool/config", Dependencies: deps}}
+						return nil
+					}),
+					MockList: test.NewMockListFn(nil, func(o client.ObjectList) error {
+						if l, ok := o.(*v1.ProviderList); ok {
+							l.Items = autoInstalled(tc.args.existing)
+						}
+						return nil
+					}),
+					MockCreate: func(_ context.Context, o client.Object, _ ...client.CreateOption) error {
+						// Created packages must count toward any limit.
+						if tc.args.max > 0 && o.GetLabels()[LabelAutoInstalled] != "true" {
+							t.Errorf("\n%s\nr.Reconcile(...): created package %s is not labeled as auto-installed", tc.reason, o.GetName())
+						}
+						created = append(created, o.GetName())
+						return nil
+					},
+					MockUpdate: test.NewMockUpdateFn(nil),
+					MockStatusUpdate: test.NewMockStatusUpdateFn(nil, func(o client.Object) error {
+						status = o.(*v1beta1.Lock)
+						return nil
+					}),
+				},
+			}
+			r := NewReconciler(mgr,
+				WithMaxAutoInstall(tc.args.max),
+				WithRecorder(rec),
+				WithFetcher(&fakexpkg.MockFetcher{MockTags: fakexpkg.NewMockTagsFn([]string{"v0.20.0"}, nil)}),
+				WithNewDagFn(dag.NewMapDag),
+			)
+			res, err := r.Reconcile(context.Background(), reconcile.Request{})
+			if err != nil {
+				t.Fatalf("\n%s\nr.Reconcile(...): %s", tc.reason, err)
+			}
+
+			// We're queued when an auto-installed package is deleted.
+			if tc.want.limited != nil && res.RequeueAfter != 0 {
+				t.Errorf("\n%s\nr.Reconcile(...): expected no requeue, got %s", tc.reason, res.RequeueAfter)
+			}
+			if diff := cmp.Diff(tc.want.created, created); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want created, +got created:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.reason, status.GetCondition(v1beta1.TypeDependenciesResolved).Reason); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want condition reason, +got condition reason:\n%s", tc.reason, diff)
+			}
+
+			var limited []string
+			for i, reason := range rec.reasons {
+				if reason == reasonAutoInstallLimit {
+					limited = append(limited, rec.messages[i])
+				}
+			}
+			if diff := cmp.Diff(tc.want.limited, limited); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want limit events, +got limit events:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	metricsNamespace = "crossplane"
+	metricsSubsystem = "pkg_resolver"
+)
+
+// Resolution failure reasons, used to label metrics.
+const (
+	failureInvalidConstraint = "invalid_constraint"
+	failureInvalidDependency = "invalid_dependency"
+	failureUnsupportedType   = "unsupported_type"
+	failureNoValidVersion    = "no_valid_version"
+	failureFetchError        = "fetch_error"
+	failureDigestMismatch    = "digest_mismatch"
+	failureCreateError       = "create_error"
+	failureAutoInstallLimit  = "auto_install_limit"
+	failureAPIGroupConflict  = "api_group_conflict"
+	failureIncompatible      = "incompatible_crossplane_version"
+	failureVersionGroup      = "version_group_conflict"
+	failureBlockedByPolicy   = "blocked_by_policy"
+)
+
+// Tag cache results, used to label metrics.
+const (
+	cacheHit  = "hit"
+	cacheMiss = "miss"
+)
+
+// Metrics recorded by the Reconciler. Metrics is a prometheus.Collector, and
+// must be registered with a prometheus.Registerer to be exposed.
+type Metrics struct {
+	created  *prometheus.CounterVec
+	failures *prometheus.CounterVec
+	fetch    prometheus.Histogram
+	missing  *prometheus.GaugeVec
+	cache    *prometheus.CounterVec
+}
+
+// NewMetrics returns a new set of resolver metrics.
+func NewMetrics() *Metrics {
+	return &Metrics{
+		created: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "dependencies_created_total",
+			Help:      "Total number of dependency packages created, by package type.",
+		}, []string{"type"}),
+		failures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "resolution_failures_total",
+			Help:      "Total number of failures to resolve a dependency, by reason.",
+		}, []string{"reason"}),
+		fetch: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "fetch_tags_duration_seconds",
+			Help:      "Time taken to fetch the tags of a dependency's repository.",
+			Buckets:   prometheus.DefBuckets,
+		}),
+		missing: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "missing_dependencies",
+			Help:      "Number of dependencies that are not yet installed, by lock.",
+		}, []string{"lock"}),
+		cache: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "tag_cache_requests_total",
+			Help:      "Total number of tag cache lookups, by result.",
+		}, []string{"result"}),
+	}
+}
+
+// Describe sends the descriptors of all resolver metrics to the supplied
+// channel.
+func (m *Metrics) Describe(ch chan<- *prometheus.Desc) {
+	m.created.Describe(ch)
+	m.failures.Describe(ch)
+	m.fetch.Describe(ch)
+	m.missing.Describe(ch)
+	m.cache.Describe(ch)
+}
+
+// Collect sends all resolver metrics to the supplied channel.
+func (m *Metrics) Collect(ch chan<- prometheus.Metric) {
+	m.created.Collect(ch)
+	m.failures.Collect(ch)
+	m.fetch.Collect(ch)
+	m.missing.Collect(ch)
+	m.cache.Collect(ch)
+}
+
+// WithMetrics specifies the metrics the Reconciler should record.
+func WithMetrics(m *Metrics) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.metrics = m
+	}
+}
@@ -0,0 +1,170 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/fake"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+	"github.com/crossplane/crossplane/internal/dag"
+	fakedag "github.com/crossplane/crossplane/internal/dag/fake"
+	fakexpkg "github.com/crossplane/crossplane/internal/xpkg/fake"
+)
+
+func TestMetrics(t *testing.T) {
+	errBoom := errors.New("boom")
+
+	type want struct {
+		created  map[string]float64
+		failures map[string]float64
+		missing  float64
+		fetches  int
+	}
+
+	cases := map[string]struct {
+		reason  string
+		deps    []dag.ImpliedNode
+		tags    []string
+		tagsErr error
+		create  error
+		want    want
+	}{
+		"Created": {
+			reason: "We should count the dependencies we create by type.",
+			deps: []dag.ImpliedNode{
+				{Node: &v1beta1.Dependency{Package: "hasheddan/config-nop-c", Constraints: ">v1.0.0", Type: v1beta1.ConfigurationPackageType}},
+				{Node: &v1beta1.Dependency{Package: "hasheddan/provider-nop-c", Constraints: ">v1.0.0", Type: v1beta1.ProviderPackageType}},
+			},
+			tags: []string{"v1.2.0"},
+			want: want{
+				created: map[string]float64{"Configuration": 1, "Provider": 1},
+				missing: 2,
+				fetches: 2,
+			},
+		},
+		"Failures": {
+			reason: "We should count resolution failures by reason.",
+			deps: []dag.ImpliedNode{
+				{Node: &v1beta1.Dependency{Package: "hasheddan/config-nop-c", Constraints: "not a constraint", Type: v1beta1.ConfigurationPackageType}},
+				{Node: &v1beta1.Dependency{Package: "hasheddan/config-nop-d", Constraints: ">v1.0.0", Type: v1beta1.ConfigurationPackageType}},
+			},
+			tagsErr: errBoom,
+			want: want{
+				failures: map[string]float64{failureInvalidConstraint: 1, failureFetchError: 1},
+				missing:  2,
+				fetches:  1,
+			},
+		},
+		"NoValidVersion": {
+			reason: "We should count failures to find a valid version.",
+			deps: []dag.ImpliedNode{
+				{Node: &v1beta1.Dependency{Package: "hasheddan/config-nop-c", Constraints: ">v2.0.0", Type: v1beta1.ConfigurationPackageType}},
+			},
+			tags: []string{"v1.2.0"},
+			want: want{
+				failures: map[string]float64{failureNoValidVersion: 1},
+				missing:  1,
+				fetches:  1,
+			},
+		},
+		"CreateError": {
+			reason: "We should count failures to create a dependency.",
+			deps: []dag.ImpliedNode{
+				{Node: &v1beta1.Dependency{Package: "hasheddan/config-nop-c", Constraints: ">v1.0.0", Type: v1beta1.ConfigurationPackageType}},
+			},
+			tags:   []string{"v1.2.0"},
+			create: errBoom,
+			want: want{
+				failures: map[string]float64{failureCreateError: 1},
+				missing:  1,
+				fetches:  1,
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			m := NewMetrics()
+			if err := prometheus.NewPedanticRegistry().Register(m); err != nil {
+				t.Fatalf("Register(...): %s", err)
+			}
+
+			mgr := &fake.Manager{
+				Client: &test.MockClient{
+					MockGet: test.NewMockGetFn(nil, func(o client.Object) error {
+						l := o.(*v1beta1.Lock)
+						l.ObjectMeta = metav1.ObjectMeta{Name: "lock"}
+						l.Packages = append(l.Packages, v1beta1.LockPackage{Name: "cool-package", Source: "cool-repo/cool-image"})
+						return nil
+					}),
+					MockList:         test.NewMockListFn(nil),
+					MockCreate:       test.NewMockCreateFn(tc.create),
+					MockUpdate:       test.NewMockUpdateFn(nil),
+					MockStatusUpdate: test.NewMockStatusUpdateFn(nil),
+				},
+			}
+			r := NewReconciler(mgr,
+				WithMetrics(m),
+				WithNewDagFn(func() dag.DAG {
+					return &fakedag.MockDag{
+						MockInit:           func(_ []dag.Node, _ ...dag.NodeFn) ([]dag.ImpliedNode, error) { return tc.deps, nil },
+						MockNodeDependents: func(_ string) ([]dag.Node, error) { return nil, nil },
+
+						MockSort: func() ([]string, error) { return nil, nil },
+					}
+				}),
+				WithFetcher(&fakexpkg.MockFetcher{MockTags: fakexpkg.NewMockTagsFn(tc.tags, tc.tagsErr)}),
+			)
+			_, _ = r.Reconcile(context.Background(), reconcile.Request{})
+
+			for typ, want := range tc.want.created {
+				if diff := cmp.Diff(want, testutil.ToFloat64(m.created.WithLabelValues(typ))); diff != "" {
+					t.Errorf("\n%s\ncreated{type=%q}: -want, +got:\n%s", tc.reason, typ, diff)
+				}
+			}
+			if diff := cmp.Diff(len(tc.want.created), testutil.CollectAndCount(m.created)); diff != "" {
+				t.Errorf("\n%s\ncreated: -want series, +got series:\n%s", tc.reason, diff)
+			}
+			for reason, want := range tc.want.failures {
+				if diff := cmp.Diff(want, testutil.ToFloat64(m.failures.WithLabelValues(reason))); diff != "" {
+					t.Errorf("\n%s\nfailures{reason=%q}: -want, +got:\n%s", tc.reason, reason, diff)
+				}
+			}
+			if diff := cmp.Diff(len(tc.want.failures), testutil.CollectAndCount(m.failures)); diff != "" {
+				t.Errorf("\n%s\nfailures: -want series, +got series:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.missing, testutil.ToFloat64(m.missing.WithLabelValues("lock"))); diff != "" {
+				t.Errorf("\n%s\nmissing: -want, +got:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(float64(tc.want.fetches), testutil.ToFloat64(m.cache.WithLabelValues(cacheMiss))); diff != "" {
+				t.Errorf("\n%s\ncache{result=miss}: -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,151 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"context"
+
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/crossplane-runtime/pkg/meta"
+
+	v1 "github.com/crossplane/crossplane/apis/pkg/v1"
+	"github.com/crossplane/crossplane/internal/xpkg"
+	"github.com/crossplane/crossplane/internal/xpkg/canonical"
+)
+
+// LabelRepository is added to packages the resolver installs. Its value is
+// derived from the canonical repository the package is installed from, so
+// that packages from the same repository can be listed before one is
+// created. We find the packages we created by this label rather than by their
+// names, so that changing how packages are named doesn't orphan them. Its value
+// must therefore never change.
+const LabelRepository = "pkg.crossplane.io/repository"
+
+const (
+	errGetExistingPackage   = "cannot get existing package"
+	errListExistingPackages = "cannot list existing packages from the same repository"
+	errNameCollisionFmt     = "packages named %s and %s already exist but are not installed from %s"
+)
+
+// WithAPIReader specifies how the Reconciler should read packages that its
+// cached client may not yet know about, e.g. because they were only just
+// created.
+func WithAPIReader(c client.Reader) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.reader = c
+	}
+}
+
+// create the supplied package. Its name is derived from its repository, and
+// different repositories may be derived to the same name. If a package with
+// the supplied name already exists and is installed from the same repository
+// it is being installed by someone else, and we report that it was not
+// created. If it is installed from a different repository we create the
+// package with a name that is unique to its repository instead; see
+// xpkg.HashedPackageName.
+//
+// The Lock may not yet include a package we created in a previous pass, so
+// we first look for a package we created from the same repository. We read
+// a package that already exists from the API server rather than our cache,
+// which may not yet know about it.
+func (r *Reconciler) create(ctx context.Context, log logging.Logger, pack v1.Package) (bool, error) {
+	// A hung request shouldn't prevent us from creating other packages.
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	repo := r.sourceRepository(pack.GetSource())
+	meta.AddLabels(pack, map[string]string{LabelRepository: xpkg.ToUniqueDNSLabel(repo)})
+
+	n, err := r.created(ctx, pack, repo)
+	if err != nil {
+		return false, errors.Wrap(err, errListExistingPackages)
+	}
+	if n != "" {
+		log.Debug("Dependency package already exists", "name", n, "repository", repo)
+		pack.SetName(n)
+		return false, nil
+	}
+
+	names := []string{pack.GetName(), xpkg.HashedPackageName(repo)}
+	for _, n := range names {
+		pack.SetName(n)
+		err := r.client.Create(ctx, pack)
+		if err == nil {
+			return true, nil
+		}
+		if !kerrors.IsAlreadyExists(err) {
+			return false, err
+		}
+
+		existing := pack.DeepCopyObject().(v1.Package)
+		if err := r.reader.Get(ctx, types.NamespacedName{Name: n}, existing); err != nil {
+			return false, errors.Wrap(err, errGetExistingPackage)
+		}
+		if r.sourceRepository(existing.GetSource()) == repo {
+			log.Debug("Dependency package already exists", "name", n, "source", existing.GetSource())
+			return false, nil
+		}
+		log.Debug("Dependency package name is used by a package from another repository", "name", n, "repository", repo, "source", existing.GetSource())
+	}
+	return false, permanent(errors.Errorf(errNameCollisionFmt, names[0], names[1], repo))
+}
+
+// created returns the name of a package of the same kind as the supplied
+// package that we created from the supplied canonical repository for the same
+// Lock, if any.
+func (r *Reconciler) created(ctx context.Context, pack v1.Package, repo string) (string, error) {
+	sel := client.MatchingLabels{LabelRepository: xpkg.ToUniqueDNSLabel(repo)}
+	pkgs := []v1.Package{}
+	switch pack.(type) {
+	case *v1.Configuration:
+		l := &v1.ConfigurationList{}
+		if err := r.client.List(ctx, l, sel); err != nil {
+			return "", err
+		}
+		for i := range l.Items {
+			pkgs = append(pkgs, &l.Items[i])
+		}
+	case *v1.Provider:
+		l := &v1.ProviderList{}
+		if err := r.client.List(ctx, l, sel); err != nil {
+			return "", err
+		}
+		for i := range l.Items {
+			pkgs = append(pkgs, &l.Items[i])
+		}
+	}
+	for _, p := range pkgs {
+		if lockOf(p) != lockOf(pack) {
+			continue
+		}
+		if r.sourceRepository(p.GetSource()) == repo {
+			return p.GetName(), nil
+		}
+	}
+	return "", nil
+}
+
+// sourceRepository returns the canonical repository of the supplied package
+// source, qualified with the default registry if it does not specify one.
+func (r *Reconciler) sourceRepository(source string) string {
+	return canonical.Source(qualify(r.registry, source))
+}
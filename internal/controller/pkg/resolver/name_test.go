@@ -0,0 +1,201 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	v1 "github.com/crossplane/crossplane/apis/pkg/v1"
+	"github.com/crossplane/crossplane/internal/xpkg"
+)
+
+func TestCreate(t *testing.T) {
+	errBoom := errors.New("boom")
+	unique := xpkg.HashedPackageName("index.docker.io/acme/provider-sql")
+
+	type args struct {
+		// existing maps the name of each existing package to its source.
+		existing map[string]string
+		get      error
+
+		// listed maps the name of each package our cache lists from the
+		// same repository to its source.
+		listed map[string]string
+		list   error
+	}
+	type want struct {
+		created bool
+		name    string
+		err     error
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"Created": {
+			reason: "We should create a package with the supplied name if none exists.",
+			want:   want{created: true, name: "acme-provider-sql"},
+		},
+		"AlreadyExists": {
+			reason: "We should not treat a package from the same repository that already exists as an error.",
+			args: args{
+				existing: map[string]string{"acme-provider-sql": "index.docker.io/acme/provider-sql:v0.1.0"},
+			},
+			want: want{name: "acme-provider-sql"},
+		},
+		"StaleCache": {
+			reason: "We should not treat a package we created that our cache doesn't yet know about as an error.",
+			args: args{
+				existing: map[string]string{"acme-provider-sql": "acme/provider-sql:v0.2.0"},
+			},
+			want: want{name: "acme-provider-sql"},
+		},
+		"Listed": {
+			reason: "We should not create a package if our cache lists one we created from the same repository.",
+			args: args{
+				listed: map[string]string{unique: "acme/provider-sql:v0.1.0"},
+			},
+			want: want{name: unique},
+		},
+		"ListedLegacyName": {
+			reason: "We should not create a package if our cache lists one we created from the same repository under a name an earlier naming scheme derived.",
+			args: args{
+				listed: map[string]string{xpkg.ToUniqueDNSLabel("index.docker.io/acme/provider-sql"): "acme/provider-sql:v0.1.0"},
+			},
+			want: want{name: xpkg.ToUniqueDNSLabel("index.docker.io/acme/provider-sql")},
+		},
+		"ListedOtherRepository": {
+			reason: "We should create a package if the packages our cache lists are not from the same repository.",
+			args: args{
+				listed: map[string]string{"acme-provider-sql": "other/acme/provider-sql:v0.1.0"},
+			},
+			want: want{created: true, name: "acme-provider-sql"},
+		},
+		"ErrList": {
+			reason: "We should return an error if we cannot list packages from the same repository.",
+			args: args{
+				list: errBoom,
+			},
+			want: want{name: "acme-provider-sql", err: errors.Wrap(errBoom, errListExistingPackages)},
+		},
+		"AliasAlreadyExists": {
+			reason: "We should not treat a package installed from an alias of the same repository that already exists as an error.",
+			args: args{
+				existing: map[string]string{"acme-provider-sql": "docker.io/acme/provider-sql:v0.1.0"},
+			},
+			want: want{name: "acme-provider-sql"},
+		},
+		"Collision": {
+			reason: "We should create a package with a name unique to its repository if a package from another repository has its name.",
+			args: args{
+				existing: map[string]string{"acme-provider-sql": "other/acme/provider-sql:v0.1.0"},
+			},
+			want: want{created: true, name: unique},
+		},
+		"CollisionAlreadyExists": {
+			reason: "We should not treat a package from the same repository that already exists with the unique name as an error.",
+			args: args{
+				existing: map[string]string{
+					"acme-provider-sql": "other/acme/provider-sql:v0.1.0",
+					unique:              "acme/provider-sql:v0.2.0",
+				},
+			},
+			want: want{name: unique},
+		},
+		"CollisionUnresolvable": {
+			reason: "We should return an error if packages from other repositories have both names.",
+			args: args{
+				existing: map[string]string{
+					"acme-provider-sql": "other/acme/provider-sql:v0.1.0",
+					unique:              "another/acme/provider-sql:v0.1.0",
+				},
+			},
+			want: want{name: unique, err: permanent(errors.Errorf(errNameCollisionFmt, "acme-provider-sql", unique, "index.docker.io/acme/provider-sql"))},
+		},
+		"ErrGetExisting": {
+			reason: "We should return an error if we cannot get the package that already exists.",
+			args: args{
+				existing: map[string]string{"acme-provider-sql": "acme/provider-sql:v0.1.0"},
+				get:      errBoom,
+			},
+			want: want{name: "acme-provider-sql", err: errors.Wrap(errBoom, errGetExistingPackage)},
+		},
+	}
+
+	for n, tc := range cases {
+		t.Run(n, func(t *testing.T) {
+			// Our cache never knows about packages that already exist,
+			// so we must read them from the API server.
+			cache := &test.MockClient{
+				MockList: test.NewMockListFn(tc.args.list, func(o client.ObjectList) error {
+					l := o.(*v1.ProviderList)
+					for n, src := range tc.args.listed {
+						p := v1.Provider{}
+						p.SetName(n)
+						p.SetSource(src)
+						l.Items = append(l.Items, p)
+					}
+					return nil
+				}),
+				MockCreate: func(_ context.Context, obj client.Object, _ ...client.CreateOption) error {
+					if obj.GetLabels()[LabelRepository] != xpkg.ToUniqueDNSLabel("index.docker.io/acme/provider-sql") {
+						t.Errorf("\n%s\nr.create(...): package is not labeled with its repository", tc.reason)
+					}
+					if _, ok := tc.args.existing[obj.GetName()]; ok {
+						return kerrors.NewAlreadyExists(schema.GroupResource{}, obj.GetName())
+					}
+					return nil
+				},
+				MockGet: test.NewMockGetFn(kerrors.NewNotFound(schema.GroupResource{}, "")),
+			}
+			live := &test.MockClient{
+				MockGet: func(_ context.Context, key client.ObjectKey, obj client.Object) error {
+					obj.(v1.Package).SetSource(tc.args.existing[key.Name])
+					return tc.args.get
+				},
+			}
+			r := &Reconciler{client: cache, reader: live}
+
+			pack := &v1.Provider{}
+			pack.SetName("acme-provider-sql")
+			pack.SetSource("acme/provider-sql:v0.2.0")
+			created, err := r.create(context.Background(), logging.NewNopLogger(), pack)
+
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nr.create(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.created, created); diff != "" {
+				t.Errorf("\n%s\nr.create(...): -want created, +got created:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.name, pack.GetName()); diff != "" {
+				t.Errorf("\n%s\nr.create(...): -want name, +got name:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
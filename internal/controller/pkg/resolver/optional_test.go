@@ -0,0 +1,143 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/crossplane/crossplane-runtime/pkg/resource/fake"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+	"github.com/crossplane/crossplane/internal/dag"
+	"github.com/crossplane/crossplane/internal/xpkg"
+	fakexpkg "github.com/crossplane/crossplane/internal/xpkg/fake"
+)
+
+func TestReconcileOptional(t *testing.T) {
+	config := v1beta1.LockPackage{Source: "cool/config", Type: v1beta1.ConfigurationPackageType, Version: "v1.0.0", Dependencies: []v1beta1.Dependency{
+		{Package: "crossplane/provider-aws", Type: v1beta1.ProviderPackageType, Constraints: ">=v0.20.0", Optional: true},
+	}}
+	aws := func(version string) v1beta1.LockPackage {
+		return v1beta1.LockPackage{Source: "crossplane/provider-aws", Type: v1beta1.ProviderPackageType, Version: version}
+	}
+
+	type want struct {
+		err      error
+		created  bool
+		status   []v1beta1.DependencyResolution
+		warnings []string
+	}
+
+	cases := map[string]struct {
+		reason string
+		pkgs   []v1beta1.LockPackage
+		want   want
+	}{
+		"OptionalMissing": {
+			reason: "We should not install a missing optional dependency, but should report it as optional and not installed.",
+			pkgs:   []v1beta1.LockPackage{config},
+			want: want{
+				status: []v1beta1.DependencyResolution{{
+					Package:     "crossplane/provider-aws",
+					State:       v1beta1.DependencyOptional,
+					Constraints: []v1beta1.DependentConstraint{{Dependent: "cool/config", Constraints: ">=v0.20.0"}},
+					Message:     xpkg.MsgOptionalMissing,
+				}},
+			},
+		},
+		"OptionalSatisfied": {
+			reason: "We should neither report nor warn about an installed optional dependency that satisfies its constraint.",
+			pkgs:   []v1beta1.LockPackage{config, aws("v0.20.0")},
+			want:   want{},
+		},
+		"OptionalViolated": {
+			reason: "We should warn about, and report, an installed optional dependency that violates its constraint, but it should never conflict or return an error.",
+			pkgs:   []v1beta1.LockPackage{config, aws("v0.19.0")},
+			want: want{
+				status: []v1beta1.DependencyResolution{{
+					Package:     "crossplane/provider-aws",
+					State:       v1beta1.DependencyOptional,
+					Constraints: []v1beta1.DependentConstraint{{Dependent: "cool/config", Constraints: ">=v0.20.0"}},
+					Message:     `package cool/config requires crossplane/provider-aws ">=v0.20.0" but version v0.19.0 is installed`,
+					Version:     "v0.19.0",
+				}},
+				warnings: []string{`package cool/config requires crossplane/provider-aws ">=v0.20.0" but version v0.19.0 is installed`},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			created := false
+			var status []v1beta1.DependencyResolution
+			rec := &recorder{}
+			mgr := &fake.Manager{
+				Client: &test.MockClient{
+					MockGet: test.NewMockGetFn(nil, func(o client.Object) error {
+						o.SetName("lock")
+						o.(*v1beta1.Lock).Packages = tc.pkgs
+						return nil
+					}),
+					MockList: test.NewMockListFn(nil),
+					MockCreate: func(_ context.Context, _ client.Object, _ ...client.CreateOption) error {
+						created = true
+						return nil
+					},
+					MockUpdate: test.NewMockUpdateFn(nil),
+					MockStatusUpdate: func(_ context.Context, o client.Object, _ ...client.UpdateOption) error {
+						status = o.(*v1beta1.Lock).Status.DependencyResolution
+						return nil
+					},
+				},
+			}
+			r := NewReconciler(mgr,
+				WithRecorder(rec),
+				WithFetcher(&fakexpkg.MockFetcher{MockTags: fakexpkg.NewMockTagsFn([]string{"v0.19.0", "v0.20.0"}, nil)}),
+				WithNewDagFn(dag.NewMapDag),
+			)
+			_, err := r.Reconcile(context.Background(), reconcile.Request{})
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.created, created); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want created, +got created:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.status, status, cmpopts.IgnoreFields(v1beta1.DependencyResolution{}, "LastAttemptTime")); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want status, +got status:\n%s", tc.reason, diff)
+			}
+			var warnings []string
+			for i, reason := range rec.reasons {
+				switch reason {
+				case reasonOptionalDependency:
+					warnings = append(warnings, rec.messages[i])
+				case reasonVersionConflict, reasonTypeConflict, reasonUnreportedViolation:
+					t.Errorf("\n%s\nr.Reconcile(...): unexpected event %s: %s", tc.reason, reason, rec.messages[i])
+				}
+			}
+			if diff := cmp.Diff(tc.want.warnings, warnings); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want warnings, +got warnings:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
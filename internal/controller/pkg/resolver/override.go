@@ -0,0 +1,110 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+	"github.com/crossplane/crossplane/internal/xpkg"
+	"github.com/crossplane/crossplane/internal/xpkg/canonical"
+)
+
+const (
+	// overrideParent is reported as the package that requires a version
+	// when the Lock overrides it.
+	overrideParent = "spec.overrides"
+
+	errOverrideViolatesFmt = "overridden version %s of dependency %s does not satisfy %s"
+)
+
+// overridden splits the supplied conflicts into those that persist, and those
+// that the supplied Lock's overrides are responsible for, i.e. of packages
+// installed at a version that satisfies their override. A package whose
+// installed version doesn't satisfy its override conflicts with the override
+// instead of the packages that depend on it, so that it's upgraded to the
+// overridden version if upgrades are allowed.
+func overridden(lock *v1beta1.Lock, cs []xpkg.Conflict) ([]xpkg.Conflict, []xpkg.Conflict) {
+	o := xpkg.NewOverrides(lock.Spec.Overrides)
+	if len(o) == 0 {
+		return cs, nil
+	}
+
+	// Packages whose installed version doesn't satisfy their override.
+	mismatched := map[string]xpkg.Conflict{}
+	for _, p := range lock.Packages {
+		v, ok := o.For(p.Source)
+		if !ok {
+			continue
+		}
+		req := []xpkg.Requirement{{Parent: overrideParent, Constraint: v}}
+		if len(xpkg.Violated(p.Version, req)) > 0 {
+			mismatched[p.Identifier()] = xpkg.Conflict{Reason: xpkg.ConflictVersion, Package: p.Source, Version: p.Version, Origin: p.GetOrigin(), Requirements: req}
+		}
+	}
+
+	conflicts := make([]xpkg.Conflict, 0, len(cs)+len(mismatched))
+	var overrides []xpkg.Conflict
+	for _, c := range cs {
+		_, ok := o.For(c.Package)
+		_, mismatch := mismatched[canonical.Source(c.Package)]
+		switch {
+		case !ok || c.Reason != xpkg.ConflictVersion:
+			conflicts = append(conflicts, c)
+		case mismatch:
+			// We report the conflict with the override instead.
+		default:
+			overrides = append(overrides, c)
+		}
+	}
+	for _, p := range lock.Packages {
+		if c, ok := mismatched[p.Identifier()]; ok {
+			conflicts = append(conflicts, c)
+		}
+	}
+	return conflicts, overrides
+}
+
+// overrideConstraints returns the constraints the supplied package's version
+// must satisfy; its override if the supplied Lock overrides it, or else the
+// constraints of the packages in the Lock that depend on it.
+func overrideConstraints(lock *v1beta1.Lock, pkg string) []string {
+	if v, ok := xpkg.NewOverrides(lock.Spec.Overrides).For(pkg); ok {
+		return []string{v}
+	}
+	return xpkg.ConstraintsOn(lock.Packages, pkg)
+}
+
+// overriddenMessage describes the supplied conflicts caused by overrides.
+func overriddenMessage(cs []xpkg.Conflict) string {
+	msgs := make([]string, len(cs))
+	for i, c := range cs {
+		msgs[i] = c.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// violationMessage describes the requirements the supplied planned install
+// violates because its version is overridden.
+func violationMessage(p xpkg.PlannedInstall) string {
+	rs := make([]string, len(p.Violations))
+	for i, rq := range p.Violations {
+		rs[i] = fmt.Sprintf("%q required by %s", rq.Constraint, rq.Parent)
+	}
+	return fmt.Sprintf(errOverrideViolatesFmt, p.Version, p.Dependency.Package, strings.Join(rs, ", "))
+}
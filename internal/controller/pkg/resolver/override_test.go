@@ -0,0 +1,231 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/fake"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	v1 "github.com/crossplane/crossplane/apis/pkg/v1"
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+	"github.com/crossplane/crossplane/internal/dag"
+	"github.com/crossplane/crossplane/internal/xpkg"
+	fakexpkg "github.com/crossplane/crossplane/internal/xpkg/fake"
+)
+
+func TestReconcileOverrides(t *testing.T) {
+	config := func(constraints string) v1beta1.LockPackage {
+		return v1beta1.LockPackage{Source: "cool/config", Dependencies: []v1beta1.Dependency{
+			{Package: "crossplane/provider-aws", Type: v1beta1.ProviderPackageType, Constraints: constraints},
+		}}
+	}
+	aws := v1beta1.LockPackage{Source: "crossplane/provider-aws", Type: v1beta1.ProviderPackageType, Version: "v0.1.0"}
+
+	type want struct {
+		created  []string
+		override string
+		events   []event.Reason
+		reason   xpv1.ConditionReason
+	}
+
+	cases := map[string]struct {
+		reason    string
+		pkgs      []v1beta1.LockPackage
+		overrides []v1beta1.DependencyOverride
+		want      want
+	}{
+		"NoOverride": {
+			reason: "We should install the highest version that satisfies a missing dependency that isn't overridden.",
+			pkgs:   []v1beta1.LockPackage{config(">=v0.1.0")},
+			want: want{
+				created: []string{"crossplane/provider-aws:v0.3.0"},
+				events:  []event.Reason{reasonInstallDependency},
+				reason:  v1beta1.ReasonMissingDependency,
+			},
+		},
+		"OverrideWins": {
+			reason:    "We should install the overridden version of a missing dependency, and record the override.",
+			pkgs:      []v1beta1.LockPackage{config(">=v0.1.0")},
+			overrides: []v1beta1.DependencyOverride{{Package: "crossplane/provider-aws", Version: "v0.1.0"}},
+			want: want{
+				created:  []string{"crossplane/provider-aws:v0.1.0"},
+				override: "v0.1.0",
+				events:   []event.Reason{reasonInstallDependency},
+				reason:   v1beta1.ReasonMissingDependency,
+			},
+		},
+		"OverrideConflictsWarns": {
+			reason:    "We should install the overridden version of a missing dependency even if it violates a constraint, but warn about it.",
+			pkgs:      []v1beta1.LockPackage{config(">=v0.2.0")},
+			overrides: []v1beta1.DependencyOverride{{Package: "crossplane/provider-aws", Version: "v0.1.0"}},
+			want: want{
+				created:  []string{"crossplane/provider-aws:v0.1.0"},
+				override: "v0.1.0",
+				events:   []event.Reason{reasonInstallDependency, reasonOverridden},
+				reason:   v1beta1.ReasonMissingDependency,
+			},
+		},
+		"InstalledOverrideConflictsWarns": {
+			reason:    "We should consider an installed dependency whose overridden version violates a constraint resolved, but warn about it.",
+			pkgs:      []v1beta1.LockPackage{config(">=v0.2.0"), aws},
+			overrides: []v1beta1.DependencyOverride{{Package: "crossplane/provider-aws", Version: "v0.1.0"}},
+			want: want{
+				events: []event.Reason{reasonOverridden},
+				reason: v1beta1.ReasonOverridden,
+			},
+		},
+		"InstalledConflictsWithoutOverride": {
+			reason: "We should report an installed dependency that violates a constraint as conflicting if it isn't overridden.",
+			pkgs:   []v1beta1.LockPackage{config(">=v0.2.0"), aws},
+			want: want{
+				events: []event.Reason{reasonVersionConflict},
+				reason: v1beta1.ReasonVersionConflict,
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var created []string
+			var override string
+			stored := &v1beta1.Lock{}
+			mgr := &fake.Manager{
+				Client: &test.MockClient{
+					MockGet: func(_ context.Context, key client.ObjectKey, o client.Object) error {
+						if l, ok := o.(*v1beta1.Lock); ok {
+							l.Packages = tc.pkgs
+							l.Spec.Overrides = tc.overrides
+							return nil
+						}
+						return kerrors.NewNotFound(schema.GroupResource{}, key.Name)
+					},
+					MockList: test.NewMockListFn(nil),
+					MockCreate: func(_ context.Context, o client.Object, _ ...client.CreateOption) error {
+						created = append(created, o.(v1.Package).GetSource())
+						override = o.GetAnnotations()[AnnotationResolvedOverride]
+						return nil
+					},
+					MockUpdate: test.NewMockUpdateFn(nil),
+					MockStatusUpdate: func(_ context.Context, o client.Object, _ ...client.UpdateOption) error {
+						o.(*v1beta1.Lock).Status.DeepCopyInto(&stored.Status)
+						return nil
+					},
+				},
+			}
+			rec := &recorder{}
+			r := NewReconciler(mgr,
+				WithRecorder(rec),
+				WithFetcher(&fakexpkg.MockFetcher{MockTags: fakexpkg.NewMockTagsFn([]string{"v0.1.0", "v0.2.0", "v0.3.0"}, nil)}),
+				WithNewDagFn(dag.NewMapDag),
+			)
+
+			if _, err := r.Reconcile(context.Background(), reconcile.Request{}); err != nil {
+				t.Fatalf("\n%s\nr.Reconcile(...): %s", tc.reason, err)
+			}
+			if diff := cmp.Diff(tc.want.created, created); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want created, +got created:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.override, override); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want override annotation, +got override annotation:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.events, rec.reasons); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want events, +got events:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.reason, stored.GetCondition(v1beta1.TypeDependenciesResolved).Reason); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want condition reason, +got condition reason:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestOverridden(t *testing.T) {
+	config := v1beta1.LockPackage{Source: "cool/config", Dependencies: []v1beta1.Dependency{
+		{Package: "crossplane/provider-aws", Type: v1beta1.ProviderPackageType, Constraints: ">=v0.2.0"},
+	}}
+	aws := v1beta1.LockPackage{Source: "crossplane/provider-aws", Type: v1beta1.ProviderPackageType, Version: "v0.1.0"}
+	conflict := xpkg.Conflict{
+		Reason:       xpkg.ConflictVersion,
+		Package:      "crossplane/provider-aws",
+		Version:      "v0.1.0",
+		Requirements: []xpkg.Requirement{{Parent: "cool/config", Constraint: ">=v0.2.0"}},
+	}
+
+	type want struct {
+		conflicts []xpkg.Conflict
+		overrides []xpkg.Conflict
+	}
+
+	cases := map[string]struct {
+		reason    string
+		overrides []v1beta1.DependencyOverride
+		pkgs      []v1beta1.LockPackage
+		cs        []xpkg.Conflict
+		want      want
+	}{
+		"NoOverrides": {
+			reason: "Conflicts should persist if the Lock has no overrides.",
+			pkgs:   []v1beta1.LockPackage{config, aws},
+			cs:     []xpkg.Conflict{conflict},
+			want:   want{conflicts: []xpkg.Conflict{conflict}},
+		},
+		"SatisfiesOverride": {
+			reason:    "A conflict of a package installed at its overridden version should be caused by the override.",
+			overrides: []v1beta1.DependencyOverride{{Package: "crossplane/provider-aws", Version: "v0.1.0"}},
+			pkgs:      []v1beta1.LockPackage{config, aws},
+			cs:        []xpkg.Conflict{conflict},
+			want:      want{conflicts: []xpkg.Conflict{}, overrides: []xpkg.Conflict{conflict}},
+		},
+		"ViolatesOverride": {
+			reason:    "A package not installed at its overridden version should conflict with the override.",
+			overrides: []v1beta1.DependencyOverride{{Package: "crossplane/provider-aws", Version: "v0.3.0"}},
+			pkgs:      []v1beta1.LockPackage{config, aws},
+			cs:        []xpkg.Conflict{conflict},
+			want: want{conflicts: []xpkg.Conflict{{
+				Reason:       xpkg.ConflictVersion,
+				Package:      "crossplane/provider-aws",
+				Version:      "v0.1.0",
+				Origin:       aws.GetOrigin(),
+				Requirements: []xpkg.Requirement{{Parent: overrideParent, Constraint: "v0.3.0"}},
+			}}},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			lock := &v1beta1.Lock{Packages: tc.pkgs, Spec: v1beta1.LockSpec{Overrides: tc.overrides}}
+			conflicts, overrides := overridden(lock, tc.cs)
+			if diff := cmp.Diff(tc.want.conflicts, conflicts, cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("\n%s\noverridden(...): -want conflicts, +got conflicts:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.overrides, overrides, cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("\n%s\noverridden(...): -want overrides, +got overrides:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
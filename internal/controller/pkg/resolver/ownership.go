@@ -0,0 +1,161 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/crossplane-runtime/pkg/meta"
+
+	v1 "github.com/crossplane/crossplane/apis/pkg/v1"
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+	"github.com/crossplane/crossplane/internal/xpkg/canonical"
+)
+
+const (
+	// LabelAutoInstalled is added to packages the resolver installs to
+	// satisfy a dependency. Only packages with this label are ever garbage
+	// collected by the resolver. Removing the label adopts the package,
+	// ensuring it is kept after nothing depends on it.
+	LabelAutoInstalled = "pkg.crossplane.io/auto-installed"
+
+	// LabelOrphaned is added to auto-installed packages that are no longer
+	// required by any package in the Lock.
+	LabelOrphaned = "pkg.crossplane.io/orphaned"
+
+	// AnnotationRequiredBy is a comma separated list of the sources of the
+	// packages in the Lock that require a package the resolver installed.
+	AnnotationRequiredBy = v1.AnnotationRequiredBy
+)
+
+const (
+	errListPackages   = "cannot list auto-installed packages"
+	errParseSource    = "cannot parse package source"
+	errUpdatePackage  = "cannot update auto-installed package"
+	errDeletePackage  = "cannot delete orphaned package"
+	errCollectGarbage = "cannot garbage collect auto-installed packages"
+)
+
+// A DependencyGCPolicy determines what the Reconciler does with packages it
+// auto-installed once no package in the Lock requires them.
+type DependencyGCPolicy string
+
+// Dependency garbage collection policies.
+const (
+	// DependencyGCOrphan labels orphaned packages, but leaves them installed.
+	DependencyGCOrphan DependencyGCPolicy = "Orphan"
+
+	// DependencyGCDelete deletes orphaned packages.
+	DependencyGCDelete DependencyGCPolicy = "Delete"
+)
+
+// WithDependencyOwnership specifies that the Reconciler should make the Lock
+// the controller of the packages it creates, and how it should handle them
+// once they are no longer required.
+func WithDependencyOwnership(p DependencyGCPolicy) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.gc = p
+	}
+}
+
+// requiredBy returns the sorted sources of the packages in the Lock that
+// depend on the supplied package source, or any alias of it.
+func requiredBy(lock *v1beta1.Lock, source string) []string {
+	parents := []string{}
+	for _, p := range lock.Packages {
+		for _, d := range p.Dependencies {
+			if d.Identifier() == canonical.Source(source) {
+				parents = append(parents, p.Source)
+				break
+			}
+		}
+	}
+	sort.Strings(parents)
+	return parents
+}
+
+// own marks the supplied package as auto-installed, and makes the Lock its
+// controller.
+func own(lock *v1beta1.Lock, pack v1.Package) {
+	meta.AddLabels(pack, map[string]string{LabelAutoInstalled: "true"})
+	meta.AddOwnerReference(pack, meta.AsController(meta.TypedReferenceTo(lock, v1beta1.LockGroupVersionKind)))
+}
+
+// collect garbage collects auto-installed packages that are no longer
+// required by any package in the Lock. Packages that were not installed by
+// the resolver are never collected. The supplied Lock must include every
+// package and dependency it declares, including stale packages and invalid
+// dependencies, so that a package that is still declared never appears to be
+// orphaned. It returns how long until the next orphaned package is due to be
+// deleted, if any.
+func (r *Reconciler) collect(ctx context.Context, lock *v1beta1.Lock) (time.Duration, error) {
+	pkgs, err := r.autoInstalled(ctx, lock)
+	if err != nil {
+		return 0, err
+	}
+	return PruneUnusedDependencies(ctx, r.client, lock, pkgs, PruneOptions{
+		Policy:      r.gc,
+		GracePeriod: r.orphanGrace,
+		Registry:    r.registry,
+		Now:         r.now(),
+		Recorder:    r.record,
+		Deleted: func(p v1.Package, source string) {
+			r.recordAudit(ctx, lock, AuditRecord{Action: AuditDeleted, Source: source, Package: p.GetName()})
+		},
+	})
+}
+
+// resolvable returns the supplied Lock with its packages and dependencies
+// normalized and aliased as they are before its dependencies are resolved, so
+// that they can be compared to the sources of installed packages.
+func (r *Reconciler) resolvable(lock *v1beta1.Lock, aliases map[string]string) *v1beta1.Lock {
+	r.normalize(logging.NewNopLogger(), lock)
+	alias(logging.NewNopLogger(), lock, aliases)
+	return lock
+}
+
+// autoInstalled returns all packages that were installed by the resolver for
+// the supplied Lock.
+func (r *Reconciler) autoInstalled(ctx context.Context, lock *v1beta1.Lock) ([]v1.Package, error) {
+	pl := &v1.ProviderList{}
+	if err := r.client.List(ctx, pl, client.MatchingLabels{LabelAutoInstalled: "true"}); err != nil {
+		return nil, errors.Wrap(err, errListPackages)
+	}
+	cl := &v1.ConfigurationList{}
+	if err := r.client.List(ctx, cl, client.MatchingLabels{LabelAutoInstalled: "true"}); err != nil {
+		return nil, errors.Wrap(err, errListPackages)
+	}
+
+	pkgs := make([]v1.Package, 0, len(pl.Items)+len(cl.Items))
+	for i := range pl.Items {
+		if lockOf(&pl.Items[i]) == lock.GetName() {
+			pkgs = append(pkgs, &pl.Items[i])
+		}
+	}
+	for i := range cl.Items {
+		if lockOf(&cl.Items[i]) == lock.GetName() {
+			pkgs = append(pkgs, &cl.Items[i])
+		}
+	}
+	return pkgs, nil
+}
@@ -0,0 +1,329 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/fake"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	v1 "github.com/crossplane/crossplane/apis/pkg/v1"
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+	"github.com/crossplane/crossplane/internal/dag"
+	fakexpkg "github.com/crossplane/crossplane/internal/xpkg/fake"
+)
+
+func TestOwn(t *testing.T) {
+	lock := &v1beta1.Lock{ObjectMeta: metav1.ObjectMeta{Name: "lock", UID: "lock-uid"}}
+	got := &v1.Provider{}
+	own(lock, got)
+
+	ctrl := true
+	want := &v1.Provider{ObjectMeta: metav1.ObjectMeta{
+		Labels: map[string]string{LabelAutoInstalled: "true"},
+		OwnerReferences: []metav1.OwnerReference{{
+			APIVersion: v1beta1.LockGroupVersionKind.GroupVersion().String(),
+			Kind:       v1beta1.LockKind,
+			Name:       "lock",
+			UID:        "lock-uid",
+			Controller: &ctrl,
+		}},
+	}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("own(...): -want, +got:\n%s", diff)
+	}
+}
+
+func TestCollect(t *testing.T) {
+	errBoom := errors.New("boom")
+
+	lock := &v1beta1.Lock{
+		ObjectMeta: metav1.ObjectMeta{Name: "lock"},
+		Packages: []v1beta1.LockPackage{
+			{
+				Source:       "hasheddan/config-nop-a",
+				Dependencies: []v1beta1.Dependency{{Package: "hasheddan/provider-nop-a"}},
+			},
+			{
+				Source: "hasheddan/provider-nop-a",
+			},
+		},
+	}
+
+	provider := func(source string, labels, annotations map[string]string) v1.Provider {
+		p := v1.Provider{ObjectMeta: metav1.ObjectMeta{Labels: labels, Annotations: annotations}}
+		p.SetSource(source)
+		return p
+	}
+
+	type args struct {
+		client client.Client
+		gc     DependencyGCPolicy
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   error
+	}{
+		"ErrList": {
+			reason: "We should return an error if we cannot list packages.",
+			args: args{
+				client: &test.MockClient{MockList: test.NewMockListFn(errBoom)},
+				gc:     DependencyGCOrphan,
+			},
+			want: errors.Wrap(errBoom, errListPackages),
+		},
+		"StillRequired": {
+			reason: "We should not modify a package that is still required.",
+			args: args{
+				client: &test.MockClient{
+					MockList: test.NewMockListFn(nil, func(o client.ObjectList) error {
+						if l, ok := o.(*v1.ProviderList); ok {
+							l.Items = []v1.Provider{provider("hasheddan/provider-nop-a:v1.0.0",
+								map[string]string{LabelAutoInstalled: "true"},
+								map[string]string{AnnotationRequiredBy: "hasheddan/config-nop-a"})}
+						}
+						return nil
+					}),
+					MockUpdate: test.NewMockUpdateFn(errBoom),
+					MockDelete: test.NewMockDeleteFn(errBoom),
+				},
+				gc: DependencyGCDelete,
+			},
+		},
+		"RequiredAgain": {
+			reason: "We should remove the orphaned label from a package that is required again.",
+			args: args{
+				client: &test.MockClient{
+					MockList: test.NewMockListFn(nil, func(o client.ObjectList) error {
+						if l, ok := o.(*v1.ProviderList); ok {
+							l.Items = []v1.Provider{provider("hasheddan/provider-nop-a:v1.0.0",
+								map[string]string{LabelAutoInstalled: "true", LabelOrphaned: "true"},
+								map[string]string{AnnotationRequiredBy: ""})}
+						}
+						return nil
+					}),
+					MockUpdate: test.NewMockUpdateFn(nil, func(o client.Object) error {
+						want := provider("hasheddan/provider-nop-a:v1.0.0",
+							map[string]string{LabelAutoInstalled: "true"},
+							map[string]string{AnnotationRequiredBy: "hasheddan/config-nop-a"})
+						if diff := cmp.Diff(&want, o); diff != "" {
+							t.Errorf("-want, +got:\n%s", diff)
+						}
+						return nil
+					}),
+				},
+				gc: DependencyGCOrphan,
+			},
+		},
+		"Orphan": {
+			reason: "We should label a package that is no longer required as orphaned.",
+			args: args{
+				client: &test.MockClient{
+					MockList: test.NewMockListFn(nil, func(o client.ObjectList) error {
+						if l, ok := o.(*v1.ProviderList); ok {
+							l.Items = []v1.Provider{provider("hasheddan/provider-nop-b:v1.0.0",
+								map[string]string{LabelAutoInstalled: "true"},
+								map[string]string{AnnotationRequiredBy: "hasheddan/config-nop-b"})}
+						}
+						return nil
+					}),
+					MockUpdate: test.NewMockUpdateFn(nil, func(o client.Object) error {
+						want := provider("hasheddan/provider-nop-b:v1.0.0",
+							map[string]string{LabelAutoInstalled: "true", LabelOrphaned: "true"},
+							map[string]string{AnnotationRequiredBy: ""})
+						if diff := cmp.Diff(&want, o); diff != "" {
+							t.Errorf("-want, +got:\n%s", diff)
+						}
+						return nil
+					}),
+				},
+				gc: DependencyGCOrphan,
+			},
+		},
+		"ErrOrphan": {
+			reason: "We should return an error if we cannot label a package as orphaned.",
+			args: args{
+				client: &test.MockClient{
+					MockList: test.NewMockListFn(nil, func(o client.ObjectList) error {
+						if l, ok := o.(*v1.ConfigurationList); ok {
+							c := v1.Configuration{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{LabelAutoInstalled: "true"}}}
+							c.SetSource("hasheddan/config-nop-b:v1.0.0")
+							l.Items = []v1.Configuration{c}
+						}
+						return nil
+					}),
+					MockUpdate: test.NewMockUpdateFn(errBoom),
+				},
+				gc: DependencyGCOrphan,
+			},
+			want: errors.Wrap(errBoom, errUpdatePackage),
+		},
+		"Delete": {
+			reason: "We should delete a package that is no longer required when configured to do so.",
+			args: args{
+				client: &test.MockClient{
+					MockList: test.NewMockListFn(nil, func(o client.ObjectList) error {
+						if l, ok := o.(*v1.ProviderList); ok {
+							l.Items = []v1.Provider{provider("hasheddan/provider-nop-b:v1.0.0",
+								map[string]string{LabelAutoInstalled: "true"}, nil)}
+						}
+						return nil
+					}),
+					MockDelete: test.NewMockDeleteFn(nil),
+				},
+				gc: DependencyGCDelete,
+			},
+		},
+		"ErrDelete": {
+			reason: "We should return an error if we cannot delete a package that is no longer required.",
+			args: args{
+				client: &test.MockClient{
+					MockList: test.NewMockListFn(nil, func(o client.ObjectList) error {
+						if l, ok := o.(*v1.ProviderList); ok {
+							l.Items = []v1.Provider{provider("hasheddan/provider-nop-b:v1.0.0",
+								map[string]string{LabelAutoInstalled: "true"}, nil)}
+						}
+						return nil
+					}),
+					MockDelete: test.NewMockDeleteFn(errBoom),
+				},
+				gc: DependencyGCDelete,
+			},
+			want: errors.Wrap(errBoom, errDeletePackage),
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			r := &Reconciler{client: tc.args.client, gc: tc.args.gc, now: time.Now}
+			_, err := r.collect(context.Background(), lock)
+			if diff := cmp.Diff(tc.want, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nr.collect(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestReconcileCollectDeclared(t *testing.T) {
+	// Both providers were auto-installed, but only provider-aws is declared
+	// as a dependency.
+	provider := func(name, source string) v1.Provider {
+		p := v1.Provider{ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: map[string]string{LabelAutoInstalled: "true", LabelLock: "lock"},
+		}}
+		p.SetSource(source)
+		return p
+	}
+	providers := []v1.Provider{
+		provider("crossplane-provider-aws", "crossplane/provider-aws:v0.20.0"),
+		provider("crossplane-provider-gcp", "crossplane/provider-gcp:v0.20.0"),
+	}
+
+	type args struct {
+		constraints string
+		verify      bool
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   []string
+	}{
+		"Declared": {
+			reason: "We should only delete the package that is not declared as a dependency.",
+			args:   args{constraints: ">=v0.20.0"},
+			want:   []string{"delete/crossplane-provider-gcp"},
+		},
+		"InvalidDependency": {
+			reason: "We should not delete a package whose declaration we rejected as invalid.",
+			args:   args{constraints: ">=v0.20.0;"},
+			want:   []string{"delete/crossplane-provider-gcp"},
+		},
+		"StaleDependent": {
+			reason: "We should not delete a package that is declared by a package with no revision.",
+			args:   args{constraints: ">=v0.20.0", verify: true},
+			want:   []string{"delete/crossplane-provider-gcp"},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var writes []string
+			mgr := &fake.Manager{
+				Client: &test.MockClient{
+					MockGet: test.NewMockGetFn(nil, func(o client.Object) error {
+						o.SetName("lock")
+						o.(*v1beta1.Lock).Packages = []v1beta1.LockPackage{{
+							Name:   "cool-config",
+							Source: "cool/config",
+							Type:   v1beta1.ConfigurationPackageType,
+							Dependencies: []v1beta1.Dependency{
+								{Package: "crossplane/provider-aws", Type: v1beta1.ProviderPackageType, Constraints: tc.args.constraints},
+							},
+						}}
+						return nil
+					}),
+					MockList: test.NewMockListFn(nil, func(o client.ObjectList) error {
+						if l, ok := o.(*v1.ProviderList); ok {
+							l.Items = append([]v1.Provider{}, providers...)
+						}
+						return nil
+					}),
+					MockCreate: func(_ context.Context, o client.Object, _ ...client.CreateOption) error {
+						writes = append(writes, "create/"+o.GetName())
+						return nil
+					},
+					MockUpdate: test.NewMockUpdateFn(nil),
+					MockDelete: func(_ context.Context, o client.Object, _ ...client.DeleteOption) error {
+						writes = append(writes, "delete/"+o.GetName())
+						return nil
+					},
+					MockStatusUpdate: test.NewMockStatusUpdateFn(nil),
+				},
+			}
+			opts := []ReconcilerOption{
+				WithDependencyOwnership(DependencyGCDelete),
+				WithOrphanGracePeriod(0),
+				WithFetcher(&fakexpkg.MockFetcher{MockTags: fakexpkg.NewMockTagsFn([]string{"v0.20.0"}, nil)}),
+				WithNewDagFn(dag.NewMapDag),
+			}
+			if tc.args.verify {
+				opts = append(opts, WithLockVerification())
+			}
+			r := NewReconciler(mgr, opts...)
+			if _, err := r.Reconcile(context.Background(), reconcile.Request{}); err != nil {
+				t.Fatalf("\n%s\nr.Reconcile(...): %s", tc.reason, err)
+			}
+
+			if diff := cmp.Diff(tc.want, writes); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want package writes, +got package writes:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"context"
+
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+)
+
+// AnnotationPaused pauses the resolution of the dependencies of the packages
+// in a Lock when its value is "true".
+const AnnotationPaused = "crossplane.io/paused"
+
+const (
+	reasonPaused event.Reason = "DependencyResolutionPaused"
+
+	msgPaused  = "Dependency resolution is paused"
+	msgResumed = "Dependency resolution is resumed"
+)
+
+// isPaused returns true if the resolution of the supplied Lock's dependencies is
+// paused.
+func isPaused(lock *v1beta1.Lock) bool {
+	return lock.GetAnnotations()[AnnotationPaused] == "true"
+}
+
+// pause reports that the resolution of the supplied Lock's dependencies is
+// paused. A paused Lock is not resolved at all, so nothing but its
+// DependenciesResolved condition changes, and only when it is first paused.
+// Any status the Lock was reconciled to before it was found to be paused is
+// discarded in favor of the supplied observed status. We'll be queued when
+// the Lock is resumed, at which point every dependency is resolved afresh.
+func (r *Reconciler) pause(ctx context.Context, log logging.Logger, lock *v1beta1.Lock, observed *v1beta1.LockStatus) (reconcile.Result, error) {
+	log.Debug(msgPaused)
+	if observed.GetCondition(v1beta1.TypeDependenciesResolved).Reason == v1beta1.ReasonPaused {
+		return reconcile.Result{}, nil
+	}
+	lock.Status = *observed.DeepCopy()
+	lock.SetConditions(v1beta1.ResolutionPaused().WithMessage(msgPaused))
+	r.record.Event(lock, event.Normal(reasonPaused, msgPaused))
+	return reconcile.Result{}, errors.Wrap(r.status.Write(ctx, lock, observed), errUpdateStatus)
+}
+
+// resumed records an event if the supplied observed status of a Lock that
+// isn't paused indicates that it was.
+func (r *Reconciler) resumed(lock *v1beta1.Lock, observed *v1beta1.LockStatus) {
+	if observed.GetCondition(v1beta1.TypeDependenciesResolved).Reason == v1beta1.ReasonPaused {
+		r.record.Event(lock, event.Normal(reasonPaused, msgResumed))
+	}
+}
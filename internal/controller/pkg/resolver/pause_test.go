@@ -0,0 +1,218 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/fake"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+	"github.com/crossplane/crossplane/internal/dag"
+	fakexpkg "github.com/crossplane/crossplane/internal/xpkg/fake"
+)
+
+func TestReconcilePaused(t *testing.T) {
+	pkgs := []v1beta1.LockPackage{{
+		Source: "cool/config",
+		Dependencies: []v1beta1.Dependency{
+			{Package: "crossplane/provider-aws", Type: v1beta1.ProviderPackageType, Constraints: ">=v0.20.0"},
+		},
+	}}
+	pausedAt := func(reads ...int) map[int]bool {
+		m := map[int]bool{}
+		for _, r := range reads {
+			m[r] = true
+		}
+		return m
+	}
+
+	type args struct {
+		pkgs []v1beta1.LockPackage
+
+		// paused are the reads of the Lock at which it is paused.
+		paused map[int]bool
+
+		// condition the Lock's status has when it is first read.
+		condition xpv1.Condition
+	}
+	type want struct {
+		writes  []string
+		fetches int
+		reasons []event.Reason
+		// messages of the events recorded about pausing and resuming.
+		messages  []string
+		condition xpv1.ConditionReason
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"Paused": {
+			reason: "We should neither fetch nor write anything but a Paused condition when a Lock is paused.",
+			args: args{
+				pkgs:   pkgs,
+				paused: pausedAt(1),
+			},
+			want: want{
+				writes:    []string{"status"},
+				reasons:   []event.Reason{reasonPaused},
+				messages:  []string{msgPaused},
+				condition: v1beta1.ReasonPaused,
+			},
+		},
+		"StillPaused": {
+			reason: "We should write nothing and record no event when a Lock that we already reported paused is still paused.",
+			args: args{
+				pkgs:      pkgs,
+				paused:    pausedAt(1),
+				condition: v1beta1.ResolutionPaused(),
+			},
+			want: want{},
+		},
+		"PausedUninstall": {
+			reason: "We should remove the finalizer of a paused Lock without packages, so that pausing never blocks an uninstall.",
+			args: args{
+				paused: pausedAt(1),
+			},
+			want: want{
+				writes: []string{"update"},
+			},
+		},
+		"PausedMidResolution": {
+			reason: "We should install nothing if a Lock is paused while we resolve it, and report it paused.",
+			args: args{
+				pkgs:   pkgs,
+				paused: pausedAt(2),
+			},
+			want: want{
+				writes:    []string{"status"},
+				fetches:   1,
+				reasons:   []event.Reason{reasonPaused},
+				messages:  []string{msgPaused},
+				condition: v1beta1.ReasonPaused,
+			},
+		},
+		"Resumed": {
+			reason: "We should resolve a Lock afresh, and record an event, once it is no longer paused.",
+			args: args{
+				pkgs:      pkgs,
+				condition: v1beta1.ResolutionPaused(),
+			},
+			want: want{
+				writes:    []string{"create/crossplane-provider-aws", "status"},
+				fetches:   1,
+				reasons:   []event.Reason{reasonPaused, reasonInstallDependency},
+				messages:  []string{msgResumed},
+				condition: v1beta1.ReasonMissingDependency,
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			reads, fetches := 0, 0
+			var writes []string
+			var status *v1beta1.Lock
+			rec := &recorder{}
+			mgr := &fake.Manager{
+				Client: &test.MockClient{
+					MockGet: test.NewMockGetFn(nil, func(o client.Object) error {
+						l, ok := o.(*v1beta1.Lock)
+						if !ok {
+							return nil
+						}
+						reads++
+						l.SetName("lock")
+						l.SetFinalizers([]string{finalizer})
+						l.Packages = tc.args.pkgs
+						if tc.args.paused[reads] {
+							l.SetAnnotations(map[string]string{AnnotationPaused: "true"})
+						}
+						if tc.args.condition.Type != "" {
+							l.SetConditions(tc.args.condition)
+						}
+						return nil
+					}),
+					MockList: test.NewMockListFn(nil),
+					MockCreate: func(_ context.Context, o client.Object, _ ...client.CreateOption) error {
+						writes = append(writes, "create/"+o.GetName())
+						return nil
+					},
+					MockUpdate: func(_ context.Context, o client.Object, _ ...client.UpdateOption) error {
+						writes = append(writes, "update")
+						return nil
+					},
+					MockPatch: func(_ context.Context, o client.Object, _ client.Patch, _ ...client.PatchOption) error {
+						writes = append(writes, "patch")
+						return nil
+					},
+					MockStatusUpdate: test.NewMockStatusUpdateFn(nil, func(o client.Object) error {
+						writes = append(writes, "status")
+						status = o.(*v1beta1.Lock)
+						return nil
+					}),
+				},
+			}
+			r := NewReconciler(mgr,
+				WithRecorder(rec),
+				WithFetcher(&fakexpkg.MockFetcher{MockTags: func() ([]string, error) {
+					fetches++
+					return []string{"v0.20.0"}, nil
+				}}),
+				WithNewDagFn(dag.NewMapDag),
+			)
+			if _, err := r.Reconcile(context.Background(), reconcile.Request{}); err != nil {
+				t.Fatalf("\n%s\nr.Reconcile(...): %s", tc.reason, err)
+			}
+
+			if diff := cmp.Diff(tc.want.writes, writes); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want writes, +got writes:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.fetches, fetches); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want fetches, +got fetches:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.reasons, rec.reasons); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want event reasons, +got event reasons:\n%s", tc.reason, diff)
+			}
+			var messages []string
+			for i, reason := range rec.reasons {
+				if reason == reasonPaused {
+					messages = append(messages, rec.messages[i])
+				}
+			}
+			if diff := cmp.Diff(tc.want.messages, messages); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want event messages, +got event messages:\n%s", tc.reason, diff)
+			}
+			if status != nil {
+				if diff := cmp.Diff(tc.want.condition, status.GetCondition(v1beta1.TypeDependenciesResolved).Reason); diff != "" {
+					t.Errorf("\n%s\nr.Reconcile(...): -want condition reason, +got condition reason:\n%s", tc.reason, diff)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"time"
+
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+	"github.com/crossplane/crossplane/internal/xpkg"
+)
+
+// A dependencyPolicy is the effective policy with which the Reconciler
+// resolves a Lock's dependencies. The Lock's spec overrides the Reconciler's
+// configuration, which applies to any field the spec does not set.
+type dependencyPolicy struct {
+	autoInstall     bool
+	upgrade         bool
+	selection       xpkg.VersionSelection
+	maxAutoInstall  int
+	recheck         v1beta1.DependencyRecheckPolicy
+	recheckInterval time.Duration
+}
+
+// policy returns the policy with which the Reconciler resolves the
+// dependencies of the supplied Lock.
+func (r *Reconciler) policy(lock *v1beta1.Lock) dependencyPolicy {
+	p := dependencyPolicy{
+		autoInstall:    !lock.Spec.SkipDependencyInstallation,
+		upgrade:        r.upgradePolicy(lock) == v1beta1.UpgradePolicyAutomatic,
+		selection:      r.selection,
+		maxAutoInstall: r.maxAutoInstall,
+	}
+	if s := lock.Spec.VersionSelection; s != nil {
+		p.selection = xpkg.VersionSelection(*s)
+	}
+	if n := lock.Spec.MaxAutoInstall; n != nil {
+		p.maxAutoInstall = int(*n)
+	}
+	if rc := lock.Spec.Recheck; rc != nil && rc.Policy != "" {
+		p.recheck = rc.Policy
+	}
+	if rc := lock.Spec.Recheck; rc != nil && rc.Interval != nil {
+		p.recheckInterval = rc.Interval.Duration
+	}
+	return p
+}
+
+// log logs the supplied policy.
+func (p dependencyPolicy) log(log logging.Logger) {
+	log.Debug("Resolving dependencies",
+		"auto-install", p.autoInstall,
+		"upgrade", p.upgrade,
+		"version-selection", p.selection,
+		"max-auto-install", p.maxAutoInstall,
+		"recheck", p.recheck,
+		"recheck-interval", p.recheckInterval,
+	)
+}
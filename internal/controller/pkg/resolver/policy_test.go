@@ -0,0 +1,250 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/fake"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	v1 "github.com/crossplane/crossplane/apis/pkg/v1"
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+	"github.com/crossplane/crossplane/internal/dag"
+	"github.com/crossplane/crossplane/internal/xpkg"
+	fakexpkg "github.com/crossplane/crossplane/internal/xpkg/fake"
+)
+
+func TestPolicy(t *testing.T) {
+	automatic, none := v1beta1.UpgradePolicyAutomatic, v1beta1.UpgradePolicyNone
+	lowest := v1beta1.VersionSelectionLowest
+	two := int64(2)
+
+	cases := map[string]struct {
+		reason string
+		opts   []ReconcilerOption
+		lock   *v1beta1.Lock
+		want   dependencyPolicy
+	}{
+		"Defaults": {
+			reason: "The Reconciler's configuration should apply if the Lock's spec sets nothing.",
+			opts:   []ReconcilerOption{WithVersionSelection(xpkg.VersionSelectionHighest), WithMaxAutoInstall(5)},
+			lock:   &v1beta1.Lock{},
+			want: dependencyPolicy{
+				autoInstall:    true,
+				selection:      xpkg.VersionSelectionHighest,
+				maxAutoInstall: 5,
+			},
+		},
+		"UpgradeAnnotation": {
+			reason: "Upgrades should be allowed by the Lock's annotation if the Reconciler allows them and the Lock's spec sets no upgrade policy.",
+			opts:   []ReconcilerOption{WithUpgradeDependencies(), WithVersionSelection(xpkg.VersionSelectionHighest)},
+			lock:   &v1beta1.Lock{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{AnnotationUpgradeDependencies: "true"}}},
+			want: dependencyPolicy{
+				autoInstall: true,
+				upgrade:     true,
+				selection:   xpkg.VersionSelectionHighest,
+			},
+		},
+		"SpecOverrides": {
+			reason: "Every field the Lock's spec sets should override the Reconciler's configuration.",
+			opts:   []ReconcilerOption{WithVersionSelection(xpkg.VersionSelectionHighest), WithMaxAutoInstall(5)},
+			lock: &v1beta1.Lock{Spec: v1beta1.LockSpec{
+				SkipDependencyInstallation: true,
+				UpgradePolicy:              &automatic,
+				VersionSelection:           &lowest,
+				MaxAutoInstall:             &two,
+			}},
+			want: dependencyPolicy{
+				upgrade:        true,
+				selection:      xpkg.VersionSelectionLowest,
+				maxAutoInstall: 2,
+			},
+		},
+		"Recheck": {
+			reason: "The Lock's recheck policy and interval should apply.",
+			opts:   []ReconcilerOption{WithVersionSelection(xpkg.VersionSelectionHighest)},
+			lock: &v1beta1.Lock{Spec: v1beta1.LockSpec{Recheck: &v1beta1.DependencyRecheck{
+				Policy:   v1beta1.RecheckPeriodic,
+				Interval: &metav1.Duration{Duration: time.Hour},
+			}}},
+			want: dependencyPolicy{
+				autoInstall:     true,
+				selection:       xpkg.VersionSelectionHighest,
+				recheck:         v1beta1.RecheckPeriodic,
+				recheckInterval: time.Hour,
+			},
+		},
+		"UpgradePolicyNone": {
+			reason: "An upgrade policy of None should override both the Reconciler's configuration and the Lock's annotation.",
+			opts:   []ReconcilerOption{WithUpgradeDependencies(), WithVersionSelection(xpkg.VersionSelectionHighest)},
+			lock: &v1beta1.Lock{
+				ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{AnnotationUpgradeDependencies: "true"}},
+				Spec:       v1beta1.LockSpec{UpgradePolicy: &none},
+			},
+			want: dependencyPolicy{
+				autoInstall: true,
+				selection:   xpkg.VersionSelectionHighest,
+			},
+		},
+		"UnlimitedOverride": {
+			reason: "A maximum of 0 in the Lock's spec should lift the Reconciler's limit.",
+			opts:   []ReconcilerOption{WithVersionSelection(xpkg.VersionSelectionHighest), WithMaxAutoInstall(5)},
+			lock:   &v1beta1.Lock{Spec: v1beta1.LockSpec{MaxAutoInstall: new(int64)}},
+			want: dependencyPolicy{
+				autoInstall: true,
+				selection:   xpkg.VersionSelectionHighest,
+			},
+		},
+		"UpgradeAnnotationIgnored": {
+			reason: "The deprecated annotation should not allow upgrades unless the Reconciler honors it.",
+			opts:   []ReconcilerOption{WithVersionSelection(xpkg.VersionSelectionHighest)},
+			lock:   &v1beta1.Lock{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{AnnotationUpgradeDependencies: "true"}}},
+			want: dependencyPolicy{
+				autoInstall: true,
+				selection:   xpkg.VersionSelectionHighest,
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			r := NewReconciler(&fake.Manager{}, tc.opts...)
+			got := r.policy(tc.lock)
+			if diff := cmp.Diff(tc.want, got, cmp.AllowUnexported(dependencyPolicy{})); diff != "" {
+				t.Errorf("\n%s\nr.policy(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestReconcilePolicy(t *testing.T) {
+	lowest := v1beta1.VersionSelectionLowest
+	one := int64(1)
+
+	type want struct {
+		created []string
+		source  string
+		reason  xpv1.ConditionReason
+	}
+
+	cases := map[string]struct {
+		reason string
+		spec   v1beta1.LockSpec
+		want   want
+	}{
+		"Defaults": {
+			reason: "We should resolve dependencies with the Reconciler's configuration if the Lock's spec sets nothing.",
+			want: want{
+				created: []string{"crossplane-provider-aws"},
+				source:  "crossplane/provider-aws:v0.35.0",
+				reason:  v1beta1.ReasonMissingDependency,
+			},
+		},
+		"SkipDependencyInstallation": {
+			reason: "We should not install missing dependencies if the Lock's spec skips dependency installation.",
+			spec:   v1beta1.LockSpec{SkipDependencyInstallation: true},
+			want: want{
+				reason: v1beta1.ReasonMissingDependency,
+			},
+		},
+		"VersionSelection": {
+			reason: "We should select versions as the Lock's spec specifies, rather than as the Reconciler is configured.",
+			spec:   v1beta1.LockSpec{VersionSelection: &lowest},
+			want: want{
+				created: []string{"crossplane-provider-aws"},
+				source:  "crossplane/provider-aws:v0.20.0",
+				reason:  v1beta1.ReasonMissingDependency,
+			},
+		},
+		"MaxAutoInstall": {
+			reason: "We should limit automatic installation as the Lock's spec specifies, rather than as the Reconciler is configured.",
+			spec:   v1beta1.LockSpec{MaxAutoInstall: &one},
+			want: want{
+				reason: v1beta1.ReasonLimitReached,
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var created []string
+			var source string
+			var status *v1beta1.Lock
+			mgr := &fake.Manager{
+				Client: &test.MockClient{
+					MockGet: test.NewMockGetFn(nil, func(o client.Object) error {
+						l := o.(*v1beta1.Lock)
+						l.SetName("lock")
+						l.Spec = tc.spec
+						l.Packages = []v1beta1.LockPackage{{
+							Source:       "cool/config",
+							Dependencies: []v1beta1.Dependency{{Package: "crossplane/provider-aws", Type: v1beta1.ProviderPackageType, Constraints: ">=v0.20.0"}},
+						}}
+						return nil
+					}),
+					MockList: test.NewMockListFn(nil, func(o client.ObjectList) error {
+						if l, ok := o.(*v1.ProviderList); ok {
+							l.Items = []v1.Provider{{ObjectMeta: metav1.ObjectMeta{
+								Name:   "provider-gcp",
+								Labels: map[string]string{LabelAutoInstalled: "true"},
+							}}}
+						}
+						return nil
+					}),
+					MockCreate: func(_ context.Context, o client.Object, _ ...client.CreateOption) error {
+						created = append(created, o.GetName())
+						source = o.(v1.Package).GetSource()
+						return nil
+					},
+					MockUpdate: test.NewMockUpdateFn(nil),
+					MockStatusUpdate: test.NewMockStatusUpdateFn(nil, func(o client.Object) error {
+						status = o.(*v1beta1.Lock)
+						return nil
+					}),
+				},
+			}
+			r := NewReconciler(mgr,
+				WithVersionSelection(xpkg.VersionSelectionHighest),
+				WithMaxAutoInstall(5),
+				WithFetcher(&fakexpkg.MockFetcher{MockTags: fakexpkg.NewMockTagsFn([]string{"v0.19.0", "v0.20.0", "v0.35.0"}, nil)}),
+				WithNewDagFn(dag.NewMapDag),
+			)
+			if _, err := r.Reconcile(context.Background(), reconcile.Request{}); err != nil {
+				t.Fatalf("\n%s\nr.Reconcile(...): %s", tc.reason, err)
+			}
+
+			if diff := cmp.Diff(tc.want.created, created); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want created, +got created:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.source, source); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want source, +got source:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.reason, status.GetCondition(v1beta1.TypeDependenciesResolved).Reason); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want condition reason, +got condition reason:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"sort"
+
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+	"github.com/crossplane/crossplane/internal/xpkg"
+)
+
+// installPriority orders the creation of missing dependencies of different
+// types within a pass. Providers are created first, because Configurations
+// typically compose the resources whose CRDs they install, and revisions of
+// a Configuration created before them would fail to establish until they
+// do. Functions are created next, before the Configurations whose
+// Compositions use them. Types without a priority are created last.
+var installPriority = map[v1beta1.PackageType]int{
+	v1beta1.ProviderPackageType:      0,
+	v1beta1.FunctionPackageType:      1,
+	v1beta1.ConfigurationPackageType: 2,
+}
+
+// prioritize sorts the supplied planned installs in the order they should be
+// created: by the priority of their type, then by identifier.
+func prioritize(plan []xpkg.PlannedInstall) {
+	priority := func(t v1beta1.PackageType) int {
+		if p, ok := installPriority[t]; ok {
+			return p
+		}
+		return len(installPriority)
+	}
+	sort.SliceStable(plan, func(i, j int) bool {
+		pi, pj := priority(plan[i].Dependency.Type), priority(plan[j].Dependency.Type)
+		if pi != pj {
+			return pi < pj
+		}
+		return plan[i].Dependency.Identifier() < plan[j].Dependency.Identifier()
+	})
+}
@@ -0,0 +1,169 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/crossplane/crossplane-runtime/pkg/resource/fake"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+	"github.com/crossplane/crossplane/internal/dag"
+	"github.com/crossplane/crossplane/internal/xpkg"
+	fakexpkg "github.com/crossplane/crossplane/internal/xpkg/fake"
+)
+
+func TestPrioritize(t *testing.T) {
+	install := func(pkg string, t v1beta1.PackageType) xpkg.PlannedInstall {
+		return xpkg.PlannedInstall{Dependency: v1beta1.Dependency{Package: pkg, Type: t}}
+	}
+
+	cases := map[string]struct {
+		reason string
+		plan   []xpkg.PlannedInstall
+		want   []xpkg.PlannedInstall
+	}{
+		"Mixed": {
+			reason: "Providers should be ordered before Configurations, and packages of the same type by identifier.",
+			plan: []xpkg.PlannedInstall{
+				install("crossplane/config-b", v1beta1.ConfigurationPackageType),
+				install("crossplane/provider-gcp", v1beta1.ProviderPackageType),
+				install("crossplane/config-a", v1beta1.ConfigurationPackageType),
+				install("crossplane/provider-aws", v1beta1.ProviderPackageType),
+			},
+			want: []xpkg.PlannedInstall{
+				install("crossplane/provider-aws", v1beta1.ProviderPackageType),
+				install("crossplane/provider-gcp", v1beta1.ProviderPackageType),
+				install("crossplane/config-a", v1beta1.ConfigurationPackageType),
+				install("crossplane/config-b", v1beta1.ConfigurationPackageType),
+			},
+		},
+		"Functions": {
+			reason: "Functions should be ordered after Providers and before Configurations.",
+			plan: []xpkg.PlannedInstall{
+				install("crossplane/config-a", v1beta1.ConfigurationPackageType),
+				install("crossplane/function-a", v1beta1.FunctionPackageType),
+				install("crossplane/provider-aws", v1beta1.ProviderPackageType),
+			},
+			want: []xpkg.PlannedInstall{
+				install("crossplane/provider-aws", v1beta1.ProviderPackageType),
+				install("crossplane/function-a", v1beta1.FunctionPackageType),
+				install("crossplane/config-a", v1beta1.ConfigurationPackageType),
+			},
+		},
+		"UnknownType": {
+			reason: "Packages of a type without a priority should be ordered last.",
+			plan: []xpkg.PlannedInstall{
+				install("crossplane/composition-a", "Composition"),
+				install("crossplane/config-a", v1beta1.ConfigurationPackageType),
+			},
+			want: []xpkg.PlannedInstall{
+				install("crossplane/config-a", v1beta1.ConfigurationPackageType),
+				install("crossplane/composition-a", "Composition"),
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			prioritize(tc.plan)
+			if diff := cmp.Diff(tc.want, tc.plan); diff != "" {
+				t.Errorf("\n%s\nprioritize(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestReconcileCreationOrder(t *testing.T) {
+	provider := func(pkg string) v1beta1.Dependency {
+		return v1beta1.Dependency{Package: pkg, Type: v1beta1.ProviderPackageType, Constraints: ">=v0.20.0"}
+	}
+	config := func(pkg string) v1beta1.Dependency {
+		return v1beta1.Dependency{Package: pkg, Type: v1beta1.ConfigurationPackageType, Constraints: ">=v0.20.0"}
+	}
+
+	cases := map[string]struct {
+		reason string
+		deps   []v1beta1.Dependency
+		want   []string
+	}{
+		"MixedLayer": {
+			reason: "We should create the Providers of a layer before its Configurations.",
+			deps: []v1beta1.Dependency{
+				config("crossplane/config-network"),
+				provider("crossplane/provider-gcp"),
+				config("crossplane/config-database"),
+				provider("crossplane/provider-aws"),
+			},
+			want: []string{
+				"crossplane-provider-aws",
+				"crossplane-provider-gcp",
+				"crossplane-config-database",
+				"crossplane-config-network",
+			},
+		},
+		"ConfigurationsOnly": {
+			reason: "We should create the Configurations of a layer that has no Providers by identifier.",
+			deps: []v1beta1.Dependency{
+				config("crossplane/config-network"),
+				config("crossplane/config-database"),
+			},
+			want: []string{
+				"crossplane-config-database",
+				"crossplane-config-network",
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var created []string
+			mgr := &fake.Manager{
+				Client: &test.MockClient{
+					MockGet: test.NewMockGetFn(nil, func(o client.Object) error {
+						o.SetName("lock")
+						o.(*v1beta1.Lock).Packages = []v1beta1.LockPackage{{Source: "cool/config", Dependencies: tc.deps}}
+						return nil
+					}),
+					MockList: test.NewMockListFn(nil),
+					MockCreate: func(_ context.Context, o client.Object, _ ...client.CreateOption) error {
+						created = append(created, o.GetName())
+						return nil
+					},
+					MockUpdate:       test.NewMockUpdateFn(nil),
+					MockStatusUpdate: test.NewMockStatusUpdateFn(nil),
+				},
+			}
+			r := NewReconciler(mgr,
+				WithFetcher(&fakexpkg.MockFetcher{MockTags: fakexpkg.NewMockTagsFn([]string{"v0.20.0"}, nil)}),
+				WithNewDagFn(dag.NewMapDag),
+			)
+			if _, err := r.Reconcile(context.Background(), reconcile.Request{}); err != nil {
+				t.Fatalf("\n%s\nr.Reconcile(...): %s", tc.reason, err)
+			}
+			if diff := cmp.Diff(tc.want, created); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want creation order, +got creation order:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+	"github.com/crossplane/crossplane/internal/xpkg"
+	"github.com/crossplane/crossplane/internal/xpkg/canonical"
+)
+
+// Annotations recording why and when the resolver installed or upgraded a
+// package. They're updated each time the resolver selects a new version of
+// the package, so they describe the most recent resolution.
+const (
+	// AnnotationResolvedConstraint is a JSON object mapping the source of
+	// each package in the Lock that required the package to the constraint
+	// it placed on the package's version when it was resolved.
+	AnnotationResolvedConstraint = "pkg.crossplane.io/resolved-constraint"
+
+	// AnnotationResolvedVersion is the tag or digest the resolver selected.
+	AnnotationResolvedVersion = "pkg.crossplane.io/resolved-version"
+
+	// AnnotationResolvedAt is the RFC 3339 time at which the resolver
+	// selected the version.
+	AnnotationResolvedAt = "pkg.crossplane.io/resolved-at"
+
+	// AnnotationResolvedOverride is the override of the package's version
+	// that the Lock specified when the version was selected. It's omitted
+	// if the Lock didn't override the package's version.
+	AnnotationResolvedOverride = "pkg.crossplane.io/resolved-override"
+)
+
+// provenance returns the annotations recording that the supplied version of
+// the supplied package source was resolved at the supplied time, to satisfy
+// the packages in the Lock that depend on it, or to satisfy the Lock's
+// override of its version.
+func provenance(lock *v1beta1.Lock, source, version string, at time.Time) map[string]string {
+	constraints := map[string]string{}
+	for _, p := range lock.Packages {
+		for _, d := range p.Dependencies {
+			if d.Identifier() == canonical.Source(source) {
+				constraints[p.Source] = d.Constraints
+				break
+			}
+		}
+	}
+
+	a := map[string]string{
+		AnnotationRequiredBy:         strings.Join(requiredBy(lock, source), ","),
+		AnnotationResolvedConstraint: marshal(constraints),
+		AnnotationResolvedVersion:    version,
+		AnnotationResolvedAt:         at.UTC().Format(time.RFC3339),
+	}
+
+	if o, ok := xpkg.NewOverrides(lock.Spec.Overrides).For(source); ok {
+		a[AnnotationResolvedOverride] = o
+	}
+	return a
+}
+
+// marshal returns the supplied constraints as a JSON object. Constraints are
+// not HTML escaped, so that they're legible in kubectl describe output.
+func marshal(constraints map[string]string) string {
+	b := &bytes.Buffer{}
+	e := json.NewEncoder(b)
+	e.SetEscapeHTML(false)
+
+	// Encoding a map of strings cannot fail.
+	_ = e.Encode(constraints)
+	return strings.TrimSpace(b.String())
+}
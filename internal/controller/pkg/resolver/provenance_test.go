@@ -0,0 +1,214 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/crossplane/crossplane-runtime/pkg/resource/fake"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	v1 "github.com/crossplane/crossplane/apis/pkg/v1"
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+	"github.com/crossplane/crossplane/internal/dag"
+	fakexpkg "github.com/crossplane/crossplane/internal/xpkg/fake"
+)
+
+func TestProvenance(t *testing.T) {
+	at := time.Date(2021, 9, 1, 12, 0, 0, 0, time.FixedZone("PDT", -7*60*60))
+
+	cases := map[string]struct {
+		reason  string
+		pkgs    []v1beta1.LockPackage
+		version string
+		want    map[string]string
+	}{
+		"SingleParent": {
+			reason: "We should record the package that required the dependency, and its constraint.",
+			pkgs: []v1beta1.LockPackage{
+				{Source: "cool/config-a", Dependencies: []v1beta1.Dependency{{Package: "crossplane/provider-aws", Constraints: ">=v0.20.0"}}},
+				{Source: "cool/config-b", Dependencies: []v1beta1.Dependency{{Package: "crossplane/provider-gcp", Constraints: ">=v0.15.0"}}},
+			},
+			version: "v0.21.0",
+			want: map[string]string{
+				AnnotationRequiredBy:         "cool/config-a",
+				AnnotationResolvedConstraint: `{"cool/config-a":">=v0.20.0"}`,
+				AnnotationResolvedVersion:    "v0.21.0",
+				AnnotationResolvedAt:         "2021-09-01T19:00:00Z",
+			},
+		},
+		"MultipleParents": {
+			reason: "We should record every package that required the dependency, and each of their constraints.",
+			pkgs: []v1beta1.LockPackage{
+				{Source: "cool/config-b", Dependencies: []v1beta1.Dependency{{Package: "crossplane/provider-aws", Constraints: "<v1.0.0"}}},
+				{Source: "cool/config-a", Dependencies: []v1beta1.Dependency{
+					{Package: "crossplane/provider-gcp", Constraints: ">=v0.15.0"},
+					{Package: "crossplane/provider-aws", Constraints: ">=v0.20.0, <v0.30.0"},
+				}},
+			},
+			version: "v0.21.0",
+			want: map[string]string{
+				AnnotationRequiredBy:         "cool/config-a,cool/config-b",
+				AnnotationResolvedConstraint: `{"cool/config-a":">=v0.20.0, <v0.30.0","cool/config-b":"<v1.0.0"}`,
+				AnnotationResolvedVersion:    "v0.21.0",
+				AnnotationResolvedAt:         "2021-09-01T19:00:00Z",
+			},
+		},
+		"Pinned": {
+			reason: "We should record the digest a dependency was pinned to as its version.",
+			pkgs: []v1beta1.LockPackage{
+				{Source: "cool/config-a", Dependencies: []v1beta1.Dependency{{Package: "crossplane/provider-aws", Constraints: "sha256:ecc25c12"}}},
+			},
+			version: "sha256:ecc25c12",
+			want: map[string]string{
+				AnnotationRequiredBy:         "cool/config-a",
+				AnnotationResolvedConstraint: `{"cool/config-a":"sha256:ecc25c12"}`,
+				AnnotationResolvedVersion:    "sha256:ecc25c12",
+				AnnotationResolvedAt:         "2021-09-01T19:00:00Z",
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := provenance(&v1beta1.Lock{Packages: tc.pkgs}, "crossplane/provider-aws", tc.version, at)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nprovenance(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestReconcileProvenance(t *testing.T) {
+	at := time.Date(2021, 9, 1, 12, 0, 0, 0, time.UTC)
+	tags := []string{"v0.19.0", "v0.20.0", "v0.21.0", "v0.35.0"}
+
+	var created v1.Package
+	mgr := &fake.Manager{
+		Client: &test.MockClient{
+			MockGet: test.NewMockGetFn(nil, func(o client.Object) error {
+				o.(*v1beta1.Lock).Packages = []v1beta1.LockPackage{
+					{Source: "cool/config-a", Dependencies: []v1beta1.Dependency{{Package: "crossplane/provider-aws", Type: v1beta1.ProviderPackageType, Constraints: ">=v0.20.0"}}},
+					{Source: "cool/config-b", Dependencies: []v1beta1.Dependency{{Package: "crossplane/provider-aws", Type: v1beta1.ProviderPackageType, Constraints: "<v0.30.0"}}},
+				}
+				return nil
+			}),
+			MockUpdate: test.NewMockUpdateFn(nil),
+			MockList:   test.NewMockListFn(nil),
+			MockCreate: func(_ context.Context, o client.Object, _ ...client.CreateOption) error {
+				created = o.(v1.Package)
+				return nil
+			},
+			MockStatusUpdate: test.NewMockStatusUpdateFn(nil),
+		},
+	}
+	r := NewReconciler(mgr,
+		WithFetcher(&fakexpkg.MockFetcher{MockTags: fakexpkg.NewMockTagsFn(tags, nil)}),
+		WithNewDagFn(dag.NewMapDag),
+	)
+	r.now = func() time.Time { return at }
+	_, _ = r.Reconcile(context.Background(), reconcile.Request{})
+
+	if created == nil {
+		t.Fatal("r.Reconcile(...): expected a dependency to be created")
+	}
+
+	// The version we select satisfies both constraints.
+	want := map[string]string{
+		AnnotationRequiredBy:         "cool/config-a,cool/config-b",
+		AnnotationResolvedConstraint: `{"cool/config-a":">=v0.20.0","cool/config-b":"<v0.30.0"}`,
+		AnnotationResolvedVersion:    "v0.21.0",
+		AnnotationResolvedAt:         "2021-09-01T12:00:00Z",
+		AnnotationVersionSelection:   "Highest",
+	}
+	if diff := cmp.Diff(want, created.GetAnnotations()); diff != "" {
+		t.Errorf("r.Reconcile(...): -want annotations, +got annotations:\n%s", diff)
+	}
+}
+
+func TestReconcileUpgradeProvenance(t *testing.T) {
+	at := time.Date(2021, 9, 1, 12, 0, 0, 0, time.UTC)
+
+	var updated v1.Package
+	mgr := &fake.Manager{
+		Client: &test.MockClient{
+			MockGet: test.NewMockGetFn(nil, func(o client.Object) error {
+				l := o.(*v1beta1.Lock)
+				l.SetName("lock")
+				l.SetAnnotations(map[string]string{AnnotationUpgradeDependencies: "true"})
+				l.Packages = []v1beta1.LockPackage{
+					{Source: "crossplane/provider-aws", Version: "v0.20.0"},
+					{Source: "cool/config-a", Dependencies: []v1beta1.Dependency{{Package: "crossplane/provider-aws", Constraints: ">=v0.20.0"}}},
+					{Source: "cool/config-b", Dependencies: []v1beta1.Dependency{{Package: "crossplane/provider-aws", Constraints: ">=v0.21.0"}}},
+				}
+				return nil
+			}),
+			MockList: test.NewMockListFn(nil, func(o client.ObjectList) error {
+				if l, ok := o.(*v1.ProviderList); ok {
+					// The provider was installed to satisfy config-a alone.
+					p := v1.Provider{ObjectMeta: metav1.ObjectMeta{
+						Name:   "crossplane-provider-aws",
+						Labels: map[string]string{LabelAutoInstalled: "true"},
+						Annotations: map[string]string{
+							AnnotationRequiredBy:         "cool/config-a",
+							AnnotationResolvedConstraint: `{"cool/config-a":">=v0.20.0"}`,
+							AnnotationResolvedVersion:    "v0.20.0",
+							AnnotationResolvedAt:         "2021-08-01T12:00:00Z",
+						},
+					}}
+					p.SetSource("crossplane/provider-aws:v0.20.0")
+					l.Items = []v1.Provider{p}
+				}
+				return nil
+			}),
+			MockUpdate: func(_ context.Context, o client.Object, _ ...client.UpdateOption) error {
+				if p, ok := o.(v1.Package); ok {
+					updated = p
+				}
+				return nil
+			},
+			MockStatusUpdate: test.NewMockStatusUpdateFn(nil),
+		},
+	}
+	r := NewReconciler(mgr,
+		WithUpgradeDependencies(),
+		WithFetcher(&fakexpkg.MockFetcher{MockTags: fakexpkg.NewMockTagsFn([]string{"v0.20.0", "v0.21.0"}, nil)}),
+		WithNewDagFn(dag.NewMapDag),
+	)
+	r.now = func() time.Time { return at }
+	_, _ = r.Reconcile(context.Background(), reconcile.Request{})
+
+	if updated == nil {
+		t.Fatal("r.Reconcile(...): expected the dependency to be upgraded")
+	}
+	want := map[string]string{
+		AnnotationRequiredBy:         "cool/config-a,cool/config-b",
+		AnnotationResolvedConstraint: `{"cool/config-a":">=v0.20.0","cool/config-b":">=v0.21.0"}`,
+		AnnotationResolvedVersion:    "v0.21.0",
+		AnnotationResolvedAt:         "2021-09-01T12:00:00Z",
+	}
+	if diff := cmp.Diff(want, updated.GetAnnotations()); diff != "" {
+		t.Errorf("r.Reconcile(...): -want annotations, +got annotations:\n%s", diff)
+	}
+}
@@ -0,0 +1,235 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/meta"
+	"github.com/crossplane/crossplane-runtime/pkg/resource"
+
+	v1 "github.com/crossplane/crossplane/apis/pkg/v1"
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+	"github.com/crossplane/crossplane/internal/dag"
+	"github.com/crossplane/crossplane/internal/xpkg"
+	"github.com/crossplane/crossplane/internal/xpkg/canonical"
+)
+
+// AnnotationOrphanedAt records when an auto-installed package was first found
+// to be required by no package in the Lock, in RFC 3339 format. A package is
+// deleted once it has been orphaned for the grace period.
+const AnnotationOrphanedAt = "pkg.crossplane.io/orphaned-at"
+
+const (
+	errBuildDependents = "cannot build dependency graph of the Lock"
+
+	reasonPruneDependency event.Reason = "PruneDependency"
+
+	msgOrphanedFmt  = "Package %s is no longer required by any package in the Lock, and will be deleted after %s"
+	msgReadoptedFmt = "Package %s is required by %s again, and will not be deleted"
+	msgPruningFmt   = "Deleting package %s, which no package in the Lock has required since %s"
+	msgPrunedFmt    = "Deleted package %s, which no package in the Lock required"
+)
+
+// DefaultOrphanGracePeriod is how long a package the Reconciler auto-installed
+// must be required by no package in the Lock before it is deleted, unless
+// another grace period is specified. It spans several default sync periods, so
+// the Reconciler has several chances to observe that the package is required
+// again.
+const DefaultOrphanGracePeriod = 3 * time.Hour
+
+// WithOrphanGracePeriod specifies how long a package the Reconciler
+// auto-installed must be required by no package in the Lock before it is
+// deleted, when the Reconciler's dependency GC policy is Delete. A grace period
+// avoids deleting a package that is only briefly orphaned, e.g. while the
+// package that requires it is upgraded. The default is
+// DefaultOrphanGracePeriod.
+func WithOrphanGracePeriod(d time.Duration) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.orphanGrace = d
+	}
+}
+
+// PruneOptions configure how unused dependencies are pruned.
+type PruneOptions struct {
+	// Policy determines whether orphaned packages are deleted, or only
+	// labeled as orphaned.
+	Policy DependencyGCPolicy
+
+	// GracePeriod for which a package must be orphaned before it is
+	// deleted.
+	GracePeriod time.Duration
+
+	// Registry used to qualify package sources that don't specify one.
+	Registry string
+
+	// Now is the time at which packages are pruned. Defaults to the current
+	// time.
+	Now time.Time
+
+	// Recorder records events before and after a package is deleted.
+	// Defaults to a recorder that records nothing.
+	Recorder event.Recorder
+
+	// Deleted is called with the source of each package that is deleted,
+	// if it is set.
+	Deleted func(p v1.Package, source string)
+}
+
+// PruneUnusedDependencies prunes the supplied packages that were
+// auto-installed to satisfy a dependency of a package in the supplied Lock,
+// but that no package in the Lock depends on any more. Packages without the
+// auto-installed label, including those a user adopted by removing it, are
+// never pruned. An orphaned package is annotated with the time it was first
+// orphaned, and deleted once it has been orphaned for the grace period. A
+// package that is required again during the grace period is kept. It returns
+// how long until the next orphaned package is due to be deleted, if any.
+func PruneUnusedDependencies(ctx context.Context, c client.Client, lock *v1beta1.Lock, pkgs []v1.Package, o PruneOptions) (time.Duration, error) {
+	if o.Now.IsZero() {
+		o.Now = time.Now()
+	}
+	if o.Recorder == nil {
+		o.Recorder = event.NewNopRecorder()
+	}
+
+	d := dag.NewMapDag()
+	nodes := make([]dag.Node, len(lock.Packages))
+	for i := range lock.Packages {
+		nodes[i] = &lock.Packages[i]
+	}
+	if _, err := d.Init(nodes); err != nil {
+		return 0, errors.Wrap(err, errBuildDependents)
+	}
+
+	var next time.Duration
+	for _, p := range pkgs {
+		if p.GetLabels()[LabelAutoInstalled] != "true" {
+			continue
+		}
+		source, _, err := xpkg.ParseSource(p.GetSource())
+		if err != nil {
+			return 0, errors.Wrap(err, errParseSource)
+		}
+		source = qualify(o.Registry, source)
+		parents := dependentSources(d, source)
+		after, err := prune(ctx, c, lock, p, source, parents, o)
+		if err != nil {
+			return 0, err
+		}
+		if after > 0 && (next == 0 || after < next) {
+			next = after
+		}
+	}
+	return next, nil
+}
+
+// dependentSources returns the sorted sources of the packages in the supplied
+// dependency graph that depend on the supplied package source.
+func dependentSources(d dag.DAG, source string) []string {
+	parents := []string{}
+	nodes, err := d.NodeDependents(canonical.Source(source))
+	if err != nil {
+		// A package that isn't in the graph has no dependents.
+		return parents
+	}
+	for _, n := range nodes {
+		if lp, ok := n.(*v1beta1.LockPackage); ok {
+			parents = append(parents, lp.Source)
+		}
+	}
+	sort.Strings(parents)
+	return parents
+}
+
+// orphanedAt returns when the supplied package was first orphaned, if it has
+// been.
+func orphanedAt(p v1.Package) (time.Time, bool) {
+	v, ok := p.GetAnnotations()[AnnotationOrphanedAt]
+	if !ok {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		// We restart the grace period of a package whose annotation we
+		// can't parse, rather than deleting it early.
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// prune the supplied package, which is installed from the supplied source and
+// required by the supplied parents. It
+// returns how long until the package is due to be deleted, if it's orphaned
+// but still within its grace period.
+func prune(ctx context.Context, c client.Client, lock *v1beta1.Lock, p v1.Package, source string, parents []string, o PruneOptions) (time.Duration, error) {
+	orphaned := len(parents) == 0
+	since, wasOrphaned := orphanedAt(p)
+
+	var after time.Duration
+	if orphaned && o.Policy == DependencyGCDelete {
+		if !wasOrphaned {
+			since = o.Now
+		}
+		after = since.Add(o.GracePeriod).Sub(o.Now)
+		if after <= 0 {
+			o.Recorder.Event(lock, event.Normal(reasonPruneDependency, fmt.Sprintf(msgPruningFmt, p.GetName(), since.Format(time.RFC3339))))
+			if err := resource.IgnoreNotFound(c.Delete(ctx, p)); err != nil {
+				return 0, errors.Wrap(err, errDeletePackage)
+			}
+			o.Recorder.Event(lock, event.Normal(reasonPruneDependency, fmt.Sprintf(msgPrunedFmt, p.GetName())))
+			if o.Deleted != nil {
+				o.Deleted(p, source)
+			}
+			return 0, nil
+		}
+	}
+
+	want := strings.Join(parents, ",")
+	_, labeled := p.GetLabels()[LabelOrphaned]
+	if p.GetAnnotations()[AnnotationRequiredBy] == want && labeled == orphaned && wasOrphaned == (after > 0) {
+		return after, nil
+	}
+
+	meta.AddAnnotations(p, map[string]string{AnnotationRequiredBy: want})
+	meta.RemoveLabels(p, LabelOrphaned)
+	meta.RemoveAnnotations(p, AnnotationOrphanedAt)
+	if orphaned {
+		meta.AddLabels(p, map[string]string{LabelOrphaned: "true"})
+	}
+	if after > 0 {
+		meta.AddAnnotations(p, map[string]string{AnnotationOrphanedAt: since.Format(time.RFC3339)})
+	}
+	if err := c.Update(ctx, p); err != nil {
+		return 0, errors.Wrap(err, errUpdatePackage)
+	}
+
+	switch {
+	case after > 0 && !wasOrphaned:
+		o.Recorder.Event(lock, event.Normal(reasonPruneDependency, fmt.Sprintf(msgOrphanedFmt, p.GetName(), o.GracePeriod)))
+	case !orphaned && wasOrphaned:
+		o.Recorder.Event(lock, event.Normal(reasonPruneDependency, fmt.Sprintf(msgReadoptedFmt, p.GetName(), want)))
+	}
+	return after, nil
+}
@@ -0,0 +1,220 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/meta"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	v1 "github.com/crossplane/crossplane/apis/pkg/v1"
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+)
+
+func TestPruneUnusedDependencies(t *testing.T) {
+	errBoom := errors.New("boom")
+	now := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	grace := time.Hour
+
+	// The Lock contains provider-aws, but nothing requires it.
+	orphaned := &v1beta1.Lock{
+		ObjectMeta: metav1.ObjectMeta{Name: "lock"},
+		Packages: []v1beta1.LockPackage{
+			{Source: "crossplane/provider-aws", Type: v1beta1.ProviderPackageType, Version: "v0.20.0"},
+		},
+	}
+	// The Lock contains provider-aws, and cool/config requires it.
+	required := &v1beta1.Lock{
+		ObjectMeta: metav1.ObjectMeta{Name: "lock"},
+		Packages: []v1beta1.LockPackage{
+			{Source: "cool/config", Type: v1beta1.ConfigurationPackageType, Dependencies: []v1beta1.Dependency{
+				{Package: "crossplane/provider-aws", Type: v1beta1.ProviderPackageType, Constraints: ">=v0.20.0"},
+			}},
+			{Source: "crossplane/provider-aws", Type: v1beta1.ProviderPackageType, Version: "v0.20.0"},
+		},
+	}
+
+	provider := func(labels, annotations map[string]string) *v1.Provider {
+		// Packages are updated in place, so each gets its own copy of the
+		// supplied labels and annotations.
+		p := &v1.Provider{ObjectMeta: metav1.ObjectMeta{Name: "crossplane-provider-aws", Labels: map[string]string{}}}
+		meta.AddLabels(p, labels)
+		if annotations != nil {
+			p.SetAnnotations(map[string]string{})
+			meta.AddAnnotations(p, annotations)
+		}
+		p.SetSource("crossplane/provider-aws:v0.20.0")
+		return p
+	}
+	auto := map[string]string{LabelAutoInstalled: "true"}
+	autoOrphaned := map[string]string{LabelAutoInstalled: "true", LabelOrphaned: "true"}
+	orphanedSince := func(d time.Duration) map[string]string {
+		return map[string]string{AnnotationRequiredBy: "", AnnotationOrphanedAt: now.Add(-d).Format(time.RFC3339)}
+	}
+
+	type args struct {
+		lock   *v1beta1.Lock
+		pkg    *v1.Provider
+		policy DependencyGCPolicy
+		delete error
+	}
+	type want struct {
+		after    time.Duration
+		err      error
+		updated  *v1.Provider
+		deleted  []string
+		messages []string
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"UserAdopted": {
+			reason: "We should never prune a package a user adopted by removing its auto-installed label.",
+			args: args{
+				lock:   orphaned,
+				pkg:    provider(map[string]string{LabelOrphaned: "true"}, orphanedSince(2*grace)),
+				policy: DependencyGCDelete,
+			},
+			want: want{},
+		},
+		"FirstOrphaned": {
+			reason: "We should record when an auto-installed package is first orphaned, and not delete it until its grace period has elapsed.",
+			args: args{
+				lock:   orphaned,
+				pkg:    provider(auto, nil),
+				policy: DependencyGCDelete,
+			},
+			want: want{
+				after:    grace,
+				updated:  provider(autoOrphaned, orphanedSince(0)),
+				messages: []string{fmt.Sprintf(msgOrphanedFmt, "crossplane-provider-aws", grace)},
+			},
+		},
+		"WithinGracePeriod": {
+			reason: "We should keep an orphaned package until its grace period has elapsed.",
+			args: args{
+				lock:   orphaned,
+				pkg:    provider(autoOrphaned, orphanedSince(grace/2)),
+				policy: DependencyGCDelete,
+			},
+			want: want{
+				after: grace / 2,
+			},
+		},
+		"OrphanedThenDeleted": {
+			reason: "We should delete a package that has been orphaned for its grace period, recording events before and after.",
+			args: args{
+				lock:   orphaned,
+				pkg:    provider(autoOrphaned, orphanedSince(2*grace)),
+				policy: DependencyGCDelete,
+			},
+			want: want{
+				deleted: []string{"crossplane-provider-aws"},
+				messages: []string{
+					fmt.Sprintf(msgPruningFmt, "crossplane-provider-aws", now.Add(-2*grace).Format(time.RFC3339)),
+					fmt.Sprintf(msgPrunedFmt, "crossplane-provider-aws"),
+				},
+			},
+		},
+		"OrphanedThenReadopted": {
+			reason: "We should keep a package that acquired a dependent during its grace period.",
+			args: args{
+				lock:   required,
+				pkg:    provider(autoOrphaned, orphanedSince(grace/2)),
+				policy: DependencyGCDelete,
+			},
+			want: want{
+				updated:  provider(auto, map[string]string{AnnotationRequiredBy: "cool/config"}),
+				messages: []string{fmt.Sprintf(msgReadoptedFmt, "crossplane-provider-aws", "cool/config")},
+			},
+		},
+		"OrphanPolicy": {
+			reason: "We should only label an orphaned package if our policy is to orphan it.",
+			args: args{
+				lock:   orphaned,
+				pkg:    provider(auto, nil),
+				policy: DependencyGCOrphan,
+			},
+			want: want{
+				updated: provider(autoOrphaned, map[string]string{AnnotationRequiredBy: ""}),
+			},
+		},
+		"ErrDelete": {
+			reason: "We should return any error encountered deleting a package whose grace period has elapsed.",
+			args: args{
+				lock:   orphaned,
+				pkg:    provider(autoOrphaned, orphanedSince(2*grace)),
+				policy: DependencyGCDelete,
+				delete: errBoom,
+			},
+			want: want{
+				err:      errors.Wrap(errBoom, errDeletePackage),
+				messages: []string{fmt.Sprintf(msgPruningFmt, "crossplane-provider-aws", now.Add(-2*grace).Format(time.RFC3339))},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var updated *v1.Provider
+			var deleted []string
+			rec := &recorder{}
+			c := &test.MockClient{
+				MockUpdate: func(_ context.Context, o client.Object, _ ...client.UpdateOption) error {
+					updated = o.(*v1.Provider).DeepCopy()
+					return nil
+				},
+				MockDelete: func(_ context.Context, o client.Object, _ ...client.DeleteOption) error {
+					if tc.args.delete == nil {
+						deleted = append(deleted, o.GetName())
+					}
+					return tc.args.delete
+				},
+			}
+			o := PruneOptions{Policy: tc.args.policy, GracePeriod: grace, Now: now, Recorder: rec}
+
+			after, err := PruneUnusedDependencies(context.Background(), c, tc.args.lock, []v1.Package{tc.args.pkg}, o)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nPruneUnusedDependencies(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.after, after); diff != "" {
+				t.Errorf("\n%s\nPruneUnusedDependencies(...): -want after, +got after:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.updated, updated); diff != "" {
+				t.Errorf("\n%s\nPruneUnusedDependencies(...): -want updated, +got updated:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.deleted, deleted); diff != "" {
+				t.Errorf("\n%s\nPruneUnusedDependencies(...): -want deleted, +got deleted:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.messages, rec.messages); diff != "" {
+				t.Errorf("\n%s\nPruneUnusedDependencies(...): -want messages, +got messages:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
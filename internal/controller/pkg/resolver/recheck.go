@@ -0,0 +1,290 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/crossplane-runtime/pkg/meta"
+
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+	"github.com/crossplane/crossplane/internal/xpkg"
+	"github.com/crossplane/crossplane/internal/xpkg/canonical"
+)
+
+const (
+	errRefreshDependency    = "cannot refresh dependency package"
+	errRefreshDependencyFmt = "cannot refresh dependency package %s"
+
+	msgRefreshedFmt = "refreshed package %s from %s to %s, the highest version that satisfies %s"
+)
+
+// A recheckPolicy determines when the Reconciler consults the registry about
+// a dependency that is already installed, as of a particular time.
+type recheckPolicy struct {
+	policy   v1beta1.DependencyRecheckPolicy
+	interval time.Duration
+	now      time.Time
+}
+
+// An installedDependency is a dependency that is already installed, which the
+// Reconciler may consult the registry about.
+type installedDependency struct {
+	// source of the installed package.
+	source string
+
+	// constraints the packages that depend on it place on it.
+	constraints []v1beta1.DependentConstraint
+
+	// settled is true if the installed package satisfies every package that
+	// depends on it, so the registry would only be consulted to find a newer
+	// version.
+	settled bool
+}
+
+// shouldFetch returns true if the registry should be consulted about the
+// supplied installed dependency, which was last checked as supplied. The
+// last check is nil if it was never checked. Every code path that consults
+// the registry about an installed dependency must consult shouldFetch first.
+// Missing dependencies can only be installed by consulting the registry, so
+// they never do.
+func (p recheckPolicy) shouldFetch(dep installedDependency, lastChecked *v1beta1.DependencyCheck) bool {
+	switch p.policy {
+	case v1beta1.RecheckOnChange, v1beta1.RecheckPeriodic:
+		if lastChecked == nil || !sameConstraints(dep.constraints, lastChecked.Constraints) {
+			return true
+		}
+		return p.policy == v1beta1.RecheckPeriodic && p.interval > 0 && !p.now.Before(lastChecked.LastCheckedTime.Add(p.interval))
+	default:
+		return !dep.settled
+	}
+}
+
+// next returns how long until a dependency that was last checked as supplied
+// is due to be checked again, or zero if only a change would make it due.
+func (p recheckPolicy) next(lastChecked v1beta1.DependencyCheck) time.Duration {
+	if p.policy != v1beta1.RecheckPeriodic || p.interval <= 0 {
+		return 0
+	}
+	d := lastChecked.LastCheckedTime.Add(p.interval).Sub(p.now)
+	if d <= 0 {
+		return 0
+	}
+	return d
+}
+
+// A checkLog records when the Reconciler consulted the registry about the
+// installed dependencies of a Lock during one pass, so that the Lock's recheck
+// policy can be applied on the next.
+type checkLog struct {
+	policy  recheckPolicy
+	lock    *v1beta1.Lock
+	last    map[string]v1beta1.DependencyCheck
+	checked map[string]v1beta1.DependencyCheck
+}
+
+// newCheckLog returns a log of the checks of the supplied Lock's installed
+// dependencies, which were last checked as recorded in its status, under
+// the supplied policy as of the supplied time.
+func newCheckLog(lock *v1beta1.Lock, pol dependencyPolicy, now time.Time) *checkLog {
+	l := &checkLog{
+		policy:  recheckPolicy{policy: pol.recheck, interval: pol.recheckInterval, now: now},
+		lock:    lock,
+		last:    make(map[string]v1beta1.DependencyCheck, len(lock.Status.Checked)),
+		checked: map[string]v1beta1.DependencyCheck{},
+	}
+	for _, c := range lock.Status.Checked {
+		l.last[canonical.Source(c.Package)] = c
+	}
+	return l
+}
+
+// due returns true if the registry should be consulted about the supplied
+// installed package, which is settled if it satisfies every package that
+// depends on it.
+func (l *checkLog) due(source string, settled bool) bool {
+	dep := installedDependency{source: source, constraints: constraintsOn(l.lock, source), settled: settled}
+	var last *v1beta1.DependencyCheck
+	if c, ok := l.last[canonical.Source(source)]; ok {
+		last = &c
+	}
+	return l.policy.shouldFetch(dep, last)
+}
+
+// record that the registry was consulted about the supplied installed
+// package.
+func (l *checkLog) record(source string) {
+	l.checked[canonical.Source(source)] = v1beta1.DependencyCheck{
+		Package:         source,
+		Constraints:     constraintsOn(l.lock, source),
+		LastCheckedTime: metav1.NewTime(l.policy.now),
+	}
+}
+
+// status returns the checks to record in the Lock's status, sorted by
+// package. Checks of packages that are no longer in the Lock are dropped. No
+// checks are recorded under the Never policy, which doesn't consult them.
+func (l *checkLog) status() []v1beta1.DependencyCheck {
+	if l.policy.policy != v1beta1.RecheckOnChange && l.policy.policy != v1beta1.RecheckPeriodic {
+		return nil
+	}
+	out := []v1beta1.DependencyCheck{}
+	for _, p := range l.lock.Packages {
+		id := p.Identifier()
+		if c, ok := l.checked[id]; ok {
+			out = append(out, c)
+			continue
+		}
+		if c, ok := l.last[id]; ok {
+			out = append(out, c)
+		}
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Package < out[j].Package })
+	return out
+}
+
+// wait returns how long until the first of the supplied checks is due to be
+// repeated, or zero if none is due to be repeated after an interval.
+func (l *checkLog) wait(checks []v1beta1.DependencyCheck) time.Duration {
+	var wait time.Duration
+	for _, c := range checks {
+		if d := l.policy.next(c); d > 0 && (wait == 0 || d < wait) {
+			wait = d
+		}
+	}
+	return wait
+}
+
+// constraintsOn returns the constraints the packages in the supplied Lock
+// place on the supplied package, sorted by dependent.
+func constraintsOn(lock *v1beta1.Lock, source string) []v1beta1.DependentConstraint {
+	out := []v1beta1.DependentConstraint{}
+	for _, p := range lock.Packages {
+		for _, d := range p.Dependencies {
+			if canonical.Equal(d.Package, source) {
+				out = append(out, v1beta1.DependentConstraint{Dependent: p.Source, Constraints: d.Constraints})
+			}
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Dependent != out[j].Dependent {
+			return out[i].Dependent < out[j].Dependent
+		}
+		return out[i].Constraints < out[j].Constraints
+	})
+	return out
+}
+
+// sameConstraints returns true if the supplied sorted constraints are equal.
+func sameConstraints(a, b []v1beta1.DependentConstraint) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// settled returns the packages in the supplied Lock that other packages
+// depend on and that satisfy all of them, i.e. that neither conflict as
+// supplied nor track a tag.
+func settled(lock *v1beta1.Lock, conflicts []xpkg.Conflict) []v1beta1.LockPackage {
+	unsettled := map[string]bool{}
+	for _, c := range conflicts {
+		unsettled[canonical.Source(c.Package)] = true
+	}
+	for _, t := range tracked(lock) {
+		unsettled[t.pkg.Identifier()] = true
+	}
+	out := []v1beta1.LockPackage{}
+	for _, ip := range lock.Packages {
+		if unsettled[ip.Identifier()] || len(constraintsOn(lock, ip.Source)) == 0 {
+			continue
+		}
+		out = append(out, ip)
+	}
+	return out
+}
+
+// refreshDependency upgrades the auto-installed package installed to satisfy
+// the supplied settled package to the highest version that satisfies the
+// constraints of every package that depends on it, if that is newer than the
+// installed version. Packages that were not installed by the resolver are
+// never refreshed, and versions the Reconciler's denylist denies or that it
+// rolled back are never refreshed to. Versions are listed using the supplied
+// pull secrets.
+func (r *Reconciler) refreshDependency(ctx context.Context, log logging.Logger, lock *v1beta1.Lock, ip v1beta1.LockPackage, secrets []string) (RequeueReason, error) {
+	pack, err := r.autoInstalledPackage(ctx, lock, ip.Source)
+	if err != nil {
+		return RequeueUpgradeError, errors.Wrapf(err, errRefreshDependencyFmt, ip.Source)
+	}
+	if pack == nil {
+		return "", nil
+	}
+
+	ref, err := name.ParseReference(pack.GetSource())
+	if err != nil {
+		return "", permanent(errors.Wrapf(errors.Wrap(err, errParseSource), errRefreshDependencyFmt, ip.Source))
+	}
+
+	constraints := overrideConstraints(lock, ip.Source)
+	v, _, err := r.resolver.WithDenylist(r.denylistFor(log, lock)).Refresh(ctx, withSecrets(r.fetcher, secrets), ref, constraints...)
+	var derr *xpkg.DependencyError
+	if errors.As(err, &derr) && derr.Reason == xpkg.DependencyFetchTags {
+		return RequeueFetchError, errors.Wrapf(errors.Wrap(err, errFetchTags), errRefreshDependencyFmt, ip.Source)
+	}
+	if err != nil {
+		return "", permanent(errors.Wrapf(err, errRefreshDependencyFmt, ip.Source))
+	}
+
+	// Only a newer semantic version is worth refreshing to, and only a user
+	// can decide whether a version we rolled back is safe to upgrade to
+	// again.
+	if !xpkg.IsDowngrade(v, ip.Version) || v == pack.GetAnnotations()[AnnotationRolledBackFrom] {
+		return "", nil
+	}
+
+	pack.SetSource(xpkg.VersionedSource(ip.Source, v))
+	meta.AddAnnotations(pack, provenance(lock, ip.Source, v, r.now()))
+	if r.rollbackWindow > 0 {
+		meta.AddAnnotations(pack, map[string]string{AnnotationUpgradedFrom: ip.Version})
+	}
+	if err := r.client.Update(ctx, pack); err != nil {
+		return retry(RequeueUpgradeError, err), errors.Wrapf(errors.Wrap(err, errUpdatePackage), errRefreshDependencyFmt, ip.Source)
+	}
+	msg := fmt.Sprintf(msgRefreshedFmt, ip.Source, ip.Version, v, strings.Join(constraints, ", "))
+	r.record.Event(lock, event.Normal(reasonRefreshDependency, msg))
+	r.record.Event(pack, event.Normal(reasonRefreshDependency, msg))
+	r.recordAudit(ctx, lock, AuditRecord{Action: AuditUpgraded, Source: ip.Source, Package: pack.GetName(), Version: v, From: ip.Version, Reason: msg})
+	return "", nil
+}
@@ -0,0 +1,303 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/crossplane/crossplane-runtime/pkg/resource/fake"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	v1 "github.com/crossplane/crossplane/apis/pkg/v1"
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+	"github.com/crossplane/crossplane/internal/dag"
+	fakexpkg "github.com/crossplane/crossplane/internal/xpkg/fake"
+)
+
+func TestShouldFetch(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	constraints := []v1beta1.DependentConstraint{{Dependent: "cool/config", Constraints: ">=v0.1.0"}}
+	checked := func(ago time.Duration, cs []v1beta1.DependentConstraint) *v1beta1.DependencyCheck {
+		return &v1beta1.DependencyCheck{Package: "crossplane/provider-aws", Constraints: cs, LastCheckedTime: metav1.NewTime(now.Add(-ago))}
+	}
+
+	cases := map[string]struct {
+		reason      string
+		policy      recheckPolicy
+		dep         installedDependency
+		lastChecked *v1beta1.DependencyCheck
+		want        bool
+	}{
+		"NeverSettled": {
+			reason: "We should never fetch a settled dependency under the Never policy.",
+			policy: recheckPolicy{policy: v1beta1.RecheckNever, now: now},
+			dep:    installedDependency{source: "crossplane/provider-aws", constraints: constraints, settled: true},
+			want:   false,
+		},
+		"DefaultSettled": {
+			reason: "A Lock that sets no policy should behave as if it set Never.",
+			policy: recheckPolicy{now: now},
+			dep:    installedDependency{source: "crossplane/provider-aws", constraints: constraints, settled: true},
+			want:   false,
+		},
+		"NeverUnsettled": {
+			reason: "We should always fetch a dependency that isn't settled under the Never policy, as we did before recheck policies existed.",
+			policy: recheckPolicy{policy: v1beta1.RecheckNever, now: now},
+			dep:    installedDependency{source: "crossplane/provider-aws", constraints: constraints},
+			want:   true,
+		},
+		"OnChangeNeverChecked": {
+			reason:      "We should fetch a dependency we never checked under the OnChange policy.",
+			policy:      recheckPolicy{policy: v1beta1.RecheckOnChange, now: now},
+			dep:         installedDependency{source: "crossplane/provider-aws", constraints: constraints, settled: true},
+			lastChecked: nil,
+			want:        true,
+		},
+		"OnChangeUnchanged": {
+			reason:      "We should not fetch a dependency whose constraints are unchanged under the OnChange policy, even if it isn't settled.",
+			policy:      recheckPolicy{policy: v1beta1.RecheckOnChange, now: now},
+			dep:         installedDependency{source: "crossplane/provider-aws", constraints: constraints},
+			lastChecked: checked(24*time.Hour, constraints),
+			want:        false,
+		},
+		"OnChangeConstraintChanged": {
+			reason:      "We should fetch a dependency whose constraints changed since it was checked under the OnChange policy.",
+			policy:      recheckPolicy{policy: v1beta1.RecheckOnChange, now: now},
+			dep:         installedDependency{source: "crossplane/provider-aws", constraints: constraints, settled: true},
+			lastChecked: checked(time.Minute, []v1beta1.DependentConstraint{{Dependent: "cool/config", Constraints: ">=v0.0.1"}}),
+			want:        true,
+		},
+		"OnChangeDependentAdded": {
+			reason: "We should fetch a dependency that a new package depends on under the OnChange policy.",
+			policy: recheckPolicy{policy: v1beta1.RecheckOnChange, now: now},
+			dep: installedDependency{source: "crossplane/provider-aws", settled: true, constraints: []v1beta1.DependentConstraint{
+				{Dependent: "cool/config", Constraints: ">=v0.1.0"},
+				{Dependent: "cool/other", Constraints: ">=v0.1.0"},
+			}},
+			lastChecked: checked(time.Minute, constraints),
+			want:        true,
+		},
+		"PeriodicNotDue": {
+			reason:      "We should not fetch a dependency before its interval has elapsed under the Periodic policy.",
+			policy:      recheckPolicy{policy: v1beta1.RecheckPeriodic, interval: time.Hour, now: now},
+			dep:         installedDependency{source: "crossplane/provider-aws", constraints: constraints, settled: true},
+			lastChecked: checked(59*time.Minute, constraints),
+			want:        false,
+		},
+		"PeriodicDue": {
+			reason:      "We should fetch a dependency once its interval has elapsed under the Periodic policy.",
+			policy:      recheckPolicy{policy: v1beta1.RecheckPeriodic, interval: time.Hour, now: now},
+			dep:         installedDependency{source: "crossplane/provider-aws", constraints: constraints, settled: true},
+			lastChecked: checked(time.Hour, constraints),
+			want:        true,
+		},
+		"PeriodicConstraintChanged": {
+			reason:      "We should fetch a dependency whose constraints changed under the Periodic policy, even before its interval has elapsed.",
+			policy:      recheckPolicy{policy: v1beta1.RecheckPeriodic, interval: time.Hour, now: now},
+			dep:         installedDependency{source: "crossplane/provider-aws", constraints: constraints, settled: true},
+			lastChecked: checked(time.Minute, nil),
+			want:        true,
+		},
+		"PeriodicWithoutInterval": {
+			reason:      "A Periodic policy without an interval should behave like OnChange.",
+			policy:      recheckPolicy{policy: v1beta1.RecheckPeriodic, now: now},
+			dep:         installedDependency{source: "crossplane/provider-aws", constraints: constraints, settled: true},
+			lastChecked: checked(24*time.Hour, constraints),
+			want:        false,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := tc.policy.shouldFetch(tc.dep, tc.lastChecked)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nshouldFetch(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestReconcileRecheck(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	automatic := v1beta1.UpgradePolicyAutomatic
+	constraints := []v1beta1.DependentConstraint{{Dependent: "cool/config", Constraints: ">=v0.1.0"}}
+	recheck := func(p v1beta1.DependencyRecheckPolicy, interval time.Duration) *v1beta1.DependencyRecheck {
+		rc := &v1beta1.DependencyRecheck{Policy: p}
+		if interval > 0 {
+			rc.Interval = &metav1.Duration{Duration: interval}
+		}
+		return rc
+	}
+	checked := func(ago time.Duration, constraint string) []v1beta1.DependencyCheck {
+		return []v1beta1.DependencyCheck{{
+			Package:         "crossplane/provider-aws",
+			Constraints:     []v1beta1.DependentConstraint{{Dependent: "cool/config", Constraints: constraint}},
+			LastCheckedTime: metav1.NewTime(now.Add(-ago)),
+		}}
+	}
+
+	// provider-aws v0.1.0 was installed by the resolver, and satisfies the
+	// Configuration that depends on it.
+	installed := func(o client.ObjectList) error {
+		if l, ok := o.(*v1.ProviderList); ok {
+			p := v1.Provider{ObjectMeta: metav1.ObjectMeta{Name: "crossplane-provider-aws", Labels: map[string]string{LabelAutoInstalled: "true"}}}
+			p.SetSource("crossplane/provider-aws:v0.1.0")
+			l.Items = []v1.Provider{p}
+		}
+		return nil
+	}
+
+	type want struct {
+		fetches      int
+		updated      string
+		checked      []v1beta1.DependencyCheck
+		requeueAfter time.Duration
+	}
+
+	cases := map[string]struct {
+		reason  string
+		recheck *v1beta1.DependencyRecheck
+		checked []v1beta1.DependencyCheck
+		want    want
+	}{
+		"Never": {
+			reason: "We should never consult the registry about a satisfied dependency if the Lock sets no recheck policy.",
+			want:   want{},
+		},
+		"OnChangeFirstCheck": {
+			reason:  "We should refresh a satisfied dependency we never checked under the OnChange policy, and record when we checked it.",
+			recheck: recheck(v1beta1.RecheckOnChange, 0),
+			want: want{
+				fetches: 1,
+				updated: "crossplane/provider-aws:v0.3.0",
+				checked: checked(0, ">=v0.1.0"),
+			},
+		},
+		"OnChangeUnchanged": {
+			reason:  "We should not consult the registry about a dependency whose constraints are unchanged under the OnChange policy.",
+			recheck: recheck(v1beta1.RecheckOnChange, 0),
+			checked: checked(24*time.Hour, ">=v0.1.0"),
+			want: want{
+				checked: checked(24*time.Hour, ">=v0.1.0"),
+			},
+		},
+		"OnChangeConstraintChanged": {
+			reason:  "We should consult the registry about a dependency whose constraints changed under the OnChange policy.",
+			recheck: recheck(v1beta1.RecheckOnChange, 0),
+			checked: checked(time.Minute, ">=v0.0.1"),
+			want: want{
+				fetches: 1,
+				updated: "crossplane/provider-aws:v0.3.0",
+				checked: checked(0, ">=v0.1.0"),
+			},
+		},
+		"PeriodicNotDue": {
+			reason:  "We should not consult the registry before a dependency's interval has elapsed under the Periodic policy, and should requeue when it will have.",
+			recheck: recheck(v1beta1.RecheckPeriodic, time.Hour),
+			checked: checked(20*time.Minute, ">=v0.1.0"),
+			want: want{
+				checked:      checked(20*time.Minute, ">=v0.1.0"),
+				requeueAfter: 40 * time.Minute,
+			},
+		},
+		"PeriodicDue": {
+			reason:  "We should consult the registry once a dependency's interval has elapsed under the Periodic policy, and requeue for its next check.",
+			recheck: recheck(v1beta1.RecheckPeriodic, time.Hour),
+			checked: checked(2*time.Hour, ">=v0.1.0"),
+			want: want{
+				fetches:      1,
+				updated:      "crossplane/provider-aws:v0.3.0",
+				checked:      checked(0, ">=v0.1.0"),
+				requeueAfter: time.Hour,
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var updated string
+			var status []v1beta1.DependencyCheck
+			fetches := 0
+			mgr := &fake.Manager{
+				Client: &test.MockClient{
+					MockGet: func(_ context.Context, key client.ObjectKey, o client.Object) error {
+						l, ok := o.(*v1beta1.Lock)
+						if !ok {
+							return kerrors.NewNotFound(schema.GroupResource{}, key.Name)
+						}
+						l.SetName("lock")
+						l.Spec = v1beta1.LockSpec{UpgradePolicy: &automatic, Recheck: tc.recheck}
+						l.Packages = []v1beta1.LockPackage{
+							{Source: "cool/config", Type: v1beta1.ConfigurationPackageType, Version: "v1.0.0", Dependencies: []v1beta1.Dependency{
+								{Package: "crossplane/provider-aws", Type: v1beta1.ProviderPackageType, Constraints: constraints[0].Constraints},
+							}},
+							{Source: "crossplane/provider-aws", Type: v1beta1.ProviderPackageType, Version: "v0.1.0"},
+						}
+						l.Status.Checked = tc.checked
+						return nil
+					},
+					MockList: test.NewMockListFn(nil, installed),
+					MockUpdate: func(_ context.Context, o client.Object, _ ...client.UpdateOption) error {
+						if p, ok := o.(v1.Package); ok {
+							updated = p.GetSource()
+						}
+						return nil
+					},
+					MockStatusUpdate: func(_ context.Context, o client.Object, _ ...client.UpdateOption) error {
+						status = o.(*v1beta1.Lock).Status.Checked
+						return nil
+					},
+				},
+			}
+			r := NewReconciler(mgr,
+				WithRecorder(&recorder{}),
+				WithFetcher(&fakexpkg.MockFetcher{MockTags: func() ([]string, error) {
+					fetches++
+					return []string{"v0.1.0", "v0.2.0", "v0.3.0"}, nil
+				}}),
+				WithNewDagFn(dag.NewMapDag),
+			)
+			r.now = func() time.Time { return now }
+			got, err := r.Reconcile(context.Background(), reconcile.Request{})
+			if err != nil {
+				t.Errorf("\n%s\nr.Reconcile(...): unexpected error: %v", tc.reason, err)
+			}
+
+			if diff := cmp.Diff(tc.want.fetches, fetches); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want fetches, +got fetches:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.updated, updated); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want updated source, +got updated source:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.checked, status); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want checked, +got checked:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.requeueAfter, got.RequeueAfter); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want requeue after, +got requeue after:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
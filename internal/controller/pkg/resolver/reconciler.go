@@ -19,26 +19,35 @@ package resolver
 import (
 	"context"
 	"fmt"
-	"sort"
 	"strings"
 	"time"
 
-	"github.com/Masterminds/semver"
 	"github.com/google/go-containerregistry/pkg/name"
+	"go.opentelemetry.io/otel/trace"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
 	"github.com/crossplane/crossplane-runtime/pkg/errors"
 	"github.com/crossplane/crossplane-runtime/pkg/event"
 	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/crossplane-runtime/pkg/meta"
 	"github.com/crossplane/crossplane-runtime/pkg/resource"
 
 	v1 "github.com/crossplane/crossplane/apis/pkg/v1"
+	"github.com/crossplane/crossplane/apis/pkg/v1alpha1"
 	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
 	"github.com/crossplane/crossplane/internal/dag"
+	"github.com/crossplane/crossplane/internal/feature"
 	"github.com/crossplane/crossplane/internal/xpkg"
 )
 
@@ -46,26 +55,82 @@ const (
 	reconcileTimeout = 1 * time.Minute
 
 	shortWait = 30 * time.Second
+	longWait  = 5 * time.Minute
 
-	packageTagFmt = "%s:%s"
+	// defaultEventWindow is the period within which identical events
+	// recorded on the Lock are suppressed.
+	defaultEventWindow = 10 * time.Minute
 )
 
 const (
 	finalizer = "lock.pkg.crossplane.io"
 
-	errGetLock              = "cannot get package lock"
-	errAddFinalizer         = "cannot add lock finalizer"
-	errRemoveFinalizer      = "cannot remove lock finalizer"
-	errBuildDAG             = "cannot build DAG"
-	errSortDAG              = "cannot sort DAG"
-	errMissingDependencyFmt = "missing package (%s) is not a dependency"
-	errInvalidConstraint    = "version constraint on dependency is invalid"
-	errInvalidDependency    = "dependency package is not valid"
-	errFetchTags            = "cannot fetch dependency package tags"
-	errNoValidVersion       = "cannot find a valid version for package constraints"
-	errNoValidVersionFmt    = "dependency (%s) does not have version in constraints (%s)"
-	errInvalidPackageType   = "cannot create invalid package dependency type"
-	errCreateDependency     = "cannot create dependency package"
+	errGetLock            = "cannot get package lock"
+	errAddFinalizer       = "cannot add lock finalizer"
+	errRemoveFinalizer    = "cannot remove lock finalizer"
+	errResolve            = "cannot resolve dependencies"
+	errSortDAG            = "cannot sort DAG"
+	errInstallDependency  = "cannot install dependency package"
+	errFetchTags          = "cannot fetch dependency package tags"
+	errCreateDependency   = "cannot create dependency package"
+	errInterrupted        = "stopped creating dependency packages"
+	errUpdateStatus       = "cannot update lock status"
+	errDependencyFmt      = "%s: dependency %s with constraints %q"
+	errUnsupportedTypeFmt = "cannot install a dependency of unsupported package type %q"
+
+	msgMissingDependenciesFmt = "waiting for %d missing dependencies to be installed"
+	msgSkipInstallationFmt    = "automatic dependency installation is disabled; %d missing dependencies must be installed manually"
+	msgLockDeleted            = "Lock was deleted while it was being reconciled"
+)
+
+// Event reasons.
+const (
+	reasonInvalidConstraint   event.Reason = "InvalidDependencyConstraint"
+	reasonInvalidDependency   event.Reason = "InvalidDependency"
+	reasonUnsupportedType     event.Reason = "UnsupportedDependencyType"
+	reasonEmptyConstraint     event.Reason = "EmptyDependencyConstraint"
+	reasonNoValidVersion      event.Reason = "NoValidDependencyVersion"
+	reasonFetchTags           event.Reason = "FetchDependencyTags"
+	reasonFetchDigest         event.Reason = "FetchDependencyDigest"
+	reasonDigestMismatch      event.Reason = "DependencyDigestMismatch"
+	reasonCreateDependency    event.Reason = "CreateDependency"
+	reasonCollectGarbage      event.Reason = "CollectDependencyGarbage"
+	reasonVersionConflict     event.Reason = "DependencyVersionConflict"
+	reasonTypeConflict        event.Reason = "DependencyTypeConflict"
+	reasonUpgradeDependency   event.Reason = "UpgradeDependency"
+	reasonInstallDependency   event.Reason = "InstallDependency"
+	reasonSkipInstallation    event.Reason = "SkipDependencyInstallation"
+	reasonDependencyCycle     event.Reason = "DependencyCycle"
+	reasonAutoInstallLimit    event.Reason = "AutoInstallLimitReached"
+	reasonDuplicatePackages   event.Reason = "DuplicatePackages"
+	reasonRepairLock          event.Reason = "RepairLock"
+	reasonAwaitingApproval    event.Reason = "AwaitingDependencyApproval"
+	reasonStalePackages       event.Reason = "StaleLockPackages"
+	reasonPullSecret          event.Reason = "DefaultPullSecret"
+	reasonCompactLock         event.Reason = "CompactLock"
+	reasonAPIGroupConflict    event.Reason = "DependencyAPIGroupConflict"
+	reasonCheckAPIGroups      event.Reason = "CheckDependencyAPIGroups"
+	reasonPackageAlias        event.Reason = "DependencyPackageAlias"
+	reasonIncompatible        event.Reason = "IncompatibleDependency"
+	reasonCheckCompatible     event.Reason = "CheckDependencyCompatibility"
+	reasonUnverifiedDigest    event.Reason = "UnverifiedDependencyDigest"
+	reasonRefuseDowngrade     event.Reason = "DependencyDowngradeRefused"
+	reasonRuntimeConfig       event.Reason = "DefaultRuntimeConfig"
+	reasonRegistryAuth        event.Reason = "DependencyRegistryUnauthorized"
+	reasonRateLimited         event.Reason = "DependencyRegistryRateLimited"
+	reasonTrackDependency     event.Reason = "TrackDependencyTag"
+	reasonLockTooLarge        event.Reason = "LockStatusTooLarge"
+	reasonOmittedStatus       event.Reason = "OmittedDependencyResolution"
+	reasonDenylist            event.Reason = "VersionDenylist"
+	reasonVersionGroup        event.Reason = "VersionGroupConflict"
+	reasonBlockedByPolicy     event.Reason = "DependencyBlockedByPolicy"
+	reasonInstalled           event.Reason = "DependenciesInstalled"
+	reasonRollbackDependency  event.Reason = "RollbackDependency"
+	reasonAwaitRegistration   event.Reason = "AwaitingDependencyRegistration"
+	reasonOverridden          event.Reason = "DependencyVersionOverridden"
+	reasonUnreportedViolation event.Reason = "UnreportedDependencyViolation"
+	reasonOptionalDependency  event.Reason = "OptionalDependencyViolated"
+	reasonRefreshDependency   event.Reason = "RefreshDependency"
 )
 
 // ReconcilerOption is used to configure the Reconciler.
@@ -85,6 +150,15 @@ func WithRecorder(er event.Recorder) ReconcilerOption {
 	}
 }
 
+// WithEventWindow specifies the period within which the Reconciler suppresses
+// events identical to one it already recorded for the same object. A zero
+// window disables suppression.
+func WithEventWindow(d time.Duration) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.eventWindow = d
+	}
+}
+
 // WithFinalizer specifies how the Reconciler should finalize package revisions.
 func WithFinalizer(f resource.Finalizer) ReconcilerOption {
 	return func(r *Reconciler) {
@@ -108,16 +182,66 @@ func WithFetcher(f xpkg.Fetcher) ReconcilerOption {
 
 // Reconciler reconciles packages.
 type Reconciler struct {
-	client  client.Client
-	log     logging.Logger
-	record  event.Recorder
-	lock    resource.Finalizer
-	newDag  dag.NewDAGFn
-	fetcher xpkg.Fetcher
+	client           client.Client
+	reader           client.Reader
+	log              logging.Logger
+	record           event.Recorder
+	eventWindow      time.Duration
+	status           statusWriter
+	lock             resource.Finalizer
+	newDag           dag.NewDAGFn
+	fetcher          xpkg.Fetcher
+	fopts            []xpkg.FetcherOption
+	namespace        string
+	secrets          []string
+	gc               DependencyGCPolicy
+	audit            AuditSink
+	orphanGrace      time.Duration
+	requeue          RequeueStrategy
+	tags             *tagCache
+	metrics          *Metrics
+	upgrade          bool
+	downgrades       bool
+	rollbackWindow   time.Duration
+	runtimeConfig    string
+	rewrites         []RegistryRewrite
+	registry         string
+	selection        xpkg.VersionSelection
+	selector         *xpkg.VersionSelector
+	filter           *xpkg.TagFilter
+	order            xpkg.TagOrder
+	fetches          int
+	maxAutoInstall   int
+	gateHealth       bool
+	approval         bool
+	source           xpkg.VersionSource
+	fallback         bool
+	digests          bool
+	repair           bool
+	verifyLock       bool
+	retryUnresolved  time.Duration
+	compact          bool
+	aliases          string
+	conflicts        bool
+	crossplane       string
+	strictDigests    bool
+	timeout          time.Duration
+	breakerThreshold int
+	breakerCooldown  time.Duration
+	statusBudget     int
+	denylist         xpkg.Denylist
+	versionGroups    map[string]string
+	sources          xpkg.SourcePolicy
+	dryRun           bool
+	dependents       bool
+	tracer           trace.Tracer
+	resolver         *xpkg.Resolver
+	now              func() time.Time
 }
 
-// Setup adds a controller that reconciles the Lock.
-func Setup(mgr ctrl.Manager, l logging.Logger, namespace string) error {
+// Setup adds a controller that reconciles the Lock. The supplied options are
+// applied after the defaults, and may override them.
+func Setup(mgr ctrl.Manager, l logging.Logger, f *feature.Flags, namespace string, o ...ReconcilerOption) error {
 	name := "packages/" + strings.ToLower(v1beta1.LockGroupKind)
 
 	clientset, err := kubernetes.NewForConfig(mgr.GetConfig())
@@ -125,40 +249,150 @@ func Setup(mgr ctrl.Manager, l logging.Logger, namespace string) error {
 		return errors.Wrap(err, "failed to initialize clientset")
 	}
 
-	r := NewReconciler(mgr,
+	m := NewMetrics()
+	if err := metrics.Registry.Register(m); err != nil {
+		return errors.Wrap(err, "failed to register metrics")
+	}
+
+	opts := []ReconcilerOption{
 		WithLogger(l.WithValues("controller", name)),
 		WithRecorder(event.NewAPIRecorder(mgr.GetEventRecorderFor(name))),
+		WithAPIReader(mgr.GetAPIReader()),
 		WithFetcher(xpkg.NewK8sFetcher(clientset, namespace)),
-	)
+		WithFetcherOptions(xpkg.WithUserAgent(userAgent()), xpkg.WithFetcherLogger(l.WithValues("controller", name))),
+		WithNamespace(namespace),
+		WithDependencyOwnership(DependencyGCOrphan),
+		WithMetrics(m),
+		WithUpgradeDependencies(),
+		WithRequeueStrategy(NewBackoffRequeueStrategy(shortWait, longWait, 0)),
+		WithRegistryCircuitBreaker(defaultBreakerThreshold, defaultBreakerCooldown),
+	}
+	if f.Enabled(feature.FlagEnableAlphaDependencyHealthGating) {
+		opts = append(opts, WithHealthGating())
+	}
+	if f.Enabled(feature.FlagEnableAlphaLockRepair) {
+		opts = append(opts, WithLockRepair())
+	}
+	if f.Enabled(feature.FlagEnableAlphaDigestPinning) {
+		opts = append(opts, WithDigestPinning())
+	}
+	if f.Enabled(feature.FlagEnableAlphaDependencyApproval) {
+		opts = append(opts, WithManualApproval())
+	}
+	if f.Enabled(feature.FlagEnableAlphaLockVerification) {
+		opts = append(opts, WithLockVerification())
+	}
+	if f.Enabled(feature.FlagEnableAlphaLockCompaction) {
+		opts = append(opts, WithLockCompaction())
+	}
+	if f.Enabled(feature.FlagEnableAlphaDependencyConflictDetection) {
+		opts = append(opts, WithConflictDetection())
+	}
+	r := NewReconciler(mgr, append(opts, o...)...)
 
 	return ctrl.NewControllerManagedBy(mgr).
 		Named(name).
 		For(&v1beta1.Lock{}).
-		Owns(&v1.ConfigurationRevision{}).
-		Owns(&v1.ProviderRevision{}).
+		Watches(&source.Kind{Type: &v1.ConfigurationRevision{}}, handler.EnqueueRequestsFromMapFunc(revisionLockRequest(mgr.GetClient()))).
+		Watches(&source.Kind{Type: &v1.ProviderRevision{}}, handler.EnqueueRequestsFromMapFunc(revisionLockRequest(mgr.GetClient()))).
+		Watches(&source.Kind{Type: &v1alpha1.FunctionRevision{}}, handler.EnqueueRequestsFromMapFunc(revisionLockRequest(mgr.GetClient()))).
+		Watches(&source.Kind{Type: &v1.Configuration{}}, handler.EnqueueRequestsFromMapFunc(lockRequest), builder.WithPredicates(packageChanged())).
+		Watches(&source.Kind{Type: &v1.Provider{}}, handler.EnqueueRequestsFromMapFunc(lockRequest), builder.WithPredicates(packageChanged())).
+		Watches(&source.Kind{Type: &v1alpha1.Function{}}, handler.EnqueueRequestsFromMapFunc(lockRequest), builder.WithPredicates(packageChanged())).
 		Complete(r)
 }
 
 // NewReconciler creates a new package revision reconciler.
 func NewReconciler(mgr manager.Manager, opts ...ReconcilerOption) *Reconciler {
 	r := &Reconciler{
-		client:  mgr.GetClient(),
-		lock:    resource.NewAPIFinalizer(mgr.GetClient(), finalizer),
-		log:     logging.NewNopLogger(),
-		record:  event.NewNopRecorder(),
-		newDag:  dag.NewMapDag,
-		fetcher: xpkg.NewNopFetcher(),
+		client:    mgr.GetClient(),
+		reader:    mgr.GetClient(),
+		lock:      resource.NewAPIFinalizer(mgr.GetClient(), finalizer),
+		log:       logging.NewNopLogger(),
+		record:    event.NewNopRecorder(),
+		newDag:    dag.NewMapDag,
+		fetcher:   xpkg.NewNopFetcher(),
+		requeue:   FixedRequeueStrategy{Error: shortWait, Cycle: longWait, Throttled: longWait},
+		tags:      newTagCache(defaultTagCacheTTL),
+		metrics:   NewMetrics(),
+		selection: xpkg.VersionSelectionHighest,
+		selector:  xpkg.NewVersionSelector(),
+		filter:    xpkg.NewTagFilter(),
+		fetches:   defaultMaxConcurrentFetches,
+		timeout:   defaultFetchTimeout,
+		now:       time.Now,
+
+		retryUnresolved: defaultUnresolvedRetryInterval,
+		orphanGrace:     DefaultOrphanGracePeriod,
+		eventWindow:     defaultEventWindow,
+		statusBudget:    defaultStatusBudget,
+		tracer:          trace.NewNoopTracerProvider().Tracer(tracerName),
 	}
 
 	for _, f := range opts {
 		f(r)
 	}
 
+	// A dry run plans as usual, but never writes a package.
+	if r.dryRun {
+		r.client = dryRunClient{Client: r.client}
+		r.record = dryRunRecorder{wrapped: r.record}
+	}
+	r.record = newDedupingRecorder(r.record, r.eventWindow)
+	r.status = statusWriter{client: r.client}
+	if k, ok := r.fetcher.(*xpkg.K8sFetcher); ok {
+		k = k.With(r.fopts...)
+		r.log.Debug("Fetching dependency tags", k.TransportSettings()...)
+		r.fetcher = k
+	}
+	if r.breakerThreshold > 0 {
+		// Cached tags are served even while their registry is unavailable.
+		r.fetcher = xpkg.NewCircuitBreakingFetcher(r.fetcher, r.breakerThreshold, r.breakerCooldown)
+	}
+	r.fetcher = &cachingFetcher{Fetcher: r.fetcher, log: r.log, cache: r.tags, metrics: r.metrics}
+	ropts := []xpkg.ResolverOption{
+		xpkg.WithLogger(r.log),
+		xpkg.WithNewDAGFn(r.newDag),
+		xpkg.WithVersionSelection(r.selection),
+		xpkg.WithVersionSelector(r.selector),
+		xpkg.WithTagFilter(r.filter),
+		xpkg.WithTagOrder(r.order),
+		xpkg.WithDefaultRegistry(r.registry),
+		xpkg.WithMaxConcurrentFetches(r.fetches),
+		xpkg.WithFetchTimeout(r.timeout),
+		xpkg.WithTracer(r.tracer),
+		xpkg.WithVersionGroups(r.versionGroups),
+		xpkg.WithSourcePolicy(r.sources),
+	}
+	if r.source != nil {
+		ropts = append(ropts, xpkg.WithVersionSource(r.source))
+	}
+	if r.fallback {
+		ropts = append(ropts, xpkg.WithTagFallback())
+	}
+	if r.digests {
+		ropts = append(ropts, xpkg.WithDigestPinning())
+	}
+	r.resolver = xpkg.NewResolver(ropts...)
+
 	return r
 }
 
 // Reconcile package revision.
-func (r *Reconciler) Reconcile(ctx context.Context, req reconcile.Request) (reconcile.Result, error) { // nolint:gocyclo
+func (r *Reconciler) Reconcile(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+	ctx, span := r.tracer.Start(ctx, SpanReconcile, trace.WithAttributes(AttributeLock.String(req.Name)))
+	defer span.End()
+
+	result, err := r.reconcile(ctx, req)
+	xpkg.RecordError(span, err)
+	if throttled(err) {
+		r.log.Debug(msgThrottled, "request", req, "error", err)
+		return reconcile.Result{RequeueAfter: r.requeue.RequeueAfter(RequeueThrottled)}, nil
+	}
+	return result, err
+}
+
+func (r *Reconciler) reconcile(ctx context.Context, req reconcile.Request) (reconcile.Result, error) { // nolint:gocyclo
 	log := r.log.WithValues("request", req)
 	log.Debug("Reconciling")
 
@@ -172,22 +406,41 @@ func (r *Reconciler) Reconcile(ctx context.Context, req reconcile.Request) (reco
 		log.Debug(errGetLock, "error", err)
 		return reconcile.Result{}, errors.Wrap(resource.IgnoreNotFound(err), errGetLock)
 	}
+	observed := lock.Status.DeepCopy()
 
 	// If no packages exist in Lock then we remove finalizer and wait until a
 	// package is added to reconcile again. This allows for cleanup of the Lock
 	// when uninstalling Crossplane after all packages have already been
-	// uninstalled.
-	if len(lock.Packages) == 0 {
+	// uninstalled. A Lock that is being deleted, e.g. because Crossplane is
+	// being uninstalled, must not wait for its packages to be uninstalled
+	// first, and must never have packages installed on its behalf.
+	if len(lock.Packages) == 0 || meta.WasDeleted(lock) {
+		r.metrics.missing.WithLabelValues(lock.GetName()).Set(0)
+		if !meta.FinalizerExists(lock, finalizer) {
+			return reconcile.Result{}, nil
+		}
 		if err := r.lock.RemoveFinalizer(ctx, lock); err != nil {
 			log.Debug(errRemoveFinalizer, "error", err)
-			return reconcile.Result{RequeueAfter: shortWait}, nil
+			return r.finalizerError(err), nil
 		}
 		return reconcile.Result{}, nil
 	}
 
-	if err := r.lock.AddFinalizer(ctx, lock); err != nil {
-		log.Debug(errAddFinalizer, "error", err)
-		return reconcile.Result{RequeueAfter: shortWait}, nil
+	// A paused Lock isn't resolved, and isn't written except to report that
+	// it is paused. We do this after the Lock's finalizer is removed above,
+	// so that pausing never blocks an uninstall.
+	if isPaused(lock) {
+		return r.pause(ctx, log, lock, observed)
+	}
+	r.resumed(lock, observed)
+
+	// We only write the Lock if its finalizer is missing, to avoid needless
+	// writes that may conflict with other controllers.
+	if !meta.FinalizerExists(lock, finalizer) {
+		if err := r.lock.AddFinalizer(ctx, lock); err != nil {
+			log.Debug(errAddFinalizer, "error", err)
+			return r.finalizerError(err), nil
+		}
 	}
 
 	log = log.WithValues(
@@ -196,101 +449,776 @@ func (r *Reconciler) Reconcile(ctx context.Context, req reconcile.Request) (reco
 		"name", lock.GetName(),
 	)
 
-	dag := r.newDag()
-	implied, err := dag.Init(v1beta1.ToNodes(lock.Packages...))
+	// A botched upgrade may leave more than one package from the same
+	// repository in the Lock, which we can't build a dependency graph from.
+	// We do this before the Lock is normalized, so that we never persist
+	// normalized sources if we repair it.
+	dups, err := r.duplicates(ctx, lock)
+	if err != nil {
+		log.Debug(errGetDuplicateRevision, "error", err)
+		r.record.Event(lock, event.Warning(reasonDuplicatePackages, err))
+		return reconcile.Result{RequeueAfter: r.requeue.RequeueAfter(RequeueRepairError)}, nil
+	}
+	if len(dups) > 0 {
+		msg := fmt.Sprintf(msgDuplicatesFmt, describeDuplicates(lock, dups))
+		if !r.repair || !repairable(dups) {
+			// Only a user can tell which of the duplicates is stale.
+			log.Debug(msg)
+			r.record.Event(lock, event.Warning(reasonDuplicatePackages, errors.New(msg)))
+			lock.SetConditions(v1beta1.DuplicatePackages().WithMessage(msg))
+			unsatisfied(lock)
+
+			// We didn't attempt to resolve any dependencies.
+			lock.Status.DependencyResolution, lock.Status.OmittedDependencyResolutions = nil, 0
+			lock.Status.Dependents = nil
+			return reconcile.Result{RequeueAfter: r.requeue.RequeueAfter(RequeueDuplicates)}, errors.Wrap(r.status.Write(ctx, lock, observed), errUpdateStatus)
+		}
+		removed, err := r.repairLock(ctx, lock, dups)
+		if kerrors.IsNotFound(err) {
+			log.Debug(msgLockDeleted, "error", err)
+			return reconcile.Result{}, nil
+		}
+		if err != nil {
+			log.Debug(errRepairLock, "error", err)
+			r.record.Event(lock, event.Warning(reasonRepairLock, err))
+			return reconcile.Result{RequeueAfter: r.requeue.RequeueAfter(RequeueRepairError)}, nil
+		}
+		log.Debug("Removed stale duplicate packages from Lock", "removed", removed)
+		r.record.Event(lock, event.Normal(reasonRepairLock, fmt.Sprintf(msgRepairedFmt, removed)))
+	}
+
+	// A dependency may be satisfied by a package from another repository,
+	// e.g. a fork of the package it declares.
+	aliases, err := r.packageAliases(ctx)
 	if err != nil {
-		return reconcile.Result{}, errors.Wrap(err, errBuildDAG)
+		log.Debug(errGetAliases, "error", err)
+		r.record.Event(lock, event.Warning(reasonPackageAlias, err))
+		return reconcile.Result{RequeueAfter: r.requeue.RequeueAfter(RequeueAliasError)}, nil
+	}
+
+	// Packages installed as dependencies linger in the Lock after the
+	// packages that depended on them are uninstalled. We remove them before
+	// we verify the Lock, which never updates it.
+	if r.compact {
+		n, removed, err := r.compactLock(ctx, log, lock, aliases)
+		if kerrors.IsNotFound(err) {
+			log.Debug(msgLockDeleted, "error", err)
+			return reconcile.Result{}, nil
+		}
+		if err != nil {
+			log.Debug(errCompactLock, "error", err)
+			r.record.Event(lock, event.Warning(reasonCompactLock, err))
+			return reconcile.Result{RequeueAfter: r.requeue.RequeueAfter(RequeueCompactError)}, nil
+		}
+		if n > 0 {
+			msg := fmt.Sprintf(msgCompactedFmt, n, removed)
+			log.Debug(msg)
+			r.record.Event(lock, event.Normal(reasonCompactLock, msg))
+		}
+	}
+
+	// A package that is still declared as a dependency must never be pruned,
+	// so we keep a copy of the Lock before we remove stale packages and
+	// invalid dependencies from it below.
+	declared := lock.DeepCopy()
+
+	// Packages may linger in the Lock after their revisions are deleted. We
+	// resolve dependencies as if they weren't there, so that the packages
+	// that depend on them are missing a dependency.
+	var stale []xpv1.Condition
+	if r.verifyLock {
+		var pkgs []v1beta1.LockPackage
+		lock.Packages, pkgs, err = r.backed(ctx, r.reader, lock.Packages)
+		if err != nil {
+			log.Debug(errListRevisions, "error", err)
+			r.record.Event(lock, event.Warning(reasonStalePackages, err))
+			return reconcile.Result{RequeueAfter: r.requeue.RequeueAfter(RequeueVerifyError)}, nil
+		}
+		if len(pkgs) > 0 {
+			msg := fmt.Sprintf(msgStalePackagesFmt, describeStale(pkgs))
+			log.Debug(msg)
+			r.record.Event(lock, event.Warning(reasonStalePackages, errors.New(msg)))
+			stale = append(stale, v1beta1.StalePackages().WithMessage(msg))
+		}
+	}
+
+	// Dependencies are declared by package authors, so we validate them
+	// before we parse, log, or record them.
+	invalid, rejected := r.rejectInvalid(log, lock)
+
+	r.normalize(log, lock)
+	for _, msg := range alias(log, lock, aliases) {
+		r.record.Event(lock, event.Normal(reasonPackageAlias, msg))
 	}
+	r.warnEmptyConstraints(lock)
+
+	// The Lock's spec may override how we resolve its dependencies.
+	pol := r.policy(lock)
+	pol.log(log)
+
+	// We only need to select versions for dependencies we will install.
+	secrets := r.pullSecrets(ctx, log, lock)
+	f := withSecrets(r.fetcher, secrets)
+	if !pol.autoInstall {
+		f = nil
+	}
+
+	// Packages are only reported as dependents once their dependencies
+	// were resolved.
+	lock.Status.Dependents = nil
+
+	start := r.now()
+	plan, found, err := r.resolver.WithSelection(pol.selection).WithDenylist(r.denylistFor(log, lock)).WithOverrides(xpkg.NewOverrides(lock.Spec.Overrides)).Resolve(ctx, lock.Packages, f)
+	conflicts, unverified := r.unverified(found)
+	conflicts, overrides := overridden(lock, conflicts)
+
+	// We summarize what we decided once we're done, however we return.
+	res := newResolutionResult(lock, plan, conflicts, start, r.now().Sub(start))
+	defer res.log(log, lock)
 
 	// Make sure we don't have any cyclical imports. If we do, refuse to install
 	// additional packages.
-	_, err = dag.Sort()
+	var cycle *dag.CyclicError
+	if errors.As(err, &cycle) {
+		// Only a user can break the cycle, so there's no point retrying
+		// quickly.
+		log.Debug(errResolve, "error", err)
+		r.record.Event(lock, event.Warning(reasonDependencyCycle, cycle))
+		lock.SetConditions(v1beta1.DependencyCycle().WithMessage(cycle.Path()))
+		return reconcile.Result{RequeueAfter: r.requeue.RequeueAfter(RequeueCycle)}, errors.Wrap(r.updateStatus(ctx, lock, observed, res), errUpdateStatus)
+	}
+	var serr *xpkg.SortError
+	if errors.As(err, &serr) {
+		lock.SetConditions(v1beta1.DependencyCycle().WithMessage(errors.Unwrap(serr).Error()))
+		// The cycle is more important to surface than any error updating
+		// status, so we ignore the latter.
+		_ = r.updateStatus(ctx, lock, observed, res)
+		return reconcile.Result{}, errors.Wrap(errors.Unwrap(serr), errSortDAG)
+	}
 	if err != nil {
-		return reconcile.Result{}, errors.Wrap(err, errSortDAG)
+		return reconcile.Result{}, err
 	}
 
-	if len(implied) == 0 {
-		return reconcile.Result{}, nil
+	// Every conflicting edge of the dependency graph should be accounted for
+	// by a conflict of the package it leads to.
+	r.checkEdges(log, lock, found, res)
+
+	// Optional dependencies are never installed, but are reported if they're
+	// missing or don't satisfy the packages that declared them.
+	res.optional = xpkg.OptionalDependencies(lock.Packages)
+
+	// Package revisions may wait for their dependencies to be resolved.
+	if r.dependents {
+		lock.Status.Dependents = resolvedDependents(lock, plan, conflicts, rejected)
 	}
 
-	// If we are missing a node, we want to create it. The resolver never
-	// modifies the Lock. We only create the first implied node as we will be
-	// requeued when it adds itself to the Lock, at which point we will check
-	// for missing nodes again.
-	dep, ok := implied[0].(*v1beta1.Dependency)
-	if !ok {
-		log.Debug(errInvalidDependency, "error", errors.Errorf(errMissingDependencyFmt, dep.Identifier()))
-		return reconcile.Result{}, nil
+	// We requeue for the union of the reasons encountered in this pass.
+	requeue := map[RequeueReason]bool{}
+	var prune time.Duration
+	if r.gc != "" {
+		if prune, err = r.collect(ctx, r.resolvable(declared, aliases)); err != nil {
+			log.Debug(errCollectGarbage, "error", err)
+			r.record.Event(lock, event.Warning(reasonCollectGarbage, errors.Wrap(err, errCollectGarbage)))
+			if rq := retry(RequeueCollectError, err); rq != "" {
+				requeue[rq] = true
+			}
+		}
+	}
+
+	// The Lock's recheck policy determines when we consult the registry
+	// about dependencies that are already installed.
+	checks := newCheckLog(lock, pol, r.now())
+
+	// An upgrade we performed may break the packages that depend on the
+	// upgraded package. We roll it back if so, before we consider
+	// upgrading anything else.
+	if pol.upgrade && r.rollbackWindow > 0 {
+		rq, err := r.rollback(ctx, log, lock)
+		if err != nil {
+			log.Debug(errRollbackDependency, "error", err)
+			r.record.Event(lock, event.Warning(reasonRollbackDependency, err))
+		}
+		if rq != "" {
+			requeue[rq] = true
+		}
+	}
+
+	// Packages that are already in the Lock may not satisfy the constraints
+	// of packages that were installed after them. We surface the conflict,
+	// and upgrade the package if it was auto-installed and we're allowed to.
+	// The conflict persists until the upgraded package updates the Lock.
+	failed := append(stale, invalid...)
+	for _, c := range conflicts {
+		// Only a user can resolve a type conflict, e.g. by uninstalling
+		// the package that is the wrong type.
+		if c.Reason == xpkg.ConflictType {
+			log.Debug("Dependency type conflict", "error", c)
+			r.record.Event(lock, event.Warning(reasonTypeConflict, c))
+			failed = append(failed, v1beta1.TypeConflict().WithMessage(c.Error()))
+			continue
+		}
+		// Upgrading can't make a digest verifiable, so only a user can
+		// resolve an unverifiable version in strict mode.
+		if c.Reason == xpkg.ConflictUnverifiable {
+			log.Debug("Dependency version cannot be verified", "error", c)
+			r.record.Event(lock, event.Warning(reasonUnverifiedDigest, c))
+			failed = append(failed, v1beta1.VersionConflict().WithMessage(c.Error()))
+			continue
+		}
+		log.Debug("Dependency version conflict", "error", c)
+		r.record.Event(lock, event.Warning(reasonVersionConflict, c))
+		if !pol.upgrade || !checks.due(c.Package, false) {
+			failed = append(failed, v1beta1.VersionConflict().WithMessage(c.Error()))
+			continue
+		}
+		rq, err := r.upgradeDependency(ctx, log, lock, c, secrets)
+		if rq != RequeueFetchError {
+			checks.record(c.Package)
+		}
+		// Only a user can decide whether it's safe to downgrade a package,
+		// e.g. by uninstalling the package that requires the older version.
+		var derr *downgradeError
+		if errors.As(err, &derr) {
+			log.Debug("Refusing to downgrade dependency", "error", err)
+			r.record.Event(lock, event.Warning(reasonRefuseDowngrade, err))
+			failed = append(failed, v1beta1.DowngradeRefused().WithMessage(err.Error()))
+			continue
+		}
+		// Only an operator can allow a package to be installed from a
+		// repository the package source policy blocks.
+		if blockedByPolicy(err) {
+			log.Debug("Refusing to upgrade dependency", "error", err)
+			r.record.Event(lock, event.Warning(reasonBlockedByPolicy, err))
+			failed = append(failed, v1beta1.BlockedByPolicy().WithMessage(err.Error()))
+			continue
+		}
+		failed = append(failed, v1beta1.VersionConflict().WithMessage(c.Error()))
+		if err != nil {
+			err = errors.Wrapf(err, errUpgradeDependencyFmt, c.Package)
+			log.Debug(errUpgradeDependency, "error", err)
+			r.record.Event(lock, event.Warning(reasonUpgradeDependency, err))
+		}
+		if rq != "" {
+			requeue[rq] = true
+		}
+	}
+
+	// Packages installed to satisfy dependencies that track a tag move with
+	// the tag, if upgrades are allowed.
+	if pol.upgrade {
+		for _, t := range tracked(lock) {
+			if !checks.due(t.pkg.Source, false) {
+				continue
+			}
+			rq, err := r.retrack(ctx, lock, t, secrets)
+			if rq != RequeueFetchError {
+				checks.record(t.pkg.Source)
+			}
+			if err != nil {
+				log.Debug(errTrackDependency, "error", err)
+				r.record.Event(lock, event.Warning(reasonTrackDependency, err))
+			}
+			if rq != "" {
+				requeue[rq] = true
+			}
+		}
+	}
+
+	// Packages that satisfy the packages that depend on them are only
+	// refreshed to a newer version if the Lock's recheck policy says so.
+	if pol.upgrade {
+		for _, ip := range settled(lock, conflicts) {
+			if !checks.due(ip.Source, true) {
+				continue
+			}
+			rq, err := r.refreshDependency(ctx, log, lock, ip, secrets)
+			if rq != RequeueFetchError {
+				checks.record(ip.Source)
+			}
+			if err != nil {
+				log.Debug(errRefreshDependency, "error", err)
+				r.record.Event(lock, event.Warning(reasonRefreshDependency, err))
+			}
+			if rq != "" {
+				requeue[rq] = true
+			}
+		}
+	}
+	lock.Status.Checked = checks.status()
+	recheck := checks.wait(lock.Status.Checked)
+
+	for _, c := range unverified {
+		log.Debug("Dependency version cannot be verified", "error", c)
+		r.record.Event(lock, event.Warning(reasonUnverifiedDigest, c))
+	}
+	for _, c := range overrides {
+		log.Debug("Dependency version is overridden", "error", c)
+		r.record.Event(lock, event.Warning(reasonOverridden, c))
+	}
+
+	lock.Status.MissingDependencies = int64(len(plan))
+	lock.Status.Blocked = nil
+	lock.Status.PendingApproval = nil
+	lock.Status.ResolvingLayer = 0
+	lock.Status.DependencyLayers = 0
+	r.metrics.missing.WithLabelValues(lock.GetName()).Set(float64(lock.Status.MissingDependencies))
+	if len(plan) == 0 {
+		cond := v1beta1.DependenciesResolved()
+		if len(unverified) > 0 {
+			cond = v1beta1.DependenciesUnverified().WithMessage(unverifiedMessage(unverified))
+		}
+		if len(overrides) > 0 {
+			cond = v1beta1.DependenciesOverridden().WithMessage(overriddenMessage(append(overrides, unverified...)))
+		}
+		if len(failed) > 0 {
+			cond = joined(failed)
+		}
+		lock.SetConditions(cond)
+
+		// We'll be queued when the revisions of any packages that aren't
+		// yet installed are created.
+		msg, err := r.completeResolution(ctx, lock)
+		if err != nil {
+			log.Debug(errListRevisions, "error", err)
+			requeue[RequeueVerifyError] = true
+		}
+		if err := r.updateStatus(ctx, lock, observed, res); err != nil {
+			return r.result(requeue), errors.Wrap(err, errUpdateStatus)
+		}
+		// We only announce that resolution is complete once we've recorded
+		// it, so that we announce it exactly once.
+		if msg != "" {
+			log.Debug(msg)
+			r.record.Event(lock, event.Normal(reasonInstalled, msg))
+		}
+		// Installed dependencies are rechecked periodically if the Lock's
+		// recheck policy says so.
+		result := r.result(requeue)
+		if recheck > 0 && (result.RequeueAfter == 0 || recheck < result.RequeueAfter) {
+			result.RequeueAfter = recheck
+		}
+		return result, nil
 	}
-	c, err := semver.NewConstraint(dep.Constraints)
+	r.startResolution(lock)
+
+	// Missing dependencies must be installed manually if automatic
+	// installation is disabled. We'll be queued when they add themselves to
+	// the Lock.
+	if !pol.autoInstall {
+		msg := fmt.Sprintf(msgSkipInstallationFmt, lock.Status.MissingDependencies)
+		log.Debug(msg)
+		r.record.Event(lock, event.Normal(reasonSkipInstallation, msg))
+		res.decideAll(outcomeSkipped)
+		cond := v1beta1.MissingDependency().WithMessage(msg)
+		if len(failed) > 0 {
+			cond = joined(append(failed, cond))
+		}
+		lock.SetConditions(cond)
+		return r.result(requeue), errors.Wrap(r.updateStatus(ctx, lock, observed, res), errUpdateStatus)
+	}
+
+	// Dependencies of packages that aren't installed yet may be constrained
+	// by those packages, so we install one layer of missing dependencies at
+	// a time. We'll be queued when the packages we install add themselves
+	// to the Lock.
+	plan, deferred := layer(lock, plan)
+	for _, p := range deferred {
+		log.Debug("Deferring missing dependency until shallower layers are installed", "dependency", p.Dependency.Package, "layer", p.Depth, "resolving-layer", lock.Status.ResolvingLayer)
+		res.decide(p.Dependency.Package, outcomeDeferred, "")
+	}
+
+	// Dependencies of packages we installed may have to wait for those
+	// packages to become healthy. We'll be queued when their health changes.
+	if r.gateHealth {
+		plan, lock.Status.Blocked, err = r.gate(ctx, log, lock, plan)
+		if err != nil {
+			log.Debug(errCheckHealth, "error", err)
+			r.record.Event(lock, event.Warning(reasonCreateDependency, err))
+			lock.SetConditions(joined(append(failed, v1beta1.MissingDependency().WithMessage(err.Error()))))
+			requeue[RequeueCreateError] = true
+			return r.result(requeue), errors.Wrap(r.updateStatus(ctx, lock, observed, res), errUpdateStatus)
+		}
+		for _, b := range lock.Status.Blocked {
+			res.decide(b.Package, outcomeBlocked, "")
+		}
+	}
+
+	// Missing dependencies may have to be approved before we install them.
+	// We'll be queued when the Lock's approvals change.
+	if r.approval {
+		plan, lock.Status.PendingApproval = r.approve(log, lock, plan)
+		for _, p := range lock.Status.PendingApproval {
+			res.decide(p.Package, outcomePending, "")
+		}
+		if len(lock.Status.PendingApproval) > 0 {
+			r.record.Event(lock, event.Normal(reasonAwaitingApproval, pendingMessage(lock.Status.PendingApproval)))
+		}
+	}
+
+	// We may only create a limited number of packages.
+	budget, err := r.budget(ctx, lock, pol.maxAutoInstall)
 	if err != nil {
-		log.Debug(errInvalidConstraint, "error", err)
+		log.Debug(errCountAutoInstalled, "error", err)
+		r.record.Event(lock, event.Warning(reasonCreateDependency, err))
+		lock.SetConditions(joined(append(failed, v1beta1.MissingDependency().WithMessage(err.Error()))))
+		requeue[RequeueCreateError] = true
+		return r.result(requeue), errors.Wrap(r.updateStatus(ctx, lock, observed, res), errUpdateStatus)
+	}
+
+	// The Lock may have been deleted or paused since we read it, in which
+	// case we must not install packages on its behalf. We'll be queued to
+	// remove its finalizer if it still exists.
+	deleted, paused := r.interrupted(ctx, lock)
+	if deleted {
+		log.Debug(msgLockDeleted)
 		return reconcile.Result{}, nil
 	}
-	ref, err := name.ParseReference(dep.Package)
+	if paused {
+		return r.pause(ctx, log, lock, observed)
+	}
+
+	// Within a pass we create the packages that others are likely to wait
+	// for first.
+	prioritize(plan)
+	res.ordered(plan)
+
+	// If we are missing nodes, we want to create them. The resolver never
+	// modifies the Lock. Missing nodes in the same layer are independent of
+	// each other, so we attempt to create all of them in a single pass. We
+	// will be requeued when each created package adds itself to the Lock,
+	// at which point we will check for missing nodes again.
+	nconflicts := len(failed)
+	var retryAfter time.Duration
+	for _, p := range plan {
+		// There's no point attempting to create packages once we've been
+		// cancelled. Packages we already created are found by their
+		// repository label next time.
+		if err := ctx.Err(); err != nil {
+			log.Debug(errInterrupted, "error", err)
+			failed = append(failed, v1beta1.MissingDependency().WithMessage(errors.Wrap(err, errInterrupted).Error()))
+			requeue[RequeueCreateError] = true
+			break
+		}
+		ictx, span := r.tracer.Start(ctx, SpanInstallDependency, trace.WithAttributes(
+			xpkg.AttributePackage.String(p.Dependency.Package),
+			xpkg.AttributeVersion.String(p.Version),
+		))
+		c, rq, err := r.install(ictx, log, lock, p, pol.selection, budget, secrets, res)
+		if d := xpkg.RetryAfter(err); d > retryAfter {
+			retryAfter = d
+		}
+		if err != nil {
+			c = c.WithMessage(err.Error())
+			res.fail(p.Dependency.Package, c)
+			failed = append(failed, c)
+		}
+		span.SetAttributes(xpkg.AttributeOutcome.String(res.outcome(p.Dependency.Package)))
+		xpkg.RecordError(span, err)
+		span.End()
+		if rq != "" {
+			requeue[rq] = true
+		}
+
+		// Every request we make while we're throttled would have to
+		// wait past the deadline too.
+		if rq == RequeueThrottled {
+			break
+		}
+	}
+
+	if len(failed) == nconflicts {
+		requeue[RequeueWaiting] = true
+	}
+
+	// The dependencies we created will not be resolved until they add
+	// themselves to the Lock.
+	cond := v1beta1.MissingDependency().WithMessage(layerMessage(lock))
+	if len(lock.Status.Blocked) > 0 {
+		failed = append(failed, v1beta1.DependencyBlocked().WithMessage(blockedMessage(lock.Status.Blocked)))
+	}
+	if len(lock.Status.PendingApproval) > 0 {
+		failed = append(failed, v1beta1.AwaitingApproval().WithMessage(pendingMessage(lock.Status.PendingApproval)))
+	}
+	if len(failed) > 0 {
+		cond = joined(failed)
+	}
+	lock.SetConditions(cond)
+	if r.crossplane != "" || r.conflicts {
+		lock.SetConditions(checked(res))
+	}
+
+	// Dependencies that no version satisfies are retried periodically, so
+	// that we notice when a satisfying version is published.
+	result := r.result(requeue)
+	if d := r.scheduleRetries(lock, res); d > 0 && (result.RequeueAfter == 0 || d < result.RequeueAfter) {
+		result.RequeueAfter = d
+	}
+	if recheck > 0 && (result.RequeueAfter == 0 || recheck < result.RequeueAfter) {
+		result.RequeueAfter = recheck
+	}
+	// Orphaned packages are deleted once their grace period has elapsed.
+	if prune > 0 && (result.RequeueAfter == 0 || prune < result.RequeueAfter) {
+		result.RequeueAfter = prune
+	}
+	// Retrying before a registry that rate limited us asked us to only
+	// prolongs the limit.
+	if result.RequeueAfter > 0 && result.RequeueAfter < retryAfter {
+		result.RequeueAfter = retryAfter
+	}
+	return result, errors.Wrap(r.updateStatus(ctx, lock, observed, res), errUpdateStatus)
+}
+
+// An unsupportedTypeError indicates that a dependency is a type of package
+// the Reconciler cannot install.
+type unsupportedTypeError struct {
+	pkgType v1beta1.PackageType
+}
+
+func (e *unsupportedTypeError) Error() string {
+	return fmt.Sprintf(errUnsupportedTypeFmt, e.pkgType)
+}
+
+// install creates the package planned to satisfy a missing dependency, if the
+// supplied budget allows it, and records the package that satisfies it in the
+// supplied result. The package uses the supplied pull secrets, and records the
+// supplied version selection policy. If the dependency cannot be installed it returns
+// an error, a condition describing why, and the reason to requeue, if
+// installation should be retried.
+func (r *Reconciler) install(ctx context.Context, log logging.Logger, lock *v1beta1.Lock, p xpkg.PlannedInstall, sel xpkg.VersionSelection, b *installBudget, secrets []string, res *resolutionResult) (xpv1.Condition, RequeueReason, error) {
+	dep := p.Dependency
+	if p.Err != nil {
+		return r.unresolvable(ctx, log, lock, p)
+	}
+	if b.exhausted() {
+		return r.limited(log, lock, p, b)
+	}
+
+	failed := func(err error) (xpv1.Condition, RequeueReason, error) {
+		err = errors.Wrapf(err, errDependencyFmt, errCreateDependency, dep.Package, dep.Constraints)
+		if throttled(err) {
+			log.Debug(msgThrottled, "error", err)
+			return v1beta1.MissingDependency(), RequeueThrottled, err
+		}
+		log.Debug(errCreateDependency, "error", err)
+		r.record.Event(lock, event.Warning(reasonCreateDependency, err))
+		r.metrics.failures.WithLabelValues(failureCreateError).Inc()
+		if classify(err) == FailurePermanent {
+			return v1beta1.DependencyRejected(), "", err
+		}
+		return v1beta1.MissingDependency(), RequeueCreateError, err
+	}
+
+	// The resolver only plans types of package we can install, but we don't
+	// rely on that here.
+	pack, ok := newPackage(dep.Type)
+	if !ok {
+		return failed(permanent(&unsupportedTypeError{pkgType: dep.Type}))
+	}
+
+	// NOTE(hasheddan): packages are currently created with default
+	// settings, except for any configured defaults and those they inherit
+	// from the packages that depend on them. This means that a dependency
+	// must be publicly available unless default pull secrets are
+	// configured. Settings can be modified manually after dependency
+	// creation to address this.
+	pack.SetName(p.Name)
+	pack.SetSource(p.Source)
+	addPullSecrets(pack, secrets)
+
+	// A user may have installed the dependency at the same time as the
+	// package that depends on it, in which case it exists but hasn't yet
+	// added itself to the Lock. Creating another package from the same
+	// repository would leave two packages fighting over it.
+	existing, err := r.unregistered(ctx, pack, r.sourceRepository(p.Source))
 	if err != nil {
-		log.Debug(errInvalidDependency, "error", err)
-		return reconcile.Result{}, nil
+		return failed(err)
+	}
+	if existing != nil {
+		return r.awaitRegistration(log, lock, p, existing, res)
 	}
 
-	// NOTE(hasheddan): we will be unable to fetch tags for private
-	// dependencies because we do not attach any secrets. Consider copying
-	// secrets from parent dependencies.
-	tags, err := r.fetcher.Tags(ctx, ref)
+	parents, err := r.parents(ctx, lock, &dep)
 	if err != nil {
-		log.Debug(errFetchTags, "error", err)
-		return reconcile.Result{RequeueAfter: shortWait}, nil
+		return failed(err)
 	}
+	inherit(log.WithValues("dependency", dep.Package), pack, parents)
 
-	vs := []*semver.Version{}
-	for _, r := range tags {
-		v, err := semver.NewVersion(r)
+	if !p.Pinned {
+		meta.AddAnnotations(pack, map[string]string{AnnotationVersionSelection: string(sel)})
+	}
+	meta.AddAnnotations(pack, provenance(lock, dep.Package, p.Version, r.now()))
+	meta.AddLabels(pack, map[string]string{LabelLock: lock.GetName()})
+	if r.gc != "" {
+		own(lock, pack)
+	}
+	if b.max > 0 {
+		// Only labeled packages count toward the limit.
+		meta.AddLabels(pack, map[string]string{LabelAutoInstalled: "true"})
+	}
+	if r.crossplane != "" {
+		if required := r.incompatible(ctx, log, lock, pack, res); required != "" {
+			return r.rejectIncompatible(log, lock, p, required)
+		}
+	}
+	if r.conflicts && dep.Type == v1beta1.ProviderPackageType {
+		conflicts, err := r.groupConflicts(ctx, log, lock, pack, res)
 		if err != nil {
-			// We skip any tags that are not valid semantic versions.
-			continue
+			return failed(err)
 		}
-		vs = append(vs, v)
+		if conflicts != "" {
+			return r.groupConflicted(log, lock, p, conflicts)
+		}
+	}
+	r.addRuntimeConfigRef(ctx, log, lock, pack)
+	if r.dryRun {
+		return r.planned(log, lock, p, pack, b, res)
 	}
+	created, err := r.create(ctx, log, pack)
+	if err != nil {
+		return failed(err)
+	}
+	res.decide(dep.Package, outcomeExists, pack.GetName())
+	if created {
+		b.used++
+		lock.Status.ResolutionInstalled++
+		r.metrics.created.WithLabelValues(string(dep.Type)).Inc()
+		res.decide(dep.Package, outcomeCreated, pack.GetName())
+		r.recordAudit(ctx, lock, AuditRecord{Action: AuditCreated, Source: dep.Package, Package: pack.GetName(), Version: p.Version, Dependents: dependentsOf(p.Requirements)})
 
-	sort.Sort(semver.Collection(vs))
-	var addVer string
-	for _, v := range vs {
-		if c.Check(v) {
-			addVer = v.Original()
-		}
+		// The create response populated the package's UID, so the event
+		// is recorded against the package we just created.
+		r.record.Event(pack, event.Normal(reasonInstallDependency, installedMessage(p)))
+	}
+	if len(p.Violations) > 0 {
+		msg := violationMessage(p)
+		log.Debug(msg)
+		r.record.Event(lock, event.Warning(reasonOverridden, errors.New(msg)))
 	}
 
-	// NOTE(hasheddan): consider creating event on package revision
-	// dictating constraints.
-	if addVer == "" {
-		log.Debug(errNoValidVersion, errors.Errorf(errNoValidVersionFmt, dep.Identifier(), dep.Constraints))
-		return reconcile.Result{}, nil
+	return v1beta1.MissingDependency(), "", nil
+}
+
+// finalizerError returns how to requeue when the Lock's finalizer could not
+// be added or removed. We requeue immediately if our view of the Lock was
+// stale, because a fresh read is all that's needed to retry.
+func (r *Reconciler) finalizerError(err error) reconcile.Result {
+	// There's nothing left to finalize if the Lock was deleted.
+	if kerrors.IsNotFound(err) {
+		return reconcile.Result{}
+	}
+	if kerrors.IsConflict(err) {
+		return reconcile.Result{Requeue: true}
+	}
+	if throttled(err) {
+		return reconcile.Result{RequeueAfter: r.requeue.RequeueAfter(RequeueThrottled)}
+	}
+	return reconcile.Result{RequeueAfter: r.requeue.RequeueAfter(RequeueFinalizerError)}
+}
+
+// interrupted returns whether the supplied Lock no longer exists or is being
+// deleted, and whether it is paused. Errors reading the Lock are ignored;
+// we'll find out whether it was deleted when we next write it.
+func (r *Reconciler) interrupted(ctx context.Context, lock *v1beta1.Lock) (deleted, paused bool) {
+	current := &v1beta1.Lock{}
+	err := r.client.Get(ctx, types.NamespacedName{Name: lock.GetName()}, current)
+	if kerrors.IsNotFound(err) {
+		return true, false
+	}
+	if err != nil {
+		return false, false
 	}
+	return meta.WasDeleted(current), isPaused(current)
+}
+
+// unresolvable reports why the planned install of a missing dependency cannot
+// be installed. It returns a condition describing why, and the reason to
+// requeue, if installation should be retried.
+func (r *Reconciler) unresolvable(ctx context.Context, log logging.Logger, lock *v1beta1.Lock, p xpkg.PlannedInstall) (xpv1.Condition, RequeueReason, error) {
+	err := p.Err
+	log.Debug(errInstallDependency, "reason", err.Reason, "error", err)
 
-	var pack v1.Package
-	switch dep.Type {
-	case v1beta1.ConfigurationPackageType:
-		pack = &v1.Configuration{}
-	case v1beta1.ProviderPackageType:
-		pack = &v1.Provider{}
+	// Only some dependency errors are transient.
+	rq := retry(RequeueFetchError, err)
+
+	switch err.Reason {
+	case xpkg.DependencyInvalidConstraint:
+		r.record.Event(lock, event.Warning(reasonInvalidConstraint, err))
+		r.metrics.failures.WithLabelValues(failureInvalidConstraint).Inc()
+		return v1beta1.InvalidConstraint(), rq, err
+	case xpkg.DependencyFetchTags:
+		r.metrics.failures.WithLabelValues(failureFetchError).Inc()
+		return r.fetchTagsFailed(log, lock, err)
+	case xpkg.DependencyFetchDigest:
+		r.record.Event(lock, event.Warning(reasonFetchDigest, err))
+		r.metrics.failures.WithLabelValues(failureFetchError).Inc()
+		return v1beta1.FetchError(), rq, err
+	case xpkg.DependencyDigestMismatch:
+		r.record.Event(lock, event.Warning(reasonDigestMismatch, err))
+		r.metrics.failures.WithLabelValues(failureDigestMismatch).Inc()
+		return v1beta1.DigestMismatch(), rq, err
+	case xpkg.DependencyNotListed:
+		r.record.Event(lock, event.Warning(reasonNoValidVersion, err))
+		r.metrics.failures.WithLabelValues(failureNoValidVersion).Inc()
+		return v1beta1.NoValidVersion(), rq, err
+	case xpkg.DependencyNoValidVersion:
+		// A tag that satisfies the constraint may since have been pushed,
+		// so we don't want to keep using the cached tags.
+		if ref, perr := name.ParseReference(p.Dependency.Package, xpkg.DefaultRegistryOptions(r.registry)...); perr == nil {
+			r.tags.Invalidate(repository(ref))
+		}
+		r.record.Event(lock, event.Warning(reasonNoValidVersion, err))
+		r.metrics.failures.WithLabelValues(failureNoValidVersion).Inc()
+		return v1beta1.NoValidVersion(), rq, err
+	case xpkg.DependencyVersionGroupConflict:
+		r.record.Event(lock, event.Warning(reasonVersionGroup, err))
+		r.metrics.failures.WithLabelValues(failureVersionGroup).Inc()
+		return v1beta1.NoValidVersion(), rq, err
+	case xpkg.DependencyBlockedByPolicy:
+		r.record.Event(lock, event.Warning(reasonBlockedByPolicy, err))
+		r.metrics.failures.WithLabelValues(failureBlockedByPolicy).Inc()
+		r.recordAudit(ctx, lock, AuditRecord{Action: AuditBlocked, Source: p.Dependency.Package, Dependents: dependentsOf(p.Requirements), Reason: err.Error()})
+		return v1beta1.BlockedByPolicy(), rq, err
+	case xpkg.DependencyInvalid:
+		r.record.Event(lock, event.Warning(reasonInvalidDependency, err))
+		r.metrics.failures.WithLabelValues(failureInvalidDependency).Inc()
+		return v1beta1.InvalidDependency(), rq, err
+	case xpkg.DependencyUnsupportedType:
+		r.record.Event(lock, event.Warning(reasonUnsupportedType, err))
+		r.metrics.failures.WithLabelValues(failureUnsupportedType).Inc()
+		return v1beta1.UnsupportedDependencyType(), rq, err
 	default:
-		log.Debug(errInvalidPackageType)
-		return reconcile.Result{}, nil
+		r.metrics.failures.WithLabelValues(failureInvalidDependency).Inc()
+		return v1beta1.InvalidDependency(), rq, err
 	}
+}
 
-	// NOTE(hasheddan): packages are currently created with default
-	// settings. This means that a dependency must be publicly available as
-	// no packagePullSecrets are set. Settings can be modified manually
-	// after dependency creation to address this.
-	pack.SetName(xpkg.ToDNSLabel(ref.Context().RepositoryStr()))
-	pack.SetSource(fmt.Sprintf(packageTagFmt, ref.String(), addVer))
-
-	// NOTE(hasheddan): consider making the lock the controller of packages
-	// it creates.
-	if err := r.client.Create(ctx, pack); err != nil {
-		log.Debug(errCreateDependency, "error", err)
-		return reconcile.Result{RequeueAfter: shortWait}, nil
+// result returns a reconcile result that requeues after the shortest wait
+// for the supplied reasons.
+func (r *Reconciler) result(reasons map[RequeueReason]bool) reconcile.Result {
+	if len(reasons) == 0 || (len(reasons) == 1 && reasons[RequeueWaiting]) {
+		// Nothing went wrong.
+		r.requeue.Reset()
+	}
+	// Requeueing sooner for another reason would only be throttled again.
+	if reasons[RequeueThrottled] {
+		return reconcile.Result{RequeueAfter: r.requeue.RequeueAfter(RequeueThrottled)}
+	}
+	res := reconcile.Result{}
+	for rr := range reasons {
+		d := r.requeue.RequeueAfter(rr)
+		if d > 0 && (res.RequeueAfter == 0 || d < res.RequeueAfter) {
+			res.RequeueAfter = d
+		}
 	}
+	return res
+}
 
-	return reconcile.Result{}, nil
+// joined returns the first of the supplied conditions, with the messages of
+// all of them.
+func joined(cs []xpv1.Condition) xpv1.Condition {
+	msgs := make([]string, len(cs))
+	for i, c := range cs {
+		msgs[i] = c.Message
+	}
+	return cs[0].WithMessage(strings.Join(msgs, "; "))
 }
@@ -18,10 +18,16 @@ package resolver
 
 import (
 	"context"
+	"fmt"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/google/go-containerregistry/pkg/name"
+	regv1 "github.com/google/go-containerregistry/pkg/v1"
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -29,18 +35,63 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
 	"github.com/crossplane/crossplane-runtime/pkg/resource"
 	"github.com/crossplane/crossplane-runtime/pkg/resource/fake"
 	"github.com/crossplane/crossplane-runtime/pkg/test"
 
+	v1 "github.com/crossplane/crossplane/apis/pkg/v1"
+	"github.com/crossplane/crossplane/apis/pkg/v1alpha1"
 	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
 	"github.com/crossplane/crossplane/internal/dag"
 	fakedag "github.com/crossplane/crossplane/internal/dag/fake"
+	"github.com/crossplane/crossplane/internal/xpkg"
 	fakexpkg "github.com/crossplane/crossplane/internal/xpkg/fake"
 )
 
+// recordedDigests lists versions and the digests recorded when they were
+// published.
+type recordedDigests map[string]string
+
+func (d recordedDigests) ListVersions(_ context.Context, _ name.Reference) ([]string, error) {
+	versions := make([]string, 0, len(d))
+	for v := range d {
+		versions = append(versions, v)
+	}
+	return versions, nil
+}
+
+func (d recordedDigests) RecordedDigest(_ context.Context, _ name.Reference, version string) (string, error) {
+	return d[version], nil
+}
+
+// ignoreResolution ignores the dependency resolution status of a Lock, and
+// the record of when its resolution started, which are tested separately.
+var ignoreResolution = cmpopts.IgnoreFields(v1beta1.LockStatus{}, "DependencyResolution", "ResolutionStartedAt", "ResolutionInstalled")
+
+// A recorder records the reasons of all events it receives.
+type recorder struct {
+	reasons  []event.Reason
+	messages []string
+
+	// objects are the names of the objects events were recorded against.
+	objects []string
+}
+
+func (r *recorder) Event(obj runtime.Object, e event.Event) {
+	r.reasons = append(r.reasons, e.Reason)
+	r.messages = append(r.messages, e.Message)
+	if o, ok := obj.(client.Object); ok {
+		r.objects = append(r.objects, o.GetName())
+	}
+}
+
+func (r *recorder) WithAnnotations(_ ...string) event.Recorder { return r }
+
 func TestReconcile(t *testing.T) {
 	errBoom := errors.New("boom")
+	_, errDigest := name.NewDigest("hasheddan/config-nop-c@sha256:nothex")
 
 	type args struct {
 		mgr manager.Manager
@@ -48,8 +99,9 @@ func TestReconcile(t *testing.T) {
 		rec []ReconcilerOption
 	}
 	type want struct {
-		r   reconcile.Result
-		err error
+		r      reconcile.Result
+		err    error
+		events []event.Reason
 	}
 	cases := map[string]struct {
 		reason string
@@ -85,7 +137,12 @@ func TestReconcile(t *testing.T) {
 			reason: "We should requeue after short wait if we fail to remove finalizer.",
 			args: args{
 				mgr: &fake.Manager{
-					Client: test.NewMockClient(),
+					Client: &test.MockClient{
+						MockGet: test.NewMockGetFn(nil, func(o client.Object) error {
+							o.SetFinalizers([]string{finalizer})
+							return nil
+						}),
+					},
 				},
 				req: reconcile.Request{NamespacedName: types.NamespacedName{Name: "test"}},
 				rec: []ReconcilerOption{
@@ -98,6 +155,36 @@ func TestReconcile(t *testing.T) {
 				r: reconcile.Result{RequeueAfter: shortWait},
 			},
 		},
+		"ConflictRemoveFinalizer": {
+			reason: "We should requeue immediately if our view of the Lock was stale when we removed its finalizer.",
+			args: args{
+				mgr: &fake.Manager{
+					Client: &test.MockClient{
+						MockGet: test.NewMockGetFn(nil, func(o client.Object) error {
+							o.SetFinalizers([]string{finalizer})
+							return nil
+						}),
+						MockUpdate: test.NewMockUpdateFn(kerrors.NewConflict(schema.GroupResource{}, "lock", errBoom)),
+					},
+				},
+				req: reconcile.Request{NamespacedName: types.NamespacedName{Name: "test"}},
+			},
+			want: want{
+				r: reconcile.Result{Requeue: true},
+			},
+		},
+		"NoopRemoveFinalizer": {
+			reason: "We should not update a Lock with no packages if its finalizer was already removed.",
+			args: args{
+				mgr: &fake.Manager{
+					Client: &test.MockClient{
+						MockGet:    test.NewMockGetFn(nil),
+						MockUpdate: test.NewMockUpdateFn(errBoom),
+					},
+				},
+				req: reconcile.Request{NamespacedName: types.NamespacedName{Name: "test"}},
+			},
+		},
 		"SuccessfulEmptyList": {
 			reason: "We should not return error and not requeue if no packages in lock.",
 			args: args{
@@ -107,6 +194,41 @@ func TestReconcile(t *testing.T) {
 				req: reconcile.Request{NamespacedName: types.NamespacedName{Name: "test"}},
 			},
 		},
+		"ConflictAddFinalizer": {
+			reason: "We should requeue immediately if our view of the Lock was stale when we added its finalizer.",
+			args: args{
+				mgr: &fake.Manager{
+					Client: &test.MockClient{
+						MockGet: test.NewMockGetFn(nil, func(o client.Object) error {
+							o.(*v1beta1.Lock).Packages = []v1beta1.LockPackage{{Source: "cool-repo/cool-image", Version: "v0.0.1"}}
+							return nil
+						}),
+						MockUpdate: test.NewMockUpdateFn(kerrors.NewConflict(schema.GroupResource{}, "lock", errBoom)),
+					},
+				},
+				req: reconcile.Request{NamespacedName: types.NamespacedName{Name: "test"}},
+			},
+			want: want{
+				r: reconcile.Result{Requeue: true},
+			},
+		},
+		"NoopAddFinalizer": {
+			reason: "We should not update a Lock if its finalizer was already added.",
+			args: args{
+				mgr: &fake.Manager{
+					Client: &test.MockClient{
+						MockGet: test.NewMockGetFn(nil, func(o client.Object) error {
+							o.SetFinalizers([]string{finalizer})
+							o.(*v1beta1.Lock).Packages = []v1beta1.LockPackage{{Source: "cool-repo/cool-image", Version: "v0.0.1"}}
+							return nil
+						}),
+						MockUpdate:       test.NewMockUpdateFn(errBoom),
+						MockStatusUpdate: test.NewMockStatusUpdateFn(nil),
+					},
+				},
+				req: reconcile.Request{NamespacedName: types.NamespacedName{Name: "test"}},
+			},
+		},
 		"ErrAddFinalizer": {
 			reason: "We should requeue after short wait if we fail to add finalizer.",
 			args: args{
@@ -123,7 +245,8 @@ func TestReconcile(t *testing.T) {
 							})
 							return nil
 						}),
-						MockUpdate: test.NewMockUpdateFn(nil),
+						MockUpdate:       test.NewMockUpdateFn(nil),
+						MockStatusUpdate: test.NewMockStatusUpdateFn(nil),
 					},
 				},
 				req: reconcile.Request{NamespacedName: types.NamespacedName{Name: "test"}},
@@ -155,14 +278,15 @@ func TestReconcile(t *testing.T) {
 							})
 							return nil
 						}),
-						MockUpdate: test.NewMockUpdateFn(nil),
+						MockUpdate:       test.NewMockUpdateFn(nil),
+						MockStatusUpdate: test.NewMockStatusUpdateFn(nil),
 					},
 				},
 				req: reconcile.Request{NamespacedName: types.NamespacedName{Name: "test"}},
 				rec: []ReconcilerOption{
 					WithNewDagFn(func() dag.DAG {
 						return &fakedag.MockDag{
-							MockInit: func(nodes []dag.Node, fns ...dag.NodeFn) ([]dag.Node, error) {
+							MockInit: func(nodes []dag.Node, fns ...dag.NodeFn) ([]dag.ImpliedNode, error) {
 								return nil, errBoom
 							},
 						}
@@ -171,7 +295,7 @@ func TestReconcile(t *testing.T) {
 			},
 			want: want{
 				r:   reconcile.Result{Requeue: false},
-				err: errors.Wrap(errBoom, errBuildDAG),
+				err: errors.Wrap(errBoom, "cannot build DAG"),
 			},
 		},
 		"ErrSortDag": {
@@ -193,15 +317,27 @@ func TestReconcile(t *testing.T) {
 							return nil
 						}),
 						MockUpdate: test.NewMockUpdateFn(nil),
+						MockStatusUpdate: test.NewMockStatusUpdateFn(nil, func(o client.Object) error {
+							want := &v1beta1.Lock{}
+							want.Status.MissingDependencies = 0
+							want.SetConditions(v1beta1.DependencyCycle().WithMessage(errBoom.Error()))
+							unsatisfied(want)
+							if diff := cmp.Diff(want.Status, o.(*v1beta1.Lock).Status, test.EquateConditions(), ignoreResolution); diff != "" {
+								t.Errorf("-want, +got:\n%s", diff)
+							}
+							return nil
+						}),
 					},
 				},
 				req: reconcile.Request{NamespacedName: types.NamespacedName{Name: "test"}},
 				rec: []ReconcilerOption{
 					WithNewDagFn(func() dag.DAG {
 						return &fakedag.MockDag{
-							MockInit: func(nodes []dag.Node, fns ...dag.NodeFn) ([]dag.Node, error) {
+							MockInit: func(nodes []dag.Node, fns ...dag.NodeFn) ([]dag.ImpliedNode, error) {
 								return nil, nil
 							},
+							MockNodeDependents: func(_ string) ([]dag.Node, error) { return nil, nil },
+
 							MockSort: func() ([]string, error) {
 								return nil, errBoom
 							},
@@ -214,6 +350,44 @@ func TestReconcile(t *testing.T) {
 				err: errors.Wrap(errBoom, errSortDAG),
 			},
 		},
+		"DependencyCycle": {
+			reason: "We should report the path of a dependency cycle, and requeue after a long wait rather than returning an error.",
+			args: args{
+				mgr: &fake.Manager{
+					Client: &test.MockClient{
+						MockGet: test.NewMockGetFn(nil, func(o client.Object) error {
+							l := o.(*v1beta1.Lock)
+							l.Packages = []v1beta1.LockPackage{
+								{
+									Source:       "cool-repo/config-a",
+									Dependencies: []v1beta1.Dependency{{Package: "cool-repo/config-b", Constraints: ">=v0.0.1"}},
+								},
+								{
+									Source:       "cool-repo/config-b",
+									Dependencies: []v1beta1.Dependency{{Package: "cool-repo/config-a", Constraints: ">=v0.0.1"}},
+								},
+							}
+							return nil
+						}),
+						MockUpdate: test.NewMockUpdateFn(nil),
+						MockStatusUpdate: test.NewMockStatusUpdateFn(nil, func(o client.Object) error {
+							want := &v1beta1.Lock{}
+							want.SetConditions(v1beta1.DependencyCycle().WithMessage("cool-repo/config-a -> cool-repo/config-b -> cool-repo/config-a"))
+							unsatisfied(want)
+							if diff := cmp.Diff(want.Status, o.(*v1beta1.Lock).Status, test.EquateConditions(), ignoreResolution); diff != "" {
+								t.Errorf("-want, +got:\n%s", diff)
+							}
+							return nil
+						}),
+					},
+				},
+				req: reconcile.Request{NamespacedName: types.NamespacedName{Name: "test"}},
+			},
+			want: want{
+				r:      reconcile.Result{RequeueAfter: longWait},
+				events: []event.Reason{reasonDependencyCycle},
+			},
+		},
 		"SuccessfulNoMissing": {
 			reason: "We should not return error and not requeue if no missing dependencies.",
 			args: args{
@@ -233,15 +407,26 @@ func TestReconcile(t *testing.T) {
 							return nil
 						}),
 						MockUpdate: test.NewMockUpdateFn(nil),
+						MockStatusUpdate: test.NewMockStatusUpdateFn(nil, func(o client.Object) error {
+							want := &v1beta1.Lock{}
+							want.Status.MissingDependencies = 0
+							want.SetConditions(v1beta1.DependenciesResolved(), v1beta1.DependenciesSatisfied())
+							if diff := cmp.Diff(want.Status, o.(*v1beta1.Lock).Status, test.EquateConditions(), ignoreResolution); diff != "" {
+								t.Errorf("-want, +got:\n%s", diff)
+							}
+							return nil
+						}),
 					},
 				},
 				req: reconcile.Request{NamespacedName: types.NamespacedName{Name: "test"}},
 				rec: []ReconcilerOption{
 					WithNewDagFn(func() dag.DAG {
 						return &fakedag.MockDag{
-							MockInit: func(nodes []dag.Node, fns ...dag.NodeFn) ([]dag.Node, error) {
+							MockInit: func(nodes []dag.Node, fns ...dag.NodeFn) ([]dag.ImpliedNode, error) {
 								return nil, nil
 							},
+							MockNodeDependents: func(_ string) ([]dag.Node, error) { return nil, nil },
+
 							MockSort: func() ([]string, error) {
 								return nil, nil
 							},
@@ -253,8 +438,241 @@ func TestReconcile(t *testing.T) {
 				r: reconcile.Result{Requeue: false},
 			},
 		},
+		"VersionConflict": {
+			reason: "We should report a conflict if an installed package does not satisfy the constraints of a package that depends on it.",
+			args: args{
+				mgr: &fake.Manager{
+					Client: &test.MockClient{
+						MockGet: test.NewMockGetFn(nil, func(o client.Object) error {
+							l := o.(*v1beta1.Lock)
+							l.Packages = []v1beta1.LockPackage{
+								{
+									Name:    "provider-aws",
+									Type:    v1beta1.ProviderPackageType,
+									Source:  "crossplane/provider-aws",
+									Version: "v0.20.0",
+								},
+								{
+									Name:    "cool-config",
+									Type:    v1beta1.ConfigurationPackageType,
+									Source:  "cool-repo/cool-config",
+									Version: "v0.0.1",
+									Dependencies: []v1beta1.Dependency{{
+										Package:     "crossplane/provider-aws",
+										Type:        v1beta1.ProviderPackageType,
+										Constraints: ">=v0.21.0",
+									}},
+								},
+							}
+							return nil
+						}),
+						MockUpdate: test.NewMockUpdateFn(nil),
+						MockStatusUpdate: test.NewMockStatusUpdateFn(nil, func(o client.Object) error {
+							want := &v1beta1.Lock{}
+							want.SetConditions(v1beta1.VersionConflict().WithMessage(`installed version v0.20.0 of package crossplane/provider-aws does not satisfy ">=v0.21.0" required by cool-repo/cool-config`))
+							unsatisfied(want)
+							if diff := cmp.Diff(want.Status, o.(*v1beta1.Lock).Status, test.EquateConditions(), ignoreResolution); diff != "" {
+								t.Errorf("-want, +got:\n%s", diff)
+							}
+							return nil
+						}),
+					},
+				},
+				req: reconcile.Request{NamespacedName: types.NamespacedName{Name: "test"}},
+				rec: []ReconcilerOption{
+					WithNewDagFn(func() dag.DAG {
+						return &fakedag.MockDag{
+							MockInit: func(nodes []dag.Node, fns ...dag.NodeFn) ([]dag.ImpliedNode, error) {
+								return nil, nil
+							},
+							MockNodeDependents: func(_ string) ([]dag.Node, error) { return nil, nil },
+
+							MockSort: func() ([]string, error) {
+								return nil, nil
+							},
+						}
+					}),
+				},
+			},
+			want: want{
+				r:      reconcile.Result{Requeue: false},
+				events: []event.Reason{reasonVersionConflict},
+			},
+		},
+		"VersionConflictDependencyOrigin": {
+			reason: "We should report which packages an installed package was installed as a dependency of if it does not satisfy the constraints of a package that depends on it.",
+			args: args{
+				mgr: &fake.Manager{
+					Client: &test.MockClient{
+						MockGet: test.NewMockGetFn(nil, func(o client.Object) error {
+							l := o.(*v1beta1.Lock)
+							l.Packages = []v1beta1.LockPackage{
+								{
+									Name:    "provider-aws",
+									Type:    v1beta1.ProviderPackageType,
+									Source:  "crossplane/provider-aws",
+									Version: "v0.20.0",
+									Origin:  &v1beta1.PackageOrigin{Type: v1beta1.OriginDependency, Parents: []string{"other-repo/other-config"}},
+								},
+								{
+									Name:    "cool-config",
+									Type:    v1beta1.ConfigurationPackageType,
+									Source:  "cool-repo/cool-config",
+									Version: "v0.0.1",
+									Dependencies: []v1beta1.Dependency{{
+										Package:     "crossplane/provider-aws",
+										Type:        v1beta1.ProviderPackageType,
+										Constraints: ">=v0.21.0",
+									}},
+								},
+							}
+							return nil
+						}),
+						MockUpdate: test.NewMockUpdateFn(nil),
+						MockStatusUpdate: test.NewMockStatusUpdateFn(nil, func(o client.Object) error {
+							want := &v1beta1.Lock{}
+							want.SetConditions(v1beta1.VersionConflict().WithMessage(`installed version v0.20.0 of package crossplane/provider-aws (installed as a dependency of other-repo/other-config) does not satisfy ">=v0.21.0" required by cool-repo/cool-config`))
+							unsatisfied(want)
+							if diff := cmp.Diff(want.Status, o.(*v1beta1.Lock).Status, test.EquateConditions(), ignoreResolution); diff != "" {
+								t.Errorf("-want, +got:\n%s", diff)
+							}
+							return nil
+						}),
+					},
+				},
+				req: reconcile.Request{NamespacedName: types.NamespacedName{Name: "test"}},
+				rec: []ReconcilerOption{
+					WithNewDagFn(func() dag.DAG {
+						return &fakedag.MockDag{
+							MockInit: func(nodes []dag.Node, fns ...dag.NodeFn) ([]dag.ImpliedNode, error) {
+								return nil, nil
+							},
+							MockNodeDependents: func(_ string) ([]dag.Node, error) { return nil, nil },
+
+							MockSort: func() ([]string, error) {
+								return nil, nil
+							},
+						}
+					}),
+				},
+			},
+			want: want{
+				r:      reconcile.Result{Requeue: false},
+				events: []event.Reason{reasonVersionConflict},
+			},
+		},
+		"TypeConflict": {
+			reason: "We should report, rather than consider satisfied, a dependency whose installed package is not the type of package declared by a package that depends on it.",
+			args: args{
+				mgr: &fake.Manager{
+					Client: &test.MockClient{
+						MockGet: test.NewMockGetFn(nil, func(o client.Object) error {
+							l := o.(*v1beta1.Lock)
+							l.Packages = []v1beta1.LockPackage{
+								{
+									Name:    "acme-foo",
+									Type:    v1beta1.ConfigurationPackageType,
+									Source:  "acme/foo",
+									Version: "v0.21.0",
+								},
+								{
+									Name:    "cool-config",
+									Type:    v1beta1.ConfigurationPackageType,
+									Source:  "cool-repo/cool-config",
+									Version: "v0.0.1",
+									Dependencies: []v1beta1.Dependency{{
+										Package:     "acme/foo",
+										Type:        v1beta1.ProviderPackageType,
+										Constraints: ">=v0.21.0",
+									}},
+								},
+							}
+							return nil
+						}),
+						MockUpdate: test.NewMockUpdateFn(nil),
+						MockStatusUpdate: test.NewMockStatusUpdateFn(nil, func(o client.Object) error {
+							want := &v1beta1.Lock{}
+							want.SetConditions(v1beta1.TypeConflict().WithMessage("dependency acme/foo declared as Provider by cool-repo/cool-config but installed package is a Configuration"))
+							unsatisfied(want)
+							if diff := cmp.Diff(want.Status, o.(*v1beta1.Lock).Status, test.EquateConditions(), ignoreResolution); diff != "" {
+								t.Errorf("-want, +got:\n%s", diff)
+							}
+							return nil
+						}),
+					},
+				},
+				req: reconcile.Request{NamespacedName: types.NamespacedName{Name: "test"}},
+				rec: []ReconcilerOption{
+					WithNewDagFn(func() dag.DAG {
+						return &fakedag.MockDag{
+							MockInit: func(nodes []dag.Node, fns ...dag.NodeFn) ([]dag.ImpliedNode, error) {
+								return nil, nil
+							},
+							MockNodeDependents: func(_ string) ([]dag.Node, error) { return nil, nil },
+
+							MockSort: func() ([]string, error) {
+								return nil, nil
+							},
+						}
+					}),
+				},
+			},
+			want: want{
+				r:      reconcile.Result{Requeue: false},
+				events: []event.Reason{reasonTypeConflict},
+			},
+		},
 		"ErrorInvalidDependency": {
 			reason: "We should not requeue if dependency is invalid.",
+			args: args{
+				mgr: &fake.Manager{
+					Client: &test.MockClient{
+						MockGet: test.NewMockGetFn(nil, func(o client.Object) error {
+							// Populate package list so we attempt
+							// reconciliation. This is overridden by the mock
+							// DAG.
+							l := o.(*v1beta1.Lock)
+							l.Packages = append(l.Packages, v1beta1.LockPackage{
+								Name:    "cool-package",
+								Type:    v1beta1.ProviderPackageType,
+								Source:  "cool-repo/cool-image",
+								Version: "v0.0.1",
+							})
+							return nil
+						}),
+						MockUpdate:       test.NewMockUpdateFn(nil),
+						MockStatusUpdate: test.NewMockStatusUpdateFn(nil),
+					},
+				},
+				req: reconcile.Request{NamespacedName: types.NamespacedName{Name: "test"}},
+				rec: []ReconcilerOption{
+					WithNewDagFn(func() dag.DAG {
+						return &fakedag.MockDag{
+							MockInit: func(nodes []dag.Node, fns ...dag.NodeFn) ([]dag.ImpliedNode, error) {
+								return []dag.ImpliedNode{
+									{Node: &v1beta1.Dependency{
+										Package:     "not a valid package",
+										Constraints: ">=v1.0.0",
+										Type:        v1beta1.ConfigurationPackageType,
+									}},
+								}, nil
+							},
+							MockNodeDependents: func(_ string) ([]dag.Node, error) { return nil, nil },
+
+							MockSort: func() ([]string, error) {
+								return nil, nil
+							},
+						}
+					}),
+				},
+			},
+			want: want{
+				r:      reconcile.Result{Requeue: false},
+				events: []event.Reason{reasonInvalidDependency},
+			},
+		},
+		"ErrorInvalidConstraint": {
+			reason: "We should not requeue if dependency constraint is invalid.",
 			args: args{
 				mgr: &fake.Manager{
 					Client: &test.MockClient{
@@ -272,19 +690,33 @@ func TestReconcile(t *testing.T) {
 							return nil
 						}),
 						MockUpdate: test.NewMockUpdateFn(nil),
+						MockStatusUpdate: test.NewMockStatusUpdateFn(nil, func(o client.Object) error {
+							want := &v1beta1.Lock{}
+							want.Status.MissingDependencies = 1
+							want.SetConditions(v1beta1.InvalidConstraint().WithMessage(errors.Wrapf(errors.New("improper constraint: not a constraint"), errDependencyFmt, "version constraint on dependency is invalid", "hasheddan/config-nop-b", "not a constraint").Error()))
+							unsatisfied(want)
+							if diff := cmp.Diff(want.Status, o.(*v1beta1.Lock).Status, test.EquateConditions(), ignoreResolution); diff != "" {
+								t.Errorf("-want, +got:\n%s", diff)
+							}
+							return nil
+						}),
 					},
 				},
 				req: reconcile.Request{NamespacedName: types.NamespacedName{Name: "test"}},
 				rec: []ReconcilerOption{
 					WithNewDagFn(func() dag.DAG {
 						return &fakedag.MockDag{
-							MockInit: func(nodes []dag.Node, fns ...dag.NodeFn) ([]dag.Node, error) {
-								return []dag.Node{
-									&v1beta1.Dependency{
-										Package: "not.a.valid.package",
-									},
+							MockInit: func(nodes []dag.Node, fns ...dag.NodeFn) ([]dag.ImpliedNode, error) {
+								return []dag.ImpliedNode{
+									{Node: &v1beta1.Dependency{
+										Package:     "hasheddan/config-nop-b",
+										Constraints: "not a constraint",
+										Type:        v1beta1.ConfigurationPackageType,
+									}},
 								}, nil
 							},
+							MockNodeDependents: func(_ string) ([]dag.Node, error) { return nil, nil },
+
 							MockSort: func() ([]string, error) {
 								return nil, nil
 							},
@@ -293,7 +725,8 @@ func TestReconcile(t *testing.T) {
 				},
 			},
 			want: want{
-				r: reconcile.Result{Requeue: false},
+				r:      reconcile.Result{Requeue: false},
+				events: []event.Reason{reasonInvalidConstraint},
 			},
 		},
 		"ErrorFetchTags": {
@@ -315,20 +748,33 @@ func TestReconcile(t *testing.T) {
 							return nil
 						}),
 						MockUpdate: test.NewMockUpdateFn(nil),
+						MockStatusUpdate: test.NewMockStatusUpdateFn(nil, func(o client.Object) error {
+							want := &v1beta1.Lock{}
+							want.Status.MissingDependencies = 1
+							want.SetConditions(v1beta1.FetchError().WithMessage(errors.Wrapf(errBoom, errDependencyFmt, errFetchTags, "hasheddan/config-nop-b", "*").Error()))
+							unsatisfied(want)
+							if diff := cmp.Diff(want.Status, o.(*v1beta1.Lock).Status, test.EquateConditions(), ignoreResolution); diff != "" {
+								t.Errorf("-want, +got:\n%s", diff)
+							}
+							return nil
+						}),
 					},
 				},
 				req: reconcile.Request{NamespacedName: types.NamespacedName{Name: "test"}},
 				rec: []ReconcilerOption{
 					WithNewDagFn(func() dag.DAG {
 						return &fakedag.MockDag{
-							MockInit: func(nodes []dag.Node, fns ...dag.NodeFn) ([]dag.Node, error) {
-								return []dag.Node{
-									&v1beta1.Dependency{
+							MockInit: func(nodes []dag.Node, fns ...dag.NodeFn) ([]dag.ImpliedNode, error) {
+								return []dag.ImpliedNode{
+									{Node: &v1beta1.Dependency{
 										Package:     "hasheddan/config-nop-b",
 										Constraints: "*",
-									},
+										Type:        v1beta1.ConfigurationPackageType,
+									}},
 								}, nil
 							},
+							MockNodeDependents: func(_ string) ([]dag.Node, error) { return nil, nil },
+
 							MockSort: func() ([]string, error) {
 								return nil, nil
 							},
@@ -340,11 +786,12 @@ func TestReconcile(t *testing.T) {
 				},
 			},
 			want: want{
-				r: reconcile.Result{RequeueAfter: shortWait},
+				events: []event.Reason{reasonFetchTags},
+				r:      reconcile.Result{RequeueAfter: shortWait},
 			},
 		},
 		"ErrorNoValidVersion": {
-			reason: "We should not requeue if valid version does not exist for dependency.",
+			reason: "We should wait to retry if valid version does not exist for dependency.",
 			args: args{
 				mgr: &fake.Manager{
 					Client: &test.MockClient{
@@ -362,20 +809,33 @@ func TestReconcile(t *testing.T) {
 							return nil
 						}),
 						MockUpdate: test.NewMockUpdateFn(nil),
+						MockStatusUpdate: test.NewMockStatusUpdateFn(nil, func(o client.Object) error {
+							want := &v1beta1.Lock{}
+							want.Status.MissingDependencies = 1
+							want.SetConditions(v1beta1.NoValidVersion().WithMessage(errors.New(`cannot find a valid version for package constraints: dependency (hasheddan/config-nop-b) does not have version in constraints (>v1.0.0): highest available version is v1.0.0`).Error()))
+							unsatisfied(want)
+							if diff := cmp.Diff(want.Status, o.(*v1beta1.Lock).Status, test.EquateConditions(), ignoreResolution); diff != "" {
+								t.Errorf("-want, +got:\n%s", diff)
+							}
+							return nil
+						}),
 					},
 				},
 				req: reconcile.Request{NamespacedName: types.NamespacedName{Name: "test"}},
 				rec: []ReconcilerOption{
 					WithNewDagFn(func() dag.DAG {
 						return &fakedag.MockDag{
-							MockInit: func(nodes []dag.Node, fns ...dag.NodeFn) ([]dag.Node, error) {
-								return []dag.Node{
-									&v1beta1.Dependency{
+							MockInit: func(nodes []dag.Node, fns ...dag.NodeFn) ([]dag.ImpliedNode, error) {
+								return []dag.ImpliedNode{
+									{Node: &v1beta1.Dependency{
 										Package:     "hasheddan/config-nop-b",
 										Constraints: ">v1.0.0",
-									},
+										Type:        v1beta1.ConfigurationPackageType,
+									}},
 								}, nil
 							},
+							MockNodeDependents: func(_ string) ([]dag.Node, error) { return nil, nil },
+
 							MockSort: func() ([]string, error) {
 								return nil, nil
 							},
@@ -387,7 +847,8 @@ func TestReconcile(t *testing.T) {
 				},
 			},
 			want: want{
-				r: reconcile.Result{Requeue: false},
+				events: []event.Reason{reasonNoValidVersion},
+				r:      reconcile.Result{RequeueAfter: defaultUnresolvedRetryInterval},
 			},
 		},
 		"ErrorCreateMissingDependency": {
@@ -408,23 +869,27 @@ func TestReconcile(t *testing.T) {
 							})
 							return nil
 						}),
-						MockCreate: test.NewMockCreateFn(errBoom),
-						MockUpdate: test.NewMockUpdateFn(nil),
+						MockList:         test.NewMockListFn(nil),
+						MockCreate:       test.NewMockCreateFn(errBoom),
+						MockUpdate:       test.NewMockUpdateFn(nil),
+						MockStatusUpdate: test.NewMockStatusUpdateFn(nil),
 					},
 				},
 				req: reconcile.Request{NamespacedName: types.NamespacedName{Name: "test"}},
 				rec: []ReconcilerOption{
 					WithNewDagFn(func() dag.DAG {
 						return &fakedag.MockDag{
-							MockInit: func(nodes []dag.Node, fns ...dag.NodeFn) ([]dag.Node, error) {
-								return []dag.Node{
-									&v1beta1.Dependency{
+							MockInit: func(nodes []dag.Node, fns ...dag.NodeFn) ([]dag.ImpliedNode, error) {
+								return []dag.ImpliedNode{
+									{Node: &v1beta1.Dependency{
 										Package:     "hasheddan/config-nop-c",
 										Constraints: ">v1.0.0",
 										Type:        v1beta1.ConfigurationPackageType,
-									},
+									}},
 								}, nil
 							},
+							MockNodeDependents: func(_ string) ([]dag.Node, error) { return nil, nil },
+
 							MockSort: func() ([]string, error) {
 								return nil, nil
 							},
@@ -436,7 +901,8 @@ func TestReconcile(t *testing.T) {
 				},
 			},
 			want: want{
-				r: reconcile.Result{RequeueAfter: shortWait},
+				events: []event.Reason{reasonCreateDependency},
+				r:      reconcile.Result{RequeueAfter: shortWait},
 			},
 		},
 		"SuccessfulCreateMissingDependency": {
@@ -457,23 +923,36 @@ func TestReconcile(t *testing.T) {
 							})
 							return nil
 						}),
+						MockList:   test.NewMockListFn(nil),
 						MockCreate: test.NewMockCreateFn(nil),
 						MockUpdate: test.NewMockUpdateFn(nil),
+						MockStatusUpdate: test.NewMockStatusUpdateFn(nil, func(o client.Object) error {
+							want := &v1beta1.Lock{}
+							want.Status.MissingDependencies = 1
+							want.SetConditions(v1beta1.MissingDependency().WithMessage(fmt.Sprintf(msgMissingDependenciesFmt, 1)))
+							unsatisfied(want)
+							if diff := cmp.Diff(want.Status, o.(*v1beta1.Lock).Status, test.EquateConditions(), ignoreResolution); diff != "" {
+								t.Errorf("-want, +got:\n%s", diff)
+							}
+							return nil
+						}),
 					},
 				},
 				req: reconcile.Request{NamespacedName: types.NamespacedName{Name: "test"}},
 				rec: []ReconcilerOption{
 					WithNewDagFn(func() dag.DAG {
 						return &fakedag.MockDag{
-							MockInit: func(nodes []dag.Node, fns ...dag.NodeFn) ([]dag.Node, error) {
-								return []dag.Node{
-									&v1beta1.Dependency{
+							MockInit: func(nodes []dag.Node, fns ...dag.NodeFn) ([]dag.ImpliedNode, error) {
+								return []dag.ImpliedNode{
+									{Node: &v1beta1.Dependency{
 										Package:     "hasheddan/config-nop-c",
 										Constraints: ">v1.0.0",
 										Type:        v1beta1.ConfigurationPackageType,
-									},
+									}},
 								}, nil
 							},
+							MockNodeDependents: func(_ string) ([]dag.Node, error) { return nil, nil },
+
 							MockSort: func() ([]string, error) {
 								return nil, nil
 							},
@@ -485,22 +964,900 @@ func TestReconcile(t *testing.T) {
 				},
 			},
 			want: want{
-				r: reconcile.Result{Requeue: false},
+				r:      reconcile.Result{Requeue: false},
+				events: []event.Reason{reasonInstallDependency},
 			},
 		},
-	}
-
-	for name, tc := range cases {
-		t.Run(name, func(t *testing.T) {
-			r := NewReconciler(tc.args.mgr, tc.args.rec...)
-			got, err := r.Reconcile(context.Background(), reconcile.Request{})
-
-			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
-				t.Errorf("\n%s\nr.Reconcile(...): -want error, +got error:\n%s", tc.reason, diff)
-			}
-			if diff := cmp.Diff(tc.want.r, got, test.EquateErrors()); diff != "" {
-				t.Errorf("\n%s\nr.Reconcile(...): -want, +got:\n%s", tc.reason, diff)
-			}
-		})
+		"SkipDependencyInstallation": {
+			reason: "We should report, but not create, missing dependencies if automatic installation is disabled.",
+			args: args{
+				mgr: &fake.Manager{
+					Client: &test.MockClient{
+						MockGet: test.NewMockGetFn(nil, func(o client.Object) error {
+							l := o.(*v1beta1.Lock)
+							l.Spec.SkipDependencyInstallation = true
+							l.Packages = append(l.Packages, v1beta1.LockPackage{
+								Name:    "cool-package",
+								Type:    v1beta1.ProviderPackageType,
+								Source:  "cool-repo/cool-image",
+								Version: "v0.0.1",
+							})
+							return nil
+						}),
+						MockList: test.NewMockListFn(nil),
+						MockCreate: func(_ context.Context, _ client.Object, _ ...client.CreateOption) error {
+							t.Errorf("unexpected call to Create")
+							return nil
+						},
+						MockUpdate: test.NewMockUpdateFn(nil),
+						MockStatusUpdate: test.NewMockStatusUpdateFn(nil, func(o client.Object) error {
+							want := &v1beta1.Lock{}
+							want.Status.MissingDependencies = 1
+							want.SetConditions(v1beta1.MissingDependency().WithMessage(fmt.Sprintf(msgSkipInstallationFmt, 1)))
+							unsatisfied(want)
+							if diff := cmp.Diff(want.Status, o.(*v1beta1.Lock).Status, test.EquateConditions(), ignoreResolution); diff != "" {
+								t.Errorf("-want, +got:\n%s", diff)
+							}
+							return nil
+						}),
+					},
+				},
+				req: reconcile.Request{NamespacedName: types.NamespacedName{Name: "test"}},
+				rec: []ReconcilerOption{
+					WithNewDagFn(func() dag.DAG {
+						return &fakedag.MockDag{
+							MockInit: func(nodes []dag.Node, fns ...dag.NodeFn) ([]dag.ImpliedNode, error) {
+								return []dag.ImpliedNode{
+									{Node: &v1beta1.Dependency{
+										Package:     "hasheddan/config-nop-c",
+										Constraints: ">v1.0.0",
+										Type:        v1beta1.ConfigurationPackageType,
+									}},
+								}, nil
+							},
+							MockNodeDependents: func(_ string) ([]dag.Node, error) { return nil, nil },
+
+							MockSort: func() ([]string, error) {
+								return nil, nil
+							},
+						}
+					}),
+					WithFetcher(&fakexpkg.MockFetcher{
+						MockTags: fakexpkg.NewMockTagsFn(nil, errBoom),
+					}),
+				},
+			},
+			want: want{
+				r:      reconcile.Result{Requeue: false},
+				events: []event.Reason{reasonSkipInstallation},
+			},
+		},
+		"SuccessfulCreateDigestDependency": {
+			reason: "We should create a dependency pinned to a digest without fetching tags.",
+			args: args{
+				mgr: &fake.Manager{
+					Client: &test.MockClient{
+						MockGet: test.NewMockGetFn(nil, func(o client.Object) error {
+							// Populate package list so we attempt
+							// reconciliation. This is overridden by the mock
+							// DAG.
+							l := o.(*v1beta1.Lock)
+							l.Packages = append(l.Packages, v1beta1.LockPackage{
+								Name:    "cool-package",
+								Type:    v1beta1.ProviderPackageType,
+								Source:  "cool-repo/cool-image",
+								Version: "v0.0.1",
+							})
+							return nil
+						}),
+						MockList: test.NewMockListFn(nil),
+						MockCreate: test.NewMockCreateFn(nil, func(o client.Object) error {
+							if diff := cmp.Diff("hasheddan/config-nop-c@sha256:ecc25c121431dfc7058754427f97c034ecde26d4aafa0da16d6429d9f7d6cd9b", o.(v1.Package).GetSource()); diff != "" {
+								t.Errorf("-want, +got:\n%s", diff)
+							}
+							return nil
+						}),
+						MockUpdate: test.NewMockUpdateFn(nil),
+						MockStatusUpdate: test.NewMockStatusUpdateFn(nil, func(o client.Object) error {
+							want := &v1beta1.Lock{}
+							want.Status.MissingDependencies = 1
+							want.SetConditions(v1beta1.MissingDependency().WithMessage(fmt.Sprintf(msgMissingDependenciesFmt, 1)))
+							unsatisfied(want)
+							if diff := cmp.Diff(want.Status, o.(*v1beta1.Lock).Status, test.EquateConditions(), ignoreResolution); diff != "" {
+								t.Errorf("-want, +got:\n%s", diff)
+							}
+							return nil
+						}),
+					},
+				},
+				req: reconcile.Request{NamespacedName: types.NamespacedName{Name: "test"}},
+				rec: []ReconcilerOption{
+					WithNewDagFn(func() dag.DAG {
+						return &fakedag.MockDag{
+							MockInit: func(nodes []dag.Node, fns ...dag.NodeFn) ([]dag.ImpliedNode, error) {
+								return []dag.ImpliedNode{
+									{Node: &v1beta1.Dependency{
+										Package:     "hasheddan/config-nop-c",
+										Constraints: "sha256:ecc25c121431dfc7058754427f97c034ecde26d4aafa0da16d6429d9f7d6cd9b",
+										Type:        v1beta1.ConfigurationPackageType,
+									}},
+								}, nil
+							},
+							MockNodeDependents: func(_ string) ([]dag.Node, error) { return nil, nil },
+
+							MockSort: func() ([]string, error) {
+								return nil, nil
+							},
+						}
+					}),
+					// Pinned dependencies should not require fetching tags.
+					WithFetcher(&fakexpkg.MockFetcher{
+						MockTags: fakexpkg.NewMockTagsFn(nil, errBoom),
+					}),
+				},
+			},
+			want: want{
+				r:      reconcile.Result{Requeue: false},
+				events: []event.Reason{reasonInstallDependency},
+			},
+		},
+		"SuccessfulCreatePinnedDigestDependency": {
+			reason: "We should create a dependency at the digest its selected tag points at if we pin digests.",
+			args: args{
+				mgr: &fake.Manager{
+					Client: &test.MockClient{
+						MockGet: test.NewMockGetFn(nil, func(o client.Object) error {
+							l := o.(*v1beta1.Lock)
+							l.Packages = append(l.Packages, v1beta1.LockPackage{
+								Name:    "cool-package",
+								Type:    v1beta1.ProviderPackageType,
+								Source:  "cool-repo/cool-image",
+								Version: "v0.0.1",
+							})
+							return nil
+						}),
+						MockList: test.NewMockListFn(nil),
+						MockCreate: test.NewMockCreateFn(nil, func(o client.Object) error {
+							if diff := cmp.Diff("hasheddan/config-nop-c@sha256:ecc25c121431dfc7058754427f97c034ecde26d4aafa0da16d6429d9f7d6cd9b", o.(v1.Package).GetSource()); diff != "" {
+								t.Errorf("-want, +got:\n%s", diff)
+							}
+							if diff := cmp.Diff("v0.2.0", o.GetAnnotations()[AnnotationResolvedVersion]); diff != "" {
+								t.Errorf("-want, +got:\n%s", diff)
+							}
+							return nil
+						}),
+						MockUpdate:       test.NewMockUpdateFn(nil),
+						MockStatusUpdate: test.NewMockStatusUpdateFn(nil),
+					},
+				},
+				req: reconcile.Request{NamespacedName: types.NamespacedName{Name: "test"}},
+				rec: []ReconcilerOption{
+					WithNewDagFn(func() dag.DAG {
+						return &fakedag.MockDag{
+							MockInit: func(nodes []dag.Node, fns ...dag.NodeFn) ([]dag.ImpliedNode, error) {
+								return []dag.ImpliedNode{
+									{Node: &v1beta1.Dependency{
+										Package:     "hasheddan/config-nop-c",
+										Constraints: "v0.1.0 || v0.2.0",
+										Type:        v1beta1.ConfigurationPackageType,
+									}},
+								}, nil
+							},
+							MockNodeDependents: func(_ string) ([]dag.Node, error) { return nil, nil },
+
+							MockSort: func() ([]string, error) {
+								return nil, nil
+							},
+						}
+					}),
+					WithFetcher(&fakexpkg.MockFetcher{
+						MockTags: fakexpkg.NewMockTagsFn([]string{"v0.1.0", "v0.2.0", "v0.3.0"}, nil),
+						MockHead: fakexpkg.NewMockHeadFn(&regv1.Descriptor{Digest: regv1.Hash{Algorithm: "sha256", Hex: "ecc25c121431dfc7058754427f97c034ecde26d4aafa0da16d6429d9f7d6cd9b"}}, nil),
+					}),
+					WithDigestPinning(),
+				},
+			},
+			want: want{
+				r:      reconcile.Result{Requeue: false},
+				events: []event.Reason{reasonInstallDependency},
+			},
+		},
+		"ErrorDigestMismatch": {
+			reason: "We should not create a dependency, or requeue, if its selected tag no longer points at the digest recorded when it was published.",
+			args: args{
+				mgr: &fake.Manager{
+					Client: &test.MockClient{
+						MockGet: test.NewMockGetFn(nil, func(o client.Object) error {
+							l := o.(*v1beta1.Lock)
+							l.Packages = append(l.Packages, v1beta1.LockPackage{
+								Name:    "cool-package",
+								Type:    v1beta1.ProviderPackageType,
+								Source:  "cool-repo/cool-image",
+								Version: "v0.0.1",
+							})
+							return nil
+						}),
+						MockList:   test.NewMockListFn(nil),
+						MockCreate: test.NewMockCreateFn(errBoom),
+						MockUpdate: test.NewMockUpdateFn(nil),
+						MockStatusUpdate: test.NewMockStatusUpdateFn(nil, func(o client.Object) error {
+							got := o.(*v1beta1.Lock).Status.GetCondition(v1beta1.TypeDependenciesResolved)
+							if diff := cmp.Diff(v1beta1.ReasonDigestMismatch, got.Reason); diff != "" {
+								t.Errorf("-want, +got:\n%s", diff)
+							}
+							return nil
+						}),
+					},
+				},
+				req: reconcile.Request{NamespacedName: types.NamespacedName{Name: "test"}},
+				rec: []ReconcilerOption{
+					WithNewDagFn(func() dag.DAG {
+						return &fakedag.MockDag{
+							MockInit: func(nodes []dag.Node, fns ...dag.NodeFn) ([]dag.ImpliedNode, error) {
+								return []dag.ImpliedNode{
+									{Node: &v1beta1.Dependency{
+										Package:     "hasheddan/config-nop-c",
+										Constraints: ">=v0.1.0",
+										Type:        v1beta1.ConfigurationPackageType,
+									}},
+								}, nil
+							},
+							MockNodeDependents: func(_ string) ([]dag.Node, error) { return nil, nil },
+
+							MockSort: func() ([]string, error) {
+								return nil, nil
+							},
+						}
+					}),
+					WithFetcher(&fakexpkg.MockFetcher{
+						MockHead: fakexpkg.NewMockHeadFn(&regv1.Descriptor{Digest: regv1.Hash{Algorithm: "sha256", Hex: "ecc25c121431dfc7058754427f97c034ecde26d4aafa0da16d6429d9f7d6cd9b"}}, nil),
+					}),
+					WithVersionSource(recordedDigests{"v0.1.0": "sha256:3b5d"}),
+					WithDigestPinning(),
+				},
+			},
+			want: want{
+				r:      reconcile.Result{},
+				events: []event.Reason{reasonDigestMismatch},
+			},
+		},
+		"SuccessfulCreateTagDependency": {
+			reason: "We should create a dependency pinned to an exact tag without fetching tags.",
+			args: args{
+				mgr: &fake.Manager{
+					Client: &test.MockClient{
+						MockGet: test.NewMockGetFn(nil, func(o client.Object) error {
+							// Populate package list so we attempt
+							// reconciliation. This is overridden by the mock
+							// DAG.
+							l := o.(*v1beta1.Lock)
+							l.Packages = append(l.Packages, v1beta1.LockPackage{
+								Name:    "cool-package",
+								Type:    v1beta1.ProviderPackageType,
+								Source:  "cool-repo/cool-image",
+								Version: "v0.0.1",
+							})
+							return nil
+						}),
+						MockList: test.NewMockListFn(nil),
+						MockCreate: test.NewMockCreateFn(nil, func(o client.Object) error {
+							if diff := cmp.Diff("hasheddan/config-nop-c:main", o.(v1.Package).GetSource()); diff != "" {
+								t.Errorf("-want, +got:\n%s", diff)
+							}
+							return nil
+						}),
+						MockUpdate: test.NewMockUpdateFn(nil),
+						MockStatusUpdate: test.NewMockStatusUpdateFn(nil, func(o client.Object) error {
+							want := &v1beta1.Lock{}
+							want.Status.MissingDependencies = 1
+							want.SetConditions(v1beta1.MissingDependency().WithMessage(fmt.Sprintf(msgMissingDependenciesFmt, 1)))
+							unsatisfied(want)
+							if diff := cmp.Diff(want.Status, o.(*v1beta1.Lock).Status, test.EquateConditions(), ignoreResolution); diff != "" {
+								t.Errorf("-want, +got:\n%s", diff)
+							}
+							return nil
+						}),
+					},
+				},
+				req: reconcile.Request{NamespacedName: types.NamespacedName{Name: "test"}},
+				rec: []ReconcilerOption{
+					WithNewDagFn(func() dag.DAG {
+						return &fakedag.MockDag{
+							MockInit: func(nodes []dag.Node, fns ...dag.NodeFn) ([]dag.ImpliedNode, error) {
+								return []dag.ImpliedNode{
+									{Node: &v1beta1.Dependency{
+										Package:     "hasheddan/config-nop-c",
+										Constraints: "main",
+										Type:        v1beta1.ConfigurationPackageType,
+									}},
+								}, nil
+							},
+							MockNodeDependents: func(_ string) ([]dag.Node, error) { return nil, nil },
+
+							MockSort: func() ([]string, error) {
+								return nil, nil
+							},
+						}
+					}),
+					// Pinned dependencies should not require fetching tags.
+					WithFetcher(&fakexpkg.MockFetcher{
+						MockTags: fakexpkg.NewMockTagsFn(nil, errBoom),
+					}),
+				},
+			},
+			want: want{
+				r:      reconcile.Result{Requeue: false},
+				events: []event.Reason{reasonInstallDependency},
+			},
+		},
+		"ErrorMalformedDigestDependency": {
+			reason: "We should not requeue if a dependency is pinned to a malformed digest.",
+			args: args{
+				mgr: &fake.Manager{
+					Client: &test.MockClient{
+						MockGet: test.NewMockGetFn(nil, func(o client.Object) error {
+							// Populate package list so we attempt
+							// reconciliation. This is overridden by the mock
+							// DAG.
+							l := o.(*v1beta1.Lock)
+							l.Packages = append(l.Packages, v1beta1.LockPackage{
+								Name:    "cool-package",
+								Type:    v1beta1.ProviderPackageType,
+								Source:  "cool-repo/cool-image",
+								Version: "v0.0.1",
+							})
+							return nil
+						}),
+						MockUpdate: test.NewMockUpdateFn(nil),
+						MockStatusUpdate: test.NewMockStatusUpdateFn(nil, func(o client.Object) error {
+							want := &v1beta1.Lock{}
+							want.Status.MissingDependencies = 1
+							want.SetConditions(v1beta1.InvalidConstraint().WithMessage(errors.Wrapf(errDigest, errDependencyFmt, "version constraint on dependency is invalid", "hasheddan/config-nop-c", "sha256:nothex").Error()))
+							unsatisfied(want)
+							if diff := cmp.Diff(want.Status, o.(*v1beta1.Lock).Status, test.EquateConditions(), ignoreResolution); diff != "" {
+								t.Errorf("-want, +got:\n%s", diff)
+							}
+							return nil
+						}),
+					},
+				},
+				req: reconcile.Request{NamespacedName: types.NamespacedName{Name: "test"}},
+				rec: []ReconcilerOption{
+					WithNewDagFn(func() dag.DAG {
+						return &fakedag.MockDag{
+							MockInit: func(nodes []dag.Node, fns ...dag.NodeFn) ([]dag.ImpliedNode, error) {
+								return []dag.ImpliedNode{
+									{Node: &v1beta1.Dependency{
+										Package:     "hasheddan/config-nop-c",
+										Constraints: "sha256:nothex",
+										Type:        v1beta1.ConfigurationPackageType,
+									}},
+								}, nil
+							},
+							MockNodeDependents: func(_ string) ([]dag.Node, error) { return nil, nil },
+
+							MockSort: func() ([]string, error) {
+								return nil, nil
+							},
+						}
+					}),
+					// Pinned dependencies should not require fetching tags.
+					WithFetcher(&fakexpkg.MockFetcher{
+						MockTags: fakexpkg.NewMockTagsFn(nil, errBoom),
+					}),
+				},
+			},
+			want: want{
+				r:      reconcile.Result{Requeue: false},
+				events: []event.Reason{reasonInvalidConstraint},
+			},
+		},
+		"ErrorUnsupportedPackageType": {
+			reason: "We should not requeue, or fetch tags, if a dependency is a type of package we cannot create.",
+			args: args{
+				mgr: &fake.Manager{
+					Client: &test.MockClient{
+						MockGet: test.NewMockGetFn(nil, func(o client.Object) error {
+							// Populate package list so we attempt
+							// reconciliation. This is overridden by the mock
+							// DAG.
+							l := o.(*v1beta1.Lock)
+							l.Packages = append(l.Packages, v1beta1.LockPackage{
+								Name:    "cool-package",
+								Type:    v1beta1.ProviderPackageType,
+								Source:  "cool-repo/cool-image",
+								Version: "v0.0.1",
+							})
+							return nil
+						}),
+						MockUpdate: test.NewMockUpdateFn(nil),
+						MockStatusUpdate: test.NewMockStatusUpdateFn(nil, func(o client.Object) error {
+							want := &v1beta1.Lock{}
+							want.Status.MissingDependencies = 1
+							want.SetConditions(v1beta1.UnsupportedDependencyType().WithMessage(errors.Wrapf(errors.New(`unsupported package type "Composition"; a dependency must be a Configuration, Provider, or Function`), errDependencyFmt, "cannot create invalid package dependency type", "hasheddan/composition-nop-c", ">v1.0.0").Error()))
+							unsatisfied(want)
+							if diff := cmp.Diff(want.Status, o.(*v1beta1.Lock).Status, test.EquateConditions(), ignoreResolution); diff != "" {
+								t.Errorf("-want, +got:\n%s", diff)
+							}
+							return nil
+						}),
+					},
+				},
+				req: reconcile.Request{NamespacedName: types.NamespacedName{Name: "test"}},
+				rec: []ReconcilerOption{
+					WithNewDagFn(func() dag.DAG {
+						return &fakedag.MockDag{
+							MockInit: func(nodes []dag.Node, fns ...dag.NodeFn) ([]dag.ImpliedNode, error) {
+								return []dag.ImpliedNode{
+									{Node: &v1beta1.Dependency{
+										Package:     "hasheddan/composition-nop-c",
+										Constraints: ">v1.0.0",
+										Type:        v1beta1.PackageType("Composition"),
+									}},
+								}, nil
+							},
+							MockNodeDependents: func(_ string) ([]dag.Node, error) { return nil, nil },
+
+							MockSort: func() ([]string, error) {
+								return nil, nil
+							},
+						}
+					}),
+					WithFetcher(&fakexpkg.MockFetcher{
+						MockTags: fakexpkg.NewMockTagsFn(nil, errBoom),
+					}),
+				},
+			},
+			want: want{
+				r:      reconcile.Result{Requeue: false},
+				events: []event.Reason{reasonUnsupportedType},
+			},
+		},
+		"StaleCacheCreateMissingDependency": {
+			reason: "We should neither requeue nor record an event if a dependency we created in a previous pass is not yet in the Lock or our cache.",
+			args: args{
+				mgr: &fake.Manager{
+					Client: &test.MockClient{
+						MockGet: test.NewMockGetFn(nil, func(o client.Object) error {
+							l, ok := o.(*v1beta1.Lock)
+							if !ok {
+								t.Errorf("Get(...): read a %T from the cache rather than the API server", o)
+								return nil
+							}
+							l.Packages = append(l.Packages, v1beta1.LockPackage{
+								Name:    "cool-package",
+								Type:    v1beta1.ProviderPackageType,
+								Source:  "cool-repo/cool-image",
+								Version: "v0.0.1",
+							})
+							return nil
+						}),
+						MockList:         test.NewMockListFn(nil),
+						MockCreate:       test.NewMockCreateFn(kerrors.NewAlreadyExists(schema.GroupResource{}, "hasheddan-config-nop-c")),
+						MockUpdate:       test.NewMockUpdateFn(nil),
+						MockStatusUpdate: test.NewMockStatusUpdateFn(nil),
+					},
+				},
+				req: reconcile.Request{NamespacedName: types.NamespacedName{Name: "test"}},
+				rec: []ReconcilerOption{
+					WithNewDagFn(func() dag.DAG {
+						return &fakedag.MockDag{
+							MockInit: func(nodes []dag.Node, fns ...dag.NodeFn) ([]dag.ImpliedNode, error) {
+								return []dag.ImpliedNode{
+									{Node: &v1beta1.Dependency{
+										Package:     "hasheddan/config-nop-c",
+										Constraints: ">v1.0.0",
+										Type:        v1beta1.ConfigurationPackageType,
+									}},
+								}, nil
+							},
+							MockNodeDependents: func(_ string) ([]dag.Node, error) { return nil, nil },
+
+							MockSort: func() ([]string, error) {
+								return nil, nil
+							},
+						}
+					}),
+					WithFetcher(&fakexpkg.MockFetcher{
+						MockTags: fakexpkg.NewMockTagsFn([]string{"v0.2.0", "v1.2.0"}, nil),
+					}),
+					WithAPIReader(&test.MockClient{
+						MockGet: test.NewMockGetFn(nil, func(o client.Object) error {
+							o.(v1.Package).SetSource("hasheddan/config-nop-c:v1.2.0")
+							return nil
+						}),
+					}),
+				},
+			},
+			want: want{
+				r: reconcile.Result{Requeue: false},
+			},
+		},
+		"SuccessfulCreateMultipleMissingDependencies": {
+			reason: "We should create every missing dependency exactly once in a single pass.",
+			args: args{
+				mgr: &fake.Manager{
+					Client: &test.MockClient{
+						MockGet: test.NewMockGetFn(nil, func(o client.Object) error {
+							// Populate package list so we attempt
+							// reconciliation. This is overridden by the mock
+							// DAG.
+							l := o.(*v1beta1.Lock)
+							l.Packages = append(l.Packages, v1beta1.LockPackage{
+								Name:    "cool-package",
+								Type:    v1beta1.ProviderPackageType,
+								Source:  "cool-repo/cool-image",
+								Version: "v0.0.1",
+							})
+							return nil
+						}),
+						MockList: test.NewMockListFn(nil),
+						MockCreate: func() test.MockCreateFn {
+							created := map[string]bool{}
+							return func(_ context.Context, o client.Object, _ ...client.CreateOption) error {
+								if created[o.GetName()] {
+									t.Errorf("dependency %s created more than once", o.GetName())
+								}
+								created[o.GetName()] = true
+								return nil
+							}
+						}(),
+						MockUpdate: test.NewMockUpdateFn(nil),
+						MockStatusUpdate: test.NewMockStatusUpdateFn(nil, func(o client.Object) error {
+							want := &v1beta1.Lock{}
+							want.Status.MissingDependencies = 2
+							want.SetConditions(v1beta1.MissingDependency().WithMessage(fmt.Sprintf(msgMissingDependenciesFmt, 2)))
+							unsatisfied(want)
+							if diff := cmp.Diff(want.Status, o.(*v1beta1.Lock).Status, test.EquateConditions(), ignoreResolution); diff != "" {
+								t.Errorf("-want, +got:\n%s", diff)
+							}
+							return nil
+						}),
+					},
+				},
+				req: reconcile.Request{NamespacedName: types.NamespacedName{Name: "test"}},
+				rec: []ReconcilerOption{
+					WithNewDagFn(func() dag.DAG {
+						return &fakedag.MockDag{
+							MockInit: func(nodes []dag.Node, fns ...dag.NodeFn) ([]dag.ImpliedNode, error) {
+								return []dag.ImpliedNode{
+									{Node: &v1beta1.Dependency{
+										Package:     "hasheddan/config-nop-c",
+										Constraints: ">v1.0.0",
+										Type:        v1beta1.ConfigurationPackageType,
+									}},
+									{Node: &v1beta1.Dependency{
+										Package:     "hasheddan/provider-nop-d",
+										Constraints: ">v1.0.0",
+										Type:        v1beta1.ProviderPackageType,
+									}},
+									// Implied by a second parent.
+									{Node: &v1beta1.Dependency{
+										Package:     "hasheddan/config-nop-c",
+										Constraints: ">v1.0.0",
+										Type:        v1beta1.ConfigurationPackageType,
+									}},
+								}, nil
+							},
+							MockNodeDependents: func(_ string) ([]dag.Node, error) { return nil, nil },
+
+							MockSort: func() ([]string, error) {
+								return nil, nil
+							},
+						}
+					}),
+					WithFetcher(&fakexpkg.MockFetcher{
+						MockTags: fakexpkg.NewMockTagsFn([]string{"v0.2.0", "v0.3.0", "v1.0.0", "v1.2.0"}, nil),
+					}),
+				},
+			},
+			want: want{
+				r:      reconcile.Result{Requeue: false},
+				events: []event.Reason{reasonInstallDependency, reasonInstallDependency},
+			},
+		},
+		"ErrorCreateSomeMissingDependencies": {
+			reason: "We should create the dependencies we can and requeue after short wait if any creation fails.",
+			args: args{
+				mgr: &fake.Manager{
+					Client: &test.MockClient{
+						MockGet: test.NewMockGetFn(nil, func(o client.Object) error {
+							// Populate package list so we attempt
+							// reconciliation. This is overridden by the mock
+							// DAG.
+							l := o.(*v1beta1.Lock)
+							l.Packages = append(l.Packages, v1beta1.LockPackage{
+								Name:    "cool-package",
+								Type:    v1beta1.ProviderPackageType,
+								Source:  "cool-repo/cool-image",
+								Version: "v0.0.1",
+							})
+							return nil
+						}),
+						MockList: test.NewMockListFn(nil),
+						MockCreate: func(_ context.Context, o client.Object, _ ...client.CreateOption) error {
+							if o.GetName() == "hasheddan-config-nop-c" {
+								return errBoom
+							}
+							return nil
+						},
+						MockUpdate: test.NewMockUpdateFn(nil),
+						MockStatusUpdate: test.NewMockStatusUpdateFn(nil, func(o client.Object) error {
+							want := &v1beta1.Lock{}
+							want.Status.MissingDependencies = 2
+							want.SetConditions(v1beta1.MissingDependency().WithMessage(errors.Wrapf(errBoom, errDependencyFmt, errCreateDependency, "hasheddan/config-nop-c", ">v1.0.0").Error()))
+							unsatisfied(want)
+							if diff := cmp.Diff(want.Status, o.(*v1beta1.Lock).Status, test.EquateConditions(), ignoreResolution); diff != "" {
+								t.Errorf("-want, +got:\n%s", diff)
+							}
+							return nil
+						}),
+					},
+				},
+				req: reconcile.Request{NamespacedName: types.NamespacedName{Name: "test"}},
+				rec: []ReconcilerOption{
+					WithNewDagFn(func() dag.DAG {
+						return &fakedag.MockDag{
+							MockInit: func(nodes []dag.Node, fns ...dag.NodeFn) ([]dag.ImpliedNode, error) {
+								return []dag.ImpliedNode{
+									{Node: &v1beta1.Dependency{
+										Package:     "hasheddan/config-nop-c",
+										Constraints: ">v1.0.0",
+										Type:        v1beta1.ConfigurationPackageType,
+									}},
+									{Node: &v1beta1.Dependency{
+										Package:     "hasheddan/provider-nop-d",
+										Constraints: ">v1.0.0",
+										Type:        v1beta1.ProviderPackageType,
+									}},
+								}, nil
+							},
+							MockNodeDependents: func(_ string) ([]dag.Node, error) { return nil, nil },
+
+							MockSort: func() ([]string, error) {
+								return nil, nil
+							},
+						}
+					}),
+					WithFetcher(&fakexpkg.MockFetcher{
+						MockTags: fakexpkg.NewMockTagsFn([]string{"v0.2.0", "v0.3.0", "v1.0.0", "v1.2.0"}, nil),
+					}),
+				},
+			},
+			want: want{
+				r: reconcile.Result{RequeueAfter: shortWait},
+				// The provider is created before the configuration.
+				events: []event.Reason{reasonInstallDependency, reasonCreateDependency},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			rec := &recorder{}
+			r := NewReconciler(tc.args.mgr, append(tc.args.rec, WithRecorder(rec))...)
+			got, err := r.Reconcile(context.Background(), reconcile.Request{})
+
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.r, got, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want, +got:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.events, rec.reasons); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want events, +got events:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestReconcileCreateOrder(t *testing.T) {
+	dep := func(pkg string) v1beta1.Dependency {
+		return v1beta1.Dependency{Package: pkg, Type: v1beta1.ProviderPackageType, Constraints: ">=v0.20.0"}
+	}
+	a := v1beta1.LockPackage{Source: "cool/config-a", Dependencies: []v1beta1.Dependency{dep("crossplane/provider-gcp"), dep("crossplane/provider-aws")}}
+	b := v1beta1.LockPackage{Source: "cool/config-b", Dependencies: []v1beta1.Dependency{dep("crossplane/provider-azure"), dep("crossplane/provider-aws")}}
+
+	cases := map[string]struct {
+		reason string
+		lock   []v1beta1.LockPackage
+		want   []string
+	}{
+		"DeclaredOrder": {
+			reason: "We should install missing dependencies in order of their identifiers.",
+			lock:   []v1beta1.LockPackage{a, b},
+			want:   []string{"crossplane-provider-aws", "crossplane-provider-azure", "crossplane-provider-gcp"},
+		},
+		"ReversedOrder": {
+			reason: "We should install missing dependencies in the same order regardless of the order of the packages in the Lock.",
+			lock:   []v1beta1.LockPackage{b, a},
+			want:   []string{"crossplane-provider-aws", "crossplane-provider-azure", "crossplane-provider-gcp"},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var created []string
+			mgr := &fake.Manager{
+				Client: &test.MockClient{
+					MockGet: func(_ context.Context, key client.ObjectKey, o client.Object) error {
+						if l, ok := o.(*v1beta1.Lock); ok {
+							l.Packages = tc.lock
+							return nil
+						}
+						return kerrors.NewNotFound(schema.GroupResource{}, key.Name)
+					},
+					MockList: test.NewMockListFn(nil),
+					MockCreate: func(_ context.Context, o client.Object, _ ...client.CreateOption) error {
+						created = append(created, o.GetName())
+						return nil
+					},
+					MockUpdate:       test.NewMockUpdateFn(nil),
+					MockStatusUpdate: test.NewMockStatusUpdateFn(nil),
+				},
+			}
+			r := NewReconciler(mgr,
+				WithRecorder(&recorder{}),
+				WithFetcher(&fakexpkg.MockFetcher{MockTags: fakexpkg.NewMockTagsFn([]string{"v0.20.0"}, nil)}),
+				WithNewDagFn(dag.NewMapDag),
+			)
+			if _, err := r.Reconcile(context.Background(), reconcile.Request{}); err != nil {
+				t.Fatalf("\n%s\nr.Reconcile(...): %s", tc.reason, err)
+			}
+			if diff := cmp.Diff(tc.want, created); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want created, +got created:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestReconcileFunctionDependency(t *testing.T) {
+	var created []client.Object
+	mgr := &fake.Manager{
+		Client: &test.MockClient{
+			MockGet: func(_ context.Context, key client.ObjectKey, o client.Object) error {
+				if l, ok := o.(*v1beta1.Lock); ok {
+					l.Packages = []v1beta1.LockPackage{{
+						Source:       "cool/config",
+						Dependencies: []v1beta1.Dependency{{Package: "crossplane/function-nop", Type: v1beta1.FunctionPackageType, Constraints: ">=v0.1.0"}},
+					}}
+					return nil
+				}
+				return kerrors.NewNotFound(schema.GroupResource{}, key.Name)
+			},
+			MockList: test.NewMockListFn(nil),
+			MockCreate: func(_ context.Context, o client.Object, _ ...client.CreateOption) error {
+				created = append(created, o)
+				return nil
+			},
+			MockUpdate:       test.NewMockUpdateFn(nil),
+			MockStatusUpdate: test.NewMockStatusUpdateFn(nil),
+		},
+	}
+	r := NewReconciler(mgr,
+		WithRecorder(&recorder{}),
+		WithFetcher(&fakexpkg.MockFetcher{MockTags: fakexpkg.NewMockTagsFn([]string{"v0.1.0", "v0.2.0"}, nil)}),
+		WithNewDagFn(dag.NewMapDag),
+	)
+
+	reason := "We should install a missing dependency on a Function package as a Function."
+	if _, err := r.Reconcile(context.Background(), reconcile.Request{}); err != nil {
+		t.Fatalf("\n%s\nr.Reconcile(...): %s", reason, err)
+	}
+	if len(created) != 1 {
+		t.Fatalf("\n%s\nr.Reconcile(...): created %d packages, want 1", reason, len(created))
+	}
+	fn, ok := created[0].(*v1alpha1.Function)
+	if !ok {
+		t.Fatalf("\n%s\nr.Reconcile(...): created %T, want *v1alpha1.Function", reason, created[0])
+	}
+	if diff := cmp.Diff("crossplane-function-nop", fn.GetName()); diff != "" {
+		t.Errorf("\n%s\nr.Reconcile(...): -want name, +got name:\n%s", reason, diff)
+	}
+	if diff := cmp.Diff("crossplane/function-nop:v0.2.0", fn.GetSource()); diff != "" {
+		t.Errorf("\n%s\nr.Reconcile(...): -want source, +got source:\n%s", reason, diff)
+	}
+}
+
+func TestReconcileImpliedNonDependency(t *testing.T) {
+	cases := map[string]struct {
+		reason string
+		node   dag.ImpliedNode
+		want   string
+	}{
+		"LockPackage": {
+			reason: "We should report an implied node that is a package rather than a dependency.",
+			node:   dag.ImpliedNode{Node: &v1beta1.LockPackage{Source: "cool/provider"}, ImpliedBy: "cool/config"},
+			want:   "dependency package is not valid: missing node (*v1beta1.LockPackage index.docker.io/cool/provider implied by cool/config) is not a dependency",
+		},
+		"NilNode": {
+			reason: "We should report an implied node that is nil.",
+			node:   dag.ImpliedNode{ImpliedBy: "cool/config"},
+			want:   "dependency package is not valid: missing node (<nil> implied by cool/config) is not a dependency",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			mgr := &fake.Manager{
+				Client: &test.MockClient{
+					MockGet: test.NewMockGetFn(nil, func(o client.Object) error {
+						o.(*v1beta1.Lock).Packages = []v1beta1.LockPackage{{Source: "cool/config"}}
+						return nil
+					}),
+					MockUpdate:       test.NewMockUpdateFn(nil),
+					MockList:         test.NewMockListFn(nil),
+					MockStatusUpdate: test.NewMockStatusUpdateFn(nil),
+				},
+			}
+			rec := &recorder{}
+			r := NewReconciler(mgr,
+				WithRecorder(rec),
+				WithNewDagFn(func() dag.DAG {
+					return &fakedag.MockDag{
+						MockInit:           func(_ []dag.Node, _ ...dag.NodeFn) ([]dag.ImpliedNode, error) { return []dag.ImpliedNode{tc.node}, nil },
+						MockNodeDependents: func(_ string) ([]dag.Node, error) { return nil, nil },
+						MockSort:           func() ([]string, error) { return nil, nil },
+					}
+				}),
+			)
+			got, err := r.Reconcile(context.Background(), reconcile.Request{})
+			if err != nil {
+				t.Errorf("\n%s\nr.Reconcile(...): %s", tc.reason, err)
+			}
+			// An invalid dependency won't become valid until the Lock
+			// changes, so there's no point requeueing.
+			if diff := cmp.Diff(reconcile.Result{}, got); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want result, +got result:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff([]event.Reason{reasonInvalidDependency}, rec.reasons); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want events, +got events:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff([]string{tc.want}, rec.messages); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want event messages, +got event messages:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestInstallUnsupportedType(t *testing.T) {
+	created := false
+	mgr := &fake.Manager{
+		Client: &test.MockClient{
+			MockCreate: func(_ context.Context, _ client.Object, _ ...client.CreateOption) error {
+				created = true
+				return nil
+			},
+		},
+	}
+	rec := &recorder{}
+	r := NewReconciler(mgr, WithRecorder(rec))
+	lock := &v1beta1.Lock{}
+	p := xpkg.PlannedInstall{
+		Dependency: v1beta1.Dependency{Package: "hasheddan/composition-nop-c", Type: v1beta1.PackageType("Composition"), Constraints: ">v1.0.0"},
+		Name:       "hasheddan-composition-nop-c",
+		Source:     "hasheddan/composition-nop-c:v1.2.0",
+		Version:    "v1.2.0",
+	}
+	res := newResolutionResult(lock, []xpkg.PlannedInstall{p}, nil, time.Time{}, 0)
+
+	c, rq, err := r.install(context.Background(), logging.NewNopLogger(), lock, p, xpkg.VersionSelectionHighest, &installBudget{}, nil, res)
+
+	reason := "We should return a permanent, typed error rather than install a type of package we don't support."
+	var uerr *unsupportedTypeError
+	if !errors.As(err, &uerr) {
+		t.Errorf("\n%s\nr.install(...): want *unsupportedTypeError, got %v", reason, err)
+	}
+	if diff := cmp.Diff(v1beta1.DependencyRejected().Reason, c.Reason); diff != "" {
+		t.Errorf("\n%s\nr.install(...): -want reason, +got reason:\n%s", reason, diff)
+	}
+	if diff := cmp.Diff(RequeueReason(""), rq); diff != "" {
+		t.Errorf("\n%s\nr.install(...): -want requeue, +got requeue:\n%s", reason, diff)
+	}
+	if created {
+		t.Errorf("\n%s\nr.install(...): created a package", reason)
+	}
+	if diff := cmp.Diff([]event.Reason{reasonCreateDependency}, rec.reasons); diff != "" {
+		t.Errorf("\n%s\nr.install(...): -want events, +got events:\n%s", reason, diff)
 	}
 }
@@ -0,0 +1,118 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Masterminds/semver"
+	"github.com/google/go-containerregistry/pkg/name"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+
+	v1 "github.com/crossplane/crossplane/apis/pkg/v1"
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+	"github.com/crossplane/crossplane/internal/xpkg"
+)
+
+const (
+	errListUnregisteredPackages = "cannot list existing packages awaiting registration"
+	errUnregisteredVersionFmt   = "existing package %s is version %s, which does not satisfy %q required of dependency %s"
+
+	msgAwaitingRegistrationFmt = "waiting for existing package %s to register"
+)
+
+// unregistered returns an existing package of the same kind as the supplied
+// package that is installed from the supplied canonical repository, if any.
+// Such a package hasn't yet added itself to the Lock, e.g. because a user
+// installed it at the same time as a package that depends on it. Packages
+// the resolver installed are found by their repository label instead; see
+// created.
+func (r *Reconciler) unregistered(ctx context.Context, pack v1.Package, repo string) (v1.Package, error) {
+	pkgs := []v1.Package{}
+	switch pack.(type) {
+	case *v1.Configuration:
+		l := &v1.ConfigurationList{}
+		if err := r.client.List(ctx, l); err != nil {
+			return nil, errors.Wrap(err, errListUnregisteredPackages)
+		}
+		for i := range l.Items {
+			pkgs = append(pkgs, &l.Items[i])
+		}
+	case *v1.Provider:
+		l := &v1.ProviderList{}
+		if err := r.client.List(ctx, l); err != nil {
+			return nil, errors.Wrap(err, errListUnregisteredPackages)
+		}
+		for i := range l.Items {
+			pkgs = append(pkgs, &l.Items[i])
+		}
+	}
+	for _, p := range pkgs {
+		if _, ok := p.GetLabels()[LabelRepository]; ok {
+			continue
+		}
+		if r.sourceRepository(p.GetSource()) == repo {
+			return p, nil
+		}
+	}
+	return nil, nil
+}
+
+// awaitRegistration reports that the planned install of a missing dependency
+// is skipped because the supplied existing package will satisfy it once it
+// adds itself to the Lock. We warn if the existing package's version doesn't
+// satisfy the dependency's constraint, because the dependency will then be
+// reported as a version conflict once it registers.
+func (r *Reconciler) awaitRegistration(log logging.Logger, lock *v1beta1.Lock, p xpkg.PlannedInstall, existing v1.Package, res *resolutionResult) (xpv1.Condition, RequeueReason, error) {
+	dep := p.Dependency
+	msg := fmt.Sprintf(msgAwaitingRegistrationFmt, existing.GetName())
+	log.Debug("Dependency package exists but is not in the Lock", "dependency", dep.Package, "name", existing.GetName(), "source", existing.GetSource())
+	r.record.Event(lock, event.Normal(reasonAwaitRegistration, msg))
+	res.decide(dep.Package, outcomeExists, existing.GetName())
+
+	if v := unregisteredVersion(existing); v != nil {
+		if c, err := xpkg.NewConstraint(dep.Constraints); err == nil && !c.Check(v) {
+			err := errors.Errorf(errUnregisteredVersionFmt, existing.GetName(), v.Original(), dep.Constraints, dep.Package)
+			log.Debug("Existing dependency package version does not satisfy constraint", "error", err)
+			r.record.Event(lock, event.Warning(reasonAwaitRegistration, err))
+		}
+	}
+
+	return v1beta1.MissingDependency(), RequeueWaiting, nil
+}
+
+// unregisteredVersion returns the semantic version the supplied package is
+// pinned to, or nil if its source isn't tagged with a semantic version.
+func unregisteredVersion(pack v1.Package) *semver.Version {
+	ref, err := name.ParseReference(pack.GetSource(), name.WithDefaultRegistry(""))
+	if err != nil {
+		return nil
+	}
+	if _, ok := ref.(name.Tag); !ok {
+		return nil
+	}
+	v, err := semver.NewVersion(ref.Identifier())
+	if err != nil {
+		return nil
+	}
+	return v
+}
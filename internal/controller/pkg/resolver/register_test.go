@@ -0,0 +1,157 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/fake"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	v1 "github.com/crossplane/crossplane/apis/pkg/v1"
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+	"github.com/crossplane/crossplane/internal/dag"
+	fakexpkg "github.com/crossplane/crossplane/internal/xpkg/fake"
+)
+
+func TestReconcileAwaitRegistration(t *testing.T) {
+	type want struct {
+		created  []string
+		events   []event.Reason
+		messages []string
+	}
+
+	cases := map[string]struct {
+		reason string
+
+		// existing maps the name of each existing Provider to its source.
+		existing map[string]string
+		labels   map[string]string
+		want     want
+	}{
+		"NoExistingPackage": {
+			reason: "We should create a missing dependency if no package from its repository exists.",
+			existing: map[string]string{
+				"my-gcp": "crossplane/provider-gcp:v0.20.0",
+			},
+			want: want{
+				created:  []string{"crossplane/provider-aws:v0.20.0"},
+				events:   []event.Reason{reasonInstallDependency},
+				messages: []string{"Installed automatically as a dependency of cool/config to satisfy constraint >=v0.20.0, selected version v0.20.0 from 1 available tags"},
+			},
+		},
+		"SimultaneousInstall": {
+			reason: "We should not create a missing dependency if a package from its repository exists but hasn't yet added itself to the Lock.",
+			existing: map[string]string{
+				"my-aws": "index.docker.io/crossplane/provider-aws:v0.20.0",
+			},
+			want: want{
+				events:   []event.Reason{reasonAwaitRegistration},
+				messages: []string{"waiting for existing package my-aws to register"},
+			},
+		},
+		"IncompatibleExistingPackage": {
+			reason: "We should warn if a package that hasn't yet added itself to the Lock doesn't satisfy the missing dependency's constraint.",
+			existing: map[string]string{
+				"my-aws": "crossplane/provider-aws:v0.19.0",
+			},
+			want: want{
+				events: []event.Reason{reasonAwaitRegistration, reasonAwaitRegistration},
+				messages: []string{
+					"waiting for existing package my-aws to register",
+					`existing package my-aws is version v0.19.0, which does not satisfy ">=v0.20.0" required of dependency crossplane/provider-aws`,
+				},
+			},
+		},
+		"InstalledByResolver": {
+			reason: "We should find packages we installed by their repository label rather than wait for them to register.",
+			existing: map[string]string{
+				"crossplane-provider-aws": "crossplane/provider-aws:v0.20.0",
+			},
+			labels: map[string]string{LabelRepository: "index.docker.io-crossplane-provider-aws"},
+			want:   want{},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var created []string
+			mgr := &fake.Manager{
+				Client: &test.MockClient{
+					MockGet: func(_ context.Context, key client.ObjectKey, o client.Object) error {
+						if l, ok := o.(*v1beta1.Lock); ok {
+							l.Packages = []v1beta1.LockPackage{{
+								Source: "cool/config",
+								Dependencies: []v1beta1.Dependency{
+									{Package: "crossplane/provider-aws", Type: v1beta1.ProviderPackageType, Constraints: ">=v0.20.0"},
+								},
+							}}
+							return nil
+						}
+						return kerrors.NewNotFound(schema.GroupResource{}, key.Name)
+					},
+					MockList: test.NewMockListFn(nil, func(o client.ObjectList) error {
+						l, ok := o.(*v1.ProviderList)
+						if !ok {
+							return nil
+						}
+						for n, src := range tc.existing {
+							p := v1.Provider{ObjectMeta: metav1.ObjectMeta{Name: n, Labels: tc.labels}}
+							p.SetSource(src)
+							l.Items = append(l.Items, p)
+						}
+						return nil
+					}),
+					MockCreate: func(_ context.Context, o client.Object, _ ...client.CreateOption) error {
+						created = append(created, o.(v1.Package).GetSource())
+						return nil
+					},
+					MockUpdate:       test.NewMockUpdateFn(nil),
+					MockStatusUpdate: test.NewMockStatusUpdateFn(nil),
+				},
+			}
+			rec := &recorder{}
+			r := NewReconciler(mgr,
+				WithRecorder(rec),
+				WithFetcher(&fakexpkg.MockFetcher{MockTags: fakexpkg.NewMockTagsFn([]string{"v0.20.0"}, nil)}),
+				WithNewDagFn(dag.NewMapDag),
+			)
+
+			if _, err := r.Reconcile(context.Background(), reconcile.Request{}); err != nil {
+				t.Fatalf("\n%s\nr.Reconcile(...): %s", tc.reason, err)
+			}
+			if diff := cmp.Diff(tc.want.created, created); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want created, +got created:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.events, rec.reasons); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want events, +got events:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.messages, rec.messages); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want messages, +got messages:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
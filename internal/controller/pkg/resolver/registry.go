@@ -0,0 +1,88 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"github.com/google/go-containerregistry/pkg/name"
+
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+	"github.com/crossplane/crossplane/internal/version"
+	"github.com/crossplane/crossplane/internal/xpkg"
+)
+
+// WithDefaultRegistry specifies the registry the Reconciler should assume
+// for dependencies that don't specify one. Dependency packages are installed
+// using their fully qualified source.
+func WithDefaultRegistry(registry string) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.registry = registry
+	}
+}
+
+// WithFetcherOptions specifies how the Reconciler should connect to
+// registries when it fetches the tags of dependencies, e.g. which CAs to
+// trust, which registries to connect to using plain HTTP, and which proxy to
+// connect through. The options only apply if the Reconciler fetches tags using
+// an xpkg.K8sFetcher.
+func WithFetcherOptions(o ...xpkg.FetcherOption) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.fopts = append(r.fopts, o...)
+	}
+}
+
+// userAgent identifies the resolver to registries.
+func userAgent() string {
+	return "crossplane-resolver/" + version.New().GetVersionString()
+}
+
+// qualify returns the supplied package source qualified with the supplied
+// default registry, if it does not specify a registry. Sources that cannot be
+// parsed are returned unchanged.
+func qualify(registry, source string) string {
+	if registry == "" {
+		return source
+	}
+	ref, err := name.ParseReference(source, name.WithDefaultRegistry(registry))
+	if err != nil {
+		return source
+	}
+	return ref.Context().Name()
+}
+
+// normalize qualifies the sources and dependencies of the packages in the
+// supplied Lock with the default registry, then applies any registry rewrites
+// to the dependencies. Packages add themselves to the Lock using the source
+// they were installed from, so both must be normalized before we determine
+// which dependencies are missing. The Lock itself is never updated.
+func (r *Reconciler) normalize(log logging.Logger, lock *v1beta1.Lock) {
+	if r.registry == "" && len(r.rewrites) == 0 {
+		return
+	}
+	for i := range lock.Packages {
+		p := &lock.Packages[i]
+		p.Source = qualify(r.registry, p.Source)
+		for j := range p.Dependencies {
+			d := &p.Dependencies[j]
+			if pkg := rewrite(r.rewrites, qualify(r.registry, d.Package)); pkg != d.Package {
+				log.Debug("Normalized dependency package", "from", d.Package, "to", pkg)
+				d.Package = pkg
+			}
+		}
+	}
+}
@@ -0,0 +1,253 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	kfake "k8s.io/client-go/kubernetes/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/crossplane/crossplane-runtime/pkg/resource/fake"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	v1 "github.com/crossplane/crossplane/apis/pkg/v1"
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+	"github.com/crossplane/crossplane/internal/dag"
+	"github.com/crossplane/crossplane/internal/xpkg"
+)
+
+func TestQualify(t *testing.T) {
+	cases := map[string]struct {
+		reason   string
+		registry string
+		source   string
+		want     string
+	}{
+		"Bare": {
+			reason:   "We should qualify a source without a registry with the default registry.",
+			registry: "registry.upbound.io",
+			source:   "crossplane/provider-aws",
+			want:     "registry.upbound.io/crossplane/provider-aws",
+		},
+		"ExplicitRegistry": {
+			reason:   "We should not qualify a source with an explicit registry.",
+			registry: "registry.upbound.io",
+			source:   "xpkg.upbound.io/crossplane/provider-aws",
+			want:     "xpkg.upbound.io/crossplane/provider-aws",
+		},
+		"ExplicitRegistryWithPort": {
+			reason:   "We should not qualify a source with an explicit registry that includes a port.",
+			registry: "registry.upbound.io",
+			source:   "localhost:5000/crossplane/provider-aws",
+			want:     "localhost:5000/crossplane/provider-aws",
+		},
+		"NoDefaultRegistry": {
+			reason: "We should not qualify a source if there is no default registry.",
+			source: "crossplane/provider-aws",
+			want:   "crossplane/provider-aws",
+		},
+		"Invalid": {
+			reason:   "We should return a source we cannot parse unchanged.",
+			registry: "registry.upbound.io",
+			source:   "Crossplane/Provider-AWS",
+			want:     "Crossplane/Provider-AWS",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := qualify(tc.registry, tc.source)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nqualify(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestReconcileDefaultRegistry(t *testing.T) {
+	type want struct {
+		refs   []string
+		source string
+	}
+
+	cases := map[string]struct {
+		reason   string
+		packages []v1beta1.LockPackage
+		want     want
+	}{
+		"Bare": {
+			reason: "We should fetch tags from, and install a dependency without a registry from, the default registry.",
+			packages: []v1beta1.LockPackage{{
+				Source:       "cool/config",
+				Dependencies: []v1beta1.Dependency{{Package: "crossplane/provider-aws", Type: v1beta1.ProviderPackageType, Constraints: ">=v1.0.0"}},
+			}},
+			want: want{
+				refs:   []string{"registry.upbound.io/crossplane/provider-aws"},
+				source: "registry.upbound.io/crossplane/provider-aws:v1.0.0",
+			},
+		},
+		"ExplicitRegistry": {
+			reason: "We should not change the registry of a dependency that specifies one.",
+			packages: []v1beta1.LockPackage{{
+				Source:       "cool/config",
+				Dependencies: []v1beta1.Dependency{{Package: "localhost:5000/crossplane/provider-aws", Type: v1beta1.ProviderPackageType, Constraints: ">=v1.0.0"}},
+			}},
+			want: want{
+				refs:   []string{"localhost:5000/crossplane/provider-aws"},
+				source: "localhost:5000/crossplane/provider-aws:v1.0.0",
+			},
+		},
+		"AlreadyInstalled": {
+			reason: "A bare dependency should be satisfied by a package installed from the default registry.",
+			packages: []v1beta1.LockPackage{
+				{
+					Source:       "cool/config",
+					Dependencies: []v1beta1.Dependency{{Package: "crossplane/provider-aws", Type: v1beta1.ProviderPackageType, Constraints: ">=v1.0.0"}},
+				},
+				{
+					Source:  "registry.upbound.io/crossplane/provider-aws",
+					Version: "v1.0.0",
+				},
+			},
+			want: want{},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			source := ""
+			mgr := &fake.Manager{
+				Client: &test.MockClient{
+					MockGet: test.NewMockGetFn(nil, func(o client.Object) error {
+						o.(*v1beta1.Lock).Packages = tc.packages
+						return nil
+					}),
+					MockUpdate: test.NewMockUpdateFn(nil),
+					MockList:   test.NewMockListFn(nil),
+					MockCreate: func(_ context.Context, o client.Object, _ ...client.CreateOption) error {
+						source = o.(v1.Package).GetSource()
+						return nil
+					},
+					MockStatusUpdate: test.NewMockStatusUpdateFn(nil),
+				},
+			}
+			f := &refFetcher{}
+			r := NewReconciler(mgr,
+				WithDefaultRegistry("registry.upbound.io"),
+				WithFetcher(f),
+				WithNewDagFn(dag.NewMapDag),
+			)
+			_, _ = r.Reconcile(context.Background(), reconcile.Request{})
+
+			if diff := cmp.Diff(tc.want.refs, f.refs); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want fetched refs, +got fetched refs:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.source, source); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want source, +got source:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+// A recordingTransport records the requests it executes using its wrapped
+// transport.
+type recordingTransport struct {
+	wrapped  http.RoundTripper
+	requests []*http.Request
+}
+
+func (t *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.requests = append(t.requests, req)
+	return t.wrapped.RoundTrip(req)
+}
+
+func TestReconcileFetcherOptions(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v2/" {
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"name": "cool/provider", "tags": []string{"v0.20.0"}})
+	}))
+	defer srv.Close()
+	host := strings.TrimPrefix(srv.URL, "http://")
+
+	var created []string
+	mgr := &fake.Manager{
+		Client: &test.MockClient{
+			MockGet: func(_ context.Context, key client.ObjectKey, o client.Object) error {
+				if l, ok := o.(*v1beta1.Lock); ok {
+					l.Packages = []v1beta1.LockPackage{{
+						Source: "cool/config",
+						Dependencies: []v1beta1.Dependency{
+							{Package: host + "/cool/provider", Type: v1beta1.ProviderPackageType, Constraints: ">=v0.20.0"},
+						},
+					}}
+					return nil
+				}
+				return kerrors.NewNotFound(schema.GroupResource{}, key.Name)
+			},
+			MockList: test.NewMockListFn(nil),
+			MockCreate: func(_ context.Context, o client.Object, _ ...client.CreateOption) error {
+				created = append(created, o.(*v1.Provider).Spec.Package)
+				return nil
+			},
+			MockUpdate:       test.NewMockUpdateFn(nil),
+			MockStatusUpdate: test.NewMockStatusUpdateFn(nil),
+		},
+	}
+
+	ns := "crossplane-system"
+	clientset := kfake.NewSimpleClientset(&corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: "default"}})
+	rt := &recordingTransport{wrapped: http.DefaultTransport}
+	r := NewReconciler(mgr,
+		WithRecorder(&recorder{}),
+		WithFetcher(xpkg.NewK8sFetcher(clientset, ns)),
+		WithFetcherOptions(xpkg.WithInsecureRegistries(host), xpkg.WithUserAgent(userAgent())),
+		WithFetcherOptions(xpkg.WithRoundTripper(rt)),
+		WithNewDagFn(dag.NewMapDag),
+	)
+
+	if _, err := r.Reconcile(context.Background(), reconcile.Request{}); err != nil {
+		t.Fatalf("r.Reconcile(...): %s", err)
+	}
+	if diff := cmp.Diff([]string{host + "/cool/provider:v0.20.0"}, created); diff != "" {
+		t.Errorf("r.Reconcile(...): -want created, +got created:\n%s", diff)
+	}
+
+	// Tags are fetched using the supplied transport, identifying the
+	// resolver by its User-Agent.
+	if len(rt.requests) == 0 {
+		t.Fatal("r.Reconcile(...): want tags fetched using the supplied transport, got no requests")
+	}
+	for _, req := range rt.requests {
+		if diff := cmp.Diff(userAgent(), req.Header.Get("User-Agent")); diff != "" {
+			t.Errorf("r.Reconcile(...): %s: -want User-Agent, +got User-Agent:\n%s", req.URL, diff)
+		}
+	}
+}
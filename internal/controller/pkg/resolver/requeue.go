@@ -0,0 +1,219 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// A RequeueReason is the class of outcome that caused the Reconciler to
+// requeue the Lock.
+type RequeueReason string
+
+// Requeue reasons.
+const (
+	// RequeueFinalizerError indicates the Lock finalizer could not be added
+	// or removed.
+	RequeueFinalizerError RequeueReason = "FinalizerError"
+
+	// RequeueFetchError indicates the tags of a dependency could not be
+	// fetched, e.g. because its registry is unavailable.
+	RequeueFetchError RequeueReason = "FetchError"
+
+	// RequeueRateLimited indicates the tags of a dependency could not be
+	// fetched because its registry is rate limiting us.
+	RequeueRateLimited RequeueReason = "RateLimited"
+
+	// RequeueUnauthorized indicates the tags of a dependency could not be
+	// fetched because its registry refused our credentials, if any. Only
+	// a user can fix it, by adding pull secrets, but they may do so
+	// without changing the Lock.
+	RequeueUnauthorized RequeueReason = "Unauthorized"
+
+	// RequeueCreateError indicates a dependency package could not be
+	// created.
+	RequeueCreateError RequeueReason = "CreateError"
+
+	// RequeueCollectError indicates auto-installed packages could not be
+	// garbage collected.
+	RequeueCollectError RequeueReason = "CollectError"
+
+	// RequeueUpgradeError indicates an auto-installed package could not be
+	// upgraded.
+	RequeueUpgradeError RequeueReason = "UpgradeError"
+
+	// RequeueCycle indicates the packages in the Lock depend on each other
+	// cyclically. Only a user can break the cycle.
+	RequeueCycle RequeueReason = "Cycle"
+
+	// RequeueDuplicates indicates the Lock contains more than one package
+	// from the same repository, and they could not be repaired. Like a
+	// cycle, only a user can fix them.
+	RequeueDuplicates RequeueReason = "Duplicates"
+
+	// RequeueRepairError indicates duplicate packages could not be removed
+	// from the Lock.
+	RequeueRepairError RequeueReason = "RepairError"
+
+	// RequeueVerifyError indicates the packages in the Lock could not be
+	// verified against their package revisions.
+	RequeueVerifyError RequeueReason = "VerifyError"
+
+	// RequeueCompactError indicates unreachable packages could not be
+	// removed from the Lock.
+	RequeueCompactError RequeueReason = "CompactError"
+
+	// RequeueAliasError indicates the package aliases could not be read.
+	RequeueAliasError RequeueReason = "AliasError"
+
+	// RequeueThrottled indicates client-side rate limiting delayed our
+	// requests to the API server past the reconcile deadline. Requeueing
+	// sooner would only add to the requests being throttled.
+	RequeueThrottled RequeueReason = "Throttled"
+
+	// RequeueWaiting indicates all missing dependencies were created, and
+	// the Reconciler is waiting for them to add themselves to the Lock.
+	RequeueWaiting RequeueReason = "Waiting"
+)
+
+// A RequeueStrategy determines how long the Reconciler waits before
+// reconciling the Lock again.
+type RequeueStrategy interface {
+	// RequeueAfter returns how long to wait before reconciling the Lock
+	// again for the supplied reason. A zero duration means the Lock should
+	// not be requeued, and will instead be reconciled when it next changes.
+	RequeueAfter(r RequeueReason) time.Duration
+
+	// Reset any state accumulated by previous requeues. It is called when
+	// a reconcile completes without error.
+	Reset()
+}
+
+// A FixedRequeueStrategy waits for a fixed duration following any error.
+type FixedRequeueStrategy struct {
+	// Error is how long to wait following any error.
+	Error time.Duration
+
+	// Waiting is how long to wait while created dependencies add themselves
+	// to the Lock. Zero means don't requeue.
+	Waiting time.Duration
+
+	// Cycle is how long to wait while the packages in the Lock depend on
+	// each other cyclically. Zero means don't requeue.
+	Cycle time.Duration
+
+	// Throttled is how long to wait after client-side rate limiting delayed
+	// our requests to the API server past the reconcile deadline. Zero
+	// means wait as long as following any error.
+	Throttled time.Duration
+}
+
+// RequeueAfter returns how long to wait for the supplied reason.
+func (s FixedRequeueStrategy) RequeueAfter(r RequeueReason) time.Duration {
+	switch r {
+	case RequeueWaiting:
+		return s.Waiting
+	case RequeueCycle, RequeueDuplicates:
+		return s.Cycle
+	case RequeueThrottled:
+		if s.Throttled > 0 {
+			return s.Throttled
+		}
+	}
+	return s.Error
+}
+
+// Reset does nothing.
+func (s FixedRequeueStrategy) Reset() {}
+
+// A BackoffRequeueStrategy backs off exponentially, with jitter, following
+// consecutive transient errors. Each reason backs off independently. Waiting,
+// cycles, and throttling are handled by the embedded FixedRequeueStrategy.
+type BackoffRequeueStrategy struct {
+	FixedRequeueStrategy
+
+	// Base is how long to wait following the first error.
+	Base time.Duration
+
+	// Max is the longest wait, before jitter is applied.
+	Max time.Duration
+
+	// Jitter is the maximum factor of the wait that is randomly added to
+	// it, e.g. 0.1 adds up to 10%.
+	Jitter float64
+
+	mu       sync.Mutex
+	failures map[RequeueReason]int
+}
+
+// NewBackoffRequeueStrategy returns a BackoffRequeueStrategy that doubles
+// its wait following each consecutive error, starting at the supplied base
+// wait and never exceeding the supplied maximum.
+func NewBackoffRequeueStrategy(base, max, waiting time.Duration) *BackoffRequeueStrategy {
+	return &BackoffRequeueStrategy{
+		FixedRequeueStrategy: FixedRequeueStrategy{Error: shortWait, Waiting: waiting, Cycle: longWait, Throttled: longWait},
+		Base:                 base,
+		Max:                  max,
+		Jitter:               0.1,
+	}
+}
+
+// RequeueAfter returns how long to wait for the supplied reason.
+func (s *BackoffRequeueStrategy) RequeueAfter(r RequeueReason) time.Duration {
+	if r == RequeueWaiting || r == RequeueCycle || r == RequeueDuplicates || r == RequeueThrottled {
+		return s.FixedRequeueStrategy.RequeueAfter(r)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.failures == nil {
+		s.failures = map[RequeueReason]int{}
+	}
+
+	// We stop counting failures once we reach the maximum wait, so that the
+	// wait can't overflow.
+	d := time.Duration(float64(s.Base) * math.Pow(2, float64(s.failures[r])))
+	if d > s.Max || d <= 0 {
+		d = s.Max
+	} else {
+		s.failures[r]++
+	}
+	if s.Jitter <= 0 {
+		return d
+	}
+	return wait.Jitter(d, s.Jitter)
+}
+
+// Reset the number of consecutive failures.
+func (s *BackoffRequeueStrategy) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = nil
+}
+
+// WithRequeueStrategy specifies how long the Reconciler should wait before
+// reconciling the Lock again.
+func WithRequeueStrategy(s RequeueStrategy) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.requeue = s
+	}
+}
@@ -0,0 +1,188 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/resource"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/fake"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+	"github.com/crossplane/crossplane/internal/dag"
+	fakedag "github.com/crossplane/crossplane/internal/dag/fake"
+	fakexpkg "github.com/crossplane/crossplane/internal/xpkg/fake"
+)
+
+// A requeueStrategy returns a distinct wait for each reason.
+type requeueStrategy map[RequeueReason]time.Duration
+
+func (s requeueStrategy) RequeueAfter(r RequeueReason) time.Duration { return s[r] }
+func (s requeueStrategy) Reset()                                     {}
+
+func TestReconcileRequeue(t *testing.T) {
+	errBoom := errors.New("boom")
+
+	strategy := requeueStrategy{
+		RequeueFinalizerError: 1 * time.Second,
+		RequeueFetchError:     2 * time.Second,
+		RequeueCreateError:    3 * time.Second,
+		RequeueWaiting:        4 * time.Second,
+	}
+
+	// withMissing returns a DAG that always implies the supplied dependencies
+	// are missing.
+	withMissing := func(deps ...*v1beta1.Dependency) ReconcilerOption {
+		nodes := make([]dag.ImpliedNode, len(deps))
+		for i := range deps {
+			nodes[i] = dag.ImpliedNode{Node: deps[i]}
+		}
+		return WithNewDagFn(func() dag.DAG {
+			return &fakedag.MockDag{
+				MockInit:           func(_ []dag.Node, _ ...dag.NodeFn) ([]dag.ImpliedNode, error) { return nodes, nil },
+				MockNodeDependents: func(_ string) ([]dag.Node, error) { return nil, nil },
+
+				MockSort: func() ([]string, error) { return nil, nil },
+			}
+		})
+	}
+
+	cases := map[string]struct {
+		reason string
+		create error
+		opts   []ReconcilerOption
+		want   reconcile.Result
+	}{
+		"FinalizerError": {
+			reason: "We should requeue for the configured finalizer error wait if we can't add our finalizer.",
+			opts: []ReconcilerOption{
+				WithFinalizer(resource.FinalizerFns{AddFinalizerFn: func(_ context.Context, _ resource.Object) error {
+					return errBoom
+				}}),
+			},
+			want: reconcile.Result{RequeueAfter: 1 * time.Second},
+		},
+		"FetchError": {
+			reason: "We should requeue for the configured fetch error wait if we can't fetch tags.",
+			opts: []ReconcilerOption{
+				withMissing(&v1beta1.Dependency{Package: "hasheddan/config-nop-c", Constraints: ">v1.0.0", Type: v1beta1.ConfigurationPackageType}),
+				WithFetcher(&fakexpkg.MockFetcher{MockTags: fakexpkg.NewMockTagsFn(nil, errBoom)}),
+			},
+			want: reconcile.Result{RequeueAfter: 2 * time.Second},
+		},
+		"CreateError": {
+			reason: "We should requeue for the configured create error wait if we can't create a dependency.",
+			create: errBoom,
+			opts: []ReconcilerOption{
+				withMissing(&v1beta1.Dependency{Package: "hasheddan/config-nop-c", Constraints: ">v1.0.0", Type: v1beta1.ConfigurationPackageType}),
+				WithFetcher(&fakexpkg.MockFetcher{MockTags: fakexpkg.NewMockTagsFn([]string{"v1.2.0"}, nil)}),
+			},
+			want: reconcile.Result{RequeueAfter: 3 * time.Second},
+		},
+		"Waiting": {
+			reason: "We should requeue for the configured waiting wait if we created all missing dependencies.",
+			opts: []ReconcilerOption{
+				withMissing(&v1beta1.Dependency{Package: "hasheddan/config-nop-c", Constraints: ">v1.0.0", Type: v1beta1.ConfigurationPackageType}),
+				WithFetcher(&fakexpkg.MockFetcher{MockTags: fakexpkg.NewMockTagsFn([]string{"v1.2.0"}, nil)}),
+			},
+			want: reconcile.Result{RequeueAfter: 4 * time.Second},
+		},
+		"ShortestWait": {
+			reason: "We should requeue for the shortest of the waits we encounter in a single pass.",
+			opts: []ReconcilerOption{
+				withMissing(
+					&v1beta1.Dependency{Package: "hasheddan/config-nop-c", Constraints: ">v1.0.0", Type: v1beta1.ConfigurationPackageType},
+					&v1beta1.Dependency{Package: "hasheddan/config-nop-d", Constraints: "not a constraint", Type: v1beta1.ConfigurationPackageType},
+				),
+				WithFetcher(&fakexpkg.MockFetcher{MockTags: fakexpkg.NewMockTagsFn(nil, errBoom)}),
+			},
+			want: reconcile.Result{RequeueAfter: 2 * time.Second},
+		},
+		"NoRequeueForTerminalError": {
+			reason: "We should not requeue if the only errors we encounter won't be fixed by retrying.",
+			opts: []ReconcilerOption{
+				withMissing(&v1beta1.Dependency{Package: "hasheddan/config-nop-d", Constraints: "not a constraint", Type: v1beta1.ConfigurationPackageType}),
+			},
+			want: reconcile.Result{},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			mgr := &fake.Manager{
+				Client: &test.MockClient{
+					MockGet: test.NewMockGetFn(nil, func(o client.Object) error {
+						l := o.(*v1beta1.Lock)
+						l.Packages = append(l.Packages, v1beta1.LockPackage{Name: "cool-package", Source: "cool-repo/cool-image"})
+						return nil
+					}),
+					MockList:         test.NewMockListFn(nil),
+					MockCreate:       test.NewMockCreateFn(tc.create),
+					MockUpdate:       test.NewMockUpdateFn(nil),
+					MockStatusUpdate: test.NewMockStatusUpdateFn(nil),
+				},
+			}
+			r := NewReconciler(mgr, append(tc.opts, WithRequeueStrategy(strategy))...)
+			got, _ := r.Reconcile(context.Background(), reconcile.Request{})
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestBackoffRequeueStrategy(t *testing.T) {
+	s := NewBackoffRequeueStrategy(1*time.Second, 4*time.Second, 5*time.Second)
+	s.Jitter = 0
+
+	got := []time.Duration{}
+	for i := 0; i < 4; i++ {
+		got = append(got, s.RequeueAfter(RequeueFetchError))
+	}
+	got = append(got, s.RequeueAfter(RequeueCreateError), s.RequeueAfter(RequeueCreateError), s.RequeueAfter(RequeueWaiting), s.RequeueAfter(RequeueCycle))
+	s.Reset()
+	got = append(got, s.RequeueAfter(RequeueFetchError))
+
+	want := []time.Duration{
+		1 * time.Second,
+		2 * time.Second,
+		4 * time.Second,
+		4 * time.Second,
+		1 * time.Second,
+		2 * time.Second,
+		5 * time.Second,
+		longWait,
+		1 * time.Second,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("s.RequeueAfter(...): -want, +got:\n%s", diff)
+	}
+
+	s.Jitter = 0.5
+	s.Reset()
+	if d := s.RequeueAfter(RequeueFetchError); d < 1*time.Second || d > 1500*time.Millisecond {
+		t.Errorf("s.RequeueAfter(...): want jittered wait between 1s and 1.5s, got %s", d)
+	}
+}
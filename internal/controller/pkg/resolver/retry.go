@@ -0,0 +1,94 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+)
+
+// defaultUnresolvedRetryInterval is how often we retry resolving dependencies
+// that no version satisfies by default.
+const defaultUnresolvedRetryInterval = 30 * time.Minute
+
+// WithUnresolvedRetryInterval specifies how often the Reconciler should retry
+// resolving missing dependencies that no version satisfies, so that they're
+// installed once a satisfying version is published. Zero disables retries;
+// such dependencies are then only resolved again when the Lock changes.
+func WithUnresolvedRetryInterval(d time.Duration) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.retryUnresolved = d
+	}
+}
+
+// scheduleRetries records when each missing dependency in the supplied result
+// that no version satisfies will next be retried, and returns how long to
+// wait until the earliest retry. A dependency keeps the retry time recorded
+// in the Lock's status until it's due, unless its constraints or the highest
+// version available changed, in which case its retry interval starts again.
+func (r *Reconciler) scheduleRetries(lock *v1beta1.Lock, res *resolutionResult) time.Duration {
+	if r.retryUnresolved <= 0 {
+		return 0
+	}
+
+	prev := make(map[string]v1beta1.DependencyResolution, len(lock.Status.DependencyResolution))
+	for _, e := range lock.Status.DependencyResolution {
+		prev[e.Package] = e
+	}
+
+	now := r.now()
+	var wait time.Duration
+	for i, c := range res.failures {
+		if c.Reason != v1beta1.ReasonNoValidVersion {
+			continue
+		}
+		next := now.Add(r.retryUnresolved)
+		if e, ok := prev[res.decisions[i].Dependency]; ok && unchanged(e, dependents(res.plan[i].Requirements), c) && e.NextRetryTime.After(now) {
+			next = e.NextRetryTime.Time
+		}
+		t := metav1.NewTime(next)
+		res.retries[i] = &t
+		if d := next.Sub(now); wait == 0 || d < wait {
+			wait = d
+		}
+	}
+	return wait
+}
+
+// unchanged returns true if the supplied dependency resolution status was
+// scheduled to be retried because no version satisfied the supplied
+// constraints, for the reason and with the message of the supplied
+// condition. The message names the highest version available.
+func unchanged(e v1beta1.DependencyResolution, constraints []v1beta1.DependentConstraint, c xpv1.Condition) bool {
+	if e.NextRetryTime == nil || e.Reason != c.Reason || e.Message != c.Message {
+		return false
+	}
+	if len(e.Constraints) != len(constraints) {
+		return false
+	}
+	for i := range constraints {
+		if e.Constraints[i] != constraints[i] {
+			return false
+		}
+	}
+	return true
+}
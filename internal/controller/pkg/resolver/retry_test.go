@@ -0,0 +1,224 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/crossplane/crossplane-runtime/pkg/resource/fake"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	v1 "github.com/crossplane/crossplane/apis/pkg/v1"
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+	"github.com/crossplane/crossplane/internal/dag"
+	fakexpkg "github.com/crossplane/crossplane/internal/xpkg/fake"
+)
+
+func TestReconcileRetryUnresolved(t *testing.T) {
+	start := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) *metav1.Time {
+		t := metav1.NewTime(start.Add(d))
+		return &t
+	}
+
+	stored := &v1beta1.Lock{}
+	stored.Packages = []v1beta1.LockPackage{{
+		Source: "cool/config",
+		Dependencies: []v1beta1.Dependency{
+			{Package: "crossplane/provider-aws", Type: v1beta1.ProviderPackageType, Constraints: ">=v0.20.0"},
+		},
+	}}
+
+	// The tags the registry lists, and the providers that were created.
+	tags := []string{"v0.18.0"}
+	var created []string
+
+	mgr := &fake.Manager{
+		Client: &test.MockClient{
+			MockGet: func(_ context.Context, key client.ObjectKey, o client.Object) error {
+				if l, ok := o.(*v1beta1.Lock); ok {
+					stored.DeepCopyInto(l)
+					return nil
+				}
+				return kerrors.NewNotFound(schema.GroupResource{}, key.Name)
+			},
+			MockList: test.NewMockListFn(nil),
+			MockCreate: func(_ context.Context, o client.Object, _ ...client.CreateOption) error {
+				created = append(created, o.(*v1.Provider).Spec.Package)
+				return nil
+			},
+			MockUpdate: test.NewMockUpdateFn(nil),
+			MockStatusUpdate: func(_ context.Context, o client.Object, _ ...client.UpdateOption) error {
+				o.(*v1beta1.Lock).Status.DeepCopyInto(&stored.Status)
+				return nil
+			},
+		},
+	}
+
+	type want struct {
+		r       reconcile.Result
+		next    *metav1.Time
+		created []string
+	}
+
+	// Each step reconciles the Lock after the supplied time has passed since
+	// the first step. Steps run in order, and depend on the Lock status that
+	// was recorded by the steps before them.
+	steps := []struct {
+		name   string
+		reason string
+		after  time.Duration
+		change func()
+		want   want
+	}{
+		{
+			name:   "NoValidVersion",
+			reason: "We should retry a dependency that no version satisfies once the retry interval has passed.",
+			want:   want{r: reconcile.Result{RequeueAfter: 30 * time.Minute}, next: at(30 * time.Minute)},
+		},
+		{
+			name:   "Unchanged",
+			reason: "We should keep the recorded retry time if we're reconciled for some other reason before it's due.",
+			after:  10 * time.Minute,
+			want:   want{r: reconcile.Result{RequeueAfter: 20 * time.Minute}, next: at(30 * time.Minute)},
+		},
+		{
+			name:   "NewTagStillUnsatisfied",
+			reason: "We should restart the retry interval if the registry lists new versions, even if none satisfies the constraints.",
+			after:  15 * time.Minute,
+			change: func() { tags = append(tags, "v0.19.0") },
+			want:   want{r: reconcile.Result{RequeueAfter: 30 * time.Minute}, next: at(45 * time.Minute)},
+		},
+		{
+			name:   "ConstraintsChanged",
+			reason: "We should restart the retry interval if the constraints on the dependency change.",
+			after:  20 * time.Minute,
+			change: func() { stored.Packages[0].Dependencies[0].Constraints = ">=v0.21.0" },
+			want:   want{r: reconcile.Result{RequeueAfter: 30 * time.Minute}, next: at(50 * time.Minute)},
+		},
+		{
+			name:   "Due",
+			reason: "We should schedule another retry if no version satisfies the constraints when a retry is due.",
+			after:  50 * time.Minute,
+			want:   want{r: reconcile.Result{RequeueAfter: 30 * time.Minute}, next: at(80 * time.Minute)},
+		},
+		{
+			name:   "Resolved",
+			reason: "We should install the dependency and stop retrying once the registry lists a satisfying version.",
+			after:  80 * time.Minute,
+			change: func() { tags = append(tags, "v0.21.0") },
+			want:   want{created: []string{"crossplane/provider-aws:v0.21.0"}},
+		},
+	}
+
+	for _, s := range steps {
+		if s.change != nil {
+			s.change()
+		}
+		created = nil
+		r := NewReconciler(mgr,
+			WithRecorder(&recorder{}),
+			WithFetcher(&fakexpkg.MockFetcher{MockTags: func() ([]string, error) { return tags, nil }}),
+			WithNewDagFn(dag.NewMapDag),
+		)
+		now := start.Add(s.after)
+		r.now = func() time.Time { return now }
+
+		got, err := r.Reconcile(context.Background(), reconcile.Request{})
+		if err != nil {
+			t.Fatalf("\n%s: %s\nr.Reconcile(...): %s", s.name, s.reason, err)
+		}
+		if diff := cmp.Diff(s.want.r, got); diff != "" {
+			t.Errorf("\n%s: %s\nr.Reconcile(...): -want, +got:\n%s", s.name, s.reason, diff)
+		}
+		var next *metav1.Time
+		for _, e := range stored.Status.DependencyResolution {
+			next = e.NextRetryTime
+		}
+		if diff := cmp.Diff(s.want.next, next); diff != "" {
+			t.Errorf("\n%s: %s\nr.Reconcile(...): -want next retry time, +got next retry time:\n%s", s.name, s.reason, diff)
+		}
+		if diff := cmp.Diff(s.want.created, created); diff != "" {
+			t.Errorf("\n%s: %s\nr.Reconcile(...): -want created, +got created:\n%s", s.name, s.reason, diff)
+		}
+	}
+}
+
+func TestReconcileRetryUnresolvedInterval(t *testing.T) {
+	cases := map[string]struct {
+		reason string
+		o      ReconcilerOption
+		want   reconcile.Result
+	}{
+		"CustomInterval": {
+			reason: "We should retry a dependency that no version satisfies at the configured interval.",
+			o:      WithUnresolvedRetryInterval(time.Hour),
+			want:   reconcile.Result{RequeueAfter: time.Hour},
+		},
+		"Disabled": {
+			reason: "We should not retry a dependency that no version satisfies if retries are disabled.",
+			o:      WithUnresolvedRetryInterval(0),
+			want:   reconcile.Result{},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			mgr := &fake.Manager{
+				Client: &test.MockClient{
+					MockGet: func(_ context.Context, key client.ObjectKey, o client.Object) error {
+						if l, ok := o.(*v1beta1.Lock); ok {
+							l.Packages = []v1beta1.LockPackage{{
+								Source: "cool/config",
+								Dependencies: []v1beta1.Dependency{
+									{Package: "crossplane/provider-aws", Type: v1beta1.ProviderPackageType, Constraints: ">=v0.20.0"},
+								},
+							}}
+							return nil
+						}
+						return kerrors.NewNotFound(schema.GroupResource{}, key.Name)
+					},
+					MockList:         test.NewMockListFn(nil),
+					MockUpdate:       test.NewMockUpdateFn(nil),
+					MockStatusUpdate: test.NewMockStatusUpdateFn(nil),
+				},
+			}
+			r := NewReconciler(mgr,
+				WithRecorder(&recorder{}),
+				WithFetcher(&fakexpkg.MockFetcher{MockTags: fakexpkg.NewMockTagsFn([]string{"v0.18.0"}, nil)}),
+				WithNewDagFn(dag.NewMapDag),
+				tc.o,
+			)
+			got, err := r.Reconcile(context.Background(), reconcile.Request{})
+			if err != nil {
+				t.Fatalf("\n%s\nr.Reconcile(...): %s", tc.reason, err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"strings"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+)
+
+const (
+	registryRewriteSep = "=>"
+
+	errInvalidRegistryRewriteFmt = "invalid registry rewrite %q: must be of the form <registry>[/<organization>]=><registry>[/<path>]"
+)
+
+// A RegistryRewrite rewrites the registry, and optionally the organization, of
+// the dependency packages the Reconciler installs. This allows dependencies
+// to be installed from a mirror in environments that cannot reach the
+// registry a package declares its dependencies in.
+type RegistryRewrite struct {
+	// From is the registry, e.g. xpkg.upbound.io, optionally followed by an
+	// organization, e.g. xpkg.upbound.io/crossplane, to rewrite.
+	From string
+
+	// To replaces From, e.g. registry.internal.corp/mirror.
+	To string
+}
+
+// ParseRegistryRewrites parses registry rewrite rules of the form
+// <from>=><to>, e.g. xpkg.upbound.io=>registry.internal.corp/mirror.
+func ParseRegistryRewrites(rules []string) ([]RegistryRewrite, error) {
+	out := make([]RegistryRewrite, 0, len(rules))
+	for _, rule := range rules {
+		parts := strings.Split(rule, registryRewriteSep)
+		if len(parts) != 2 {
+			return nil, errors.Errorf(errInvalidRegistryRewriteFmt, rule)
+		}
+		rw := RegistryRewrite{
+			From: strings.TrimSuffix(strings.TrimSpace(parts[0]), "/"),
+			To:   strings.TrimSuffix(strings.TrimSpace(parts[1]), "/"),
+		}
+		if rw.From == "" || rw.To == "" {
+			return nil, errors.Errorf(errInvalidRegistryRewriteFmt, rule)
+		}
+		out = append(out, rw)
+	}
+	return out, nil
+}
+
+// WithRegistryRewrites specifies how the Reconciler should rewrite the
+// registries of the dependency packages it installs.
+func WithRegistryRewrites(rw ...RegistryRewrite) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.rewrites = rw
+	}
+}
+
+// rewrite returns the supplied package with the most specific of the supplied
+// rewrites that matches it applied. A rewrite matches a package if its From
+// is a whole path prefix of the package, e.g. xpkg.upbound.io/crossplane
+// matches xpkg.upbound.io/crossplane/provider-aws, but not
+// xpkg.upbound.io/crossplane-contrib/provider-helm.
+func rewrite(rewrites []RegistryRewrite, pkg string) string {
+	best := -1
+	for i, rw := range rewrites {
+		if pkg != rw.From && !strings.HasPrefix(pkg, rw.From+"/") {
+			continue
+		}
+		if best < 0 || len(rw.From) > len(rewrites[best].From) {
+			best = i
+		}
+	}
+	if best < 0 {
+		return pkg
+	}
+	return rewrites[best].To + strings.TrimPrefix(pkg, rewrites[best].From)
+}
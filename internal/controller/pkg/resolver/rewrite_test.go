@@ -0,0 +1,215 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-containerregistry/pkg/name"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/fake"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	v1 "github.com/crossplane/crossplane/apis/pkg/v1"
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+	"github.com/crossplane/crossplane/internal/dag"
+	fakexpkg "github.com/crossplane/crossplane/internal/xpkg/fake"
+)
+
+// A refFetcher records the references it fetches tags for.
+type refFetcher struct {
+	*fakexpkg.MockFetcher
+	refs []string
+}
+
+func (f *refFetcher) Tags(_ context.Context, ref name.Reference, _ ...string) ([]string, error) {
+	f.refs = append(f.refs, ref.String())
+	return []string{"v1.0.0"}, nil
+}
+
+func TestParseRegistryRewrites(t *testing.T) {
+	type want struct {
+		rw  []RegistryRewrite
+		err error
+	}
+
+	cases := map[string]struct {
+		reason string
+		rules  []string
+		want   want
+	}{
+		"Valid": {
+			reason: "We should parse registry and organization rewrites, ignoring whitespace and trailing slashes.",
+			rules:  []string{"xpkg.upbound.io => registry.internal.corp/mirror/", "xpkg.upbound.io/crossplane=>registry.internal.corp/crossplane"},
+			want: want{rw: []RegistryRewrite{
+				{From: "xpkg.upbound.io", To: "registry.internal.corp/mirror"},
+				{From: "xpkg.upbound.io/crossplane", To: "registry.internal.corp/crossplane"},
+			}},
+		},
+		"MissingSeparator": {
+			reason: "We should return an error if a rule has no separator.",
+			rules:  []string{"xpkg.upbound.io"},
+			want:   want{err: errors.Errorf(errInvalidRegistryRewriteFmt, "xpkg.upbound.io")},
+		},
+		"MissingTo": {
+			reason: "We should return an error if a rule has nothing to rewrite to.",
+			rules:  []string{"xpkg.upbound.io=>"},
+			want:   want{err: errors.Errorf(errInvalidRegistryRewriteFmt, "xpkg.upbound.io=>")},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseRegistryRewrites(tc.rules)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nParseRegistryRewrites(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.rw, got); diff != "" {
+				t.Errorf("\n%s\nParseRegistryRewrites(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestRewrite(t *testing.T) {
+	rewrites := []RegistryRewrite{
+		{From: "xpkg.upbound.io/crossplane", To: "registry.internal.corp/crossplane"},
+		{From: "xpkg.upbound.io", To: "registry.internal.corp/mirror"},
+	}
+
+	cases := map[string]struct {
+		reason string
+		pkg    string
+		want   string
+	}{
+		"Registry": {
+			reason: "We should rewrite the registry of a package.",
+			pkg:    "xpkg.upbound.io/upbound/provider-aws",
+			want:   "registry.internal.corp/mirror/upbound/provider-aws",
+		},
+		"Organization": {
+			reason: "The most specific matching rule should win.",
+			pkg:    "xpkg.upbound.io/crossplane/provider-aws",
+			want:   "registry.internal.corp/crossplane/provider-aws",
+		},
+		"PartialOrganization": {
+			reason: "A rule should only match whole path segments.",
+			pkg:    "xpkg.upbound.io/crossplane-contrib/provider-helm",
+			want:   "registry.internal.corp/mirror/crossplane-contrib/provider-helm",
+		},
+		"PartialRegistry": {
+			reason: "A rule should not match a registry it is a prefix of.",
+			pkg:    "xpkg.upbound.io.example.org/crossplane/provider-aws",
+			want:   "xpkg.upbound.io.example.org/crossplane/provider-aws",
+		},
+		"NoMatch": {
+			reason: "We should not rewrite a package that matches no rule.",
+			pkg:    "registry.upbound.io/crossplane/provider-aws",
+			want:   "registry.upbound.io/crossplane/provider-aws",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := rewrite(rewrites, tc.pkg)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nrewrite(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestReconcileRewrite(t *testing.T) {
+	type want struct {
+		refs   []string
+		source string
+	}
+
+	cases := map[string]struct {
+		reason string
+		dep    v1beta1.Dependency
+		want   want
+	}{
+		"Rewritten": {
+			reason: "We should fetch tags from, and install the dependency from, the rewritten registry.",
+			dep:    v1beta1.Dependency{Package: "xpkg.upbound.io/crossplane/provider-aws", Type: v1beta1.ProviderPackageType, Constraints: ">=v1.0.0"},
+			want: want{
+				refs:   []string{"registry.internal.corp/mirror/crossplane/provider-aws"},
+				source: "registry.internal.corp/mirror/crossplane/provider-aws:v1.0.0",
+			},
+		},
+		"RewrittenPinned": {
+			reason: "We should install a pinned dependency from the rewritten registry without fetching tags.",
+			dep:    v1beta1.Dependency{Package: "xpkg.upbound.io/crossplane/provider-aws", Type: v1beta1.ProviderPackageType, Constraints: "main"},
+			want: want{
+				source: "registry.internal.corp/mirror/crossplane/provider-aws:main",
+			},
+		},
+		"NoMatch": {
+			reason: "We should fetch tags from, and install the dependency from, its declared registry if no rule matches.",
+			dep:    v1beta1.Dependency{Package: "registry.upbound.io/crossplane/provider-aws", Type: v1beta1.ProviderPackageType, Constraints: ">=v1.0.0"},
+			want: want{
+				refs:   []string{"registry.upbound.io/crossplane/provider-aws"},
+				source: "registry.upbound.io/crossplane/provider-aws:v1.0.0",
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			source := ""
+			mgr := &fake.Manager{
+				Client: &test.MockClient{
+					MockGet: test.NewMockGetFn(nil, func(o client.Object) error {
+						l := o.(*v1beta1.Lock)
+						l.Packages = []v1beta1.LockPackage{{
+							Source:       "cool/config",
+							Dependencies: []v1beta1.Dependency{tc.dep},
+						}}
+						return nil
+					}),
+					MockUpdate: test.NewMockUpdateFn(nil),
+					MockList:   test.NewMockListFn(nil),
+					MockCreate: func(_ context.Context, o client.Object, _ ...client.CreateOption) error {
+						source = o.(v1.Package).GetSource()
+						return nil
+					},
+					MockStatusUpdate: test.NewMockStatusUpdateFn(nil),
+				},
+			}
+			f := &refFetcher{}
+			r := NewReconciler(mgr,
+				WithRegistryRewrites(RegistryRewrite{From: "xpkg.upbound.io", To: "registry.internal.corp/mirror"}),
+				WithFetcher(f),
+				WithNewDagFn(dag.NewMapDag),
+			)
+			_, _ = r.Reconcile(context.Background(), reconcile.Request{})
+
+			if diff := cmp.Diff(tc.want.refs, f.refs); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want fetched refs, +got fetched refs:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.source, source); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want source, +got source:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,220 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/crossplane-runtime/pkg/meta"
+
+	v1 "github.com/crossplane/crossplane/apis/pkg/v1"
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+	"github.com/crossplane/crossplane/internal/xpkg"
+)
+
+// Annotations recording the versions of an auto-installed package that the
+// packages that depend on it were healthy with, so that an upgrade that
+// breaks them can be rolled back.
+const (
+	// AnnotationLastKnownGoodVersion is the most recent version of the
+	// package at which the revision of every package that depends on it
+	// was healthy.
+	AnnotationLastKnownGoodVersion = "pkg.crossplane.io/last-known-good-version"
+
+	// AnnotationUpgradedFrom is the version the resolver upgraded the
+	// package from. It's removed once the rollback window elapses.
+	AnnotationUpgradedFrom = "pkg.crossplane.io/upgraded-from"
+
+	// AnnotationRolledBackFrom is the version the resolver rolled the
+	// package back from. The resolver won't upgrade the package to this
+	// version again until the annotation is removed.
+	AnnotationRolledBackFrom = "pkg.crossplane.io/rolled-back-from"
+)
+
+const (
+	errRollbackDependency = "cannot roll back dependency package"
+	errGetDependentRev    = "cannot get package revision of dependent package"
+	errRolledBackFmt      = "refusing to upgrade package %s to %s, which was rolled back because packages that depend on it became unhealthy"
+
+	msgRolledBackFmt = "rolled back package %s from %s to last-known-good version %s because packages that depend on it became unhealthy after it was upgraded: %s"
+)
+
+// WithUpgradeRollback specifies that the Reconciler should roll back an
+// auto-installed dependency it upgraded to the dependency's last-known-good
+// version if the revision of any package that depends on it becomes
+// unhealthy within the supplied window after the upgrade. A version is known
+// to be good once the revision of every package that depends on it is
+// healthy, and it has been installed for longer than the window. Only
+// upgrades the Reconciler performed are ever rolled back.
+func WithUpgradeRollback(window time.Duration) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.rollbackWindow = window
+	}
+}
+
+// rollback rolls back each package auto-installed for the supplied Lock that
+// the Reconciler upgraded within the rollback window, if the revision of any
+// package that depends on it is unhealthy. It records the last-known-good
+// version of every other such package.
+func (r *Reconciler) rollback(ctx context.Context, log logging.Logger, lock *v1beta1.Lock) (RequeueReason, error) {
+	pkgs, err := r.autoInstalled(ctx, lock)
+	if err != nil {
+		return RequeueUpgradeError, errors.Wrap(err, errRollbackDependency)
+	}
+	for _, pack := range pkgs {
+		if rq, err := r.rollbackPackage(ctx, log, lock, pack); err != nil {
+			return rq, errors.Wrap(err, errRollbackDependency)
+		}
+	}
+	return "", nil
+}
+
+func (r *Reconciler) rollbackPackage(ctx context.Context, log logging.Logger, lock *v1beta1.Lock, pack v1.Package) (RequeueReason, error) { // nolint:gocyclo
+	ref, err := name.ParseReference(pack.GetSource(), name.WithDefaultRegistry(""))
+	if err != nil {
+		return "", nil
+	}
+	repo, version := xpkg.ParsePackageSourceFromReference(ref), ref.Identifier()
+
+	// The packages that depend on this one are only affected by its
+	// version once its revision at that version has added itself to the
+	// Lock.
+	var installed *v1beta1.LockPackage
+	for i := range lock.Packages {
+		if r.sourceRepository(lock.Packages[i].Source) == r.sourceRepository(repo) {
+			installed = &lock.Packages[i]
+		}
+	}
+	if installed == nil || installed.Version != version {
+		return "", nil
+	}
+
+	healthy, unhealthy, err := r.dependentsHealth(ctx, lock, installed)
+	if err != nil {
+		return retry(RequeueUpgradeError, err), err
+	}
+
+	a := pack.GetAnnotations()
+	at, err := time.Parse(time.RFC3339, a[AnnotationResolvedAt])
+	upgraded := a[AnnotationUpgradedFrom] != "" && a[AnnotationResolvedVersion] == version
+	watching := upgraded && err == nil && r.now().Sub(at) < r.rollbackWindow
+
+	switch {
+	case watching && len(unhealthy) > 0:
+		good := a[AnnotationLastKnownGoodVersion]
+		if good == "" || good == version {
+			log.Debug("Cannot roll back dependency with no last-known-good version", "package", repo, "version", version, "unhealthy", unhealthy)
+			return "", nil
+		}
+		pack.SetSource(xpkg.VersionedSource(repo, good))
+		meta.AddAnnotations(pack, provenance(lock, r.sourceRepository(repo), good, r.now()))
+		meta.AddAnnotations(pack, map[string]string{AnnotationRolledBackFrom: version})
+		meta.RemoveAnnotations(pack, AnnotationUpgradedFrom)
+		if err := r.client.Update(ctx, pack); err != nil {
+			return retry(RequeueUpgradeError, err), errors.Wrap(err, errUpdatePackage)
+		}
+		msg := fmt.Sprintf(msgRolledBackFmt, repo, version, good, strings.Join(unhealthy, ", "))
+		log.Debug(msg)
+		r.record.Event(lock, event.Warning(reasonRollbackDependency, errors.New(msg)))
+		r.record.Event(pack, event.Warning(reasonRollbackDependency, errors.New(msg)))
+		r.recordAudit(ctx, lock, AuditRecord{Action: AuditRolledBack, Source: repo, Package: pack.GetName(), Version: good, From: version, Reason: msg})
+		return "", nil
+	case watching:
+		// A version isn't known to be good until the packages that
+		// depend on it have been healthy for the whole window.
+		return "", nil
+	}
+
+	update := false
+	if healthy && a[AnnotationLastKnownGoodVersion] != version {
+		meta.AddAnnotations(pack, map[string]string{AnnotationLastKnownGoodVersion: version})
+		update = true
+	}
+	if _, ok := a[AnnotationUpgradedFrom]; ok {
+		meta.RemoveAnnotations(pack, AnnotationUpgradedFrom)
+		update = true
+	}
+	if !update {
+		return "", nil
+	}
+	if err := r.client.Update(ctx, pack); err != nil {
+		return retry(RequeueUpgradeError, err), errors.Wrap(err, errUpdatePackage)
+	}
+	return "", nil
+}
+
+// dependentsHealth returns true if the supplied package has dependents in the
+// supplied Lock, and the revision of each of them is healthy. It also returns
+// the sources of the dependents whose revision is unhealthy. A dependent whose
+// health is unknown is neither healthy nor unhealthy.
+func (r *Reconciler) dependentsHealth(ctx context.Context, lock *v1beta1.Lock, ip *v1beta1.LockPackage) (bool, []string, error) {
+	healthy, unhealthy, n := true, []string{}, 0
+	for _, p := range lock.Packages {
+		if !dependsOn(p, ip) {
+			continue
+		}
+		n++
+		status, err := r.revisionHealth(ctx, p)
+		if err != nil {
+			return false, nil, err
+		}
+		healthy = healthy && status == corev1.ConditionTrue
+		if status == corev1.ConditionFalse {
+			unhealthy = append(unhealthy, p.Source)
+		}
+	}
+	return healthy && n > 0, unhealthy, nil
+}
+
+// dependsOn returns true if the supplied package depends on the supplied
+// installed package.
+func dependsOn(p v1beta1.LockPackage, ip *v1beta1.LockPackage) bool {
+	for _, d := range p.Dependencies {
+		if d.Identifier() == ip.Identifier() {
+			return true
+		}
+	}
+	return false
+}
+
+// revisionHealth returns the status of the Healthy condition of the revision
+// of the supplied package. The status is unknown if the revision doesn't
+// exist.
+func (r *Reconciler) revisionHealth(ctx context.Context, p v1beta1.LockPackage) (corev1.ConditionStatus, error) {
+	rev, ok := newPackageRevision(p.Type)
+	if !ok {
+		return corev1.ConditionUnknown, nil
+	}
+	if err := r.client.Get(ctx, types.NamespacedName{Name: p.Name}, rev); err != nil {
+		if kerrors.IsNotFound(err) {
+			return corev1.ConditionUnknown, nil
+		}
+		return corev1.ConditionUnknown, errors.Wrap(err, errGetDependentRev)
+	}
+	return rev.GetCondition(v1.TypeHealthy).Status, nil
+}
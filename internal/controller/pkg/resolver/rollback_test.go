@@ -0,0 +1,344 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/fake"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	v1 "github.com/crossplane/crossplane/apis/pkg/v1"
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+	"github.com/crossplane/crossplane/internal/dag"
+	fakexpkg "github.com/crossplane/crossplane/internal/xpkg/fake"
+)
+
+// rollbackClient returns a client that serves the supplied Lock, the supplied
+// auto-installed Provider, and a ConfigurationRevision of the supplied health
+// for each entry in revs. It records updates to the Provider.
+func rollbackClient(lock *v1beta1.Lock, prov *v1.Provider, revs map[string]corev1.ConditionStatus) client.Client {
+	return &test.MockClient{
+		MockGet: func(_ context.Context, key client.ObjectKey, o client.Object) error {
+			switch obj := o.(type) {
+			case *v1beta1.Lock:
+				lock.DeepCopyInto(obj)
+				return nil
+			case *v1.ConfigurationRevision:
+				s, ok := revs[key.Name]
+				if !ok {
+					break
+				}
+				obj.SetName(key.Name)
+				obj.SetConditions(xpv1.Condition{Type: v1.TypeHealthy, Status: s})
+				return nil
+			}
+			return kerrors.NewNotFound(schema.GroupResource{}, key.Name)
+		},
+		MockList: func(_ context.Context, o client.ObjectList, _ ...client.ListOption) error {
+			if l, ok := o.(*v1.ProviderList); ok {
+				l.Items = []v1.Provider{*prov.DeepCopy()}
+			}
+			return nil
+		},
+		MockUpdate: func(_ context.Context, o client.Object, _ ...client.UpdateOption) error {
+			if p, ok := o.(*v1.Provider); ok && p != prov {
+				p.DeepCopyInto(prov)
+			}
+			return nil
+		},
+		MockStatusUpdate: test.NewMockStatusUpdateFn(nil),
+	}
+}
+
+func TestReconcileRollback(t *testing.T) {
+	now := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	// provider-aws is auto-installed for config-a. config-b, installed
+	// later, requires a newer version of it.
+	provider := func(version string) v1beta1.LockPackage {
+		return v1beta1.LockPackage{Name: "provider-aws-" + version, Type: v1beta1.ProviderPackageType, Source: "crossplane/provider-aws", Version: version}
+	}
+	configA := v1beta1.LockPackage{Name: "config-a", Type: v1beta1.ConfigurationPackageType, Source: "cool/config-a", Version: "v1.0.0", Dependencies: []v1beta1.Dependency{
+		{Package: "crossplane/provider-aws", Type: v1beta1.ProviderPackageType, Constraints: ">=v0.20.0"},
+	}}
+	configB := v1beta1.LockPackage{Name: "config-b", Type: v1beta1.ConfigurationPackageType, Source: "cool/config-b", Version: "v1.0.0", Dependencies: []v1beta1.Dependency{
+		{Package: "crossplane/provider-aws", Type: v1beta1.ProviderPackageType, Constraints: ">=v0.21.0"},
+	}}
+
+	lock := &v1beta1.Lock{}
+	lock.SetName("lock")
+	lock.SetAnnotations(map[string]string{AnnotationUpgradeDependencies: "true"})
+
+	prov := &v1.Provider{ObjectMeta: metav1.ObjectMeta{Name: "crossplane-provider-aws", Labels: map[string]string{LabelAutoInstalled: "true"}}}
+	prov.SetSource("crossplane/provider-aws:v0.20.0")
+
+	revs := map[string]corev1.ConditionStatus{}
+	rec := &recorder{}
+	r := NewReconciler(&fake.Manager{Client: rollbackClient(lock, prov, revs)},
+		WithRecorder(rec),
+		WithUpgradeDependencies(),
+		WithUpgradeRollback(10*time.Minute),
+		WithFetcher(fakexpkg.NewTagFetcher(map[string][]string{
+			"crossplane/provider-aws": {"v0.20.0", "v0.21.0"},
+		})),
+		WithNewDagFn(dag.NewMapDag),
+	)
+
+	type want struct {
+		source      string
+		annotations map[string]string
+		events      []event.Reason
+	}
+
+	// Each step reconciles the Lock after the time elapsed, the packages in
+	// the Lock, and the health of config-a's revision changed.
+	steps := []struct {
+		name    string
+		reason  string
+		elapsed time.Duration
+		pkgs    []v1beta1.LockPackage
+		health  corev1.ConditionStatus
+		want    want
+	}{
+		{
+			name:   "Healthy",
+			reason: "We should record the installed version as last-known-good once every package that depends on it is healthy.",
+			pkgs:   []v1beta1.LockPackage{provider("v0.20.0"), configA},
+			health: corev1.ConditionTrue,
+			want: want{
+				source:      "crossplane/provider-aws:v0.20.0",
+				annotations: map[string]string{AnnotationLastKnownGoodVersion: "v0.20.0"},
+			},
+		},
+		{
+			name:    "Upgraded",
+			reason:  "We should record the version we upgraded from when we upgrade the package.",
+			elapsed: time.Minute,
+			pkgs:    []v1beta1.LockPackage{provider("v0.20.0"), configA, configB},
+			health:  corev1.ConditionTrue,
+			want: want{
+				source: "crossplane/provider-aws:v0.21.0",
+				annotations: map[string]string{
+					AnnotationLastKnownGoodVersion: "v0.20.0",
+					AnnotationUpgradedFrom:         "v0.20.0",
+					AnnotationResolvedVersion:      "v0.21.0",
+				},
+				events: []event.Reason{reasonVersionConflict, reasonUpgradeDependency, reasonUpgradeDependency},
+			},
+		},
+		{
+			name:    "BrokeDependent",
+			reason:  "We should roll the package back to its last-known-good version if a package that depends on it becomes unhealthy within the window.",
+			elapsed: 2 * time.Minute,
+			pkgs:    []v1beta1.LockPackage{provider("v0.21.0"), configA, configB},
+			health:  corev1.ConditionFalse,
+			want: want{
+				source: "crossplane/provider-aws:v0.20.0",
+				annotations: map[string]string{
+					AnnotationLastKnownGoodVersion: "v0.20.0",
+					AnnotationRolledBackFrom:       "v0.21.0",
+					AnnotationResolvedVersion:      "v0.20.0",
+				},
+				events: []event.Reason{reasonRollbackDependency, reasonRollbackDependency},
+			},
+		},
+		{
+			name:    "Recovered",
+			reason:  "We should refuse to upgrade the package to the version we rolled back once its dependents recover. The version conflict was already reported within the event window.",
+			elapsed: time.Minute,
+			pkgs:    []v1beta1.LockPackage{provider("v0.20.0"), configA, configB},
+			health:  corev1.ConditionTrue,
+			want: want{
+				source: "crossplane/provider-aws:v0.20.0",
+				annotations: map[string]string{
+					AnnotationLastKnownGoodVersion: "v0.20.0",
+					AnnotationRolledBackFrom:       "v0.21.0",
+					AnnotationResolvedVersion:      "v0.20.0",
+				},
+				events: []event.Reason{reasonUpgradeDependency},
+			},
+		},
+	}
+
+	for _, s := range steps {
+		now = now.Add(s.elapsed)
+		r.now = func() time.Time { return now }
+		lock.Packages = s.pkgs
+		revs["config-a"], revs["config-b"] = s.health, corev1.ConditionTrue
+		rec.reasons = nil
+
+		if _, err := r.Reconcile(context.Background(), reconcile.Request{}); err != nil {
+			t.Fatalf("\n%s: %s\nr.Reconcile(...): %s", s.name, s.reason, err)
+		}
+		if diff := cmp.Diff(s.want.source, prov.GetSource()); diff != "" {
+			t.Errorf("\n%s: %s\nr.Reconcile(...): -want source, +got source:\n%s", s.name, s.reason, diff)
+		}
+		got := map[string]string{}
+		for _, k := range []string{AnnotationLastKnownGoodVersion, AnnotationUpgradedFrom, AnnotationRolledBackFrom, AnnotationResolvedVersion} {
+			if v, ok := prov.GetAnnotations()[k]; ok {
+				got[k] = v
+			}
+		}
+		if diff := cmp.Diff(s.want.annotations, got); diff != "" {
+			t.Errorf("\n%s: %s\nr.Reconcile(...): -want annotations, +got annotations:\n%s", s.name, s.reason, diff)
+		}
+		if diff := cmp.Diff(s.want.events, rec.reasons); diff != "" {
+			t.Errorf("\n%s: %s\nr.Reconcile(...): -want events, +got events:\n%s", s.name, s.reason, diff)
+		}
+	}
+}
+
+func TestRollbackPackage(t *testing.T) {
+	now := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	lock := &v1beta1.Lock{}
+	lock.SetName("lock")
+	lock.Packages = []v1beta1.LockPackage{
+		{Name: "provider-aws", Type: v1beta1.ProviderPackageType, Source: "crossplane/provider-aws", Version: "v0.21.0"},
+		{Name: "config-a", Type: v1beta1.ConfigurationPackageType, Source: "cool/config-a", Dependencies: []v1beta1.Dependency{
+			{Package: "crossplane/provider-aws", Type: v1beta1.ProviderPackageType, Constraints: ">=v0.20.0"},
+		}},
+	}
+
+	upgraded := func(at time.Time) map[string]string {
+		return map[string]string{
+			AnnotationLastKnownGoodVersion: "v0.20.0",
+			AnnotationUpgradedFrom:         "v0.20.0",
+			AnnotationResolvedVersion:      "v0.21.0",
+			AnnotationResolvedAt:           at.Format(time.RFC3339),
+		}
+	}
+
+	type want struct {
+		source      string
+		annotations map[string]string
+		events      []event.Reason
+	}
+
+	cases := map[string]struct {
+		reason      string
+		annotations map[string]string
+		health      map[string]corev1.ConditionStatus
+		want        want
+	}{
+		"WindowElapsed": {
+			reason:      "We should not roll back an upgrade once the window has elapsed, and should stop watching it.",
+			annotations: upgraded(now.Add(-time.Hour)),
+			health:      map[string]corev1.ConditionStatus{"config-a": corev1.ConditionFalse},
+			want: want{
+				source: "crossplane/provider-aws:v0.21.0",
+				annotations: map[string]string{
+					AnnotationLastKnownGoodVersion: "v0.20.0",
+					AnnotationResolvedVersion:      "v0.21.0",
+					AnnotationResolvedAt:           now.Add(-time.Hour).Format(time.RFC3339),
+				},
+			},
+		},
+		"NotUpgradedByResolver": {
+			reason: "We should never roll back a version we didn't upgrade the package to.",
+			annotations: map[string]string{
+				AnnotationLastKnownGoodVersion: "v0.20.0",
+				AnnotationResolvedVersion:      "v0.20.0",
+				AnnotationResolvedAt:           now.Format(time.RFC3339),
+			},
+			health: map[string]corev1.ConditionStatus{"config-a": corev1.ConditionFalse},
+			want: want{
+				source: "crossplane/provider-aws:v0.21.0",
+				annotations: map[string]string{
+					AnnotationLastKnownGoodVersion: "v0.20.0",
+					AnnotationResolvedVersion:      "v0.20.0",
+					AnnotationResolvedAt:           now.Format(time.RFC3339),
+				},
+			},
+		},
+		"NoLastKnownGood": {
+			reason: "We should not roll back an upgrade if no version is known to be good.",
+			annotations: map[string]string{
+				AnnotationUpgradedFrom:    "v0.20.0",
+				AnnotationResolvedVersion: "v0.21.0",
+				AnnotationResolvedAt:      now.Format(time.RFC3339),
+			},
+			health: map[string]corev1.ConditionStatus{"config-a": corev1.ConditionFalse},
+			want: want{
+				source: "crossplane/provider-aws:v0.21.0",
+				annotations: map[string]string{
+					AnnotationUpgradedFrom:    "v0.20.0",
+					AnnotationResolvedVersion: "v0.21.0",
+					AnnotationResolvedAt:      now.Format(time.RFC3339),
+				},
+			},
+		},
+		"HealthUnknown": {
+			reason:      "We should neither roll back nor trust an upgrade while the health of its dependents is unknown.",
+			annotations: upgraded(now),
+			want: want{
+				source:      "crossplane/provider-aws:v0.21.0",
+				annotations: upgraded(now),
+			},
+		},
+		"HealthyWithinWindow": {
+			reason:      "We should not record an upgraded version as last-known-good until the window has elapsed.",
+			annotations: upgraded(now),
+			health:      map[string]corev1.ConditionStatus{"config-a": corev1.ConditionTrue},
+			want: want{
+				source:      "crossplane/provider-aws:v0.21.0",
+				annotations: upgraded(now),
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			prov := &v1.Provider{ObjectMeta: metav1.ObjectMeta{Name: "crossplane-provider-aws", Annotations: tc.annotations}}
+			prov.SetSource("crossplane/provider-aws:v0.21.0")
+
+			rec := &recorder{}
+			r := NewReconciler(&fake.Manager{Client: rollbackClient(lock, prov, tc.health)},
+				WithRecorder(rec),
+				WithUpgradeRollback(10*time.Minute),
+			)
+			r.now = func() time.Time { return now }
+
+			if _, err := r.rollbackPackage(context.Background(), logging.NewNopLogger(), lock, prov); err != nil {
+				t.Fatalf("\n%s\nr.rollbackPackage(...): %s", tc.reason, err)
+			}
+			if diff := cmp.Diff(tc.want.source, prov.GetSource()); diff != "" {
+				t.Errorf("\n%s\nr.rollbackPackage(...): -want source, +got source:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.annotations, prov.GetAnnotations()); diff != "" {
+				t.Errorf("\n%s\nr.rollbackPackage(...): -want annotations, +got annotations:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.events, rec.reasons); diff != "" {
+				t.Errorf("\n%s\nr.rollbackPackage(...): -want events, +got events:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
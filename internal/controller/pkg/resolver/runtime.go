@@ -0,0 +1,66 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/types"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+
+	v1 "github.com/crossplane/crossplane/apis/pkg/v1"
+	"github.com/crossplane/crossplane/apis/pkg/v1alpha1"
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+)
+
+const (
+	errGetRuntimeConfigFmt = "cannot get default runtime config %s; installing dependency with a reference to it anyway"
+)
+
+// WithDefaultRuntimeConfigRef specifies the name of a ControllerConfig that
+// the Reconciler should reference from every Provider it installs, so that
+// auto-installed Providers run with the settings an operator expects rather
+// than the defaults. Configurations don't run a controller, and thus never
+// reference it.
+func WithDefaultRuntimeConfigRef(name string) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.runtimeConfig = name
+	}
+}
+
+// addRuntimeConfigRef references the default runtime config from the supplied
+// package, if it is a Provider. A runtime config that doesn't exist yet is
+// still referenced, so that the Provider uses it once it's created, but we
+// emit a warning so that someone notices.
+func (r *Reconciler) addRuntimeConfigRef(ctx context.Context, log logging.Logger, lock *v1beta1.Lock, pack v1.Package) {
+	if r.runtimeConfig == "" {
+		return
+	}
+	if _, ok := pack.(*v1.Provider); !ok {
+		return
+	}
+	if err := r.client.Get(ctx, types.NamespacedName{Name: r.runtimeConfig}, &v1alpha1.ControllerConfig{}); err != nil {
+		err = errors.Wrapf(err, errGetRuntimeConfigFmt, r.runtimeConfig)
+		log.Debug("Cannot get default runtime config", "error", err)
+		r.record.Event(lock, event.Warning(reasonRuntimeConfig, err))
+	}
+	pack.SetControllerConfigRef(&xpv1.Reference{Name: r.runtimeConfig})
+}
@@ -0,0 +1,141 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/fake"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	v1 "github.com/crossplane/crossplane/apis/pkg/v1"
+	"github.com/crossplane/crossplane/apis/pkg/v1alpha1"
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+	"github.com/crossplane/crossplane/internal/dag"
+	fakexpkg "github.com/crossplane/crossplane/internal/xpkg/fake"
+)
+
+func TestReconcileDefaultRuntimeConfig(t *testing.T) {
+	type want struct {
+		ref    *xpv1.Reference
+		events []event.Reason
+	}
+
+	cases := map[string]struct {
+		reason  string
+		config  string
+		exists  bool
+		depType v1beta1.PackageType
+		want    want
+	}{
+		"NoDefaultRuntimeConfig": {
+			reason:  "We should not reference a runtime config from a Provider unless a default is configured.",
+			depType: v1beta1.ProviderPackageType,
+			want: want{
+				events: []event.Reason{reasonInstallDependency},
+			},
+		},
+		"Provider": {
+			reason:  "We should reference the default runtime config from a Provider we install.",
+			config:  "cool-config",
+			exists:  true,
+			depType: v1beta1.ProviderPackageType,
+			want: want{
+				ref:    &xpv1.Reference{Name: "cool-config"},
+				events: []event.Reason{reasonInstallDependency},
+			},
+		},
+		"Configuration": {
+			reason:  "We should not reference the default runtime config from a Configuration we install.",
+			config:  "cool-config",
+			exists:  true,
+			depType: v1beta1.ConfigurationPackageType,
+			want: want{
+				events: []event.Reason{reasonInstallDependency},
+			},
+		},
+		"MissingRuntimeConfig": {
+			reason:  "We should install a Provider that references a default runtime config that doesn't exist, but warn about it.",
+			config:  "cool-config",
+			depType: v1beta1.ProviderPackageType,
+			want: want{
+				ref:    &xpv1.Reference{Name: "cool-config"},
+				events: []event.Reason{reasonRuntimeConfig, reasonInstallDependency},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var ref *xpv1.Reference
+			mgr := &fake.Manager{
+				Client: &test.MockClient{
+					MockGet: func(_ context.Context, key client.ObjectKey, o client.Object) error {
+						switch obj := o.(type) {
+						case *v1beta1.Lock:
+							obj.Packages = []v1beta1.LockPackage{{
+								Source: "cool/config",
+								Dependencies: []v1beta1.Dependency{
+									{Package: "cool/dep", Type: tc.depType, Constraints: ">=v0.20.0"},
+								},
+							}}
+							return nil
+						case *v1alpha1.ControllerConfig:
+							if tc.exists && key.Name == tc.config {
+								return nil
+							}
+						}
+						return kerrors.NewNotFound(schema.GroupResource{}, key.Name)
+					},
+					MockList: test.NewMockListFn(nil),
+					MockCreate: func(_ context.Context, o client.Object, _ ...client.CreateOption) error {
+						ref = o.(v1.Package).GetControllerConfigRef()
+						return nil
+					},
+					MockUpdate:       test.NewMockUpdateFn(nil),
+					MockStatusUpdate: test.NewMockStatusUpdateFn(nil),
+				},
+			}
+			rec := &recorder{}
+			r := NewReconciler(mgr,
+				WithRecorder(rec),
+				WithFetcher(&fakexpkg.MockFetcher{MockTags: fakexpkg.NewMockTagsFn([]string{"v0.20.0"}, nil)}),
+				WithDefaultRuntimeConfigRef(tc.config),
+				WithNewDagFn(dag.NewMapDag),
+			)
+
+			if _, err := r.Reconcile(context.Background(), reconcile.Request{}); err != nil {
+				t.Fatalf("\n%s\nr.Reconcile(...): %s", tc.reason, err)
+			}
+			if diff := cmp.Diff(tc.want.ref, ref); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want runtime config ref, +got runtime config ref:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.events, rec.reasons); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want events, +got events:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,136 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"context"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	ociv1 "github.com/google/go-containerregistry/pkg/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+
+	v1 "github.com/crossplane/crossplane/apis/pkg/v1"
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+	"github.com/crossplane/crossplane/internal/xpkg"
+)
+
+const (
+	errGetPullSecretFmt = "cannot get default pull secret %s; fetching dependency tags without it"
+)
+
+// WithNamespace specifies the namespace Crossplane runs in.
+func WithNamespace(namespace string) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.namespace = namespace
+	}
+}
+
+// WithDefaultPullSecrets specifies pull secrets in Crossplane's namespace that
+// the Reconciler should present when it fetches the tags of dependencies, and
+// add to the package pull secrets of every dependency package it installs.
+// Secrets that can't be read are skipped, so that dependencies are fetched and
+// installed unauthenticated rather than not at all.
+func WithDefaultPullSecrets(secrets []string) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.secrets = secrets
+	}
+}
+
+// pullSecrets returns the default pull secrets that exist. Secrets are read
+// from the API server rather than the cache, so that we don't cache every
+// secret in Crossplane's namespace.
+func (r *Reconciler) pullSecrets(ctx context.Context, log logging.Logger, lock *v1beta1.Lock) []string {
+	out := make([]string, 0, len(r.secrets))
+	for _, n := range r.secrets {
+		s := &corev1.Secret{}
+		if err := r.reader.Get(ctx, types.NamespacedName{Namespace: r.namespace, Name: n}, s); err != nil {
+			err = errors.Wrapf(err, errGetPullSecretFmt, n)
+			log.Debug("Skipping default pull secret", "error", err)
+			r.record.Event(lock, event.Warning(reasonPullSecret, err))
+			continue
+		}
+		out = append(out, n)
+	}
+	return out
+}
+
+// addPullSecrets adds the supplied pull secrets to the supplied package,
+// unless it already uses them.
+func addPullSecrets(pack v1.Package, secrets []string) {
+	refs := pack.GetPackagePullSecrets()
+	has := make(map[string]bool, len(refs))
+	for _, s := range refs {
+		has[s.Name] = true
+	}
+	for _, s := range secrets {
+		if !has[s] {
+			refs = append(refs, corev1.LocalObjectReference{Name: s})
+			has[s] = true
+		}
+	}
+	pack.SetPackagePullSecrets(refs)
+}
+
+// withSecrets returns a fetcher that presents the supplied pull secrets in
+// addition to those it is called with.
+func withSecrets(f xpkg.Fetcher, secrets []string) xpkg.Fetcher {
+	if len(secrets) == 0 {
+		return f
+	}
+	return &secretFetcher{Fetcher: f, secrets: secrets}
+}
+
+// A secretFetcher presents its pull secrets in addition to those it is called
+// with.
+type secretFetcher struct {
+	xpkg.Fetcher
+	secrets []string
+}
+
+func (f *secretFetcher) with(secrets []string) []string {
+	return append(append([]string{}, secrets...), f.secrets...)
+}
+
+// Fetch fetches a package image.
+func (f *secretFetcher) Fetch(ctx context.Context, ref name.Reference, secrets ...string) (ociv1.Image, error) {
+	return f.Fetcher.Fetch(ctx, ref, f.with(secrets)...)
+}
+
+// Head fetches a package descriptor.
+func (f *secretFetcher) Head(ctx context.Context, ref name.Reference, secrets ...string) (*ociv1.Descriptor, error) {
+	return f.Fetcher.Head(ctx, ref, f.with(secrets)...)
+}
+
+// FetchMeta fetches a package's metadata.
+func (f *secretFetcher) FetchMeta(ctx context.Context, ref name.Reference, secrets ...string) (*xpkg.PackageMeta, error) {
+	return f.Fetcher.FetchMeta(ctx, ref, f.with(secrets)...)
+}
+
+// Tags fetches a package's tags.
+func (f *secretFetcher) Tags(ctx context.Context, ref name.Reference, secrets ...string) ([]string, error) {
+	return f.Fetcher.Tags(ctx, ref, f.with(secrets)...)
+}
+
+// TagsPaged fetches a package's tags a page at a time.
+func (f *secretFetcher) TagsPaged(ctx context.Context, ref name.Reference, fn xpkg.TagPageFn, secrets ...string) error {
+	return xpkg.PagedTags(ctx, f.Fetcher, ref, fn, f.with(secrets)...)
+}
@@ -0,0 +1,173 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	kfake "k8s.io/client-go/kubernetes/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/fake"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	v1 "github.com/crossplane/crossplane/apis/pkg/v1"
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+	"github.com/crossplane/crossplane/internal/dag"
+	"github.com/crossplane/crossplane/internal/xpkg"
+)
+
+func TestReconcileDefaultPullSecrets(t *testing.T) {
+	// The registry only lists tags to clients that present its credentials.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if u, p, ok := r.BasicAuth(); !ok || u != "cool" || p != "secret" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="registry"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path == "/v2/" {
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"name": "cool/provider", "tags": []string{"v0.20.0"}})
+	}))
+	defer srv.Close()
+	registry := strings.TrimPrefix(srv.URL, "http://")
+
+	ns := "crossplane-system"
+	creds := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: "creds"},
+		Type:       corev1.SecretTypeDockerConfigJson,
+		Data: map[string][]byte{
+			corev1.DockerConfigJsonKey: []byte(fmt.Sprintf(`{"auths":{%q:{"username":"cool","password":"secret"}}}`, registry)),
+		},
+	}
+	clientset := kfake.NewSimpleClientset(&corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: "default"}}, creds)
+
+	type want struct {
+		created []corev1.LocalObjectReference
+		events  []event.Reason
+		reason  xpv1.ConditionReason
+	}
+
+	cases := map[string]struct {
+		reason  string
+		secrets []string
+		want    want
+	}{
+		"NoDefaultPullSecrets": {
+			reason: "We should fail to fetch the tags of a private dependency if no default pull secrets are configured.",
+			want: want{
+				events: []event.Reason{reasonRegistryAuth},
+				reason: v1beta1.ReasonUnauthorized,
+			},
+		},
+		"DefaultPullSecrets": {
+			reason:  "We should fetch tags using the default pull secrets, and add them to the dependency package we install.",
+			secrets: []string{"creds"},
+			want: want{
+				created: []corev1.LocalObjectReference{{Name: "creds"}},
+				events:  []event.Reason{reasonInstallDependency},
+				reason:  v1beta1.ReasonMissingDependency,
+			},
+		},
+		"MissingDefaultPullSecret": {
+			reason:  "We should skip default pull secrets that don't exist, rather than failing to resolve dependencies.",
+			secrets: []string{"missing", "creds"},
+			want: want{
+				created: []corev1.LocalObjectReference{{Name: "creds"}},
+				events:  []event.Reason{reasonPullSecret, reasonInstallDependency},
+				reason:  v1beta1.ReasonMissingDependency,
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var created []corev1.LocalObjectReference
+			var got xpv1.ConditionReason
+			mgr := &fake.Manager{
+				Client: &test.MockClient{
+					MockGet: func(_ context.Context, key client.ObjectKey, o client.Object) error {
+						switch obj := o.(type) {
+						case *v1beta1.Lock:
+							obj.Packages = []v1beta1.LockPackage{{
+								Source: "cool/config",
+								Dependencies: []v1beta1.Dependency{
+									{Package: registry + "/cool/provider", Type: v1beta1.ProviderPackageType, Constraints: ">=v0.20.0"},
+								},
+							}}
+							return nil
+						case *corev1.Secret:
+							s, err := clientset.CoreV1().Secrets(key.Namespace).Get(context.Background(), key.Name, metav1.GetOptions{})
+							if err != nil {
+								return err
+							}
+							s.DeepCopyInto(obj)
+							return nil
+						}
+						return kerrors.NewNotFound(schema.GroupResource{}, key.Name)
+					},
+					MockList: test.NewMockListFn(nil),
+					MockCreate: func(_ context.Context, o client.Object, _ ...client.CreateOption) error {
+						created = o.(*v1.Provider).GetPackagePullSecrets()
+						return nil
+					},
+					MockUpdate: test.NewMockUpdateFn(nil),
+					MockStatusUpdate: test.NewMockStatusUpdateFn(nil, func(o client.Object) error {
+						got = o.(*v1beta1.Lock).GetCondition(v1beta1.TypeDependenciesResolved).Reason
+						return nil
+					}),
+				},
+			}
+			rec := &recorder{}
+			r := NewReconciler(mgr,
+				WithRecorder(rec),
+				WithFetcher(xpkg.NewK8sFetcher(clientset, ns)),
+				WithNamespace(ns),
+				WithDefaultPullSecrets(tc.secrets),
+				WithNewDagFn(dag.NewMapDag),
+			)
+
+			if _, err := r.Reconcile(context.Background(), reconcile.Request{}); err != nil {
+				t.Fatalf("\n%s\nr.Reconcile(...): %s", tc.reason, err)
+			}
+			if diff := cmp.Diff(tc.want.created, created); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want pull secrets, +got pull secrets:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.events, rec.reasons); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want events, +got events:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.reason, got); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want condition reason, +got condition reason:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"github.com/crossplane/crossplane/internal/xpkg"
+)
+
+// AnnotationVersionSelection is added to dependency packages whose version
+// was selected from those satisfying a semantic version constraint. Its value
+// is the xpkg.VersionSelection policy that selected the version.
+const AnnotationVersionSelection = "pkg.crossplane.io/version-selection"
+
+// WithVersionSelection specifies which of the versions that satisfy a
+// dependency's constraint the Reconciler should install.
+func WithVersionSelection(s xpkg.VersionSelection) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.selection = s
+	}
+}
+
+// WithVersionGroups specifies groups of repositories whose packages the
+// Reconciler must install at the same major.minor version, for example the
+// packages of a provider family. Each key is a repository prefix, e.g.
+// xpkg.upbound.io/upbound/provider-aws-, and each value names the group of the
+// repositories it prefixes.
+func WithVersionGroups(groups map[string]string) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.versionGroups = groups
+	}
+}
+
+// WithDigestPinning specifies that the Reconciler should install a dependency
+// whose version it selected at the digest the selected tag points at, rather
+// than at the tag. If the Reconciler's version source records the digest each
+// version was published with the tag must still point at it.
+func WithDigestPinning() ReconcilerOption {
+	return func(r *Reconciler) {
+		r.digests = true
+	}
+}
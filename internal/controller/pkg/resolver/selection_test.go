@@ -0,0 +1,296 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/fake"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	v1 "github.com/crossplane/crossplane/apis/pkg/v1"
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+	"github.com/crossplane/crossplane/internal/dag"
+	"github.com/crossplane/crossplane/internal/xpkg"
+	fakexpkg "github.com/crossplane/crossplane/internal/xpkg/fake"
+)
+
+func TestReconcileVersionSelection(t *testing.T) {
+	tags := []string{"v0.19.0", "v0.20.0", "v0.21.0", "v0.35.0"}
+
+	type want struct {
+		source    string
+		selection string
+	}
+
+	cases := map[string]struct {
+		reason     string
+		selection  xpkg.VersionSelection
+		constraint string
+		want       want
+	}{
+		"Highest": {
+			reason:     "We should install the highest version that satisfies the constraint, and record why.",
+			selection:  xpkg.VersionSelectionHighest,
+			constraint: ">=v0.20.0",
+			want: want{
+				source:    "crossplane/provider-aws:v0.35.0",
+				selection: "Highest",
+			},
+		},
+		"Lowest": {
+			reason:     "We should install the lowest version that satisfies the constraint, and record why.",
+			selection:  xpkg.VersionSelectionLowest,
+			constraint: ">=v0.20.0",
+			want: want{
+				source:    "crossplane/provider-aws:v0.20.0",
+				selection: "Lowest",
+			},
+		},
+		"Pinned": {
+			reason:     "We should not record a version selection policy for a pinned dependency.",
+			selection:  xpkg.VersionSelectionLowest,
+			constraint: "main",
+			want: want{
+				source: "crossplane/provider-aws:main",
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var created v1.Package
+			mgr := &fake.Manager{
+				Client: &test.MockClient{
+					MockGet: test.NewMockGetFn(nil, func(o client.Object) error {
+						o.(*v1beta1.Lock).Packages = []v1beta1.LockPackage{{
+							Source:       "cool/config",
+							Dependencies: []v1beta1.Dependency{{Package: "crossplane/provider-aws", Type: v1beta1.ProviderPackageType, Constraints: tc.constraint}},
+						}}
+						return nil
+					}),
+					MockUpdate: test.NewMockUpdateFn(nil),
+					MockList:   test.NewMockListFn(nil),
+					MockCreate: func(_ context.Context, o client.Object, _ ...client.CreateOption) error {
+						created = o.(v1.Package)
+						return nil
+					},
+					MockStatusUpdate: test.NewMockStatusUpdateFn(nil),
+				},
+			}
+			r := NewReconciler(mgr,
+				WithVersionSelection(tc.selection),
+				WithFetcher(&fakexpkg.MockFetcher{MockTags: fakexpkg.NewMockTagsFn(tags, nil)}),
+				WithNewDagFn(dag.NewMapDag),
+			)
+			_, _ = r.Reconcile(context.Background(), reconcile.Request{})
+
+			if created == nil {
+				t.Fatalf("\n%s\nr.Reconcile(...): expected a dependency to be created", tc.reason)
+			}
+			if diff := cmp.Diff(tc.want.source, created.GetSource()); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want source, +got source:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.selection, created.GetAnnotations()[AnnotationVersionSelection]); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want version selection, +got version selection:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestReconcileConstraintIntersection(t *testing.T) {
+	tags := []string{"v0.15.0", "v0.18.0", "v0.19.0", "v0.19.1", "v0.20.0", "v0.25.0"}
+
+	type want struct {
+		source  string
+		events  []event.Reason
+		msgs    []string
+		objects []string
+	}
+
+	cases := map[string]struct {
+		reason      string
+		constraints map[string]string
+		want        want
+	}{
+		"Intersection": {
+			reason: "We should install the highest version that satisfies the constraints of every package that depends on a missing dependency.",
+			constraints: map[string]string{
+				"cool/config-a": ">=0.15.0, <0.20.0",
+				"cool/config-b": ">=0.18.0",
+			},
+			want: want{
+				source:  "crossplane/provider-gcp:v0.19.1",
+				events:  []event.Reason{reasonInstallDependency},
+				msgs:    []string{"Installed automatically as a dependency of cool/config-a to satisfy constraint >=0.15.0, <0.20.0 and of cool/config-b to satisfy constraint >=0.18.0, selected version v0.19.1 from 6 available tags"},
+				objects: []string{"crossplane-provider-gcp"},
+			},
+		},
+		"EmptyIntersection": {
+			reason: "We should emit an event listing every package that depends on a missing dependency and its constraint if no version satisfies all of them.",
+			constraints: map[string]string{
+				"cool/config-a": ">=0.15.0, <0.18.0",
+				"cool/config-b": ">=0.20.0",
+			},
+			want: want{
+				events:  []event.Reason{reasonNoValidVersion},
+				msgs:    []string{`cannot find a valid version for package constraints: no version of dependency (crossplane/provider-gcp) satisfies every package that depends on it: ">=0.15.0, <0.18.0" required by cool/config-a, ">=0.20.0" required by cool/config-b: highest available version is v0.25.0; ">=0.15.0, <0.18.0" allows only v0.15.0, ">=0.20.0" allows v0.20.0 to v0.25.0; changing the constraint of cool/config-a to allow v0.25.0, or of cool/config-b to allow v0.15.0 would be compatible with every other package that depends on it`},
+				objects: []string{"lock"},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			source := ""
+			mgr := &fake.Manager{
+				Client: &test.MockClient{
+					MockGet: test.NewMockGetFn(nil, func(o client.Object) error {
+						l := o.(*v1beta1.Lock)
+						l.SetName("lock")
+						for _, p := range []string{"cool/config-a", "cool/config-b"} {
+							l.Packages = append(l.Packages, v1beta1.LockPackage{
+								Source:       p,
+								Dependencies: []v1beta1.Dependency{{Package: "crossplane/provider-gcp", Type: v1beta1.ProviderPackageType, Constraints: tc.constraints[p]}},
+							})
+						}
+						return nil
+					}),
+					MockUpdate: test.NewMockUpdateFn(nil),
+					MockList:   test.NewMockListFn(nil),
+					MockCreate: func(_ context.Context, o client.Object, _ ...client.CreateOption) error {
+						source = o.(v1.Package).GetSource()
+						return nil
+					},
+					MockStatusUpdate: test.NewMockStatusUpdateFn(nil),
+				},
+			}
+			rec := &recorder{}
+			r := NewReconciler(mgr,
+				WithRecorder(rec),
+				WithFetcher(&fakexpkg.MockFetcher{MockTags: fakexpkg.NewMockTagsFn(tags, nil)}),
+				WithNewDagFn(dag.NewMapDag),
+			)
+			_, _ = r.Reconcile(context.Background(), reconcile.Request{})
+
+			if diff := cmp.Diff(tc.want.source, source); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want source, +got source:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.events, rec.reasons); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want events, +got events:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.msgs, rec.messages); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want event messages, +got event messages:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.objects, rec.objects); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want event objects, +got event objects:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestReconcileVersionGroups(t *testing.T) {
+	tags := []string{"v1.14.0", "v1.15.0", "v1.16.0"}
+
+	type want struct {
+		sources []string
+		events  []event.Reason
+		msgs    []string
+	}
+
+	cases := map[string]struct {
+		reason      string
+		constraints map[string]string
+		want        want
+	}{
+		"CommonStream": {
+			reason: "We should install every dependency in a version group at the highest major.minor version that satisfies all of them.",
+			constraints: map[string]string{
+				"crossplane/provider-aws-s3":  ">=v1.14.0",
+				"crossplane/provider-aws-ec2": ">=v1.14.0, <v1.16.0",
+			},
+			want: want{
+				sources: []string{"crossplane/provider-aws-ec2:v1.15.0", "crossplane/provider-aws-s3:v1.15.0"},
+				events:  []event.Reason{reasonInstallDependency, reasonInstallDependency},
+				msgs: []string{
+					"Installed automatically as a dependency of cool/config to satisfy constraint >=v1.14.0, <v1.16.0, selected version v1.15.0 from 3 available tags",
+					"Installed automatically as a dependency of cool/config to satisfy constraint >=v1.14.0, selected version v1.15.0 from 1 available tags",
+				},
+			},
+		},
+		"NoCommonStream": {
+			reason: "We should report a conflict naming every dependency in a version group if they have no major.minor version in common.",
+			constraints: map[string]string{
+				"crossplane/provider-aws-s3":  ">=v1.16.0",
+				"crossplane/provider-aws-ec2": "<v1.15.0",
+			},
+			want: want{
+				events: []event.Reason{reasonVersionGroup},
+				msgs:   []string{"dependencies in version group aws have no major.minor version in common: crossplane/provider-aws-ec2 (<v1.15.0), crossplane/provider-aws-s3 (>=v1.16.0)"},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var sources []string
+			mgr := &fake.Manager{
+				Client: &test.MockClient{
+					MockGet: test.NewMockGetFn(nil, func(o client.Object) error {
+						l := o.(*v1beta1.Lock)
+						l.Packages = []v1beta1.LockPackage{{Source: "cool/config"}}
+						for _, p := range []string{"crossplane/provider-aws-s3", "crossplane/provider-aws-ec2"} {
+							l.Packages[0].Dependencies = append(l.Packages[0].Dependencies, v1beta1.Dependency{Package: p, Type: v1beta1.ProviderPackageType, Constraints: tc.constraints[p]})
+						}
+						return nil
+					}),
+					MockUpdate: test.NewMockUpdateFn(nil),
+					MockList:   test.NewMockListFn(nil),
+					MockCreate: func(_ context.Context, o client.Object, _ ...client.CreateOption) error {
+						sources = append(sources, o.(v1.Package).GetSource())
+						return nil
+					},
+					MockStatusUpdate: test.NewMockStatusUpdateFn(nil),
+				},
+			}
+			rec := &recorder{}
+			r := NewReconciler(mgr,
+				WithRecorder(rec),
+				WithVersionGroups(map[string]string{"crossplane/provider-aws-": "aws"}),
+				WithFetcher(&fakexpkg.MockFetcher{MockTags: fakexpkg.NewMockTagsFn(tags, nil)}),
+				WithNewDagFn(dag.NewMapDag),
+			)
+			_, _ = r.Reconcile(context.Background(), reconcile.Request{})
+
+			if diff := cmp.Diff(tc.want.sources, sources); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want sources, +got sources:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.events, rec.reasons); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want events, +got events:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.msgs, rec.messages); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want event messages, +got event messages:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+)
+
+const (
+	// defaultStatusBudget leaves room below etcd's default 1.5MiB request
+	// limit for the Lock's metadata to grow between passes.
+	defaultStatusBudget = 1 << 20
+
+	// maxMessageLength is the maximum length of a message in a Lock's
+	// status. Joined conditions can otherwise grow with every dependency.
+	maxMessageLength = 1024
+
+	// maxConstraintLength is the maximum length of a constraint in a Lock's
+	// status.
+	maxConstraintLength = 256
+
+	// truncationMarker ends a string that was truncated.
+	truncationMarker = "... [truncated]"
+)
+
+const (
+	errStatusTooLarge = "cannot update Lock status because the Lock is too large, even with its status compacted"
+
+	msgCompactedStatusFmt = "Lock exceeds %d bytes; compacted its status to a summary, omitting %d dependency resolutions recorded as events"
+	msgOmittedStatusFmt   = "%s dependency %s: %s"
+)
+
+// WithStatusBudget specifies the maximum size in bytes of a serialized Lock,
+// beyond which the Reconciler compacts the Lock's status to a summary and
+// records the details it omits as events. A budget of 0 means unlimited.
+func WithStatusBudget(bytes int) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.statusBudget = bytes
+	}
+}
+
+// truncate returns the supplied string, truncated to at most the supplied
+// length in bytes if it is longer. A truncated string ends with a marker, and
+// never ends mid-rune.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	cut := n - len(truncationMarker)
+	if cut < 0 {
+		cut = 0
+	}
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return s[:cut] + truncationMarker
+}
+
+// truncateStatus truncates the messages and constraints of the supplied
+// status, so that one verbose dependency can't dominate its size.
+func truncateStatus(s *v1beta1.LockStatus) {
+	for i := range s.Conditions {
+		s.Conditions[i].Message = truncate(s.Conditions[i].Message, maxMessageLength)
+	}
+	for i := range s.DependencyResolution {
+		e := &s.DependencyResolution[i]
+		e.Message = truncate(e.Message, maxMessageLength)
+		for j := range e.Constraints {
+			e.Constraints[j].Constraints = truncate(e.Constraints[j].Constraints, maxConstraintLength)
+		}
+	}
+	for i := range s.Blocked {
+		s.Blocked[i].Message = truncate(s.Blocked[i].Message, maxMessageLength)
+	}
+}
+
+// oversized returns true if the supplied Lock exceeds the Reconciler's status
+// budget once serialized.
+func (r *Reconciler) oversized(lock *v1beta1.Lock) bool {
+	if r.statusBudget <= 0 {
+		return false
+	}
+	b, err := json.Marshal(lock)
+	return err == nil && len(b) > r.statusBudget
+}
+
+// compactStatus reduces the status of the supplied Lock to a summary of its
+// conditions and counts. Each dependency resolution it omits is recorded as
+// an event instead. It returns false if the status was already compact.
+func (r *Reconciler) compactStatus(lock *v1beta1.Lock) bool {
+	s := &lock.Status
+	if len(s.DependencyResolution) == 0 && len(s.Blocked) == 0 && len(s.PendingApproval) == 0 {
+		return false
+	}
+	for _, e := range s.DependencyResolution {
+		detail := string(e.Reason)
+		if e.Message != "" {
+			detail = e.Message
+		}
+		r.record.Event(lock, event.Normal(reasonOmittedStatus, fmt.Sprintf(msgOmittedStatusFmt, e.State, e.Package, detail)))
+	}
+	omitted := s.OmittedDependencyResolutions + int64(len(s.DependencyResolution))
+	r.record.Event(lock, event.Warning(reasonLockTooLarge, errors.Errorf(msgCompactedStatusFmt, r.statusBudget, omitted)))
+	s.DependencyResolution = nil
+	s.OmittedDependencyResolutions = omitted
+	s.Blocked = nil
+	s.PendingApproval = nil
+	return true
+}
+
+// tooLarge returns true if the supplied error indicates that the API server
+// or etcd refused to store an object because it is too large.
+func tooLarge(err error) bool {
+	if err == nil {
+		return false
+	}
+	return kerrors.IsRequestEntityTooLargeError(err) || strings.Contains(err.Error(), "request is too large")
+}
@@ -0,0 +1,277 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/fake"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+	"github.com/crossplane/crossplane/internal/dag"
+	"github.com/crossplane/crossplane/internal/xpkg"
+	fakexpkg "github.com/crossplane/crossplane/internal/xpkg/fake"
+)
+
+func TestTruncate(t *testing.T) {
+	cases := map[string]struct {
+		reason string
+		s      string
+		n      int
+		want   string
+	}{
+		"Short": {
+			reason: "A string no longer than the limit should not be truncated.",
+			s:      "cool",
+			n:      4,
+			want:   "cool",
+		},
+		"Long": {
+			reason: "A string longer than the limit should be truncated to the limit, ending with a marker.",
+			s:      strings.Repeat("a", 30),
+			n:      20,
+			want:   "aaaaa" + truncationMarker,
+		},
+		"MultiByte": {
+			reason: "A string should not be truncated mid-rune.",
+			s:      "aaaa€€€€€€€€",
+			n:      23,
+			want:   "aaaa€" + truncationMarker,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := truncate(tc.s, tc.n)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\ntruncate(...): -want, +got:\n%s", tc.reason, diff)
+			}
+			if len(got) > tc.n {
+				t.Errorf("\n%s\ntruncate(...): got %d bytes, want at most %d", tc.reason, len(got), tc.n)
+			}
+		})
+	}
+}
+
+// largeLock returns a Lock with the supplied number of packages, each of which
+// depends on a distinct missing provider with a long constraint.
+func largeLock(n int) []v1beta1.LockPackage {
+	constraint := strings.Repeat(">=v0.1.0, ", 40) + "<v9.0.0"
+	pkgs := make([]v1beta1.LockPackage, n)
+	for i := range pkgs {
+		pkgs[i] = v1beta1.LockPackage{
+			Name:    fmt.Sprintf("config-%03d", i),
+			Source:  fmt.Sprintf("example.org/cool/config-%03d", i),
+			Version: "v1.0.0",
+			Dependencies: []v1beta1.Dependency{{
+				Package:     fmt.Sprintf("example.org/cool/provider-%03d", i),
+				Type:        v1beta1.ProviderPackageType,
+				Constraints: constraint,
+			}},
+		}
+	}
+	return pkgs
+}
+
+func TestReconcileLargeLock(t *testing.T) {
+	pkgs := largeLock(500)
+	spec, _ := json.Marshal(&v1beta1.Lock{Packages: pkgs})
+
+	type want struct {
+		entries int
+		omitted int64
+	}
+
+	cases := map[string]struct {
+		reason string
+		budget int
+		want   want
+	}{
+		"UnderBudget": {
+			reason: "A Lock under budget should report as many dependency resolutions as fit in its status, with truncated messages and constraints.",
+			budget: defaultStatusBudget,
+			want: want{
+				entries: v1beta1.MaxDependencyResolutions,
+				omitted: 500 - v1beta1.MaxDependencyResolutions,
+			},
+		},
+		"OverBudget": {
+			reason: "A Lock over budget should report a summary-only status, omitting every dependency resolution.",
+			budget: len(spec) + 8192,
+			want: want{
+				omitted: 500,
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var status *v1beta1.Lock
+			rec := &recorder{}
+			mgr := &fake.Manager{
+				Client: &test.MockClient{
+					MockGet: test.NewMockGetFn(nil, func(o client.Object) error {
+						o.(*v1beta1.Lock).Packages = largeLock(500)
+						return nil
+					}),
+					MockUpdate: test.NewMockUpdateFn(nil),
+					MockList:   test.NewMockListFn(nil),
+					MockStatusUpdate: test.NewMockStatusUpdateFn(nil, func(o client.Object) error {
+						status = o.(*v1beta1.Lock)
+						return nil
+					}),
+				},
+			}
+			r := NewReconciler(mgr,
+				WithStatusBudget(tc.budget),
+				WithRecorder(rec),
+				WithFetcher(&fakexpkg.MockFetcher{MockTags: fakexpkg.NewMockTagsFn(nil, errors.New(strings.Repeat("boom ", 500)))}),
+				WithNewDagFn(dag.NewMapDag),
+			)
+			if _, err := r.Reconcile(context.Background(), reconcile.Request{}); err != nil {
+				t.Fatalf("\n%s\nr.Reconcile(...): %s", tc.reason, err)
+			}
+			if status == nil {
+				t.Fatalf("\n%s\nr.Reconcile(...): expected the Lock's status to be updated", tc.reason)
+			}
+
+			b, _ := json.Marshal(status)
+			if len(b) > tc.budget {
+				t.Errorf("\n%s\nr.Reconcile(...): Lock is %d bytes, want at most %d", tc.reason, len(b), tc.budget)
+			}
+			if diff := cmp.Diff(tc.want.entries, len(status.Status.DependencyResolution)); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want entries, +got entries:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.omitted, status.Status.OmittedDependencyResolutions); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want omitted, +got omitted:\n%s", tc.reason, diff)
+			}
+			for _, c := range status.Status.Conditions {
+				if len(c.Message) > maxMessageLength {
+					t.Errorf("\n%s\nr.Reconcile(...): condition message is %d bytes, want at most %d", tc.reason, len(c.Message), maxMessageLength)
+				}
+			}
+			for _, e := range status.Status.DependencyResolution {
+				if len(e.Message) > maxMessageLength || !strings.HasSuffix(e.Message, truncationMarker) {
+					t.Errorf("\n%s\nr.Reconcile(...): expected message of %s to be truncated, got %d bytes", tc.reason, e.Package, len(e.Message))
+				}
+				for _, c := range e.Constraints {
+					if len(c.Constraints) > maxConstraintLength {
+						t.Errorf("\n%s\nr.Reconcile(...): constraint of %s is %d bytes, want at most %d", tc.reason, e.Package, len(c.Constraints), maxConstraintLength)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestUpdateStatusTooLarge(t *testing.T) {
+	errTooLarge := kerrors.NewRequestEntityTooLargeError("limit is 3145728")
+	errEtcd := kerrors.NewInternalError(errors.New("etcdserver: request is too large"))
+	errBoom := errors.New("boom")
+
+	type want struct {
+		err     error
+		writes  int
+		entries int
+		events  []event.Reason
+	}
+
+	cases := map[string]struct {
+		reason string
+		errs   []error
+		want   want
+	}{
+		"Fits": {
+			reason: "A status that fits should be written as is.",
+			errs:   []error{nil},
+			want: want{
+				writes:  1,
+				entries: 1,
+			},
+		},
+		"CompactedOnRetry": {
+			reason: "A status the API server refuses as too large should be compacted and written again.",
+			errs:   []error{errTooLarge, nil},
+			want: want{
+				writes: 2,
+				events: []event.Reason{reasonOmittedStatus, reasonLockTooLarge},
+			},
+		},
+		"StillTooLarge": {
+			reason: "We should not return an error if the Lock is too large even with its status compacted, because retrying would fail the same way.",
+			errs:   []error{errEtcd, errEtcd},
+			want: want{
+				writes: 2,
+				events: []event.Reason{reasonOmittedStatus, reasonLockTooLarge, reasonLockTooLarge},
+			},
+		},
+		"OtherError": {
+			reason: "Errors unrelated to the size of the Lock should be returned.",
+			errs:   []error{errBoom},
+			want: want{
+				err:     errBoom,
+				writes:  1,
+				entries: 1,
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			writes := 0
+			rec := &recorder{}
+			c := &test.MockClient{
+				MockStatusUpdate: func(_ context.Context, _ client.Object, _ ...client.UpdateOption) error {
+					err := tc.errs[writes]
+					writes++
+					return err
+				},
+			}
+			r := NewReconciler(&fake.Manager{Client: c}, WithRecorder(rec), WithEventWindow(0))
+
+			lock := &v1beta1.Lock{}
+			res := newResolutionResult(lock, nil, nil, r.now(), 0)
+			res.conflicted = []xpkg.Conflict{{Reason: xpkg.ConflictVersion, Package: "crossplane/provider-aws", Version: "v0.20.0"}}
+
+			err := r.updateStatus(context.Background(), lock, nil, res)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nr.updateStatus(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.writes, writes); diff != "" {
+				t.Errorf("\n%s\nr.updateStatus(...): -want writes, +got writes:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.entries, len(lock.Status.DependencyResolution)); diff != "" {
+				t.Errorf("\n%s\nr.updateStatus(...): -want entries, +got entries:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.events, rec.reasons); diff != "" {
+				t.Errorf("\n%s\nr.updateStatus(...): -want events, +got events:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
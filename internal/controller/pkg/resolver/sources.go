@@ -0,0 +1,43 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+
+	"github.com/crossplane/crossplane/internal/xpkg"
+)
+
+// WithPackageSourcePolicy specifies the repositories the Reconciler may
+// install and upgrade dependency packages from. Each pattern matches a
+// canonical repository, e.g. xpkg.upbound.io/crossplane/provider-aws, and
+// may use wildcards in any of its path segments, e.g. xpkg.upbound.io/*/*.
+// A repository must match one of the allow patterns, if there are any, and
+// none of the deny patterns. Dependencies on other repositories are reported,
+// but never installed. See xpkg.SourcePolicy.
+func WithPackageSourcePolicy(allow, deny []string) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.sources = xpkg.SourcePolicy{Allow: allow, Deny: deny}
+	}
+}
+
+// blockedByPolicy returns true if the supplied error indicates the
+// Reconciler's package source policy doesn't allow a dependency's repository.
+func blockedByPolicy(err error) bool {
+	var derr *xpkg.DependencyError
+	return errors.As(err, &derr) && derr.Reason == xpkg.DependencyBlockedByPolicy
+}
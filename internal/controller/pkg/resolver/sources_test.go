@@ -0,0 +1,220 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/fake"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	v1 "github.com/crossplane/crossplane/apis/pkg/v1"
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+	"github.com/crossplane/crossplane/internal/dag"
+	fakedag "github.com/crossplane/crossplane/internal/dag/fake"
+	fakexpkg "github.com/crossplane/crossplane/internal/xpkg/fake"
+)
+
+func TestReconcilePackageSourcePolicy(t *testing.T) {
+	type args struct {
+		allow []string
+		deny  []string
+	}
+
+	type want struct {
+		source    string
+		fetched   bool
+		condition xpv1.ConditionReason
+		events    []event.Reason
+		msgs      []string
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"Allowed": {
+			reason: "We should install a dependency from a repository the policy allows.",
+			args:   args{allow: []string{"index.docker.io/crossplane/*"}},
+			want: want{
+				source:    "crossplane/provider-aws:v0.21.0",
+				fetched:   true,
+				condition: v1beta1.ReasonMissingDependency,
+				events:    []event.Reason{reasonInstallDependency},
+				msgs:      []string{"Installed automatically as a dependency of cool/config to satisfy constraint >=v0.21.0, selected version v0.21.0 from 1 available tags"},
+			},
+		},
+		"NotAllowed": {
+			reason: "We should not install, or fetch the tags of, a dependency from a repository that matches no allow pattern.",
+			args:   args{allow: []string{"xpkg.upbound.io/**"}},
+			want: want{
+				condition: v1beta1.ReasonBlockedByPolicy,
+				events:    []event.Reason{reasonBlockedByPolicy},
+				msgs:      []string{"dependency blocked by policy: index.docker.io/crossplane/provider-aws"},
+			},
+		},
+		"Denied": {
+			reason: "We should not install a dependency from a repository that matches a deny pattern, even if it also matches an allow pattern.",
+			args: args{
+				allow: []string{"index.docker.io/**"},
+				deny:  []string{"*/crossplane/provider-*"},
+			},
+			want: want{
+				condition: v1beta1.ReasonBlockedByPolicy,
+				events:    []event.Reason{reasonBlockedByPolicy},
+				msgs:      []string{"dependency blocked by policy: index.docker.io/crossplane/provider-aws"},
+			},
+		},
+	}
+
+	for n, tc := range cases {
+		t.Run(n, func(t *testing.T) {
+			source := ""
+			var condition xpv1.ConditionReason
+			mgr := &fake.Manager{
+				Client: &test.MockClient{
+					MockGet: test.NewMockGetFn(nil, func(o client.Object) error {
+						o.(*v1beta1.Lock).Packages = []v1beta1.LockPackage{{
+							Source:       "cool/config",
+							Dependencies: []v1beta1.Dependency{{Package: "crossplane/provider-aws", Type: v1beta1.ProviderPackageType, Constraints: ">=v0.21.0"}},
+						}}
+						return nil
+					}),
+					MockUpdate: test.NewMockUpdateFn(nil),
+					MockList:   test.NewMockListFn(nil),
+					MockCreate: func(_ context.Context, o client.Object, _ ...client.CreateOption) error {
+						source = o.(v1.Package).GetSource()
+						return nil
+					},
+					MockStatusUpdate: test.NewMockStatusUpdateFn(nil, func(o client.Object) error {
+						condition = o.(*v1beta1.Lock).GetCondition(v1beta1.TypeDependenciesResolved).Reason
+						return nil
+					}),
+				},
+			}
+			fetched := false
+			rec := &recorder{}
+			r := NewReconciler(mgr,
+				WithRecorder(rec),
+				WithPackageSourcePolicy(tc.args.allow, tc.args.deny),
+				WithFetcher(&fakexpkg.MockFetcher{MockTags: func() ([]string, error) {
+					fetched = true
+					return []string{"v0.21.0"}, nil
+				}}),
+				WithNewDagFn(dag.NewMapDag),
+			)
+			_, _ = r.Reconcile(context.Background(), reconcile.Request{})
+
+			if diff := cmp.Diff(tc.want.source, source); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want source, +got source:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.fetched, fetched); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want tags fetched, +got tags fetched:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.condition, condition); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want condition, +got condition:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.events, rec.reasons); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want events, +got events:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.msgs, rec.messages); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want messages, +got messages:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestReconcileUpgradeBlockedByPolicy(t *testing.T) {
+	// A Lock in which provider-aws v0.20.0 was auto-installed, but a
+	// Configuration now requires a newer version of it.
+	mgr := &fake.Manager{
+		Client: &test.MockClient{
+			MockGet: test.NewMockGetFn(nil, func(o client.Object) error {
+				l := o.(*v1beta1.Lock)
+				l.SetName("lock")
+				l.SetAnnotations(map[string]string{AnnotationUpgradeDependencies: "true"})
+				l.Packages = []v1beta1.LockPackage{
+					{Source: "crossplane/provider-aws", Version: "v0.20.0"},
+					{Source: "cool/config", Dependencies: []v1beta1.Dependency{
+						{Package: "crossplane/provider-aws", Constraints: ">=v0.21.0"},
+					}},
+				}
+				return nil
+			}),
+			MockList: test.NewMockListFn(nil, func(o client.ObjectList) error {
+				if l, ok := o.(*v1.ProviderList); ok {
+					p := v1.Provider{ObjectMeta: metav1.ObjectMeta{Name: "crossplane-provider-aws", Labels: map[string]string{LabelAutoInstalled: "true"}}}
+					p.SetSource("crossplane/provider-aws:v0.20.0")
+					l.Items = []v1.Provider{p}
+				}
+				return nil
+			}),
+			MockUpdate: func(_ context.Context, o client.Object, _ ...client.UpdateOption) error {
+				if _, ok := o.(v1.Package); ok {
+					t.Errorf("r.Reconcile(...): upgraded package blocked by policy")
+				}
+				return nil
+			},
+			MockStatusUpdate: test.NewMockStatusUpdateFn(nil, func(o client.Object) error {
+				c := o.(*v1beta1.Lock).GetCondition(v1beta1.TypeDependenciesResolved)
+				if diff := cmp.Diff(v1beta1.ReasonBlockedByPolicy, c.Reason); diff != "" {
+					t.Errorf("r.Reconcile(...): -want condition, +got condition:\n%s", diff)
+				}
+				return nil
+			}),
+		},
+	}
+	rec := &recorder{}
+	r := NewReconciler(mgr,
+		WithUpgradeDependencies(),
+		WithRecorder(rec),
+		WithPackageSourcePolicy(nil, []string{"index.docker.io/crossplane/provider-aws"}),
+		WithNewDagFn(func() dag.DAG {
+			return &fakedag.MockDag{
+				MockInit:           func(_ []dag.Node, _ ...dag.NodeFn) ([]dag.ImpliedNode, error) { return nil, nil },
+				MockNodeDependents: func(_ string) ([]dag.Node, error) { return nil, nil },
+
+				MockSort: func() ([]string, error) { return nil, nil },
+			}
+		}),
+		WithFetcher(&fakexpkg.MockFetcher{MockTags: fakexpkg.NewMockTagsFn([]string{"v0.21.0"}, nil)}),
+	)
+	got, _ := r.Reconcile(context.Background(), reconcile.Request{})
+
+	// Only an operator can unblock the upgrade, so there's no point
+	// requeueing early.
+	if diff := cmp.Diff(reconcile.Result{}, got); diff != "" {
+		t.Errorf("r.Reconcile(...): -want, +got:\n%s", diff)
+	}
+	want := []event.Reason{reasonVersionConflict, reasonBlockedByPolicy}
+	if diff := cmp.Diff(want, rec.reasons); diff != "" {
+		t.Errorf("r.Reconcile(...): -want events, +got events:\n%s", diff)
+	}
+	msg := "dependency blocked by policy: index.docker.io/crossplane/provider-aws"
+	if diff := cmp.Diff(msg, rec.messages[len(rec.messages)-1]); diff != "" {
+		t.Errorf("r.Reconcile(...): -want message, +got message:\n%s", diff)
+	}
+}
@@ -0,0 +1,208 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/resource"
+
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+	"github.com/crossplane/crossplane/internal/xpkg"
+)
+
+// statusTimeout bounds how long we wait to update the status of a Lock once
+// the reconcile that decided it has been cancelled.
+const statusTimeout = 10 * time.Second
+
+// A detachedContext carries the values of its parent context, but is never
+// cancelled when its parent is.
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}       { return nil }
+func (detachedContext) Err() error                  { return nil }
+
+func (c detachedContext) Value(key interface{}) interface{} {
+	return c.parent.Value(key)
+}
+
+// updateStatus updates the status of the supplied Lock, replacing its
+// dependency resolution status with the supplied result. The status is
+// updated even if the supplied context was cancelled, e.g. because Crossplane
+// is shutting down, so that packages we created before we were cancelled are
+// recorded. It is not updated if it is unchanged from the supplied observed
+// status.
+//
+// A Lock with hundreds of packages can approach the limit on the size of an
+// object, so the status is compacted to a summary if the Lock would exceed
+// the Reconciler's status budget, or if the API server refuses it as too
+// large. Retrying a Lock that is too large even with its status compacted
+// would fail the same way, so we record an event rather than return an error.
+func (r *Reconciler) updateStatus(ctx context.Context, lock *v1beta1.Lock, observed *v1beta1.LockStatus, res *resolutionResult) error {
+	unsatisfied(lock)
+	lock.Status.DependencyResolution, lock.Status.OmittedDependencyResolutions = res.status()
+	truncateStatus(&lock.Status)
+	if r.oversized(lock) {
+		r.compactStatus(lock)
+	}
+
+	ctx, cancel := context.WithTimeout(detachedContext{parent: ctx}, statusTimeout)
+	defer cancel()
+	err := r.status.Write(ctx, lock, observed)
+	if tooLarge(err) && r.compactStatus(lock) {
+		err = r.status.Write(ctx, lock, observed)
+	}
+	if tooLarge(err) {
+		r.record.Event(lock, event.Warning(reasonLockTooLarge, errors.Wrap(err, errStatusTooLarge)))
+		return nil
+	}
+	return err
+}
+
+// A statusWriter updates the status of a Lock only when it has changed. The
+// Lock is reconciled whenever any package revision changes, and most passes
+// change nothing, so writing unconditionally would cause a status update per
+// pass.
+type statusWriter struct {
+	client client.StatusClient
+}
+
+// Write updates the status of the supplied Lock, unless it is semantically
+// identical to the supplied status observed before it was reconciled. The
+// times at which conditions transitioned and dependencies were attempted are
+// ignored, because they change on every pass. A Lock that was deleted while
+// it was reconciled has no status to update.
+func (w statusWriter) Write(ctx context.Context, lock *v1beta1.Lock, observed *v1beta1.LockStatus) error {
+	if observed != nil && equality.Semantic.DeepEqual(withoutTimestamps(observed), withoutTimestamps(&lock.Status)) {
+		return nil
+	}
+	return resource.IgnoreNotFound(w.client.Status().Update(ctx, lock))
+}
+
+// withoutTimestamps returns a copy of the supplied status without the times
+// at which conditions transitioned and dependencies were attempted.
+func withoutTimestamps(s *v1beta1.LockStatus) *v1beta1.LockStatus {
+	out := s.DeepCopy()
+	for i := range out.Conditions {
+		out.Conditions[i].LastTransitionTime = metav1.Time{}
+	}
+	for i := range out.DependencyResolution {
+		out.DependencyResolution[i].LastAttemptTime = nil
+	}
+	return out
+}
+
+// status returns the missing, conflicting, and unsatisfied optional
+// dependencies of the result as the dependency resolution status of a Lock,
+// sorted by package. It returns at most v1beta1.MaxDependencyResolutions
+// entries, and the number of entries that were omitted.
+func (res *resolutionResult) status() ([]v1beta1.DependencyResolution, int64) {
+	entries := make([]v1beta1.DependencyResolution, 0, len(res.decisions)+len(res.conflicted)+len(res.optional))
+	for i, d := range res.decisions {
+		e := v1beta1.DependencyResolution{
+			Package:         d.Dependency,
+			State:           v1beta1.DependencyMissing,
+			Constraints:     dependents(res.plan[i].Requirements),
+			LastAttemptTime: res.attempted(),
+			Reason:          res.failures[i].Reason,
+			Message:         res.failures[i].Message,
+			Version:         d.Version,
+			NextRetryTime:   res.retries[i],
+		}
+		switch d.Outcome {
+		case outcomeBlocked:
+			e.Reason = v1beta1.ReasonBlocked
+		case outcomePending:
+			e.Reason = v1beta1.ReasonAwaitingApproval
+		case outcomePlanned:
+			e.Message = msgPlannedStatus
+		}
+		entries = append(entries, e)
+	}
+	for _, c := range res.conflicted {
+		reason := v1beta1.ReasonVersionConflict
+		if c.Reason == xpkg.ConflictType {
+			reason = v1beta1.ReasonTypeConflict
+		}
+		entries = append(entries, v1beta1.DependencyResolution{
+			Package:         c.Package,
+			State:           v1beta1.DependencyConflicting,
+			Constraints:     dependents(c.Requirements),
+			LastAttemptTime: res.attempted(),
+			Reason:          reason,
+			Message:         c.Error(),
+			Version:         c.Version,
+		})
+	}
+	for _, o := range res.optional {
+		entries = append(entries, v1beta1.DependencyResolution{
+			Package:         o.Package,
+			State:           v1beta1.DependencyOptional,
+			Constraints:     dependents(o.Requirements),
+			LastAttemptTime: res.attempted(),
+			Message:         o.Message(),
+			Version:         o.Version,
+		})
+	}
+
+	// Entries are sorted so that the status only changes when the
+	// dependencies do.
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].Package != entries[j].Package {
+			return entries[i].Package < entries[j].Package
+		}
+		return entries[i].State < entries[j].State
+	})
+
+	if len(entries) > v1beta1.MaxDependencyResolutions {
+		return entries[:v1beta1.MaxDependencyResolutions], int64(len(entries) - v1beta1.MaxDependencyResolutions)
+	}
+	if len(entries) == 0 {
+		return nil, 0
+	}
+	return entries, 0
+}
+
+// attempted returns the time at which resolution was attempted.
+func (res *resolutionResult) attempted() *metav1.Time {
+	t := metav1.NewTime(res.started)
+	return &t
+}
+
+// dependents returns the supplied requirements as the constraints packages
+// place on a dependency.
+func dependents(reqs []xpkg.Requirement) []v1beta1.DependentConstraint {
+	if len(reqs) == 0 {
+		return nil
+	}
+	cs := make([]v1beta1.DependentConstraint, len(reqs))
+	for i, rq := range reqs {
+		cs[i] = v1beta1.DependentConstraint{Dependent: rq.Parent, Constraints: rq.Constraint}
+	}
+	return cs
+}
@@ -0,0 +1,322 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/fake"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+	"github.com/crossplane/crossplane/internal/dag"
+	"github.com/crossplane/crossplane/internal/xpkg"
+	fakexpkg "github.com/crossplane/crossplane/internal/xpkg/fake"
+)
+
+func TestReconcileDependencyResolution(t *testing.T) {
+	now := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	at := metav1.NewTime(now)
+	retry := metav1.NewTime(now.Add(defaultUnresolvedRetryInterval))
+	aws := func(c string) v1beta1.Dependency {
+		return v1beta1.Dependency{Package: "crossplane/provider-aws", Type: v1beta1.ProviderPackageType, Constraints: c}
+	}
+	gcp := func(c string) v1beta1.Dependency {
+		return v1beta1.Dependency{Package: "crossplane/provider-gcp", Type: v1beta1.ProviderPackageType, Constraints: c}
+	}
+
+	type want struct {
+		resolution []v1beta1.DependencyResolution
+		omitted    int64
+	}
+
+	cases := map[string]struct {
+		reason string
+		pkgs   []v1beta1.LockPackage
+		want   want
+	}{
+		"Resolved": {
+			reason: "We should not report any dependencies if every dependency is satisfied.",
+			pkgs: []v1beta1.LockPackage{
+				{Source: "cool/config", Dependencies: []v1beta1.Dependency{aws(">=v0.20.0")}},
+				{Source: "crossplane/provider-aws", Type: v1beta1.ProviderPackageType, Version: "v0.21.0"},
+			},
+		},
+		"MissingAndConflicting": {
+			reason: "We should report the version we installed for a missing dependency, and why an installed dependency conflicts.",
+			pkgs: []v1beta1.LockPackage{
+				{Source: "cool/config", Dependencies: []v1beta1.Dependency{gcp(">=v0.20.0"), aws(">=v0.21.0")}},
+				{Source: "crossplane/provider-aws", Type: v1beta1.ProviderPackageType, Version: "v0.20.0"},
+			},
+			want: want{resolution: []v1beta1.DependencyResolution{
+				{
+					Package:         "crossplane/provider-aws",
+					State:           v1beta1.DependencyConflicting,
+					Constraints:     []v1beta1.DependentConstraint{{Dependent: "cool/config", Constraints: ">=v0.21.0"}},
+					LastAttemptTime: &at,
+					Reason:          v1beta1.ReasonVersionConflict,
+					Message:         xpkg.Conflict{Reason: xpkg.ConflictVersion, Package: "crossplane/provider-aws", Version: "v0.20.0", Requirements: []xpkg.Requirement{{Parent: "cool/config", Constraint: ">=v0.21.0"}}}.Error(),
+					Version:         "v0.20.0",
+				},
+				{
+					Package:         "crossplane/provider-gcp",
+					State:           v1beta1.DependencyMissing,
+					Constraints:     []v1beta1.DependentConstraint{{Dependent: "cool/config", Constraints: ">=v0.20.0"}},
+					LastAttemptTime: &at,
+					Version:         "v0.21.0",
+				},
+			}},
+		},
+		"SharedUnsatisfiable": {
+			reason: "We should report the constraint each dependent places on a missing dependency that no version satisfies.",
+			pkgs: []v1beta1.LockPackage{
+				{Source: "cool/config-a", Dependencies: []v1beta1.Dependency{aws("<v0.21.0")}},
+				{Source: "cool/config-b", Dependencies: []v1beta1.Dependency{aws(">=v0.21.0")}},
+			},
+			want: want{resolution: []v1beta1.DependencyResolution{{
+				Package: "crossplane/provider-aws",
+				State:   v1beta1.DependencyMissing,
+				Constraints: []v1beta1.DependentConstraint{
+					{Dependent: "cool/config-a", Constraints: "<v0.21.0"},
+					{Dependent: "cool/config-b", Constraints: ">=v0.21.0"},
+				},
+				LastAttemptTime: &at,
+				Reason:          v1beta1.ReasonNoValidVersion,
+				Message:         `cannot find a valid version for package constraints: no version of dependency (crossplane/provider-aws) satisfies every package that depends on it: "<v0.21.0" required by cool/config-a, ">=v0.21.0" required by cool/config-b: highest available version is v0.21.0; "<v0.21.0" allows only v0.20.0, ">=v0.21.0" allows only v0.21.0; changing the constraint of cool/config-a to allow v0.21.0, or of cool/config-b to allow v0.20.0 would be compatible with every other package that depends on it`,
+				NextRetryTime:   &retry,
+			}}},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := &v1beta1.Lock{}
+			mgr := &fake.Manager{
+				Client: &test.MockClient{
+					MockGet: test.NewMockGetFn(nil, func(o client.Object) error {
+						l := o.(*v1beta1.Lock)
+						l.SetFinalizers([]string{finalizer})
+						l.Packages = tc.pkgs
+
+						// The previous pass's status must be replaced.
+						l.Status.DependencyResolution = []v1beta1.DependencyResolution{{Package: "crossplane/provider-stale"}}
+						l.Status.OmittedDependencyResolutions = 3
+						return nil
+					}),
+					MockList:   test.NewMockListFn(nil),
+					MockCreate: test.NewMockCreateFn(nil),
+					MockStatusUpdate: test.NewMockStatusUpdateFn(nil, func(o client.Object) error {
+						got = o.(*v1beta1.Lock)
+						return nil
+					}),
+				},
+			}
+			r := NewReconciler(mgr,
+				WithRecorder(&recorder{}),
+				WithFetcher(&fakexpkg.MockFetcher{MockTags: fakexpkg.NewMockTagsFn([]string{"v0.20.0", "v0.21.0"}, nil)}),
+				WithNewDagFn(dag.NewMapDag),
+			)
+			r.now = func() time.Time { return now }
+
+			if _, err := r.Reconcile(context.Background(), reconcile.Request{}); err != nil {
+				t.Fatalf("\n%s\nr.Reconcile(...): %s", tc.reason, err)
+			}
+			if diff := cmp.Diff(tc.want.resolution, got.Status.DependencyResolution); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want resolution, +got resolution:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.omitted, got.Status.OmittedDependencyResolutions); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want omitted, +got omitted:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestResolutionStatusBounded(t *testing.T) {
+	n := v1beta1.MaxDependencyResolutions + 2
+	plan := make([]xpkg.PlannedInstall, n)
+	for i := range plan {
+		// Dependencies are planned in reverse order so that we can tell
+		// the entries we keep are sorted.
+		plan[i] = xpkg.PlannedInstall{Dependency: v1beta1.Dependency{Package: fmt.Sprintf("crossplane/provider-%03d", n-i)}}
+	}
+	res := newResolutionResult(&v1beta1.Lock{}, plan, nil, time.Now(), 0)
+
+	entries, omitted := res.status()
+	if diff := cmp.Diff(v1beta1.MaxDependencyResolutions, len(entries)); diff != "" {
+		t.Errorf("res.status(): -want entries, +got entries:\n%s", diff)
+	}
+	if diff := cmp.Diff(int64(2), omitted); diff != "" {
+		t.Errorf("res.status(): -want omitted, +got omitted:\n%s", diff)
+	}
+	if diff := cmp.Diff("crossplane/provider-001", entries[0].Package); diff != "" {
+		t.Errorf("res.status(): -want first, +got first:\n%s", diff)
+	}
+}
+
+func TestStatusWriterWrite(t *testing.T) {
+	earlier := metav1.NewTime(time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC))
+	later := metav1.NewTime(earlier.Add(time.Minute))
+
+	status := func(at metav1.Time, reason xpv1.ConditionReason) *v1beta1.LockStatus {
+		s := &v1beta1.LockStatus{
+			MissingDependencies: 1,
+			DependencyResolution: []v1beta1.DependencyResolution{{
+				Package:         "crossplane/provider-aws",
+				State:           v1beta1.DependencyMissing,
+				LastAttemptTime: &at,
+				Reason:          reason,
+			}},
+		}
+		c := v1beta1.MissingDependency()
+		c.Reason = reason
+		c.LastTransitionTime = at
+		s.SetConditions(c)
+		return s
+	}
+
+	cases := map[string]struct {
+		reason   string
+		observed *v1beta1.LockStatus
+		desired  *v1beta1.LockStatus
+		want     int
+	}{
+		"Unchanged": {
+			reason:   "We should not update a status that is identical to the observed status.",
+			observed: status(earlier, v1beta1.ReasonFetchError),
+			desired:  status(earlier, v1beta1.ReasonFetchError),
+			want:     0,
+		},
+		"TimestampsChanged": {
+			reason:   "We should not update a status that differs from the observed status only in its timestamps.",
+			observed: status(earlier, v1beta1.ReasonFetchError),
+			desired:  status(later, v1beta1.ReasonFetchError),
+			want:     0,
+		},
+		"EmptyAndNil": {
+			reason:   "We should treat empty and absent dependency resolutions as identical.",
+			observed: &v1beta1.LockStatus{},
+			desired:  &v1beta1.LockStatus{DependencyResolution: []v1beta1.DependencyResolution{}},
+			want:     0,
+		},
+		"Changed": {
+			reason:   "We should update a status that differs from the observed status.",
+			observed: status(earlier, v1beta1.ReasonFetchError),
+			desired:  status(later, v1beta1.ReasonNoValidVersion),
+			want:     1,
+		},
+		"NotObserved": {
+			reason:  "We should update the status if we don't know what was observed.",
+			desired: status(earlier, v1beta1.ReasonFetchError),
+			want:    1,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			writes := 0
+			w := statusWriter{client: &test.MockClient{
+				MockStatusUpdate: func(_ context.Context, _ client.Object, _ ...client.UpdateOption) error {
+					writes++
+					return nil
+				},
+			}}
+			lock := &v1beta1.Lock{Status: *tc.desired}
+			if err := w.Write(context.Background(), lock, tc.observed); err != nil {
+				t.Fatalf("\n%s\nw.Write(...): %s", tc.reason, err)
+			}
+			if diff := cmp.Diff(tc.want, writes); diff != "" {
+				t.Errorf("\n%s\nw.Write(...): -want writes, +got writes:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestReconcileStatusWrites(t *testing.T) {
+	errBoom := errors.New("boom")
+
+	stored := &v1beta1.Lock{}
+	stored.Packages = []v1beta1.LockPackage{{
+		Source: "cool/config",
+		Dependencies: []v1beta1.Dependency{
+			{Package: "crossplane/provider-aws", Type: v1beta1.ProviderPackageType, Constraints: ">=v0.20.0"},
+		},
+	}}
+
+	writes := 0
+	mgr := &fake.Manager{
+		Client: &test.MockClient{
+			MockGet: test.NewMockGetFn(nil, func(o client.Object) error {
+				if l, ok := o.(*v1beta1.Lock); ok {
+					stored.DeepCopyInto(l)
+					return nil
+				}
+				return kerrors.NewNotFound(schema.GroupResource{}, o.GetName())
+			}),
+			MockList:   test.NewMockListFn(nil),
+			MockCreate: test.NewMockCreateFn(nil),
+			MockUpdate: test.NewMockUpdateFn(nil),
+			MockStatusUpdate: func(_ context.Context, o client.Object, _ ...client.UpdateOption) error {
+				writes++
+				o.(*v1beta1.Lock).Status.DeepCopyInto(&stored.Status)
+				return nil
+			},
+		},
+	}
+
+	now := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	reconcileOnce := func(step string, fetch error) int {
+		writes = 0
+		r := NewReconciler(mgr,
+			WithFetcher(&fakexpkg.MockFetcher{MockTags: fakexpkg.NewMockTagsFn([]string{"v0.20.0"}, fetch)}),
+			WithNewDagFn(dag.NewMapDag),
+		)
+		r.now = func() time.Time { return now }
+		if _, err := r.Reconcile(context.Background(), reconcile.Request{}); err != nil {
+			t.Fatalf("%s: r.Reconcile(...): %s", step, err)
+		}
+		return writes
+	}
+
+	// The first pass records that we could not fetch the dependency's tags.
+	if diff := cmp.Diff(1, reconcileOnce("First", errBoom)); diff != "" {
+		t.Errorf("First: r.Reconcile(...): -want writes, +got writes:\n%s", diff)
+	}
+
+	// A later pass that makes the same decision doesn't write the status,
+	// even though it attempted the dependency at a different time.
+	now = now.Add(time.Minute)
+	if diff := cmp.Diff(0, reconcileOnce("Unchanged", errBoom)); diff != "" {
+		t.Errorf("Unchanged: r.Reconcile(...): -want writes, +got writes:\n%s", diff)
+	}
+
+	// A pass that makes a different decision writes the status once.
+	if diff := cmp.Diff(1, reconcileOnce("Changed", nil)); diff != "" {
+		t.Errorf("Changed: r.Reconcile(...): -want writes, +got writes:\n%s", diff)
+	}
+}
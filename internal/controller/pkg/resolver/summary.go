@@ -0,0 +1,217 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+	"github.com/crossplane/crossplane/internal/xpkg"
+)
+
+const msgResolutionSummary = "Resolved dependencies"
+
+// Outcomes of a missing dependency that aren't the reason of a condition.
+const (
+	outcomeCreated  = "Created"
+	outcomeExists   = "Exists"
+	outcomeSkipped  = "Skipped"
+	outcomeBlocked  = "Blocked"
+	outcomePending  = "PendingApproval"
+	outcomeDeferred = "Deferred"
+	outcomePlanned  = "Planned"
+)
+
+// A dependencyDecision records what the Reconciler decided to do about a
+// missing dependency. It never includes the tags of the dependency's
+// repository, only how many versions were considered.
+type dependencyDecision struct {
+	Dependency  string   `json:"dependency"`
+	Constraints []string `json:"constraints"`
+	Candidates  int      `json:"candidates"`
+	Version     string   `json:"version,omitempty"`
+	Package     string   `json:"package,omitempty"`
+	Outcome     string   `json:"outcome"`
+}
+
+// A resolutionResult accumulates what happened during a single pass of the
+// Reconciler, so that it can be logged as one summary.
+type resolutionResult struct {
+	satisfied int
+	missing   int
+	conflicts int
+	edgeState map[xpkg.EdgeState]int
+	started   time.Time
+	fetch     time.Duration
+	decisions []dependencyDecision
+	index     map[string]int
+	order     []string
+
+	// Reported in the Lock's status rather than logged. Each failure is
+	// the condition describing why the decision at the same index failed.
+	plan       []xpkg.PlannedInstall
+	failures   []xpv1.Condition
+	retries    []*metav1.Time
+	conflicted []xpkg.Conflict
+	optional   []xpkg.OptionalDependency
+
+	// Why dependencies we installed couldn't be checked first.
+	unknown []string
+}
+
+// newResolutionResult returns a result for the supplied Lock, whose missing
+// dependencies were planned as supplied at the supplied time, and whose
+// installed packages conflict as supplied. Optional dependencies are never
+// counted as satisfied or missing.
+func newResolutionResult(lock *v1beta1.Lock, plan []xpkg.PlannedInstall, conflicts []xpkg.Conflict, started time.Time, fetch time.Duration) *resolutionResult {
+	deps := map[string]bool{}
+	for _, p := range lock.Packages {
+		for _, d := range p.Dependencies {
+			if !d.Optional {
+				deps[d.Identifier()] = true
+			}
+		}
+	}
+
+	res := &resolutionResult{
+		satisfied:  len(deps) - len(plan),
+		missing:    len(plan),
+		conflicts:  len(conflicts),
+		started:    started,
+		fetch:      fetch,
+		decisions:  make([]dependencyDecision, len(plan)),
+		index:      make(map[string]int, len(plan)),
+		plan:       plan,
+		failures:   make([]xpv1.Condition, len(plan)),
+		retries:    make([]*metav1.Time, len(plan)),
+		conflicted: conflicts,
+	}
+	if res.satisfied < 0 {
+		res.satisfied = 0
+	}
+	for i, p := range plan {
+		constraints := make([]string, len(p.Requirements))
+		for j, rq := range p.Requirements {
+			constraints[j] = rq.Constraint
+		}
+		res.decisions[i] = dependencyDecision{
+			Dependency:  p.Dependency.Package,
+			Constraints: constraints,
+			Candidates:  p.Candidates,
+			Version:     p.Version,
+		}
+		res.index[p.Dependency.Package] = i
+	}
+	return res
+}
+
+// edges records how many edges of the dependency graph were satisfied,
+// violated, and unverifiable.
+func (res *resolutionResult) edges(r xpkg.EdgeReport) {
+	res.edgeState = map[xpkg.EdgeState]int{
+		xpkg.EdgeSatisfied:    len(r.Satisfied),
+		xpkg.EdgeViolated:     len(r.Violated),
+		xpkg.EdgeUnverifiable: len(r.Unverifiable),
+	}
+}
+
+// ordered records the order in which the supplied planned installs are
+// created.
+func (res *resolutionResult) ordered(plan []xpkg.PlannedInstall) {
+	res.order = make([]string, len(plan))
+	for i, p := range plan {
+		res.order[i] = p.Dependency.Package
+	}
+}
+
+// decide records the outcome of the supplied missing dependency, and the
+// name of the package that satisfies it, if any.
+func (res *resolutionResult) decide(dependency, outcome, pkg string) {
+	i, ok := res.index[dependency]
+	if !ok {
+		return
+	}
+	res.decisions[i].Outcome = outcome
+	res.decisions[i].Package = pkg
+}
+
+// fail records that the supplied missing dependency could not be installed,
+// for the reason and with the message of the supplied condition.
+func (res *resolutionResult) fail(dependency string, c xpv1.Condition) {
+	i, ok := res.index[dependency]
+	if !ok {
+		return
+	}
+	res.decisions[i].Outcome = string(c.Reason)
+	res.decisions[i].Package = ""
+	res.failures[i] = c
+}
+
+// unchecked records that a dependency was installed without being checked,
+// for the supplied reason.
+func (res *resolutionResult) unchecked(err error) {
+	res.unknown = append(res.unknown, err.Error())
+}
+
+// outcome returns the recorded outcome of the supplied missing dependency, or
+// an empty string if none has been recorded.
+func (res *resolutionResult) outcome(dependency string) string {
+	i, ok := res.index[dependency]
+	if !ok {
+		return ""
+	}
+	return res.decisions[i].Outcome
+}
+
+// decideAll records the supplied outcome for every missing dependency whose
+// outcome isn't yet known.
+func (res *resolutionResult) decideAll(outcome string) {
+	for i := range res.decisions {
+		if res.decisions[i].Outcome == "" {
+			res.decisions[i].Outcome = outcome
+		}
+	}
+}
+
+// log the result as a single structured message, along with the reason of
+// the supplied Lock's DependenciesResolved condition.
+func (res *resolutionResult) log(log logging.Logger, lock *v1beta1.Lock) {
+	created := 0
+	for _, d := range res.decisions {
+		if d.Outcome == outcomeCreated {
+			created++
+		}
+	}
+	log.Info(msgResolutionSummary,
+		"satisfied", res.satisfied,
+		"missing", res.missing,
+		"conflicts", res.conflicts,
+		"satisfied-edges", res.edgeState[xpkg.EdgeSatisfied],
+		"violated-edges", res.edgeState[xpkg.EdgeViolated],
+		"unverifiable-edges", res.edgeState[xpkg.EdgeUnverifiable],
+		"created", created,
+		"fetch-duration", res.fetch.String(),
+		"condition", string(lock.GetCondition(v1beta1.TypeDependenciesResolved).Reason),
+		"decisions", res.decisions,
+		"install-order", res.order,
+	)
+}
@@ -0,0 +1,182 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/fake"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+	"github.com/crossplane/crossplane/internal/dag"
+	fakexpkg "github.com/crossplane/crossplane/internal/xpkg/fake"
+)
+
+// A capturingLogger captures the structured data of the Info messages it
+// logs, keyed by message.
+type capturingLogger struct {
+	infos map[string][]map[string]interface{}
+}
+
+func (l *capturingLogger) Info(msg string, keysAndValues ...interface{}) {
+	kv := map[string]interface{}{}
+	for i := 0; i+1 < len(keysAndValues); i += 2 {
+		kv[keysAndValues[i].(string)] = keysAndValues[i+1]
+	}
+	l.infos[msg] = append(l.infos[msg], kv)
+}
+
+func (l *capturingLogger) Debug(_ string, _ ...interface{}) {}
+
+func (l *capturingLogger) WithValues(_ ...interface{}) logging.Logger { return l }
+
+func TestReconcileSummary(t *testing.T) {
+	aws := v1beta1.Dependency{Package: "crossplane/provider-aws", Type: v1beta1.ProviderPackageType, Constraints: ">=v0.20.0"}
+	gcp := v1beta1.Dependency{Package: "crossplane/provider-gcp", Type: v1beta1.ProviderPackageType, Constraints: ">=v1.0.0"}
+
+	type args struct {
+		pkgs []v1beta1.LockPackage
+		skip bool
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   []map[string]interface{}
+	}{
+		"Created": {
+			reason: "We should summarize the version we selected and the package we created for a missing dependency.",
+			args: args{
+				pkgs: []v1beta1.LockPackage{{Source: "cool/config", Dependencies: []v1beta1.Dependency{aws}}},
+			},
+			want: []map[string]interface{}{{
+				"satisfied":          0,
+				"satisfied-edges":    0,
+				"violated-edges":     0,
+				"unverifiable-edges": 0,
+				"missing":            1,
+				"conflicts":          0,
+				"created":            1,
+				"fetch-duration":     "0s",
+				"condition":          string(v1beta1.ReasonMissingDependency),
+				"decisions": []dependencyDecision{{
+					Dependency:  "crossplane/provider-aws",
+					Constraints: []string{">=v0.20.0"},
+					Candidates:  2,
+					Version:     "v0.21.0",
+					Package:     "crossplane-provider-aws",
+					Outcome:     outcomeCreated,
+				}},
+				"install-order": []string{"crossplane/provider-aws"},
+			}},
+		},
+		"SatisfiedAndUnresolvable": {
+			reason: "We should summarize satisfied dependencies, and why a missing dependency could not be installed.",
+			args: args{
+				pkgs: []v1beta1.LockPackage{
+					{Source: "cool/config", Dependencies: []v1beta1.Dependency{aws, gcp}},
+					{Source: "crossplane/provider-aws", Version: "v0.20.0"},
+				},
+			},
+			want: []map[string]interface{}{{
+				"satisfied":          1,
+				"satisfied-edges":    1,
+				"violated-edges":     0,
+				"unverifiable-edges": 0,
+				"missing":            1,
+				"conflicts":          0,
+				"created":            0,
+				"fetch-duration":     "0s",
+				"condition":          string(v1beta1.ReasonNoValidVersion),
+				"decisions": []dependencyDecision{{
+					Dependency:  "crossplane/provider-gcp",
+					Constraints: []string{">=v1.0.0"},
+					Candidates:  2,
+					Outcome:     string(v1beta1.ReasonNoValidVersion),
+				}},
+				"install-order": []string{"crossplane/provider-gcp"},
+			}},
+		},
+		"Skipped": {
+			reason: "We should summarize missing dependencies that we skipped installing.",
+			args: args{
+				pkgs: []v1beta1.LockPackage{{Source: "cool/config", Dependencies: []v1beta1.Dependency{aws}}},
+				skip: true,
+			},
+			want: []map[string]interface{}{{
+				"satisfied":          0,
+				"satisfied-edges":    0,
+				"violated-edges":     0,
+				"unverifiable-edges": 0,
+				"missing":            1,
+				"conflicts":          0,
+				"created":            0,
+				"fetch-duration":     "0s",
+				"condition":          string(v1beta1.ReasonMissingDependency),
+				"decisions": []dependencyDecision{{
+					Dependency:  "crossplane/provider-aws",
+					Constraints: []string{">=v0.20.0"},
+					Outcome:     outcomeSkipped,
+				}},
+				"install-order": []string(nil),
+			}},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			log := &capturingLogger{infos: map[string][]map[string]interface{}{}}
+			mgr := &fake.Manager{
+				Client: &test.MockClient{
+					MockGet: test.NewMockGetFn(nil, func(o client.Object) error {
+						l := o.(*v1beta1.Lock)
+						l.Packages = tc.args.pkgs
+						l.Spec.SkipDependencyInstallation = tc.args.skip
+						return nil
+					}),
+					MockList:         test.NewMockListFn(nil),
+					MockCreate:       test.NewMockCreateFn(nil),
+					MockUpdate:       test.NewMockUpdateFn(nil),
+					MockStatusUpdate: test.NewMockStatusUpdateFn(nil),
+				},
+			}
+			r := NewReconciler(mgr,
+				WithLogger(log),
+				WithRecorder(&recorder{}),
+				WithFetcher(&fakexpkg.MockFetcher{MockTags: fakexpkg.NewMockTagsFn([]string{"v0.20.0", "v0.21.0"}, nil)}),
+				WithNewDagFn(dag.NewMapDag),
+			)
+			now := time.Now()
+			r.now = func() time.Time { return now }
+
+			if _, err := r.Reconcile(context.Background(), reconcile.Request{}); err != nil {
+				t.Fatalf("\n%s\nr.Reconcile(...): %s", tc.reason, err)
+			}
+			if diff := cmp.Diff(tc.want, log.infos[msgResolutionSummary]); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want summary, +got summary:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,237 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+
+	"github.com/crossplane/crossplane/internal/xpkg"
+)
+
+const (
+	// defaultTagCacheTTL is how long the tags of a repository are cached by
+	// default.
+	defaultTagCacheTTL = 5 * time.Minute
+
+	// defaultMaxConcurrentFetches is the maximum number of repositories whose
+	// tags are fetched at once by default.
+	defaultMaxConcurrentFetches = 4
+
+	// defaultFetchTimeout is how long we wait for the tags of a repository,
+	// or to create a package, by default. It's a fraction of the reconcile
+	// timeout so that a hung registry or API server connection leaves time
+	// to install the dependencies whose tags we did fetch.
+	defaultFetchTimeout = 10 * time.Second
+
+	// defaultBreakerThreshold is the number of consecutive failures to fetch
+	// tags from a registry host after which Setup stops fetching from it.
+	defaultBreakerThreshold = 3
+
+	// defaultBreakerCooldown is how long Setup stops fetching tags from a
+	// registry host once its breaker opens.
+	defaultBreakerCooldown = 2 * time.Minute
+)
+
+// WithFetchTimeout specifies how long the Reconciler should wait for the tags
+// of each dependency's repository, and for each dependency package to be
+// created. A dependency whose tags can't be fetched in time is retried, and
+// doesn't prevent other dependencies from being installed. A timeout of zero
+// means each operation may use the remainder of the reconcile timeout.
+func WithFetchTimeout(d time.Duration) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.timeout = d
+	}
+}
+
+// WithRegistryCircuitBreaker specifies that the Reconciler should stop
+// fetching tags from a registry host for the supplied cooldown period once
+// the supplied number of consecutive fetches from it fail. Dependencies that
+// are hosted by an unavailable registry are reported as such, along with when
+// they'll next be fetched. A threshold of zero disables the breaker, which is
+// the default.
+func WithRegistryCircuitBreaker(threshold int, cooldown time.Duration) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.breakerThreshold = threshold
+		r.breakerCooldown = cooldown
+	}
+}
+
+// withTimeout returns a context derived from the supplied one that is
+// cancelled once the Reconciler's per-operation timeout expires.
+func (r *Reconciler) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if r.timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, r.timeout)
+}
+
+// WithTagCacheTTL specifies how long the Reconciler should cache the tags of
+// a dependency's repository. A TTL of zero disables caching.
+func WithTagCacheTTL(ttl time.Duration) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.tags = newTagCache(ttl)
+	}
+}
+
+type tagCacheEntry struct {
+	tags    []string
+	expires time.Time
+}
+
+// A tagCache caches the tags of repositories for a fixed TTL. It is safe for
+// concurrent use.
+type tagCache struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mu      sync.RWMutex
+	entries map[string]tagCacheEntry
+}
+
+func newTagCache(ttl time.Duration) *tagCache {
+	return &tagCache{ttl: ttl, now: time.Now, entries: map[string]tagCacheEntry{}}
+}
+
+// Get the cached tags of the supplied repository, if any.
+func (c *tagCache) Get(repo string) ([]string, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	e, ok := c.entries[repo]
+	if !ok || !c.now().Before(e.expires) {
+		return nil, false
+	}
+	return e.tags, true
+}
+
+// Set the cached tags of the supplied repository.
+func (c *tagCache) Set(repo string, tags []string) {
+	if c.ttl <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Prune expired entries so that repositories we no longer depend on
+	// don't accumulate.
+	now := c.now()
+	for k, e := range c.entries {
+		if !now.Before(e.expires) {
+			delete(c.entries, k)
+		}
+	}
+	c.entries[repo] = tagCacheEntry{tags: tags, expires: now.Add(c.ttl)}
+}
+
+// Invalidate the cached tags of the supplied repository.
+func (c *tagCache) Invalidate(repo string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, repo)
+}
+
+// repository returns the fully qualified repository of the supplied
+// reference, which is used to key the tag cache.
+func repository(ref name.Reference) string {
+	return ref.Context().RegistryStr() + "/" + ref.Context().RepositoryStr()
+}
+
+// WithTagFilter specifies how the Reconciler should filter the tags of a
+// dependency's repository before checking them against its constraint.
+func WithTagFilter(f *xpkg.TagFilter) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.filter = f
+	}
+}
+
+// WithTagOrder specifies the order in which the registry lists the tags of a
+// dependency's repository. When tags are listed in the order the version
+// selection policy prefers the Reconciler stops fetching pages of tags once
+// it has found a version that satisfies a dependency's constraint.
+func WithTagOrder(o xpkg.TagOrder) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.order = o
+	}
+}
+
+// WithMaxConcurrentFetches specifies the maximum number of dependency
+// repositories the Reconciler should fetch the tags of at once.
+func WithMaxConcurrentFetches(n int) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.fetches = n
+	}
+}
+
+// A cachingFetcher caches the tags of the repositories it fetches. Only
+// complete lists of tags are cached; if a caller stops fetching pages early
+// the tags fetched so far are not cached.
+type cachingFetcher struct {
+	xpkg.Fetcher
+
+	log     logging.Logger
+	cache   *tagCache
+	metrics *Metrics
+}
+
+// Tags returns all tags of the supplied reference's repository.
+func (f *cachingFetcher) Tags(ctx context.Context, ref name.Reference, secrets ...string) ([]string, error) {
+	tags := []string{}
+	err := f.TagsPaged(ctx, ref, func(page []string) bool {
+		tags = append(tags, page...)
+		return true
+	}, secrets...)
+	return tags, err
+}
+
+// TagsPaged fetches the tags of the supplied reference's repository a page at
+// a time, from the cache if possible. Cached tags are a single page.
+func (f *cachingFetcher) TagsPaged(ctx context.Context, ref name.Reference, fn xpkg.TagPageFn, secrets ...string) error {
+	repo := repository(ref)
+	if tags, ok := f.cache.Get(repo); ok {
+		f.log.Debug("Tag cache hit", "repository", repo)
+		f.metrics.cache.WithLabelValues(cacheHit).Inc()
+		fn(tags)
+		return nil
+	}
+	f.log.Debug("Tag cache miss", "repository", repo)
+	f.metrics.cache.WithLabelValues(cacheMiss).Inc()
+
+	tags := []string{}
+	complete := true
+	t := prometheus.NewTimer(f.metrics.fetch)
+	err := xpkg.PagedTags(ctx, f.Fetcher, ref, func(page []string) bool {
+		tags = append(tags, page...)
+		complete = fn(page)
+		return complete
+	}, secrets...)
+	t.ObserveDuration()
+	if err != nil {
+		return err
+	}
+	if !complete {
+		f.log.Debug("Stopped fetching tags once a satisfying version was found", "repository", repo, "fetched", len(tags))
+		return nil
+	}
+	f.cache.Set(repo, tags)
+	return nil
+}
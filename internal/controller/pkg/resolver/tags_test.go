@@ -0,0 +1,304 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-containerregistry/pkg/name"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/fake"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	v1 "github.com/crossplane/crossplane/apis/pkg/v1"
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+	"github.com/crossplane/crossplane/internal/xpkg"
+	fakexpkg "github.com/crossplane/crossplane/internal/xpkg/fake"
+)
+
+func TestTagCache(t *testing.T) {
+	now := time.Now()
+	c := newTagCache(time.Minute)
+	c.now = func() time.Time { return now }
+
+	if _, ok := c.Get("repo"); ok {
+		t.Errorf("c.Get(...): want miss before set")
+	}
+
+	c.Set("repo", []string{"v1.0.0"})
+	got, ok := c.Get("repo")
+	if !ok {
+		t.Errorf("c.Get(...): want hit after set")
+	}
+	if diff := cmp.Diff([]string{"v1.0.0"}, got); diff != "" {
+		t.Errorf("c.Get(...): -want, +got:\n%s", diff)
+	}
+
+	c.Invalidate("repo")
+	if _, ok := c.Get("repo"); ok {
+		t.Errorf("c.Get(...): want miss after invalidate")
+	}
+
+	c.Set("repo", []string{"v1.0.0"})
+	now = now.Add(time.Minute)
+	if _, ok := c.Get("repo"); ok {
+		t.Errorf("c.Get(...): want miss after TTL elapsed")
+	}
+
+	c.Set("other", nil)
+	if _, ok := c.entries["repo"]; ok {
+		t.Errorf("c.Set(...): want expired entries pruned")
+	}
+}
+
+func TestCachingFetcher(t *testing.T) {
+	errBoom := errors.New("boom")
+
+	cases := map[string]struct {
+		reason string
+		ttl    time.Duration
+		err    error
+		want   int
+	}{
+		"Cached": {
+			reason: "We should only fetch tags once within the TTL.",
+			ttl:    time.Minute,
+			want:   1,
+		},
+		"Disabled": {
+			reason: "We should fetch tags every time if caching is disabled.",
+			ttl:    0,
+			want:   2,
+		},
+		"ErrNotCached": {
+			reason: "We should not cache a failure to fetch tags.",
+			ttl:    time.Minute,
+			err:    errBoom,
+			want:   2,
+		},
+	}
+
+	for n, tc := range cases {
+		t.Run(n, func(t *testing.T) {
+			fetches := 0
+			f := &cachingFetcher{
+				Fetcher: &fakexpkg.MockFetcher{MockTags: func() ([]string, error) {
+					fetches++
+					return []string{"v1.0.0", "sha256-3b5d.sig"}, tc.err
+				}},
+				log:     logging.NewNopLogger(),
+				cache:   newTagCache(tc.ttl),
+				metrics: NewMetrics(),
+			}
+			ref, _ := name.ParseReference("hasheddan/config-nop-a")
+			for i := 0; i < 2; i++ {
+				tags, err := f.Tags(context.Background(), ref)
+				if err != nil {
+					continue
+				}
+				if diff := cmp.Diff([]string{"v1.0.0", "sha256-3b5d.sig"}, tags); diff != "" {
+					t.Errorf("\n%s\nf.Tags(...): -want tags, +got tags:\n%s", tc.reason, diff)
+				}
+			}
+			if diff := cmp.Diff(tc.want, fetches); diff != "" {
+				t.Errorf("\n%s\nf.Tags(...): -want fetches, +got fetches:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+// pagedFetcher serves tags a page at a time and counts the pages it serves.
+type pagedFetcher struct {
+	fakexpkg.MockFetcher
+	tags  []string
+	size  int
+	pages int
+}
+
+func (f *pagedFetcher) TagsPaged(_ context.Context, _ name.Reference, fn xpkg.TagPageFn, _ ...string) error {
+	for i := 0; i < len(f.tags); i += f.size {
+		f.pages++
+		end := i + f.size
+		if end > len(f.tags) {
+			end = len(f.tags)
+		}
+		if !fn(f.tags[i:end]) {
+			return nil
+		}
+	}
+	return nil
+}
+
+func TestCachingFetcherPaged(t *testing.T) {
+	tags := make([]string, 10000)
+	for i := range tags {
+		tags[i] = fmt.Sprintf("v0.%d.0", i)
+	}
+
+	type want struct {
+		pages  int
+		cached bool
+	}
+
+	cases := map[string]struct {
+		reason string
+		stop   int
+		want   want
+	}{
+		"Complete": {
+			reason: "We should cache the tags if every page was fetched.",
+			want:   want{pages: 10, cached: true},
+		},
+		"Partial": {
+			reason: "We should not cache the tags if the caller stopped fetching pages early.",
+			stop:   3,
+			want:   want{pages: 3},
+		},
+	}
+
+	for n, tc := range cases {
+		t.Run(n, func(t *testing.T) {
+			pf := &pagedFetcher{tags: tags, size: 1000}
+			f := &cachingFetcher{Fetcher: pf, log: logging.NewNopLogger(), cache: newTagCache(time.Minute), metrics: NewMetrics()}
+			ref, _ := name.ParseReference("crossplane/provider-nightly")
+
+			pages := 0
+			err := f.TagsPaged(context.Background(), ref, func(_ []string) bool {
+				pages++
+				return pages != tc.stop
+			})
+			if err != nil {
+				t.Fatalf("\n%s\nf.TagsPaged(...): unexpected error: %s", tc.reason, err)
+			}
+			if diff := cmp.Diff(tc.want.pages, pf.pages); diff != "" {
+				t.Errorf("\n%s\nf.TagsPaged(...): -want pages, +got pages:\n%s", tc.reason, diff)
+			}
+			_, cached := f.cache.Get(repository(ref))
+			if diff := cmp.Diff(tc.want.cached, cached); diff != "" {
+				t.Errorf("\n%s\nf.TagsPaged(...): -want cached, +got cached:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+// repoFetcher fails to fetch the tags of some repositories.
+type repoFetcher struct {
+	fakexpkg.MockFetcher
+	fail map[string]bool
+}
+
+func (f *repoFetcher) Tags(_ context.Context, ref name.Reference, _ ...string) ([]string, error) {
+	if f.fail[ref.Context().RepositoryStr()] {
+		return nil, errors.New("boom")
+	}
+	return []string{"v1.0.0"}, nil
+}
+
+func TestReconcilePartialFetch(t *testing.T) {
+	created := []string{}
+	mgr := &fake.Manager{
+		Client: &test.MockClient{
+			MockGet: test.NewMockGetFn(nil, func(o client.Object) error {
+				o.(*v1beta1.Lock).Packages = []v1beta1.LockPackage{{
+					Source: "cool/config",
+					Dependencies: []v1beta1.Dependency{
+						{Package: "cool/provider-a", Type: v1beta1.ProviderPackageType, Constraints: ">=v1.0.0"},
+						{Package: "cool/provider-b", Type: v1beta1.ProviderPackageType, Constraints: ">=v1.0.0"},
+						{Package: "cool/provider-c", Type: v1beta1.ProviderPackageType, Constraints: ">=v1.0.0"},
+					},
+				}}
+				return nil
+			}),
+			MockUpdate: test.NewMockUpdateFn(nil),
+			MockList:   test.NewMockListFn(nil),
+			MockCreate: func(_ context.Context, o client.Object, _ ...client.CreateOption) error {
+				created = append(created, o.(v1.Package).GetSource())
+				return nil
+			},
+			MockStatusUpdate: test.NewMockStatusUpdateFn(nil),
+		},
+	}
+	r := NewReconciler(mgr,
+		WithFetcher(&repoFetcher{fail: map[string]bool{"cool/provider-b": true}}),
+		WithMaxConcurrentFetches(2),
+	)
+	got, err := r.Reconcile(context.Background(), reconcile.Request{})
+	if err != nil {
+		t.Fatalf("r.Reconcile(...): unexpected error: %s", err)
+	}
+
+	reason := "We should create the dependencies whose tags we fetched, and requeue to retry the others."
+	if diff := cmp.Diff(reconcile.Result{RequeueAfter: shortWait}, got); diff != "" {
+		t.Errorf("\n%s\nr.Reconcile(...): -want result, +got result:\n%s", reason, diff)
+	}
+	if diff := cmp.Diff([]string{"cool/provider-a:v1.0.0", "cool/provider-c:v1.0.0"}, created); diff != "" {
+		t.Errorf("\n%s\nr.Reconcile(...): -want created, +got created:\n%s", reason, diff)
+	}
+}
+
+func TestReconcileRegistryUnavailable(t *testing.T) {
+	var status *v1beta1.Lock
+	mgr := &fake.Manager{
+		Client: &test.MockClient{
+			MockGet: test.NewMockGetFn(nil, func(o client.Object) error {
+				o.(*v1beta1.Lock).Packages = []v1beta1.LockPackage{{
+					Source: "cool/config",
+					Dependencies: []v1beta1.Dependency{
+						{Package: "cool/provider-a", Type: v1beta1.ProviderPackageType, Constraints: ">=v1.0.0"},
+						{Package: "cool/provider-b", Type: v1beta1.ProviderPackageType, Constraints: ">=v1.0.0"},
+					},
+				}}
+				return nil
+			}),
+			MockUpdate: test.NewMockUpdateFn(nil),
+			MockStatusUpdate: test.NewMockStatusUpdateFn(nil, func(o client.Object) error {
+				status = o.(*v1beta1.Lock)
+				return nil
+			}),
+		},
+	}
+	fetches := 0
+	r := NewReconciler(mgr,
+		WithFetcher(&fakexpkg.MockFetcher{MockTags: func() ([]string, error) {
+			fetches++
+			return nil, errors.New("boom")
+		}}),
+		WithMaxConcurrentFetches(1),
+		WithRegistryCircuitBreaker(1, time.Minute),
+	)
+	if _, err := r.Reconcile(context.Background(), reconcile.Request{}); err != nil {
+		t.Fatalf("r.Reconcile(...): unexpected error: %s", err)
+	}
+
+	reason := "We should stop fetching tags from a registry once its breaker opens, and report when we'll retry."
+	if diff := cmp.Diff(1, fetches); diff != "" {
+		t.Errorf("\n%s\nr.Reconcile(...): -want fetches, +got fetches:\n%s", reason, diff)
+	}
+	msg := status.GetCondition(v1beta1.TypeDependenciesResolved).Message
+	if !strings.Contains(msg, "registry index.docker.io is unavailable, retrying at") {
+		t.Errorf("\n%s\nr.Reconcile(...): condition message %q does not report the unavailable registry", reason, msg)
+	}
+}
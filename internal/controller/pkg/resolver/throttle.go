@@ -0,0 +1,37 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"strings"
+)
+
+const msgThrottled = "Client-side rate limiting delayed requests to the API server past the reconcile deadline; waiting before reconciling again"
+
+// throttleDeadline is part of the error a client-side rate limiter returns
+// when a request would have to wait past its context's deadline. The rate
+// limiter doesn't return a typed error, and the API client returns its error
+// unwrapped.
+const throttleDeadline = "would exceed context deadline"
+
+// throttled returns true if the supplied error indicates client-side rate
+// limiting delayed a request to the API server past the reconcile deadline.
+// Throttling isn't a bug, and will persist while the cluster is busy, so we
+// back off quietly rather than report it as an error.
+func throttled(err error) bool {
+	return err != nil && strings.Contains(err.Error(), throttleDeadline)
+}
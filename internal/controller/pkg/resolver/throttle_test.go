@@ -0,0 +1,175 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/client-go/util/flowcontrol"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/fake"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+	"github.com/crossplane/crossplane/internal/dag"
+	fakexpkg "github.com/crossplane/crossplane/internal/xpkg/fake"
+)
+
+func TestThrottled(t *testing.T) {
+	cases := map[string]struct {
+		reason string
+		err    error
+		want   bool
+	}{
+		"RateLimited": {
+			reason: "An error from a rate limiter that would wait past the deadline should indicate throttling.",
+			err:    errors.New("rate: Wait(n=1) would exceed context deadline"),
+			want:   true,
+		},
+		"Wrapped": {
+			reason: "A wrapped rate limiter error should indicate throttling.",
+			err:    errors.Wrap(errors.New("rate: Wait(n=1) would exceed context deadline"), errCreateDependency),
+			want:   true,
+		},
+		"DeadlineExceeded": {
+			reason: "A request that timed out for any other reason should not indicate throttling.",
+			err:    context.DeadlineExceeded,
+			want:   false,
+		},
+		"NoError": {
+			reason: "No error should not indicate throttling.",
+			want:   false,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := throttled(tc.err)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nthrottled(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestReconcileThrottled(t *testing.T) {
+	type want struct {
+		r       reconcile.Result
+		created int
+		events  []event.Reason
+	}
+
+	// Each case allows the supplied number of requests, then throttles the
+	// rest. The rate limiter refills too slowly to allow another request
+	// before the reconcile deadline, so it fails them immediately.
+	cases := map[string]struct {
+		reason  string
+		allowed int
+		want    want
+	}{
+		"GetLock": {
+			reason:  "We should requeue quietly, and later than after an error, if reading the Lock is throttled.",
+			allowed: 0,
+			want:    want{r: reconcile.Result{RequeueAfter: longWait}},
+		},
+		"CreateDependency": {
+			reason:  "We should stop creating dependencies, and requeue quietly, once creating them is throttled.",
+			allowed: 5,
+			want:    want{r: reconcile.Result{RequeueAfter: longWait}, created: 1, events: []event.Reason{reasonInstallDependency}},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			// A rate limiter whose burst we use up before reconciling.
+			limiter := flowcontrol.NewTokenBucketRateLimiter(0.001, tc.allowed+1)
+			if err := limiter.Wait(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			throttle := func(ctx context.Context) error { return limiter.Wait(ctx) }
+
+			created := 0
+			mgr := &fake.Manager{
+				Client: &test.MockClient{
+					MockGet: func(ctx context.Context, _ client.ObjectKey, o client.Object) error {
+						if err := throttle(ctx); err != nil {
+							return err
+						}
+						l := o.(*v1beta1.Lock)
+						l.SetName("lock")
+						l.SetFinalizers([]string{finalizer})
+						l.Packages = []v1beta1.LockPackage{{
+							Source: "cool/config",
+							Dependencies: []v1beta1.Dependency{
+								{Package: "crossplane/provider-aws", Type: v1beta1.ProviderPackageType, Constraints: ">=v0.20.0"},
+								{Package: "crossplane/provider-gcp", Type: v1beta1.ProviderPackageType, Constraints: ">=v0.20.0"},
+							},
+						}}
+						return nil
+					},
+					MockList: func(ctx context.Context, _ client.ObjectList, _ ...client.ListOption) error {
+						return throttle(ctx)
+					},
+					MockCreate: func(ctx context.Context, _ client.Object, _ ...client.CreateOption) error {
+						if err := throttle(ctx); err != nil {
+							return err
+						}
+						created++
+						return nil
+					},
+					MockUpdate: func(ctx context.Context, _ client.Object, _ ...client.UpdateOption) error {
+						return throttle(ctx)
+					},
+					MockStatusUpdate: func(ctx context.Context, _ client.Object, _ ...client.UpdateOption) error {
+						return throttle(ctx)
+					},
+				},
+			}
+			rec := &recorder{}
+			r := NewReconciler(mgr,
+				WithRecorder(rec),
+				WithFetcher(&fakexpkg.MockFetcher{MockTags: fakexpkg.NewMockTagsFn([]string{"v0.20.0"}, nil)}),
+				WithNewDagFn(dag.NewMapDag),
+			)
+
+			start := time.Now()
+			got, err := r.Reconcile(context.Background(), reconcile.Request{})
+			if err != nil {
+				t.Errorf("\n%s\nr.Reconcile(...): %s", tc.reason, err)
+			}
+			if d := time.Since(start); d > reconcileTimeout/2 {
+				t.Errorf("\n%s\nr.Reconcile(...): took %s, want well within the %s reconcile timeout", tc.reason, d, reconcileTimeout)
+			}
+			if diff := cmp.Diff(tc.want.r, got); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want result, +got result:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.created, created); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want created, +got created:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.events, rec.reasons); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want events, +got events:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
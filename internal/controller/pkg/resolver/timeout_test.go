@@ -0,0 +1,171 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-containerregistry/pkg/name"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/fake"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+	"github.com/crossplane/crossplane/internal/dag"
+	fakexpkg "github.com/crossplane/crossplane/internal/xpkg/fake"
+)
+
+// A blockingFetcher blocks fetching the tags of the supplied repositories
+// until its context is cancelled.
+type blockingFetcher struct {
+	fakexpkg.MockFetcher
+	blocked map[string]bool
+}
+
+func (f *blockingFetcher) Tags(ctx context.Context, ref name.Reference, secrets ...string) ([]string, error) {
+	if f.blocked[ref.Context().RepositoryStr()] {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	return f.MockFetcher.Tags(ctx, ref, secrets...)
+}
+
+func TestReconcileTimeout(t *testing.T) {
+	config := v1beta1.LockPackage{
+		Source: "cool/config",
+		Dependencies: []v1beta1.Dependency{
+			{Package: "crossplane/provider-aws", Type: v1beta1.ProviderPackageType, Constraints: ">=v0.20.0"},
+			{Package: "crossplane/provider-gcp", Type: v1beta1.ProviderPackageType, Constraints: ">=v0.20.0"},
+		},
+	}
+
+	type args struct {
+		blocked map[string]bool
+		create  func(ctx context.Context, o client.Object) error
+	}
+	type want struct {
+		r       reconcile.Result
+		created []string
+		reason  xpv1.ConditionReason
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"FetchTimeout": {
+			reason: "We should give up fetching the tags of a repository once the fetch timeout expires, install the dependencies whose tags we fetched, and requeue.",
+			args: args{
+				blocked: map[string]bool{"crossplane/provider-gcp": true},
+			},
+			want: want{
+				r:       reconcile.Result{RequeueAfter: shortWait},
+				created: []string{"crossplane-provider-aws"},
+				reason:  v1beta1.ReasonFetchError,
+			},
+		},
+		"CreateTimeout": {
+			reason: "We should give up creating a package once the fetch timeout expires, create the other dependencies, and requeue.",
+			args: args{
+				create: func(ctx context.Context, o client.Object) error {
+					if o.GetName() == "crossplane-provider-gcp" {
+						<-ctx.Done()
+						return ctx.Err()
+					}
+					return nil
+				},
+			},
+			want: want{
+				r:       reconcile.Result{RequeueAfter: shortWait},
+				created: []string{"crossplane-provider-aws"},
+				reason:  v1beta1.ReasonMissingDependency,
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			created := []string{}
+			var status *v1beta1.Lock
+			mgr := &fake.Manager{
+				Client: &test.MockClient{
+					MockGet: func(_ context.Context, key client.ObjectKey, o client.Object) error {
+						if l, ok := o.(*v1beta1.Lock); ok {
+							l.Packages = []v1beta1.LockPackage{config}
+							return nil
+						}
+						return kerrors.NewNotFound(schema.GroupResource{}, key.Name)
+					},
+					MockList: test.NewMockListFn(nil),
+					MockCreate: func(ctx context.Context, o client.Object, _ ...client.CreateOption) error {
+						if tc.args.create != nil {
+							if err := tc.args.create(ctx, o); err != nil {
+								return err
+							}
+						}
+						created = append(created, o.GetName())
+						return nil
+					},
+					MockUpdate: test.NewMockUpdateFn(nil),
+					MockStatusUpdate: test.NewMockStatusUpdateFn(nil, func(o client.Object) error {
+						status = o.(*v1beta1.Lock)
+						return nil
+					}),
+				},
+			}
+			r := NewReconciler(mgr,
+				WithRecorder(&recorder{}),
+				WithFetcher(&blockingFetcher{
+					MockFetcher: fakexpkg.MockFetcher{MockTags: fakexpkg.NewMockTagsFn([]string{"v0.20.0"}, nil)},
+					blocked:     tc.args.blocked,
+				}),
+				WithNewDagFn(dag.NewMapDag),
+				WithFetchTimeout(10*time.Millisecond),
+			)
+
+			start := time.Now()
+			got, err := r.Reconcile(context.Background(), reconcile.Request{})
+			if err != nil {
+				t.Fatalf("\n%s\nr.Reconcile(...): %s", tc.reason, err)
+			}
+
+			// The reconcile would take its full budget if the per operation
+			// timeout didn't fire.
+			if elapsed := time.Since(start); elapsed > reconcileTimeout/2 {
+				t.Errorf("\n%s\nr.Reconcile(...): took %s, want less than %s", tc.reason, elapsed, reconcileTimeout/2)
+			}
+			if diff := cmp.Diff(tc.want.r, got); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want, +got:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.created, created); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want created, +got created:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.reason, status.GetCondition(v1beta1.TypeDependenciesResolved).Reason); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want condition reason, +got condition reason:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName identifies the Reconciler's spans as its instrumentation.
+const tracerName = "github.com/crossplane/crossplane/internal/controller/pkg/resolver"
+
+// Names of the spans the Reconciler records, in addition to those recorded by
+// its xpkg.Resolver.
+const (
+	// SpanReconcile covers a single pass of the Reconciler.
+	SpanReconcile = "Reconcile"
+
+	// SpanInstallDependency covers creating the package that satisfies a
+	// missing dependency, or deciding not to.
+	SpanInstallDependency = "InstallDependency"
+)
+
+// AttributeLock is the name of the Lock being reconciled.
+const AttributeLock = attribute.Key("crossplane.lock.name")
+
+// WithTracerProvider specifies the provider of the tracer the Reconciler uses
+// to record how long it spends resolving each Lock and each of its missing
+// dependencies. By default no spans are recorded.
+func WithTracerProvider(tp trace.TracerProvider) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.tracer = tp.Tracer(tracerName)
+	}
+}
@@ -0,0 +1,193 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/crossplane/crossplane-runtime/pkg/resource/fake"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+	"github.com/crossplane/crossplane/internal/dag"
+	"github.com/crossplane/crossplane/internal/xpkg"
+	fakexpkg "github.com/crossplane/crossplane/internal/xpkg/fake"
+)
+
+// A span summarises a recorded span for comparison.
+type span struct {
+	Name       string
+	Parent     string
+	Attributes map[attribute.Key]string
+	Failed     bool
+}
+
+// spans summarises the supplied recorded spans, identifying each span's parent
+// by name.
+func spans(stubs tracetest.SpanStubs) []span {
+	names := map[string]string{}
+	for _, s := range stubs {
+		names[s.SpanContext.SpanID().String()] = s.Name
+	}
+	got := make([]span, len(stubs))
+	for i, s := range stubs {
+		attrs := map[attribute.Key]string{}
+		for _, kv := range s.Attributes {
+			attrs[kv.Key] = kv.Value.Emit()
+		}
+		got[i] = span{
+			Name:       s.Name,
+			Parent:     names[s.Parent.SpanID().String()],
+			Attributes: attrs,
+			Failed:     s.Status.Code == codes.Error,
+		}
+	}
+	return got
+}
+
+func TestReconcileTracing(t *testing.T) {
+	cases := map[string]struct {
+		reason string
+		tags   []string
+		want   []span
+	}{
+		"Resolved": {
+			reason: "We should record a span for the pass, with child spans covering fetching, selecting, and creating the dependency.",
+			tags:   []string{"v0.20.0", "v0.21.0", "v0.22.0"},
+			want: []span{
+				{
+					Name:       xpkg.SpanFetchVersions,
+					Parent:     xpkg.SpanResolveDependency,
+					Attributes: map[attribute.Key]string{xpkg.AttributePackage: "crossplane/provider-aws"},
+				},
+				{
+					Name:       xpkg.SpanSelectVersion,
+					Parent:     xpkg.SpanResolveDependency,
+					Attributes: map[attribute.Key]string{xpkg.AttributeCandidates: "3", xpkg.AttributeVersion: "v0.22.0"},
+				},
+				{
+					Name:   xpkg.SpanResolveDependency,
+					Parent: SpanReconcile,
+					Attributes: map[attribute.Key]string{
+						xpkg.AttributePackage:     "crossplane/provider-aws",
+						xpkg.AttributeConstraints: "[>=v0.21.0]",
+						xpkg.AttributeVersion:     "v0.22.0",
+						xpkg.AttributeCandidates:  "3",
+						xpkg.AttributeOutcome:     xpkg.OutcomePlanned,
+					},
+				},
+				{
+					Name:   SpanInstallDependency,
+					Parent: SpanReconcile,
+					Attributes: map[attribute.Key]string{
+						xpkg.AttributePackage: "crossplane/provider-aws",
+						xpkg.AttributeVersion: "v0.22.0",
+						xpkg.AttributeOutcome: outcomeCreated,
+					},
+				},
+				{
+					Name:       SpanReconcile,
+					Attributes: map[attribute.Key]string{AttributeLock: "lock"},
+				},
+			},
+		},
+		"NoValidVersion": {
+			reason: "We should record that a dependency no version satisfies failed, and why.",
+			tags:   []string{"v0.19.0", "v0.20.0"},
+			want: []span{
+				{
+					Name:       xpkg.SpanFetchVersions,
+					Parent:     xpkg.SpanResolveDependency,
+					Attributes: map[attribute.Key]string{xpkg.AttributePackage: "crossplane/provider-aws"},
+				},
+				{
+					Name:       xpkg.SpanSelectVersion,
+					Parent:     xpkg.SpanResolveDependency,
+					Attributes: map[attribute.Key]string{xpkg.AttributeCandidates: "2", xpkg.AttributeVersion: ""},
+					Failed:     true,
+				},
+				{
+					Name:   xpkg.SpanResolveDependency,
+					Parent: SpanReconcile,
+					Attributes: map[attribute.Key]string{
+						xpkg.AttributePackage:     "crossplane/provider-aws",
+						xpkg.AttributeConstraints: "[>=v0.21.0]",
+						xpkg.AttributeVersion:     "",
+						xpkg.AttributeCandidates:  "2",
+						xpkg.AttributeOutcome:     string(xpkg.DependencyNoValidVersion),
+					},
+					Failed: true,
+				},
+				{
+					Name:   SpanInstallDependency,
+					Parent: SpanReconcile,
+					Attributes: map[attribute.Key]string{
+						xpkg.AttributePackage: "crossplane/provider-aws",
+						xpkg.AttributeVersion: "",
+						xpkg.AttributeOutcome: string(xpkg.DependencyNoValidVersion),
+					},
+					Failed: true,
+				},
+				{
+					Name:       SpanReconcile,
+					Attributes: map[attribute.Key]string{AttributeLock: "lock"},
+				},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			mgr := &fake.Manager{
+				Client: &test.MockClient{
+					MockGet: test.NewMockGetFn(nil, func(o client.Object) error {
+						o.(*v1beta1.Lock).Packages = []v1beta1.LockPackage{{
+							Source:       "cool/config",
+							Dependencies: []v1beta1.Dependency{{Package: "crossplane/provider-aws", Type: v1beta1.ProviderPackageType, Constraints: ">=v0.21.0"}},
+						}}
+						return nil
+					}),
+					MockUpdate:       test.NewMockUpdateFn(nil),
+					MockList:         test.NewMockListFn(nil),
+					MockCreate:       test.NewMockCreateFn(nil),
+					MockStatusUpdate: test.NewMockStatusUpdateFn(nil),
+				},
+			}
+			exp := tracetest.NewInMemoryExporter()
+			r := NewReconciler(mgr,
+				WithTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exp))),
+				WithFetcher(&fakexpkg.MockFetcher{MockTags: fakexpkg.NewMockTagsFn(tc.tags, nil)}),
+				WithNewDagFn(dag.NewMapDag),
+			)
+			_, _ = r.Reconcile(context.Background(), reconcile.Request{NamespacedName: types.NamespacedName{Name: "lock"}})
+
+			if diff := cmp.Diff(tc.want, spans(exp.GetSpans())); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want spans, +got spans:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/go-containerregistry/pkg/name"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/meta"
+
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+	"github.com/crossplane/crossplane/internal/xpkg"
+)
+
+const (
+	errTrackDependency    = "cannot track dependency package tag"
+	errTrackDependencyFmt = "cannot move package %s to the digest tag %s points at"
+
+	msgTrackedFmt = "moved package %s tracking tag %s from digest %s to %s"
+)
+
+// A trackedPackage is a package in the Lock that the packages that depend on
+// it require to track a tag.
+type trackedPackage struct {
+	pkg v1beta1.LockPackage
+	tag string
+}
+
+// tracked returns the packages in the supplied Lock that every package that
+// depends on them requires to track the same tag. A package that is also
+// constrained some other way, or that must track different tags, can't
+// follow all of them, so it's left where it is.
+func tracked(lock *v1beta1.Lock) []trackedPackage {
+	out := []trackedPackage{}
+	for _, ip := range lock.Packages {
+		tag, follow := "", true
+		for _, p := range lock.Packages {
+			for _, d := range p.Dependencies {
+				if d.Identifier() != ip.Identifier() {
+					continue
+				}
+				t, ok := xpkg.TrackedTag(d.Constraints)
+				follow = follow && ok && (tag == "" || tag == t)
+				tag = t
+			}
+		}
+		if follow && tag != "" {
+			out = append(out, trackedPackage{pkg: ip, tag: tag})
+		}
+	}
+	return out
+}
+
+// retrack moves the auto-installed package installed to satisfy the supplied
+// tracked package to the digest its tag now points at, if the tag has moved.
+// Tags are resolved using the supplied pull secrets. Packages that were not
+// installed by the resolver are never moved.
+func (r *Reconciler) retrack(ctx context.Context, lock *v1beta1.Lock, t trackedPackage, secrets []string) (RequeueReason, error) {
+	ref, err := name.ParseReference(t.pkg.Source, xpkg.DefaultRegistryOptions(r.registry)...)
+	if err != nil {
+		return "", permanent(errors.Wrapf(errors.Wrap(err, errParseSource), errTrackDependencyFmt, t.pkg.Source, t.tag))
+	}
+	d, err := r.resolver.Digest(ctx, withSecrets(r.fetcher, secrets), ref, t.tag)
+	if blockedByPolicy(err) {
+		return "", errors.Wrapf(err, errTrackDependencyFmt, t.pkg.Source, t.tag)
+	}
+	if err != nil {
+		return RequeueFetchError, errors.Wrapf(err, errTrackDependencyFmt, t.pkg.Source, t.tag)
+	}
+	if d == t.pkg.Version {
+		return "", nil
+	}
+
+	pack, err := r.autoInstalledPackage(ctx, lock, t.pkg.Source)
+	if err != nil {
+		return RequeueUpgradeError, errors.Wrapf(err, errTrackDependencyFmt, t.pkg.Source, t.tag)
+	}
+	if pack == nil {
+		return "", nil
+	}
+	pack.SetSource(xpkg.VersionedSource(t.pkg.Source, d))
+	meta.AddAnnotations(pack, provenance(lock, t.pkg.Source, d, r.now()))
+	if err := r.client.Update(ctx, pack); err != nil {
+		return retry(RequeueUpgradeError, err), errors.Wrapf(errors.Wrap(err, errUpdatePackage), errTrackDependencyFmt, t.pkg.Source, t.tag)
+	}
+	msg := fmt.Sprintf(msgTrackedFmt, t.pkg.Source, t.tag, t.pkg.Version, d)
+	r.record.Event(lock, event.Normal(reasonTrackDependency, msg))
+	r.record.Event(pack, event.Normal(reasonTrackDependency, msg))
+	r.recordAudit(ctx, lock, AuditRecord{Action: AuditUpgraded, Source: t.pkg.Source, Package: pack.GetName(), Version: d, From: t.pkg.Version, Reason: msg})
+	return "", nil
+}
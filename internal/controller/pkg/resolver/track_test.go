@@ -0,0 +1,197 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	ociv1 "github.com/google/go-containerregistry/pkg/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/fake"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	v1 "github.com/crossplane/crossplane/apis/pkg/v1"
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+	"github.com/crossplane/crossplane/internal/dag"
+	fakexpkg "github.com/crossplane/crossplane/internal/xpkg/fake"
+)
+
+func TestReconcileTrackedTag(t *testing.T) {
+	errBoom := errors.New("boom")
+	before := "sha256:ecc25c121431dfc7058754427f97c034ecde26d4aafa0da16d6429d9f7d6cd9b"
+	after := "sha256:3b5d3b5d3b5d3b5d3b5d3b5d3b5d3b5d3b5d3b5d3b5d3b5d3b5d3b5d3b5d3b5d"
+
+	// The Lock contains a Configuration that tracks the v1-stable tag of
+	// provider-aws, and provider-aws at the supplied digest, if any.
+	lock := func(installed string) func(o client.Object) error {
+		return func(o client.Object) error {
+			l := o.(*v1beta1.Lock)
+			l.SetName("lock")
+			l.SetAnnotations(map[string]string{AnnotationUpgradeDependencies: "true"})
+			l.Packages = []v1beta1.LockPackage{{
+				Source: "cool/config",
+				Type:   v1beta1.ConfigurationPackageType,
+				Dependencies: []v1beta1.Dependency{
+					{Package: "crossplane/provider-aws", Type: v1beta1.ProviderPackageType, Constraints: "track:v1-stable"},
+				},
+			}}
+			if installed != "" {
+				l.Packages = append(l.Packages, v1beta1.LockPackage{Source: "crossplane/provider-aws", Type: v1beta1.ProviderPackageType, Version: installed})
+			}
+			return nil
+		}
+	}
+
+	// provider-aws was installed by the resolver at the first digest.
+	installed := func(o client.ObjectList) error {
+		if l, ok := o.(*v1.ProviderList); ok {
+			p := v1.Provider{ObjectMeta: metav1.ObjectMeta{Name: "crossplane-provider-aws", Labels: map[string]string{LabelAutoInstalled: "true"}}}
+			p.SetSource("crossplane/provider-aws@" + before)
+			l.Items = []v1.Provider{p}
+		}
+		return nil
+	}
+
+	head := func(digest string, err error) func() (*ociv1.Descriptor, error) {
+		return func() (*ociv1.Descriptor, error) {
+			if err != nil {
+				return nil, err
+			}
+			h, err := ociv1.NewHash(digest)
+			return &ociv1.Descriptor{Digest: h}, err
+		}
+	}
+
+	type want struct {
+		created string
+		updated string
+		events  []event.Reason
+	}
+
+	cases := map[string]struct {
+		reason   string
+		get      func(o client.Object) error
+		list     func(o client.ObjectList) error
+		head     func() (*ociv1.Descriptor, error)
+		upgrades bool
+		want     want
+	}{
+		"Install": {
+			reason:   "We should install a missing dependency that tracks a tag at the digest the tag points at.",
+			get:      lock(""),
+			list:     func(_ client.ObjectList) error { return nil },
+			head:     head(before, nil),
+			upgrades: true,
+			want: want{
+				created: "crossplane/provider-aws@" + before,
+				events:  []event.Reason{reasonInstallDependency},
+			},
+		},
+		"Unmoved": {
+			reason:   "We should leave a package alone if the tag it tracks still points at its digest.",
+			get:      lock(before),
+			list:     installed,
+			head:     head(before, nil),
+			upgrades: true,
+		},
+		"Moved": {
+			reason:   "We should move a package to the digest the tag it tracks now points at.",
+			get:      lock(before),
+			list:     installed,
+			head:     head(after, nil),
+			upgrades: true,
+			want: want{
+				updated: "crossplane/provider-aws@" + after,
+				events:  []event.Reason{reasonTrackDependency, reasonTrackDependency},
+			},
+		},
+		"MovedUpgradesNotAllowed": {
+			reason: "We should not move a package unless upgrades are allowed.",
+			get:    lock(before),
+			list:   installed,
+			head:   head(after, nil),
+		},
+		"ErrHead": {
+			reason:   "We should report that we cannot resolve the tag a package tracks.",
+			get:      lock(before),
+			list:     installed,
+			head:     head("", errBoom),
+			upgrades: true,
+			want: want{
+				events: []event.Reason{reasonTrackDependency},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var created, updated string
+			mgr := &fake.Manager{
+				Client: &test.MockClient{
+					MockGet: func(_ context.Context, key client.ObjectKey, o client.Object) error {
+						if _, ok := o.(*v1beta1.Lock); ok {
+							return tc.get(o)
+						}
+						return kerrors.NewNotFound(schema.GroupResource{}, key.Name)
+					},
+					MockList: test.NewMockListFn(nil, tc.list),
+					MockCreate: func(_ context.Context, o client.Object, _ ...client.CreateOption) error {
+						created = o.(v1.Package).GetSource()
+						return nil
+					},
+					MockUpdate: func(_ context.Context, o client.Object, _ ...client.UpdateOption) error {
+						if p, ok := o.(v1.Package); ok {
+							updated = p.GetSource()
+						}
+						return nil
+					},
+					MockStatusUpdate: test.NewMockStatusUpdateFn(nil),
+				},
+			}
+			rec := &recorder{}
+			opts := []ReconcilerOption{
+				WithRecorder(rec),
+				WithFetcher(&fakexpkg.MockFetcher{MockHead: tc.head}),
+				WithNewDagFn(dag.NewMapDag),
+			}
+			if tc.upgrades {
+				opts = append(opts, WithUpgradeDependencies())
+			}
+			r := NewReconciler(mgr, opts...)
+			_, _ = r.Reconcile(context.Background(), reconcile.Request{})
+
+			if diff := cmp.Diff(tc.want.created, created); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want created source, +got created source:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.updated, updated); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want updated source, +got updated source:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.events, rec.reasons); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want events, +got events:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	v1 "github.com/crossplane/crossplane/apis/pkg/v1"
+	"github.com/crossplane/crossplane/apis/pkg/v1alpha1"
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+)
+
+// A packageKind is the set of API types that represent a type of package.
+type packageKind struct {
+	newPackage             func() v1.Package
+	newPackageRevision     func() v1.PackageRevision
+	newPackageRevisionList func() v1.PackageRevisionList
+}
+
+// packageKinds are the types of package the Reconciler can install as
+// dependencies, and the API types that represent them.
+var packageKinds = map[v1beta1.PackageType]packageKind{
+	v1beta1.ConfigurationPackageType: {
+		newPackage:             func() v1.Package { return &v1.Configuration{} },
+		newPackageRevision:     func() v1.PackageRevision { return &v1.ConfigurationRevision{} },
+		newPackageRevisionList: func() v1.PackageRevisionList { return &v1.ConfigurationRevisionList{} },
+	},
+	v1beta1.ProviderPackageType: {
+		newPackage:             func() v1.Package { return &v1.Provider{} },
+		newPackageRevision:     func() v1.PackageRevision { return &v1.ProviderRevision{} },
+		newPackageRevisionList: func() v1.PackageRevisionList { return &v1.ProviderRevisionList{} },
+	},
+	v1beta1.FunctionPackageType: {
+		newPackage:             func() v1.Package { return &v1alpha1.Function{} },
+		newPackageRevision:     func() v1.PackageRevision { return &v1alpha1.FunctionRevision{} },
+		newPackageRevisionList: func() v1.PackageRevisionList { return &v1alpha1.FunctionRevisionList{} },
+	},
+}
+
+// newPackage returns a new package of the supplied type, or false if the
+// Reconciler can't install that type of package.
+func newPackage(t v1beta1.PackageType) (v1.Package, bool) {
+	k, ok := packageKinds[t]
+	if !ok {
+		return nil, false
+	}
+	return k.newPackage(), true
+}
+
+// newPackageRevision returns a new revision of a package of the supplied
+// type, or false if the Reconciler can't install that type of package.
+func newPackageRevision(t v1beta1.PackageType) (v1.PackageRevision, bool) {
+	k, ok := packageKinds[t]
+	if !ok {
+		return nil, false
+	}
+	return k.newPackageRevision(), true
+}
@@ -0,0 +1,183 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/go-containerregistry/pkg/name"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/crossplane-runtime/pkg/meta"
+
+	v1 "github.com/crossplane/crossplane/apis/pkg/v1"
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+	"github.com/crossplane/crossplane/internal/xpkg"
+	"github.com/crossplane/crossplane/internal/xpkg/canonical"
+)
+
+// AnnotationUpgradeDependencies may be set to "true" on the Lock to allow the
+// resolver to upgrade auto-installed dependencies whose installed version no
+// longer satisfies the constraints of the packages that depend on them. The
+// Reconciler must also be configured using WithUpgradeDependencies.
+//
+// Deprecated: Set the Lock's spec.upgradePolicy to Automatic instead. The
+// annotation is converted to that policy if the Lock's spec sets none.
+const AnnotationUpgradeDependencies = "pkg.crossplane.io/upgrade-dependencies"
+
+const (
+	errNotAutoInstalled     = "package was not installed by the resolver"
+	errNoUpgradeVersion     = "cannot find a version that satisfies all constraints"
+	errUpgradeDependency    = "cannot upgrade dependency package"
+	errUpgradeDependencyFmt = "cannot upgrade dependency package %s"
+
+	errDowngradeFmt = "refusing to downgrade package %s from %s to %s as a dependency of %s"
+
+	msgUpgradedFmt   = "upgraded package %s from %s to %s"
+	msgDowngradedFmt = "downgraded package %s from %s to %s"
+)
+
+// A downgradeError indicates that the only versions of an auto-installed
+// package that satisfy the packages that depend on it are older than the
+// installed version.
+type downgradeError struct {
+	msg string
+}
+
+func (e *downgradeError) Error() string {
+	return e.msg
+}
+
+// WithUpgradeDependencies specifies that the Reconciler honors the deprecated
+// AnnotationUpgradeDependencies, which allows it to upgrade auto-installed
+// dependencies to satisfy the constraints of the packages that depend on them.
+// A Lock's upgrade policy applies regardless.
+func WithUpgradeDependencies() ReconcilerOption {
+	return func(r *Reconciler) {
+		r.upgrade = true
+	}
+}
+
+// WithAllowDowngrades specifies that the Reconciler may replace an
+// auto-installed dependency with an older version when the packages that
+// depend on it require one. Downgrading a package may remove API versions that
+// are in use, so by default such dependencies are reported and left alone.
+func WithAllowDowngrades() ReconcilerOption {
+	return func(r *Reconciler) {
+		r.downgrades = true
+	}
+}
+
+// upgradePolicy returns the upgrade policy of the supplied Lock. A Lock whose
+// spec sets no policy has the None policy, unless it has the deprecated
+// AnnotationUpgradeDependencies and the Reconciler honors it, in which case
+// the annotation is converted to the Automatic policy.
+func (r *Reconciler) upgradePolicy(lock *v1beta1.Lock) v1beta1.DependencyUpgradePolicy {
+	if p := lock.Spec.UpgradePolicy; p != nil {
+		return *p
+	}
+	if r.upgrade && lock.GetAnnotations()[AnnotationUpgradeDependencies] == "true" {
+		return v1beta1.UpgradePolicyAutomatic
+	}
+	return v1beta1.UpgradePolicyNone
+}
+
+// autoInstalledPackage returns the package auto-installed for the supplied Lock
+// from the supplied package source, or nil if there is none.
+func (r *Reconciler) autoInstalledPackage(ctx context.Context, lock *v1beta1.Lock, source string) (v1.Package, error) {
+	pkgs, err := r.autoInstalled(ctx, lock)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range pkgs {
+		ref, err := name.ParseReference(p.GetSource(), name.WithDefaultRegistry(""))
+		if err != nil {
+			continue
+		}
+		if canonical.Equal(qualify(r.registry, xpkg.ParsePackageSourceFromReference(ref)), source) {
+			return p, nil
+		}
+	}
+	return nil, nil
+}
+
+// upgradeDependency upgrades the auto-installed package in conflict to the
+// minimum version that satisfies the constraints of every package that depends
+// on it. Packages that were not installed by the resolver are never upgraded,
+// and versions the Reconciler's denylist denies or that it rolled back are
+// never upgraded to. Versions are listed using the supplied pull secrets.
+func (r *Reconciler) upgradeDependency(ctx context.Context, log logging.Logger, lock *v1beta1.Lock, c xpkg.Conflict, secrets []string) (RequeueReason, error) { // nolint:gocyclo
+	pack, err := r.autoInstalledPackage(ctx, lock, c.Package)
+	if err != nil {
+		return RequeueUpgradeError, err
+	}
+	if pack == nil {
+		return "", permanent(errors.New(errNotAutoInstalled))
+	}
+
+	ref, err := name.ParseReference(pack.GetSource(), xpkg.DefaultRegistryOptions(r.registry)...)
+	if err != nil {
+		return "", permanent(errors.Wrap(err, errParseSource))
+	}
+
+	v, candidates, err := r.resolver.WithDenylist(r.denylistFor(log, lock)).Upgrade(ctx, withSecrets(r.fetcher, secrets), ref, overrideConstraints(lock, c.Package)...)
+	var derr *xpkg.DependencyError
+	if errors.As(err, &derr) && derr.Reason == xpkg.DependencyFetchTags {
+		return RequeueFetchError, errors.Wrap(err, errFetchTags)
+	}
+	if errors.Is(err, xpkg.ErrNoMatch) {
+		r.tags.Invalidate(repository(ref))
+		return "", permanent(errors.Wrap(err, errNoUpgradeVersion))
+	}
+	if err != nil {
+		return "", permanent(err)
+	}
+
+	// Only a user can decide whether a version we rolled back is safe to
+	// upgrade to again.
+	if v == pack.GetAnnotations()[AnnotationRolledBackFrom] {
+		return "", permanent(errors.Errorf(errRolledBackFmt, c.Package, v))
+	}
+
+	// Downgrading a package may remove API versions that are in use.
+	downgrade := xpkg.IsDowngrade(c.Version, v)
+	if downgrade && !r.downgrades {
+		return "", &downgradeError{msg: fmt.Sprintf(errDowngradeFmt, c.Package, c.Version, v, requirements(c.Requirements))}
+	}
+
+	pack.SetSource(xpkg.VersionedSource(c.Package, v))
+	// The Lock may no longer override the version it was installed at.
+	meta.RemoveAnnotations(pack, AnnotationResolvedOverride)
+	meta.AddAnnotations(pack, provenance(lock, c.Package, v, r.now()))
+	if r.rollbackWindow > 0 {
+		meta.AddAnnotations(pack, map[string]string{AnnotationUpgradedFrom: c.Version})
+	}
+	if err := r.client.Update(ctx, pack); err != nil {
+		return retry(RequeueUpgradeError, err), errors.Wrap(err, errUpdatePackage)
+	}
+	msg := msgUpgradedFmt
+	if downgrade {
+		msg = msgDowngradedFmt
+	}
+	r.record.Event(lock, event.Normal(reasonUpgradeDependency, fmt.Sprintf(msg, c.Package, c.Version, v)))
+	r.record.Event(pack, event.Normal(reasonUpgradeDependency, upgradedMessage(c, v, candidates)))
+	r.recordAudit(ctx, lock, AuditRecord{Action: AuditUpgraded, Source: c.Package, Package: pack.GetName(), Version: v, From: c.Version, Dependents: dependentsOf(c.Requirements)})
+	return "", nil
+}
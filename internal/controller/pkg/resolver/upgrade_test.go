@@ -0,0 +1,367 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-containerregistry/pkg/name"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/fake"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	v1 "github.com/crossplane/crossplane/apis/pkg/v1"
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+	"github.com/crossplane/crossplane/internal/dag"
+	fakedag "github.com/crossplane/crossplane/internal/dag/fake"
+	fakexpkg "github.com/crossplane/crossplane/internal/xpkg/fake"
+)
+
+func TestReconcileUpgrade(t *testing.T) {
+	errBoom := errors.New("boom")
+
+	// A Lock in which provider-aws v0.20.0 is installed, but two
+	// Configurations require newer versions of it.
+	lock := func(annotations map[string]string) func(o client.Object) error {
+		return func(o client.Object) error {
+			l := o.(*v1beta1.Lock)
+			l.SetName("lock")
+			l.SetAnnotations(annotations)
+			l.Packages = []v1beta1.LockPackage{
+				{Source: "crossplane/provider-aws", Version: "v0.20.0"},
+				{Source: "cool/config-a", Dependencies: []v1beta1.Dependency{
+					{Package: "crossplane/provider-aws", Constraints: ">=v0.21.0"},
+				}},
+				{Source: "cool/config-b", Dependencies: []v1beta1.Dependency{
+					{Package: "crossplane/provider-aws", Constraints: "<v1.0.0"},
+				}},
+			}
+			return nil
+		}
+	}
+	allowed := map[string]string{AnnotationUpgradeDependencies: "true"}
+
+	provider := func(labels map[string]string) func(o client.ObjectList) error {
+		return func(o client.ObjectList) error {
+			if l, ok := o.(*v1.ProviderList); ok {
+				p := v1.Provider{ObjectMeta: metav1.ObjectMeta{Name: "crossplane-provider-aws", Labels: labels}}
+				p.SetSource("crossplane/provider-aws:v0.20.0")
+				l.Items = []v1.Provider{p}
+			}
+			return nil
+		}
+	}
+	auto := map[string]string{LabelAutoInstalled: "true"}
+
+	type want struct {
+		source  string
+		r       reconcile.Result
+		events  []event.Reason
+		objects []string
+		listed  []string
+	}
+
+	cases := map[string]struct {
+		reason   string
+		registry string
+		get      func(o client.Object) error
+		list     func(o client.ObjectList) error
+		tags     []string
+		tagsErr  error
+		update   error
+		want     want
+	}{
+		"Upgraded": {
+			reason: "We should upgrade an auto-installed package to the minimum version that satisfies all constraints.",
+			get:    lock(allowed),
+			list:   provider(auto),
+			tags:   []string{"v0.20.0", "v0.21.0", "v0.22.0", "v1.0.0"},
+			want: want{
+				source:  "crossplane/provider-aws:v0.21.0",
+				events:  []event.Reason{reasonVersionConflict, reasonUpgradeDependency, reasonUpgradeDependency},
+				objects: []string{"lock", "lock", "crossplane-provider-aws"},
+			},
+		},
+		"DefaultRegistry": {
+			reason:   "We should list the versions of a package whose source doesn't specify a registry from the default registry.",
+			registry: "xpkg.example.org",
+			get:      lock(allowed),
+			list:     provider(auto),
+			tags:     []string{"v0.20.0", "v0.21.0"},
+			want: want{
+				source:  "xpkg.example.org/crossplane/provider-aws:v0.21.0",
+				events:  []event.Reason{reasonVersionConflict, reasonUpgradeDependency, reasonUpgradeDependency},
+				objects: []string{"lock", "lock", "crossplane-provider-aws"},
+				listed:  []string{"xpkg.example.org/crossplane/provider-aws"},
+			},
+		},
+		"NotAllowed": {
+			reason: "We should not upgrade a package unless the Lock allows it.",
+			get:    lock(nil),
+			list:   provider(auto),
+			tags:   []string{"v0.21.0"},
+			want: want{
+				events: []event.Reason{reasonVersionConflict},
+			},
+		},
+		"NotAutoInstalled": {
+			reason: "We should never upgrade a package that was not installed by the resolver.",
+			get:    lock(allowed),
+			list: func(o client.ObjectList) error {
+				// The package exists, but is not labelled auto-installed,
+				// so it doesn't match the label selector.
+				return nil
+			},
+			tags: []string{"v0.21.0"},
+			want: want{
+				events: []event.Reason{reasonVersionConflict, reasonUpgradeDependency},
+			},
+		},
+		"NoValidVersion": {
+			reason: "We should leave the package alone if no version satisfies all constraints.",
+			get:    lock(allowed),
+			list:   provider(auto),
+			tags:   []string{"v0.20.0", "v1.0.0"},
+			want: want{
+				events: []event.Reason{reasonVersionConflict, reasonUpgradeDependency},
+			},
+		},
+		"ErrFetchTags": {
+			reason:  "We should requeue if we cannot fetch tags to confirm the upgrade version exists.",
+			get:     lock(allowed),
+			list:    provider(auto),
+			tagsErr: errBoom,
+			want: want{
+				r:      reconcile.Result{RequeueAfter: shortWait},
+				events: []event.Reason{reasonVersionConflict, reasonUpgradeDependency},
+			},
+		},
+		"ErrUpdate": {
+			reason: "We should requeue if we cannot update the package.",
+			get:    lock(allowed),
+			list:   provider(auto),
+			tags:   []string{"v0.21.0"},
+			update: errBoom,
+			want: want{
+				source: "crossplane/provider-aws:v0.21.0",
+				r:      reconcile.Result{RequeueAfter: shortWait},
+				events: []event.Reason{reasonVersionConflict, reasonUpgradeDependency},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			source := ""
+			mgr := &fake.Manager{
+				Client: &test.MockClient{
+					MockGet:  test.NewMockGetFn(nil, tc.get),
+					MockList: test.NewMockListFn(nil, tc.list),
+					MockUpdate: func(_ context.Context, o client.Object, _ ...client.UpdateOption) error {
+						if p, ok := o.(v1.Package); ok {
+							source = p.GetSource()
+							return tc.update
+						}
+						return nil
+					},
+					MockStatusUpdate: test.NewMockStatusUpdateFn(nil),
+				},
+			}
+			rec := &recorder{}
+			f := &listingFetcher{MockFetcher: &fakexpkg.MockFetcher{MockTags: fakexpkg.NewMockTagsFn(tc.tags, tc.tagsErr)}}
+			r := NewReconciler(mgr,
+				WithUpgradeDependencies(),
+				WithDefaultRegistry(tc.registry),
+				WithRecorder(rec),
+				WithNewDagFn(func() dag.DAG {
+					return &fakedag.MockDag{
+						MockInit:           func(_ []dag.Node, _ ...dag.NodeFn) ([]dag.ImpliedNode, error) { return nil, nil },
+						MockNodeDependents: func(_ string) ([]dag.Node, error) { return nil, nil },
+
+						MockSort: func() ([]string, error) { return nil, nil },
+					}
+				}),
+				WithFetcher(f),
+			)
+			got, _ := r.Reconcile(context.Background(), reconcile.Request{})
+
+			if diff := cmp.Diff(tc.want.source, source); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want source, +got source:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.r, got); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want, +got:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.events, rec.reasons); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want events, +got events:\n%s", tc.reason, diff)
+			}
+			if tc.want.objects != nil {
+				if diff := cmp.Diff(tc.want.objects, rec.objects); diff != "" {
+					t.Errorf("\n%s\nr.Reconcile(...): -want event objects, +got event objects:\n%s", tc.reason, diff)
+				}
+			}
+			if tc.want.listed != nil {
+				if diff := cmp.Diff(tc.want.listed, f.listed); diff != "" {
+					t.Errorf("\n%s\nr.Reconcile(...): -want listed repositories, +got listed repositories:\n%s", tc.reason, diff)
+				}
+			}
+		})
+	}
+}
+
+// A listingFetcher records the repositories it lists tags of.
+type listingFetcher struct {
+	*fakexpkg.MockFetcher
+	listed []string
+}
+
+func (f *listingFetcher) Tags(ctx context.Context, ref name.Reference, secrets ...string) ([]string, error) {
+	f.listed = append(f.listed, ref.Context().Name())
+	return f.MockFetcher.Tags(ctx, ref, secrets...)
+}
+
+func TestReconcileDowngrade(t *testing.T) {
+	// A Lock in which provider-aws is installed at the supplied version, and a
+	// Configuration constrains it.
+	lock := func(version, constraint string) func(o client.Object) error {
+		return func(o client.Object) error {
+			l := o.(*v1beta1.Lock)
+			l.SetName("lock")
+			l.SetAnnotations(map[string]string{AnnotationUpgradeDependencies: "true"})
+			l.Packages = []v1beta1.LockPackage{
+				{Source: "crossplane/provider-aws", Version: version},
+				{Source: "cool/config", Dependencies: []v1beta1.Dependency{
+					{Package: "crossplane/provider-aws", Constraints: constraint},
+				}},
+			}
+			return nil
+		}
+	}
+
+	provider := func(o client.ObjectList) error {
+		if l, ok := o.(*v1.ProviderList); ok {
+			p := v1.Provider{ObjectMeta: metav1.ObjectMeta{Name: "crossplane-provider-aws", Labels: map[string]string{LabelAutoInstalled: "true"}}}
+			p.SetSource("crossplane/provider-aws:v0.21.0")
+			l.Items = []v1.Provider{p}
+		}
+		return nil
+	}
+
+	type want struct {
+		source string
+		reason xpv1.ConditionReason
+		events []event.Reason
+	}
+
+	cases := map[string]struct {
+		reason string
+		get    func(o client.Object) error
+		opts   []ReconcilerOption
+		want   want
+	}{
+		"Upgrade": {
+			reason: "We should upgrade a package when its dependents require a newer version.",
+			get:    lock("v0.21.0", ">=v0.22.0+build.1"),
+			want: want{
+				source: "crossplane/provider-aws:v0.22.0+build.1",
+				reason: v1beta1.ReasonVersionConflict,
+				events: []event.Reason{reasonVersionConflict, reasonUpgradeDependency, reasonUpgradeDependency},
+			},
+		},
+		"NoOp": {
+			reason: "We should leave a package alone when its installed version satisfies its dependents.",
+			get:    lock("v0.21.0", ">=v0.20.0"),
+			want: want{
+				reason: v1beta1.ReasonResolved,
+			},
+		},
+		"DowngradeRefused": {
+			reason: "We should refuse to downgrade a package, and explain which dependent requires the older version.",
+			get:    lock("v0.21.0", "<v0.21.0"),
+			want: want{
+				reason: v1beta1.ReasonDowngradeRefused,
+				events: []event.Reason{reasonVersionConflict, reasonRefuseDowngrade},
+			},
+		},
+		"DowngradeAllowed": {
+			reason: "We should downgrade a package to the lowest version its dependents allow if downgrades are allowed.",
+			get:    lock("v0.21.0", "<v0.21.0"),
+			opts:   []ReconcilerOption{WithAllowDowngrades()},
+			want: want{
+				source: "crossplane/provider-aws:v0.19.0",
+				reason: v1beta1.ReasonVersionConflict,
+				events: []event.Reason{reasonVersionConflict, reasonUpgradeDependency, reasonUpgradeDependency},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			source := ""
+			stored := &v1beta1.Lock{}
+			mgr := &fake.Manager{
+				Client: &test.MockClient{
+					MockGet:  test.NewMockGetFn(nil, tc.get),
+					MockList: test.NewMockListFn(nil, provider),
+					MockUpdate: func(_ context.Context, o client.Object, _ ...client.UpdateOption) error {
+						if p, ok := o.(v1.Package); ok {
+							source = p.GetSource()
+						}
+						return nil
+					},
+					MockStatusUpdate: func(_ context.Context, o client.Object, _ ...client.UpdateOption) error {
+						o.(*v1beta1.Lock).Status.DeepCopyInto(&stored.Status)
+						return nil
+					},
+				},
+			}
+			rec := &recorder{}
+			opts := append([]ReconcilerOption{
+				WithUpgradeDependencies(),
+				WithRecorder(rec),
+				WithNewDagFn(func() dag.DAG {
+					return &fakedag.MockDag{
+						MockInit:           func(_ []dag.Node, _ ...dag.NodeFn) ([]dag.ImpliedNode, error) { return nil, nil },
+						MockNodeDependents: func(_ string) ([]dag.Node, error) { return nil, nil },
+
+						MockSort: func() ([]string, error) { return nil, nil },
+					}
+				}),
+				WithFetcher(&fakexpkg.MockFetcher{MockTags: fakexpkg.NewMockTagsFn([]string{"v0.19.0", "v0.20.0", "v0.21.0", "v0.22.0+build.1"}, nil)}),
+			}, tc.opts...)
+			r := NewReconciler(mgr, opts...)
+			_, _ = r.Reconcile(context.Background(), reconcile.Request{})
+
+			if diff := cmp.Diff(tc.want.source, source); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want source, +got source:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.reason, stored.GetCondition(v1beta1.TypeDependenciesResolved).Reason); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want condition reason, +got condition reason:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.events, rec.reasons); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want events, +got events:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"fmt"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+	"github.com/crossplane/crossplane/internal/xpkg"
+)
+
+const (
+	errInvalidDependencyFmt = "package %s declares invalid dependency %d"
+	msgInvalidDependencyFmt = "invalid dependency %d"
+)
+
+// rejectInvalid removes the dependencies that fail validation from the
+// packages in the supplied Lock, so that they're never resolved or installed,
+// and returns a condition describing each, and a description of the invalid
+// dependencies of each package by its index in the Lock. Dependencies are
+// declared by package authors, so we identify an invalid dependency by the
+// package that declares it and its index rather than echoing it. The Lock must
+// not be updated once its invalid dependencies are removed.
+func (r *Reconciler) rejectInvalid(log logging.Logger, lock *v1beta1.Lock) ([]xpv1.Condition, map[int][]string) {
+	var failed []xpv1.Condition
+	rejected := map[int][]string{}
+	for i := range lock.Packages {
+		p := &lock.Packages[i]
+		valid := p.Dependencies[:0:0]
+		for j, d := range p.Dependencies {
+			err := xpkg.ValidateDependency(d)
+			if err == nil {
+				valid = append(valid, d)
+				continue
+			}
+			err = errors.Wrapf(err, errInvalidDependencyFmt, truncate(p.Source, xpkg.MaxPackageLength), j)
+			log.Debug(errInstallDependency, "error", err)
+			r.record.Event(lock, event.Warning(reasonInvalidDependency, err))
+			r.metrics.failures.WithLabelValues(failureInvalidDependency).Inc()
+			failed = append(failed, v1beta1.InvalidDependency().WithMessage(err.Error()))
+			rejected[i] = append(rejected[i], fmt.Sprintf(msgInvalidDependencyFmt, j))
+		}
+		if len(valid) < len(p.Dependencies) {
+			p.Dependencies = valid
+		}
+	}
+	return failed, rejected
+}
@@ -0,0 +1,152 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/fake"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	v1 "github.com/crossplane/crossplane/apis/pkg/v1"
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+	"github.com/crossplane/crossplane/internal/dag"
+	fakexpkg "github.com/crossplane/crossplane/internal/xpkg/fake"
+)
+
+func TestReconcileInvalidDependencies(t *testing.T) {
+	valid := v1beta1.Dependency{Package: "crossplane/provider-aws", Type: v1beta1.ProviderPackageType, Constraints: ">=v0.20.0"}
+	hostile := strings.Repeat("A", 100000)
+
+	type want struct {
+		created []string
+		events  []event.Reason
+		msgs    []string
+	}
+
+	cases := map[string]struct {
+		reason string
+		dep    v1beta1.Dependency
+		want   want
+	}{
+		"OversizedConstraints": {
+			reason: "We should reject a dependency with oversized constraints, identifying the package that declares it, and still install its valid siblings.",
+			dep:    v1beta1.Dependency{Package: "crossplane/provider-gcp", Type: v1beta1.ProviderPackageType, Constraints: ">=v0.1.0 " + hostile},
+			want: want{
+				created: []string{"crossplane/provider-aws:v0.20.0"},
+				events:  []event.Reason{reasonInvalidDependency, reasonInstallDependency},
+				msgs: []string{
+					"package cool/config declares invalid dependency 1: dependency version constraints are 100009 characters long; at most 1024 are allowed",
+					"Installed automatically as a dependency of cool/config to satisfy constraint >=v0.20.0, selected version v0.20.0 from 1 available tags",
+				},
+			},
+		},
+		"OversizedPackage": {
+			reason: "We should reject a dependency with an oversized package without parsing or echoing it.",
+			dep:    v1beta1.Dependency{Package: "crossplane/" + hostile, Type: v1beta1.ProviderPackageType, Constraints: ">=v0.1.0"},
+			want: want{
+				created: []string{"crossplane/provider-aws:v0.20.0"},
+				events:  []event.Reason{reasonInvalidDependency, reasonInstallDependency},
+				msgs: []string{
+					"package cool/config declares invalid dependency 1: dependency package is 100011 characters long; at most 255 are allowed",
+					"Installed automatically as a dependency of cool/config to satisfy constraint >=v0.20.0, selected version v0.20.0 from 1 available tags",
+				},
+			},
+		},
+		"YAMLInjection": {
+			reason: "We should reject a dependency whose constraints could inject YAML into the objects we create.",
+			dep:    v1beta1.Dependency{Package: "crossplane/provider-gcp", Type: v1beta1.ProviderPackageType, Constraints: ">=v0.1.0\n  packagePullSecrets:\n  - name: stolen"},
+			want: want{
+				created: []string{"crossplane/provider-aws:v0.20.0"},
+				events:  []event.Reason{reasonInvalidDependency, reasonInstallDependency},
+				msgs: []string{
+					`package cool/config declares invalid dependency 1: dependency version constraints contain disallowed character '\n' at position 8`,
+					"Installed automatically as a dependency of cool/config to satisfy constraint >=v0.20.0, selected version v0.20.0 from 1 available tags",
+				},
+			},
+		},
+	}
+
+	for n, tc := range cases {
+		t.Run(n, func(t *testing.T) {
+			var created []string
+			var status *v1beta1.LockStatus
+			mgr := &fake.Manager{
+				Client: &test.MockClient{
+					MockGet: test.NewMockGetFn(nil, func(o client.Object) error {
+						o.(*v1beta1.Lock).Packages = []v1beta1.LockPackage{{
+							Source:       "cool/config",
+							Dependencies: []v1beta1.Dependency{valid, tc.dep},
+						}}
+						return nil
+					}),
+					MockUpdate: test.NewMockUpdateFn(nil),
+					MockList:   test.NewMockListFn(nil),
+					MockCreate: func(_ context.Context, o client.Object, _ ...client.CreateOption) error {
+						created = append(created, o.(v1.Package).GetSource())
+						return nil
+					},
+					MockStatusUpdate: test.NewMockStatusUpdateFn(nil, func(o client.Object) error {
+						status = o.(*v1beta1.Lock).Status.DeepCopy()
+						return nil
+					}),
+				},
+			}
+			rec := &recorder{}
+			r := NewReconciler(mgr,
+				WithRecorder(rec),
+				WithFetcher(&fakexpkg.MockFetcher{MockTags: fakexpkg.NewMockTagsFn([]string{"v0.20.0"}, nil)}),
+				WithNewDagFn(dag.NewMapDag),
+			)
+			if _, err := r.Reconcile(context.Background(), reconcile.Request{}); err != nil {
+				t.Fatalf("\n%s\nr.Reconcile(...): %s", tc.reason, err)
+			}
+
+			if diff := cmp.Diff(tc.want.created, created); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want created, +got created:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.events, rec.reasons); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want events, +got events:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.msgs, rec.messages); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want messages, +got messages:\n%s", tc.reason, diff)
+			}
+			if status == nil {
+				t.Fatalf("\n%s\nr.Reconcile(...): expected the Lock's status to be updated", tc.reason)
+			}
+			c := status.GetCondition(v1beta1.TypeDependenciesResolved)
+			if diff := cmp.Diff(v1beta1.ReasonInvalidDependency, c.Reason); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want condition, +got condition:\n%s", tc.reason, diff)
+			}
+			if !strings.Contains(c.Message, "package cool/config declares invalid dependency 1") {
+				t.Errorf("\n%s\nr.Reconcile(...): condition %q doesn't identify the package that declares the invalid dependency", tc.reason, c.Message)
+			}
+			for _, m := range append(rec.messages, c.Message) {
+				if strings.Contains(m, hostile[:64]) || strings.Contains(m, "packagePullSecrets") {
+					t.Errorf("\n%s\nr.Reconcile(...): message echoes the invalid dependency: %.200q", tc.reason, m)
+				}
+			}
+		})
+	}
+}
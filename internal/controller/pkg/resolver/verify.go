@@ -0,0 +1,87 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+)
+
+const (
+	errListRevisions = "cannot list package revisions to verify Lock"
+
+	msgStalePackagesFmt = "lock contains packages with no package revision: %s"
+)
+
+// WithLockVerification specifies that the Reconciler should verify that each
+// package in the Lock is backed by a package revision. A package whose
+// revision no longer exists, e.g. because it was deleted with its finalizers
+// removed, is stale. Stale packages are reported, and dependencies are
+// resolved as if they weren't in the Lock, so that they are installed again
+// if other packages depend on them. The Lock itself is never updated.
+func WithLockVerification() ReconcilerOption {
+	return func(r *Reconciler) {
+		r.verifyLock = true
+	}
+}
+
+// backed returns the supplied packages that are backed by a package revision
+// from the same repository, and those that are stale, according to revisions
+// read from the supplied reader. Revisions are usually read from the API
+// server rather than the cache, so that a revision that was created very
+// recently doesn't appear to be missing.
+func (r *Reconciler) backed(ctx context.Context, c client.Reader, pkgs []v1beta1.LockPackage) ([]v1beta1.LockPackage, []v1beta1.LockPackage, error) {
+	revs := map[v1beta1.PackageType]map[string]string{}
+	for t, k := range packageKinds {
+		l := k.newPackageRevisionList()
+		if err := c.List(ctx, l); err != nil {
+			return nil, nil, errors.Wrap(err, errListRevisions)
+		}
+		revs[t] = map[string]string{}
+		for _, rev := range l.GetRevisions() {
+			revs[t][rev.GetName()] = r.sourceRepository(rev.GetSource())
+		}
+	}
+
+	fresh := make([]v1beta1.LockPackage, 0, len(pkgs))
+	var stale []v1beta1.LockPackage
+	for _, p := range pkgs {
+		repo, ok := revs[p.Type][p.Name]
+		if ok && repo == r.sourceRepository(p.Source) {
+			fresh = append(fresh, p)
+			continue
+		}
+		stale = append(stale, p)
+	}
+	return fresh, stale, nil
+}
+
+// describeStale describes the supplied stale packages.
+func describeStale(stale []v1beta1.LockPackage) string {
+	s := make([]string, len(stale))
+	for i, p := range stale {
+		s[i] = fmt.Sprintf("%s (revision %s)", p.Source, p.Name)
+	}
+	return strings.Join(s, ", ")
+}
@@ -0,0 +1,199 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/fake"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	v1 "github.com/crossplane/crossplane/apis/pkg/v1"
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+	"github.com/crossplane/crossplane/internal/dag"
+	fakexpkg "github.com/crossplane/crossplane/internal/xpkg/fake"
+)
+
+func TestReconcileLockVerification(t *testing.T) {
+	errBoom := errors.New("boom")
+
+	// config-a depends on provider-aws. Both are in the Lock.
+	lock := []v1beta1.LockPackage{
+		{
+			Name:    "crossplane-config-a-1234",
+			Source:  "crossplane/config-a",
+			Type:    v1beta1.ConfigurationPackageType,
+			Version: "v1.0.0",
+			Dependencies: []v1beta1.Dependency{
+				{Package: "crossplane/provider-aws", Type: v1beta1.ProviderPackageType, Constraints: ">=v0.20.0"},
+			},
+		},
+		{
+			Name:    "crossplane-provider-aws-5678",
+			Source:  "crossplane/provider-aws",
+			Type:    v1beta1.ProviderPackageType,
+			Version: "v0.20.0",
+		},
+	}
+	configRev := v1.ConfigurationRevision{ObjectMeta: metav1.ObjectMeta{Name: "crossplane-config-a-1234"}}
+	configRev.SetSource("crossplane/config-a:v1.0.0")
+	providerRev := func(source string) []v1.ProviderRevision {
+		r := v1.ProviderRevision{ObjectMeta: metav1.ObjectMeta{Name: "crossplane-provider-aws-5678"}}
+		r.SetSource(source)
+		return []v1.ProviderRevision{r}
+	}
+
+	type args struct {
+		verify    bool
+		providers []v1.ProviderRevision
+		listErr   error
+	}
+	type want struct {
+		r       reconcile.Result
+		created []string
+		events  []event.Reason
+		reason  xpv1.ConditionReason
+		message string
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"NotVerified": {
+			reason: "We should trust the Lock unless verification is enabled.",
+			args:   args{},
+			want:   want{reason: v1beta1.ReasonResolved},
+		},
+		"Backed": {
+			reason: "We should resolve dependencies as usual if every package in the Lock is backed by a revision.",
+			args:   args{verify: true, providers: providerRev("crossplane/provider-aws:v0.20.0")},
+			want:   want{reason: v1beta1.ReasonResolved},
+		},
+		"Stale": {
+			reason: "We should report a package whose revision no longer exists, and install it again for the packages that depend on it.",
+			args:   args{verify: true},
+			want: want{
+				created: []string{"crossplane-provider-aws"},
+				events:  []event.Reason{reasonStalePackages, reasonInstallDependency},
+				reason:  v1beta1.ReasonStalePackages,
+				message: "lock contains packages with no package revision: crossplane/provider-aws (revision crossplane-provider-aws-5678)",
+			},
+		},
+		"RevisionFromOtherRepository": {
+			reason: "We should consider a package stale if the revision it names is from another repository.",
+			args:   args{verify: true, providers: providerRev("crossplane/provider-gcp:v0.20.0")},
+			want: want{
+				created: []string{"crossplane-provider-aws"},
+				events:  []event.Reason{reasonStalePackages, reasonInstallDependency},
+				reason:  v1beta1.ReasonStalePackages,
+				message: "lock contains packages with no package revision: crossplane/provider-aws (revision crossplane-provider-aws-5678)",
+			},
+		},
+		"ErrListRevisions": {
+			reason: "We should requeue without resolving dependencies if we cannot list package revisions.",
+			args:   args{verify: true, listErr: errBoom},
+			want: want{
+				r:      reconcile.Result{RequeueAfter: shortWait},
+				events: []event.Reason{reasonStalePackages},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var created []string
+			status := &v1beta1.Lock{}
+			mgr := &fake.Manager{
+				Client: &test.MockClient{
+					MockGet: test.NewMockGetFn(nil, func(o client.Object) error {
+						l, ok := o.(*v1beta1.Lock)
+						if !ok {
+							return kerrors.NewNotFound(schema.GroupResource{}, o.GetName())
+						}
+						l.Packages = append([]v1beta1.LockPackage{}, lock...)
+						return nil
+					}),
+					MockList: func(_ context.Context, o client.ObjectList, _ ...client.ListOption) error {
+						switch l := o.(type) {
+						case *v1.ConfigurationRevisionList:
+							l.Items = []v1.ConfigurationRevision{configRev}
+						case *v1.ProviderRevisionList:
+							if tc.args.listErr != nil {
+								return tc.args.listErr
+							}
+							l.Items = tc.args.providers
+						}
+						return nil
+					},
+					MockCreate: func(_ context.Context, o client.Object, _ ...client.CreateOption) error {
+						created = append(created, o.GetName())
+						return nil
+					},
+					MockUpdate: test.NewMockUpdateFn(nil),
+					MockStatusUpdate: test.NewMockStatusUpdateFn(nil, func(o client.Object) error {
+						status = o.(*v1beta1.Lock)
+						return nil
+					}),
+				},
+			}
+			rec := &recorder{}
+			opts := []ReconcilerOption{
+				WithRecorder(rec),
+				WithFetcher(&fakexpkg.MockFetcher{MockTags: fakexpkg.NewMockTagsFn([]string{"v0.20.0"}, nil)}),
+				WithNewDagFn(dag.NewMapDag),
+			}
+			if tc.args.verify {
+				opts = append(opts, WithLockVerification())
+			}
+			r := NewReconciler(mgr, opts...)
+			got, err := r.Reconcile(context.Background(), reconcile.Request{})
+			if err != nil {
+				t.Fatalf("\n%s\nr.Reconcile(...): %s", tc.reason, err)
+			}
+
+			if diff := cmp.Diff(tc.want.r, got); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want, +got:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.created, created); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want created, +got created:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.events, rec.reasons); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want events, +got events:\n%s", tc.reason, diff)
+			}
+			c := status.GetCondition(v1beta1.TypeDependenciesResolved)
+			if diff := cmp.Diff(tc.want.reason, c.Reason); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want condition reason, +got condition reason:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.message, c.Message); diff != "" {
+				t.Errorf("\n%s\nr.Reconcile(...): -want condition message, +got condition message:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
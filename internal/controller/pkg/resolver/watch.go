@@ -0,0 +1,132 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"context"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+
+	v1 "github.com/crossplane/crossplane/apis/pkg/v1"
+	"github.com/crossplane/crossplane/apis/pkg/v1alpha1"
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+)
+
+// lockName is the name of the singleton Lock.
+const lockName = "lock"
+
+// watchTimeout bounds how long we wait to read the package of a watched package
+// revision. The watch handlers of this version of controller-runtime aren't
+// passed a context, so this is the only bound on the read.
+const watchTimeout = 10 * time.Second
+
+// LabelLock is added to packages the resolver installs. Its value is the name
+// of the Lock whose packages depend on them. The resolver only considers the
+// packages it installed for a Lock when it resolves that Lock's dependencies.
+const LabelLock = "pkg.crossplane.io/lock"
+
+// lockOf returns the name of the Lock the supplied object is associated with.
+// Packages the resolver installed before it labeled them with their Lock are
+// associated with the Lock that controls them, if any, and otherwise with the
+// singleton Lock.
+func lockOf(o metav1.Object) string {
+	if n := o.GetLabels()[LabelLock]; n != "" {
+		return n
+	}
+	if ref := metav1.GetControllerOf(o); ref != nil && ref.Kind == v1beta1.LockKind {
+		return ref.Name
+	}
+	return lockName
+}
+
+// lockRequest maps an object to a request to reconcile the Lock it is
+// associated with.
+func lockRequest(o client.Object) []reconcile.Request {
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: lockOf(o)}}}
+}
+
+// revisionLockRequest returns a function that maps a package revision to a
+// request to reconcile the Lock its package is associated with. Revisions
+// aren't associated with a Lock themselves, so we read their package. We fall
+// back to the revision's own association if we can't.
+func revisionLockRequest(c client.Reader) handler.MapFunc {
+	return func(o client.Object) []reconcile.Request {
+		var pack client.Object
+		switch o.(type) {
+		case *v1.ProviderRevision:
+			pack = &v1.Provider{}
+		case *v1.ConfigurationRevision:
+			pack = &v1.Configuration{}
+		case *v1alpha1.FunctionRevision:
+			pack = &v1alpha1.Function{}
+		default:
+			return lockRequest(o)
+		}
+		n := o.GetLabels()[v1.LabelParentPackage]
+		if n == "" {
+			return lockRequest(o)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), watchTimeout)
+		defer cancel()
+		if err := c.Get(ctx, types.NamespacedName{Name: n}, pack); err != nil {
+			return lockRequest(o)
+		}
+		return lockRequest(pack)
+	}
+}
+
+// packageChanged passes events that may leave a dependency missing, i.e. when
+// a package is deleted or its source is changed, and events that may unblock
+// a missing dependency, i.e. when an auto-installed package becomes installed
+// or healthy. Packages add themselves to the Lock once they're installed, so
+// we don't need to know when they're created, and we ignore other frequent
+// updates to their status.
+func packageChanged() predicate.Funcs {
+	return predicate.Funcs{
+		CreateFunc:  func(_ event.CreateEvent) bool { return false },
+		DeleteFunc:  func(_ event.DeleteEvent) bool { return true },
+		GenericFunc: func(_ event.GenericEvent) bool { return false },
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			o, ook := e.ObjectOld.(v1.Package)
+			n, nok := e.ObjectNew.(v1.Package)
+			if !ook || !nok {
+				return false
+			}
+			if o.GetSource() != n.GetSource() {
+				return true
+			}
+			if n.GetLabels()[LabelAutoInstalled] != "true" {
+				return false
+			}
+			for _, ct := range []xpv1.ConditionType{v1.TypeInstalled, v1.TypeHealthy} {
+				if o.GetCondition(ct).Status != n.GetCondition(ct).Status {
+					return true
+				}
+			}
+			return false
+		},
+	}
+}
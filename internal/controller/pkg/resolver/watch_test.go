@@ -0,0 +1,344 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resolver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/fake"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	v1 "github.com/crossplane/crossplane/apis/pkg/v1"
+	"github.com/crossplane/crossplane/apis/pkg/v1alpha1"
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+	"github.com/crossplane/crossplane/internal/dag"
+	fakexpkg "github.com/crossplane/crossplane/internal/xpkg/fake"
+)
+
+func TestLockRequest(t *testing.T) {
+	ctrl := true
+	cases := map[string]struct {
+		reason string
+		o      client.Object
+		want   string
+	}{
+		"Unassociated": {
+			reason: "We should enqueue the singleton Lock for a package that isn't associated with a Lock.",
+			o:      &v1.Provider{},
+			want:   lockName,
+		},
+		"Labeled": {
+			reason: "We should enqueue the Lock a package is labeled with.",
+			o:      &v1.Configuration{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{LabelLock: "lock-a"}}},
+			want:   "lock-a",
+		},
+		"Controlled": {
+			reason: "We should enqueue the Lock that controls a package that isn't labeled with a Lock.",
+			o: &v1.Provider{ObjectMeta: metav1.ObjectMeta{OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: v1beta1.LockGroupVersionKind.GroupVersion().String(),
+				Kind:       v1beta1.LockKind,
+				Name:       "lock-b",
+				Controller: &ctrl,
+			}}}},
+			want: "lock-b",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			want := []reconcile.Request{{NamespacedName: types.NamespacedName{Name: tc.want}}}
+			if diff := cmp.Diff(want, lockRequest(tc.o)); diff != "" {
+				t.Errorf("\n%s\nlockRequest(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestRevisionLockRequest(t *testing.T) {
+	revision := func(parent string) *v1.ProviderRevision {
+		return &v1.ProviderRevision{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{v1.LabelParentPackage: parent}}}
+	}
+
+	cases := map[string]struct {
+		reason string
+		get    test.MockGetFn
+		o      client.Object
+		want   string
+	}{
+		"PackageLock": {
+			reason: "We should enqueue the Lock a revision's package is associated with.",
+			get: test.NewMockGetFn(nil, func(o client.Object) error {
+				if _, ok := o.(*v1.Provider); !ok {
+					t.Errorf("revisionLockRequest(...): got %T, want *v1.Provider", o)
+				}
+				o.SetLabels(map[string]string{LabelLock: "lock-a"})
+				return nil
+			}),
+			o:    revision("crossplane-provider-aws"),
+			want: "lock-a",
+		},
+		"FunctionLock": {
+			reason: "We should enqueue the Lock a Function revision's Function is associated with.",
+			get: test.NewMockGetFn(nil, func(o client.Object) error {
+				if _, ok := o.(*v1alpha1.Function); !ok {
+					t.Errorf("revisionLockRequest(...): got %T, want *v1alpha1.Function", o)
+				}
+				o.SetLabels(map[string]string{LabelLock: "lock-a"})
+				return nil
+			}),
+			o:    &v1alpha1.FunctionRevision{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{v1.LabelParentPackage: "crossplane-function-nop"}}},
+			want: "lock-a",
+		},
+		"BoundedGet": {
+			reason: "We should bound how long we wait to get a revision's package.",
+			get: func(ctx context.Context, _ client.ObjectKey, o client.Object) error {
+				if _, ok := ctx.Deadline(); !ok {
+					return errors.New("no deadline")
+				}
+				o.SetLabels(map[string]string{LabelLock: "lock-a"})
+				return nil
+			},
+			o:    revision("crossplane-provider-aws"),
+			want: "lock-a",
+		},
+		"NoParent": {
+			reason: "We should enqueue the singleton Lock for a revision that doesn't name its package.",
+			get:    test.NewMockGetFn(errors.New("boom")),
+			o:      &v1.ConfigurationRevision{},
+			want:   lockName,
+		},
+		"ErrGetPackage": {
+			reason: "We should enqueue the singleton Lock if we can't get a revision's package.",
+			get:    test.NewMockGetFn(errors.New("boom")),
+			o:      revision("crossplane-provider-aws"),
+			want:   lockName,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			want := []reconcile.Request{{NamespacedName: types.NamespacedName{Name: tc.want}}}
+			got := revisionLockRequest(&test.MockClient{MockGet: tc.get})(tc.o)
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Errorf("\n%s\nrevisionLockRequest(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestReconcileMultipleLocks(t *testing.T) {
+	// Both Locks depend on provider-aws. Lock A has already used its
+	// auto-install budget to install provider-gcp.
+	locks := map[string][]v1beta1.LockPackage{
+		"lock-a": {
+			{Source: "cool/config-a", Dependencies: []v1beta1.Dependency{
+				{Package: "crossplane/provider-aws", Type: v1beta1.ProviderPackageType, Constraints: ">=v0.20.0"},
+				{Package: "crossplane/provider-gcp", Type: v1beta1.ProviderPackageType, Constraints: ">=v0.20.0"},
+			}},
+			{Source: "crossplane/provider-gcp", Version: "v0.20.0"},
+		},
+		"lock-b": {
+			{Source: "cool/config-b", Dependencies: []v1beta1.Dependency{
+				{Package: "crossplane/provider-aws", Type: v1beta1.ProviderPackageType, Constraints: ">=v0.20.0"},
+			}},
+		},
+	}
+	gcp := v1.Provider{ObjectMeta: metav1.ObjectMeta{
+		Name:        "crossplane-provider-gcp",
+		Labels:      map[string]string{LabelAutoInstalled: "true", LabelLock: "lock-a"},
+		Annotations: map[string]string{AnnotationRequiredBy: "cool/config-a"},
+	}}
+	gcp.SetSource("crossplane/provider-gcp:v0.20.0")
+
+	installed := []v1.Provider{gcp}
+	created := map[string]string{}
+	orphaned := []string{}
+	status := map[string]xpv1.ConditionReason{}
+	mgr := &fake.Manager{
+		Client: &test.MockClient{
+			MockGet: func(_ context.Context, key client.ObjectKey, o client.Object) error {
+				l, ok := o.(*v1beta1.Lock)
+				if !ok {
+					return kerrors.NewNotFound(schema.GroupResource{}, key.Name)
+				}
+				l.SetName(key.Name)
+				l.Packages = locks[key.Name]
+				return nil
+			},
+			MockList: test.NewMockListFn(nil, func(o client.ObjectList) error {
+				if l, ok := o.(*v1.ProviderList); ok {
+					l.Items = installed
+				}
+				return nil
+			}),
+			MockCreate: func(_ context.Context, o client.Object, _ ...client.CreateOption) error {
+				created[o.GetName()] = o.GetLabels()[LabelLock]
+				installed = append(installed, *o.(*v1.Provider))
+				return nil
+			},
+			MockUpdate: test.NewMockUpdateFn(nil, func(o client.Object) error {
+				if _, ok := o.GetLabels()[LabelOrphaned]; ok {
+					orphaned = append(orphaned, o.GetName())
+				}
+				return nil
+			}),
+			MockStatusUpdate: test.NewMockStatusUpdateFn(nil, func(o client.Object) error {
+				status[o.GetName()] = o.(*v1beta1.Lock).GetCondition(v1beta1.TypeDependenciesResolved).Reason
+				return nil
+			}),
+		},
+	}
+	r := NewReconciler(mgr,
+		WithRecorder(&recorder{}),
+		WithDependencyOwnership(DependencyGCOrphan),
+		WithMaxAutoInstall(1),
+		WithFetcher(&fakexpkg.MockFetcher{MockTags: fakexpkg.NewMockTagsFn([]string{"v0.20.0"}, nil)}),
+		WithNewDagFn(dag.NewMapDag),
+	)
+	for _, n := range []string{"lock-a", "lock-b"} {
+		if _, err := r.Reconcile(context.Background(), reconcile.Request{NamespacedName: types.NamespacedName{Name: n}}); err != nil {
+			t.Fatalf("r.Reconcile(%s): %s", n, err)
+		}
+	}
+
+	// Lock B's budget isn't spent by the packages installed for Lock A.
+	if diff := cmp.Diff(map[string]string{"crossplane-provider-aws": "lock-b"}, created); diff != "" {
+		t.Errorf("r.Reconcile(...): -want created, +got created:\n%s", diff)
+	}
+	// Lock B doesn't orphan the packages installed for Lock A.
+	if diff := cmp.Diff([]string{}, orphaned); diff != "" {
+		t.Errorf("r.Reconcile(...): -want orphaned, +got orphaned:\n%s", diff)
+	}
+	want := map[string]xpv1.ConditionReason{
+		"lock-a": v1beta1.ReasonLimitReached,
+		"lock-b": v1beta1.ReasonMissingDependency,
+	}
+	if diff := cmp.Diff(want, status); diff != "" {
+		t.Errorf("r.Reconcile(...): -want status, +got status:\n%s", diff)
+	}
+}
+
+func TestPackageChanged(t *testing.T) {
+	provider := func(source string, c ...xpv1.Condition) *v1.Provider {
+		p := &v1.Provider{}
+		p.SetSource(source)
+		p.SetConditions(c...)
+		return p
+	}
+	configuration := func(source string) *v1.Configuration {
+		c := &v1.Configuration{}
+		c.SetSource(source)
+		return c
+	}
+	autoInstalled := func(p *v1.Provider) *v1.Provider {
+		p.SetLabels(map[string]string{LabelAutoInstalled: "true"})
+		return p
+	}
+	p := packageChanged()
+
+	cases := map[string]struct {
+		reason string
+		passes func() bool
+		want   bool
+	}{
+		"Create": {
+			reason: "We should ignore a package being created; it adds itself to the Lock once installed.",
+			passes: func() bool { return p.Create(event.CreateEvent{Object: provider("crossplane/provider-aws:v0.20.0")}) },
+			want:   false,
+		},
+		"Delete": {
+			reason: "We should pass a package being deleted, because it may leave a dependency missing.",
+			passes: func() bool {
+				return p.Delete(event.DeleteEvent{Object: configuration("crossplane/getting-started:v1.0.0")})
+			},
+			want: true,
+		},
+		"Generic": {
+			reason: "We should ignore generic events.",
+			passes: func() bool { return p.Generic(event.GenericEvent{Object: provider("crossplane/provider-aws:v0.20.0")}) },
+			want:   false,
+		},
+		"SourceChanged": {
+			reason: "We should pass an update that changes the package's source.",
+			passes: func() bool {
+				return p.Update(event.UpdateEvent{
+					ObjectOld: provider("crossplane/provider-aws:v0.20.0"),
+					ObjectNew: provider("crossplane/provider-aws:v0.21.0"),
+				})
+			},
+			want: true,
+		},
+		"AutoInstalledHealthChanged": {
+			reason: "We should pass an update that changes whether an auto-installed package is healthy, because it may unblock its dependencies.",
+			passes: func() bool {
+				return p.Update(event.UpdateEvent{
+					ObjectOld: autoInstalled(provider("crossplane/provider-aws:v0.20.0", v1.Unhealthy())),
+					ObjectNew: autoInstalled(provider("crossplane/provider-aws:v0.20.0", v1.Healthy())),
+				})
+			},
+			want: true,
+		},
+		"AutoInstalledHealthUnchanged": {
+			reason: "We should ignore an update that doesn't change whether an auto-installed package is installed or healthy.",
+			passes: func() bool {
+				return p.Update(event.UpdateEvent{
+					ObjectOld: autoInstalled(provider("crossplane/provider-aws:v0.20.0", v1.Healthy())),
+					ObjectNew: autoInstalled(provider("crossplane/provider-aws:v0.20.0", v1.Healthy().WithMessage("still healthy"))),
+				})
+			},
+			want: false,
+		},
+		"StatusChanged": {
+			reason: "We should ignore an update that doesn't change the source of a package that wasn't auto-installed.",
+			passes: func() bool {
+				return p.Update(event.UpdateEvent{
+					ObjectOld: provider("crossplane/provider-aws:v0.20.0"),
+					ObjectNew: provider("crossplane/provider-aws:v0.20.0", v1.Healthy()),
+				})
+			},
+			want: false,
+		},
+		"NotAPackage": {
+			reason: "We should ignore updates to objects that aren't packages.",
+			passes: func() bool {
+				return p.Update(event.UpdateEvent{ObjectOld: &corev1.ConfigMap{}, ObjectNew: &corev1.ConfigMap{}})
+			},
+			want: false,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if diff := cmp.Diff(tc.want, tc.passes()); diff != "" {
+				t.Errorf("\n%s\npackageChanged(): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -18,9 +18,9 @@ package revision
 
 import (
 	"context"
+	"strings"
 
 	"github.com/Masterminds/semver"
-	"github.com/google/go-containerregistry/pkg/name"
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
@@ -32,6 +32,7 @@ import (
 	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
 	"github.com/crossplane/crossplane/internal/dag"
 	"github.com/crossplane/crossplane/internal/xpkg"
+	"github.com/crossplane/crossplane/internal/xpkg/canonical"
 )
 
 const (
@@ -75,19 +76,7 @@ func (m *PackageDependencyManager) Resolve(ctx context.Context, pkg runtime.Obje
 	}
 
 	// Copy package dependencies into Lock Dependencies.
-	sources := make([]v1beta1.Dependency, len(pack.GetDependencies()))
-	for i, dep := range pack.GetDependencies() {
-		pdep := v1beta1.Dependency{}
-		if dep.Configuration != nil {
-			pdep.Package = *dep.Configuration
-			pdep.Type = v1beta1.ConfigurationPackageType
-		} else if dep.Provider != nil {
-			pdep.Package = *dep.Provider
-			pdep.Type = v1beta1.ProviderPackageType
-		}
-		pdep.Constraints = dep.Version
-		sources[i] = pdep
-	}
+	sources := xpkg.Dependencies(pack)
 
 	found = len(sources)
 
@@ -107,15 +96,14 @@ func (m *PackageDependencyManager) Resolve(ctx context.Context, pkg runtime.Obje
 		return found, installed, invalid, errors.Wrap(err, errGetOrCreateLock)
 	}
 
-	prRef, err := name.ParseReference(pr.GetSource(), name.WithDefaultRegistry(""))
+	lockRef, version, err := xpkg.ParseSource(pr.GetSource())
 	if err != nil {
 		return found, installed, invalid, err
 	}
 
-	lockRef := xpkg.ParsePackageSourceFromReference(prRef)
 	selfIndex := intPointer(-1)
 	d := m.newDag()
-	implied, err := d.Init(v1beta1.ToNodes(lock.Packages...), dag.FindIndex(lockRef, selfIndex))
+	implied, err := d.Init(v1beta1.ToNodes(lock.Packages...), dag.FindIndex(canonical.Source(lockRef), selfIndex))
 	if err != nil {
 		return found, installed, invalid, err
 	}
@@ -135,8 +123,9 @@ func (m *PackageDependencyManager) Resolve(ctx context.Context, pkg runtime.Obje
 		Name:         pr.GetName(),
 		Type:         m.packageType,
 		Source:       lockRef,
-		Version:      prRef.Identifier(),
+		Version:      version,
 		Dependencies: sources,
+		Origin:       origin(pr),
 	}
 
 	// If we don't exist in lock then we should add self.
@@ -150,21 +139,26 @@ func (m *PackageDependencyManager) Resolve(ctx context.Context, pkg runtime.Obje
 		d.AddOrUpdateNodes(&self)
 
 		// If any direct dependencies are missing we skip checking for
-		// transitive ones.
+		// transitive ones. Optional dependencies may be missing; they're
+		// not found, as far as we're concerned.
 		var missing []string
 		for _, dep := range self.Dependencies {
 			if d.NodeExists(dep.Identifier()) {
 				installed++
 				continue
 			}
-			missing = append(missing, dep.Identifier())
+			if dep.Optional {
+				found--
+				continue
+			}
+			missing = append(missing, dep.Package)
 		}
 		if installed != found {
 			return found, installed, invalid, errors.Errorf(errMissingDependenciesFmt, missing)
 		}
 	}
 
-	tree, err := d.TraceNode(lockRef)
+	tree, err := d.TraceNode(self.Identifier())
 	if err != nil {
 		return found, installed, invalid, err
 	}
@@ -173,20 +167,30 @@ func (m *PackageDependencyManager) Resolve(ctx context.Context, pkg runtime.Obje
 	// Check if any dependencies or transitive dependencies are missing (implied).
 	var missing []string
 	for _, imp := range implied {
-		if _, ok := tree[imp.Identifier()]; ok {
-			installed--
-			missing = append(missing, imp.Identifier())
+		if _, ok := tree[imp.Identifier()]; !ok {
+			continue
+		}
+		installed--
+		// Report missing dependencies as they were declared.
+		if dep, ok := imp.Node.(*v1beta1.Dependency); ok {
+			missing = append(missing, dep.Package)
+			continue
 		}
+		missing = append(missing, imp.Identifier())
 	}
 	if len(missing) != 0 {
 		return found, installed, invalid, errors.Errorf(errMissingDependenciesFmt, missing)
 	}
 
 	// All of our dependencies and transitive dependencies must exist. Check
-	// that neighbors have valid versions.
+	// that neighbors have valid versions. The resolver warns about optional
+	// dependencies that don't, but they never make us unhealthy.
 	var invalidDeps []string
 	for _, dep := range self.Dependencies {
-		n, err := d.GetNode(dep.Package)
+		if dep.Optional {
+			continue
+		}
+		n, err := d.GetNode(dep.Identifier())
 		if err != nil {
 			return found, installed, invalid, errors.New(errDependencyNotInGraph)
 		}
@@ -194,7 +198,7 @@ func (m *PackageDependencyManager) Resolve(ctx context.Context, pkg runtime.Obje
 		if !ok {
 			return found, installed, invalid, errors.New(errDependencyNotLockPackage)
 		}
-		c, err := semver.NewConstraint(dep.Constraints)
+		c, err := xpkg.NewConstraint(dep.Constraints)
 		if err != nil {
 			return found, installed, invalid, err
 		}
@@ -203,7 +207,7 @@ func (m *PackageDependencyManager) Resolve(ctx context.Context, pkg runtime.Obje
 			return found, installed, invalid, err
 		}
 		if !c.Check(v) {
-			invalidDeps = append(invalidDeps, lp.Identifier())
+			invalidDeps = append(invalidDeps, lp.Source)
 		}
 	}
 	invalid = len(invalidDeps)
@@ -213,9 +217,20 @@ func (m *PackageDependencyManager) Resolve(ctx context.Context, pkg runtime.Obje
 	return found, installed, invalid, nil
 }
 
+// origin returns the origin of the supplied revision's package. A package was
+// installed as a dependency if the resolver recorded which packages required
+// it, and by a user otherwise.
+func origin(pr v1.PackageRevision) *v1beta1.PackageOrigin {
+	rb := pr.GetAnnotations()[v1.AnnotationRequiredBy]
+	if rb == "" {
+		return &v1beta1.PackageOrigin{Type: v1beta1.OriginUser}
+	}
+	return &v1beta1.PackageOrigin{Type: v1beta1.OriginDependency, Parents: strings.Split(rb, ",")}
+}
+
 // RemoveSelf removes a package from the lock.
 func (m *PackageDependencyManager) RemoveSelf(ctx context.Context, pr v1.PackageRevision) error {
-	prRef, err := name.ParseReference(pr.GetSource(), name.WithDefaultRegistry(""))
+	lockRef, _, err := xpkg.ParseSource(pr.GetSource())
 	if err != nil {
 		return err
 	}
@@ -232,9 +247,8 @@ func (m *PackageDependencyManager) RemoveSelf(ctx context.Context, pr v1.Package
 	}
 
 	// Find self and remove. If we don't exist, its a no-op.
-	lockRef := xpkg.ParsePackageSourceFromReference(prRef)
 	for i, lp := range lock.Packages {
-		if lp.Source == lockRef {
+		if canonical.Equal(lp.Source, lockRef) {
 			lock.Packages = append(lock.Packages[:i], lock.Packages[i+1:]...)
 			return m.client.Update(ctx, lock)
 		}
@@ -22,6 +22,7 @@ import (
 
 	"github.com/google/go-cmp/cmp"
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/utils/pointer"
@@ -35,6 +36,7 @@ import (
 	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
 	"github.com/crossplane/crossplane/internal/dag"
 	dagfake "github.com/crossplane/crossplane/internal/dag/fake"
+	"github.com/crossplane/crossplane/internal/xpkg/canonical"
 )
 
 var _ DependencyManager = &PackageDependencyManager{}
@@ -131,7 +133,7 @@ func TestResolve(t *testing.T) {
 					},
 					newDag: func() dag.DAG {
 						return &dagfake.MockDag{
-							MockInit: func(_ []dag.Node, _ ...dag.NodeFn) ([]dag.Node, error) {
+							MockInit: func(_ []dag.Node, _ ...dag.NodeFn) ([]dag.ImpliedNode, error) {
 								return nil, errBoom
 							},
 						}
@@ -158,7 +160,7 @@ func TestResolve(t *testing.T) {
 					},
 					newDag: func() dag.DAG {
 						return &dagfake.MockDag{
-							MockInit: func(_ []dag.Node, _ ...dag.NodeFn) ([]dag.Node, error) {
+							MockInit: func(_ []dag.Node, _ ...dag.NodeFn) ([]dag.ImpliedNode, error) {
 								return nil, nil
 							},
 						}
@@ -194,7 +196,7 @@ func TestResolve(t *testing.T) {
 					},
 					newDag: func() dag.DAG {
 						return &dagfake.MockDag{
-							MockInit: func(nodes []dag.Node, fns ...dag.NodeFn) ([]dag.Node, error) {
+							MockInit: func(nodes []dag.Node, fns ...dag.NodeFn) ([]dag.ImpliedNode, error) {
 								for i, n := range nodes {
 									for _, f := range fns {
 										f(i, n)
@@ -235,7 +237,7 @@ func TestResolve(t *testing.T) {
 					},
 					newDag: func() dag.DAG {
 						return &dagfake.MockDag{
-							MockInit: func(nodes []dag.Node, fns ...dag.NodeFn) ([]dag.Node, error) {
+							MockInit: func(nodes []dag.Node, fns ...dag.NodeFn) ([]dag.ImpliedNode, error) {
 								for i, n := range nodes {
 									for _, f := range fns {
 										f(i, n)
@@ -275,7 +277,7 @@ func TestResolve(t *testing.T) {
 					},
 					newDag: func() dag.DAG {
 						return &dagfake.MockDag{
-							MockInit: func(nodes []dag.Node, fns ...dag.NodeFn) ([]dag.Node, error) {
+							MockInit: func(nodes []dag.Node, fns ...dag.NodeFn) ([]dag.ImpliedNode, error) {
 								for i, n := range nodes {
 									for _, f := range fns {
 										f(i, n)
@@ -299,6 +301,101 @@ func TestResolve(t *testing.T) {
 			},
 			want: want{},
 		},
+		"SuccessfulSelfNotExistUserOrigin": {
+			reason: "Should record that self was installed by a user when adding self to the lock.",
+			args: args{
+				dep: &PackageDependencyManager{
+					client: &test.MockClient{
+						MockGet: test.NewMockGetFn(nil),
+						MockUpdate: test.NewMockUpdateFn(nil, func(obj client.Object) error {
+							want := []v1beta1.LockPackage{{
+								Name:         "config-nop-a-abc123",
+								Type:         v1beta1.ConfigurationPackageType,
+								Source:       "hasheddan/config-nop-a",
+								Version:      "v0.0.1",
+								Dependencies: []v1beta1.Dependency{},
+								Origin:       &v1beta1.PackageOrigin{Type: v1beta1.OriginUser},
+							}}
+							if diff := cmp.Diff(want, obj.(*v1beta1.Lock).Packages); diff != "" {
+								t.Errorf("-want, +got:\n%s", diff)
+							}
+							return nil
+						}),
+					},
+					newDag: func() dag.DAG {
+						return &dagfake.MockDag{
+							MockInit: func(nodes []dag.Node, fns ...dag.NodeFn) ([]dag.ImpliedNode, error) {
+								return nil, nil
+							},
+							MockAddOrUpdateNodes: func(_ ...dag.Node) {},
+							MockTraceNode: func(_ string) (map[string]dag.Node, error) {
+								return nil, nil
+							},
+						}
+					},
+					packageType: v1beta1.ConfigurationPackageType,
+				},
+				meta: &pkgmetav1.Configuration{},
+				pr: &v1.ConfigurationRevision{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "config-nop-a-abc123",
+					},
+					Spec: v1.PackageRevisionSpec{
+						Package:      "hasheddan/config-nop-a:v0.0.1",
+						DesiredState: v1.PackageRevisionActive,
+					},
+				},
+			},
+			want: want{},
+		},
+		"SuccessfulSelfNotExistDependencyOrigin": {
+			reason: "Should record that self was installed as a dependency when adding self to the lock.",
+			args: args{
+				dep: &PackageDependencyManager{
+					client: &test.MockClient{
+						MockGet: test.NewMockGetFn(nil),
+						MockUpdate: test.NewMockUpdateFn(nil, func(obj client.Object) error {
+							want := []v1beta1.LockPackage{{
+								Name:         "config-nop-a-abc123",
+								Type:         v1beta1.ConfigurationPackageType,
+								Source:       "hasheddan/config-nop-a",
+								Version:      "v0.0.1",
+								Dependencies: []v1beta1.Dependency{},
+								Origin:       &v1beta1.PackageOrigin{Type: v1beta1.OriginDependency, Parents: []string{"cool/config-a", "cool/config-b"}},
+							}}
+							if diff := cmp.Diff(want, obj.(*v1beta1.Lock).Packages); diff != "" {
+								t.Errorf("-want, +got:\n%s", diff)
+							}
+							return nil
+						}),
+					},
+					newDag: func() dag.DAG {
+						return &dagfake.MockDag{
+							MockInit: func(nodes []dag.Node, fns ...dag.NodeFn) ([]dag.ImpliedNode, error) {
+								return nil, nil
+							},
+							MockAddOrUpdateNodes: func(_ ...dag.Node) {},
+							MockTraceNode: func(_ string) (map[string]dag.Node, error) {
+								return nil, nil
+							},
+						}
+					},
+					packageType: v1beta1.ConfigurationPackageType,
+				},
+				meta: &pkgmetav1.Configuration{},
+				pr: &v1.ConfigurationRevision{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:        "config-nop-a-abc123",
+						Annotations: map[string]string{v1.AnnotationRequiredBy: "cool/config-a,cool/config-b"},
+					},
+					Spec: v1.PackageRevisionSpec{
+						Package:      "hasheddan/config-nop-a:v0.0.1",
+						DesiredState: v1.PackageRevisionActive,
+					},
+				},
+			},
+			want: want{},
+		},
 		"ErrorSelfNotExistMissingDirectDependencies": {
 			reason: "Should return error if self does not exist and missing direct dependencies.",
 			args: args{
@@ -327,7 +424,7 @@ func TestResolve(t *testing.T) {
 					},
 					newDag: func() dag.DAG {
 						return &dagfake.MockDag{
-							MockInit: func(nodes []dag.Node, fns ...dag.NodeFn) ([]dag.Node, error) {
+							MockInit: func(nodes []dag.Node, fns ...dag.NodeFn) ([]dag.ImpliedNode, error) {
 								return nil, nil
 							},
 							MockAddNode: func(_ dag.Node) error {
@@ -403,26 +500,26 @@ func TestResolve(t *testing.T) {
 					},
 					newDag: func() dag.DAG {
 						return &dagfake.MockDag{
-							MockInit: func(nodes []dag.Node, fns ...dag.NodeFn) ([]dag.Node, error) {
+							MockInit: func(nodes []dag.Node, fns ...dag.NodeFn) ([]dag.ImpliedNode, error) {
 								for i, n := range nodes {
 									for _, f := range fns {
 										f(i, n)
 									}
 								}
-								return []dag.Node{
-									&v1beta1.Dependency{
+								return []dag.ImpliedNode{
+									{Node: &v1beta1.Dependency{
 										Package: "not-here-2",
-									},
-									&v1beta1.Dependency{
+									}},
+									{Node: &v1beta1.Dependency{
 										Package: "not-here-3",
-									},
+									}},
 								}, nil
 							},
 							MockTraceNode: func(_ string) (map[string]dag.Node, error) {
 								return map[string]dag.Node{
-									"not-here-1": &v1beta1.Dependency{},
-									"not-here-2": &v1beta1.Dependency{},
-									"not-here-3": &v1beta1.Dependency{},
+									canonical.Source("not-here-1"): &v1beta1.Dependency{},
+									canonical.Source("not-here-2"): &v1beta1.Dependency{},
+									canonical.Source("not-here-3"): &v1beta1.Dependency{},
 								}, nil
 							},
 						}
@@ -492,7 +589,7 @@ func TestResolve(t *testing.T) {
 					},
 					newDag: func() dag.DAG {
 						return &dagfake.MockDag{
-							MockInit: func(nodes []dag.Node, fns ...dag.NodeFn) ([]dag.Node, error) {
+							MockInit: func(nodes []dag.Node, fns ...dag.NodeFn) ([]dag.ImpliedNode, error) {
 								for i, n := range nodes {
 									for _, f := range fns {
 										f(i, n)
@@ -502,19 +599,19 @@ func TestResolve(t *testing.T) {
 							},
 							MockTraceNode: func(_ string) (map[string]dag.Node, error) {
 								return map[string]dag.Node{
-									"not-here-1": &v1beta1.Dependency{},
-									"not-here-2": &v1beta1.Dependency{},
-									"not-here-3": &v1beta1.Dependency{},
+									canonical.Source("not-here-1"): &v1beta1.Dependency{},
+									canonical.Source("not-here-2"): &v1beta1.Dependency{},
+									canonical.Source("not-here-3"): &v1beta1.Dependency{},
 								}, nil
 							},
 							MockGetNode: func(s string) (dag.Node, error) {
-								if s == "not-here-1" {
+								if s == canonical.Source("not-here-1") {
 									return &v1beta1.LockPackage{
 										Source:  "not-here-1",
 										Version: "v0.0.1",
 									}, nil
 								}
-								if s == "not-here-2" {
+								if s == canonical.Source("not-here-2") {
 									return &v1beta1.LockPackage{
 										Source:  "not-here-2",
 										Version: "v0.0.1",
@@ -592,7 +689,7 @@ func TestResolve(t *testing.T) {
 					},
 					newDag: func() dag.DAG {
 						return &dagfake.MockDag{
-							MockInit: func(nodes []dag.Node, fns ...dag.NodeFn) ([]dag.Node, error) {
+							MockInit: func(nodes []dag.Node, fns ...dag.NodeFn) ([]dag.ImpliedNode, error) {
 								for i, n := range nodes {
 									for _, f := range fns {
 										f(i, n)
@@ -602,19 +699,19 @@ func TestResolve(t *testing.T) {
 							},
 							MockTraceNode: func(_ string) (map[string]dag.Node, error) {
 								return map[string]dag.Node{
-									"not-here-1": &v1beta1.Dependency{},
-									"not-here-2": &v1beta1.Dependency{},
-									"not-here-3": &v1beta1.Dependency{},
+									canonical.Source("not-here-1"): &v1beta1.Dependency{},
+									canonical.Source("not-here-2"): &v1beta1.Dependency{},
+									canonical.Source("not-here-3"): &v1beta1.Dependency{},
 								}, nil
 							},
 							MockGetNode: func(s string) (dag.Node, error) {
-								if s == "not-here-1" {
+								if s == canonical.Source("not-here-1") {
 									return &v1beta1.LockPackage{
 										Source:  "not-here-1",
 										Version: "v0.20.0",
 									}, nil
 								}
-								if s == "not-here-2" {
+								if s == canonical.Source("not-here-2") {
 									return &v1beta1.LockPackage{
 										Source:  "not-here-2",
 										Version: "v0.100.1",
@@ -654,6 +751,106 @@ func TestResolve(t *testing.T) {
 				invalid:   0,
 			},
 		},
+		"SuccessfulSelfExistOptionalInvalidDependencies": {
+			reason: "Should not return error if self exists and an optional dependency is invalid.",
+			args: args{
+				dep: &PackageDependencyManager{
+					client: &test.MockClient{
+						MockGet: test.NewMockGetFn(nil, func(obj client.Object) error {
+							l := obj.(*v1beta1.Lock)
+							l.Packages = []v1beta1.LockPackage{
+								{
+									Source: "hasheddan/config-nop-a",
+									Dependencies: []v1beta1.Dependency{
+										{
+											Package: "not-here-1",
+											Type:    v1beta1.ProviderPackageType,
+										},
+										{
+											Package: "not-here-2",
+											Type:    v1beta1.ConfigurationPackageType,
+										},
+									},
+								},
+								{
+									Source: "not-here-1",
+									Dependencies: []v1beta1.Dependency{
+										{
+											Package: "not-here-3",
+											Type:    v1beta1.ProviderPackageType,
+										},
+									},
+								},
+							}
+							return nil
+						}),
+						MockUpdate: test.NewMockUpdateFn(nil),
+					},
+					newDag: func() dag.DAG {
+						return &dagfake.MockDag{
+							MockInit: func(nodes []dag.Node, fns ...dag.NodeFn) ([]dag.ImpliedNode, error) {
+								for i, n := range nodes {
+									for _, f := range fns {
+										f(i, n)
+									}
+								}
+								return nil, nil
+							},
+							MockTraceNode: func(_ string) (map[string]dag.Node, error) {
+								return map[string]dag.Node{
+									canonical.Source("not-here-1"): &v1beta1.Dependency{},
+									canonical.Source("not-here-2"): &v1beta1.Dependency{},
+									canonical.Source("not-here-3"): &v1beta1.Dependency{},
+								}, nil
+							},
+							MockGetNode: func(s string) (dag.Node, error) {
+								if s == canonical.Source("not-here-1") {
+									return &v1beta1.LockPackage{
+										Source:  "not-here-1",
+										Version: "v0.20.0",
+									}, nil
+								}
+								if s == canonical.Source("not-here-2") {
+									return &v1beta1.LockPackage{
+										Source:  "not-here-2",
+										Version: "v0.100.1",
+									}, nil
+								}
+								return nil, nil
+							},
+						}
+					},
+				},
+				meta: &pkgmetav1.Configuration{
+					Spec: pkgmetav1.ConfigurationSpec{
+						MetaSpec: pkgmetav1.MetaSpec{
+							DependsOn: []pkgmetav1.Dependency{
+								{
+									Provider: pointer.StringPtr("not-here-1"),
+									Version:  ">=v0.1.0",
+								},
+								{
+									Provider: pointer.StringPtr("not-here-2"),
+									Version:  ">=v1.0.0",
+									Optional: true,
+								},
+							},
+						},
+					},
+				},
+				pr: &v1.ConfigurationRevision{
+					Spec: v1.PackageRevisionSpec{
+						Package:      "hasheddan/config-nop-a:v0.0.1",
+						DesiredState: v1.PackageRevisionActive,
+					},
+				},
+			},
+			want: want{
+				total:     3,
+				installed: 3,
+				invalid:   0,
+			},
+		},
 	}
 
 	for name, tc := range cases {
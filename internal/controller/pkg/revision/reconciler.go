@@ -74,6 +74,8 @@ const (
 	errEstablishControl = "cannot establish control of object"
 
 	errUpdateAnnotations = "cannot update annotations for package revision"
+
+	errUnresolvedDependencies = "package revision dependencies are not resolved"
 )
 
 // Event reasons.
@@ -195,12 +197,13 @@ type Reconciler struct {
 	backend   parser.Backend
 	log       logging.Logger
 	record    event.Recorder
+	strict    bool
 
 	newPackageRevision func() v1.PackageRevision
 }
 
 // SetupProviderRevision adds a controller that reconciles ProviderRevisions.
-func SetupProviderRevision(mgr ctrl.Manager, l logging.Logger, cache xpkg.Cache, namespace, registry string) error {
+func SetupProviderRevision(mgr ctrl.Manager, l logging.Logger, cache xpkg.Cache, namespace, registry string, strict bool) error {
 	name := "packages/" + strings.ToLower(v1.ProviderRevisionGroupKind)
 	nr := func() v1.PackageRevision { return &v1.ProviderRevision{} }
 
@@ -218,7 +221,7 @@ func SetupProviderRevision(mgr ctrl.Manager, l logging.Logger, cache xpkg.Cache,
 		return errors.New("cannot build object scheme for package parser")
 	}
 
-	r := NewReconciler(mgr,
+	opts := []ReconcilerOption{
 		WithCache(cache),
 		WithDependencyManager(NewPackageDependencyManager(mgr.GetClient(), dag.NewMapDag, v1beta1.ProviderPackageType)),
 		WithHooks(NewProviderHooks(resource.ClientApplicator{
@@ -231,7 +234,11 @@ func SetupProviderRevision(mgr ctrl.Manager, l logging.Logger, cache xpkg.Cache,
 		WithLinter(xpkg.NewProviderLinter()),
 		WithLogger(l.WithValues("controller", name)),
 		WithRecorder(event.NewAPIRecorder(mgr.GetEventRecorderFor(name))),
-	)
+	}
+	if strict {
+		opts = append(opts, WithStrictDependencies())
+	}
+	r := NewReconciler(mgr, opts...)
 
 	return ctrl.NewControllerManagedBy(mgr).
 		Named(name).
@@ -244,7 +251,7 @@ func SetupProviderRevision(mgr ctrl.Manager, l logging.Logger, cache xpkg.Cache,
 }
 
 // SetupConfigurationRevision adds a controller that reconciles ConfigurationRevisions.
-func SetupConfigurationRevision(mgr ctrl.Manager, l logging.Logger, cache xpkg.Cache, namespace, registry string) error {
+func SetupConfigurationRevision(mgr ctrl.Manager, l logging.Logger, cache xpkg.Cache, namespace, registry string, strict bool) error {
 	name := "packages/" + strings.ToLower(v1.ConfigurationRevisionGroupKind)
 	nr := func() v1.PackageRevision { return &v1.ConfigurationRevision{} }
 
@@ -262,7 +269,7 @@ func SetupConfigurationRevision(mgr ctrl.Manager, l logging.Logger, cache xpkg.C
 		return errors.New("cannot build object scheme for package parser")
 	}
 
-	r := NewReconciler(mgr,
+	opts := []ReconcilerOption{
 		WithCache(cache),
 		WithDependencyManager(NewPackageDependencyManager(mgr.GetClient(), dag.NewMapDag, v1beta1.ConfigurationPackageType)),
 		WithHooks(NewConfigurationHooks()),
@@ -272,7 +279,11 @@ func SetupConfigurationRevision(mgr ctrl.Manager, l logging.Logger, cache xpkg.C
 		WithLinter(xpkg.NewConfigurationLinter()),
 		WithLogger(l.WithValues("controller", name)),
 		WithRecorder(event.NewAPIRecorder(mgr.GetEventRecorderFor(name))),
-	)
+	}
+	if strict {
+		opts = append(opts, WithStrictDependencies())
+	}
+	r := NewReconciler(mgr, opts...)
 
 	return ctrl.NewControllerManagedBy(mgr).
 		Named(name).
@@ -458,6 +469,17 @@ func (r *Reconciler) Reconcile(ctx context.Context, req reconcile.Request) (reco
 		return reconcile.Result{RequeueAfter: shortWait}, errors.Wrap(r.client.Status().Update(ctx, pr), errUpdateStatus)
 	}
 
+	// In strict mode a revision isn't healthy until the resolver reports that
+	// its dependencies are resolved.
+	if r.strict && pr.GetSkipDependencyResolution() != nil && !*pr.GetSkipDependencyResolution() {
+		if err := r.unresolved(ctx, pkgMeta.(pkgmetav1.Pkg), pr); err != nil {
+			log.Debug(errUnresolvedDependencies, "error", err)
+			r.record.Event(pr, event.Warning(reasonDependencies, errors.Wrap(err, errUnresolvedDependencies)))
+			pr.SetConditions(v1.UnresolvedDependencies().WithMessage(err.Error()))
+			return reconcile.Result{RequeueAfter: shortWait}, errors.Wrap(r.client.Status().Update(ctx, pr), errUpdateStatus)
+		}
+	}
+
 	r.record.Event(pr, event.Normal(reasonSync, "Successfully configured package revision"))
 	pr.SetConditions(v1.Healthy())
 	return reconcile.Result{Requeue: false}, errors.Wrap(r.client.Status().Update(ctx, pr), errUpdateStatus)
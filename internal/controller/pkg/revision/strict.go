@@ -0,0 +1,62 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package revision
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+
+	pkgmetav1 "github.com/crossplane/crossplane/apis/pkg/meta/v1"
+	v1 "github.com/crossplane/crossplane/apis/pkg/v1"
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+	"github.com/crossplane/crossplane/internal/xpkg"
+)
+
+const errGetLock = "cannot get lock"
+
+// WithStrictDependencies specifies that the Reconciler should only report a
+// package revision that declares dependencies healthy once the resolver
+// reports that its dependencies are resolved. By default a revision is healthy
+// once its dependencies are present in the Lock, whether or not they satisfy
+// its constraints.
+func WithStrictDependencies() ReconcilerOption {
+	return func(r *Reconciler) {
+		r.strict = true
+	}
+}
+
+// unresolved returns an error describing why the dependencies of the supplied
+// package revision are not yet resolved, or nil if they are or the revision's
+// package declares no dependencies. The resolver reports whether each package
+// in the Lock has its dependencies resolved in the Lock's status.
+func (r *Reconciler) unresolved(ctx context.Context, pkg pkgmetav1.Pkg, pr v1.PackageRevision) error {
+	if len(pkg.GetDependencies()) == 0 {
+		return nil
+	}
+	source, version, err := xpkg.ParseSource(pr.GetSource())
+	if err != nil {
+		return err
+	}
+	lock := &v1beta1.Lock{}
+	if err := r.client.Get(ctx, types.NamespacedName{Name: lockName}, lock); err != nil {
+		return errors.Wrap(err, errGetLock)
+	}
+	return xpkg.UnresolvedDependencies(lock, source, version)
+}
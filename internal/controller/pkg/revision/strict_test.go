@@ -0,0 +1,120 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package revision
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/resource"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/fake"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	pkgmetav1 "github.com/crossplane/crossplane/apis/pkg/meta/v1"
+	v1 "github.com/crossplane/crossplane/apis/pkg/v1"
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+)
+
+func TestUnresolved(t *testing.T) {
+	errBoom := errors.New("boom")
+	withDeps := &pkgmetav1.Configuration{Spec: pkgmetav1.ConfigurationSpec{MetaSpec: pkgmetav1.MetaSpec{
+		DependsOn: []pkgmetav1.Dependency{{Provider: pointer.StringPtr("crossplane/provider-aws"), Version: ">=v0.1.0"}},
+	}}}
+	pr := &v1.ConfigurationRevision{Spec: v1.PackageRevisionSpec{Package: "crossplane/config-test:v0.1.0"}}
+
+	type args struct {
+		pkg       pkgmetav1.Pkg
+		dependent []v1beta1.DependentResolution
+		get       error
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   error
+	}{
+		"NoDependencies": {
+			reason: "A package that declares no dependencies should never be unresolved.",
+			args: args{
+				pkg: &pkgmetav1.Configuration{},
+				get: errBoom,
+			},
+		},
+		"ErrGetLock": {
+			reason: "We should return an error if we can't get the Lock.",
+			args: args{
+				pkg: withDeps,
+				get: errBoom,
+			},
+			want: errors.Wrap(errBoom, errGetLock),
+		},
+		"NotReported": {
+			reason: "A package the resolver hasn't reported on should be unresolved.",
+			args: args{
+				pkg: withDeps,
+				dependent: []v1beta1.DependentResolution{
+					{Package: "crossplane/config-test", Version: "v0.0.1"},
+				},
+			},
+			want: errors.New("dependencies of crossplane/config-test at version v0.1.0 have not yet been resolved"),
+		},
+		"Unresolved": {
+			reason: "A package the resolver reports unresolved dependencies for should be unresolved.",
+			args: args{
+				pkg: withDeps,
+				dependent: []v1beta1.DependentResolution{
+					{Package: "crossplane/config-test", Version: "v0.1.0", Unresolved: []string{"crossplane/provider-aws"}},
+				},
+			},
+			want: errors.New("unresolved dependencies: crossplane/provider-aws"),
+		},
+		"Resolved": {
+			reason: "A package the resolver reports no unresolved dependencies for should be resolved.",
+			args: args{
+				pkg: withDeps,
+				dependent: []v1beta1.DependentResolution{
+					{Package: "crossplane/config-test", Version: "v0.1.0"},
+				},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			r := NewReconciler(&fake.Manager{},
+				WithStrictDependencies(),
+				WithClientApplicator(resource.ClientApplicator{
+					Client: &test.MockClient{
+						MockGet: test.NewMockGetFn(tc.args.get, func(o client.Object) error {
+							o.(*v1beta1.Lock).Status.Dependents = tc.args.dependent
+							return nil
+						}),
+					},
+				}),
+			)
+			err := r.unresolved(context.Background(), tc.args.pkg, pr)
+			if diff := cmp.Diff(tc.want, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nr.unresolved(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -17,9 +17,15 @@ limitations under the License.
 package dag
 
 import (
+	"fmt"
+	"sort"
+	"strings"
+
 	"github.com/crossplane/crossplane-runtime/pkg/errors"
 )
 
+const cyclePathSep = " -> "
+
 // Node is a node in DAG.
 type Node interface {
 	Identifier() string
@@ -31,25 +37,76 @@ type Node interface {
 	AddNeighbors(...Node) error
 }
 
-// DAG is a Directed Acyclic Graph.
+// An ImpliedNode is a node that a node in a DAG has as a neighbor, but that was
+// not itself added to the DAG. A DAG doesn't know what type of Node it is, so
+// it must be checked before it is converted to a more specific type.
+type ImpliedNode struct {
+	Node
+
+	// ImpliedBy is the identifier of the node that implied this one. If
+	// multiple nodes have it as a neighbor, it is the first one found.
+	ImpliedBy string
+}
+
+// String describes the implied node, including its type, so that a node of an
+// unexpected type can be reported.
+func (n ImpliedNode) String() string {
+	if n.Node == nil {
+		return fmt.Sprintf("<nil> implied by %s", n.ImpliedBy)
+	}
+	return fmt.Sprintf("%T %s implied by %s", n.Node, n.Identifier(), n.ImpliedBy)
+}
+
+// DAG is a Directed Acyclic Graph. The nodes that Init and AddEdges imply are
+// returned sorted by identifier, regardless of the order in which the nodes
+// and their neighbors were supplied.
 type DAG interface {
-	Init(nodes []Node, fns ...NodeFn) ([]Node, error)
+	Init(nodes []Node, fns ...NodeFn) ([]ImpliedNode, error)
 	AddNode(Node) error
 	AddNodes(...Node) error
 	AddOrUpdateNodes(...Node)
 	GetNode(identifier string) (Node, error)
 	AddEdge(from string, to Node) (bool, error)
-	AddEdges(edges map[string][]Node) ([]Node, error)
+	AddEdges(edges map[string][]Node) ([]ImpliedNode, error)
 	NodeExists(identifier string) bool
 	NodeNeighbors(identifier string) ([]Node, error)
+	NodeDependents(identifier string) ([]Node, error)
 	TraceNode(identifier string) (map[string]Node, error)
+	TraverseFrom(identifiers ...string) (map[string]bool, error)
 	Sort() ([]string, error)
 }
 
+// A CyclicError is returned when a graph contains a cycle.
+type CyclicError struct {
+	// Cycle is the identifiers of the nodes in the cycle, in order. The first
+	// node is repeated at the end, e.g. [a b c a].
+	Cycle []string
+}
+
+// Path returns the cycle formatted as a path, e.g. a -> b -> c -> a.
+func (e *CyclicError) Path() string {
+	return strings.Join(e.Cycle, cyclePathSep)
+}
+
+func (e *CyclicError) Error() string {
+	return "detected cycle: " + e.Path()
+}
+
 // MapDag is a directed acyclic graph implementation that uses a map for its
 // underlying data structure.
 type MapDag struct {
 	nodes map[string]Node
+
+	// index of the graph, built when it's first sorted and discarded
+	// whenever the graph changes.
+	index *sortIndex
+}
+
+// A sortIndex is the adjacency of a graph's nodes, identified by their index
+// into the sorted identifiers of the graph's nodes.
+type sortIndex struct {
+	names []string
+	adj   [][]int
 }
 
 // NodeFn performs executes a function on each node.
@@ -75,9 +132,10 @@ func NewMapDag() DAG {
 }
 
 // Init initializes a MapDag and implies missing destination nodes. Any implied
-// nodes are returned. Any existing nodes are cleared.
-func (d *MapDag) Init(nodes []Node, fns ...NodeFn) ([]Node, error) {
-	d.nodes = map[string]Node{}
+// nodes are returned, sorted by identifier. Any existing nodes are cleared.
+func (d *MapDag) Init(nodes []Node, fns ...NodeFn) ([]ImpliedNode, error) {
+	d.nodes = make(map[string]Node, len(nodes))
+	d.index = nil
 	// Add all nodes before adding edges so we know what nodes were implied.
 	for i, node := range nodes {
 		if err := d.AddNode(node); err != nil {
@@ -87,16 +145,23 @@ func (d *MapDag) Init(nodes []Node, fns ...NodeFn) ([]Node, error) {
 			f(i, node)
 		}
 	}
-	var implied []Node // nolint:prealloc
+	var implied []ImpliedNode // nolint:prealloc
 	for _, node := range nodes {
-		miss, err := d.AddEdges(map[string][]Node{
-			node.Identifier(): node.Neighbors(),
-		})
-		if err != nil {
-			return nil, err
+		from := node.Identifier()
+		for _, e := range node.Neighbors() {
+			if d.missingOptional(e) {
+				continue
+			}
+			miss, err := d.AddEdge(from, e)
+			if miss {
+				implied = append(implied, ImpliedNode{Node: e, ImpliedBy: from})
+			}
+			if err != nil {
+				return nil, err
+			}
 		}
-		implied = append(implied, miss...)
 	}
+	sortNodes(implied)
 	return implied, nil
 }
 
@@ -116,6 +181,7 @@ func (d *MapDag) AddNode(node Node) error {
 		return errors.New("node already exists")
 	}
 	d.nodes[node.Identifier()] = node
+	d.index = nil
 	return nil
 }
 
@@ -125,6 +191,7 @@ func (d *MapDag) AddOrUpdateNodes(nodes ...Node) {
 	for _, node := range nodes {
 		d.nodes[node.Identifier()] = node
 	}
+	d.index = nil
 }
 
 // NodeExists checks whether a node exists.
@@ -141,6 +208,25 @@ func (d *MapDag) NodeNeighbors(identifier string) ([]Node, error) {
 	return d.nodes[identifier].Neighbors(), nil
 }
 
+// NodeDependents returns the nodes that have the supplied node as a neighbor,
+// sorted by identifier.
+func (d *MapDag) NodeDependents(identifier string) ([]Node, error) {
+	if _, ok := d.nodes[identifier]; !ok {
+		return nil, errors.New("node does not exist")
+	}
+	dependents := []Node{}
+	for _, n := range d.nodes {
+		for _, nn := range n.Neighbors() {
+			if nn.Identifier() == identifier {
+				dependents = append(dependents, n)
+				break
+			}
+		}
+	}
+	sort.Slice(dependents, func(i, j int) bool { return dependents[i].Identifier() < dependents[j].Identifier() })
+	return dependents, nil
+}
+
 // TraceNode returns a node's neighbors and all transitive neighbors using depth
 // first search.
 func (d *MapDag) TraceNode(identifier string) (map[string]Node, error) {
@@ -161,6 +247,10 @@ func (d *MapDag) traceNode(identifier string, tree map[string]Node) error {
 		if _, ok := tree[n.Identifier()]; ok {
 			continue
 		}
+		// A missing optional neighbor isn't part of the tree.
+		if d.missingOptional(n) {
+			continue
+		}
 		tree[n.Identifier()] = n
 		if err := d.traceNode(n.Identifier(), tree); err != nil {
 			return err
@@ -169,6 +259,38 @@ func (d *MapDag) traceNode(identifier string, tree map[string]Node) error {
 	return nil
 }
 
+// TraverseFrom returns the identifiers of the supplied nodes, and of every
+// node reachable from them, using breadth first search.
+func (d *MapDag) TraverseFrom(identifiers ...string) (map[string]bool, error) {
+	reached := map[string]bool{}
+	queue := make([]string, 0, len(identifiers))
+	for _, id := range identifiers {
+		if _, ok := d.nodes[id]; !ok {
+			return nil, errors.Errorf("node %s does not exist", id)
+		}
+		if !reached[id] {
+			reached[id] = true
+			queue = append(queue, id)
+		}
+	}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		for _, n := range d.nodes[id].Neighbors() {
+			// A neighbor that was never added to the graph has no
+			// neighbors of its own to traverse.
+			if reached[n.Identifier()] {
+				continue
+			}
+			reached[n.Identifier()] = true
+			if _, ok := d.nodes[n.Identifier()]; ok {
+				queue = append(queue, n.Identifier())
+			}
+		}
+	}
+	return reached, nil
+}
+
 // GetNode returns a node in the dag.
 func (d *MapDag) GetNode(identifier string) (Node, error) {
 	if _, ok := d.nodes[identifier]; !ok {
@@ -178,22 +300,31 @@ func (d *MapDag) GetNode(identifier string) (Node, error) {
 }
 
 // AddEdges adds edges to the graph.
-func (d *MapDag) AddEdges(edges map[string][]Node) ([]Node, error) {
-	var missing []Node
+func (d *MapDag) AddEdges(edges map[string][]Node) ([]ImpliedNode, error) {
+	var missing []ImpliedNode
 	for f, ne := range edges {
 		for _, e := range ne {
+			if d.missingOptional(e) {
+				continue
+			}
 			implied, err := d.AddEdge(f, e)
 			if implied {
-				missing = append(missing, e)
+				missing = append(missing, ImpliedNode{Node: e, ImpliedBy: f})
 			}
 			if err != nil {
 				return nil, err
 			}
 		}
 	}
+	sortNodes(missing)
 	return missing, nil
 }
 
+// sortNodes sorts the supplied implied nodes by identifier.
+func sortNodes(nodes []ImpliedNode) {
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Identifier() < nodes[j].Identifier() })
+}
+
 // AddEdge adds an edge to the graph.
 func (d *MapDag) AddEdge(from string, to Node) (bool, error) {
 	if _, ok := d.nodes[from]; !ok {
@@ -206,42 +337,224 @@ func (d *MapDag) AddEdge(from string, to Node) (bool, error) {
 			return implied, err
 		}
 	}
+	d.index = nil
 	return implied, d.nodes[from].AddNeighbors(to)
 }
 
-// Sort performs topological sort on the graph.
+// Sort performs topological sort on the graph. Nodes are visited in order of
+// their identifiers, so the cycle a *CyclicError reports is deterministic.
 func (d *MapDag) Sort() ([]string, error) {
-	visited := map[string]bool{}
-	results := make([]string, len(d.nodes))
-	for n, node := range d.nodes {
-		if !visited[n] {
-			stack := map[string]bool{}
-			if err := d.visit(n, node.Neighbors(), stack, visited, results); err != nil {
+	if d.index == nil {
+		d.index = d.buildIndex()
+	}
+	names, adj := d.index.names, d.index.adj
+
+	s := &sorter{
+		names:   names,
+		adj:     adj,
+		state:   make([]visitState, len(names)),
+		results: make([]string, 0, len(names)),
+	}
+	for i := range names {
+		if s.state[i] == unvisited {
+			if err := s.visit(i); err != nil {
 				return nil, err
 			}
 		}
 	}
-	return results, nil
+	return s.results, nil
 }
 
-func (d *MapDag) visit(name string, neighbors []Node, stack map[string]bool, visited map[string]bool, results []string) error {
-	visited[name] = true
-	stack[name] = true
-	for _, n := range neighbors {
-		if !visited[n.Identifier()] {
-			if err := d.visit(n.Identifier(), d.nodes[n.Identifier()].Neighbors(), stack, visited, results); err != nil {
+// Depths returns the depth of each node in the supplied DAG, which is the
+// length of the longest path to it from a node that no other node has as a
+// neighbor. Every node is deeper than each node that has it as a neighbor, so
+// nodes of the same depth never depend on each other. An error is returned if
+// the DAG cannot be sorted.
+func Depths(d DAG) (map[string]int, error) {
+	sorted, err := d.Sort()
+	if err != nil {
+		return nil, err
+	}
+
+	// Nodes are sorted after their neighbors, so walking them in reverse
+	// visits each node after every node that has it as a neighbor.
+	depths := make(map[string]int, len(sorted))
+	for i := len(sorted) - 1; i >= 0; i-- {
+		id := sorted[i]
+		if _, ok := depths[id]; !ok {
+			depths[id] = 0
+		}
+		neighbors, err := d.NodeNeighbors(id)
+		if err != nil {
+			return nil, err
+		}
+		for _, n := range neighbors {
+			if depths[id]+1 > depths[n.Identifier()] {
+				depths[n.Identifier()] = depths[id] + 1
+			}
+		}
+	}
+	return depths, nil
+}
+
+// A ConstrainedNode is a neighbor that constrains the node it identifies, for
+// example to a range of versions.
+type ConstrainedNode interface {
+	Node
+
+	// GetConstraints returns the constraints the neighbor places on the
+	// node it identifies.
+	GetConstraints() string
+}
+
+// An OptionalNode is a neighbor that the node that has it can do without.
+// A missing optional neighbor is never implied, and is not part of the tree of
+// the node that has it.
+type OptionalNode interface {
+	Node
+
+	// IsOptional returns true if the neighbor is optional.
+	IsOptional() bool
+}
+
+// optional returns true if the supplied neighbor is optional.
+func optional(n Node) bool {
+	o, ok := n.(OptionalNode)
+	return ok && o.IsOptional()
+}
+
+// missingOptional returns true if the supplied neighbor is optional, and no
+// node with its identifier has been added to the graph.
+func (d *MapDag) missingOptional(n Node) bool {
+	return optional(n) && !d.NodeExists(n.Identifier())
+}
+
+// An Edge is a node's dependency on one of its neighbors.
+type Edge struct {
+	// From is the node that has the neighbor.
+	From Node
+
+	// To is the neighbor, as the node that has it declared it. It may be a
+	// different value from the node the DAG holds for the same identifier.
+	To Node
+
+	// Constraints the neighbor places on the node it identifies, if it is
+	// a ConstrainedNode.
+	Constraints string
+
+	// Optional is true if the neighbor is an OptionalNode that is optional.
+	// The node it identifies may not be in the DAG.
+	Optional bool
+}
+
+// Edges returns every edge of the supplied DAG, sorted by the identifier of
+// the node it's from and then of its neighbor. An error is returned if the
+// DAG cannot be sorted.
+func Edges(d DAG) ([]Edge, error) {
+	sorted, err := d.Sort()
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(sorted)
+
+	var edges []Edge // nolint:prealloc
+	for _, id := range sorted {
+		from, err := d.GetNode(id)
+		if err != nil {
+			return nil, err
+		}
+		neighbors := from.Neighbors()
+		sort.SliceStable(neighbors, func(i, j int) bool { return neighbors[i].Identifier() < neighbors[j].Identifier() })
+		for _, n := range neighbors {
+			e := Edge{From: from, To: n, Optional: optional(n)}
+			if c, ok := n.(ConstrainedNode); ok {
+				e.Constraints = c.GetConstraints()
+			}
+			edges = append(edges, e)
+		}
+	}
+	return edges, nil
+}
+
+// buildIndex returns the sort index of the graph. Each node's neighbors are
+// only built once, and the index can be reused until the graph changes.
+func (d *MapDag) buildIndex() *sortIndex {
+	names := make([]string, 0, len(d.nodes))
+	for n := range d.nodes {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	index := make(map[string]int, len(names))
+	for i, n := range names {
+		index[n] = i
+	}
+	adj := make([][]int, len(names))
+	for i, n := range names {
+		neighbors := d.nodes[n].Neighbors()
+		adj[i] = make([]int, 0, len(neighbors))
+		for _, nn := range neighbors {
+			if j, ok := index[nn.Identifier()]; ok {
+				adj[i] = append(adj[i], j)
+			}
+		}
+	}
+	return &sortIndex{names: names, adj: adj}
+}
+
+type visitState uint8
+
+const (
+	unvisited visitState = iota
+	visiting
+	visited
+)
+
+// A sorter topologically sorts nodes, identified by their index into names,
+// using depth first search.
+type sorter struct {
+	names   []string
+	adj     [][]int
+	state   []visitState
+	path    []int
+	results []string
+}
+
+func (s *sorter) visit(i int) error {
+	s.state[i] = visiting
+	s.path = append(s.path, i)
+	for _, j := range s.adj[i] {
+		switch s.state[j] {
+		case unvisited:
+			if err := s.visit(j); err != nil {
 				return err
 			}
-		} else if stack[n.Identifier()] {
-			return errors.Errorf("detected cycle on: %s", n.Identifier())
+		case visiting:
+			path := make([]string, len(s.path))
+			for k, p := range s.path {
+				path[k] = s.names[p]
+			}
+			return &CyclicError{Cycle: cycle(path, s.names[j])}
+		case visited:
 		}
 	}
-	for i, r := range results {
-		if r == "" {
-			results[i] = name
+	s.path = s.path[:len(s.path)-1]
+	s.state[i] = visited
+	s.results = append(s.results, s.names[i])
+	return nil
+}
+
+// cycle returns the cycle that closes when the last node of the supplied path
+// leads back to the supplied node.
+func cycle(path []string, to string) []string {
+	start := 0
+	for i, n := range path {
+		if n == to {
+			start = i
 			break
 		}
 	}
-	stack[name] = false
-	return nil
+	c := make([]string, 0, len(path)-start+1)
+	c = append(c, path[start:]...)
+	return append(c, to)
 }
@@ -17,11 +17,16 @@ limitations under the License.
 package dag
 
 import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
 
 	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
 )
 
 type simpleNode struct {
@@ -244,3 +249,582 @@ func TestDag(t *testing.T) {
 	d := NewMapDag()
 	d.AddNode(&simpleNode{identifier: "hi"})
 }
+
+func TestSortCycle(t *testing.T) {
+	cases := map[string]struct {
+		reason string
+		nodes  []simpleNode
+		want   error
+	}{
+		"TwoNodes": {
+			reason: "We should return the path of a cycle between two nodes.",
+			nodes: []simpleNode{
+				{identifier: "a", neighbors: map[string]simpleNode{"b": {identifier: "b"}}},
+				{identifier: "b", neighbors: map[string]simpleNode{"a": {identifier: "a"}}},
+			},
+			want: &CyclicError{Cycle: []string{"a", "b", "a"}},
+		},
+		"ThreeNodes": {
+			reason: "We should return the path of a cycle between three nodes in order.",
+			nodes: []simpleNode{
+				{identifier: "c", neighbors: map[string]simpleNode{"a": {identifier: "a"}}},
+				{identifier: "b", neighbors: map[string]simpleNode{"c": {identifier: "c"}}},
+				{identifier: "a", neighbors: map[string]simpleNode{"b": {identifier: "b"}}},
+			},
+			want: &CyclicError{Cycle: []string{"a", "b", "c", "a"}},
+		},
+		"SelfReference": {
+			reason: "We should return the path of a node that depends on itself.",
+			nodes: []simpleNode{
+				{identifier: "a", neighbors: map[string]simpleNode{"a": {identifier: "a"}}},
+			},
+			want: &CyclicError{Cycle: []string{"a", "a"}},
+		},
+		"ReachedFromOutside": {
+			reason: "We should only return the nodes that form the cycle, not those that lead to it.",
+			nodes: []simpleNode{
+				{identifier: "a", neighbors: map[string]simpleNode{"b": {identifier: "b"}}},
+				{identifier: "b", neighbors: map[string]simpleNode{"c": {identifier: "c"}}},
+				{identifier: "c", neighbors: map[string]simpleNode{"b": {identifier: "b"}}},
+			},
+			want: &CyclicError{Cycle: []string{"b", "c", "b"}},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			d := NewMapDag()
+			if _, err := d.Init(toNodes(tc.nodes)); err != nil {
+				t.Fatalf("\n%s\nInit(...): %s", tc.reason, err)
+			}
+			_, err := d.Sort()
+			if diff := cmp.Diff(tc.want, err); diff != "" {
+				t.Errorf("\n%s\nSort(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestNodeDependents(t *testing.T) {
+	nodes := []simpleNode{
+		{identifier: "c", neighbors: map[string]simpleNode{"d": {identifier: "d"}, "e": {identifier: "e"}}},
+		{identifier: "a", neighbors: map[string]simpleNode{"d": {identifier: "d"}}},
+		{identifier: "b", neighbors: map[string]simpleNode{"a": {identifier: "a"}}},
+	}
+
+	type want struct {
+		dependents []string
+		err        error
+	}
+
+	cases := map[string]struct {
+		reason     string
+		identifier string
+		want       want
+	}{
+		"ImpliedNode": {
+			reason:     "We should return every node that depends on an implied node, sorted by identifier.",
+			identifier: "d",
+			want:       want{dependents: []string{"a", "c"}},
+		},
+		"SingleDependent": {
+			reason:     "We should return the only node that depends on a node.",
+			identifier: "a",
+			want:       want{dependents: []string{"b"}},
+		},
+		"NoDependents": {
+			reason:     "We should return no nodes if nothing depends on a node.",
+			identifier: "b",
+			want:       want{dependents: []string{}},
+		},
+		"MissingNode": {
+			reason:     "We should return an error if the node does not exist.",
+			identifier: "z",
+			want:       want{err: errors.New("node does not exist")},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			d := NewMapDag()
+			if _, err := d.Init(toNodes(nodes)); err != nil {
+				t.Fatalf("\n%s\nInit(...): %s", tc.reason, err)
+			}
+			got, err := d.NodeDependents(tc.identifier)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nNodeDependents(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if tc.want.err != nil {
+				return
+			}
+			ids := make([]string, len(got))
+			for i, n := range got {
+				ids[i] = n.Identifier()
+			}
+			if diff := cmp.Diff(tc.want.dependents, ids); diff != "" {
+				t.Errorf("\n%s\nNodeDependents(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestDepths(t *testing.T) {
+	type want struct {
+		depths map[string]int
+		err    error
+	}
+
+	cases := map[string]struct {
+		reason string
+		nodes  []simpleNode
+		want   want
+	}{
+		"Empty": {
+			reason: "An empty graph should have no depths.",
+			want:   want{depths: map[string]int{}},
+		},
+		"Implied": {
+			reason: "Implied nodes should be one deeper than the node that implied them.",
+			nodes: []simpleNode{
+				{identifier: "a", neighbors: map[string]simpleNode{"b": {identifier: "b"}}},
+				{identifier: "b", neighbors: map[string]simpleNode{"c": {identifier: "c"}}},
+			},
+			want: want{depths: map[string]int{"a": 0, "b": 1, "c": 2}},
+		},
+		"LongestPath": {
+			reason: "A node should be deeper than every node that depends on it, even if a shallower node depends on it too.",
+			nodes: []simpleNode{
+				{identifier: "a", neighbors: map[string]simpleNode{"b": {identifier: "b"}, "d": {identifier: "d"}}},
+				{identifier: "b", neighbors: map[string]simpleNode{"c": {identifier: "c"}}},
+				{identifier: "c", neighbors: map[string]simpleNode{"d": {identifier: "d"}}},
+				{identifier: "e", neighbors: map[string]simpleNode{"d": {identifier: "d"}}},
+			},
+			want: want{depths: map[string]int{"a": 0, "b": 1, "c": 2, "d": 3, "e": 0}},
+		},
+		"Cycle": {
+			reason: "We should return an error if the graph cannot be sorted.",
+			nodes: []simpleNode{
+				{identifier: "a", neighbors: map[string]simpleNode{"b": {identifier: "b"}}},
+				{identifier: "b", neighbors: map[string]simpleNode{"a": {identifier: "a"}}},
+			},
+			want: want{err: &CyclicError{Cycle: []string{"a", "b", "a"}}},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			d := NewMapDag()
+			if _, err := d.Init(toNodes(tc.nodes)); err != nil {
+				t.Fatalf("\n%s\nInit(...): %s", tc.reason, err)
+			}
+			got, err := Depths(d)
+			if diff := cmp.Diff(tc.want.err, err); diff != "" {
+				t.Errorf("\n%s\nDepths(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.depths, got); diff != "" {
+				t.Errorf("\n%s\nDepths(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+// A constrainedNode is a neighbor that constrains the node it identifies.
+type constrainedNode struct {
+	simpleNode
+	constraints string
+	optional    bool
+}
+
+func (c *constrainedNode) GetConstraints() string {
+	return c.constraints
+}
+
+func (c *constrainedNode) IsOptional() bool {
+	return c.optional
+}
+
+// A dependentNode has constrained neighbors.
+type dependentNode struct {
+	identifier string
+	neighbors  []constrainedNode
+}
+
+func (d *dependentNode) Identifier() string { return d.identifier }
+
+func (d *dependentNode) Neighbors() []Node {
+	nodes := make([]Node, len(d.neighbors))
+	for i := range d.neighbors {
+		nodes[i] = &d.neighbors[i]
+	}
+	return nodes
+}
+
+func (d *dependentNode) AddNeighbors(...Node) error { return nil }
+
+func TestEdges(t *testing.T) {
+	// An edge is summarized as from -> to (constraints).
+	type want struct {
+		edges []string
+		err   error
+	}
+
+	cases := map[string]struct {
+		reason string
+		nodes  []Node
+		want   want
+	}{
+		"Empty": {
+			reason: "An empty graph should have no edges.",
+			want:   want{},
+		},
+		"Unconstrained": {
+			reason: "Edges to neighbors that aren't constrained should have no constraints.",
+			nodes:  toNodes([]simpleNode{{identifier: "a", neighbors: map[string]simpleNode{"b": {identifier: "b"}}}}),
+			want:   want{edges: []string{"a -> b ()"}},
+		},
+		"Constrained": {
+			reason: "Edges should be sorted, and carry the constraints of the neighbor that declared them.",
+			nodes: []Node{
+				&dependentNode{identifier: "b", neighbors: []constrainedNode{
+					{simpleNode: simpleNode{identifier: "d"}, constraints: ">=v1.0.0"},
+					{simpleNode: simpleNode{identifier: "c"}, constraints: "v0.1.0"},
+				}},
+				&dependentNode{identifier: "a", neighbors: []constrainedNode{
+					{simpleNode: simpleNode{identifier: "b"}, constraints: "*"},
+					{simpleNode: simpleNode{identifier: "c"}, constraints: "<v0.2.0"},
+				}},
+			},
+			want: want{edges: []string{"a -> b (*)", "a -> c (<v0.2.0)", "b -> c (v0.1.0)", "b -> d (>=v1.0.0)"}},
+		},
+		"Optional": {
+			reason: "Edges to optional neighbors should be optional, whether or not the neighbor is in the graph.",
+			nodes: []Node{
+				&dependentNode{identifier: "a", neighbors: []constrainedNode{
+					{simpleNode: simpleNode{identifier: "b"}, constraints: "*", optional: true},
+					{simpleNode: simpleNode{identifier: "c"}, constraints: "<v0.2.0", optional: true},
+				}},
+				&dependentNode{identifier: "b"},
+			},
+			want: want{edges: []string{"a -> b (*) optional", "a -> c (<v0.2.0) optional"}},
+		},
+		"Cycle": {
+			reason: "We should return an error if the graph cannot be sorted.",
+			nodes: toNodes([]simpleNode{
+				{identifier: "a", neighbors: map[string]simpleNode{"b": {identifier: "b"}}},
+				{identifier: "b", neighbors: map[string]simpleNode{"a": {identifier: "a"}}},
+			}),
+			want: want{err: &CyclicError{Cycle: []string{"a", "b", "a"}}},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			d := NewMapDag()
+			if _, err := d.Init(tc.nodes); err != nil {
+				t.Fatalf("\n%s\nInit(...): %s", tc.reason, err)
+			}
+			edges, err := Edges(d)
+			if diff := cmp.Diff(tc.want.err, err); diff != "" {
+				t.Errorf("\n%s\nEdges(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			var got []string
+			for _, e := range edges {
+				line := fmt.Sprintf("%s -> %s (%s)", e.From.Identifier(), e.To.Identifier(), e.Constraints)
+				if e.Optional {
+					line += " optional"
+				}
+				got = append(got, line)
+			}
+			if diff := cmp.Diff(tc.want.edges, got); diff != "" {
+				t.Errorf("\n%s\nEdges(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestOptional(t *testing.T) {
+	// a optionally depends on b and c, and requires d. c is installed.
+	nodes := []Node{
+		&dependentNode{identifier: "a", neighbors: []constrainedNode{
+			{simpleNode: simpleNode{identifier: "b"}, optional: true},
+			{simpleNode: simpleNode{identifier: "c"}, optional: true},
+			{simpleNode: simpleNode{identifier: "d"}},
+		}},
+		&dependentNode{identifier: "c"},
+	}
+
+	d := NewMapDag()
+	implied, err := d.Init(nodes)
+	if err != nil {
+		t.Fatalf("Init(...): %s", err)
+	}
+	var got []string
+	for _, n := range implied {
+		got = append(got, n.Identifier())
+	}
+	if diff := cmp.Diff([]string{"d"}, got); diff != "" {
+		t.Errorf("Init(...): a missing optional neighbor should not be implied: -want implied, +got implied:\n%s", diff)
+	}
+	if d.NodeExists("b") {
+		t.Errorf("Init(...): a missing optional neighbor should not be added to the graph")
+	}
+
+	tree, err := d.TraceNode("a")
+	if err != nil {
+		t.Fatalf("TraceNode(...): %s", err)
+	}
+	got = nil
+	for id := range tree {
+		got = append(got, id)
+	}
+	sort.Strings(got)
+	if diff := cmp.Diff([]string{"c", "d"}, got); diff != "" {
+		t.Errorf("TraceNode(...): a missing optional neighbor should not be part of the tree: -want, +got:\n%s", diff)
+	}
+}
+
+func TestCyclicError(t *testing.T) {
+	err := &CyclicError{Cycle: []string{"a", "b", "c", "a"}}
+	if diff := cmp.Diff("detected cycle: a -> b -> c -> a", err.Error()); diff != "" {
+		t.Errorf("Error(): -want, +got:\n%s", diff)
+	}
+}
+
+func TestTraverseFrom(t *testing.T) {
+	// a -> b -> d and a -> c -> d form a diamond, while e -> f is
+	// unreachable from it.
+	nodes := []simpleNode{
+		{identifier: "a", neighbors: map[string]simpleNode{"b": {identifier: "b"}, "c": {identifier: "c"}}},
+		{identifier: "b", neighbors: map[string]simpleNode{"d": {identifier: "d"}}},
+		{identifier: "c", neighbors: map[string]simpleNode{"d": {identifier: "d"}}},
+		{identifier: "e", neighbors: map[string]simpleNode{"f": {identifier: "f"}}},
+	}
+
+	type want struct {
+		reached map[string]bool
+		err     error
+	}
+
+	cases := map[string]struct {
+		reason string
+		roots  []string
+		want   want
+	}{
+		"Diamond": {
+			reason: "We should reach every node of a diamond once, but not nodes unreachable from it.",
+			roots:  []string{"a"},
+			want:   want{reached: map[string]bool{"a": true, "b": true, "c": true, "d": true}},
+		},
+		"MultipleRoots": {
+			reason: "We should return the union of the nodes reachable from each root.",
+			roots:  []string{"b", "e"},
+			want:   want{reached: map[string]bool{"b": true, "d": true, "e": true, "f": true}},
+		},
+		"Leaf": {
+			reason: "We should reach only a root that has no neighbors.",
+			roots:  []string{"d"},
+			want:   want{reached: map[string]bool{"d": true}},
+		},
+		"NoRoots": {
+			reason: "We should reach nothing if there are no roots.",
+			want:   want{reached: map[string]bool{}},
+		},
+		"MissingRoot": {
+			reason: "We should return an error if a root does not exist.",
+			roots:  []string{"a", "z"},
+			want:   want{err: errors.New("node z does not exist")},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			d := NewMapDag()
+			if _, err := d.Init(toNodes(nodes)); err != nil {
+				t.Fatalf("\n%s\nInit(...): %s", tc.reason, err)
+			}
+			got, err := d.TraverseFrom(tc.roots...)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nTraverseFrom(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.reached, got); diff != "" {
+				t.Errorf("\n%s\nTraverseFrom(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+// benchmarkNodes returns n nodes, each of which has up to fanout neighbors.
+// Nodes only depend on nodes with a higher index, so the graph is acyclic.
+func TestImpliedOrder(t *testing.T) {
+	// The neighbors of a simpleNode are returned in random order.
+	node := func(id string, neighbors ...string) simpleNode {
+		n := simpleNode{identifier: id, neighbors: map[string]simpleNode{}}
+		for _, nb := range neighbors {
+			n.neighbors[nb] = simpleNode{identifier: nb, neighbors: map[string]simpleNode{}}
+		}
+		return n
+	}
+	nodes := []simpleNode{
+		node("crossplane/a", "crossplane/z", "crossplane/m", "crossplane/b"),
+		node("crossplane/c", "crossplane/y", "crossplane/b"),
+		node("crossplane/d"),
+	}
+	want := []string{"crossplane/b", "crossplane/m", "crossplane/y", "crossplane/z"}
+
+	identifiers := func(nodes []ImpliedNode) []string {
+		ids := make([]string, len(nodes))
+		for i, n := range nodes {
+			ids[i] = n.Identifier()
+		}
+		return ids
+	}
+
+	// Shuffle deterministically, so that a failure is reproducible.
+	r := rand.New(rand.NewSource(1)) // nolint:gosec
+	for i := 0; i < 100; i++ {
+		in := toNodes(nodes)
+		r.Shuffle(len(in), func(i, j int) { in[i], in[j] = in[j], in[i] })
+
+		implied, err := NewMapDag().Init(in)
+		if err != nil {
+			t.Fatalf("Init(...): run %d: %s", i, err)
+		}
+		if diff := cmp.Diff(want, identifiers(implied)); diff != "" {
+			t.Fatalf("Init(...): run %d: -want implied, +got implied:\n%s", i, diff)
+		}
+
+		d := NewMapDag()
+		if _, err := d.Init(nil); err != nil {
+			t.Fatalf("Init(...): run %d: %s", i, err)
+		}
+		edges := map[string][]Node{}
+		for _, n := range in {
+			if err := d.AddNode(&simpleNode{identifier: n.Identifier(), neighbors: map[string]simpleNode{}}); err != nil {
+				t.Fatalf("AddNode(...): run %d: %s", i, err)
+			}
+			edges[n.Identifier()] = n.Neighbors()
+		}
+		implied, err = d.AddEdges(edges)
+		if err != nil {
+			t.Fatalf("AddEdges(...): run %d: %s", i, err)
+		}
+		if diff := cmp.Diff(want, identifiers(implied)); diff != "" {
+			t.Fatalf("AddEdges(...): run %d: -want implied, +got implied:\n%s", i, diff)
+		}
+	}
+}
+
+func benchmarkNodes(n, fanout int) []Node {
+	nodes := make([]simpleNode, n)
+	for i := range nodes {
+		nodes[i] = simpleNode{identifier: fmt.Sprintf("node-%d", i), neighbors: map[string]simpleNode{}}
+	}
+	for i := range nodes {
+		for j := 1; j <= fanout; j++ {
+			// Neighbors are spread across the graph, like packages that
+			// depend on a few common providers.
+			k := i + j*j*7 + 1
+			if k >= n {
+				break
+			}
+			nodes[i].neighbors[nodes[k].identifier] = simpleNode{identifier: nodes[k].identifier}
+		}
+	}
+	return toNodes(nodes)
+}
+
+func BenchmarkInit(b *testing.B) {
+	for _, n := range []int{100, 500, 2000} {
+		nodes := benchmarkNodes(n, 4)
+		b.Run(strconv.Itoa(n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := NewMapDag().Init(nodes); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkSort(b *testing.B) {
+	for _, n := range []int{100, 500, 2000} {
+		d := NewMapDag()
+		if _, err := d.Init(benchmarkNodes(n, 4)); err != nil {
+			b.Fatal(err)
+		}
+		b.Run(strconv.Itoa(n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := d.Sort(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkInitAndSort measures a fresh graph being built and sorted, as it is
+// on every reconcile of the package Lock.
+func BenchmarkInitAndSort(b *testing.B) {
+	for _, n := range []int{100, 500, 2000} {
+		nodes := benchmarkNodes(n, 4)
+		b.Run(strconv.Itoa(n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				d := NewMapDag()
+				if _, err := d.Init(nodes); err != nil {
+					b.Fatal(err)
+				}
+				if _, err := d.Sort(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkNodeDependents(b *testing.B) {
+	for _, n := range []int{100, 500, 2000} {
+		d := NewMapDag()
+		if _, err := d.Init(benchmarkNodes(n, 4)); err != nil {
+			b.Fatal(err)
+		}
+		id := fmt.Sprintf("node-%d", n-1)
+		b.Run(strconv.Itoa(n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := d.NodeDependents(id); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestImpliedNodeString(t *testing.T) {
+	cases := map[string]struct {
+		reason string
+		node   ImpliedNode
+		want   string
+	}{
+		"Node": {
+			reason: "We should describe an implied node by its type, identifier, and the node that implied it.",
+			node:   ImpliedNode{Node: &simpleNode{identifier: "crossplane/b"}, ImpliedBy: "crossplane/a"},
+			want:   "*dag.simpleNode crossplane/b implied by crossplane/a",
+		},
+		"NilNode": {
+			reason: "We should describe a nil implied node without panicking.",
+			node:   ImpliedNode{ImpliedBy: "crossplane/a"},
+			want:   "<nil> implied by crossplane/a",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if diff := cmp.Diff(tc.want, tc.node.String()); diff != "" {
+				t.Errorf("\n%s\nString(): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -24,21 +24,23 @@ var _ dag.DAG = &MockDag{}
 
 // MockDag is a mock DAG.
 type MockDag struct {
-	MockInit             func(nodes []dag.Node, fns ...dag.NodeFn) ([]dag.Node, error)
+	MockInit             func(nodes []dag.Node, fns ...dag.NodeFn) ([]dag.ImpliedNode, error)
 	MockAddNode          func(dag.Node) error
 	MockAddNodes         func(...dag.Node) error
 	MockAddOrUpdateNodes func(...dag.Node)
 	MockGetNode          func(identifier string) (dag.Node, error)
 	MockAddEdge          func(from string, to dag.Node) (bool, error)
-	MockAddEdges         func(edges map[string][]dag.Node) ([]dag.Node, error)
+	MockAddEdges         func(edges map[string][]dag.Node) ([]dag.ImpliedNode, error)
 	MockNodeExists       func(identifier string) bool
 	MockNodeNeighbors    func(identifier string) ([]dag.Node, error)
+	MockNodeDependents   func(identifier string) ([]dag.Node, error)
 	MockTraceNode        func(identifier string) (map[string]dag.Node, error)
+	MockTraverseFrom     func(identifiers ...string) (map[string]bool, error)
 	MockSort             func() ([]string, error)
 }
 
 // Init calls the underlying MockInit.
-func (d *MockDag) Init(nodes []dag.Node, fns ...dag.NodeFn) ([]dag.Node, error) {
+func (d *MockDag) Init(nodes []dag.Node, fns ...dag.NodeFn) ([]dag.ImpliedNode, error) {
 	return d.MockInit(nodes, fns...)
 }
 
@@ -68,7 +70,7 @@ func (d *MockDag) AddEdge(from string, to dag.Node) (bool, error) {
 }
 
 // AddEdges calls the underlying MockAddEdges.
-func (d *MockDag) AddEdges(edges map[string][]dag.Node) ([]dag.Node, error) {
+func (d *MockDag) AddEdges(edges map[string][]dag.Node) ([]dag.ImpliedNode, error) {
 	return d.MockAddEdges(edges)
 }
 
@@ -82,11 +84,21 @@ func (d *MockDag) NodeNeighbors(i string) ([]dag.Node, error) {
 	return d.MockNodeNeighbors(i)
 }
 
+// NodeDependents calls the underlying MockNodeDependents.
+func (d *MockDag) NodeDependents(i string) ([]dag.Node, error) {
+	return d.MockNodeDependents(i)
+}
+
 // TraceNode calls the underlying MockTraceNode.
 func (d *MockDag) TraceNode(i string) (map[string]dag.Node, error) {
 	return d.MockTraceNode(i)
 }
 
+// TraverseFrom calls the underlying MockTraverseFrom.
+func (d *MockDag) TraverseFrom(i ...string) (map[string]bool, error) {
+	return d.MockTraverseFrom(i...)
+}
+
 // Sort calls the underlying MockSort.
 func (d *MockDag) Sort() ([]string, error) {
 	return d.MockSort()
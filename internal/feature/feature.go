@@ -29,6 +29,46 @@ const (
 	// CompositionRevisions. See the below design for more details.
 	// https://github.com/crossplane/crossplane/blob/ecd9d5/design/one-pager-composition-revisions.md
 	FlagEnableAlphaCompositionRevisions Flag = iota
+
+	// FlagEnableAlphaDependencyHealthGating enables alpha support for waiting
+	// for auto-installed dependency packages to become healthy before their
+	// own dependencies are installed.
+	FlagEnableAlphaDependencyHealthGating
+
+	// FlagEnableAlphaLockRepair enables alpha support for removing stale
+	// duplicate packages from the Lock.
+	FlagEnableAlphaLockRepair
+
+	// FlagEnableAlphaDependencyAdmission enables alpha support for validating
+	// that the dependencies of a Configuration or Provider can be satisfied
+	// when it is created, or its package changes.
+	FlagEnableAlphaDependencyAdmission
+
+	// FlagEnableAlphaDigestPinning enables alpha support for installing
+	// dependency packages at the digest their selected tag points at.
+	FlagEnableAlphaDigestPinning
+
+	// FlagEnableAlphaDependencyApproval enables alpha support for requiring
+	// that dependency packages be approved before they are installed.
+	FlagEnableAlphaDependencyApproval
+
+	// FlagEnableAlphaLockVerification enables alpha support for verifying
+	// that each package in the Lock is backed by a package revision.
+	FlagEnableAlphaLockVerification
+
+	// FlagEnableAlphaLockCompaction enables alpha support for removing
+	// packages from the Lock that no user-installed package depends on.
+	FlagEnableAlphaLockCompaction
+
+	// FlagEnableAlphaDependencyConflictDetection enables alpha support for
+	// refusing to install dependency providers that serve API groups another
+	// installed provider serves.
+	FlagEnableAlphaDependencyConflictDetection
+
+	// FlagEnableAlphaLockAdmission enables alpha support for rejecting
+	// changes to the package Lock that would prevent its dependencies from
+	// being resolved.
+	FlagEnableAlphaLockAdmission
 )
 
 // Flags that are enabled. The zero value - i.e. &feature.Flags{} - is usable.
@@ -9,11 +9,20 @@ func _() {
 	// Re-run the stringer command to generate them again.
 	var x [1]struct{}
 	_ = x[FlagEnableAlphaCompositionRevisions-0]
+	_ = x[FlagEnableAlphaDependencyHealthGating-1]
+	_ = x[FlagEnableAlphaLockRepair-2]
+	_ = x[FlagEnableAlphaDependencyAdmission-3]
+	_ = x[FlagEnableAlphaDigestPinning-4]
+	_ = x[FlagEnableAlphaDependencyApproval-5]
+	_ = x[FlagEnableAlphaLockVerification-6]
+	_ = x[FlagEnableAlphaLockCompaction-7]
+	_ = x[FlagEnableAlphaDependencyConflictDetection-8]
+	_ = x[FlagEnableAlphaLockAdmission-9]
 }
 
-const _Flag_name = "FlagEnableAlphaCompositionRevisions"
+const _Flag_name = "FlagEnableAlphaCompositionRevisionsFlagEnableAlphaDependencyHealthGatingFlagEnableAlphaLockRepairFlagEnableAlphaDependencyAdmissionFlagEnableAlphaDigestPinningFlagEnableAlphaDependencyApprovalFlagEnableAlphaLockVerificationFlagEnableAlphaLockCompactionFlagEnableAlphaDependencyConflictDetectionFlagEnableAlphaLockAdmission"
 
-var _Flag_index = [...]uint8{0, 35}
+var _Flag_index = [...]uint16{0, 35, 72, 97, 131, 159, 192, 223, 252, 294, 322}
 
 func (i Flag) String() string {
 	if i < 0 || i >= Flag(len(_Flag_index)-1) {
@@ -0,0 +1,75 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dependency
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+
+	v1 "github.com/crossplane/crossplane/apis/pkg/v1"
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+	"github.com/crossplane/crossplane/internal/xpkg"
+)
+
+const (
+	// defaultMetaTimeout is how long a MetaDependencyLister waits for the
+	// metadata of a package by default.
+	defaultMetaTimeout = 2 * time.Second
+
+	errBadReference        = "package tag is not a valid reference"
+	errFetchPackageMeta    = "cannot fetch package metadata"
+	errUnknownDependencies = "package has no meta artifact from which to read its dependencies"
+)
+
+// A MetaDependencyLister lists the dependencies a package declares by
+// fetching its metadata, without pulling its layers.
+type MetaDependencyLister struct {
+	fetcher  xpkg.Fetcher
+	registry string
+	timeout  time.Duration
+}
+
+// NewMetaDependencyLister returns a DependencyLister that fetches the metadata
+// of packages using the supplied Fetcher.
+func NewMetaDependencyLister(f xpkg.Fetcher, registry string) *MetaDependencyLister {
+	return &MetaDependencyLister{fetcher: f, registry: registry, timeout: defaultMetaTimeout}
+}
+
+// ListDependencies the supplied package declares in its metadata. Only the
+// meta artifact a package's publisher may attach to it lists its
+// dependencies, so the dependencies of a package without one are unknown.
+func (l *MetaDependencyLister) ListDependencies(ctx context.Context, p v1.Package) ([]v1beta1.Dependency, error) {
+	ref, err := name.ParseReference(p.GetSource(), name.WithDefaultRegistry(l.registry))
+	if err != nil {
+		return nil, errors.Wrap(err, errBadReference)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, l.timeout)
+	defer cancel()
+	m, err := l.fetcher.FetchMeta(ctx, ref, v1.RefNames(p.GetPackagePullSecrets())...)
+	if err != nil {
+		return nil, errors.Wrap(err, errFetchPackageMeta)
+	}
+	if m == nil || !m.Complete {
+		return nil, errors.New(errUnknownDependencies)
+	}
+	return xpkg.ConvertDependencies(m.Dependencies), nil
+}
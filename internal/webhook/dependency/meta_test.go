@@ -0,0 +1,93 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dependency
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	pkgmetav1 "github.com/crossplane/crossplane/apis/pkg/meta/v1"
+	v1 "github.com/crossplane/crossplane/apis/pkg/v1"
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+	"github.com/crossplane/crossplane/internal/xpkg"
+	fakexpkg "github.com/crossplane/crossplane/internal/xpkg/fake"
+)
+
+func TestMetaDependencyLister(t *testing.T) {
+	errBoom := errors.New("boom")
+	aws := "crossplane/provider-aws"
+
+	type want struct {
+		deps []v1beta1.Dependency
+		err  error
+	}
+
+	cases := map[string]struct {
+		reason string
+		source string
+		meta   *xpkg.PackageMeta
+		err    error
+		want   want
+	}{
+		"Complete": {
+			reason: "We should return the dependencies the package's meta artifact declares.",
+			source: "cool/config:v1.0.0",
+			meta: &xpkg.PackageMeta{
+				Complete:     true,
+				Dependencies: []pkgmetav1.Dependency{{Provider: &aws, Version: ">=v1.0.0"}},
+			},
+			want: want{
+				deps: []v1beta1.Dependency{{Package: aws, Type: v1beta1.ProviderPackageType, Constraints: ">=v1.0.0"}},
+			},
+		},
+		"Incomplete": {
+			reason: "We should return an error if the package has no meta artifact, rather than pulling it.",
+			source: "cool/config:v1.0.0",
+			meta:   &xpkg.PackageMeta{Kind: pkgmetav1.ConfigurationKind},
+			want: want{
+				err: errors.New(errUnknownDependencies),
+			},
+		},
+		"ErrFetchMeta": {
+			reason: "We should return an error if we cannot fetch the package's metadata.",
+			source: "cool/config:v1.0.0",
+			err:    errBoom,
+			want: want{
+				err: errors.Wrap(errBoom, errFetchPackageMeta),
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			c := &v1.Configuration{Spec: v1.ConfigurationSpec{PackageSpec: v1.PackageSpec{Package: tc.source}}}
+			l := NewMetaDependencyLister(&fakexpkg.MockFetcher{MockFetchMeta: fakexpkg.NewMockFetchMetaFn(tc.meta, tc.err)}, "")
+			got, err := l.ListDependencies(context.Background(), c)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nl.ListDependencies(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.deps, got); diff != "" {
+				t.Errorf("\n%s\nl.ListDependencies(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,351 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package dependency validates that the dependencies of a package can be
+// satisfied before it is installed.
+package dependency
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/crossplane-runtime/pkg/resource"
+
+	v1 "github.com/crossplane/crossplane/apis/pkg/v1"
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+	"github.com/crossplane/crossplane/internal/dag"
+	"github.com/crossplane/crossplane/internal/xpkg"
+)
+
+const (
+	// Path at which the validating webhook is served.
+	Path = "/validate-package-dependencies"
+
+	lockName = "lock"
+
+	defaultTimeout = 5 * time.Second
+)
+
+const (
+	errUnsupportedKindFmt = "unsupported kind %q"
+	errDecode             = "cannot decode package"
+	errParseSource        = "cannot parse package source"
+	errListDependencies   = "cannot list package dependencies"
+	errGetLock            = "cannot get package Lock"
+	errResolve            = "cannot resolve package dependencies"
+
+	msgUnsatisfiableFmt = "dependencies of package %s cannot be satisfied: %s"
+	msgUnverifiedFmt    = "cannot verify that dependencies of package %s can be satisfied: %s"
+)
+
+// A Mode determines what the Validator does with a package whose
+// dependencies cannot be satisfied.
+type Mode string
+
+// Validation modes.
+const (
+	// ModeWarn admits a package whose dependencies cannot be satisfied,
+	// with a warning explaining why.
+	ModeWarn Mode = "Warn"
+
+	// ModeReject denies a package whose dependencies cannot be satisfied.
+	ModeReject Mode = "Reject"
+)
+
+// A DependencyLister lists the dependencies a package declares.
+type DependencyLister interface {
+	ListDependencies(ctx context.Context, p v1.Package) ([]v1beta1.Dependency, error)
+}
+
+// A DependencyListerFn is a function that satisfies DependencyLister.
+type DependencyListerFn func(ctx context.Context, p v1.Package) ([]v1beta1.Dependency, error)
+
+// ListDependencies the supplied package declares.
+func (fn DependencyListerFn) ListDependencies(ctx context.Context, p v1.Package) ([]v1beta1.Dependency, error) {
+	return fn(ctx, p)
+}
+
+// A ValidatorOption configures a Validator.
+type ValidatorOption func(*Validator)
+
+// WithLogger specifies how the Validator should log messages.
+func WithLogger(l logging.Logger) ValidatorOption {
+	return func(v *Validator) {
+		v.log = l
+	}
+}
+
+// WithMode specifies what the Validator should do with a package whose
+// dependencies cannot be satisfied. Packages are admitted with a warning by
+// default.
+func WithMode(m Mode) ValidatorOption {
+	return func(v *Validator) {
+		v.mode = m
+	}
+}
+
+// WithTimeout specifies how long the Validator may spend fetching the metadata
+// of a package and the tags of its dependencies. A package whose dependencies can't be
+// validated in time is admitted with a warning.
+func WithTimeout(d time.Duration) ValidatorOption {
+	return func(v *Validator) {
+		v.timeout = d
+	}
+}
+
+// WithDependencyLister specifies how the Validator should list the
+// dependencies a package declares.
+func WithDependencyLister(l DependencyLister) ValidatorOption {
+	return func(v *Validator) {
+		v.lister = l
+	}
+}
+
+// WithFetcher specifies how the Validator should fetch the tags of a
+// package's dependencies.
+func WithFetcher(f xpkg.Fetcher) ValidatorOption {
+	return func(v *Validator) {
+		v.fetcher = f
+	}
+}
+
+// WithResolver specifies how the Validator should resolve a package's
+// dependencies.
+func WithResolver(r *xpkg.Resolver) ValidatorOption {
+	return func(v *Validator) {
+		v.resolver = r
+	}
+}
+
+// A Validator validates that the dependencies of a Configuration or Provider
+// can be satisfied, given the packages that are already installed. It never
+// prevents a package from being admitted because its dependencies could not
+// be validated, for example because a registry is unavailable.
+type Validator struct {
+	client   client.Reader
+	lister   DependencyLister
+	fetcher  xpkg.Fetcher
+	resolver *xpkg.Resolver
+	log      logging.Logger
+	mode     Mode
+	timeout  time.Duration
+}
+
+// NewValidator returns a Validator that validates packages against the Lock
+// read from the supplied client.
+func NewValidator(c client.Reader, opts ...ValidatorOption) *Validator {
+	v := &Validator{
+		client: c,
+		lister: DependencyListerFn(func(_ context.Context, _ v1.Package) ([]v1beta1.Dependency, error) {
+			return nil, nil
+		}),
+		resolver: xpkg.NewResolver(),
+		log:      logging.NewNopLogger(),
+		mode:     ModeWarn,
+		timeout:  defaultTimeout,
+	}
+	for _, o := range opts {
+		o(v)
+	}
+	return v
+}
+
+// Setup registers a Validator for Configurations and Providers with the
+// supplied manager's webhook server.
+func Setup(mgr ctrl.Manager, l logging.Logger, namespace, registry string, mode Mode) error {
+	clientset, err := kubernetes.NewForConfig(mgr.GetConfig())
+	if err != nil {
+		return errors.Wrap(err, "failed to initialize host clientset with in cluster config")
+	}
+	f := xpkg.NewK8sFetcher(clientset, namespace)
+	v := NewValidator(mgr.GetAPIReader(),
+		WithLogger(l.WithValues("webhook", Path)),
+		WithMode(mode),
+		WithDependencyLister(NewMetaDependencyLister(f, registry)),
+		WithFetcher(f),
+		WithResolver(xpkg.NewResolver(
+			xpkg.WithLogger(l),
+			xpkg.WithNewDAGFn(dag.NewMapDag),
+			xpkg.WithDefaultRegistry(registry),
+		)),
+	)
+	mgr.GetWebhookServer().Register(Path, &webhook.Admission{Handler: v})
+	return nil
+}
+
+// Handle an admission request for a Configuration or Provider.
+func (v *Validator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	p, err := decode(req.Kind.Kind, req.Object.Raw)
+	if err != nil {
+		return admission.Errored(http.StatusBadRequest, errors.Wrap(err, errDecode))
+	}
+
+	// Only a new package, or a change to the package of an existing one,
+	// can change what must be installed to satisfy its dependencies.
+	if req.Operation == admissionv1.Update {
+		if old, err := decode(req.Kind.Kind, req.OldObject.Raw); err == nil && old.GetSource() == p.GetSource() {
+			return admission.Allowed("")
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, v.timeout)
+	defer cancel()
+
+	log := v.log.WithValues("package", p.GetSource())
+	problems, unverified := v.validate(ctx, p)
+	warnings := make([]string, 0, len(unverified)+1)
+	if len(unverified) > 0 {
+		log.Debug("Cannot verify package dependencies", "reasons", unverified)
+		warnings = append(warnings, fmt.Sprintf(msgUnverifiedFmt, p.GetSource(), strings.Join(unverified, "; ")))
+	}
+	if len(problems) == 0 {
+		return admission.Allowed("").WithWarnings(warnings...)
+	}
+
+	msg := fmt.Sprintf(msgUnsatisfiableFmt, p.GetSource(), strings.Join(problems, "; "))
+	log.Debug("Package dependencies cannot be satisfied", "mode", v.mode, "problems", problems)
+	if v.mode == ModeReject {
+		return admission.Denied(msg).WithWarnings(warnings...)
+	}
+	return admission.Allowed("").WithWarnings(append(warnings, msg)...)
+}
+
+// validate returns why the dependencies of the supplied package cannot be
+// satisfied, and why they could not be validated, if they couldn't.
+func (v *Validator) validate(ctx context.Context, p v1.Package) (problems, unverified []string) { // nolint:gocyclo
+	ref, err := name.ParseReference(p.GetSource(), name.WithDefaultRegistry(""))
+	if err != nil {
+		return []string{errors.Wrap(err, errParseSource).Error()}, nil
+	}
+
+	deps, err := v.lister.ListDependencies(ctx, p)
+	if err != nil {
+		return nil, []string{errors.Wrap(err, errListDependencies).Error()}
+	}
+	if len(deps) == 0 {
+		return nil, nil
+	}
+
+	lock := &v1beta1.Lock{}
+	if err := v.client.Get(ctx, types.NamespacedName{Name: lockName}, lock); resource.IgnoreNotFound(err) != nil {
+		return nil, []string{errors.Wrap(err, errGetLock).Error()}
+	}
+
+	// The incoming package replaces any version of itself that is already
+	// installed.
+	self := v1beta1.LockPackage{
+		Name:         p.GetName(),
+		Type:         packageType(p),
+		Source:       xpkg.ParsePackageSourceFromReference(ref),
+		Version:      ref.Identifier(),
+		Dependencies: deps,
+	}
+	pkgs := make([]v1beta1.LockPackage, 0, len(lock.Packages)+1)
+	for _, lp := range lock.Packages {
+		if lp.Identifier() != self.Identifier() {
+			pkgs = append(pkgs, lp)
+		}
+	}
+	pkgs = append(pkgs, self)
+
+	plan, conflicts, err := v.resolver.Resolve(ctx, pkgs, v.fetcher)
+	if err != nil {
+		if cyclic(err, self.Source) {
+			return []string{err.Error()}, nil
+		}
+		return nil, []string{errors.Wrap(err, errResolve).Error()}
+	}
+
+	for _, c := range conflicts {
+		if c.Package == self.Source || requiredBy(c.Requirements, self.Source) {
+			problems = append(problems, c.Error())
+		}
+	}
+	for _, pi := range plan {
+		if pi.Err == nil || !requiredBy(pi.Requirements, self.Source) {
+			continue
+		}
+		switch pi.Err.Reason {
+		case xpkg.DependencyFetchTags, xpkg.DependencyNotListed:
+			unverified = append(unverified, pi.Err.Error())
+		default:
+			problems = append(problems, pi.Err.Error())
+		}
+	}
+	return problems, unverified
+}
+
+// decode the supplied raw object of the supplied kind.
+func decode(kind string, raw []byte) (v1.Package, error) {
+	var p v1.Package
+	switch kind {
+	case v1.ConfigurationKind:
+		p = &v1.Configuration{}
+	case v1.ProviderKind:
+		p = &v1.Provider{}
+	default:
+		return nil, errors.Errorf(errUnsupportedKindFmt, kind)
+	}
+	return p, json.Unmarshal(raw, p)
+}
+
+func packageType(p v1.Package) v1beta1.PackageType {
+	if _, ok := p.(*v1.Provider); ok {
+		return v1beta1.ProviderPackageType
+	}
+	return v1beta1.ConfigurationPackageType
+}
+
+// requiredBy returns true if any of the supplied requirements are placed by
+// the supplied parent.
+func requiredBy(reqs []xpkg.Requirement, parent string) bool {
+	for _, r := range reqs {
+		if r.Parent == parent {
+			return true
+		}
+	}
+	return false
+}
+
+// cyclic returns true if the supplied error reports a cycle that includes the
+// supplied package.
+func cyclic(err error, pkg string) bool {
+	cerr := &dag.CyclicError{}
+	if !errors.As(err, &cerr) {
+		return false
+	}
+	for _, p := range cerr.Cycle {
+		if p == pkg {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,262 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dependency
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	admissionv1 "k8s.io/api/admission/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	v1 "github.com/crossplane/crossplane/apis/pkg/v1"
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+	"github.com/crossplane/crossplane/internal/xpkg"
+	fakexpkg "github.com/crossplane/crossplane/internal/xpkg/fake"
+)
+
+func TestHandle(t *testing.T) {
+	errBoom := errors.New("boom")
+
+	aws := v1beta1.LockPackage{Name: "provider-aws-a1b2c3", Source: "crossplane/provider-aws", Type: v1beta1.ProviderPackageType, Version: "v0.21.0"}
+	old := v1beta1.LockPackage{Name: "cool-config-a1b2c3", Source: "cool/config", Type: v1beta1.ConfigurationPackageType, Version: "v1.0.0"}
+	other := v1beta1.LockPackage{
+		Name:    "other-config-a1b2c3",
+		Source:  "other/config",
+		Type:    v1beta1.ConfigurationPackageType,
+		Version: "v1.0.0",
+		Dependencies: []v1beta1.Dependency{
+			{Package: "crossplane/provider-aws", Type: v1beta1.ProviderPackageType, Constraints: ">=v1.0.0"},
+		},
+	}
+
+	config := func(pkg string) runtime.RawExtension {
+		c := &v1.Configuration{Spec: v1.ConfigurationSpec{PackageSpec: v1.PackageSpec{Package: pkg}}}
+		c.SetName("cool-config")
+		raw, _ := json.Marshal(c)
+		return runtime.RawExtension{Raw: raw}
+	}
+	create := func(pkg string) admission.Request {
+		return admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+			Operation: admissionv1.Create,
+			Kind:      metav1.GroupVersionKind{Group: v1.Group, Version: v1.Version, Kind: v1.ConfigurationKind},
+			Object:    config(pkg),
+		}}
+	}
+	depends := func(deps ...v1beta1.Dependency) DependencyLister {
+		return DependencyListerFn(func(_ context.Context, _ v1.Package) ([]v1beta1.Dependency, error) {
+			return deps, nil
+		})
+	}
+	lock := func(pkgs ...v1beta1.LockPackage) client.Reader {
+		return &test.MockClient{MockGet: test.NewMockGetFn(nil, func(o client.Object) error {
+			o.(*v1beta1.Lock).Packages = pkgs
+			return nil
+		})}
+	}
+	tags := func(tags ...string) xpkg.Fetcher {
+		return &fakexpkg.MockFetcher{MockTags: fakexpkg.NewMockTagsFn(tags, nil)}
+	}
+
+	type args struct {
+		client  client.Reader
+		lister  DependencyLister
+		fetcher xpkg.Fetcher
+		mode    Mode
+		req     admission.Request
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   admission.Response
+	}{
+		"AcceptInstalled": {
+			reason: "We should admit a package whose dependencies are installed at a version that satisfies them.",
+			args: args{
+				client:  lock(aws),
+				lister:  depends(v1beta1.Dependency{Package: "crossplane/provider-aws", Type: v1beta1.ProviderPackageType, Constraints: ">=v0.20.0"}),
+				fetcher: tags(),
+				mode:    ModeReject,
+				req:     create("cool/config:v1.1.0"),
+			},
+			want: admission.Allowed(""),
+		},
+		"AcceptMissing": {
+			reason: "We should admit a package whose missing dependencies have a version that satisfies them.",
+			args: args{
+				client:  test.NewMockClient(),
+				lister:  depends(v1beta1.Dependency{Package: "crossplane/provider-gcp", Type: v1beta1.ProviderPackageType, Constraints: ">=v0.18.0"}),
+				fetcher: tags("v0.17.0", "v0.18.0"),
+				mode:    ModeReject,
+				req:     create("cool/config:v1.1.0"),
+			},
+			want: admission.Allowed(""),
+		},
+		"AcceptUnrelatedConflict": {
+			reason: "We should admit a package even if other packages' dependencies conflict.",
+			args: args{
+				client:  lock(aws, other),
+				lister:  depends(v1beta1.Dependency{Package: "crossplane/provider-aws", Type: v1beta1.ProviderPackageType, Constraints: ">=v0.20.0"}),
+				fetcher: tags(),
+				mode:    ModeReject,
+				req:     create("cool/config:v1.1.0"),
+			},
+			want: admission.Allowed(""),
+		},
+		"AcceptUnchanged": {
+			reason: "We should admit an update that doesn't change the package without validating it.",
+			args: args{
+				lister: DependencyListerFn(func(_ context.Context, _ v1.Package) ([]v1beta1.Dependency, error) {
+					return nil, errBoom
+				}),
+				mode: ModeReject,
+				req: admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+					Operation: admissionv1.Update,
+					Kind:      metav1.GroupVersionKind{Group: v1.Group, Version: v1.Version, Kind: v1.ConfigurationKind},
+					Object:    config("cool/config:v1.1.0"),
+					OldObject: config("cool/config:v1.1.0"),
+				}},
+			},
+			want: admission.Allowed(""),
+		},
+		"WarnConflict": {
+			reason: "We should admit a package whose dependencies conflict with an installed package with a warning, if we're configured to warn.",
+			args: args{
+				client:  lock(aws, old),
+				lister:  depends(v1beta1.Dependency{Package: "crossplane/provider-aws", Type: v1beta1.ProviderPackageType, Constraints: ">=v1.0.0"}),
+				fetcher: tags(),
+				mode:    ModeWarn,
+				req:     create("cool/config:v1.1.0"),
+			},
+			want: admission.Allowed("").WithWarnings(
+				`dependencies of package cool/config:v1.1.0 cannot be satisfied: installed version v0.21.0 of package crossplane/provider-aws does not satisfy ">=v1.0.0" required by cool/config`,
+			),
+		},
+		"RejectConflict": {
+			reason: "We should deny a package whose dependencies conflict with an installed package, if we're configured to reject.",
+			args: args{
+				client:  lock(aws, old),
+				lister:  depends(v1beta1.Dependency{Package: "crossplane/provider-aws", Type: v1beta1.ProviderPackageType, Constraints: ">=v1.0.0"}),
+				fetcher: tags(),
+				mode:    ModeReject,
+				req:     create("cool/config:v1.1.0"),
+			},
+			want: admission.Denied(`dependencies of package cool/config:v1.1.0 cannot be satisfied: installed version v0.21.0 of package crossplane/provider-aws does not satisfy ">=v1.0.0" required by cool/config`),
+		},
+		"RejectNoValidVersion": {
+			reason: "We should deny a package whose missing dependencies have no version that satisfies them.",
+			args: args{
+				client:  test.NewMockClient(),
+				lister:  depends(v1beta1.Dependency{Package: "crossplane/provider-gcp", Type: v1beta1.ProviderPackageType, Constraints: ">=v1.0.0"}),
+				fetcher: tags("v0.17.0", "v0.18.0"),
+				mode:    ModeReject,
+				req:     create("cool/config:v1.1.0"),
+			},
+			want: admission.Denied(`dependencies of package cool/config:v1.1.0 cannot be satisfied: cannot find a valid version for package constraints: dependency (crossplane/provider-gcp) does not have version in constraints (>=v1.0.0): highest available version is v0.18.0`),
+		},
+		"RejectInvalidDependency": {
+			reason: "We should deny a package whose dependencies are not valid package references.",
+			args: args{
+				client:  test.NewMockClient(),
+				lister:  depends(v1beta1.Dependency{Package: "crossplane/PROVIDER-GCP", Type: v1beta1.ProviderPackageType, Constraints: ">=v1.0.0"}),
+				fetcher: tags(),
+				mode:    ModeReject,
+				req:     create("cool/config:v1.1.0"),
+			},
+			want: admission.Denied(`dependencies of package cool/config:v1.1.0 cannot be satisfied: dependency package is not valid: dependency crossplane/PROVIDER-GCP with constraints ">=v1.0.0": could not parse reference: crossplane/PROVIDER-GCP`),
+		},
+		"FailOpenFetchTags": {
+			reason: "We should admit a package with a warning if we can't fetch the tags of its dependencies.",
+			args: args{
+				client:  test.NewMockClient(),
+				lister:  depends(v1beta1.Dependency{Package: "crossplane/provider-gcp", Type: v1beta1.ProviderPackageType, Constraints: ">=v1.0.0"}),
+				fetcher: &fakexpkg.MockFetcher{MockTags: fakexpkg.NewMockTagsFn(nil, errBoom)},
+				mode:    ModeReject,
+				req:     create("cool/config:v1.1.0"),
+			},
+			want: admission.Allowed("").WithWarnings(`cannot verify that dependencies of package cool/config:v1.1.0 can be satisfied: cannot fetch dependency package tags: dependency crossplane/provider-gcp with constraints ">=v1.0.0": boom`),
+		},
+		"FailOpenListDependencies": {
+			reason: "We should admit a package with a warning if we can't list its dependencies.",
+			args: args{
+				client: test.NewMockClient(),
+				lister: DependencyListerFn(func(_ context.Context, _ v1.Package) ([]v1beta1.Dependency, error) {
+					return nil, errBoom
+				}),
+				mode: ModeReject,
+				req:  create("cool/config:v1.1.0"),
+			},
+			want: admission.Allowed("").WithWarnings(`cannot verify that dependencies of package cool/config:v1.1.0 can be satisfied: cannot list package dependencies: boom`),
+		},
+		"FailOpenGetLock": {
+			reason: "We should admit a package with a warning if we can't get the Lock.",
+			args: args{
+				client:  &test.MockClient{MockGet: test.NewMockGetFn(errBoom)},
+				lister:  depends(v1beta1.Dependency{Package: "crossplane/provider-gcp", Type: v1beta1.ProviderPackageType, Constraints: ">=v1.0.0"}),
+				fetcher: tags(),
+				mode:    ModeReject,
+				req:     create("cool/config:v1.1.0"),
+			},
+			want: admission.Allowed("").WithWarnings(`cannot verify that dependencies of package cool/config:v1.1.0 can be satisfied: cannot get package Lock: boom`),
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			v := NewValidator(tc.args.client,
+				WithDependencyLister(tc.args.lister),
+				WithFetcher(tc.args.fetcher),
+				WithMode(tc.args.mode),
+			)
+			got := v.Handle(context.Background(), tc.args.req)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nv.Handle(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestHandleNoLock(t *testing.T) {
+	// A missing Lock means nothing is installed yet.
+	c := &test.MockClient{MockGet: test.NewMockGetFn(kerrors.NewNotFound(schema.GroupResource{}, lockName))}
+	v := NewValidator(c,
+		WithDependencyLister(DependencyListerFn(func(_ context.Context, _ v1.Package) ([]v1beta1.Dependency, error) {
+			return []v1beta1.Dependency{{Package: "crossplane/provider-gcp", Type: v1beta1.ProviderPackageType, Constraints: ">=v0.18.0"}}, nil
+		})),
+		WithFetcher(&fakexpkg.MockFetcher{MockTags: fakexpkg.NewMockTagsFn([]string{"v0.18.0"}, nil)}),
+		WithMode(ModeReject),
+	)
+	req := admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+		Operation: admissionv1.Create,
+		Kind:      metav1.GroupVersionKind{Group: v1.Group, Version: v1.Version, Kind: v1.ProviderKind},
+		Object:    runtime.RawExtension{Raw: []byte(`{"spec":{"package":"cool/provider:v1.0.0"}}`)},
+	}}
+	if diff := cmp.Diff(admission.Allowed(""), v.Handle(context.Background(), req)); diff != "" {
+		t.Errorf("v.Handle(...): -want, +got:\n%s", diff)
+	}
+}
@@ -0,0 +1,260 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package lock validates that changes to the package Lock keep its packages
+// resolvable.
+package lock
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	admissionv1 "k8s.io/api/admission/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+	"github.com/crossplane/crossplane/internal/dag"
+)
+
+const (
+	// Path at which the validating webhook is served.
+	Path = "/validate-lock"
+)
+
+const (
+	errDecode = "cannot decode package Lock"
+
+	msgInvalidFmt     = "package Lock change rejected: %s"
+	msgParseSourceFmt = "package %s (%s) has an unparseable source: %s"
+	msgParseDepFmt    = "package %s (%s) has a dependency with an unparseable package %q: %s"
+	msgDuplicateFmt   = "packages %s have the same source %s"
+	msgCycleFmt       = "packages would form a dependency cycle: %s"
+	msgBuildGraphFmt  = "cannot build dependency graph of packages: %s"
+)
+
+// Problems are keyed by what they're about, e.g. the source that can't be
+// parsed, so that the problems of two versions of the Lock can be compared.
+const (
+	keySourceFmt     = "source/%s"
+	keyDependencyFmt = "dependency/%s/%s"
+	keyDuplicateFmt  = "duplicate/%s"
+	keyCycleFmt      = "cycle/%s"
+	keyBuildGraph    = "graph"
+)
+
+// A ValidatorOption configures a Validator.
+type ValidatorOption func(*Validator)
+
+// WithLogger specifies how the Validator should log messages.
+func WithLogger(l logging.Logger) ValidatorOption {
+	return func(v *Validator) {
+		v.log = l
+	}
+}
+
+// WithNewDagFn specifies how the Validator should build a dependency graph of
+// the Lock's packages.
+func WithNewDagFn(fn dag.NewDAGFn) ValidatorOption {
+	return func(v *Validator) {
+		v.newDag = fn
+	}
+}
+
+// A Validator rejects changes to the package Lock that would prevent its
+// dependencies from being resolved: packages whose source or dependencies
+// can't be parsed, packages from the same repository, and dependency cycles.
+// Only problems a change introduces are rejected, so that a Lock that already
+// has a problem can still be changed, for example to fix it or to uninstall a
+// package. Deleting the Lock is always allowed.
+type Validator struct {
+	log    logging.Logger
+	newDag dag.NewDAGFn
+}
+
+// NewValidator returns a Validator of package Lock changes.
+func NewValidator(opts ...ValidatorOption) *Validator {
+	v := &Validator{
+		log:    logging.NewNopLogger(),
+		newDag: dag.NewMapDag,
+	}
+	for _, o := range opts {
+		o(v)
+	}
+	return v
+}
+
+// Setup registers a Validator for the package Lock with the supplied
+// manager's webhook server.
+func Setup(mgr ctrl.Manager, l logging.Logger) error {
+	v := NewValidator(WithLogger(l.WithValues("webhook", Path)))
+	mgr.GetWebhookServer().Register(Path, &webhook.Admission{Handler: v})
+	return nil
+}
+
+// Handle an admission request for the package Lock.
+func (v *Validator) Handle(_ context.Context, req admission.Request) admission.Response {
+	if req.Operation == admissionv1.Delete {
+		return admission.Allowed("")
+	}
+
+	l := &v1beta1.Lock{}
+	if err := json.Unmarshal(req.Object.Raw, l); err != nil {
+		return admission.Errored(http.StatusBadRequest, errors.Wrap(err, errDecode))
+	}
+	if len(l.Packages) == 0 {
+		return admission.Allowed("")
+	}
+
+	existing := map[string]string{}
+	if req.Operation == admissionv1.Update {
+		old := &v1beta1.Lock{}
+		if err := json.Unmarshal(req.OldObject.Raw, old); err == nil {
+			existing = v.problems(old.Packages)
+		}
+	}
+
+	introduced := []string{}
+	for k, msg := range v.problems(l.Packages) {
+		if _, ok := existing[k]; !ok {
+			introduced = append(introduced, msg)
+		}
+	}
+	if len(introduced) == 0 {
+		return admission.Allowed("")
+	}
+	sort.Strings(introduced)
+
+	v.log.Debug("Rejecting package Lock change", "name", l.GetName(), "problems", introduced)
+	return admission.Denied(fmt.Sprintf(msgInvalidFmt, strings.Join(introduced, "; ")))
+}
+
+// problems returns why the supplied packages can't be resolved, keyed by what
+// each problem is about.
+func (v *Validator) problems(pkgs []v1beta1.LockPackage) map[string]string {
+	out := map[string]string{}
+
+	// A package whose source or dependencies can't be parsed isn't added to
+	// the graph, so that we can still look for other problems.
+	valid := make([]v1beta1.LockPackage, 0, len(pkgs))
+	for _, p := range pkgs {
+		if _, err := name.ParseReference(p.Source, name.WithDefaultRegistry("")); err != nil {
+			out[fmt.Sprintf(keySourceFmt, p.Source)] = fmt.Sprintf(msgParseSourceFmt, p.Source, p.Name, err)
+			continue
+		}
+		ok := true
+		for _, d := range p.Dependencies {
+			if _, err := name.ParseReference(d.Package, name.WithDefaultRegistry("")); err != nil {
+				out[fmt.Sprintf(keyDependencyFmt, p.Identifier(), d.Package)] = fmt.Sprintf(msgParseDepFmt, p.Source, p.Name, d.Package, err)
+				ok = false
+			}
+		}
+		if ok {
+			valid = append(valid, p)
+		}
+	}
+
+	// Only the first package from each repository is added to the graph.
+	byID := map[string][]v1beta1.LockPackage{}
+	unique := make([]v1beta1.LockPackage, 0, len(valid))
+	for _, p := range valid {
+		id := p.Identifier()
+		if len(byID[id]) == 0 {
+			unique = append(unique, p)
+		}
+		byID[id] = append(byID[id], p)
+	}
+	for id, dups := range byID {
+		if len(dups) < 2 {
+			continue
+		}
+		described := make([]string, len(dups))
+		for i, p := range dups {
+			described[i] = fmt.Sprintf("%s (%s)", p.Source, p.Name)
+		}
+		out[fmt.Sprintf(keyDuplicateFmt, id)] = fmt.Sprintf(msgDuplicateFmt, strings.Join(described, ", "), id)
+	}
+
+	// Sorting reports one cycle at a time. We break each cycle we find and
+	// sort again, so that a cycle a change introduces is reported even if
+	// the Lock already has another.
+	for {
+		d := v.newDag()
+		if _, err := d.Init(v1beta1.ToNodes(unique...)); err != nil {
+			out[keyBuildGraph] = fmt.Sprintf(msgBuildGraphFmt, err)
+			return out
+		}
+		_, err := d.Sort()
+		cerr := &dag.CyclicError{}
+		if !errors.As(err, &cerr) {
+			return out
+		}
+		out[fmt.Sprintf(keyCycleFmt, canonicalCycle(cerr.Cycle))] = fmt.Sprintf(msgCycleFmt, cerr.Path())
+		if !breakCycle(unique, cerr.Cycle) {
+			return out
+		}
+	}
+}
+
+// canonicalCycle returns the sorted identifiers of the packages in the
+// supplied cycle, which are the same wherever the cycle was entered.
+func canonicalCycle(cycle []string) string {
+	ids := map[string]bool{}
+	for _, id := range cycle {
+		ids[id] = true
+	}
+	sorted := make([]string, 0, len(ids))
+	for id := range ids {
+		sorted = append(sorted, id)
+	}
+	sort.Strings(sorted)
+	return strings.Join(sorted, ",")
+}
+
+// breakCycle removes the dependency that closes the supplied cycle from the
+// supplied packages. It returns false if no package has that dependency.
+func breakCycle(pkgs []v1beta1.LockPackage, cycle []string) bool {
+	if len(cycle) < 2 {
+		return false
+	}
+	from, to := cycle[len(cycle)-2], cycle[len(cycle)-1]
+	for i := range pkgs {
+		p := &pkgs[i]
+		if p.Identifier() != from {
+			continue
+		}
+		deps := make([]v1beta1.Dependency, 0, len(p.Dependencies))
+		for j := range p.Dependencies {
+			if p.Dependencies[j].Identifier() != to {
+				deps = append(deps, p.Dependencies[j])
+			}
+		}
+		if len(deps) < len(p.Dependencies) {
+			p.Dependencies = deps
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,135 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lock
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+)
+
+func TestHandle(t *testing.T) {
+	dep := func(pkg string) v1beta1.Dependency {
+		return v1beta1.Dependency{Package: pkg, Type: v1beta1.ConfigurationPackageType, Constraints: ">=v1.0.0"}
+	}
+	pkg := func(name, source string, deps ...v1beta1.Dependency) v1beta1.LockPackage {
+		return v1beta1.LockPackage{Name: name, Type: v1beta1.ConfigurationPackageType, Source: source, Version: "v1.0.0", Dependencies: deps}
+	}
+	a := pkg("config-a-1234", "cool/config-a", dep("cool/config-b"))
+	b := pkg("config-b-1234", "cool/config-b", dep("cool/config-c"))
+	c := pkg("config-c-1234", "cool/config-c")
+	cyclic := pkg("config-c-1234", "cool/config-c", dep("cool/config-a"))
+	x := pkg("config-x-1234", "cool/config-x", dep("cool/config-y"))
+	y := pkg("config-y-1234", "cool/config-y", dep("cool/config-x"))
+
+	raw := func(pkgs ...v1beta1.LockPackage) runtime.RawExtension {
+		l := &v1beta1.Lock{Packages: pkgs}
+		l.SetName("lock")
+		b, _ := json.Marshal(l)
+		return runtime.RawExtension{Raw: b}
+	}
+	request := func(op admissionv1.Operation, old, obj runtime.RawExtension) admission.Request {
+		return admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+			Operation: op,
+			Kind:      metav1.GroupVersionKind{Group: v1beta1.Group, Version: v1beta1.Version, Kind: v1beta1.LockKind},
+			Object:    obj,
+			OldObject: old,
+		}}
+	}
+
+	cases := map[string]struct {
+		reason string
+		req    admission.Request
+		want   admission.Response
+	}{
+		"Valid": {
+			reason: "We should admit a change that leaves the Lock's packages resolvable.",
+			req:    request(admissionv1.Update, raw(a, b), raw(a, b, c)),
+			want:   admission.Allowed(""),
+		},
+		"Created": {
+			reason: "We should admit a new Lock whose packages are resolvable.",
+			req:    request(admissionv1.Create, runtime.RawExtension{}, raw(a, b, c)),
+			want:   admission.Allowed(""),
+		},
+		"Empty": {
+			reason: "We should always admit a Lock with no packages.",
+			req:    request(admissionv1.Update, raw(a, b, cyclic), raw()),
+			want:   admission.Allowed(""),
+		},
+		"Delete": {
+			reason: "We should always admit deleting the Lock.",
+			req:    request(admissionv1.Delete, raw(a, b, cyclic), runtime.RawExtension{}),
+			want:   admission.Allowed(""),
+		},
+		"Undecodable": {
+			reason: "We should return an error if we can't decode the Lock.",
+			req:    request(admissionv1.Update, raw(), runtime.RawExtension{Raw: []byte("{")}),
+			want:   admission.Errored(http.StatusBadRequest, errors.Wrap(json.Unmarshal([]byte("{"), &v1beta1.Lock{}), errDecode)),
+		},
+		"IntroducesCycle": {
+			reason: "We should reject a change that introduces a dependency cycle, naming the packages in it.",
+			req:    request(admissionv1.Update, raw(a, b, c), raw(a, b, cyclic)),
+			want:   admission.Denied("package Lock change rejected: packages would form a dependency cycle: index.docker.io/cool/config-a -> index.docker.io/cool/config-b -> index.docker.io/cool/config-c -> index.docker.io/cool/config-a"),
+		},
+		"ExistingCycle": {
+			reason: "We should admit a change to a Lock that already has a dependency cycle, so that it can be fixed or packages uninstalled.",
+			req:    request(admissionv1.Update, raw(a, b, cyclic), raw(b, cyclic, a)),
+			want:   admission.Allowed(""),
+		},
+		"IntroducesAnotherCycle": {
+			reason: "We should reject a change that introduces a dependency cycle to a Lock that already has another.",
+			req:    request(admissionv1.Update, raw(a, b, cyclic), raw(a, b, cyclic, x, y)),
+			want:   admission.Denied("package Lock change rejected: packages would form a dependency cycle: index.docker.io/cool/config-x -> index.docker.io/cool/config-y -> index.docker.io/cool/config-x"),
+		},
+		"IntroducesDuplicate": {
+			reason: "We should reject a change that adds a package from a repository another package in the Lock is from.",
+			req:    request(admissionv1.Update, raw(a, b, c), raw(a, b, c, pkg("config-c-5678", "index.docker.io/cool/config-c"))),
+			want:   admission.Denied("package Lock change rejected: packages cool/config-c (config-c-1234), index.docker.io/cool/config-c (config-c-5678) have the same source index.docker.io/cool/config-c"),
+		},
+		"IntroducesUnparseableSource": {
+			reason: "We should reject a change that adds a package whose source can't be parsed.",
+			req:    request(admissionv1.Update, raw(a, b, c), raw(a, b, c, pkg("bad-1234", "cool/BAD!"))),
+			want:   admission.Denied(`package Lock change rejected: package cool/BAD! (bad-1234) has an unparseable source: could not parse reference: cool/BAD!`),
+		},
+		"IntroducesUnparseableDependency": {
+			reason: "We should reject a change that adds a package with a dependency whose package can't be parsed.",
+			req:    request(admissionv1.Update, raw(a, b), raw(a, b, pkg("config-c-1234", "cool/config-c", dep("cool/BAD!")))),
+			want:   admission.Denied(`package Lock change rejected: package cool/config-c (config-c-1234) has a dependency with an unparseable package "cool/BAD!": could not parse reference: cool/BAD!`),
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := NewValidator().Handle(context.Background(), tc.req)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nv.Handle(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
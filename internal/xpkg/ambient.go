@@ -0,0 +1,114 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package xpkg
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/authn/k8schain"
+	"github.com/google/go-containerregistry/pkg/name"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+)
+
+const (
+	// ambientCredentialTTL is how long credentials obtained from the cloud
+	// credential providers are cached. The providers cache the tokens they
+	// obtain until shortly before they expire, so asking them again is cheap.
+	ambientCredentialTTL = 10 * time.Minute
+
+	errAmbientKeychain = "cannot build cloud credential provider keychain"
+	errAmbientResolve  = "cannot resolve cloud provider credentials"
+)
+
+var ecrHost = regexp.MustCompile(`^\d{12}\.dkr\.ecr(-fips)?\.([a-z0-9-]+)\.amazonaws\.com(\.cn)?$`)
+
+// A KeyringHelper obtains credentials for the registries of a cloud provider
+// from the credential providers Kubernetes uses to pull images from them. The
+// providers are registered by k8schain, and use the ambient credentials of
+// Crossplane's environment, e.g. those of IAM Roles for Service Accounts on
+// EKS or Workload Identity on GKE.
+type KeyringHelper struct {
+	name     string
+	matches  func(host string) bool
+	keychain func(ctx context.Context) (authn.Keychain, error)
+	now      func() time.Time
+}
+
+func newKeyringHelper(name string, matches func(host string) bool) *KeyringHelper {
+	return &KeyringHelper{name: name, matches: matches, keychain: k8schain.NewNoClient, now: time.Now}
+}
+
+// NewECRHelper returns a CredentialHelper for Amazon ECR registries.
+func NewECRHelper() *KeyringHelper {
+	return newKeyringHelper("ECR", ecrHost.MatchString)
+}
+
+// NewGARHelper returns a CredentialHelper for Google Artifact Registry and
+// Container Registry registries.
+func NewGARHelper() *KeyringHelper {
+	return newKeyringHelper("GAR", func(host string) bool {
+		return host == "gcr.io" || strings.HasSuffix(host, ".gcr.io") || strings.HasSuffix(host, "-docker.pkg.dev")
+	})
+}
+
+// NewACRHelper returns a CredentialHelper for Azure Container Registry
+// registries.
+func NewACRHelper() *KeyringHelper {
+	return newKeyringHelper("ACR", func(host string) bool {
+		return strings.HasSuffix(host, ".azurecr.io") || strings.HasSuffix(host, ".azurecr.cn") || strings.HasSuffix(host, ".azurecr.us")
+	})
+}
+
+// Name of the helper.
+func (h *KeyringHelper) Name() string { return h.name }
+
+// Matches returns true if the supplied host is a registry of the helper's
+// cloud provider. The credential providers are only consulted about matching
+// hosts, because they may block while they look for credentials.
+func (h *KeyringHelper) Matches(host string) bool { return h.matches(host) }
+
+// Get credentials for the supplied registry.
+func (h *KeyringHelper) Get(ctx context.Context, host string) (*Credential, error) {
+	reg, err := name.NewRegistry(host)
+	if err != nil {
+		return nil, errors.Wrap(err, errAmbientResolve)
+	}
+	kc, err := h.keychain(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, errAmbientKeychain)
+	}
+	auth, err := kc.Resolve(reg)
+	if err != nil {
+		return nil, errors.Wrap(err, errAmbientResolve)
+	}
+	if auth == authn.Anonymous {
+		return nil, nil
+	}
+	cfg, err := auth.Authorization()
+	if err != nil {
+		return nil, errors.Wrap(err, errAmbientResolve)
+	}
+	if cfg.Username == "" && cfg.Password == "" {
+		return nil, nil
+	}
+	return &Credential{Username: cfg.Username, Password: cfg.Password, Expiry: h.now().Add(ambientCredentialTTL)}, nil
+}
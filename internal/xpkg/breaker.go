@@ -0,0 +1,209 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package xpkg
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+)
+
+const errRegistryUnavailableFmt = "registry %s is unavailable, retrying at %s"
+
+// A BreakerState is the state of the circuit breaker of a registry host.
+type BreakerState string
+
+// Circuit breaker states.
+const (
+	// BreakerClosed breakers allow fetches to their host.
+	BreakerClosed BreakerState = "Closed"
+
+	// BreakerOpen breakers skip fetches to their host until their cooldown
+	// period has passed.
+	BreakerOpen BreakerState = "Open"
+
+	// BreakerHalfOpen breakers allow a single trial fetch to their host
+	// once their cooldown period has passed. The breaker closes if the
+	// trial succeeds, and opens again if it fails.
+	BreakerHalfOpen BreakerState = "HalfOpen"
+)
+
+// A RegistryUnavailableError indicates tags were not fetched from a registry
+// because too many recent fetches from it failed.
+type RegistryUnavailableError struct {
+	Registry string
+	RetryAt  time.Time
+}
+
+func (e *RegistryUnavailableError) Error() string {
+	return errors.Errorf(errRegistryUnavailableFmt, e.Registry, e.RetryAt.UTC().Format(time.RFC3339)).Error()
+}
+
+// IsRegistryUnavailable returns true if the supplied error indicates tags
+// were not fetched from a registry because too many recent fetches from it
+// failed.
+func IsRegistryUnavailable(err error) bool {
+	var uerr *RegistryUnavailableError
+	return errors.As(err, &uerr)
+}
+
+type breaker struct {
+	failures int
+	open     bool
+	retryAt  time.Time
+	probing  bool
+}
+
+// A CircuitBreakingFetcher stops fetching tags from a registry host for a
+// cooldown period after a number of consecutive fetches from it fail, so that
+// a registry that is down isn't asked for the tags of every repository it
+// hosts each time dependencies are resolved. Its state is kept in memory, and
+// it is safe for concurrent use. Images are always fetched.
+type CircuitBreakingFetcher struct {
+	Fetcher
+
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+
+	mu    sync.Mutex
+	hosts map[string]*breaker
+}
+
+// NewCircuitBreakingFetcher returns a Fetcher that skips fetching tags from a
+// registry host for the supplied cooldown period after the supplied number of
+// consecutive fetches from it fail. A threshold of zero or less disables the
+// circuit breaker.
+func NewCircuitBreakingFetcher(f Fetcher, threshold int, cooldown time.Duration) *CircuitBreakingFetcher {
+	return &CircuitBreakingFetcher{
+		Fetcher:   f,
+		threshold: threshold,
+		cooldown:  cooldown,
+		now:       time.Now,
+		hosts:     map[string]*breaker{},
+	}
+}
+
+// Tags fetches the tags of the supplied reference's repository, unless the
+// circuit breaker of its registry host is open.
+func (f *CircuitBreakingFetcher) Tags(ctx context.Context, ref name.Reference, secrets ...string) ([]string, error) {
+	host := ref.Context().RegistryStr()
+	if err := f.allow(host); err != nil {
+		return nil, err
+	}
+	tags, err := f.Fetcher.Tags(ctx, ref, secrets...)
+	f.record(host, err)
+	return tags, err
+}
+
+// TagsPaged fetches the tags of the supplied reference's repository a page at
+// a time, unless the circuit breaker of its registry host is open.
+func (f *CircuitBreakingFetcher) TagsPaged(ctx context.Context, ref name.Reference, fn TagPageFn, secrets ...string) error {
+	host := ref.Context().RegistryStr()
+	if err := f.allow(host); err != nil {
+		return err
+	}
+	err := PagedTags(ctx, f.Fetcher, ref, fn, secrets...)
+	f.record(host, err)
+	return err
+}
+
+// State returns the state of the circuit breaker of the supplied registry
+// host.
+func (f *CircuitBreakingFetcher) State(host string) BreakerState {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	b, ok := f.hosts[host]
+	switch {
+	case !ok || !b.open:
+		return BreakerClosed
+	case b.probing || !f.now().Before(b.retryAt):
+		return BreakerHalfOpen
+	}
+	return BreakerOpen
+}
+
+// allow returns an error if tags should not be fetched from the supplied
+// host. Once an open breaker's cooldown has passed a single caller is allowed
+// to try the host.
+func (f *CircuitBreakingFetcher) allow(host string) error {
+	if f.threshold <= 0 {
+		return nil
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	b, ok := f.hosts[host]
+	if !ok || !b.open {
+		return nil
+	}
+	if b.probing || f.now().Before(b.retryAt) {
+		return &RegistryUnavailableError{Registry: host, RetryAt: b.retryAt}
+	}
+	b.probing = true
+	return nil
+}
+
+// record the outcome of fetching tags from the supplied host. The breaker is
+// reset by the first success, and opened by the threshold'th consecutive
+// failure, or by the failure of a trial fetch.
+func (f *CircuitBreakingFetcher) record(host string, err error) {
+	if f.threshold <= 0 {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	b, ok := f.hosts[host]
+	if !ok {
+		b = &breaker{}
+		f.hosts[host] = b
+	}
+	switch {
+	case errors.Is(err, context.Canceled):
+		// The caller gave up, which says nothing about the host.
+		b.probing = false
+		return
+	case !unavailable(err):
+		*b = breaker{}
+		return
+	}
+	b.failures++
+	if b.probing || b.failures >= f.threshold {
+		b.open = true
+		b.retryAt = f.now().Add(f.cooldown)
+	}
+	b.probing = false
+}
+
+// unavailable returns true if the supplied error indicates a registry host is
+// unavailable. A registry that reports a repository doesn't exist or that we
+// aren't authorized to read it is available.
+func unavailable(err error) bool {
+	if err == nil {
+		return false
+	}
+	var terr *transport.Error
+	if errors.As(err, &terr) {
+		return terr.StatusCode >= http.StatusInternalServerError || terr.StatusCode == http.StatusTooManyRequests
+	}
+	return true
+}
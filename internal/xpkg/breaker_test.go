@@ -0,0 +1,226 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package xpkg
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+)
+
+// A scriptedFetcher returns the next of its scripted errors each time tags
+// are fetched, and calls during, if set, while fetching.
+type scriptedFetcher struct {
+	NopFetcher
+	script []error
+	calls  int
+	during func()
+}
+
+func (f *scriptedFetcher) Tags(_ context.Context, _ name.Reference, _ ...string) ([]string, error) {
+	if f.during != nil {
+		f.during()
+	}
+	err := f.script[f.calls]
+	f.calls++
+	if err != nil {
+		return nil, err
+	}
+	return []string{"v0.1.0"}, nil
+}
+
+func mustParseReference(t *testing.T, s string) name.Reference {
+	t.Helper()
+	ref, err := name.ParseReference(s)
+	if err != nil {
+		t.Fatalf("name.ParseReference(%q): %s", s, err)
+	}
+	return ref
+}
+
+func TestCircuitBreakingFetcher(t *testing.T) {
+	errBoom := errors.New("boom")
+	errNotFound := &transport.Error{StatusCode: http.StatusNotFound}
+	errTooMany := &transport.Error{StatusCode: http.StatusTooManyRequests}
+	errUnavailable := &transport.Error{StatusCode: http.StatusServiceUnavailable}
+
+	host := "registry.example.org"
+	cooldown := time.Minute
+
+	// A step advances the clock, then fetches tags unless it only checks
+	// the state of the breaker. Skipped fetches don't consume the script.
+	type step struct {
+		after   time.Duration
+		check   bool
+		result  error
+		skipped bool
+		state   BreakerState
+	}
+
+	cases := map[string]struct {
+		reason    string
+		threshold int
+		steps     []step
+	}{
+		"ClosedToOpen": {
+			reason:    "The breaker should open once the threshold of consecutive failures is reached, and skip fetches while open.",
+			threshold: 2,
+			steps: []step{
+				{result: errBoom, state: BreakerClosed},
+				{result: errUnavailable, state: BreakerOpen},
+				{skipped: true, state: BreakerOpen},
+				{after: cooldown - time.Second, skipped: true, state: BreakerOpen},
+			},
+		},
+		"ResetOnSuccess": {
+			reason:    "The first success should reset the count of consecutive failures.",
+			threshold: 2,
+			steps: []step{
+				{result: errBoom, state: BreakerClosed},
+				{result: nil, state: BreakerClosed},
+				{result: errBoom, state: BreakerClosed},
+				{result: errBoom, state: BreakerOpen},
+			},
+		},
+		"HalfOpenToClosed": {
+			reason:    "Once the cooldown has passed the breaker should allow a trial fetch, and close if it succeeds.",
+			threshold: 1,
+			steps: []step{
+				{result: errBoom, state: BreakerOpen},
+				{after: cooldown, check: true, state: BreakerHalfOpen},
+				{result: nil, state: BreakerClosed},
+				{result: nil, state: BreakerClosed},
+			},
+		},
+		"HalfOpenToOpen": {
+			reason:    "Once the cooldown has passed the breaker should allow a trial fetch, and open again for another cooldown if it fails.",
+			threshold: 2,
+			steps: []step{
+				{result: errBoom, state: BreakerClosed},
+				{result: errBoom, state: BreakerOpen},
+				{after: cooldown, result: errBoom, state: BreakerOpen},
+				{after: cooldown - time.Second, skipped: true, state: BreakerOpen},
+				{after: time.Second, result: nil, state: BreakerClosed},
+			},
+		},
+		"AvailableErrors": {
+			reason:    "Errors that indicate the registry is available, such as an unknown repository, should not open the breaker.",
+			threshold: 1,
+			steps: []step{
+				{result: errNotFound, state: BreakerClosed},
+				{result: context.Canceled, state: BreakerClosed},
+				{result: errTooMany, state: BreakerOpen},
+			},
+		},
+		"Disabled": {
+			reason:    "A threshold of zero should disable the breaker.",
+			threshold: 0,
+			steps: []step{
+				{result: errBoom, state: BreakerClosed},
+				{result: errBoom, state: BreakerClosed},
+				{result: errBoom, state: BreakerClosed},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			script := []error{}
+			for _, s := range tc.steps {
+				if !s.check && !s.skipped {
+					script = append(script, s.result)
+				}
+			}
+			sf := &scriptedFetcher{script: script}
+			now := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+			f := NewCircuitBreakingFetcher(sf, tc.threshold, cooldown)
+			f.now = func() time.Time { return now }
+			ref := mustParseReference(t, host+"/crossplane/provider-aws")
+
+			for i, s := range tc.steps {
+				now = now.Add(s.after)
+				if !s.check {
+					calls := sf.calls
+					_, err := f.Tags(context.Background(), ref)
+					switch {
+					case s.skipped && !IsRegistryUnavailable(err):
+						t.Errorf("\n%s\nstep %d: f.Tags(...): want registry unavailable error, got %v", tc.reason, i, err)
+					case s.skipped && sf.calls != calls:
+						t.Errorf("\n%s\nstep %d: f.Tags(...): fetched tags from an unavailable registry", tc.reason, i)
+					case !s.skipped:
+						if diff := cmp.Diff(s.result, err, cmpopts.EquateErrors()); diff != "" {
+							t.Errorf("\n%s\nstep %d: f.Tags(...): -want error, +got error:\n%s", tc.reason, i, diff)
+						}
+					}
+				}
+				if diff := cmp.Diff(s.state, f.State(host)); diff != "" {
+					t.Errorf("\n%s\nstep %d: f.State(...): -want, +got:\n%s", tc.reason, i, diff)
+				}
+			}
+		})
+	}
+}
+
+func TestCircuitBreakingFetcherTrial(t *testing.T) {
+	now := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	sf := &scriptedFetcher{script: []error{errors.New("boom"), nil}}
+	f := NewCircuitBreakingFetcher(sf, 1, time.Minute)
+	f.now = func() time.Time { return now }
+	aws := mustParseReference(t, "registry.example.org/crossplane/provider-aws")
+	gcp := mustParseReference(t, "registry.example.org/crossplane/provider-gcp")
+
+	_, _ = f.Tags(context.Background(), aws)
+	now = now.Add(time.Minute)
+
+	// Only one caller may try the registry once its cooldown has passed.
+	var trialErr error
+	sf.during = func() {
+		sf.during = nil
+		_, trialErr = f.Tags(context.Background(), gcp)
+	}
+	if _, err := f.Tags(context.Background(), aws); err != nil {
+		t.Errorf("f.Tags(...): trial fetch: %s", err)
+	}
+	want := &RegistryUnavailableError{Registry: "registry.example.org", RetryAt: now}
+	if diff := cmp.Diff(want.Error(), errors.Cause(trialErr).Error()); diff != "" {
+		t.Errorf("f.Tags(...): concurrent fetch during trial: -want, +got:\n%s", diff)
+	}
+	if diff := cmp.Diff("registry registry.example.org is unavailable, retrying at 2021-06-01T00:01:00Z", want.Error()); diff != "" {
+		t.Errorf("RegistryUnavailableError.Error(): -want, +got:\n%s", diff)
+	}
+}
+
+func TestCircuitBreakingFetcherHosts(t *testing.T) {
+	sf := &scriptedFetcher{script: []error{errors.New("boom"), nil}}
+	f := NewCircuitBreakingFetcher(sf, 1, time.Minute)
+
+	_, _ = f.Tags(context.Background(), mustParseReference(t, "registry.example.org/crossplane/provider-aws"))
+	if _, err := f.Tags(context.Background(), mustParseReference(t, "xpkg.example.org/crossplane/provider-aws")); err != nil {
+		t.Errorf("f.Tags(...): a breaker should only affect its own registry host: %s", err)
+	}
+	if diff := cmp.Diff(BreakerOpen, f.State("registry.example.org")); diff != "" {
+		t.Errorf("f.State(...): -want, +got:\n%s", diff)
+	}
+}
@@ -0,0 +1,50 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package canonical canonicalizes package sources, so that sources that refer
+// to the same OCI repository can be compared. It is separate from package xpkg
+// so that the package APIs, which xpkg depends on, can use it.
+package canonical
+
+import (
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+// Source returns the canonical form of the supplied package source, i.e. the
+// fully qualified repository it refers to. Sources that don't specify a
+// registry refer to Docker Hub, and Docker Hub repositories that don't
+// specify a namespace are in its library namespace. Registry hosts are
+// lowercased, and any tag or digest is stripped. Every alias of Docker Hub
+// has the canonical registry index.docker.io, so for example docker.io/nginx,
+// library/nginx, and nginx:latest all have the canonical source
+// index.docker.io/library/nginx. Sources that cannot be parsed are returned
+// unchanged.
+func Source(s string) string {
+	ref, err := name.ParseReference(s)
+	if err != nil {
+		return s
+	}
+	repo := ref.Context()
+	return strings.ToLower(repo.RegistryStr()) + "/" + repo.RepositoryStr()
+}
+
+// Equal returns true if the supplied package sources refer to the same
+// repository.
+func Equal(a, b string) bool {
+	return Source(a) == Source(b)
+}
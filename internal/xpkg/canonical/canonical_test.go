@@ -0,0 +1,107 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package canonical
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSource(t *testing.T) {
+	digest := "sha256:ecc25c121431dfc7058754427f97c034ecde26d4aafa0da16d26e9ec4b1c2f4f"
+
+	cases := map[string]struct {
+		reason string
+		source string
+		want   string
+	}{
+		"Implicit": {
+			reason: "A source without a registry should refer to Docker Hub.",
+			source: "crossplane/provider-aws",
+			want:   "index.docker.io/crossplane/provider-aws",
+		},
+		"DockerIO": {
+			reason: "The docker.io alias should refer to Docker Hub.",
+			source: "docker.io/crossplane/provider-aws",
+			want:   "index.docker.io/crossplane/provider-aws",
+		},
+		"IndexDockerIO": {
+			reason: "A source in Docker Hub's canonical registry should be unchanged.",
+			source: "index.docker.io/crossplane/provider-aws",
+			want:   "index.docker.io/crossplane/provider-aws",
+		},
+		"ImplicitLibrary": {
+			reason: "A Docker Hub source without a namespace should be in the library namespace.",
+			source: "provider-aws",
+			want:   "index.docker.io/library/provider-aws",
+		},
+		"DockerIOImplicitLibrary": {
+			reason: "A docker.io source without a namespace should be in the library namespace.",
+			source: "docker.io/provider-aws",
+			want:   "index.docker.io/library/provider-aws",
+		},
+		"ExplicitLibrary": {
+			reason: "A source in the library namespace should be equal to one with an implicit library namespace.",
+			source: "library/provider-aws",
+			want:   "index.docker.io/library/provider-aws",
+		},
+		"OtherRegistryNamespace": {
+			reason: "A source in another registry should not have the library namespace added.",
+			source: "registry.example.org/provider-aws",
+			want:   "registry.example.org/provider-aws",
+		},
+		"HostWithPort": {
+			reason: "A registry host's port should be preserved.",
+			source: "registry.example.org:5000/crossplane/provider-aws:v0.20.0",
+			want:   "registry.example.org:5000/crossplane/provider-aws",
+		},
+		"Localhost": {
+			reason: "A localhost registry should be preserved.",
+			source: "localhost:5000/crossplane/provider-aws",
+			want:   "localhost:5000/crossplane/provider-aws",
+		},
+		"UppercaseHost": {
+			reason: "A registry host should be lowercased.",
+			source: "Registry.Example.org/crossplane/provider-aws",
+			want:   "registry.example.org/crossplane/provider-aws",
+		},
+		"Tag": {
+			reason: "A source's tag should be stripped.",
+			source: "crossplane/provider-aws:v0.20.0",
+			want:   "index.docker.io/crossplane/provider-aws",
+		},
+		"Digest": {
+			reason: "A digest pinned source's digest should be stripped.",
+			source: "docker.io/crossplane/provider-aws@" + digest,
+			want:   "index.docker.io/crossplane/provider-aws",
+		},
+		"Invalid": {
+			reason: "A source that cannot be parsed should be unchanged.",
+			source: "crossplane/Provider-AWS",
+			want:   "crossplane/Provider-AWS",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if diff := cmp.Diff(tc.want, Source(tc.source)); diff != "" {
+				t.Errorf("\n%s\nSource(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,210 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package xpkg
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/semver"
+
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+)
+
+const (
+	errVersionConflictFmt = "installed version %s of package %s%s does not satisfy %s"
+	errTypeConflictFmt    = "dependency %s declared as %s by %s but installed package is a %s"
+	errUnverifiableFmt    = "cannot verify that package %s%s installed at digest %s satisfies %s"
+	requiredByFmt         = "%q required by %s"
+	dependencyOfFmt       = " (installed as a dependency of %s)"
+)
+
+// A ConflictReason is the reason a package in the Lock conflicts with the
+// packages that depend on it.
+type ConflictReason string
+
+// Reasons a package in the Lock conflicts with the packages that depend on
+// it.
+const (
+	// ConflictVersion indicates the installed version of the package does
+	// not satisfy the version constraints of the packages that depend on it.
+	ConflictVersion ConflictReason = "Version"
+
+	// ConflictType indicates the installed package is not the type of
+	// package that the packages that depend on it declared, for example
+	// because a Provider and a Configuration were published to the same
+	// repository.
+	ConflictType ConflictReason = "Type"
+
+	// ConflictUnverifiable indicates the package was installed by digest, so
+	// whether it satisfies the semantic version constraints of the packages
+	// that depend on it cannot be verified.
+	ConflictUnverifiable ConflictReason = "Unverifiable"
+)
+
+// A Requirement is a version constraint a package in the Lock places on one of
+// its dependencies.
+type Requirement struct {
+	Parent     string
+	Constraint string
+}
+
+// A Conflict is a package in the Lock whose installed version or type does
+// not satisfy one or more packages that depend on it.
+type Conflict struct {
+	Reason       ConflictReason
+	Package      string
+	Version      string
+	Origin       v1beta1.PackageOrigin
+	Requirements []Requirement
+
+	// Type of the installed package, and the type of package the packages
+	// that depend on it declared. Only set for type conflicts.
+	Type     v1beta1.PackageType
+	Declared v1beta1.PackageType
+}
+
+func (c Conflict) Error() string {
+	if c.Reason == ConflictType {
+		parents := make([]string, len(c.Requirements))
+		for i, r := range c.Requirements {
+			parents[i] = r.Parent
+		}
+		return fmt.Sprintf(errTypeConflictFmt, c.Package, c.Declared, strings.Join(parents, ", "), c.Type)
+	}
+	reqs := make([]string, len(c.Requirements))
+	for i, r := range c.Requirements {
+		reqs[i] = fmt.Sprintf(requiredByFmt, r.Constraint, r.Parent)
+	}
+	origin := ""
+	if c.Origin.Type == v1beta1.OriginDependency && len(c.Origin.Parents) > 0 {
+		origin = fmt.Sprintf(dependencyOfFmt, strings.Join(c.Origin.Parents, ", "))
+	}
+	if c.Reason == ConflictUnverifiable {
+		return fmt.Sprintf(errUnverifiableFmt, c.Package, origin, c.Version, strings.Join(reqs, ", "))
+	}
+	return fmt.Sprintf(errVersionConflictFmt, c.Version, c.Package, origin, strings.Join(reqs, ", "))
+}
+
+// conflicts returns the supplied packages whose installed version or type
+// does not satisfy the packages that depend on them, sorted by package and
+// reason. A dependency whose installed package is the wrong type is never
+// considered satisfied, whatever its version. A package installed by digest
+// is reported as unverifiable if packages that depend on it constrain its
+// semantic version. Packages that are not yet installed are missing, not
+// conflicting. Optional dependencies never conflict; see
+// OptionalDependencies.
+func conflicts(pkgs []v1beta1.LockPackage) []Conflict { // nolint:gocyclo
+	installed := map[string]v1beta1.LockPackage{}
+	for _, p := range pkgs {
+		installed[p.Identifier()] = p
+	}
+
+	type key struct {
+		id       string
+		declared v1beta1.PackageType
+	}
+	violated := map[string][]Requirement{}
+	unverified := map[string][]Requirement{}
+	mistyped := map[key][]Requirement{}
+	for _, p := range pkgs {
+		for _, d := range p.Dependencies {
+			ip, ok := installed[d.Identifier()]
+			if !ok || d.Optional {
+				continue
+			}
+			rq := Requirement{Parent: p.Source, Constraint: d.Constraints}
+			switch {
+			case wrongType(d.Type, ip.Type):
+				k := key{id: d.Identifier(), declared: d.Type}
+				mistyped[k] = append(mistyped[k], rq)
+			case !satisfies(ip.Version, d.Constraints):
+				violated[d.Identifier()] = append(violated[d.Identifier()], rq)
+			case !verifiable(ip.Version, d.Constraints):
+				unverified[d.Identifier()] = append(unverified[d.Identifier()], rq)
+			}
+		}
+	}
+
+	byParent := func(reqs []Requirement) {
+		sort.Slice(reqs, func(i, j int) bool { return reqs[i].Parent < reqs[j].Parent })
+	}
+	out := make([]Conflict, 0, len(violated)+len(unverified)+len(mistyped))
+	for k, reqs := range mistyped {
+		byParent(reqs)
+		ip := installed[k.id]
+		out = append(out, Conflict{Reason: ConflictType, Package: ip.Source, Version: ip.Version, Origin: ip.GetOrigin(), Requirements: reqs, Type: ip.Type, Declared: k.declared})
+	}
+	for id, reqs := range violated {
+		byParent(reqs)
+		ip := installed[id]
+		out = append(out, Conflict{Reason: ConflictVersion, Package: ip.Source, Version: ip.Version, Origin: ip.GetOrigin(), Requirements: reqs})
+	}
+	for id, reqs := range unverified {
+		byParent(reqs)
+		ip := installed[id]
+		out = append(out, Conflict{Reason: ConflictUnverifiable, Package: ip.Source, Version: ip.Version, Origin: ip.GetOrigin(), Requirements: reqs})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Package != out[j].Package {
+			return out[i].Package < out[j].Package
+		}
+		if out[i].Reason != out[j].Reason {
+			return out[i].Reason < out[j].Reason
+		}
+		return out[i].Declared < out[j].Declared
+	})
+	return out
+}
+
+// satisfies returns true if the supplied installed version satisfies the
+// supplied constraint. A dependency pinned to a digest or exact tag is only
+// satisfied by that digest or tag. A semantic version constraint can't be
+// checked against an installed version that is not a semantic version, e.g.
+// a digest, and is assumed to be satisfied. An alias of latest is satisfied by
+// any installed version; it only determines which version is installed.
+func satisfies(version, constraint string) bool {
+	// Which digest a tracked tag points at changes over time, so any
+	// installed version satisfies it until the tag is resolved again.
+	if _, ok := TrackedTag(constraint); ok {
+		return true
+	}
+	if strings.HasPrefix(constraint, digestPrefix) {
+		return version == constraint
+	}
+	c, err := NewConstraint(constraint)
+	if err != nil {
+		return version == constraint
+	}
+	v, err := semver.NewVersion(version)
+	if err != nil {
+		return true
+	}
+	return c.Check(v)
+}
+
+// verifiable returns false if the supplied constraint is a semantic version
+// constraint, and the supplied installed version is a digest that it can't be
+// checked against. Any installed version satisfies an alias.
+func verifiable(version, constraint string) bool {
+	if !strings.HasPrefix(version, digestPrefix) || strings.HasPrefix(constraint, digestPrefix) || IsConstraintAlias(constraint) {
+		return true
+	}
+	_, err := NewConstraint(constraint)
+	return err != nil
+}
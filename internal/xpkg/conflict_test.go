@@ -0,0 +1,238 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package xpkg
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+)
+
+func TestConflicts(t *testing.T) {
+	digest := "sha256:ecc25c121431dfc7058754427f97c034ecde26d4aafa0da16d6429d9f7d6cd9b"
+
+	cases := map[string]struct {
+		reason string
+		pkgs   []v1beta1.LockPackage
+		want   []Conflict
+	}{
+		"Satisfied": {
+			reason: "We should not report a conflict if every installed package satisfies the constraints on it.",
+			pkgs: []v1beta1.LockPackage{
+				{Source: "crossplane/provider-aws", Version: "v0.21.0"},
+				{Source: "crossplane/provider-gcp", Version: digest},
+				{Source: "cool/config-a", Dependencies: []v1beta1.Dependency{
+					{Package: "crossplane/provider-aws", Constraints: ">=v0.21.0"},
+					{Package: "crossplane/provider-gcp", Constraints: digest},
+				}},
+				{Source: "cool/config-b", Dependencies: []v1beta1.Dependency{
+					{Package: "crossplane/provider-aws", Constraints: "<v1.0.0"},
+					{Package: "crossplane/provider-gcp", Constraints: "latest"},
+				}},
+			},
+		},
+		"Unverifiable": {
+			reason: "We should report a package installed by digest whose semantic version is constrained, whether or not the constraint looks satisfiable.",
+			pkgs: []v1beta1.LockPackage{
+				{Source: "crossplane/provider-gcp", Version: digest},
+				{Source: "cool/config-b", Dependencies: []v1beta1.Dependency{
+					{Package: "crossplane/provider-gcp", Constraints: ">=v0.1.0"},
+				}},
+				{Source: "cool/config-a", Dependencies: []v1beta1.Dependency{
+					{Package: "crossplane/provider-gcp", Constraints: "<v0.0.1"},
+				}},
+				{Source: "cool/config-c", Dependencies: []v1beta1.Dependency{
+					{Package: "crossplane/provider-gcp", Constraints: digest},
+				}},
+			},
+			want: []Conflict{
+				{
+					Reason:  ConflictUnverifiable,
+					Package: "crossplane/provider-gcp",
+					Version: digest,
+					Origin:  v1beta1.PackageOrigin{Type: v1beta1.OriginUser},
+					Requirements: []Requirement{
+						{Parent: "cool/config-a", Constraint: "<v0.0.1"},
+						{Parent: "cool/config-b", Constraint: ">=v0.1.0"},
+					},
+				},
+			},
+		},
+		"Missing": {
+			reason: "We should not report a conflict for a dependency that is not yet installed.",
+			pkgs: []v1beta1.LockPackage{
+				{Source: "cool/config-a", Dependencies: []v1beta1.Dependency{
+					{Package: "crossplane/provider-aws", Constraints: ">=v0.21.0"},
+				}},
+			},
+		},
+		"Violated": {
+			reason: "We should report each installed package that violates the constraints on it, with its origin and each violated constraint.",
+			pkgs: []v1beta1.LockPackage{
+				{Source: "crossplane/provider-gcp", Version: "v0.1.0", Origin: &v1beta1.PackageOrigin{Type: v1beta1.OriginDependency, Parents: []string{"cool/config-b"}}},
+				{Source: "crossplane/provider-aws", Version: "v0.20.0"},
+				{Source: "cool/config-b", Dependencies: []v1beta1.Dependency{
+					{Package: "crossplane/provider-aws", Constraints: ">=v0.22.0"},
+					{Package: "crossplane/provider-gcp", Constraints: digest},
+				}},
+				{Source: "cool/config-a", Dependencies: []v1beta1.Dependency{
+					{Package: "crossplane/provider-aws", Constraints: ">=v0.21.0"},
+				}},
+				{Source: "cool/config-c", Dependencies: []v1beta1.Dependency{
+					{Package: "crossplane/provider-aws", Constraints: "<v1.0.0"},
+				}},
+			},
+			want: []Conflict{
+				{
+					Reason:  ConflictVersion,
+					Package: "crossplane/provider-aws",
+					Version: "v0.20.0",
+					Origin:  v1beta1.PackageOrigin{Type: v1beta1.OriginUser},
+					Requirements: []Requirement{
+						{Parent: "cool/config-a", Constraint: ">=v0.21.0"},
+						{Parent: "cool/config-b", Constraint: ">=v0.22.0"},
+					},
+				},
+				{
+					Reason:  ConflictVersion,
+					Package: "crossplane/provider-gcp",
+					Version: "v0.1.0",
+					Origin:  v1beta1.PackageOrigin{Type: v1beta1.OriginDependency, Parents: []string{"cool/config-b"}},
+					Requirements: []Requirement{
+						{Parent: "cool/config-b", Constraint: digest},
+					},
+				},
+			},
+		},
+		"MatchingType": {
+			reason: "We should not report a conflict if every installed package is the type of package declared by the packages that depend on it.",
+			pkgs: []v1beta1.LockPackage{
+				{Source: "acme/foo", Type: v1beta1.ProviderPackageType, Version: "v1.0.0"},
+				{Source: "cool/config-a", Type: v1beta1.ConfigurationPackageType, Dependencies: []v1beta1.Dependency{
+					{Package: "acme/foo", Type: v1beta1.ProviderPackageType, Constraints: ">=v1.0.0"},
+				}},
+			},
+		},
+		"MismatchedType": {
+			reason: "We should report an installed package that is not the type of package declared by the packages that depend on it, even if its version satisfies them.",
+			pkgs: []v1beta1.LockPackage{
+				{Source: "acme/foo", Type: v1beta1.ConfigurationPackageType, Version: "v1.0.0"},
+				{Source: "cool/config-b", Type: v1beta1.ConfigurationPackageType, Dependencies: []v1beta1.Dependency{
+					{Package: "acme/foo", Type: v1beta1.ProviderPackageType, Constraints: ">=v2.0.0"},
+				}},
+				{Source: "cool/config-a", Type: v1beta1.ConfigurationPackageType, Dependencies: []v1beta1.Dependency{
+					{Package: "acme/foo", Type: v1beta1.ProviderPackageType, Constraints: ">=v1.0.0"},
+				}},
+				{Source: "cool/config-c", Type: v1beta1.ConfigurationPackageType, Dependencies: []v1beta1.Dependency{
+					{Package: "acme/foo", Type: v1beta1.ConfigurationPackageType, Constraints: ">=v2.0.0"},
+				}},
+			},
+			want: []Conflict{
+				{
+					Reason:  ConflictType,
+					Package: "acme/foo",
+					Version: "v1.0.0",
+					Origin:  v1beta1.PackageOrigin{Type: v1beta1.OriginUser},
+					Requirements: []Requirement{
+						{Parent: "cool/config-a", Constraint: ">=v1.0.0"},
+						{Parent: "cool/config-b", Constraint: ">=v2.0.0"},
+					},
+					Type:     v1beta1.ConfigurationPackageType,
+					Declared: v1beta1.ProviderPackageType,
+				},
+				{
+					Reason:  ConflictVersion,
+					Package: "acme/foo",
+					Version: "v1.0.0",
+					Origin:  v1beta1.PackageOrigin{Type: v1beta1.OriginUser},
+					Requirements: []Requirement{
+						{Parent: "cool/config-c", Constraint: ">=v2.0.0"},
+					},
+				},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := conflicts(tc.pkgs)
+			if diff := cmp.Diff(tc.want, got, cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("\n%s\nconflicts(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestConflictError(t *testing.T) {
+	reqs := []Requirement{{Parent: "cool/config-a", Constraint: ">=v0.21.0"}}
+
+	cases := map[string]struct {
+		reason string
+		c      Conflict
+		want   string
+	}{
+		"User": {
+			reason: "We should not describe the origin of a package a user installed.",
+			c:      Conflict{Package: "crossplane/provider-aws", Version: "v0.20.0", Origin: v1beta1.PackageOrigin{Type: v1beta1.OriginUser}, Requirements: reqs},
+			want:   `installed version v0.20.0 of package crossplane/provider-aws does not satisfy ">=v0.21.0" required by cool/config-a`,
+		},
+		"Dependency": {
+			reason: "We should describe which packages a package installed as a dependency was installed for.",
+			c: Conflict{
+				Package:      "crossplane/provider-aws",
+				Version:      "v0.20.0",
+				Origin:       v1beta1.PackageOrigin{Type: v1beta1.OriginDependency, Parents: []string{"cool/config-b", "cool/config-c"}},
+				Requirements: reqs,
+			},
+			want: `installed version v0.20.0 of package crossplane/provider-aws (installed as a dependency of cool/config-b, cool/config-c) does not satisfy ">=v0.21.0" required by cool/config-a`,
+		},
+		"Type": {
+			reason: "We should describe which type of package was declared, by which packages, and which type is installed.",
+			c: Conflict{
+				Reason:       ConflictType,
+				Package:      "acme/foo",
+				Version:      "v1.0.0",
+				Requirements: []Requirement{{Parent: "cool/config-a", Constraint: ">=v1.0.0"}, {Parent: "cool/config-b", Constraint: ">=v1.0.0"}},
+				Type:         v1beta1.ConfigurationPackageType,
+				Declared:     v1beta1.ProviderPackageType,
+			},
+			want: "dependency acme/foo declared as Provider by cool/config-a, cool/config-b but installed package is a Configuration",
+		},
+		"Unverifiable": {
+			reason: "We should describe which digest is installed, and which constraints can't be verified against it.",
+			c: Conflict{
+				Reason:       ConflictUnverifiable,
+				Package:      "cool/config-b",
+				Version:      "sha256:ecc25c12",
+				Origin:       v1beta1.PackageOrigin{Type: v1beta1.OriginUser},
+				Requirements: reqs,
+			},
+			want: `cannot verify that package cool/config-b installed at digest sha256:ecc25c12 satisfies ">=v0.21.0" required by cool/config-a`,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if diff := cmp.Diff(tc.want, tc.c.Error()); diff != "" {
+				t.Errorf("\n%s\nError(): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
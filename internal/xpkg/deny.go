@@ -0,0 +1,158 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package xpkg
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Masterminds/semver"
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+const errDeniedFmt = "every version of dependency (%s) that satisfies its constraints is excluded by the version denylist: %s"
+
+// A Denylist lists versions of package repositories that must not be
+// installed, e.g. because they were yanked after they were published.
+type Denylist interface {
+	// DeniedVersions returns the versions of the supplied reference's
+	// repository that must not be installed. Each is either a version, or a
+	// semantic version constraint matching a range of versions.
+	DeniedVersions(ctx context.Context, ref name.Reference) ([]string, error)
+}
+
+// A DenylistFn is a function that satisfies Denylist.
+type DenylistFn func(ctx context.Context, ref name.Reference) ([]string, error)
+
+// DeniedVersions returns the denied versions of the supplied reference's
+// repository.
+func (fn DenylistFn) DeniedVersions(ctx context.Context, ref name.Reference) ([]string, error) {
+	return fn(ctx, ref)
+}
+
+// A DeniedError indicates every version that satisfies a set of constraints
+// is excluded by a Denylist. It matches ErrNoMatch.
+type DeniedError struct {
+	// Package whose versions are denied.
+	Package string
+
+	// Versions that satisfy the constraints, but are denied.
+	Versions []string
+}
+
+func (e *DeniedError) Error() string {
+	return fmt.Sprintf(errDeniedFmt, e.Package, strings.Join(e.Versions, ", "))
+}
+
+// Is returns true if the supplied error is ErrNoMatch.
+func (e *DeniedError) Is(target error) bool {
+	return target == ErrNoMatch
+}
+
+// WithDenylist returns a copy of the Resolver that never selects a version
+// the supplied Denylist denies. Versions are not denied if the Denylist can't
+// be consulted. The Resolver it was called on is not modified.
+func (r *Resolver) WithDenylist(d Denylist) *Resolver {
+	c := *r
+	c.denylist = d
+	return &c
+}
+
+// denied returns a function that reports whether a version of the supplied
+// reference's repository is denied, or nil if none are.
+func (r *Resolver) denied(ctx context.Context, ref name.Reference) func(version string) bool {
+	if r.denylist == nil {
+		return nil
+	}
+	entries, err := r.denylist.DeniedVersions(ctx, ref)
+	if err != nil {
+		r.log.Debug("Cannot consult version denylist, denying no versions", "repository", ref.Context().Name(), "error", err)
+		return nil
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+
+	exact := map[string]bool{}
+	ranges := make([]*semver.Constraints, 0, len(entries))
+	for _, e := range entries {
+		exact[e] = true
+		if v, err := semver.NewVersion(e); err == nil {
+			exact[v.String()] = true
+			continue
+		}
+		c, err := r.selector.Parse(e)
+		if err != nil {
+			r.log.Debug("Ignoring invalid version denylist entry", "repository", ref.Context().Name(), "entry", e, "error", err)
+			continue
+		}
+		ranges = append(ranges, c)
+	}
+	return func(version string) bool {
+		if exact[version] {
+			return true
+		}
+		v, err := semver.NewVersion(version)
+		if err != nil {
+			return false
+		}
+		if exact[v.String()] {
+			return true
+		}
+		for _, c := range ranges {
+			if c.Check(v) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// exclude returns the supplied versions that the supplied function doesn't
+// deny, and those it does, in their original order.
+func exclude(versions []string, denied func(version string) bool) ([]string, []string) {
+	if denied == nil {
+		return versions, nil
+	}
+	kept := make([]string, 0, len(versions))
+	var excluded []string
+	for _, v := range versions {
+		if denied(v) {
+			excluded = append(excluded, v)
+			continue
+		}
+		kept = append(kept, v)
+	}
+	return kept, excluded
+}
+
+// deniedMatches returns a *DeniedError for the supplied package if any of the
+// supplied excluded versions satisfy all of the supplied constraints, or nil
+// if none do.
+func (r *Resolver) deniedMatches(pkg string, excluded []string, constraints []string) error {
+	var matching []string
+	for _, v := range excluded {
+		if _, err := r.selector.Select([]string{v}, VersionSelectionLowest, constraints...); err == nil {
+			matching = append(matching, v)
+		}
+	}
+	if len(matching) == 0 {
+		return nil
+	}
+	return &DeniedError{Package: pkg, Versions: matching}
+}
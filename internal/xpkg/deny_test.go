@@ -0,0 +1,163 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package xpkg
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-containerregistry/pkg/name"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+)
+
+func TestResolveDenylist(t *testing.T) {
+	errBoom := errors.New("boom")
+	tags := []string{"v0.1.0", "v0.2.0", "v0.3.0"}
+	dep := v1beta1.Dependency{Package: "crossplane/provider-aws", Type: v1beta1.ProviderPackageType, Constraints: ">=v0.2.0"}
+	pkgs := []v1beta1.LockPackage{{Source: "cool/config", Type: v1beta1.ConfigurationPackageType, Dependencies: []v1beta1.Dependency{dep}}}
+	deny := func(versions []string, err error) Denylist {
+		return DenylistFn(func(_ context.Context, _ name.Reference) ([]string, error) { return versions, err })
+	}
+
+	type want struct {
+		source     string
+		candidates int
+		err        error
+	}
+
+	cases := map[string]struct {
+		reason   string
+		denylist Denylist
+		want     want
+	}{
+		"NoDenylist": {
+			reason: "We should select the highest version that satisfies the constraint if there is no denylist.",
+			want: want{
+				source:     "crossplane/provider-aws:v0.3.0",
+				candidates: 3,
+			},
+		},
+		"SkipDenied": {
+			reason:   "We should skip a denied version, and select the next version that satisfies the constraint.",
+			denylist: deny([]string{"v0.3.0"}, nil),
+			want: want{
+				source:     "crossplane/provider-aws:v0.2.0",
+				candidates: 2,
+			},
+		},
+		"SkipDeniedRange": {
+			reason:   "We should skip every version in a denied range, regardless of whether it is v-prefixed.",
+			denylist: deny([]string{">=0.2.5"}, nil),
+			want: want{
+				source:     "crossplane/provider-aws:v0.2.0",
+				candidates: 2,
+			},
+		},
+		"AllDenied": {
+			reason:   "We should report that no version is valid, noting the versions the denylist excluded, if every version that satisfies the constraint is denied.",
+			denylist: deny([]string{"0.2.0", "v0.3.0"}, nil),
+			want: want{
+				candidates: 1,
+				err:        errors.Wrap(&DeniedError{Package: "crossplane/provider-aws", Versions: []string{"v0.2.0", "v0.3.0"}}, errNoValidVersion),
+			},
+		},
+		"DenylistUnavailable": {
+			reason:   "We should deny no versions if the denylist can't be consulted.",
+			denylist: deny(nil, errBoom),
+			want: want{
+				source:     "crossplane/provider-aws:v0.3.0",
+				candidates: 3,
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			plan, _, err := NewResolver().WithDenylist(tc.denylist).Resolve(context.Background(), pkgs, &mockTagsFetcher{tags: tags})
+			if err != nil {
+				t.Fatalf("\n%s\nResolve(...): %s", tc.reason, err)
+			}
+			if len(plan) != 1 {
+				t.Fatalf("\n%s\nResolve(...): want 1 planned install, got %d", tc.reason, len(plan))
+			}
+			p := plan[0]
+			if diff := cmp.Diff(tc.want.source, p.Source); diff != "" {
+				t.Errorf("\n%s\nResolve(...): -want source, +got source:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.candidates, p.Candidates); diff != "" {
+				t.Errorf("\n%s\nResolve(...): -want candidates, +got candidates:\n%s", tc.reason, diff)
+			}
+			var got error
+			if p.Err != nil {
+				got = p.Err.Unwrap()
+			}
+			if diff := cmp.Diff(tc.want.err, got, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nResolve(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestUpgradeDenylist(t *testing.T) {
+	tags := []string{"v0.1.0", "v0.2.0", "v0.3.0"}
+	ref, _ := name.ParseReference("crossplane/provider-aws")
+	deny := func(versions ...string) Denylist {
+		return DenylistFn(func(_ context.Context, _ name.Reference) ([]string, error) { return versions, nil })
+	}
+
+	type want struct {
+		version string
+		err     error
+	}
+
+	cases := map[string]struct {
+		reason   string
+		denylist Denylist
+		want     want
+	}{
+		"SkipDenied": {
+			reason:   "We should upgrade to the lowest satisfying version that is not denied.",
+			denylist: deny("v0.2.0"),
+			want:     want{version: "v0.3.0"},
+		},
+		"AllDenied": {
+			reason:   "We should return an error matching ErrNoMatch if every satisfying version is denied.",
+			denylist: deny("v0.2.0", "v0.3.0"),
+			want:     want{err: &DeniedError{Package: ref.Context().Name(), Versions: []string{"v0.2.0", "v0.3.0"}}},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			v, _, err := NewResolver().WithDenylist(tc.denylist).Upgrade(context.Background(), &mockTagsFetcher{tags: tags}, ref, ">=v0.2.0")
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nUpgrade(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if tc.want.err != nil && !errors.Is(err, ErrNoMatch) {
+				t.Errorf("\n%s\nUpgrade(...): want error matching ErrNoMatch, got %v", tc.reason, err)
+			}
+			if diff := cmp.Diff(tc.want.version, v); diff != "" {
+				t.Errorf("\n%s\nUpgrade(...): -want version, +got version:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
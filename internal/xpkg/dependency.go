@@ -0,0 +1,148 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package xpkg
+
+import (
+	"github.com/google/go-containerregistry/pkg/name"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+
+	pkgmetav1 "github.com/crossplane/crossplane/apis/pkg/meta/v1"
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+)
+
+const (
+	// MaxPackageLength is the maximum length of a dependency's package. The
+	// OCI distribution spec limits a repository name, including its
+	// registry, to 255 characters.
+	MaxPackageLength = 255
+
+	// MaxConstraintsLength is the maximum length of a dependency's version
+	// constraints. Constraints are truncated further when they're recorded
+	// in a Lock's status.
+	MaxConstraintsLength = 1024
+
+	errPackageEmpty          = "dependency package is empty"
+	errPackageTooLongFmt     = "dependency package is %d characters long; at most %d are allowed"
+	errPackageCharFmt        = "dependency package contains disallowed character %q at position %d"
+	errPackageInvalid        = "dependency package is not a valid OCI reference"
+	errConstraintsTooLongFmt = "dependency version constraints are %d characters long; at most %d are allowed"
+	errConstraintsCharFmt    = "dependency version constraints contain disallowed character %q at position %d"
+)
+
+// Dependencies returns the dependencies the supplied package declares in its
+// metadata, as they would be recorded in the Lock.
+func Dependencies(pkg pkgmetav1.Pkg) []v1beta1.Dependency {
+	return ConvertDependencies(pkg.GetDependencies())
+}
+
+// ConvertDependencies returns the supplied dependencies declared in package
+// metadata as they would be recorded in the Lock.
+func ConvertDependencies(in []pkgmetav1.Dependency) []v1beta1.Dependency {
+	deps := make([]v1beta1.Dependency, len(in))
+	for i, dep := range in {
+		pdep := v1beta1.Dependency{}
+		if dep.Configuration != nil {
+			pdep.Package = *dep.Configuration
+			pdep.Type = v1beta1.ConfigurationPackageType
+		} else if dep.Provider != nil {
+			pdep.Package = *dep.Provider
+			pdep.Type = v1beta1.ProviderPackageType
+		} else if dep.Function != nil {
+			pdep.Package = *dep.Function
+			pdep.Type = v1beta1.FunctionPackageType
+		}
+		pdep.Constraints = dep.Version
+		pdep.Optional = dep.Optional
+		deps[i] = pdep
+	}
+	return deps
+}
+
+// ValidateDependency returns an error if the supplied dependency's package or
+// version constraints are too long, contain characters that can't appear in
+// them, or if its package is not a valid OCI reference. Dependencies are
+// declared by package authors, so they are validated before they are parsed,
+// logged, or recorded. Errors never include the dependency's package or
+// constraints.
+func ValidateDependency(d v1beta1.Dependency) error {
+	if err := ValidatePackage(d.Package); err != nil {
+		return err
+	}
+	return ValidateConstraints(d.Constraints)
+}
+
+// ValidatePackage returns an error if the supplied dependency package is too
+// long, contains characters that can't appear in an OCI reference, or is not
+// a valid OCI reference.
+func ValidatePackage(pkg string) error {
+	if pkg == "" {
+		return errors.New(errPackageEmpty)
+	}
+	if len(pkg) > MaxPackageLength {
+		return errors.Errorf(errPackageTooLongFmt, len(pkg), MaxPackageLength)
+	}
+	for i := 0; i < len(pkg); i++ {
+		if !packageChar(pkg[i]) {
+			return errors.Errorf(errPackageCharFmt, pkg[i], i)
+		}
+	}
+	// We don't wrap the parse error, because it includes the package.
+	if _, err := name.ParseReference(pkg); err != nil {
+		return errors.New(errPackageInvalid)
+	}
+	return nil
+}
+
+// ValidateConstraints returns an error if the supplied dependency version
+// constraints are too long, or contain characters that can't appear in a
+// semantic version constraint, tag, or digest. Constraints are otherwise
+// validated when the dependency is resolved.
+func ValidateConstraints(c string) error {
+	if len(c) > MaxConstraintsLength {
+		return errors.Errorf(errConstraintsTooLongFmt, len(c), MaxConstraintsLength)
+	}
+	for i := 0; i < len(c); i++ {
+		if !constraintChar(c[i]) {
+			return errors.Errorf(errConstraintsCharFmt, c[i], i)
+		}
+	}
+	return nil
+}
+
+// packageChar returns true if the supplied byte may appear in an OCI
+// reference, i.e. a registry, repository, tag, or digest.
+func packageChar(b byte) bool {
+	return alphanumeric(b) || b == '.' || b == '_' || b == '-' || b == '/' || b == ':' || b == '@'
+}
+
+// constraintChar returns true if the supplied byte may appear in a semantic
+// version constraint, a tag, or a digest.
+func constraintChar(b byte) bool {
+	if alphanumeric(b) {
+		return true
+	}
+	switch b {
+	case ' ', '.', '_', '-', '+', ':', ',', '|', '<', '>', '=', '!', '~', '^', '*':
+		return true
+	}
+	return false
+}
+
+func alphanumeric(b byte) bool {
+	return (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z') || (b >= '0' && b <= '9')
+}
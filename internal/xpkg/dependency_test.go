@@ -0,0 +1,179 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package xpkg
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	pkgmetav1 "github.com/crossplane/crossplane/apis/pkg/meta/v1"
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+)
+
+func TestConvertDependencies(t *testing.T) {
+	provider := "crossplane/provider-aws"
+	configuration := "crossplane/getting-started-with-aws"
+	function := "crossplane/function-nop"
+
+	cases := map[string]struct {
+		reason string
+		in     []pkgmetav1.Dependency
+		want   []v1beta1.Dependency
+	}{
+		"None": {
+			reason: "A package without dependencies should have no dependencies in the Lock.",
+			want:   []v1beta1.Dependency{},
+		},
+		"EachType": {
+			reason: "Each type of dependency should be recorded as the corresponding type of package.",
+			in: []pkgmetav1.Dependency{
+				{Provider: &provider, Version: ">=v0.1.0"},
+				{Configuration: &configuration, Version: ">=v0.2.0", Optional: true},
+				{Function: &function, Version: ">=v0.3.0"},
+			},
+			want: []v1beta1.Dependency{
+				{Package: provider, Type: v1beta1.ProviderPackageType, Constraints: ">=v0.1.0"},
+				{Package: configuration, Type: v1beta1.ConfigurationPackageType, Constraints: ">=v0.2.0", Optional: true},
+				{Package: function, Type: v1beta1.FunctionPackageType, Constraints: ">=v0.3.0"},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := ConvertDependencies(tc.in)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nConvertDependencies(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestValidateDependency(t *testing.T) {
+	cases := map[string]struct {
+		reason string
+		dep    v1beta1.Dependency
+		err    string
+	}{
+		"Valid": {
+			reason: "A dependency on a repository with a semantic version constraint should be valid.",
+			dep:    v1beta1.Dependency{Package: "xpkg.upbound.io/crossplane/provider-aws", Constraints: ">=v0.20.0, <v1.0.0 || ~v1.2"},
+		},
+		"ValidDigest": {
+			reason: "A dependency on a registry with a port, constrained to a digest, should be valid.",
+			dep:    v1beta1.Dependency{Package: "registry.example.org:5000/cool/provider-aws", Constraints: "sha256:ecc25c121431dfc7058754427f97c034ecde26d4aafa0da16d8f6a7a1ed29fd9"},
+		},
+		"ValidNoConstraints": {
+			reason: "A dependency without constraints should be valid.",
+			dep:    v1beta1.Dependency{Package: "crossplane/provider-aws"},
+		},
+		"EmptyPackage": {
+			reason: "A dependency without a package should be invalid.",
+			err:    errPackageEmpty,
+		},
+		"PackageTooLong": {
+			reason: "A dependency whose package is too long should be invalid, without being parsed.",
+			dep:    v1beta1.Dependency{Package: "crossplane/" + strings.Repeat("a", 100000)},
+			err:    "dependency package is 100011 characters long; at most 255 are allowed",
+		},
+		"PackageNewline": {
+			reason: "A dependency whose package could inject YAML should be invalid.",
+			dep:    v1beta1.Dependency{Package: "crossplane/provider-aws\n  evil: true"},
+			err:    `dependency package contains disallowed character '\n' at position 23`,
+		},
+		"PackageShell": {
+			reason: "A dependency whose package contains shell metacharacters should be invalid.",
+			dep:    v1beta1.Dependency{Package: "crossplane/$(whoami)"},
+			err:    `dependency package contains disallowed character '$' at position 11`,
+		},
+		"PackageNotReference": {
+			reason: "A dependency whose package is not an OCI reference should be invalid.",
+			dep:    v1beta1.Dependency{Package: "Crossplane/Provider-AWS"},
+			err:    errPackageInvalid,
+		},
+		"ConstraintsTooLong": {
+			reason: "A dependency whose constraints are too long should be invalid.",
+			dep:    v1beta1.Dependency{Package: "crossplane/provider-aws", Constraints: strings.Repeat(">=v0.1.0, ", 10000)},
+			err:    "dependency version constraints are 100000 characters long; at most 1024 are allowed",
+		},
+		"ConstraintsYAML": {
+			reason: "A dependency whose constraints could inject YAML should be invalid.",
+			dep:    v1beta1.Dependency{Package: "crossplane/provider-aws", Constraints: ">=v0.1.0\"\nkind: Secret"},
+			err:    `dependency version constraints contain disallowed character '"' at position 8`,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := ValidateDependency(tc.dep)
+			got := ""
+			if err != nil {
+				got = err.Error()
+			}
+			if diff := cmp.Diff(tc.err, got); diff != "" {
+				t.Errorf("\n%s\nValidateDependency(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+// TestValidateDependencyRandom validates many random, mostly hostile,
+// dependencies. Validation should never panic, should reject anything too
+// long to be legitimate, and should never echo what it rejects.
+func TestValidateDependencyRandom(t *testing.T) {
+	alphabets := [][]rune{
+		[]rune("abcdefghijklmnopqrstuvwxyz0123456789./-_:@"),
+		[]rune("<>=!~^*|, .v0123456789-+x"),
+		[]rune("\x00\n\r\t\"'`${}[]#&;\\ é‮�"),
+	}
+	r := rand.New(rand.NewSource(1))
+	str := func() string {
+		n := r.Intn(64)
+		if r.Intn(8) == 0 {
+			n = r.Intn(200000)
+		}
+		var b strings.Builder
+		for i := 0; i < n; i++ {
+			a := alphabets[r.Intn(len(alphabets))]
+			b.WriteRune(a[r.Intn(len(a))])
+		}
+		return b.String()
+	}
+
+	for i := 0; i < 2000; i++ {
+		d := v1beta1.Dependency{Package: str(), Constraints: str()}
+		err := ValidateDependency(d)
+		if err == nil {
+			if len(d.Package) > MaxPackageLength || len(d.Constraints) > MaxConstraintsLength {
+				t.Fatalf("ValidateDependency(%q, %q): accepted an overly long dependency", d.Package, d.Constraints)
+			}
+			continue
+		}
+		msg := err.Error()
+		if len(msg) > 128 {
+			t.Errorf("ValidateDependency(...): error is %d characters long, want at most 128", len(msg))
+		}
+		for _, s := range []string{d.Package, d.Constraints} {
+			if len(s) > 8 && strings.Contains(msg, s) {
+				t.Errorf("ValidateDependency(...): error %q echoes its input %q", msg, s)
+			}
+		}
+	}
+}
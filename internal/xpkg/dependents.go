@@ -0,0 +1,50 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package xpkg
+
+import (
+	"strings"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+	"github.com/crossplane/crossplane/internal/xpkg/canonical"
+)
+
+const (
+	errNotReportedFmt = "dependencies of %s at version %s have not yet been resolved"
+	errUnresolvedFmt  = "unresolved dependencies: %s"
+)
+
+// UnresolvedDependencies returns an error describing why the dependencies of
+// the package from the supplied source at the supplied version are not
+// resolved, according to the dependent resolutions in the supplied Lock's
+// status, or nil if they are. Dependencies the resolver has not yet reported
+// on, e.g. because the package was only just added to the Lock or changed
+// version, are not resolved.
+func UnresolvedDependencies(lock *v1beta1.Lock, source, version string) error {
+	for _, d := range lock.Status.Dependents {
+		if !canonical.Equal(d.Package, source) || d.Version != version {
+			continue
+		}
+		if len(d.Unresolved) > 0 {
+			return errors.Errorf(errUnresolvedFmt, strings.Join(d.Unresolved, ", "))
+		}
+		return nil
+	}
+	return errors.Errorf(errNotReportedFmt, source, version)
+}
@@ -0,0 +1,75 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package xpkg
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+)
+
+func TestUnresolvedDependencies(t *testing.T) {
+	lock := &v1beta1.Lock{Status: v1beta1.LockStatus{Dependents: []v1beta1.DependentResolution{
+		{Package: "index.docker.io/cool/config-a", Version: "v1.0.0"},
+		{Package: "cool/config-b", Version: "v1.0.0", Unresolved: []string{"crossplane/provider-aws", "crossplane/provider-gcp"}},
+	}}}
+
+	type args struct {
+		source  string
+		version string
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   error
+	}{
+		"Resolved": {
+			reason: "A package the resolver reported no unresolved dependencies for should be resolved, however its source is written.",
+			args:   args{source: "docker.io/cool/config-a", version: "v1.0.0"},
+		},
+		"Unresolved": {
+			reason: "A package the resolver reported unresolved dependencies for should not be resolved.",
+			args:   args{source: "cool/config-b", version: "v1.0.0"},
+			want:   errors.Errorf(errUnresolvedFmt, "crossplane/provider-aws, crossplane/provider-gcp"),
+		},
+		"NotReported": {
+			reason: "A package the resolver hasn't reported on should not be resolved.",
+			args:   args{source: "cool/config-c", version: "v1.0.0"},
+			want:   errors.Errorf(errNotReportedFmt, "cool/config-c", "v1.0.0"),
+		},
+		"OtherVersion": {
+			reason: "A package the resolver only reported on at another version should not be resolved.",
+			args:   args{source: "cool/config-a", version: "v1.1.0"},
+			want:   errors.Errorf(errNotReportedFmt, "cool/config-a", "v1.1.0"),
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := UnresolvedDependencies(lock, tc.args.source, tc.args.version)
+			if diff := cmp.Diff(tc.want, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nUnresolvedDependencies(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
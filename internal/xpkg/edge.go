@@ -0,0 +1,161 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package xpkg
+
+import (
+	"fmt"
+
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+	"github.com/crossplane/crossplane/internal/dag"
+)
+
+const (
+	errEdgeViolatedFmt     = "package %s requires %s %q but version %s is installed"
+	errEdgeMistypedFmt     = "package %s requires %s to be a %s but it is a %s"
+	errEdgeUnverifiableFmt = "package %s requires %s %q but it is installed at digest %s"
+	msgEdgeSatisfiedFmt    = "package %s requires %s %q and version %s is installed"
+)
+
+// An EdgeState is the outcome of checking whether an installed package
+// satisfies the constraint one package that depends on it places on it.
+type EdgeState string
+
+// Outcomes of checking an edge of the dependency graph.
+const (
+	// EdgeSatisfied indicates the installed package satisfies the
+	// constraint.
+	EdgeSatisfied EdgeState = "Satisfied"
+
+	// EdgeViolated indicates the installed package is not the declared type
+	// of package, or its version does not satisfy the constraint.
+	EdgeViolated EdgeState = "Violated"
+
+	// EdgeUnverifiable indicates the installed package was installed by
+	// digest, so whether it satisfies a semantic version constraint cannot
+	// be verified.
+	EdgeUnverifiable EdgeState = "Unverifiable"
+)
+
+// An EdgeCheck is the outcome of checking one edge of the dependency graph,
+// i.e. one package's dependency on a package that is installed.
+type EdgeCheck struct {
+	// Dependent is the source of the package that declared the dependency.
+	Dependent string
+
+	// Dependency is the source of the installed package.
+	Dependency string
+
+	// Version the dependency is installed at.
+	Version string
+
+	// Constraint the dependent places on the dependency's version.
+	Constraint string
+
+	// Type of package the dependent declared, and the type of the
+	// installed package.
+	Declared v1beta1.PackageType
+	Type     v1beta1.PackageType
+
+	// Optional is true if the dependent declared the dependency optional.
+	// A violated optional edge never conflicts.
+	Optional bool
+
+	State EdgeState
+}
+
+// String describes the edge, and why it is violated or unverifiable if it is.
+func (c EdgeCheck) String() string {
+	switch {
+	case c.State == EdgeUnverifiable:
+		return fmt.Sprintf(errEdgeUnverifiableFmt, c.Dependent, c.Dependency, c.Constraint, c.Version)
+	case c.State == EdgeViolated && wrongType(c.Declared, c.Type):
+		return fmt.Sprintf(errEdgeMistypedFmt, c.Dependent, c.Dependency, c.Declared, c.Type)
+	case c.State == EdgeViolated:
+		return fmt.Sprintf(errEdgeViolatedFmt, c.Dependent, c.Dependency, c.Constraint, c.Version)
+	}
+	return fmt.Sprintf(msgEdgeSatisfiedFmt, c.Dependent, c.Dependency, c.Constraint, c.Version)
+}
+
+// An EdgeReport accounts for every edge of a dependency graph whose
+// dependency is installed. Edges whose dependency is missing are planned,
+// not checked.
+type EdgeReport struct {
+	Satisfied    []EdgeCheck
+	Violated     []EdgeCheck
+	Unverifiable []EdgeCheck
+}
+
+// CheckEdges checks whether the installed package each edge of the supplied
+// dependency graph leads to satisfies the constraint of the package the edge
+// is from. Unlike the conflicts Resolve reports, which are aggregated per
+// installed package, each edge is checked and accounted for on its own, so
+// that a dependency considered satisfied only because a package from the
+// right repository is installed can't go unnoticed. Edges are reported in the
+// order dag.Edges returns them. An error is returned if the graph cannot be
+// sorted.
+func CheckEdges(d dag.DAG) (EdgeReport, error) {
+	edges, err := dag.Edges(d)
+	if err != nil {
+		return EdgeReport{}, err
+	}
+
+	r := EdgeReport{}
+	for _, e := range edges {
+		// A missing optional dependency is never planned, or checked.
+		if e.Optional && !d.NodeExists(e.To.Identifier()) {
+			continue
+		}
+		n, err := d.GetNode(e.To.Identifier())
+		if err != nil {
+			return EdgeReport{}, err
+		}
+		ip, ok := n.(*v1beta1.LockPackage)
+		if !ok {
+			// The DAG implied the dependency, so it's missing.
+			continue
+		}
+		c := EdgeCheck{
+			Dependent:  source(e.From),
+			Dependency: ip.Source,
+			Version:    ip.Version,
+			Constraint: e.Constraints,
+			Type:       ip.Type,
+			Optional:   e.Optional,
+		}
+		if dep, ok := e.To.(*v1beta1.Dependency); ok {
+			c.Declared = dep.Type
+		}
+		switch {
+		case wrongType(c.Declared, c.Type) || !satisfies(c.Version, c.Constraint):
+			c.State = EdgeViolated
+			r.Violated = append(r.Violated, c)
+		case !verifiable(c.Version, c.Constraint):
+			c.State = EdgeUnverifiable
+			r.Unverifiable = append(r.Unverifiable, c)
+		default:
+			c.State = EdgeSatisfied
+			r.Satisfied = append(r.Satisfied, c)
+		}
+	}
+	return r, nil
+}
+
+// wrongType returns true if a dependency was declared as one type of package
+// but a different type of package is installed.
+func wrongType(declared, installed v1beta1.PackageType) bool {
+	return declared != "" && installed != "" && declared != installed
+}
@@ -0,0 +1,152 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package xpkg
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+	"github.com/crossplane/crossplane/internal/dag"
+)
+
+func TestCheckEdges(t *testing.T) {
+	digest := "sha256:ecc25c121431dfc7058754427f97c034ecde26d4aafa0da16d258090e0443904"
+	config := func(source string, deps ...v1beta1.Dependency) v1beta1.LockPackage {
+		return v1beta1.LockPackage{Source: source, Type: v1beta1.ConfigurationPackageType, Version: "v1.0.0", Dependencies: deps}
+	}
+	provider := func(constraints string) v1beta1.Dependency {
+		return v1beta1.Dependency{Package: "crossplane/provider-aws", Type: v1beta1.ProviderPackageType, Constraints: constraints}
+	}
+	aws := func(version string) v1beta1.LockPackage {
+		return v1beta1.LockPackage{Source: "crossplane/provider-aws", Type: v1beta1.ProviderPackageType, Version: version}
+	}
+
+	type want struct {
+		report EdgeReport
+		err    error
+	}
+
+	cases := map[string]struct {
+		reason string
+		pkgs   []v1beta1.LockPackage
+		want   want
+	}{
+		"Missing": {
+			reason: "Edges to missing dependencies should not be checked.",
+			pkgs:   []v1beta1.LockPackage{config("cool/config", provider(">=v0.1.0"))},
+			want:   want{},
+		},
+		"Satisfied": {
+			reason: "An edge whose installed dependency satisfies its constraint should be satisfied.",
+			pkgs:   []v1beta1.LockPackage{config("cool/config", provider(">=v0.1.0")), aws("v0.2.0")},
+			want: want{report: EdgeReport{Satisfied: []EdgeCheck{
+				{Dependent: "cool/config", Dependency: "crossplane/provider-aws", Version: "v0.2.0", Constraint: ">=v0.1.0", Declared: v1beta1.ProviderPackageType, Type: v1beta1.ProviderPackageType, State: EdgeSatisfied},
+			}}},
+		},
+		"Violated": {
+			reason: "Each edge should be checked on its own, even if other edges to the same dependency are satisfied.",
+			pkgs: []v1beta1.LockPackage{
+				config("cool/config-a", provider(">=v0.1.0")),
+				config("cool/config-b", provider(">=v0.3.0")),
+				aws("v0.2.0"),
+			},
+			want: want{report: EdgeReport{
+				Satisfied: []EdgeCheck{
+					{Dependent: "cool/config-a", Dependency: "crossplane/provider-aws", Version: "v0.2.0", Constraint: ">=v0.1.0", Declared: v1beta1.ProviderPackageType, Type: v1beta1.ProviderPackageType, State: EdgeSatisfied},
+				},
+				Violated: []EdgeCheck{
+					{Dependent: "cool/config-b", Dependency: "crossplane/provider-aws", Version: "v0.2.0", Constraint: ">=v0.3.0", Declared: v1beta1.ProviderPackageType, Type: v1beta1.ProviderPackageType, State: EdgeViolated},
+				},
+			}},
+		},
+		"WrongType": {
+			reason: "An edge to a package of the wrong type should be violated whatever its version.",
+			pkgs: []v1beta1.LockPackage{
+				config("cool/config", v1beta1.Dependency{Package: "crossplane/provider-aws", Type: v1beta1.ConfigurationPackageType, Constraints: ">=v0.1.0"}),
+				aws("v0.2.0"),
+			},
+			want: want{report: EdgeReport{Violated: []EdgeCheck{
+				{Dependent: "cool/config", Dependency: "crossplane/provider-aws", Version: "v0.2.0", Constraint: ">=v0.1.0", Declared: v1beta1.ConfigurationPackageType, Type: v1beta1.ProviderPackageType, State: EdgeViolated},
+			}}},
+		},
+		"Unverifiable": {
+			reason: "An edge whose dependency is installed by digest should be unverifiable if it constrains the semantic version, and satisfied if it is an alias.",
+			pkgs: []v1beta1.LockPackage{
+				config("cool/config-a", provider(">=v0.1.0")),
+				config("cool/config-b", provider("latest")),
+				aws(digest),
+			},
+			want: want{report: EdgeReport{
+				Satisfied: []EdgeCheck{
+					{Dependent: "cool/config-b", Dependency: "crossplane/provider-aws", Version: digest, Constraint: "latest", Declared: v1beta1.ProviderPackageType, Type: v1beta1.ProviderPackageType, State: EdgeSatisfied},
+				},
+				Unverifiable: []EdgeCheck{
+					{Dependent: "cool/config-a", Dependency: "crossplane/provider-aws", Version: digest, Constraint: ">=v0.1.0", Declared: v1beta1.ProviderPackageType, Type: v1beta1.ProviderPackageType, State: EdgeUnverifiable},
+				},
+			}},
+		},
+		"OptionalMissing": {
+			reason: "Edges to missing optional dependencies should not be checked.",
+			pkgs:   []v1beta1.LockPackage{config("cool/config", v1beta1.Dependency{Package: "crossplane/provider-aws", Type: v1beta1.ProviderPackageType, Constraints: ">=v0.1.0", Optional: true})},
+			want:   want{},
+		},
+		"OptionalViolated": {
+			reason: "An edge to an installed optional dependency should be checked, and marked optional.",
+			pkgs: []v1beta1.LockPackage{
+				config("cool/config", v1beta1.Dependency{Package: "crossplane/provider-aws", Type: v1beta1.ProviderPackageType, Constraints: ">=v0.3.0", Optional: true}),
+				aws("v0.2.0"),
+			},
+			want: want{report: EdgeReport{Violated: []EdgeCheck{
+				{Dependent: "cool/config", Dependency: "crossplane/provider-aws", Version: "v0.2.0", Constraint: ">=v0.3.0", Declared: v1beta1.ProviderPackageType, Type: v1beta1.ProviderPackageType, Optional: true, State: EdgeViolated},
+			}}},
+		},
+		"Chain": {
+			reason: "Edges between installed packages should be checked wherever they are in the graph.",
+			pkgs: []v1beta1.LockPackage{
+				config("cool/config-a", v1beta1.Dependency{Package: "cool/config-b", Type: v1beta1.ConfigurationPackageType, Constraints: "<v1.0.0"}),
+				config("cool/config-b", provider("v0.2.0")),
+				aws("v0.2.0"),
+			},
+			want: want{report: EdgeReport{
+				Satisfied: []EdgeCheck{
+					{Dependent: "cool/config-b", Dependency: "crossplane/provider-aws", Version: "v0.2.0", Constraint: "v0.2.0", Declared: v1beta1.ProviderPackageType, Type: v1beta1.ProviderPackageType, State: EdgeSatisfied},
+				},
+				Violated: []EdgeCheck{
+					{Dependent: "cool/config-a", Dependency: "cool/config-b", Version: "v1.0.0", Constraint: "<v1.0.0", Declared: v1beta1.ConfigurationPackageType, Type: v1beta1.ConfigurationPackageType, State: EdgeViolated},
+				},
+			}},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			d := dag.NewMapDag()
+			if _, err := d.Init(v1beta1.ToNodes(tc.pkgs...)); err != nil {
+				t.Fatalf("\n%s\nInit(...): %s", tc.reason, err)
+			}
+			report, err := CheckEdges(d)
+			if diff := cmp.Diff(tc.want.err, err); diff != "" {
+				t.Errorf("\n%s\nCheckEdges(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.report, report); diff != "" {
+				t.Errorf("\n%s\nCheckEdges(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
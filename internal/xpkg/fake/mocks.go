@@ -22,9 +22,13 @@ import (
 	"github.com/google/go-containerregistry/pkg/name"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+
 	"github.com/crossplane/crossplane/internal/xpkg"
 )
 
+const errNotSeededFmt = "repository %s was not seeded"
+
 var _ xpkg.Cache = &MockCache{}
 
 // MockCache is a mock Cache.
@@ -68,9 +72,10 @@ var _ xpkg.Fetcher = &MockFetcher{}
 
 // MockFetcher is a mock fetcher.
 type MockFetcher struct {
-	MockFetch func() (v1.Image, error)
-	MockHead  func() (*v1.Descriptor, error)
-	MockTags  func() ([]string, error)
+	MockFetch     func() (v1.Image, error)
+	MockHead      func() (*v1.Descriptor, error)
+	MockTags      func() ([]string, error)
+	MockFetchMeta func() (*xpkg.PackageMeta, error)
 }
 
 // NewMockFetchFn creates a new MockFetch function for MockFetcher.
@@ -102,3 +107,58 @@ func NewMockTagsFn(tags []string, err error) func() ([]string, error) {
 func (m *MockFetcher) Tags(ctx context.Context, ref name.Reference, secrets ...string) ([]string, error) {
 	return m.MockTags()
 }
+
+// NewMockFetchMetaFn creates a new MockFetchMeta function for MockFetcher.
+func NewMockFetchMetaFn(meta *xpkg.PackageMeta, err error) func() (*xpkg.PackageMeta, error) {
+	return func() (*xpkg.PackageMeta, error) { return meta, err }
+}
+
+// FetchMeta calls the underlying MockFetchMeta. It returns empty metadata if
+// there is no MockFetchMeta.
+func (m *MockFetcher) FetchMeta(ctx context.Context, ref name.Reference, secrets ...string) (*xpkg.PackageMeta, error) {
+	if m.MockFetchMeta == nil {
+		return &xpkg.PackageMeta{}, nil
+	}
+	return m.MockFetchMeta()
+}
+
+var _ xpkg.Fetcher = &TagFetcher{}
+
+// A TagFetcher fetches the tags of the repositories it was seeded with. It
+// fails to fetch anything else, including images.
+type TagFetcher struct {
+	tags map[string][]string
+}
+
+// NewTagFetcher returns a Fetcher that fetches the supplied tags, keyed by
+// repository, e.g. crossplane/provider-aws. Repositories of the default
+// registry may also be keyed by their fully qualified name, e.g.
+// index.docker.io/crossplane/provider-aws.
+func NewTagFetcher(tags map[string][]string) *TagFetcher {
+	return &TagFetcher{tags: tags}
+}
+
+// Fetch always returns an error.
+func (f *TagFetcher) Fetch(ctx context.Context, ref name.Reference, secrets ...string) (v1.Image, error) {
+	return nil, errors.Errorf(errNotSeededFmt, ref.Context().Name())
+}
+
+// Head always returns an error.
+func (f *TagFetcher) Head(ctx context.Context, ref name.Reference, secrets ...string) (*v1.Descriptor, error) {
+	return nil, errors.Errorf(errNotSeededFmt, ref.Context().Name())
+}
+
+// FetchMeta always returns an error.
+func (f *TagFetcher) FetchMeta(ctx context.Context, ref name.Reference, secrets ...string) (*xpkg.PackageMeta, error) {
+	return nil, errors.Errorf(errNotSeededFmt, ref.Context().Name())
+}
+
+// Tags returns the seeded tags of the supplied reference's repository.
+func (f *TagFetcher) Tags(ctx context.Context, ref name.Reference, secrets ...string) ([]string, error) {
+	for _, repo := range []string{ref.Context().Name(), ref.Context().RepositoryStr()} {
+		if tags, ok := f.tags[repo]; ok {
+			return tags, nil
+		}
+	}
+	return nil, errors.Errorf(errNotSeededFmt, ref.Context().Name())
+}
@@ -18,13 +18,120 @@ package xpkg
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
-	"github.com/google/go-containerregistry/pkg/authn/k8schain"
+	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/name"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/empty"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
 	"k8s.io/client-go/kubernetes"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+)
+
+const (
+	// tagPageSize is the number of tags requested per page. Some registries,
+	// e.g. ECR, return an error if more than 1000 are requested.
+	tagPageSize = 1000
+
+	errParseLinkHeaderFmt = "cannot parse Link header %q"
+	errDecodeTagPage      = "cannot decode page of tags"
+	errReadCABundle       = "cannot read CA bundle"
+	errNoCertificatesFmt  = "no PEM encoded certificates found in %s"
+	errRateLimitedFmt     = "registry rate limited request, retry after %s"
+)
+
+// A FetchErrorReason classifies why the tags of a repository could not be
+// fetched from its registry.
+type FetchErrorReason string
+
+// Fetch error reasons.
+const (
+	// FetchUnauthorized errors indicate that the registry refused our
+	// credentials, or requires credentials we didn't present. Adding pull
+	// secrets for the registry may fix them.
+	FetchUnauthorized FetchErrorReason = "Unauthorized"
+
+	// FetchRateLimited errors indicate that the registry is rate limiting
+	// our requests. Retrying too soon prolongs the limit.
+	FetchRateLimited FetchErrorReason = "RateLimited"
+
+	// FetchUnavailable errors indicate that the registry could not be
+	// reached, or failed to serve our request.
+	FetchUnavailable FetchErrorReason = "Unavailable"
+)
+
+// A RateLimitError indicates that a registry rate limited a request.
+type RateLimitError struct {
+	// RetryAfter is how long the registry asked us to wait before retrying.
+	// Zero if it didn't say.
+	RetryAfter time.Duration
+
+	err error
+}
+
+func (e *RateLimitError) Error() string {
+	if e.RetryAfter <= 0 {
+		return e.err.Error()
+	}
+	return errors.Wrapf(e.err, errRateLimitedFmt, e.RetryAfter).Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *RateLimitError) Unwrap() error {
+	return e.err
+}
+
+// ClassifyFetchError returns why the supplied error prevented the tags of a
+// repository from being fetched. Errors that don't indicate the registry
+// refused our credentials or rate limited us are assumed to indicate that it
+// is unavailable.
+func ClassifyFetchError(err error) FetchErrorReason {
+	var rerr *RateLimitError
+	if errors.As(err, &rerr) {
+		return FetchRateLimited
+	}
+	var terr *transport.Error
+	if errors.As(err, &terr) {
+		switch terr.StatusCode {
+		case http.StatusUnauthorized, http.StatusForbidden:
+			return FetchUnauthorized
+		case http.StatusTooManyRequests:
+			return FetchRateLimited
+		}
+	}
+	return FetchUnavailable
+}
+
+// RetryAfter returns how long the registry that rate limited the request that
+// caused the supplied error asked us to wait before retrying, or zero.
+func RetryAfter(err error) time.Duration {
+	var rerr *RateLimitError
+	if errors.As(err, &rerr) {
+		return rerr.RetryAfter
+	}
+	return 0
+}
+
+// How a K8sFetcher determines which proxy to connect to registries through.
+const (
+	proxyEnvironment = "Environment"
+	proxyCustom      = "Custom"
+	proxyNone        = "None"
 )
 
 // Fetcher fetches package images.
@@ -32,56 +139,469 @@ type Fetcher interface {
 	Fetch(ctx context.Context, ref name.Reference, secrets ...string) (v1.Image, error)
 	Head(ctx context.Context, ref name.Reference, secrets ...string) (*v1.Descriptor, error)
 	Tags(ctx context.Context, ref name.Reference, secrets ...string) ([]string, error)
+	FetchMeta(ctx context.Context, ref name.Reference, secrets ...string) (*PackageMeta, error)
+}
+
+// A TagPageFn is called with each page of tags fetched from a repository. It
+// returns false if no further pages should be fetched.
+type TagPageFn func(tags []string) bool
+
+// A PagedTagFetcher fetches the tags of a package's repository a page at a
+// time, which allows a caller to stop once it has found the tag it needs.
+type PagedTagFetcher interface {
+	TagsPaged(ctx context.Context, ref name.Reference, fn TagPageFn, secrets ...string) error
+}
+
+// PagedTags fetches the tags of the supplied reference's repository a page at
+// a time using the supplied Fetcher. A Fetcher that is not a PagedTagFetcher
+// fetches all tags as a single page.
+func PagedTags(ctx context.Context, f Fetcher, ref name.Reference, fn TagPageFn, secrets ...string) error {
+	if pf, ok := f.(PagedTagFetcher); ok {
+		return pf.TagsPaged(ctx, ref, fn, secrets...)
+	}
+	tags, err := f.Tags(ctx, ref, secrets...)
+	if err != nil {
+		return err
+	}
+	fn(tags)
+	return nil
 }
 
 // K8sFetcher uses kubernetes credentials to fetch package images.
 type K8sFetcher struct {
 	client    kubernetes.Interface
 	namespace string
+
+	// base is the transport used to connect to registries, before any TLS
+	// settings are applied.
+	base      *http.Transport
+	proxy     string
+	timeout   time.Duration
+	rootCAs   *x509.CertPool
+	hostCAs   map[string]*x509.CertPool
+	insecure  map[string]bool
+	userAgent string
+	custom    http.RoundTripper
+	transport http.RoundTripper
+
+	// keychain is the order in which sources of registry credentials are
+	// consulted, and helpers obtain ambient credentials.
+	keychain []KeychainSource
+	helpers  []CredentialHelper
+	log      logging.Logger
+
+	// meta caches package metadata, and creds caches ambient registry
+	// credentials. They are shared by copies of the fetcher.
+	meta  *metaCache
+	creds *credentialCache
+}
+
+// A FetcherOption configures a K8sFetcher.
+type FetcherOption func(f *K8sFetcher)
+
+// WithCustomCA specifies that the K8sFetcher should trust registry
+// certificates signed by the supplied CAs, rather than the system's CAs.
+func WithCustomCA(rootCAs *x509.CertPool) FetcherOption {
+	return func(f *K8sFetcher) {
+		f.rootCAs = rootCAs
+	}
+}
+
+// WithRegistryCA specifies that the K8sFetcher should trust certificates of
+// the supplied registry host, e.g. registry.example.org:5000, that are signed
+// by the supplied CAs. It takes precedence over WithCustomCA for that host.
+func WithRegistryCA(host string, rootCAs *x509.CertPool) FetcherOption {
+	return func(f *K8sFetcher) {
+		f.hostCAs[registryHost(host)] = rootCAs
+	}
+}
+
+// WithInsecureRegistries specifies that the K8sFetcher should connect to the
+// supplied registry hosts using plain HTTP.
+func WithInsecureRegistries(hosts ...string) FetcherOption {
+	return func(f *K8sFetcher) {
+		for _, h := range hosts {
+			f.insecure[registryHost(h)] = true
+		}
+	}
+}
+
+// WithProxy specifies the function the K8sFetcher should use to determine
+// which proxy to connect to a registry through. A nil function connects
+// directly. By default the HTTPS_PROXY, HTTP_PROXY, and NO_PROXY environment
+// variables are honored.
+func WithProxy(proxy func(*http.Request) (*url.URL, error)) FetcherOption {
+	return func(f *K8sFetcher) {
+		f.base = f.base.Clone()
+		f.base.Proxy = proxy
+		f.proxy = proxyCustom
+		if proxy == nil {
+			f.proxy = proxyNone
+		}
+	}
+}
+
+// WithConnectTimeout specifies how long the K8sFetcher should wait to
+// establish a connection to a registry, including its TLS handshake.
+func WithConnectTimeout(d time.Duration) FetcherOption {
+	return func(f *K8sFetcher) {
+		f.base = f.base.Clone()
+		f.base.DialContext = (&net.Dialer{Timeout: d, KeepAlive: 30 * time.Second}).DialContext
+		f.base.TLSHandshakeTimeout = d
+		f.timeout = d
+	}
+}
+
+// WithUserAgent specifies the User-Agent the K8sFetcher should identify
+// itself to registries with.
+func WithUserAgent(ua string) FetcherOption {
+	return func(f *K8sFetcher) {
+		f.userAgent = ua
+	}
+}
+
+// WithRoundTripper specifies the transport the K8sFetcher should use to
+// connect to registries. It replaces the default transport, so options that
+// configure the default transport, e.g. WithProxy and WithCustomCA, have no
+// effect.
+func WithRoundTripper(rt http.RoundTripper) FetcherOption {
+	return func(f *K8sFetcher) {
+		f.custom = rt
+	}
 }
 
 // NewK8sFetcher creates a new K8sFetcher.
-func NewK8sFetcher(client kubernetes.Interface, namespace string) *K8sFetcher {
-	return &K8sFetcher{
+func NewK8sFetcher(client kubernetes.Interface, namespace string, opts ...FetcherOption) *K8sFetcher {
+	base := http.DefaultTransport.(*http.Transport).Clone()
+	base.Proxy = http.ProxyFromEnvironment
+	f := &K8sFetcher{
 		client:    client,
 		namespace: namespace,
+		base:      base,
+		proxy:     proxyEnvironment,
+		keychain:  DefaultKeychainOrder,
+		helpers:   DefaultCredentialHelpers(),
+		log:       logging.NewNopLogger(),
+		meta:      newMetaCache(defaultMetaCacheSize),
+		creds:     newCredentialCache(),
 	}
+	return f.With(opts...)
 }
 
-// Fetch fetches a package image.
-func (i *K8sFetcher) Fetch(ctx context.Context, ref name.Reference, secrets ...string) (v1.Image, error) {
-	auth, err := k8schain.New(ctx, i.client, k8schain.Options{
-		Namespace:        i.namespace,
-		ImagePullSecrets: secrets,
-	})
+// With returns a copy of the K8sFetcher configured with the supplied options.
+func (i *K8sFetcher) With(opts ...FetcherOption) *K8sFetcher {
+	f := &K8sFetcher{
+		client:    i.client,
+		namespace: i.namespace,
+		base:      i.base,
+		proxy:     i.proxy,
+		timeout:   i.timeout,
+		rootCAs:   i.rootCAs,
+		hostCAs:   make(map[string]*x509.CertPool, len(i.hostCAs)),
+		insecure:  make(map[string]bool, len(i.insecure)),
+		userAgent: i.userAgent,
+		custom:    i.custom,
+		keychain:  i.keychain,
+		helpers:   i.helpers,
+		log:       i.log,
+		meta:      i.meta,
+		creds:     i.creds,
+	}
+	for h, p := range i.hostCAs {
+		f.hostCAs[h] = p
+	}
+	for h := range i.insecure {
+		f.insecure[h] = true
+	}
+	for _, o := range opts {
+		o(f)
+	}
+	f.transport = f.newTransport()
+	return f
+}
+
+// newTransport returns a transport that connects to each registry using its
+// TLS settings, unless a custom transport was supplied.
+func (i *K8sFetcher) newTransport() http.RoundTripper {
+	var rt http.RoundTripper = i.custom
+	if rt == nil {
+		t := &registryTransport{base: i.base, hosts: make(map[string]http.RoundTripper, len(i.hostCAs))}
+		if i.rootCAs != nil {
+			t.base = withRootCAs(i.base, i.rootCAs)
+		}
+		for h, p := range i.hostCAs {
+			t.hosts[h] = withRootCAs(i.base, p)
+		}
+		rt = t
+	}
+	if i.userAgent != "" {
+		rt = &userAgentTransport{wrapped: rt, userAgent: i.userAgent}
+	}
+	return rt
+}
+
+// TransportSettings returns the settings the K8sFetcher connects to
+// registries with, as alternating keys and values suitable for logging.
+func (i *K8sFetcher) TransportSettings() []interface{} {
+	timeout := "Default"
+	if i.timeout > 0 {
+		timeout = i.timeout.String()
+	}
+	hosts := make([]string, 0, len(i.hostCAs))
+	for h := range i.hostCAs {
+		hosts = append(hosts, h)
+	}
+	sort.Strings(hosts)
+	insecure := make([]string, 0, len(i.insecure))
+	for h := range i.insecure {
+		insecure = append(insecure, h)
+	}
+	sort.Strings(insecure)
+	keychain := make([]string, len(i.keychain))
+	for j, s := range i.keychain {
+		keychain[j] = string(s)
+	}
+	return []interface{}{
+		"proxy", i.proxy,
+		"connect-timeout", timeout,
+		"user-agent", i.userAgent,
+		"custom-transport", i.custom != nil,
+		"custom-ca", i.rootCAs != nil,
+		"registry-cas", hosts,
+		"insecure-registries", insecure,
+		"keychain", keychain,
+	}
+}
+
+// reference returns the supplied reference, marked insecure if its registry
+// must be connected to using plain HTTP.
+func (i *K8sFetcher) reference(ref name.Reference) name.Reference {
+	if !i.insecure[ref.Context().RegistryStr()] {
+		return ref
+	}
+	r, err := name.ParseReference(ref.Name(), name.Insecure)
 	if err != nil {
-		return nil, err
+		return ref
 	}
-	return remote.Image(ref, remote.WithAuthFromKeychain(auth), remote.WithContext(ctx))
+	return r
+}
+
+// Fetch fetches a package image.
+func (i *K8sFetcher) Fetch(ctx context.Context, ref name.Reference, secrets ...string) (v1.Image, error) {
+	auth := fetcherKeychain{ctx: ctx, fetcher: i, secrets: secrets}
+	return remote.Image(i.reference(ref), remote.WithAuthFromKeychain(auth), remote.WithTransport(i.transport), remote.WithContext(ctx))
 }
 
 // Head fetches a package descriptor.
 func (i *K8sFetcher) Head(ctx context.Context, ref name.Reference, secrets ...string) (*v1.Descriptor, error) {
-	auth, err := k8schain.New(ctx, i.client, k8schain.Options{
-		Namespace:        i.namespace,
-		ImagePullSecrets: secrets,
-	})
+	auth := fetcherKeychain{ctx: ctx, fetcher: i, secrets: secrets}
+	return remote.Head(i.reference(ref), remote.WithAuthFromKeychain(auth), remote.WithTransport(i.transport), remote.WithContext(ctx))
+}
+
+// Tags fetches a package's tags. Tags are listed using the credentials of the
+// first keychain source that has any for the package's registry, and
+// anonymously if the registry refuses them.
+func (i *K8sFetcher) Tags(ctx context.Context, ref name.Reference, secrets ...string) ([]string, error) {
+	repo := i.reference(ref).Context()
+	auth, err := i.authenticator(ctx, repo, secrets...)
 	if err != nil {
 		return nil, err
 	}
-	return remote.Head(ref, remote.WithAuthFromKeychain(auth), remote.WithContext(ctx))
+	tags, err := remote.List(repo, remote.WithAuth(auth), remote.WithTransport(i.transport), remote.WithContext(ctx))
+	if auth == authn.Anonymous || !i.anonymous() || ClassifyFetchError(err) != FetchUnauthorized {
+		return tags, err
+	}
+	return remote.List(repo, remote.WithAuth(authn.Anonymous), remote.WithTransport(i.transport), remote.WithContext(ctx))
 }
 
-// Tags fetches a package's tags.
-func (i *K8sFetcher) Tags(ctx context.Context, ref name.Reference, secrets ...string) ([]string, error) {
-	auth, err := k8schain.New(ctx, i.client, k8schain.Options{
-		Namespace:        i.namespace,
-		ImagePullSecrets: secrets,
+// TagsPaged fetches a package's tags a page at a time, calling the supplied
+// function with each page until it returns false or there are no more pages.
+// Like Tags, it falls back to listing tags anonymously if the registry
+// refuses our credentials before returning any tags.
+func (i *K8sFetcher) TagsPaged(ctx context.Context, ref name.Reference, fn TagPageFn, secrets ...string) error {
+	repo := i.reference(ref).Context()
+	auth, err := i.authenticator(ctx, repo, secrets...)
+	if err != nil {
+		return err
+	}
+	listed := false
+	err = i.listTags(ctx, repo, auth, func(tags []string) bool {
+		listed = true
+		return fn(tags)
 	})
+	if listed || auth == authn.Anonymous || !i.anonymous() || ClassifyFetchError(err) != FetchUnauthorized {
+		return err
+	}
+	return i.listTags(ctx, repo, authn.Anonymous, fn)
+}
+
+// listTags lists the tags of the supplied repository a page at a time using
+// the supplied credentials.
+func (i *K8sFetcher) listTags(ctx context.Context, repo name.Repository, auth authn.Authenticator, fn TagPageFn) error {
+	tr, err := transport.NewWithContext(ctx, repo.Registry, auth, i.transport, []string{repo.Scope(transport.PullScope)})
 	if err != nil {
-		return nil, err
+		return err
+	}
+	return listTags(ctx, &http.Client{Transport: tr}, repo, tagPageSize, fn)
+}
+
+// A registryTransport connects to each registry host using the transport
+// configured for that host, if any, or its base transport otherwise. Note
+// that a registry's token service may be served by a different host.
+type registryTransport struct {
+	base  http.RoundTripper
+	hosts map[string]http.RoundTripper
+}
+
+// RoundTrip executes the supplied request using the transport configured for
+// its host.
+func (t *registryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if rt, ok := t.hosts[req.URL.Host]; ok {
+		return rt.RoundTrip(req)
+	}
+	return t.base.RoundTrip(req)
+}
+
+// A userAgentTransport identifies itself to registries using its User-Agent.
+type userAgentTransport struct {
+	wrapped   http.RoundTripper
+	userAgent string
+}
+
+// RoundTrip executes a copy of the supplied request with its User-Agent set.
+func (t *userAgentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("User-Agent", t.userAgent)
+	return t.wrapped.RoundTrip(req)
+}
+
+// withRootCAs returns a copy of the supplied transport that trusts
+// certificates signed by the supplied CAs.
+func withRootCAs(t *http.Transport, rootCAs *x509.CertPool) *http.Transport {
+	c := t.Clone()
+	if c.TLSClientConfig == nil {
+		c.TLSClientConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+	c.TLSClientConfig.RootCAs = rootCAs
+	return c
+}
+
+// registryHost returns the canonical form of the supplied registry host, e.g.
+// index.docker.io for docker.io.
+func registryHost(host string) string {
+	r, err := name.NewRegistry(host)
+	if err != nil {
+		return host
+	}
+	return r.RegistryStr()
+}
+
+// ParseCertificatesFromPath returns a pool of the system's CAs, and the PEM
+// encoded CAs read from the supplied path.
+func ParseCertificatesFromPath(path string) (*x509.CertPool, error) {
+	pem, err := ioutil.ReadFile(path) // nolint:gosec
+	if err != nil {
+		return nil, errors.Wrap(err, errReadCABundle)
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
 	}
-	return remote.List(ref.Context(), remote.WithAuthFromKeychain(auth), remote.WithContext(ctx))
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, errors.Errorf(errNoCertificatesFmt, path)
+	}
+	return pool, nil
+}
+
+// listTags lists the tags of the supplied repository a page at a time,
+// following the Link header of each response to the next page.
+func listTags(ctx context.Context, c *http.Client, repo name.Repository, n int, fn TagPageFn) error {
+	uri := &url.URL{
+		Scheme:   repo.Registry.Scheme(),
+		Host:     repo.RegistryStr(),
+		Path:     fmt.Sprintf("/v2/%s/tags/list", repo.RepositoryStr()),
+		RawQuery: fmt.Sprintf("n=%d", n),
+	}
+	for uri != nil {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri.String(), nil)
+		if err != nil {
+			return err
+		}
+		resp, err := c.Do(req)
+		if err != nil {
+			return err
+		}
+		var tags []string
+		tags, uri, err = readTagPage(resp)
+		if err != nil {
+			return err
+		}
+		if !fn(tags) {
+			return nil
+		}
+	}
+	return nil
+}
+
+// readTagPage reads a page of tags from the supplied response, and returns
+// the URL of the next page, if any.
+func readTagPage(resp *http.Response) ([]string, *url.URL, error) {
+	defer resp.Body.Close() // nolint:errcheck
+	if err := transport.CheckError(resp, http.StatusOK); err != nil {
+		if resp.StatusCode == http.StatusTooManyRequests {
+			return nil, nil, &RateLimitError{RetryAfter: retryAfter(resp.Header.Get("Retry-After"), time.Now()), err: err}
+		}
+		return nil, nil, err
+	}
+	page := struct {
+		Tags []string `json:"tags"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+		return nil, nil, errors.Wrap(err, errDecodeTagPage)
+	}
+	next, err := nextPage(resp)
+	return page.Tags, next, err
+}
+
+// nextPage returns the URL of the next page linked to by the supplied
+// response, e.g. Link: </v2/repo/tags/list?n=1000&last=v1.0.0>; rel="next".
+func nextPage(resp *http.Response) (*url.URL, error) {
+	link := resp.Header.Get("Link")
+	if link == "" {
+		return nil, nil
+	}
+	start, end := strings.Index(link, "<"), strings.Index(link, ">")
+	if start != 0 || end < 0 {
+		return nil, errors.Errorf(errParseLinkHeaderFmt, link)
+	}
+	u, err := url.Parse(link[1:end])
+	if err != nil {
+		return nil, errors.Wrapf(err, errParseLinkHeaderFmt, link)
+	}
+	if resp.Request == nil || resp.Request.URL == nil {
+		return u, nil
+	}
+	return resp.Request.URL.ResolveReference(u), nil
+}
+
+// retryAfter parses the supplied Retry-After header, which is either a number
+// of seconds or an HTTP date, relative to the supplied time. It returns zero
+// if the header is empty, invalid, or in the past.
+func retryAfter(header string, now time.Time) time.Duration {
+	if header == "" {
+		return 0
+	}
+	if s, err := strconv.Atoi(header); err == nil {
+		if s < 0 {
+			return 0
+		}
+		return time.Duration(s) * time.Second
+	}
+	t, err := http.ParseTime(header)
+	if err != nil || !t.After(now) {
+		return 0
+	}
+	return t.Sub(now)
 }
 
 // NopFetcher always returns an empty image and never returns error.
@@ -106,3 +626,8 @@ func (n *NopFetcher) Head(ctx context.Context, ref name.Reference, secrets ...st
 func (n *NopFetcher) Tags(ctx context.Context, ref name.Reference, secrets ...string) ([]string, error) {
 	return nil, nil
 }
+
+// FetchMeta returns empty metadata and does not return error.
+func (n *NopFetcher) FetchMeta(ctx context.Context, ref name.Reference, secrets ...string) (*PackageMeta, error) {
+	return &PackageMeta{}, nil
+}
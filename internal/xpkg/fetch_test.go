@@ -0,0 +1,596 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package xpkg
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-containerregistry/pkg/name"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kfake "k8s.io/client-go/kubernetes/fake"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+)
+
+// registry serves the supplied tags a page at a time, in the style of the
+// OCI distribution tag listing API, and counts the pages it serves.
+func registry(tags []string, link func(next int) string, pages *int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*pages++
+		n, _ := strconv.Atoi(r.URL.Query().Get("n"))
+		start, _ := strconv.Atoi(r.URL.Query().Get("last"))
+		end := start + n
+		if end < len(tags) {
+			w.Header().Set("Link", link(end))
+		} else {
+			end = len(tags)
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"name": "repo", "tags": tags[start:end]})
+	}))
+}
+
+func TestListTags(t *testing.T) {
+	// A repository with many nightly builds.
+	many := make([]string, 10000)
+	for i := range many {
+		many[i] = fmt.Sprintf("v0.%d.0", i)
+	}
+	next := func(end int) string { return fmt.Sprintf("</v2/repo/tags/list?n=1000&last=%d>; rel=\"next\"", end) }
+
+	type want struct {
+		pages   int
+		tags    int
+		maxPage int
+		err     error
+	}
+
+	cases := map[string]struct {
+		reason string
+		tags   []string
+		link   func(end int) string
+		stop   func(fetched int) bool
+		want   want
+	}{
+		"AllPages": {
+			reason: "We should fetch every page of a large repository, one bounded page at a time.",
+			tags:   many,
+			link:   next,
+			stop:   func(int) bool { return false },
+			want:   want{pages: 10, tags: 10000, maxPage: 1000},
+		},
+		"StopEarly": {
+			reason: "We should stop fetching pages as soon as the caller has what it needs.",
+			tags:   many,
+			link:   next,
+			stop:   func(fetched int) bool { return fetched >= 2000 },
+			want:   want{pages: 2, tags: 2000, maxPage: 1000},
+		},
+		"SinglePage": {
+			reason: "We should fetch a single page if there is no Link header.",
+			tags:   many[:10],
+			link:   next,
+			stop:   func(int) bool { return false },
+			want:   want{pages: 1, tags: 10, maxPage: 10},
+		},
+		"InvalidLink": {
+			reason: "We should return an error if we cannot parse the link to the next page.",
+			tags:   many,
+			link:   func(int) string { return "nope" },
+			stop:   func(int) bool { return false },
+			want:   want{pages: 1, err: errors.Errorf(errParseLinkHeaderFmt, "nope")},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			pages := 0
+			srv := registry(tc.tags, tc.link, &pages)
+			defer srv.Close()
+
+			repo, _ := newRepository(srv.URL)
+			fetched, maxPage := 0, 0
+			err := listTags(context.Background(), srv.Client(), repo, 1000, func(tags []string) bool {
+				fetched += len(tags)
+				if len(tags) > maxPage {
+					maxPage = len(tags)
+				}
+				return !tc.stop(fetched)
+			})
+
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nlistTags(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.pages, pages); diff != "" {
+				t.Errorf("\n%s\nlistTags(...): -want pages, +got pages:\n%s", tc.reason, diff)
+			}
+			if tc.want.err != nil {
+				return
+			}
+			if diff := cmp.Diff(tc.want.tags, fetched); diff != "" {
+				t.Errorf("\n%s\nlistTags(...): -want tags, +got tags:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.maxPage, maxPage); diff != "" {
+				t.Errorf("\n%s\nlistTags(...): -want largest page, +got largest page:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestPagedTags(t *testing.T) {
+	errBoom := errors.New("boom")
+
+	type want struct {
+		pages [][]string
+		err   error
+	}
+
+	cases := map[string]struct {
+		reason  string
+		fetcher Fetcher
+		want    want
+	}{
+		"NotPaged": {
+			reason:  "We should return all tags as a single page if the Fetcher cannot fetch pages.",
+			fetcher: &mockTagsFetcher{tags: []string{"v1.0.0", "v1.1.0"}},
+			want:    want{pages: [][]string{{"v1.0.0", "v1.1.0"}}},
+		},
+		"NotPagedError": {
+			reason:  "We should return any error fetching tags.",
+			fetcher: &mockTagsFetcher{err: errBoom},
+			want:    want{err: errBoom},
+		},
+		"Nop": {
+			reason:  "We should support the NopFetcher.",
+			fetcher: NewNopFetcher(),
+			want:    want{pages: [][]string{nil}},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var pages [][]string
+			err := PagedTags(context.Background(), tc.fetcher, name2ref("crossplane/provider-aws"), func(tags []string) bool {
+				pages = append(pages, tags)
+				return true
+			})
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nPagedTags(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.pages, pages); diff != "" {
+				t.Errorf("\n%s\nPagedTags(...): -want pages, +got pages:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestK8sFetcherRegistryTLS(t *testing.T) {
+	tags := []string{"v1.0.0", "v1.1.0"}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v2/" {
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"name": "repo", "tags": tags})
+	})
+
+	// The TLS server's certificate is signed by its own CA, and is valid
+	// for example.com. We pretend it serves example.com by dialing it
+	// regardless of the address the fetcher connects to.
+	tlsSrv := httptest.NewTLSServer(handler)
+	defer tlsSrv.Close()
+	ca := x509.NewCertPool()
+	ca.AddCert(tlsSrv.Certificate())
+	httpSrv := httptest.NewServer(handler)
+	defer httpSrv.Close()
+
+	dial := func(srv *httptest.Server) FetcherOption {
+		return func(f *K8sFetcher) {
+			t := http.DefaultTransport.(*http.Transport).Clone()
+			t.DialContext = func(ctx context.Context, network, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, network, srv.Listener.Addr().String())
+			}
+			f.base = t
+		}
+	}
+
+	type want struct {
+		tags []string
+		err  bool
+	}
+
+	cases := map[string]struct {
+		reason string
+		opts   []FetcherOption
+		want   want
+	}{
+		"UntrustedCA": {
+			reason: "We should fail to list tags if we don't trust the registry's CA.",
+			opts:   []FetcherOption{dial(tlsSrv)},
+			want:   want{err: true},
+		},
+		"CustomCA": {
+			reason: "We should list tags from a registry whose certificate is signed by a custom CA.",
+			opts:   []FetcherOption{dial(tlsSrv), WithCustomCA(ca)},
+			want:   want{tags: tags},
+		},
+		"RegistryCA": {
+			reason: "We should list tags from a registry whose certificate is signed by a CA trusted for that registry.",
+			opts:   []FetcherOption{dial(tlsSrv), WithRegistryCA("example.com", ca)},
+			want:   want{tags: tags},
+		},
+		"OtherRegistryCA": {
+			reason: "We should not trust a CA for a registry other than the one it was configured for.",
+			opts:   []FetcherOption{dial(tlsSrv), WithRegistryCA("registry.example.org", ca)},
+			want:   want{err: true},
+		},
+		"Insecure": {
+			reason: "We should list tags from an insecure registry using plain HTTP.",
+			opts:   []FetcherOption{dial(httpSrv), WithInsecureRegistries("example.com")},
+			want:   want{tags: tags},
+		},
+		"NotInsecure": {
+			reason: "We should not use plain HTTP for registries that are not insecure.",
+			opts:   []FetcherOption{dial(httpSrv), WithInsecureRegistries("registry.example.org")},
+			want:   want{err: true},
+		},
+	}
+
+	client := kfake.NewSimpleClientset(&corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Namespace: "crossplane-system", Name: "default"}})
+	ref := name2ref("example.com/repo:v1.0.0")
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			f := NewK8sFetcher(client, "crossplane-system", tc.opts...)
+
+			got, err := f.Tags(context.Background(), ref)
+			if diff := cmp.Diff(tc.want.err, err != nil); diff != "" {
+				t.Errorf("\n%s\nf.Tags(...): -want error, +got error:\n%s\n%v", tc.reason, diff, err)
+			}
+			if diff := cmp.Diff(tc.want.tags, got); diff != "" {
+				t.Errorf("\n%s\nf.Tags(...): -want, +got:\n%s", tc.reason, diff)
+			}
+
+			var paged []string
+			err = f.TagsPaged(context.Background(), ref, func(tags []string) bool {
+				paged = append(paged, tags...)
+				return true
+			})
+			if diff := cmp.Diff(tc.want.err, err != nil); diff != "" {
+				t.Errorf("\n%s\nf.TagsPaged(...): -want error, +got error:\n%s\n%v", tc.reason, diff, err)
+			}
+			if diff := cmp.Diff(tc.want.tags, paged); diff != "" {
+				t.Errorf("\n%s\nf.TagsPaged(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+// A recordingTransport records the requests it executes using its wrapped
+// transport.
+type recordingTransport struct {
+	wrapped  http.RoundTripper
+	requests []*http.Request
+}
+
+func (t *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.requests = append(t.requests, req)
+	return t.wrapped.RoundTrip(req)
+}
+
+func TestK8sFetcherTransport(t *testing.T) {
+	tags := []string{"v1.0.0", "v1.1.0"}
+
+	// The registry serves its tags, or proxies requests for them.
+	var proxied []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.IsAbs() {
+			proxied = append(proxied, r.URL.Host)
+		}
+		if r.URL.Path == "/v2/" {
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"name": "repo", "tags": tags})
+	}))
+	defer srv.Close()
+	host := strings.TrimPrefix(srv.URL, "http://")
+	proxy, _ := url.Parse(srv.URL)
+
+	type args struct {
+		ref  string
+		opts []FetcherOption
+	}
+	type want struct {
+		userAgent string
+		proxied   []string
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"UserAgent": {
+			reason: "We should identify ourselves to the registry using the configured User-Agent.",
+			args: args{
+				ref:  host + "/repo:v1.0.0",
+				opts: []FetcherOption{WithInsecureRegistries(host), WithUserAgent("crossplane-resolver/v1.5.0")},
+			},
+			want: want{userAgent: "crossplane-resolver/v1.5.0"},
+		},
+		"Proxy": {
+			reason: "We should connect to the registry through the configured proxy.",
+			args: args{
+				ref:  "registry.example.org/repo:v1.0.0",
+				opts: []FetcherOption{WithInsecureRegistries("registry.example.org"), WithProxy(http.ProxyURL(proxy)), WithUserAgent("crossplane-resolver/v1.5.0")},
+			},
+			want: want{
+				userAgent: "crossplane-resolver/v1.5.0",
+				proxied:   []string{"registry.example.org", "registry.example.org"},
+			},
+		},
+		"NoProxy": {
+			reason: "We should connect to the registry directly if no proxy is configured.",
+			args: args{
+				ref:  host + "/repo:v1.0.0",
+				opts: []FetcherOption{WithInsecureRegistries(host), WithProxy(nil), WithUserAgent("crossplane-resolver/v1.5.0")},
+			},
+			want: want{userAgent: "crossplane-resolver/v1.5.0"},
+		},
+	}
+
+	client := kfake.NewSimpleClientset(&corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Namespace: "crossplane-system", Name: "default"}})
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			proxied = nil
+
+			// We record the requests that reach the transport the
+			// fetcher's options configured.
+			f := NewK8sFetcher(client, "crossplane-system", tc.args.opts...)
+			rec := &recordingTransport{wrapped: f.transport.(*userAgentTransport).wrapped}
+			f.transport.(*userAgentTransport).wrapped = rec
+
+			got, err := f.Tags(context.Background(), name2ref(tc.args.ref))
+			if err != nil {
+				t.Fatalf("\n%s\nf.Tags(...): %s", tc.reason, err)
+			}
+			if diff := cmp.Diff(tags, got); diff != "" {
+				t.Errorf("\n%s\nf.Tags(...): -want, +got:\n%s", tc.reason, diff)
+			}
+			if len(rec.requests) == 0 {
+				t.Fatalf("\n%s\nf.Tags(...): want requests recorded, got none", tc.reason)
+			}
+			for _, r := range rec.requests {
+				if diff := cmp.Diff(tc.want.userAgent, r.Header.Get("User-Agent")); diff != "" {
+					t.Errorf("\n%s\nf.Tags(...): -want User-Agent, +got User-Agent:\n%s", tc.reason, diff)
+				}
+			}
+			if diff := cmp.Diff(tc.want.proxied, proxied); diff != "" {
+				t.Errorf("\n%s\nf.Tags(...): -want proxied hosts, +got proxied hosts:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestK8sFetcherRoundTripper(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v2/" {
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"name": "repo", "tags": []string{"v1.0.0"}})
+	}))
+	defer srv.Close()
+	host := strings.TrimPrefix(srv.URL, "http://")
+
+	rec := &recordingTransport{wrapped: http.DefaultTransport}
+	client := kfake.NewSimpleClientset(&corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Namespace: "crossplane-system", Name: "default"}})
+	f := NewK8sFetcher(client, "crossplane-system", WithInsecureRegistries(host), WithRoundTripper(rec), WithUserAgent("crossplane-resolver"))
+
+	var got []string
+	err := f.TagsPaged(context.Background(), name2ref(host+"/repo:v1.0.0"), func(tags []string) bool {
+		got = append(got, tags...)
+		return true
+	})
+	if err != nil {
+		t.Fatalf("f.TagsPaged(...): %s", err)
+	}
+	if diff := cmp.Diff([]string{"v1.0.0"}, got); diff != "" {
+		t.Errorf("f.TagsPaged(...): -want, +got:\n%s", diff)
+	}
+	if len(rec.requests) == 0 {
+		t.Fatal("f.TagsPaged(...): want requests executed by the custom transport, got none")
+	}
+	for _, r := range rec.requests {
+		if diff := cmp.Diff("crossplane-resolver", r.Header.Get("User-Agent")); diff != "" {
+			t.Errorf("f.TagsPaged(...): -want User-Agent, +got User-Agent:\n%s", diff)
+		}
+	}
+}
+
+type mockTagsFetcher struct {
+	NopFetcher
+	tags []string
+	err  error
+}
+
+func (f *mockTagsFetcher) Tags(_ context.Context, _ name.Reference, _ ...string) ([]string, error) {
+	return f.tags, f.err
+}
+
+func newRepository(url string) (name.Repository, error) {
+	return name.NewRepository(strings.TrimPrefix(url, "http://")+"/repo", name.Insecure)
+}
+
+func TestK8sFetcherTagsFailures(t *testing.T) {
+	// The registry asks for basic auth credentials, then serves tags, or
+	// fails, depending on the case.
+	var handle func(w http.ResponseWriter, r *http.Request)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v2/" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="registry"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		handle(w, r)
+	}))
+	defer srv.Close()
+	host := strings.TrimPrefix(srv.URL, "http://")
+
+	ns := "crossplane-system"
+	creds := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: "creds"},
+		Type:       corev1.SecretTypeDockerConfigJson,
+		Data: map[string][]byte{
+			corev1.DockerConfigJsonKey: []byte(fmt.Sprintf(`{"auths":{%q:{"username":"cool","password":"expired"}}}`, host)),
+		},
+	}
+	client := kfake.NewSimpleClientset(&corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: "default"}}, creds)
+
+	type want struct {
+		tags       []string
+		reason     FetchErrorReason
+		retryAfter time.Duration
+	}
+
+	cases := map[string]struct {
+		reason  string
+		secrets []string
+		handle  func(w http.ResponseWriter, r *http.Request)
+		want    want
+	}{
+		"Unauthorized": {
+			reason: "We should classify a registry that refuses to list tags anonymously as unauthorized.",
+			handle: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusUnauthorized)
+			},
+			want: want{reason: FetchUnauthorized},
+		},
+		"AnonymousFallback": {
+			reason:  "We should list tags anonymously if the registry refuses our credentials.",
+			secrets: []string{"creds"},
+			handle: func(w http.ResponseWriter, r *http.Request) {
+				if _, _, ok := r.BasicAuth(); ok {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				_ = json.NewEncoder(w).Encode(map[string]interface{}{"name": "repo", "tags": []string{"v1.0.0"}})
+			},
+			want: want{tags: []string{"v1.0.0"}},
+		},
+		"RateLimited": {
+			reason: "We should classify a registry that rate limits us as such, and honor its Retry-After header.",
+			handle: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Retry-After", "30")
+				w.WriteHeader(http.StatusTooManyRequests)
+			},
+			want: want{reason: FetchRateLimited, retryAfter: 30 * time.Second},
+		},
+		"Unavailable": {
+			reason: "We should classify a registry that fails to serve tags as unavailable.",
+			handle: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusInternalServerError)
+			},
+			want: want{reason: FetchUnavailable},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			handle = tc.handle
+			f := NewK8sFetcher(client, ns, WithInsecureRegistries(host))
+
+			var got []string
+			err := f.TagsPaged(context.Background(), name2ref(host+"/repo:v1.0.0"), func(tags []string) bool {
+				got = append(got, tags...)
+				return true
+			}, tc.secrets...)
+			if diff := cmp.Diff(tc.want.tags, got); diff != "" {
+				t.Errorf("\n%s\nf.TagsPaged(...): -want tags, +got tags:\n%s", tc.reason, diff)
+			}
+			if err == nil {
+				if tc.want.reason != "" {
+					t.Errorf("\n%s\nf.TagsPaged(...): want error, got nil", tc.reason)
+				}
+				return
+			}
+			if diff := cmp.Diff(tc.want.reason, ClassifyFetchError(err)); diff != "" {
+				t.Errorf("\n%s\nClassifyFetchError(...): -want, +got:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.retryAfter, RetryAfter(err)); diff != "" {
+				t.Errorf("\n%s\nRetryAfter(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	cases := map[string]struct {
+		reason string
+		header string
+		want   time.Duration
+	}{
+		"Empty": {
+			reason: "We should not wait if the registry doesn't say how long to wait.",
+		},
+		"Seconds": {
+			reason: "We should wait the number of seconds the registry asks for.",
+			header: "120",
+			want:   2 * time.Minute,
+		},
+		"Date": {
+			reason: "We should wait until the date the registry asks for.",
+			header: now.Add(90 * time.Second).Format(http.TimeFormat),
+			want:   90 * time.Second,
+		},
+		"PastDate": {
+			reason: "We should not wait if the date the registry asks for has passed.",
+			header: now.Add(-time.Minute).Format(http.TimeFormat),
+		},
+		"Invalid": {
+			reason: "We should not wait if we can't parse the header.",
+			header: "soon",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if diff := cmp.Diff(tc.want, retryAfter(tc.header, now)); diff != "" {
+				t.Errorf("\n%s\nretryAfter(%q): -want, +got:\n%s", tc.reason, tc.header, diff)
+			}
+		})
+	}
+}
+
+func name2ref(s string) name.Reference {
+	ref, _ := name.ParseReference(s)
+	return ref
+}
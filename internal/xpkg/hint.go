@@ -0,0 +1,169 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package xpkg
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	hintAllowsNoneFmt  = "%q allows no available version"
+	hintAllowsOneFmt   = "%q allows only %s"
+	hintAllowsRangeFmt = "%q allows %s to %s"
+	hintOutlierFmt     = "%s is the outlier; changing its constraint to allow %s would be compatible with every other package that depends on it"
+	hintOutliersFmt    = "changing the constraint of %s would be compatible with every other package that depends on it"
+	hintChangeFmt      = "%s to allow %s"
+)
+
+// A ConstraintBounds is the range of available versions a constraint allows.
+type ConstraintBounds struct {
+	// Constraint a package places on a dependency.
+	Constraint string
+
+	// Lowest and Highest available versions the constraint allows. Both
+	// are empty if it allows none.
+	Lowest  string
+	Highest string
+}
+
+func (b ConstraintBounds) String() string {
+	switch {
+	case b.Lowest == "":
+		return fmt.Sprintf(hintAllowsNoneFmt, b.Constraint)
+	case b.Lowest == b.Highest:
+		return fmt.Sprintf(hintAllowsOneFmt, b.Constraint, b.Lowest)
+	}
+	return fmt.Sprintf(hintAllowsRangeFmt, b.Constraint, b.Lowest, b.Highest)
+}
+
+// An Outlier is a package whose constraint on a dependency excludes every
+// available version that satisfies the other packages that depend on it.
+type Outlier struct {
+	// Parent is the package whose constraint is the outlier.
+	Parent string
+
+	// Version that would be selected if the parent's constraint allowed it.
+	Version string
+}
+
+// A ConflictHint suggests how to resolve a conflict between the constraints
+// packages place on a dependency, when no available version satisfies all
+// of them.
+type ConflictHint struct {
+	// Bounds of the available versions each distinct constraint allows, in
+	// the order they were required.
+	Bounds []ConstraintBounds
+
+	// Outliers are the packages whose constraint alone prevents a version
+	// from being selected, in the order they were required. When there are
+	// only two constraints each of them is an outlier.
+	Outliers []Outlier
+}
+
+// String describes the available versions each constraint allows, and which
+// packages could change their constraint to resolve the conflict.
+func (h *ConflictHint) String() string {
+	parts := make([]string, 0, len(h.Bounds)+1)
+	for _, b := range h.Bounds {
+		parts = append(parts, b.String())
+	}
+	s := strings.Join(parts, ", ")
+
+	switch len(h.Outliers) {
+	case 0:
+		return s
+	case 1:
+		return s + "; " + fmt.Sprintf(hintOutlierFmt, h.Outliers[0].Parent, h.Outliers[0].Version)
+	}
+	changes := make([]string, len(h.Outliers))
+	for i, o := range h.Outliers {
+		changes[i] = fmt.Sprintf(hintChangeFmt, o.Parent, o.Version)
+	}
+	return s + "; " + fmt.Sprintf(hintOutliersFmt, strings.Join(changes, ", or of "))
+}
+
+// AnalyzeConflict returns a hint describing how to resolve a conflict between
+// the supplied requirements. Each set of tags must be those that satisfy the
+// requirement at the same index alone, sorted from lowest to highest as
+// returned by VersionSelector.Satisfying. An outlier's suggested version is
+// selected from the tags that satisfy every other requirement using the
+// supplied policy.
+func AnalyzeConflict(reqs []Requirement, sets [][]string, policy VersionSelection) *ConflictHint {
+	h := &ConflictHint{}
+
+	seen := map[string]bool{}
+	for i, rq := range reqs {
+		if seen[rq.Constraint] {
+			continue
+		}
+		seen[rq.Constraint] = true
+		b := ConstraintBounds{Constraint: rq.Constraint}
+		if len(sets[i]) > 0 {
+			b.Lowest, b.Highest = sets[i][0], sets[i][len(sets[i])-1]
+		}
+		h.Bounds = append(h.Bounds, b)
+	}
+
+	// A requirement is an outlier if the tags that satisfy every other
+	// requirement have something in common.
+	if len(reqs) < 2 {
+		return h
+	}
+	for i, rq := range reqs {
+		others := make([][]string, 0, len(sets)-1)
+		others = append(others, sets[:i]...)
+		others = append(others, sets[i+1:]...)
+		common := intersect(others...)
+		if len(common) == 0 {
+			continue
+		}
+		v := common[len(common)-1]
+		if policy == VersionSelectionLowest {
+			v = common[0]
+		}
+		parent := rq.Parent
+		if parent == "" {
+			parent = fmt.Sprintf("the package that requires %q", rq.Constraint)
+		}
+		h.Outliers = append(h.Outliers, Outlier{Parent: parent, Version: v})
+	}
+	return h
+}
+
+// intersect returns the strings that appear in every one of the supplied
+// sets, in the order they appear in the first set.
+func intersect(sets ...[]string) []string {
+	if len(sets) == 0 {
+		return nil
+	}
+	common := sets[0]
+	for _, set := range sets[1:] {
+		in := make(map[string]bool, len(set))
+		for _, t := range set {
+			in[t] = true
+		}
+		kept := []string{}
+		for _, t := range common {
+			if in[t] {
+				kept = append(kept, t)
+			}
+		}
+		common = kept
+	}
+	return common
+}
@@ -0,0 +1,219 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package xpkg
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestAnalyzeConflict(t *testing.T) {
+	type args struct {
+		reqs   []Requirement
+		sets   [][]string
+		policy VersionSelection
+	}
+	type want struct {
+		hint *ConflictHint
+		msg  string
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"TwoConstraints": {
+			reason: "Each of two conflicting constraints is an outlier, since changing either would resolve the conflict.",
+			args: args{
+				reqs: []Requirement{
+					{Parent: "cool/config-a", Constraint: "<v0.2.0"},
+					{Parent: "cool/config-b", Constraint: ">=v0.3.0"},
+				},
+				sets: [][]string{{"v0.1.0"}, {"v0.3.0", "v0.4.0"}},
+			},
+			want: want{
+				hint: &ConflictHint{
+					Bounds: []ConstraintBounds{
+						{Constraint: "<v0.2.0", Lowest: "v0.1.0", Highest: "v0.1.0"},
+						{Constraint: ">=v0.3.0", Lowest: "v0.3.0", Highest: "v0.4.0"},
+					},
+					Outliers: []Outlier{
+						{Parent: "cool/config-a", Version: "v0.4.0"},
+						{Parent: "cool/config-b", Version: "v0.1.0"},
+					},
+				},
+				msg: `"<v0.2.0" allows only v0.1.0, ">=v0.3.0" allows v0.3.0 to v0.4.0; changing the constraint of cool/config-a to allow v0.4.0, or of cool/config-b to allow v0.1.0 would be compatible with every other package that depends on it`,
+			},
+		},
+		"SingleOutlier": {
+			reason: "We should identify the one constraint that excludes a version every other constraint allows.",
+			args: args{
+				reqs: []Requirement{
+					{Parent: "cool/config-a", Constraint: ">=v0.2.0, <v0.4.0"},
+					{Parent: "cool/config-b", Constraint: "<v0.4.0"},
+					{Parent: "cool/config-c", Constraint: ">=v0.4.0"},
+				},
+				sets: [][]string{{"v0.2.0", "v0.3.0"}, {"v0.1.0", "v0.2.0", "v0.3.0"}, {"v0.4.0"}},
+			},
+			want: want{
+				hint: &ConflictHint{
+					Bounds: []ConstraintBounds{
+						{Constraint: ">=v0.2.0, <v0.4.0", Lowest: "v0.2.0", Highest: "v0.3.0"},
+						{Constraint: "<v0.4.0", Lowest: "v0.1.0", Highest: "v0.3.0"},
+						{Constraint: ">=v0.4.0", Lowest: "v0.4.0", Highest: "v0.4.0"},
+					},
+					Outliers: []Outlier{
+						{Parent: "cool/config-c", Version: "v0.3.0"},
+					},
+				},
+				msg: `">=v0.2.0, <v0.4.0" allows v0.2.0 to v0.3.0, "<v0.4.0" allows v0.1.0 to v0.3.0, ">=v0.4.0" allows only v0.4.0; cool/config-c is the outlier; changing its constraint to allow v0.3.0 would be compatible with every other package that depends on it`,
+			},
+		},
+		"SingleOutlierLowest": {
+			reason: "We should suggest the lowest version every other constraint allows if the lowest version is selected.",
+			args: args{
+				reqs: []Requirement{
+					{Parent: "cool/config-a", Constraint: ">=v0.2.0, <v0.4.0"},
+					{Parent: "cool/config-b", Constraint: "<v0.4.0"},
+					{Parent: "cool/config-c", Constraint: ">=v0.4.0"},
+				},
+				sets:   [][]string{{"v0.2.0", "v0.3.0"}, {"v0.1.0", "v0.2.0", "v0.3.0"}, {"v0.4.0"}},
+				policy: VersionSelectionLowest,
+			},
+			want: want{
+				hint: &ConflictHint{
+					Bounds: []ConstraintBounds{
+						{Constraint: ">=v0.2.0, <v0.4.0", Lowest: "v0.2.0", Highest: "v0.3.0"},
+						{Constraint: "<v0.4.0", Lowest: "v0.1.0", Highest: "v0.3.0"},
+						{Constraint: ">=v0.4.0", Lowest: "v0.4.0", Highest: "v0.4.0"},
+					},
+					Outliers: []Outlier{
+						{Parent: "cool/config-c", Version: "v0.2.0"},
+					},
+				},
+				msg: `">=v0.2.0, <v0.4.0" allows v0.2.0 to v0.3.0, "<v0.4.0" allows v0.1.0 to v0.3.0, ">=v0.4.0" allows only v0.4.0; cool/config-c is the outlier; changing its constraint to allow v0.2.0 would be compatible with every other package that depends on it`,
+			},
+		},
+		"NoOutlier": {
+			reason: "We should not identify an outlier if no single constraint change would resolve the conflict.",
+			args: args{
+				reqs: []Requirement{
+					{Parent: "cool/config-a", Constraint: "<v0.2.0"},
+					{Parent: "cool/config-b", Constraint: "~v0.2.0"},
+					{Parent: "cool/config-c", Constraint: ">=v0.3.0"},
+				},
+				sets: [][]string{{"v0.1.0"}, {"v0.2.0"}, {"v0.3.0"}},
+			},
+			want: want{
+				hint: &ConflictHint{
+					Bounds: []ConstraintBounds{
+						{Constraint: "<v0.2.0", Lowest: "v0.1.0", Highest: "v0.1.0"},
+						{Constraint: "~v0.2.0", Lowest: "v0.2.0", Highest: "v0.2.0"},
+						{Constraint: ">=v0.3.0", Lowest: "v0.3.0", Highest: "v0.3.0"},
+					},
+				},
+				msg: `"<v0.2.0" allows only v0.1.0, "~v0.2.0" allows only v0.2.0, ">=v0.3.0" allows only v0.3.0`,
+			},
+		},
+		"AllowsNone": {
+			reason: "We should report a constraint that allows no available version, and identify it as the outlier.",
+			args: args{
+				reqs: []Requirement{
+					{Parent: "cool/config-a", Constraint: ">=v0.2.0"},
+					{Parent: "cool/config-b", Constraint: ">=v1.0.0"},
+					{Parent: "cool/config-c", Constraint: "<v0.4.0"},
+				},
+				sets: [][]string{{"v0.2.0", "v0.3.0"}, {}, {"v0.1.0", "v0.2.0", "v0.3.0"}},
+			},
+			want: want{
+				hint: &ConflictHint{
+					Bounds: []ConstraintBounds{
+						{Constraint: ">=v0.2.0", Lowest: "v0.2.0", Highest: "v0.3.0"},
+						{Constraint: ">=v1.0.0"},
+						{Constraint: "<v0.4.0", Lowest: "v0.1.0", Highest: "v0.3.0"},
+					},
+					Outliers: []Outlier{
+						{Parent: "cool/config-b", Version: "v0.3.0"},
+					},
+				},
+				msg: `">=v0.2.0" allows v0.2.0 to v0.3.0, ">=v1.0.0" allows no available version, "<v0.4.0" allows v0.1.0 to v0.3.0; cool/config-b is the outlier; changing its constraint to allow v0.3.0 would be compatible with every other package that depends on it`,
+			},
+		},
+		"DuplicateConstraints": {
+			reason: "We should describe each distinct constraint once, and not identify an outlier whose constraint another package shares.",
+			args: args{
+				reqs: []Requirement{
+					{Parent: "cool/config-a", Constraint: "<v0.2.0"},
+					{Parent: "cool/config-b", Constraint: "<v0.2.0"},
+					{Parent: "cool/config-c", Constraint: ">=v0.3.0"},
+				},
+				sets: [][]string{{"v0.1.0"}, {"v0.1.0"}, {"v0.3.0"}},
+			},
+			want: want{
+				hint: &ConflictHint{
+					Bounds: []ConstraintBounds{
+						{Constraint: "<v0.2.0", Lowest: "v0.1.0", Highest: "v0.1.0"},
+						{Constraint: ">=v0.3.0", Lowest: "v0.3.0", Highest: "v0.3.0"},
+					},
+					Outliers: []Outlier{
+						{Parent: "cool/config-c", Version: "v0.1.0"},
+					},
+				},
+				msg: `"<v0.2.0" allows only v0.1.0, ">=v0.3.0" allows only v0.3.0; cool/config-c is the outlier; changing its constraint to allow v0.1.0 would be compatible with every other package that depends on it`,
+			},
+		},
+		"UnknownParent": {
+			reason: "We should describe an outlier without a parent by its constraint.",
+			args: args{
+				reqs: []Requirement{
+					{Constraint: "<v0.2.0"},
+					{Parent: "cool/config-b", Constraint: ">=v0.1.0"},
+					{Parent: "cool/config-c", Constraint: ">=v0.3.0"},
+				},
+				sets: [][]string{{"v0.1.0"}, {"v0.1.0", "v0.3.0"}, {"v0.3.0"}},
+			},
+			want: want{
+				hint: &ConflictHint{
+					Bounds: []ConstraintBounds{
+						{Constraint: "<v0.2.0", Lowest: "v0.1.0", Highest: "v0.1.0"},
+						{Constraint: ">=v0.1.0", Lowest: "v0.1.0", Highest: "v0.3.0"},
+						{Constraint: ">=v0.3.0", Lowest: "v0.3.0", Highest: "v0.3.0"},
+					},
+					Outliers: []Outlier{
+						{Parent: `the package that requires "<v0.2.0"`, Version: "v0.3.0"},
+						{Parent: "cool/config-c", Version: "v0.1.0"},
+					},
+				},
+				msg: `"<v0.2.0" allows only v0.1.0, ">=v0.1.0" allows v0.1.0 to v0.3.0, ">=v0.3.0" allows only v0.3.0; changing the constraint of the package that requires "<v0.2.0" to allow v0.3.0, or of cool/config-c to allow v0.1.0 would be compatible with every other package that depends on it`,
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := AnalyzeConflict(tc.args.reqs, tc.args.sets, tc.args.policy)
+			if diff := cmp.Diff(tc.want.hint, got); diff != "" {
+				t.Errorf("\n%s\nAnalyzeConflict(...): -want, +got:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.msg, got.String()); diff != "" {
+				t.Errorf("\n%s\nAnalyzeConflict(...).String(): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,342 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package xpkg
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	credentialprovider "github.com/vdemeester/k8s-pkg-credentialprovider"
+	credentialprovidersecrets "github.com/vdemeester/k8s-pkg-credentialprovider/secrets"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+)
+
+const (
+	// credentialRefreshSkew is how long before they expire cached
+	// credentials are refreshed, so that they don't expire mid-request.
+	credentialRefreshSkew = 1 * time.Minute
+
+	// credentialMissTTL is how long the fact that a credential helper could
+	// not supply credentials for a host is cached, so that we don't ask it
+	// for every request.
+	credentialMissTTL = 1 * time.Minute
+
+	// pullSecretServiceAccount is the service account whose pull secrets
+	// are consulted in addition to those supplied.
+	pullSecretServiceAccount = "default"
+
+	errUnknownKeychainFmt = "unknown registry keychain %q, must be one of %s"
+)
+
+// A KeychainSource is a source of credentials to present to registries.
+type KeychainSource string
+
+// Keychain sources.
+const (
+	// KeychainAmbient credentials are obtained by credential helpers from
+	// the cloud environment Crossplane runs in, e.g. IAM Roles for Service
+	// Accounts on EKS or Workload Identity on GKE.
+	KeychainAmbient KeychainSource = "Ambient"
+
+	// KeychainPullSecrets credentials are read from the supplied pull
+	// secrets and those of the namespace's default service account only.
+	// Ambient credentials are never presented from this source.
+	KeychainPullSecrets KeychainSource = "PullSecrets"
+
+	// KeychainAnonymous means no credentials are presented.
+	KeychainAnonymous KeychainSource = "Anonymous"
+)
+
+// DefaultKeychainOrder is the order in which keychain sources are consulted
+// by default.
+var DefaultKeychainOrder = []KeychainSource{KeychainAmbient, KeychainPullSecrets, KeychainAnonymous}
+
+// ParseKeychainOrder parses the supplied names of keychain sources, in the
+// order they should be consulted.
+func ParseKeychainOrder(names []string) ([]KeychainSource, error) {
+	valid := make([]string, len(DefaultKeychainOrder))
+	for i, s := range DefaultKeychainOrder {
+		valid[i] = string(s)
+	}
+	order := make([]KeychainSource, 0, len(names))
+	for _, n := range names {
+		s, ok := parseKeychainSource(n)
+		if !ok {
+			return nil, errors.Errorf(errUnknownKeychainFmt, n, strings.Join(valid, ", "))
+		}
+		order = append(order, s)
+	}
+	return order, nil
+}
+
+func parseKeychainSource(n string) (KeychainSource, bool) {
+	for _, s := range DefaultKeychainOrder {
+		if strings.EqualFold(string(s), strings.TrimSpace(n)) {
+			return s, true
+		}
+	}
+	return "", false
+}
+
+// A Credential is a possibly short-lived credential for a registry.
+type Credential struct {
+	Username string
+	Password string
+
+	// Expiry is when the credential expires. The zero time means it never
+	// does.
+	Expiry time.Time
+}
+
+// A CredentialHelper obtains credentials for registries from the environment
+// Crossplane runs in.
+type CredentialHelper interface {
+	// Name of the credential helper, e.g. ECR.
+	Name() string
+
+	// Matches returns true if the helper can obtain credentials for the
+	// supplied registry host.
+	Matches(host string) bool
+
+	// Get credentials for the supplied registry host. Returns nil if the
+	// environment isn't configured to supply them.
+	Get(ctx context.Context, host string) (*Credential, error)
+}
+
+// WithKeychainOrder specifies the order in which the K8sFetcher should
+// consult keychain sources for the credentials to present to a registry. The
+// first source that has credentials for a registry is used. Sources that are
+// omitted are never consulted, e.g. tags are never listed anonymously if
+// KeychainAnonymous is omitted.
+func WithKeychainOrder(order ...KeychainSource) FetcherOption {
+	return func(f *K8sFetcher) {
+		f.keychain = order
+	}
+}
+
+// WithCredentialHelpers specifies the credential helpers the K8sFetcher
+// should consult for ambient credentials, in order. By default the ECR, GAR,
+// and ACR helpers are consulted.
+func WithCredentialHelpers(h ...CredentialHelper) FetcherOption {
+	return func(f *K8sFetcher) {
+		f.helpers = h
+	}
+}
+
+// WithFetcherLogger specifies how the K8sFetcher should log.
+func WithFetcherLogger(l logging.Logger) FetcherOption {
+	return func(f *K8sFetcher) {
+		f.log = l
+	}
+}
+
+// DefaultCredentialHelpers returns the credential helpers that obtain ambient
+// credentials for the registries of the major cloud providers.
+func DefaultCredentialHelpers() []CredentialHelper {
+	return []CredentialHelper{NewECRHelper(), NewGARHelper(), NewACRHelper()}
+}
+
+type cachedCredential struct {
+	cred   *Credential
+	expiry time.Time
+}
+
+// A credentialCache caches the credentials credential helpers obtain for
+// registry hosts until shortly before they expire, so that short-lived
+// tokens are reused and refreshed across reconciles. It also remembers which
+// keychain source was selected for each host.
+type credentialCache struct {
+	mu       sync.Mutex
+	now      func() time.Time
+	creds    map[string]cachedCredential
+	selected map[string]string
+}
+
+func newCredentialCache() *credentialCache {
+	return &credentialCache{
+		now:      time.Now,
+		creds:    make(map[string]cachedCredential),
+		selected: make(map[string]string),
+	}
+}
+
+// Get credentials for the supplied host from the supplied helper, using
+// cached credentials unless they are about to expire. Failures to obtain
+// credentials are cached briefly.
+func (c *credentialCache) Get(ctx context.Context, h CredentialHelper, host string) (*Credential, error) {
+	key := h.Name() + "/" + host
+
+	c.mu.Lock()
+	e, ok := c.creds[key]
+	c.mu.Unlock()
+	if ok && (e.expiry.IsZero() || c.now().Before(e.expiry)) {
+		return e.cred, nil
+	}
+
+	cred, err := h.Get(ctx, host)
+	e = cachedCredential{cred: cred, expiry: c.now().Add(credentialMissTTL)}
+	if cred != nil {
+		e.expiry = time.Time{}
+		if !cred.Expiry.IsZero() {
+			e.expiry = cred.Expiry.Add(-credentialRefreshSkew)
+		}
+	}
+
+	c.mu.Lock()
+	c.creds[key] = e
+	c.mu.Unlock()
+	return cred, err
+}
+
+// Select records that the supplied keychain source was selected for the
+// supplied host. It returns true the first time a source is selected for a
+// host, and when the selected source changes.
+func (c *credentialCache) Select(host, source string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.selected[host] == source {
+		return false
+	}
+	c.selected[host] = source
+	return true
+}
+
+// authenticator returns the credentials to present to the supplied
+// repository's registry, as supplied by the first keychain source that has
+// any, or authn.Anonymous if none do.
+func (i *K8sFetcher) authenticator(ctx context.Context, repo name.Repository, secrets ...string) (authn.Authenticator, error) {
+	host := repo.RegistryStr()
+	for _, s := range i.keychain {
+		switch s {
+		case KeychainAmbient:
+			for _, h := range i.helpers {
+				if !h.Matches(host) {
+					continue
+				}
+				cred, err := i.creds.Get(ctx, h, host)
+				if err != nil {
+					i.log.Debug("Cannot get ambient registry credentials", "host", host, "helper", h.Name(), "error", err)
+					continue
+				}
+				if cred == nil {
+					continue
+				}
+				i.selected(host, h.Name())
+				return &authn.Basic{Username: cred.Username, Password: cred.Password}, nil
+			}
+		case KeychainPullSecrets:
+			kr, err := i.pullSecrets(ctx, secrets...)
+			if err != nil {
+				return nil, err
+			}
+			creds, ok := kr.Lookup(repo.String())
+			if !ok || len(creds) == 0 {
+				continue
+			}
+			i.selected(host, string(KeychainPullSecrets))
+			c := creds[0]
+			return authn.FromConfig(authn.AuthConfig{
+				Username:      c.Username,
+				Password:      c.Password,
+				Auth:          c.Auth,
+				IdentityToken: c.IdentityToken,
+				RegistryToken: c.RegistryToken,
+			}), nil
+		case KeychainAnonymous:
+			i.selected(host, string(KeychainAnonymous))
+			return authn.Anonymous, nil
+		}
+	}
+	return authn.Anonymous, nil
+}
+
+// pullSecrets returns a keyring of the supplied pull secrets, and those of the
+// default service account, in the K8sFetcher's namespace. Unlike the keychains
+// k8schain builds, it never includes the cloud credential providers; those
+// supply the KeychainAmbient source.
+func (i *K8sFetcher) pullSecrets(ctx context.Context, secrets ...string) (credentialprovider.DockerKeyring, error) {
+	var ps []corev1.Secret
+	if i.client != nil {
+		ns := i.namespace
+		if ns == "" {
+			ns = corev1.NamespaceDefault
+		}
+		for _, n := range secrets {
+			s, err := i.client.CoreV1().Secrets(ns).Get(ctx, n, metav1.GetOptions{})
+			if err != nil {
+				return nil, err
+			}
+			ps = append(ps, *s)
+		}
+		sa, err := i.client.CoreV1().ServiceAccounts(ns).Get(ctx, pullSecretServiceAccount, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		for _, ref := range sa.ImagePullSecrets {
+			s, err := i.client.CoreV1().Secrets(ns).Get(ctx, ref.Name, metav1.GetOptions{})
+			if err != nil {
+				return nil, err
+			}
+			ps = append(ps, *s)
+		}
+	}
+	return credentialprovidersecrets.MakeDockerKeyring(ps, &credentialprovider.BasicDockerKeyring{})
+}
+
+// selected logs the keychain source selected for the supplied host the first
+// time it is selected.
+func (i *K8sFetcher) selected(host, source string) {
+	if i.creds.Select(host, source) {
+		i.log.Debug("Selected registry credentials", "host", host, "keychain", source)
+	}
+}
+
+// anonymous returns true if the K8sFetcher may fall back to presenting no
+// credentials when a registry refuses the credentials it presented.
+func (i *K8sFetcher) anonymous() bool {
+	for _, s := range i.keychain {
+		if s == KeychainAnonymous {
+			return true
+		}
+	}
+	return false
+}
+
+// A fetcherKeychain resolves the credentials for a resource using the keychain
+// sources of its K8sFetcher.
+type fetcherKeychain struct {
+	ctx     context.Context
+	fetcher *K8sFetcher
+	secrets []string
+}
+
+// Resolve the credentials for the supplied resource.
+func (k fetcherKeychain) Resolve(r authn.Resource) (authn.Authenticator, error) {
+	repo, ok := r.(name.Repository)
+	if !ok {
+		return authn.Anonymous, nil
+	}
+	return k.fetcher.authenticator(k.ctx, repo, k.secrets...)
+}
@@ -0,0 +1,414 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package xpkg
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	credentialprovider "github.com/vdemeester/k8s-pkg-credentialprovider"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kfake "k8s.io/client-go/kubernetes/fake"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+)
+
+type mockHelper struct {
+	name  string
+	host  string
+	cred  *Credential
+	err   error
+	calls int
+}
+
+func (h *mockHelper) Name() string             { return h.name }
+func (h *mockHelper) Matches(host string) bool { return host == h.host }
+func (h *mockHelper) Get(_ context.Context, _ string) (*Credential, error) {
+	h.calls++
+	return h.cred, h.err
+}
+
+func TestParseKeychainOrder(t *testing.T) {
+	type want struct {
+		order []KeychainSource
+		err   error
+	}
+
+	cases := map[string]struct {
+		reason string
+		names  []string
+		want   want
+	}{
+		"Valid": {
+			reason: "We should parse keychain sources case insensitively, in the supplied order.",
+			names:  []string{"pullsecrets", " Ambient"},
+			want:   want{order: []KeychainSource{KeychainPullSecrets, KeychainAmbient}},
+		},
+		"Unknown": {
+			reason: "We should return an error for an unknown keychain source.",
+			names:  []string{"Ambient", "Vault"},
+			want:   want{err: errors.Errorf(errUnknownKeychainFmt, "Vault", "Ambient, PullSecrets, Anonymous")},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseKeychainOrder(tc.names)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nParseKeychainOrder(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.order, got); diff != "" {
+				t.Errorf("\n%s\nParseKeychainOrder(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestAuthenticator(t *testing.T) {
+	host := "registry.example.org"
+	ns := "crossplane-system"
+	creds := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: "creds"},
+		Type:       corev1.SecretTypeDockerConfigJson,
+		Data: map[string][]byte{
+			corev1.DockerConfigJsonKey: []byte(fmt.Sprintf(`{"auths":{%q:{"username":"secret","password":"pass"}}}`, host)),
+		},
+	}
+	client := kfake.NewSimpleClientset(&corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: "default"}}, creds)
+	ambient := &Credential{Username: "ambient", Password: "token"}
+
+	type args struct {
+		order   []KeychainSource
+		helpers []CredentialHelper
+		secrets []string
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   *authn.AuthConfig
+	}{
+		"Ambient": {
+			reason: "We should present ambient credentials if the first matching helper has any.",
+			args: args{
+				order:   DefaultKeychainOrder,
+				helpers: []CredentialHelper{&mockHelper{name: "Other", host: "other.example.org", cred: &Credential{}}, &mockHelper{name: "Mock", host: host, cred: ambient}},
+				secrets: []string{"creds"},
+			},
+			want: &authn.AuthConfig{Username: "ambient", Password: "token"},
+		},
+		"PullSecretsFirst": {
+			reason: "We should consult keychain sources in the configured order.",
+			args: args{
+				order:   []KeychainSource{KeychainPullSecrets, KeychainAmbient},
+				helpers: []CredentialHelper{&mockHelper{name: "Mock", host: host, cred: ambient}},
+				secrets: []string{"creds"},
+			},
+			want: &authn.AuthConfig{Username: "secret", Password: "pass"},
+		},
+		"AmbientMissing": {
+			reason: "We should fall through to pull secrets if no helper has ambient credentials.",
+			args: args{
+				order:   DefaultKeychainOrder,
+				helpers: []CredentialHelper{&mockHelper{name: "Mock", host: host}},
+				secrets: []string{"creds"},
+			},
+			want: &authn.AuthConfig{Username: "secret", Password: "pass"},
+		},
+		"AmbientError": {
+			reason: "We should fall through to pull secrets if a helper can't get ambient credentials.",
+			args: args{
+				order:   DefaultKeychainOrder,
+				helpers: []CredentialHelper{&mockHelper{name: "Mock", host: host, err: errors.New("boom")}},
+				secrets: []string{"creds"},
+			},
+			want: &authn.AuthConfig{Username: "secret", Password: "pass"},
+		},
+		"Anonymous": {
+			reason: "We should present no credentials if no keychain source has any.",
+			args: args{
+				order:   DefaultKeychainOrder,
+				helpers: []CredentialHelper{&mockHelper{name: "Mock", host: host}},
+			},
+			want: &authn.AuthConfig{},
+		},
+	}
+
+	for n, tc := range cases {
+		t.Run(n, func(t *testing.T) {
+			f := NewK8sFetcher(client, ns, WithKeychainOrder(tc.args.order...), WithCredentialHelpers(tc.args.helpers...))
+			repo, _ := name.NewRepository(host + "/cool/repo")
+			auth, err := f.authenticator(context.Background(), repo, tc.args.secrets...)
+			if err != nil {
+				t.Fatalf("\n%s\nf.authenticator(...): %s", tc.reason, err)
+			}
+			got, _ := auth.Authorization()
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nf.authenticator(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestCredentialCache(t *testing.T) {
+	host := "registry.example.org"
+	start := time.Now()
+
+	type want struct {
+		calls int
+		cred  *Credential
+	}
+
+	cases := map[string]struct {
+		reason string
+		helper *mockHelper
+		after  []time.Duration
+		want   want
+	}{
+		"ReuseUnexpired": {
+			reason: "We should reuse credentials until shortly before they expire.",
+			helper: &mockHelper{name: "Mock", cred: &Credential{Password: "token", Expiry: start.Add(10 * time.Minute)}},
+			after:  []time.Duration{0, 5 * time.Minute, 8 * time.Minute},
+			want:   want{calls: 1, cred: &Credential{Password: "token", Expiry: start.Add(10 * time.Minute)}},
+		},
+		"RefreshExpiring": {
+			reason: "We should refresh credentials that are about to expire.",
+			helper: &mockHelper{name: "Mock", cred: &Credential{Password: "token", Expiry: start.Add(10 * time.Minute)}},
+			after:  []time.Duration{0, 9*time.Minute + 30*time.Second},
+			want:   want{calls: 2, cred: &Credential{Password: "token", Expiry: start.Add(10 * time.Minute)}},
+		},
+		"ReuseNonExpiring": {
+			reason: "We should reuse credentials that never expire.",
+			helper: &mockHelper{name: "Mock", cred: &Credential{Password: "token"}},
+			after:  []time.Duration{0, 24 * time.Hour},
+			want:   want{calls: 1, cred: &Credential{Password: "token"}},
+		},
+		"CacheMiss": {
+			reason: "We should briefly cache the fact that a helper has no credentials.",
+			helper: &mockHelper{name: "Mock"},
+			after:  []time.Duration{0, 30 * time.Second},
+			want:   want{calls: 1},
+		},
+		"RetryMiss": {
+			reason: "We should ask a helper that had no credentials again once the miss expires.",
+			helper: &mockHelper{name: "Mock"},
+			after:  []time.Duration{0, credentialMissTTL},
+			want:   want{calls: 2},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			c := newCredentialCache()
+			var got *Credential
+			for _, d := range tc.after {
+				c.now = func() time.Time { return start.Add(d) }
+				got, _ = c.Get(context.Background(), tc.helper, host)
+			}
+			if diff := cmp.Diff(tc.want.calls, tc.helper.calls); diff != "" {
+				t.Errorf("\n%s\nc.Get(...): -want calls, +got calls:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.cred, got); diff != "" {
+				t.Errorf("\n%s\nc.Get(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestCredentialCacheSelect(t *testing.T) {
+	c := newCredentialCache()
+	got := []bool{
+		c.Select("registry.example.org", "ECR"),
+		c.Select("registry.example.org", "ECR"),
+		c.Select("other.example.org", "ECR"),
+		c.Select("registry.example.org", string(KeychainPullSecrets)),
+	}
+	want := []bool{true, false, true, true}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("c.Select(...): -want first use, +got first use:\n%s", diff)
+	}
+}
+
+func TestHelperMatches(t *testing.T) {
+	cases := map[string]struct {
+		helper CredentialHelper
+		want   map[string]bool
+	}{
+		"ECR": {
+			helper: NewECRHelper(),
+			want: map[string]bool{
+				"123456789012.dkr.ecr.us-west-2.amazonaws.com":      true,
+				"123456789012.dkr.ecr-fips.us-east-1.amazonaws.com": true,
+				"123456789012.dkr.ecr.cn-north-1.amazonaws.com.cn":  true,
+				"public.ecr.aws": false,
+				"123456789012.dkr.ecr.us-west-2.amazonaws.com.example": false,
+			},
+		},
+		"GAR": {
+			helper: NewGARHelper(),
+			want: map[string]bool{
+				"gcr.io":                      true,
+				"eu.gcr.io":                   true,
+				"us-central1-docker.pkg.dev":  true,
+				"index.docker.io":             false,
+				"us-central1-python.pkg.dev":  false,
+				"registry.example.org/gcr.io": false,
+			},
+		},
+		"ACR": {
+			helper: NewACRHelper(),
+			want: map[string]bool{
+				"cool.azurecr.io": true,
+				"cool.azurecr.cn": true,
+				"azurecr.io":      false,
+				"index.docker.io": false,
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			for host, want := range tc.want {
+				if got := tc.helper.Matches(host); got != want {
+					t.Errorf("%s.Matches(%q): want %t, got %t", tc.helper.Name(), host, want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestKeyringHelperGet(t *testing.T) {
+	now := time.Now()
+	host := "cool.azurecr.io"
+
+	type want struct {
+		cred *Credential
+		err  error
+	}
+
+	cases := map[string]struct {
+		reason   string
+		keychain authn.Keychain
+		want     want
+	}{
+		"Credentials": {
+			reason: "We should return the credentials the credential providers supply, until they should be asked again.",
+			keychain: mockKeychain(func(authn.Resource) (authn.Authenticator, error) {
+				return &authn.Basic{Username: "user", Password: "token"}, nil
+			}),
+			want: want{cred: &Credential{Username: "user", Password: "token", Expiry: now.Add(ambientCredentialTTL)}},
+		},
+		"NoCredentials": {
+			reason: "We should return no credentials if the credential providers supply none.",
+			keychain: mockKeychain(func(authn.Resource) (authn.Authenticator, error) {
+				return authn.Anonymous, nil
+			}),
+		},
+		"ResolveError": {
+			reason: "We should return an error if the credential providers fail.",
+			keychain: mockKeychain(func(authn.Resource) (authn.Authenticator, error) {
+				return nil, errors.New("boom")
+			}),
+			want: want{err: errors.Wrap(errors.New("boom"), errAmbientResolve)},
+		},
+	}
+
+	for n, tc := range cases {
+		t.Run(n, func(t *testing.T) {
+			h := NewACRHelper()
+			h.keychain = func(context.Context) (authn.Keychain, error) { return tc.keychain, nil }
+			h.now = func() time.Time { return now }
+			got, err := h.Get(context.Background(), host)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nh.Get(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.cred, got); diff != "" {
+				t.Errorf("\n%s\nh.Get(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+type mockKeychain func(authn.Resource) (authn.Authenticator, error)
+
+func (fn mockKeychain) Resolve(r authn.Resource) (authn.Authenticator, error) { return fn(r) }
+
+// ambientHost is a registry the ambientProvider supplies credentials for.
+const ambientHost = "ambient.example.org"
+
+// An ambientProvider is a cloud credential provider, like those k8schain
+// registers, that supplies credentials for the ambientHost.
+type ambientProvider struct{}
+
+func (ambientProvider) Enabled() bool { return true }
+func (ambientProvider) Provide(_ string) credentialprovider.DockerConfig {
+	return credentialprovider.DockerConfig{ambientHost: {Username: "ambient", Password: "token"}}
+}
+
+func init() {
+	credentialprovider.RegisterCredentialProvider("xpkg-test-ambient", ambientProvider{})
+}
+
+func TestAuthenticatorSourcesDisjoint(t *testing.T) {
+	ns := "crossplane-system"
+	client := kfake.NewSimpleClientset(&corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: "default"}})
+	helper := newKeyringHelper("Ambient", func(host string) bool { return host == ambientHost })
+
+	cases := map[string]struct {
+		reason string
+		order  []KeychainSource
+		want   *authn.AuthConfig
+	}{
+		"PullSecretsOnly": {
+			reason: "Pull secrets should never supply the credentials of the cloud credential providers.",
+			order:  []KeychainSource{KeychainPullSecrets},
+			want:   &authn.AuthConfig{},
+		},
+		"AmbientOnly": {
+			reason: "The ambient keychain should supply the credentials of the cloud credential providers.",
+			order:  []KeychainSource{KeychainAmbient},
+			want:   &authn.AuthConfig{Username: "ambient", Password: "token"},
+		},
+		"PullSecretsThenAmbient": {
+			reason: "The ambient keychain should supply credentials when it follows pull secrets that have none.",
+			order:  []KeychainSource{KeychainPullSecrets, KeychainAmbient},
+			want:   &authn.AuthConfig{Username: "ambient", Password: "token"},
+		},
+	}
+
+	for n, tc := range cases {
+		t.Run(n, func(t *testing.T) {
+			f := NewK8sFetcher(client, ns, WithKeychainOrder(tc.order...), WithCredentialHelpers(helper))
+			repo, _ := name.NewRepository(ambientHost + "/cool/repo")
+			auth, err := f.authenticator(context.Background(), repo)
+			if err != nil {
+				t.Fatalf("\n%s\nf.authenticator(...): %s", tc.reason, err)
+			}
+			got, _ := auth.Authorization()
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nf.authenticator(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
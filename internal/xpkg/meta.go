@@ -0,0 +1,318 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package xpkg
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"sigs.k8s.io/yaml"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+
+	pkgmetav1 "github.com/crossplane/crossplane/apis/pkg/meta/v1"
+)
+
+const (
+	// MetaArtifactType is the media type of the config of an OCI artifact
+	// that refers to a package image and contains its package metadata. The
+	// artifact's only layer is the package's meta file, i.e. crossplane.yaml.
+	// Artifacts are found using the referrers tag schema, i.e. by listing the
+	// index tagged with the algorithm and hex of the package's digest, e.g.
+	// sha256-<hex>.
+	MetaArtifactType = "application/vnd.crossplane.xpkg.meta.config.v1+json"
+
+	// AnnotationAPIGroups may be set on the manifest of a package's meta
+	// artifact, or as a label on the config of a package image, to the comma
+	// separated API groups of the CustomResourceDefinitions in the package.
+	AnnotationAPIGroups = "io.crossplane.xpkg.api-groups"
+
+	// LabelKind may be set on the config of a package image to the kind of
+	// package, i.e. Provider or Configuration.
+	LabelKind = "io.crossplane.xpkg.kind"
+
+	// LabelCrossplane may be set on the config of a package image to the
+	// range of Crossplane versions the package is compatible with.
+	LabelCrossplane = "io.crossplane.xpkg.crossplane"
+)
+
+const (
+	// maxMetaSize is the largest meta file we'll read from a meta artifact.
+	maxMetaSize = 1 << 20
+
+	// defaultMetaCacheSize is the default number of packages whose metadata
+	// a K8sFetcher caches.
+	defaultMetaCacheSize = 256
+
+	errFetchManifest    = "cannot fetch package manifest"
+	errFetchConfig      = "cannot fetch package config"
+	errFetchReferrers   = "cannot fetch package referrers"
+	errFetchArtifact    = "cannot fetch package meta artifact"
+	errReadMetaFile     = "cannot read package meta file"
+	errParseMetaFile    = "cannot parse package meta file"
+	errMetaFileTooLarge = "package meta file is too large"
+	errUnknownKindFmt   = "unknown package kind %q"
+)
+
+// PackageMeta is the metadata of a package that the resolver needs to decide
+// whether and how to install it. It is read without pulling the package's
+// layers.
+type PackageMeta struct {
+	// Digest of the package's manifest.
+	Digest string
+
+	// Kind of the package, i.e. Provider or Configuration. Empty if unknown.
+	Kind string
+
+	// Dependencies declared by the package. Nil if unknown.
+	Dependencies []pkgmetav1.Dependency
+
+	// Crossplane is the range of Crossplane versions the package declares it
+	// is compatible with. Empty if it declares none, or if unknown.
+	Crossplane string
+
+	// APIGroups of the CustomResourceDefinitions in the package, sorted. Nil
+	// if unknown.
+	APIGroups []string
+
+	// Complete is true if the metadata was read from the package's meta
+	// artifact, in which case its kind, dependencies, and Crossplane
+	// constraints are known.
+	Complete bool
+}
+
+// KnowsCrossplane returns true if the metadata tells whether the package
+// declares a range of compatible Crossplane versions.
+func (m *PackageMeta) KnowsCrossplane() bool {
+	return m != nil && (m.Complete || m.Crossplane != "")
+}
+
+// KnowsAPIGroups returns true if the metadata tells the API groups of the
+// CustomResourceDefinitions in the package.
+func (m *PackageMeta) KnowsAPIGroups() bool {
+	return m != nil && m.APIGroups != nil
+}
+
+// A metaCache caches the metadata of packages, keyed by digest. A package's
+// metadata can't change without its digest changing. Once full it evicts the
+// metadata it cached first.
+type metaCache struct {
+	mu    sync.Mutex
+	max   int
+	meta  map[string]*PackageMeta
+	order []string
+}
+
+func newMetaCache(max int) *metaCache {
+	return &metaCache{max: max, meta: map[string]*PackageMeta{}}
+}
+
+func (c *metaCache) get(digest string) (*PackageMeta, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	m, ok := c.meta[digest]
+	return m, ok
+}
+
+func (c *metaCache) add(m *PackageMeta) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.meta[m.Digest]; ok {
+		return
+	}
+	if len(c.order) >= c.max {
+		delete(c.meta, c.order[0])
+		c.order = c.order[1:]
+	}
+	c.meta[m.Digest] = m
+	c.order = append(c.order, m.Digest)
+}
+
+// FetchMeta fetches the metadata of a package without pulling its layers. The
+// package's manifest and config are fetched, as is its meta artifact if its
+// publisher attached one. Metadata is cached by digest, so once cached only
+// the package's manifest is fetched.
+func (i *K8sFetcher) FetchMeta(ctx context.Context, ref name.Reference, secrets ...string) (*PackageMeta, error) {
+	ref = i.reference(ref)
+	auth, err := i.authenticator(ctx, ref.Context(), secrets...)
+	if err != nil {
+		return nil, err
+	}
+	opts := []remote.Option{remote.WithAuth(auth), remote.WithTransport(i.transport), remote.WithContext(ctx)}
+
+	desc, err := remote.Get(ref, opts...)
+	if err != nil {
+		return nil, errors.Wrap(err, errFetchManifest)
+	}
+	if m, ok := i.meta.get(desc.Digest.String()); ok {
+		return m, nil
+	}
+
+	img, err := desc.Image()
+	if err != nil {
+		return nil, errors.Wrap(err, errFetchManifest)
+	}
+	cfg, err := img.ConfigFile()
+	if err != nil {
+		return nil, errors.Wrap(err, errFetchConfig)
+	}
+	m := metaFromLabels(desc.Digest.String(), cfg.Config.Labels)
+
+	if err := readMetaArtifact(ref.Context(), desc.Digest, m, opts...); err != nil {
+		return nil, err
+	}
+	i.meta.add(m)
+	return m, nil
+}
+
+// readMetaArtifact reads the meta artifact that refers to the supplied digest
+// into the supplied metadata, if there is one.
+func readMetaArtifact(repo name.Repository, d v1.Hash, m *PackageMeta, opts ...remote.Option) error {
+	idx, err := remote.Index(repo.Tag(d.Algorithm+"-"+d.Hex), opts...)
+	if isNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, errFetchReferrers)
+	}
+	im, err := idx.IndexManifest()
+	if err != nil {
+		return errors.Wrap(err, errFetchReferrers)
+	}
+	for _, r := range im.Manifests {
+		a, err := remote.Image(repo.Digest(r.Digest.String()), opts...)
+		if err != nil {
+			return errors.Wrap(err, errFetchArtifact)
+		}
+		am, err := a.Manifest()
+		if err != nil {
+			return errors.Wrap(err, errFetchArtifact)
+		}
+		if string(am.Config.MediaType) != MetaArtifactType || len(am.Layers) != 1 {
+			continue
+		}
+		l, err := a.LayerByDigest(am.Layers[0].Digest)
+		if err != nil {
+			return errors.Wrap(err, errFetchArtifact)
+		}
+		rc, err := l.Compressed()
+		if err != nil {
+			return errors.Wrap(err, errFetchArtifact)
+		}
+		defer func() { _ = rc.Close() }()
+		return readMetaFile(rc, am.Annotations, m)
+	}
+	return nil
+}
+
+// metaFromLabels returns the metadata of the package with the supplied digest
+// that the supplied image config labels describe.
+func metaFromLabels(digest string, labels map[string]string) *PackageMeta {
+	m := &PackageMeta{
+		Digest:     digest,
+		Kind:       labels[LabelKind],
+		Crossplane: labels[LabelCrossplane],
+	}
+	if g, ok := labels[AnnotationAPIGroups]; ok {
+		m.APIGroups = splitGroups(g)
+	}
+	return m
+}
+
+// readMetaFile reads the supplied meta file, and the supplied annotations of
+// the meta artifact it was read from, into the supplied metadata.
+func readMetaFile(r io.Reader, annotations map[string]string, m *PackageMeta) error {
+	b, err := ioutil.ReadAll(io.LimitReader(r, maxMetaSize+1))
+	if err != nil {
+		return errors.Wrap(err, errReadMetaFile)
+	}
+	if len(b) > maxMetaSize {
+		return errors.New(errMetaFileTooLarge)
+	}
+
+	// Every version of the meta API has the fields we read, so we decode the
+	// meta file as the v1 type of its kind.
+	tm := struct {
+		Kind string `json:"kind"`
+	}{}
+	if err := yaml.Unmarshal(b, &tm); err != nil {
+		return errors.Wrap(err, errParseMetaFile)
+	}
+	var pkg pkgmetav1.Pkg
+	switch tm.Kind {
+	case pkgmetav1.ProviderKind:
+		pkg = &pkgmetav1.Provider{}
+	case pkgmetav1.ConfigurationKind:
+		pkg = &pkgmetav1.Configuration{}
+	default:
+		return errors.Errorf(errUnknownKindFmt, tm.Kind)
+	}
+	if err := yaml.Unmarshal(b, pkg); err != nil {
+		return errors.Wrap(err, errParseMetaFile)
+	}
+
+	m.Kind = tm.Kind
+	m.Dependencies = append([]pkgmetav1.Dependency{}, pkg.GetDependencies()...)
+	m.Crossplane = ""
+	if c := pkg.GetCrossplaneConstraints(); c != nil {
+		m.Crossplane = c.Version
+	}
+	if g, ok := annotations[AnnotationAPIGroups]; ok {
+		m.APIGroups = splitGroups(g)
+	}
+	m.Complete = true
+	return nil
+}
+
+// splitGroups splits the supplied comma separated API groups.
+func splitGroups(s string) []string {
+	groups := []string{}
+	for _, g := range strings.Split(s, ",") {
+		if g = strings.TrimSpace(g); g != "" {
+			groups = append(groups, g)
+		}
+	}
+	sort.Strings(groups)
+	return groups
+}
+
+// isNotFound returns true if the supplied error indicates that the registry
+// doesn't have the requested manifest.
+func isNotFound(err error) bool {
+	var terr *transport.Error
+	if !errors.As(err, &terr) {
+		return false
+	}
+	if terr.StatusCode == http.StatusNotFound {
+		return true
+	}
+	for _, d := range terr.Errors {
+		if d.Code == transport.ManifestUnknownErrorCode || d.Code == transport.NameUnknownErrorCode {
+			return true
+		}
+	}
+	return false
+}
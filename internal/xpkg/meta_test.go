@@ -0,0 +1,316 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package xpkg
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-containerregistry/pkg/name"
+	ggcrregistry "github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kfake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/utils/pointer"
+
+	pkgmetav1 "github.com/crossplane/crossplane/apis/pkg/meta/v1"
+)
+
+// A countingRegistry serves an in-memory OCI registry, counting the requests
+// it serves and the bytes it responds with.
+type countingRegistry struct {
+	mu       sync.Mutex
+	handler  http.Handler
+	paths    []string
+	received int
+}
+
+func (c *countingRegistry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	cw := &countingWriter{ResponseWriter: w}
+	c.handler.ServeHTTP(cw, r)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if r.URL.Path != "/v2/" {
+		c.paths = append(c.paths, r.Method+" "+r.URL.Path)
+	}
+	c.received += cw.n
+}
+
+// reset returns the requests served and bytes sent since the last reset.
+func (c *countingRegistry) reset() ([]string, int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	paths, n := c.paths, c.received
+	c.paths, c.received = nil, 0
+	return paths, n
+}
+
+type countingWriter struct {
+	http.ResponseWriter
+	n int
+}
+
+func (w *countingWriter) Write(b []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(b)
+	w.n += n
+	return n, err
+}
+
+// A rawBlob is an uncompressed artifact layer.
+type rawBlob []byte
+
+func (b rawBlob) Digest() (v1.Hash, error) {
+	h, _, err := v1.SHA256(bytes.NewReader(b))
+	return h, err
+}
+func (b rawBlob) DiffID() (v1.Hash, error)            { return b.Digest() }
+func (b rawBlob) Size() (int64, error)                { return int64(len(b)), nil }
+func (b rawBlob) MediaType() (types.MediaType, error) { return "application/yaml", nil }
+func (b rawBlob) Compressed() (io.ReadCloser, error) {
+	return ioutil.NopCloser(bytes.NewReader(b)), nil
+}
+
+// A metaArtifact is a package meta artifact.
+type metaArtifact struct {
+	v1.Image
+	annotations map[string]string
+}
+
+func (a *metaArtifact) Manifest() (*v1.Manifest, error) {
+	m, err := a.Image.Manifest()
+	if err != nil {
+		return nil, err
+	}
+	m = m.DeepCopy()
+	m.Config.MediaType = MetaArtifactType
+	m.Annotations = a.annotations
+	return m, nil
+}
+func (a *metaArtifact) RawManifest() ([]byte, error) { return partial.RawManifest(a) }
+func (a *metaArtifact) Digest() (v1.Hash, error)     { return partial.Digest(a) }
+func (a *metaArtifact) Size() (int64, error)         { return partial.Size(a) }
+
+func newMetaArtifact(t *testing.T, meta string, annotations map[string]string) v1.Image {
+	t.Helper()
+	l, err := partial.CompressedToLayer(rawBlob(meta))
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := mutate.AppendLayers(empty.Image, l)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &metaArtifact{Image: img, annotations: annotations}
+}
+
+func TestK8sFetcherFetchMeta(t *testing.T) {
+	// The package's only layer is much larger than its metadata.
+	layered, err := random.Image(1<<20, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg, err := mutate.Config(layered, v1.Config{Labels: map[string]string{
+		LabelKind:           pkgmetav1.ProviderKind,
+		AnnotationAPIGroups: "s3.aws.crossplane.io,ec2.aws.crossplane.io",
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := pkg.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	layers, err := pkg.Layers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ld, err := layers[0].Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	artifact := newMetaArtifact(t, strings.Join([]string{
+		"apiVersion: meta.pkg.crossplane.io/v1",
+		"kind: Configuration",
+		"metadata:",
+		"  name: getting-started",
+		"spec:",
+		"  crossplane:",
+		"    version: '>=v1.4.0'",
+		"  dependsOn:",
+		"  - provider: crossplane/provider-aws",
+		"    version: '>=v0.20.0'",
+	}, "\n"), map[string]string{AnnotationAPIGroups: "aws.platform.example.org"})
+
+	type want struct {
+		meta *PackageMeta
+		err  bool
+	}
+
+	cases := map[string]struct {
+		reason   string
+		artifact v1.Image
+		want     want
+	}{
+		"ConfigLabels": {
+			reason: "We should read the metadata the package's config labels describe if it has no meta artifact.",
+			want: want{meta: &PackageMeta{
+				Digest:    d.String(),
+				Kind:      pkgmetav1.ProviderKind,
+				APIGroups: []string{"ec2.aws.crossplane.io", "s3.aws.crossplane.io"},
+			}},
+		},
+		"MetaArtifact": {
+			reason:   "We should read the metadata of the package's meta artifact if it has one.",
+			artifact: artifact,
+			want: want{meta: &PackageMeta{
+				Digest:       d.String(),
+				Kind:         pkgmetav1.ConfigurationKind,
+				Dependencies: []pkgmetav1.Dependency{{Provider: pointer.String("crossplane/provider-aws"), Version: ">=v0.20.0"}},
+				Crossplane:   ">=v1.4.0",
+				APIGroups:    []string{"aws.platform.example.org"},
+				Complete:     true,
+			}},
+		},
+	}
+
+	client := kfake.NewSimpleClientset(&corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Namespace: "crossplane-system", Name: "default"}})
+
+	for n, tc := range cases {
+		t.Run(n, func(t *testing.T) {
+			reg := &countingRegistry{handler: ggcrregistry.New()}
+			srv := httptest.NewServer(reg)
+			defer srv.Close()
+			host := strings.TrimPrefix(srv.URL, "http://")
+
+			ref, err := name.ParseReference(host+"/crossplane/provider-aws:v0.20.0", name.Insecure)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := remote.Write(ref, pkg); err != nil {
+				t.Fatal(err)
+			}
+			if tc.artifact != nil {
+				idx := mutate.AppendManifests(empty.Index, mutate.IndexAddendum{Add: tc.artifact})
+				if err := remote.WriteIndex(ref.Context().Tag(d.Algorithm+"-"+d.Hex), idx); err != nil {
+					t.Fatal(err)
+				}
+			}
+			_, _ = reg.reset()
+
+			f := NewK8sFetcher(client, "crossplane-system", WithInsecureRegistries(host))
+			got, err := f.FetchMeta(context.Background(), ref)
+			if diff := cmp.Diff(tc.want.err, err != nil); diff != "" {
+				t.Errorf("\n%s\nFetchMeta(...): -want error, +got error:\n%s\n%v", tc.reason, diff, err)
+			}
+			if diff := cmp.Diff(tc.want.meta, got); diff != "" {
+				t.Errorf("\n%s\nFetchMeta(...): -want, +got:\n%s", tc.reason, diff)
+			}
+
+			// We should transfer a few KB, and never the package's layer.
+			paths, received := reg.reset()
+			for _, p := range paths {
+				if strings.HasSuffix(p, ld.String()) {
+					t.Errorf("\n%s\nFetchMeta(...): fetched the package's layer: %s", tc.reason, p)
+				}
+			}
+			if received > 8<<10 {
+				t.Errorf("\n%s\nFetchMeta(...): received %d bytes in %d requests, want at most 8KiB: %v", tc.reason, received, len(paths), paths)
+			}
+
+			// Once cached we should only fetch the package's manifest.
+			if _, err := f.FetchMeta(context.Background(), ref); err != nil {
+				t.Errorf("\n%s\nFetchMeta(...): %s", tc.reason, err)
+			}
+			paths, _ = reg.reset()
+			want := []string{"GET /v2/crossplane/provider-aws/manifests/v0.20.0"}
+			if diff := cmp.Diff(want, paths); diff != "" {
+				t.Errorf("\n%s\nFetchMeta(...): -want cached requests, +got cached requests:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestReadMetaFile(t *testing.T) {
+	cases := map[string]struct {
+		reason string
+		meta   string
+		want   *PackageMeta
+		err    bool
+	}{
+		"Provider": {
+			reason: "We should read a provider's meta file.",
+			meta:   "apiVersion: meta.pkg.crossplane.io/v1alpha1\nkind: Provider\nspec:\n  controller:\n    image: crossplane/provider-aws-controller:v0.20.0",
+			want:   &PackageMeta{Kind: pkgmetav1.ProviderKind, Dependencies: []pkgmetav1.Dependency{}, Complete: true},
+		},
+		"UnknownKind": {
+			reason: "We should return an error if the meta file isn't a package.",
+			meta:   "apiVersion: v1\nkind: ConfigMap",
+			want:   &PackageMeta{},
+			err:    true,
+		},
+		"TooLarge": {
+			reason: "We should refuse to read an overly large meta file.",
+			meta:   strings.Repeat("#", maxMetaSize+1),
+			want:   &PackageMeta{},
+			err:    true,
+		},
+	}
+
+	for n, tc := range cases {
+		t.Run(n, func(t *testing.T) {
+			got := &PackageMeta{}
+			err := readMetaFile(strings.NewReader(tc.meta), nil, got)
+			if diff := cmp.Diff(tc.err, err != nil); diff != "" {
+				t.Errorf("\n%s\nreadMetaFile(...): -want error, +got error:\n%s\n%v", tc.reason, diff, err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nreadMetaFile(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestMetaCache(t *testing.T) {
+	c := newMetaCache(2)
+	for _, d := range []string{"sha256:a", "sha256:b", "sha256:c"} {
+		c.add(&PackageMeta{Digest: d})
+	}
+	got := map[string]bool{}
+	for _, d := range []string{"sha256:a", "sha256:b", "sha256:c"} {
+		_, got[d] = c.get(d)
+	}
+	want := map[string]bool{"sha256:a": false, "sha256:b": true, "sha256:c": true}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("metaCache: -want cached, +got cached:\n%s", diff)
+	}
+}
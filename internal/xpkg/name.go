@@ -17,6 +17,8 @@ limitations under the License.
 package xpkg
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"os"
 	"path/filepath"
 	"strings"
@@ -44,12 +46,6 @@ const (
 	XpkgMatchPattern string = "*" + XpkgExtension
 )
 
-const (
-	// identifierDelimeters is the set of valid OCI image identifier delimeter
-	// characters.
-	identifierDelimeters string = ":@"
-)
-
 func truncate(str string, num int) string {
 	t := str
 	if len(str) > num {
@@ -82,6 +78,79 @@ func ToDNSLabel(s string) string { // nolint:gocyclo
 	return strings.Trim(cut.String(), "-")
 }
 
+// ToUniqueDNSLabel converts the string to a valid DNS label that is distinct
+// from the labels of other strings that ToDNSLabel would convert to the same
+// label, e.g. because they differ only in invalid characters or beyond the
+// 63rd character. The label is suffixed with a short hash of the string.
+func ToUniqueDNSLabel(s string) string {
+	h := sha256.Sum256([]byte(s))
+	return FriendlyID(ToDNSLabel(s), hex.EncodeToString(h[:]))
+}
+
+const (
+	// maxDNSLabelLength is the maximum length of a DNS label.
+	maxDNSLabelLength = 63
+
+	// nameHashLength is the number of hex characters of a repository's hash
+	// that PackageName and HashedPackageName append to a name.
+	nameHashLength = 8
+)
+
+// PackageName returns the name of a package the resolver installs from the
+// supplied canonical repository, e.g. index.docker.io/crossplane/provider-aws.
+// The name must stay the same across reconciles and Crossplane versions, so
+// the algorithm must not change:
+//
+//  1. If the repository's path, i.e. the repository without its registry, is
+//     at most 63 characters, the name is ToDNSLabel of the path. For example
+//     index.docker.io/crossplane/provider-aws is named crossplane-provider-aws.
+//  2. Otherwise ToDNSLabel would truncate the path, and paths that differ
+//     only beyond the 63rd character would have the same name, so the name is
+//     HashedPackageName of the repository.
+//
+// Packages aren't found by their name, which may be taken by a package from
+// another repository, but by the repository label the resolver adds to them.
+func PackageName(repo string) string {
+	path := repo
+	if i := strings.Index(repo, "/"); i >= 0 {
+		path = repo[i+1:]
+	}
+	if n := ToDNSLabel(path); len(path) <= maxDNSLabelLength && n != "" {
+		return n
+	}
+	return HashedPackageName(repo)
+}
+
+// HashedPackageName returns the name of a package the resolver installs from
+// the supplied canonical repository when PackageName's is too long or is taken
+// by a package from another repository. The name is ToDNSLabel of the last
+// segment of the repository's path, truncated so that the name is a valid DNS
+// label, a dash, and the first 8 hex characters of the SHA-256 hash of the
+// repository. For example index.docker.io/crossplane/provider-aws is named
+// provider-aws-f69c57d5. Like PackageName's, the algorithm must not change.
+func HashedPackageName(repo string) string {
+	h := sha256.Sum256([]byte(repo))
+	hash := hex.EncodeToString(h[:])[:nameHashLength]
+
+	prefix := ToDNSLabel(repo[strings.LastIndex(repo, "/")+1:])
+	prefix = strings.Trim(truncate(prefix, maxDNSLabelLength-nameHashLength-1), "-")
+	if prefix == "" {
+		return hash
+	}
+	return prefix + "-" + hash
+}
+
+// DefaultRegistryOptions returns the options with which to parse a package
+// reference that does not specify a registry so that it refers to the
+// supplied default registry. An empty registry refers to go-containerregistry's
+// default registry, i.e. Docker Hub.
+func DefaultRegistryOptions(registry string) []name.Option {
+	if registry == "" {
+		return nil
+	}
+	return []name.Option{name.WithDefaultRegistry(registry)}
+}
+
 // BuildPath builds a path for a compiled Crossplane package. If file name has
 // extension it will be replaced.
 func BuildPath(path, name string) string {
@@ -111,7 +180,45 @@ func ParseNameFromMeta(fs afero.Fs, path string) (string, error) {
 // not want to do that in cases where we are not pulling an image because it
 // breaks comparison with dependencies defined in a Configuration manifest.
 func ParsePackageSourceFromReference(ref name.Reference) string {
-	return strings.TrimRight(strings.TrimSuffix(ref.String(), ref.Identifier()), identifierDelimeters)
+	return stripIdentifier(ref.String())
+}
+
+// ParseSource parses the supplied package reference, and returns its source
+// and its identifier, i.e. its tag or digest. The source is parsed as
+// ParsePackageSourceFromReference parses it. The identifier of a reference
+// that has neither a tag nor a digest is latest. Both the resolver and the
+// package revision controller derive sources this way, so that they agree on
+// the source of any reference.
+func ParseSource(ref string) (source, identifier string, err error) {
+	r, err := name.ParseReference(ref, name.WithDefaultRegistry(""))
+	if err != nil {
+		return "", "", err
+	}
+	return ParsePackageSourceFromReference(r), r.Identifier(), nil
+}
+
+// VersionedSource returns the supplied package reference at the supplied
+// version, which may be a tag or a digest. Any tag or digest the reference
+// already has is replaced, so that the returned reference has exactly one.
+func VersionedSource(ref, version string) string {
+	if strings.Contains(version, ":") {
+		return stripIdentifier(ref) + "@" + version
+	}
+	return stripIdentifier(ref) + ":" + version
+}
+
+// stripIdentifier strips any tag or digest from the supplied package
+// reference. A digest follows the first @. A tag follows the last colon, but
+// only if no slash follows it; a colon followed by a slash separates a
+// registry host from its port.
+func stripIdentifier(ref string) string {
+	if i := strings.Index(ref, "@"); i >= 0 {
+		ref = ref[:i]
+	}
+	if i := strings.LastIndex(ref, ":"); i > strings.LastIndex(ref, "/") {
+		ref = ref[:i]
+	}
+	return ref
 }
 
 type metaPkg struct {
@@ -17,10 +17,12 @@ limitations under the License.
 package xpkg
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-containerregistry/pkg/name"
+	"k8s.io/apimachinery/pkg/util/validation"
 )
 
 func TestFriendlyID(t *testing.T) {
@@ -129,6 +131,132 @@ func TestToDNSLabel(t *testing.T) {
 	}
 }
 
+func TestToUniqueDNSLabel(t *testing.T) {
+	long := "registry.example.org/acme/" + strings.Repeat("provider-", 8)
+
+	cases := map[string]struct {
+		reason string
+		a      string
+		b      string
+	}{
+		"InvalidCharacters": {
+			reason: "Strings that differ only in characters that are removed should have distinct labels.",
+			a:      "acme/provider_sql",
+			b:      "acme/providersql",
+		},
+		"Truncated": {
+			reason: "Strings that differ only beyond the 63rd character should have distinct labels.",
+			a:      long + "aws",
+			b:      long + "gcp",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if ToDNSLabel(tc.a) != ToDNSLabel(tc.b) {
+				t.Fatalf("\n%s\nToDNSLabel(...): test strings must have the same label", tc.reason)
+			}
+			a, b := ToUniqueDNSLabel(tc.a), ToUniqueDNSLabel(tc.b)
+			if a == b {
+				t.Errorf("\n%s\nToUniqueDNSLabel(...): want distinct labels, got %q for both", tc.reason, a)
+			}
+			for _, l := range []string{a, b} {
+				if errs := validation.IsDNS1123Label(l); len(errs) > 0 {
+					t.Errorf("\n%s\nToUniqueDNSLabel(...): %q is not a DNS label: %v", tc.reason, l, errs)
+				}
+			}
+			if diff := cmp.Diff(a, ToUniqueDNSLabel(tc.a)); diff != "" {
+				t.Errorf("\n%s\nToUniqueDNSLabel(...): want deterministic label: -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestPackageName(t *testing.T) {
+	long := "registry.example.com/very/long/org/structure/" + strings.Repeat("team-", 4)
+
+	cases := map[string]struct {
+		reason string
+		repo   string
+		want   string
+	}{
+		"Short": {
+			reason: "A repository whose path is a valid DNS label should be named for its path.",
+			repo:   "index.docker.io/crossplane/provider-aws",
+			want:   "crossplane-provider-aws",
+		},
+		"Long": {
+			reason: "A repository whose path is longer than 63 characters should be named for the last segment of its path and its hash.",
+			repo:   long + "provider-foo-bar-baz",
+			want:   HashedPackageName(long + "provider-foo-bar-baz"),
+		},
+		"NoValidCharacters": {
+			reason: "A repository whose path has no valid characters should be named for its hash.",
+			repo:   "registry.example.com/___",
+			want:   HashedPackageName("registry.example.com/___"),
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := PackageName(tc.repo)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nPackageName(...): -want, +got:\n%s", tc.reason, diff)
+			}
+			if errs := validation.IsDNS1123Label(got); len(errs) > 0 {
+				t.Errorf("\n%s\nPackageName(...): %q is not a DNS label: %v", tc.reason, got, errs)
+			}
+		})
+	}
+}
+
+func TestPackageNameCollision(t *testing.T) {
+	// These repositories' paths differ only beyond the 63rd character, so
+	// ToDNSLabel truncates them to the same label.
+	long := "registry.example.com/very/long/org/structure/" + strings.Repeat("provider-", 6)
+	a, b := long+"aws", long+"gcp"
+
+	if PackageName(a) == PackageName(b) {
+		t.Errorf("PackageName(...): want distinct names, got %q for both", PackageName(a))
+	}
+}
+
+func TestHashedPackageName(t *testing.T) {
+	cases := map[string]struct {
+		reason string
+		repo   string
+		want   string
+	}{
+		"Stable": {
+			reason: "The name must not change across Crossplane versions.",
+			repo:   "index.docker.io/crossplane/provider-aws",
+			want:   "provider-aws-f69c57d5",
+		},
+		"LongSegment": {
+			reason: "The last segment of the path should be truncated so that the name is at most 63 characters.",
+			repo:   "registry.example.com/acme/" + strings.Repeat("provider-", 10),
+			want:   "provider-provider-provider-provider-provider-provider-fb7ed428",
+		},
+		"NoValidCharacters": {
+			reason: "A repository whose last segment has no valid characters should be named for its hash alone.",
+			repo:   "registry.example.com/___",
+			want:   "3e3cd831",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := HashedPackageName(tc.repo)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nHashedPackageName(...): -want, +got:\n%s", tc.reason, diff)
+			}
+			if errs := validation.IsDNS1123Label(got); len(errs) > 0 {
+				t.Errorf("\n%s\nHashedPackageName(...): %q is not a DNS label: %v", tc.reason, got, errs)
+			}
+		})
+	}
+}
+
 func TestSourceFromReference(t *testing.T) {
 	cases := map[string]struct {
 		reason string
@@ -167,6 +295,30 @@ func TestSourceFromReference(t *testing.T) {
 			}(),
 			want: "hasheddan/xpkg-test",
 		},
+		"SuccessfulNoTag": {
+			reason: "A reference without a tag should be returned unchanged, even if its repository ends with the implied latest tag.",
+			arg: func() name.Reference {
+				ref, _ := name.ParseReference("hasheddan/xpkg-latest", name.WithDefaultRegistry(""))
+				return ref
+			}(),
+			want: "hasheddan/xpkg-latest",
+		},
+		"SuccessfulPortNoTag": {
+			reason: "The port of a registry should not be mistaken for a tag.",
+			arg: func() name.Reference {
+				ref, _ := name.ParseReference("registry.example.org:5000/hasheddan/xpkg-test")
+				return ref
+			}(),
+			want: "registry.example.org:5000/hasheddan/xpkg-test",
+		},
+		"SuccessfulPortWithTag": {
+			reason: "The tag of a reference to a registry with a port should be stripped.",
+			arg: func() name.Reference {
+				ref, _ := name.ParseReference("registry.example.org:5000/hasheddan/xpkg-test:v0.1.0")
+				return ref
+			}(),
+			want: "registry.example.org:5000/hasheddan/xpkg-test",
+		},
 	}
 
 	for name, tc := range cases {
@@ -227,3 +379,115 @@ func TestBuildPath(t *testing.T) {
 		})
 	}
 }
+
+func TestParseSource(t *testing.T) {
+	digest := "sha256:c88b938d6e7b2ed43d40b71e5a55df9c60fa653bea0c0961f3294fac46d5b56e"
+
+	type want struct {
+		source     string
+		identifier string
+		err        bool
+	}
+
+	cases := map[string]struct {
+		reason string
+		ref    string
+		want   want
+	}{
+		"Tag": {
+			reason: "We should return the source and tag of a tagged reference.",
+			ref:    "xpkg.upbound.io/crossplane/provider-aws:v1.2.3",
+			want:   want{source: "xpkg.upbound.io/crossplane/provider-aws", identifier: "v1.2.3"},
+		},
+		"Digest": {
+			reason: "We should return the source and digest of a reference by digest.",
+			ref:    "xpkg.upbound.io/crossplane/provider-aws@" + digest,
+			want:   want{source: "xpkg.upbound.io/crossplane/provider-aws", identifier: digest},
+		},
+		"PortWithTag": {
+			reason: "We should not mistake the port of a registry for a tag.",
+			ref:    "registry.example.org:5000/crossplane/provider-aws:v1.2.3",
+			want:   want{source: "registry.example.org:5000/crossplane/provider-aws", identifier: "v1.2.3"},
+		},
+		"NoTag": {
+			reason: "We should return the source of a reference with no tag unchanged, with the implied latest tag.",
+			ref:    "crossplane/provider-aws",
+			want:   want{source: "crossplane/provider-aws", identifier: "latest"},
+		},
+		"Invalid": {
+			reason: "We should return an error if the reference cannot be parsed.",
+			ref:    "crossplane/provider-aws:v1:v2",
+			want:   want{err: true},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			source, identifier, err := ParseSource(tc.ref)
+			if diff := cmp.Diff(tc.want.err, err != nil); diff != "" {
+				t.Errorf("\n%s\nParseSource(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.source, source); diff != "" {
+				t.Errorf("\n%s\nParseSource(...): -want source, +got source:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.identifier, identifier); diff != "" {
+				t.Errorf("\n%s\nParseSource(...): -want identifier, +got identifier:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestVersionedSource(t *testing.T) {
+	digest := "sha256:c88b938d6e7b2ed43d40b71e5a55df9c60fa653bea0c0961f3294fac46d5b56e"
+
+	type args struct {
+		ref     string
+		version string
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   string
+	}{
+		"NoTag": {
+			reason: "We should append the version to a reference without a tag.",
+			args:   args{ref: "xpkg.upbound.io/crossplane/provider-aws", version: "v1.2.3"},
+			want:   "xpkg.upbound.io/crossplane/provider-aws:v1.2.3",
+		},
+		"Tag": {
+			reason: "We should replace the tag of a tagged reference, rather than appending another.",
+			args:   args{ref: "xpkg.upbound.io/crossplane/provider-aws:v1.2.3", version: "v1.2.3"},
+			want:   "xpkg.upbound.io/crossplane/provider-aws:v1.2.3",
+		},
+		"Digest": {
+			reason: "We should replace the digest of a reference by digest.",
+			args:   args{ref: "xpkg.upbound.io/crossplane/provider-aws@" + digest, version: "v1.2.3"},
+			want:   "xpkg.upbound.io/crossplane/provider-aws:v1.2.3",
+		},
+		"TagToDigest": {
+			reason: "We should replace the tag of a tagged reference with a digest.",
+			args:   args{ref: "xpkg.upbound.io/crossplane/provider-aws:v1.2.3", version: digest},
+			want:   "xpkg.upbound.io/crossplane/provider-aws@" + digest,
+		},
+		"Port": {
+			reason: "We should not mistake the port of a registry for a tag.",
+			args:   args{ref: "registry.example.org:5000/crossplane/provider-aws", version: "v1.2.3"},
+			want:   "registry.example.org:5000/crossplane/provider-aws:v1.2.3",
+		},
+		"PortWithTag": {
+			reason: "We should replace the tag of a reference to a registry with a port.",
+			args:   args{ref: "registry.example.org:5000/crossplane/provider-aws:v1.0.0", version: "v1.2.3"},
+			want:   "registry.example.org:5000/crossplane/provider-aws:v1.2.3",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := VersionedSource(tc.args.ref, tc.args.version)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nVersionedSource(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
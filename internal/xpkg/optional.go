@@ -0,0 +1,125 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package xpkg
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+)
+
+// MsgOptionalMissing describes an optional dependency that is not installed.
+const MsgOptionalMissing = "optional, not installed"
+
+// An OptionalDependency is a dependency that every package that depends on
+// it declared optional, and that is either not installed, or installed at a
+// version or type that doesn't satisfy one or more of those packages.
+type OptionalDependency struct {
+	Package      string
+	Requirements []Requirement
+
+	// Version the dependency is installed at. Empty if it's not installed.
+	Version string
+
+	// Violations describes each requirement the installed package doesn't
+	// satisfy.
+	Violations []string
+}
+
+// Installed returns true if the optional dependency is installed.
+func (o OptionalDependency) Installed() bool {
+	return o.Version != ""
+}
+
+// Message describes why the optional dependency is unsatisfied.
+func (o OptionalDependency) Message() string {
+	if !o.Installed() {
+		return MsgOptionalMissing
+	}
+	return strings.Join(o.Violations, "; ")
+}
+
+// OptionalDependencies returns the optional dependencies of the supplied
+// packages that are either not installed, or installed but don't satisfy the
+// packages that declared them optional, sorted by package. A dependency that
+// any package requires is not optional; it's missing or conflicting instead.
+// The requirements of a dependency that isn't installed are those of every
+// package that declared it, while those of an installed dependency are only
+// the ones it violates. An installed dependency whose version can't be
+// verified is considered satisfied.
+func OptionalDependencies(pkgs []v1beta1.LockPackage) []OptionalDependency {
+	installed := map[string]v1beta1.LockPackage{}
+	required := map[string]bool{}
+	for _, p := range pkgs {
+		installed[p.Identifier()] = p
+		for _, d := range p.Dependencies {
+			if !d.Optional {
+				required[d.Identifier()] = true
+			}
+		}
+	}
+
+	found := map[string]*OptionalDependency{}
+	for _, p := range pkgs {
+		for _, d := range p.Dependencies {
+			if !d.Optional || required[d.Identifier()] {
+				continue
+			}
+			rq := Requirement{Parent: p.Source, Constraint: d.Constraints}
+			ip, ok := installed[d.Identifier()]
+			if !ok {
+				o := found[d.Identifier()]
+				if o == nil {
+					o = &OptionalDependency{Package: d.Package}
+					found[d.Identifier()] = o
+				}
+				o.Requirements = append(o.Requirements, rq)
+				continue
+			}
+			if !wrongType(d.Type, ip.Type) && satisfies(ip.Version, d.Constraints) {
+				continue
+			}
+			o := found[d.Identifier()]
+			if o == nil {
+				o = &OptionalDependency{Package: ip.Source, Version: ip.Version}
+				found[d.Identifier()] = o
+			}
+			o.Requirements = append(o.Requirements, rq)
+			c := EdgeCheck{
+				Dependent:  p.Source,
+				Dependency: ip.Source,
+				Version:    ip.Version,
+				Constraint: d.Constraints,
+				Declared:   d.Type,
+				Type:       ip.Type,
+				Optional:   true,
+				State:      EdgeViolated,
+			}
+			o.Violations = append(o.Violations, c.String())
+		}
+	}
+
+	out := make([]OptionalDependency, 0, len(found))
+	for _, o := range found {
+		sort.SliceStable(o.Requirements, func(i, j int) bool { return o.Requirements[i].Parent < o.Requirements[j].Parent })
+		sort.Strings(o.Violations)
+		out = append(out, *o)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Package < out[j].Package })
+	return out
+}
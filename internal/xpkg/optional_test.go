@@ -0,0 +1,134 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package xpkg
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+)
+
+func TestOptionalDependencies(t *testing.T) {
+	config := func(source string, deps ...v1beta1.Dependency) v1beta1.LockPackage {
+		return v1beta1.LockPackage{Source: source, Type: v1beta1.ConfigurationPackageType, Version: "v1.0.0", Dependencies: deps}
+	}
+	optional := func(constraints string) v1beta1.Dependency {
+		return v1beta1.Dependency{Package: "crossplane/provider-aws", Type: v1beta1.ProviderPackageType, Constraints: constraints, Optional: true}
+	}
+	aws := func(version string) v1beta1.LockPackage {
+		return v1beta1.LockPackage{Source: "crossplane/provider-aws", Type: v1beta1.ProviderPackageType, Version: version}
+	}
+
+	cases := map[string]struct {
+		reason string
+		pkgs   []v1beta1.LockPackage
+		want   []OptionalDependency
+	}{
+		"Missing": {
+			reason: "An optional dependency that isn't installed should be reported with the requirements of every package that declared it.",
+			pkgs: []v1beta1.LockPackage{
+				config("cool/config-b", optional(">=v0.2.0")),
+				config("cool/config-a", optional(">=v0.1.0")),
+			},
+			want: []OptionalDependency{{
+				Package: "crossplane/provider-aws",
+				Requirements: []Requirement{
+					{Parent: "cool/config-a", Constraint: ">=v0.1.0"},
+					{Parent: "cool/config-b", Constraint: ">=v0.2.0"},
+				},
+			}},
+		},
+		"Required": {
+			reason: "A dependency that any package requires is not optional.",
+			pkgs: []v1beta1.LockPackage{
+				config("cool/config-a", optional(">=v0.1.0")),
+				config("cool/config-b", v1beta1.Dependency{Package: "crossplane/provider-aws", Type: v1beta1.ProviderPackageType, Constraints: ">=v0.1.0"}),
+			},
+			want: []OptionalDependency{},
+		},
+		"Satisfied": {
+			reason: "An installed optional dependency that satisfies its constraint should not be reported.",
+			pkgs:   []v1beta1.LockPackage{config("cool/config", optional(">=v0.1.0")), aws("v0.2.0")},
+			want:   []OptionalDependency{},
+		},
+		"Violated": {
+			reason: "An installed optional dependency should be reported with only the requirements it violates.",
+			pkgs: []v1beta1.LockPackage{
+				config("cool/config-a", optional(">=v0.1.0")),
+				config("cool/config-b", optional(">=v0.3.0")),
+				aws("v0.2.0"),
+			},
+			want: []OptionalDependency{{
+				Package:      "crossplane/provider-aws",
+				Requirements: []Requirement{{Parent: "cool/config-b", Constraint: ">=v0.3.0"}},
+				Version:      "v0.2.0",
+				Violations:   []string{`package cool/config-b requires crossplane/provider-aws ">=v0.3.0" but version v0.2.0 is installed`},
+			}},
+		},
+		"WrongType": {
+			reason: "An installed optional dependency of the wrong type should be reported whatever its version.",
+			pkgs: []v1beta1.LockPackage{
+				config("cool/config", v1beta1.Dependency{Package: "crossplane/provider-aws", Type: v1beta1.ConfigurationPackageType, Constraints: ">=v0.1.0", Optional: true}),
+				aws("v0.2.0"),
+			},
+			want: []OptionalDependency{{
+				Package:      "crossplane/provider-aws",
+				Requirements: []Requirement{{Parent: "cool/config", Constraint: ">=v0.1.0"}},
+				Version:      "v0.2.0",
+				Violations:   []string{"package cool/config requires crossplane/provider-aws to be a Configuration but it is a Provider"},
+			}},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := OptionalDependencies(tc.pkgs)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nOptionalDependencies(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestOptionalDependencyMessage(t *testing.T) {
+	cases := map[string]struct {
+		reason string
+		o      OptionalDependency
+		want   string
+	}{
+		"Missing": {
+			reason: "An optional dependency that isn't installed should say so.",
+			o:      OptionalDependency{Package: "crossplane/provider-aws"},
+			want:   MsgOptionalMissing,
+		},
+		"Violated": {
+			reason: "An installed optional dependency should describe each violation.",
+			o:      OptionalDependency{Package: "crossplane/provider-aws", Version: "v0.2.0", Violations: []string{"a", "b"}},
+			want:   "a; b",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if diff := cmp.Diff(tc.want, tc.o.Message()); diff != "" {
+				t.Errorf("\n%s\nMessage(): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
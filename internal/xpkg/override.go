@@ -0,0 +1,69 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package xpkg
+
+import (
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+	"github.com/crossplane/crossplane/internal/xpkg/canonical"
+)
+
+// Overrides force the version of dependencies, keyed by the canonical
+// identifier of their repository. Each is an exact version or a semantic
+// version constraint.
+type Overrides map[string]string
+
+// NewOverrides returns the supplied dependency overrides, keyed by the
+// canonical identifier of their repository. A later override of the same
+// repository takes precedence over an earlier one.
+func NewOverrides(os []v1beta1.DependencyOverride) Overrides {
+	if len(os) == 0 {
+		return nil
+	}
+	out := Overrides{}
+	for _, o := range os {
+		out[canonical.Source(o.Package)] = o.Version
+	}
+	return out
+}
+
+// For returns the override of the supplied package, if any.
+func (o Overrides) For(pkg string) (string, bool) {
+	v, ok := o[canonical.Source(pkg)]
+	return v, ok
+}
+
+// WithOverrides returns a copy of the Resolver that plans missing
+// dependencies with an override at the overridden version, regardless of the
+// constraints the packages that depend on them place on their versions. The
+// Resolver it was called on is not modified.
+func (r *Resolver) WithOverrides(o Overrides) *Resolver {
+	c := *r
+	c.overrides = o
+	return &c
+}
+
+// Violated returns the supplied requirements that the supplied version does
+// not satisfy.
+func Violated(version string, reqs []Requirement) []Requirement {
+	var out []Requirement
+	for _, rq := range reqs {
+		if !satisfies(version, rq.Constraint) {
+			out = append(out, rq)
+		}
+	}
+	return out
+}
@@ -0,0 +1,110 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package xpkg
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+)
+
+func TestResolveOverrides(t *testing.T) {
+	tags := []string{"v0.1.0", "v0.2.0", "v0.3.0"}
+	provider := func(constraints string) v1beta1.Dependency {
+		return v1beta1.Dependency{Package: "crossplane/provider-aws", Type: v1beta1.ProviderPackageType, Constraints: constraints}
+	}
+	pkgs := func(constraints ...string) []v1beta1.LockPackage {
+		out := make([]v1beta1.LockPackage, len(constraints))
+		for i, c := range constraints {
+			out[i] = v1beta1.LockPackage{Source: "cool/config-" + string(rune('a'+i)), Dependencies: []v1beta1.Dependency{provider(c)}}
+		}
+		return out
+	}
+
+	type want struct {
+		version    string
+		override   string
+		violations []Requirement
+	}
+
+	cases := map[string]struct {
+		reason    string
+		overrides []v1beta1.DependencyOverride
+		pkgs      []v1beta1.LockPackage
+		want      want
+	}{
+		"NoOverride": {
+			reason: "We should select a version that satisfies every constraint if the dependency isn't overridden.",
+			overrides: []v1beta1.DependencyOverride{
+				{Package: "crossplane/provider-gcp", Version: "v0.1.0"},
+			},
+			pkgs: pkgs(">=v0.1.0", "<v0.3.0"),
+			want: want{version: "v0.2.0"},
+		},
+		"OverrideWins": {
+			reason: "We should select the overridden version of a dependency, even if another version satisfies every constraint.",
+			overrides: []v1beta1.DependencyOverride{
+				{Package: "index.docker.io/crossplane/provider-aws", Version: "v0.1.0"},
+			},
+			pkgs: pkgs(">=v0.1.0"),
+			want: want{version: "v0.1.0", override: "v0.1.0"},
+		},
+		"OverrideConstraint": {
+			reason: "We should select a version that satisfies an override that is a constraint.",
+			overrides: []v1beta1.DependencyOverride{
+				{Package: "crossplane/provider-aws", Version: "<v0.3.0"},
+			},
+			pkgs: pkgs(">=v0.1.0"),
+			want: want{version: "v0.2.0", override: "<v0.3.0"},
+		},
+		"OverrideConflicts": {
+			reason: "We should select the overridden version of a dependency even if it violates some constraints, and report which.",
+			overrides: []v1beta1.DependencyOverride{
+				{Package: "crossplane/provider-aws", Version: "v0.3.0"},
+			},
+			pkgs: pkgs(">=v0.1.0", "<v0.3.0"),
+			want: want{
+				version:    "v0.3.0",
+				override:   "v0.3.0",
+				violations: []Requirement{{Parent: "cool/config-b", Constraint: "<v0.3.0"}},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			r := NewResolver().WithOverrides(NewOverrides(tc.overrides))
+			plan, _, err := r.Resolve(context.Background(), tc.pkgs, &mockTagsFetcher{tags: tags})
+			if err != nil {
+				t.Fatalf("\n%s\nResolve(...): unexpected error: %s", tc.reason, err)
+			}
+			if len(plan) != 1 {
+				t.Fatalf("\n%s\nResolve(...): want 1 planned install, got %d", tc.reason, len(plan))
+			}
+			if plan[0].Err != nil {
+				t.Fatalf("\n%s\nResolve(...): unexpected dependency error: %s", tc.reason, plan[0].Err)
+			}
+			got := want{version: plan[0].Version, override: plan[0].Override, violations: plan[0].Violations}
+			if diff := cmp.Diff(tc.want, got, cmp.AllowUnexported(want{})); diff != "" {
+				t.Errorf("\n%s\nResolve(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
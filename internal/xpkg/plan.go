@@ -0,0 +1,247 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package xpkg
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+	"github.com/crossplane/crossplane/internal/xpkg/canonical"
+)
+
+const (
+	errRenderPlan = "cannot render dependency plan"
+)
+
+// A PlannedUpgrade is an installed package that would be upgraded because its
+// version does not satisfy the packages that depend on it.
+type PlannedUpgrade struct {
+	// Conflict the upgrade would resolve.
+	Conflict Conflict
+
+	// Version the package would be upgraded to, i.e. the lowest version
+	// that satisfies the constraints of every package that depends on it.
+	// Empty if the package cannot be upgraded, or if versions were not
+	// listed.
+	Version string
+
+	// Candidates is the number of versions of the package's repository that
+	// Version was selected from. Zero if versions were not listed.
+	Candidates int
+
+	// Downgrade is true if Version is lower than the installed version, i.e.
+	// if the packages that depend on the package require an older version.
+	Downgrade bool
+
+	// Err indicates why the package cannot be upgraded, if it can't.
+	Err error
+}
+
+// A Plan describes how the packages in a Lock would change if a package were
+// installed.
+type Plan struct {
+	// Package that would be installed.
+	Package v1beta1.LockPackage
+
+	// Creates are the missing dependencies that would be installed, sorted
+	// by identifier.
+	Creates []PlannedInstall
+
+	// Upgrades are the packages installed as dependencies that would be
+	// upgraded to satisfy the packages that depend on them, sorted by
+	// package.
+	Upgrades []PlannedUpgrade
+
+	// Conflicts are the installed packages that conflict with the packages
+	// that depend on them, and that would not be upgraded, sorted by
+	// package and reason. Only a user can resolve them.
+	Conflicts []Conflict
+}
+
+// Plan plans how to install the supplied proposed package alongside the
+// supplied installed packages, using the supplied Fetcher to list the tags of
+// their repositories. The proposed package replaces any installed version of
+// the same package. Packages that were installed as dependencies are planned
+// to be upgraded to the lowest version that satisfies the packages that
+// depend on them, as they would be if upgrades were allowed. If the Fetcher is
+// nil versions are not selected, and nothing is planned to be upgraded.
+//
+// An error is returned if the packages' dependencies cannot be built into a
+// graph, or if they cannot be sorted, in which case it is a *SortError.
+func (r *Resolver) Plan(ctx context.Context, pkgs []v1beta1.LockPackage, proposed v1beta1.LockPackage, f Fetcher) (*Plan, error) {
+	all := make([]v1beta1.LockPackage, 0, len(pkgs)+1)
+	for i := range pkgs {
+		if pkgs[i].Identifier() == proposed.Identifier() {
+			continue
+		}
+		all = append(all, pkgs[i])
+	}
+	all = append(all, proposed)
+
+	creates, conflicts, err := r.Resolve(ctx, all, f)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Plan{Package: proposed, Creates: creates}
+	for _, c := range conflicts {
+		if f == nil || c.Reason != ConflictVersion || c.Origin.Type != v1beta1.OriginDependency {
+			p.Conflicts = append(p.Conflicts, c)
+			continue
+		}
+		u := PlannedUpgrade{Conflict: c}
+		ref, err := name.ParseReference(c.Package, DefaultRegistryOptions(r.registry)...)
+		if err != nil {
+			u.Err = errors.Wrap(err, errInvalidDependency)
+			p.Upgrades = append(p.Upgrades, u)
+			continue
+		}
+		u.Version, u.Candidates, u.Err = r.Upgrade(ctx, f, ref, ConstraintsOn(all, c.Package)...)
+		u.Downgrade = IsDowngrade(c.Version, u.Version)
+		p.Upgrades = append(p.Upgrades, u)
+	}
+	return p, nil
+}
+
+// Upgrade returns the lowest version of the supplied package's repository
+// that satisfies all of the supplied constraints, and the number of versions
+// it was selected from. It returns a *DependencyError with reason
+// DependencyFetchTags if versions cannot be listed, and an error matching
+// ErrNoMatch if no version satisfies the constraints. Versions the Resolver's
+// Denylist denies are never selected; if only denied versions satisfy the
+// constraints the error is a *DeniedError. It returns a *DependencyError with
+// reason DependencyBlockedByPolicy, without listing versions, if the
+// Resolver's source policy doesn't allow the package's repository.
+func (r *Resolver) Upgrade(ctx context.Context, f Fetcher, ref name.Reference, constraints ...string) (string, int, error) {
+	return r.upgrade(ctx, f, ref, VersionSelectionLowest, constraints)
+}
+
+// Refresh returns the highest version of the supplied package's repository
+// that satisfies all of the supplied constraints, and the number of versions
+// it was selected from. It is used to find a newer version of a package that
+// already satisfies its constraints, and otherwise behaves like Upgrade.
+func (r *Resolver) Refresh(ctx context.Context, f Fetcher, ref name.Reference, constraints ...string) (string, int, error) {
+	return r.upgrade(ctx, f, ref, VersionSelectionHighest, constraints)
+}
+
+// upgrade returns the version of the supplied package's repository the
+// supplied selection selects from those that satisfy all of the supplied
+// constraints.
+func (r *Resolver) upgrade(ctx context.Context, f Fetcher, ref name.Reference, s VersionSelection, constraints []string) (string, int, error) {
+	if err := r.blocked(ref); err != nil {
+		return "", 0, err
+	}
+
+	// We list versions to confirm the version we upgrade to exists.
+	versions, err := r.Versions(ctx, f, ref)
+	if err != nil {
+		return "", 0, &DependencyError{Reason: DependencyFetchTags, err: err}
+	}
+	versions, excluded := exclude(versions, r.denied(ctx, ref))
+	v, err := r.selector.Select(versions, s, constraints...)
+	if errors.Is(err, ErrNoMatch) {
+		if derr := r.deniedMatches(ref.Context().Name(), excluded, constraints); derr != nil {
+			return "", len(versions), derr
+		}
+	}
+	if err != nil {
+		return "", len(versions), err
+	}
+	return v, len(versions), nil
+}
+
+// ConstraintsOn returns the constraints that the supplied packages place on
+// the supplied package, in the order they are declared.
+func ConstraintsOn(pkgs []v1beta1.LockPackage, pkg string) []string {
+	constraints := []string{}
+	for _, p := range pkgs {
+		for _, d := range p.Dependencies {
+			if canonical.Equal(d.Package, pkg) {
+				constraints = append(constraints, d.Constraints)
+			}
+		}
+	}
+	return constraints
+}
+
+// RenderText writes the plan to the supplied writer as text, one change per
+// line.
+func (p *Plan) RenderText(w io.Writer) error {
+	lines := []string{fmt.Sprintf("Install %s %s", p.Package.Source, p.Package.Version)}
+	for _, c := range p.Creates {
+		lines = append(lines, "  create "+c.describe())
+	}
+	for _, u := range p.Upgrades {
+		verb := "upgrade"
+		if u.Downgrade {
+			verb = "downgrade"
+		}
+		lines = append(lines, "  "+verb+" "+u.describe())
+	}
+	for _, c := range p.Conflicts {
+		lines = append(lines, "  conflict "+c.Error())
+	}
+	if len(lines) == 1 {
+		lines = append(lines, "  no changes to dependencies")
+	}
+	_, err := fmt.Fprintln(w, strings.Join(lines, "\n"))
+	return errors.Wrap(err, errRenderPlan)
+}
+
+// describe returns a one line description of the planned install, e.g.
+// crossplane/provider-aws v0.20.0 (Provider, ">=v0.20.0" required by cool/config)
+func (p PlannedInstall) describe() string {
+	version := p.Version
+	if version == "" {
+		version = "<unselected>"
+	}
+	details := []string{}
+	if p.Dependency.Type != "" {
+		details = append(details, string(p.Dependency.Type))
+	}
+	for _, rq := range p.Requirements {
+		details = append(details, fmt.Sprintf(requiredByFmt, rq.Constraint, rq.Parent))
+	}
+	if p.Err != nil {
+		details = append(details, "error: "+p.Err.Error())
+	}
+	return fmt.Sprintf("%s %s (%s)", p.Dependency.Package, version, strings.Join(details, ", "))
+}
+
+// describe returns a one line description of the planned upgrade, e.g.
+// crossplane/provider-aws v0.20.0 -> v0.21.0 (">=v0.21.0" required by cool/config)
+func (u PlannedUpgrade) describe() string {
+	version := u.Version
+	if version == "" {
+		version = "<unselected>"
+	}
+	details := []string{}
+	for _, rq := range u.Conflict.Requirements {
+		details = append(details, fmt.Sprintf(requiredByFmt, rq.Constraint, rq.Parent))
+	}
+	if u.Err != nil {
+		details = append(details, "error: "+u.Err.Error())
+	}
+	return fmt.Sprintf("%s %s -> %s (%s)", u.Conflict.Package, u.Conflict.Version, version, strings.Join(details, ", "))
+}
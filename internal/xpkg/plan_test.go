@@ -0,0 +1,233 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package xpkg
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-containerregistry/pkg/name"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+)
+
+func TestResolverPlan(t *testing.T) {
+	errBoom := errors.New("boom")
+	tags := []string{"v0.1.0", "v0.2.0", "v0.3.0"}
+
+	app := func(deps ...v1beta1.Dependency) v1beta1.LockPackage {
+		return v1beta1.LockPackage{Source: "cool/config-app", Type: v1beta1.ConfigurationPackageType, Version: "v1.0.0", Dependencies: deps}
+	}
+	aws := func(constraints string) v1beta1.Dependency {
+		return v1beta1.Dependency{Package: "crossplane/provider-aws", Type: v1beta1.ProviderPackageType, Constraints: constraints}
+	}
+	installed := func(origin v1beta1.OriginType) v1beta1.LockPackage {
+		return v1beta1.LockPackage{
+			Source:  "crossplane/provider-aws",
+			Type:    v1beta1.ProviderPackageType,
+			Version: "v0.1.0",
+			Origin:  &v1beta1.PackageOrigin{Type: origin},
+		}
+	}
+
+	type args struct {
+		pkgs     []v1beta1.LockPackage
+		proposed v1beta1.LockPackage
+		fetcher  Fetcher
+	}
+	type want struct {
+		text string
+		err  bool
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"Creates": {
+			reason: "We should plan to install the highest version of each missing dependency of the proposed package.",
+			args: args{
+				proposed: app(aws(">=v0.2.0")),
+				fetcher:  &mockTagsFetcher{tags: tags},
+			},
+			want: want{text: `Install cool/config-app v1.0.0
+  create crossplane/provider-aws v0.3.0 (Provider, ">=v0.2.0" required by cool/config-app)
+`},
+		},
+		"NoFetcher": {
+			reason: "We should plan missing dependencies without selecting their versions if there is no fetcher.",
+			args: args{
+				proposed: app(aws(">=v0.2.0")),
+			},
+			want: want{text: `Install cool/config-app v1.0.0
+  create crossplane/provider-aws <unselected> (Provider, ">=v0.2.0" required by cool/config-app)
+`},
+		},
+		"Upgrades": {
+			reason: "We should plan to upgrade a package installed as a dependency to the lowest version that satisfies the proposed package.",
+			args: args{
+				pkgs:     []v1beta1.LockPackage{installed(v1beta1.OriginDependency)},
+				proposed: app(aws(">=v0.2.0")),
+				fetcher:  &mockTagsFetcher{tags: tags},
+			},
+			want: want{text: `Install cool/config-app v1.0.0
+  upgrade crossplane/provider-aws v0.1.0 -> v0.2.0 (">=v0.2.0" required by cool/config-app)
+`},
+		},
+		"Downgrades": {
+			reason: "We should report that upgrading a package installed as a dependency would downgrade it.",
+			args: args{
+				pkgs:     []v1beta1.LockPackage{{Source: "crossplane/provider-aws", Type: v1beta1.ProviderPackageType, Version: "v0.3.0", Origin: &v1beta1.PackageOrigin{Type: v1beta1.OriginDependency}}},
+				proposed: app(aws("<v0.3.0")),
+				fetcher:  &mockTagsFetcher{tags: tags},
+			},
+			want: want{text: `Install cool/config-app v1.0.0
+  downgrade crossplane/provider-aws v0.3.0 -> v0.1.0 ("<v0.3.0" required by cool/config-app)
+`},
+		},
+		"NoUpgradeVersion": {
+			reason: "We should report why a package installed as a dependency can't be upgraded.",
+			args: args{
+				pkgs:     []v1beta1.LockPackage{installed(v1beta1.OriginDependency)},
+				proposed: app(aws(">=v1.0.0")),
+				fetcher:  &mockTagsFetcher{tags: tags},
+			},
+			want: want{text: `Install cool/config-app v1.0.0
+  upgrade crossplane/provider-aws v0.1.0 -> <unselected> (">=v1.0.0" required by cool/config-app, error: highest available version is v0.3.0 but constraints require >=v1.0.0)
+`},
+		},
+		"ErrFetchTags": {
+			reason: "We should report why a package installed as a dependency can't be upgraded if we can't list its versions.",
+			args: args{
+				pkgs:     []v1beta1.LockPackage{installed(v1beta1.OriginDependency)},
+				proposed: app(aws(">=v0.2.0")),
+				fetcher:  &mockTagsFetcher{err: errBoom},
+			},
+			want: want{text: `Install cool/config-app v1.0.0
+  upgrade crossplane/provider-aws v0.1.0 -> <unselected> (">=v0.2.0" required by cool/config-app, error: boom)
+`},
+		},
+		"Conflicts": {
+			reason: "We should report a conflict, rather than an upgrade, for a package a user installed.",
+			args: args{
+				pkgs:     []v1beta1.LockPackage{installed(v1beta1.OriginUser)},
+				proposed: app(aws(">=v0.2.0")),
+				fetcher:  &mockTagsFetcher{tags: tags},
+			},
+			want: want{text: `Install cool/config-app v1.0.0
+  conflict installed version v0.1.0 of package crossplane/provider-aws does not satisfy ">=v0.2.0" required by cool/config-app
+`},
+		},
+		"Replaces": {
+			reason: "We should plan against the proposed package rather than any installed version of it.",
+			args: args{
+				pkgs: []v1beta1.LockPackage{
+					{Source: "cool/config-app", Type: v1beta1.ConfigurationPackageType, Version: "v0.9.0", Dependencies: []v1beta1.Dependency{aws(">=v0.2.0")}},
+				},
+				proposed: app(),
+				fetcher:  &mockTagsFetcher{tags: tags},
+			},
+			want: want{text: `Install cool/config-app v1.0.0
+  no changes to dependencies
+`},
+		},
+		"Cycle": {
+			reason: "We should return an error if the proposed package would introduce a dependency cycle.",
+			args: args{
+				pkgs: []v1beta1.LockPackage{
+					{Source: "crossplane/provider-aws", Type: v1beta1.ConfigurationPackageType, Version: "v0.1.0", Dependencies: []v1beta1.Dependency{
+						{Package: "cool/config-app", Type: v1beta1.ConfigurationPackageType, Constraints: ">=v1.0.0"},
+					}},
+				},
+				proposed: app(v1beta1.Dependency{Package: "crossplane/provider-aws", Type: v1beta1.ConfigurationPackageType, Constraints: ">=v0.1.0"}),
+				fetcher:  &mockTagsFetcher{tags: tags},
+			},
+			want: want{err: true},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			p, err := NewResolver().Plan(context.Background(), tc.args.pkgs, tc.args.proposed, tc.args.fetcher)
+			if tc.want.err {
+				var serr *SortError
+				if !errors.As(err, &serr) {
+					t.Errorf("\n%s\nPlan(...): want *SortError, got %v", tc.reason, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("\n%s\nPlan(...): %s", tc.reason, err)
+			}
+			buf := &bytes.Buffer{}
+			if err := p.RenderText(buf); err != nil {
+				t.Fatalf("\n%s\nRenderText(...): %s", tc.reason, err)
+			}
+			if diff := cmp.Diff(tc.want.text, buf.String()); diff != "" {
+				t.Errorf("\n%s\nPlan(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	errBoom := errors.New("boom")
+	ref, _ := name.ParseReference("crossplane/provider-aws")
+
+	type want struct {
+		version string
+		err     error
+	}
+
+	cases := map[string]struct {
+		reason      string
+		fetcher     Fetcher
+		constraints []string
+		want        want
+	}{
+		"Highest": {
+			reason:      "We should refresh to the highest version that satisfies every constraint, unlike Upgrade.",
+			fetcher:     &mockTagsFetcher{tags: []string{"v0.1.0", "v0.2.0", "v0.3.0", "v1.0.0"}},
+			constraints: []string{">=v0.2.0", "<v1.0.0"},
+			want:        want{version: "v0.3.0"},
+		},
+		"FetchError": {
+			reason:      "We should return a DependencyError if we cannot list versions.",
+			fetcher:     &mockTagsFetcher{err: errBoom},
+			constraints: []string{">=v0.2.0"},
+			want:        want{err: &DependencyError{Reason: DependencyFetchTags, err: errBoom}},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			v, _, err := NewResolver().Refresh(context.Background(), tc.fetcher, ref, tc.constraints...)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nRefresh(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.version, v); diff != "" {
+				t.Errorf("\n%s\nRefresh(...): -want version, +got version:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
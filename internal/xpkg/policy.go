@@ -0,0 +1,140 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package xpkg
+
+import (
+	"path"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+
+	"github.com/crossplane/crossplane/internal/xpkg/canonical"
+)
+
+const (
+	errBlockedByPolicyFmt = "dependency blocked by policy: %s"
+	errInvalidPatternFmt  = "invalid package source pattern %q"
+)
+
+// A SourcePolicy decides which repositories dependency packages may be
+// installed from. Patterns are matched against the canonical repository of a
+// package, e.g. xpkg.upbound.io/crossplane/provider-aws, one path segment at a
+// time. Each segment of a pattern may use the wildcards supported by
+// path.Match, e.g. xpkg.upbound.io/*/provider-*, and a segment that is exactly
+// ** matches any number of segments, e.g. ghcr.io/example/**.
+type SourcePolicy struct {
+	// Allow lists the patterns a repository must match one of to be
+	// allowed. Every repository is allowed if Allow is empty.
+	Allow []string
+
+	// Deny lists patterns that a repository must not match. Deny takes
+	// precedence over Allow.
+	Deny []string
+}
+
+// Validate returns an error if any of the policy's patterns is malformed.
+func (p SourcePolicy) Validate() error {
+	for _, pt := range append(append([]string{}, p.Allow...), p.Deny...) {
+		if _, err := matchSegments(strings.Split(pt, "/"), nil); err != nil {
+			return errors.Wrapf(err, errInvalidPatternFmt, pt)
+		}
+	}
+	return nil
+}
+
+// Allows returns true if the policy allows installing packages from the
+// supplied repository. Malformed patterns fail closed; a malformed deny
+// pattern denies every repository and a malformed allow pattern allows none.
+func (p SourcePolicy) Allows(repo string) bool {
+	repo = canonical.Source(repo)
+	for _, pt := range p.Deny {
+		if ok, err := MatchSource(pt, repo); ok || err != nil {
+			return false
+		}
+	}
+	if len(p.Allow) == 0 {
+		return true
+	}
+	for _, pt := range p.Allow {
+		if ok, err := MatchSource(pt, repo); ok && err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchSource returns true if the supplied repository matches the supplied
+// pattern. Both are split into path segments, each of which is matched using
+// path.Match. A pattern segment that is exactly ** matches zero or more
+// repository segments.
+func MatchSource(pattern, repo string) (bool, error) {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(repo, "/"))
+}
+
+func matchSegments(pattern, repo []string) (bool, error) {
+	if len(pattern) == 0 {
+		return len(repo) == 0, nil
+	}
+	if pattern[0] == "**" {
+		// Try consuming every possible number of repository segments,
+		// validating the rest of the pattern even if nothing is left.
+		matched := false
+		for i := 0; i <= len(repo); i++ {
+			ok, err := matchSegments(pattern[1:], repo[i:])
+			if err != nil {
+				return false, err
+			}
+			matched = matched || ok
+		}
+		return matched, nil
+	}
+	seg := ""
+	if len(repo) > 0 {
+		seg = repo[0]
+	}
+	ok, err := path.Match(pattern[0], seg)
+	if err != nil {
+		return false, err
+	}
+	rest := repo
+	if len(rest) > 0 {
+		rest = rest[1:]
+	}
+	more, err := matchSegments(pattern[1:], rest)
+	return ok && more && len(repo) > 0, err
+}
+
+// WithSourcePolicy specifies the policy that decides which repositories the
+// Resolver may install and upgrade dependencies from. By default every
+// repository is allowed.
+func WithSourcePolicy(p SourcePolicy) ResolverOption {
+	return func(r *Resolver) {
+		r.policy = p
+	}
+}
+
+// blocked returns an error if the Resolver's source policy doesn't allow
+// packages to be installed from the supplied reference's repository.
+func (r *Resolver) blocked(ref name.Reference) *DependencyError {
+	repo := canonical.Source(ref.Context().Name())
+	if r.policy.Allows(repo) {
+		return nil
+	}
+	return &DependencyError{Reason: DependencyBlockedByPolicy, err: errors.Errorf(errBlockedByPolicyFmt, repo)}
+}
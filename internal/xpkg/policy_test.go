@@ -0,0 +1,292 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package xpkg
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-containerregistry/pkg/name"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+)
+
+func TestMatchSource(t *testing.T) {
+	type want struct {
+		match bool
+		err   bool
+	}
+
+	cases := map[string]struct {
+		reason  string
+		pattern string
+		repo    string
+		want    want
+	}{
+		"Exact": {
+			reason:  "A pattern without wildcards should match only the same repository.",
+			pattern: "xpkg.upbound.io/crossplane/provider-aws",
+			repo:    "xpkg.upbound.io/crossplane/provider-aws",
+			want:    want{match: true},
+		},
+		"WildcardRegistry": {
+			reason:  "A wildcard should match any registry.",
+			pattern: "*/crossplane/provider-aws",
+			repo:    "ghcr.io/crossplane/provider-aws",
+			want:    want{match: true},
+		},
+		"WildcardOrg": {
+			reason:  "A wildcard should match any organization.",
+			pattern: "xpkg.upbound.io/*/provider-aws",
+			repo:    "xpkg.upbound.io/upbound/provider-aws",
+			want:    want{match: true},
+		},
+		"WildcardRepoPrefix": {
+			reason:  "A wildcard should match part of a segment.",
+			pattern: "xpkg.upbound.io/crossplane/provider-*",
+			repo:    "xpkg.upbound.io/crossplane/provider-gcp",
+			want:    want{match: true},
+		},
+		"WildcardDoesNotCrossSegments": {
+			reason:  "A single wildcard should not match more than one segment.",
+			pattern: "xpkg.upbound.io/*",
+			repo:    "xpkg.upbound.io/crossplane/provider-aws",
+			want:    want{match: false},
+		},
+		"DoubleWildcard": {
+			reason:  "A ** segment should match any number of segments.",
+			pattern: "ghcr.io/**",
+			repo:    "ghcr.io/example/team/provider-aws",
+			want:    want{match: true},
+		},
+		"DoubleWildcardMiddle": {
+			reason:  "A ** segment should match segments in the middle of a repository.",
+			pattern: "ghcr.io/**/provider-aws",
+			repo:    "ghcr.io/example/team/provider-aws",
+			want:    want{match: true},
+		},
+		"DoubleWildcardNoSegments": {
+			reason:  "A ** segment should match zero segments.",
+			pattern: "ghcr.io/**/provider-aws",
+			repo:    "ghcr.io/provider-aws",
+			want:    want{match: true},
+		},
+		"TooFewSegments": {
+			reason:  "A pattern should not match a repository with fewer segments.",
+			pattern: "xpkg.upbound.io/crossplane/provider-aws/extra",
+			repo:    "xpkg.upbound.io/crossplane/provider-aws",
+			want:    want{match: false},
+		},
+		"DifferentRegistry": {
+			reason:  "A pattern should not match a repository in a different registry.",
+			pattern: "xpkg.upbound.io/crossplane/provider-aws",
+			repo:    "index.docker.io/crossplane/provider-aws",
+			want:    want{match: false},
+		},
+		"Malformed": {
+			reason:  "A malformed pattern should return an error.",
+			pattern: "xpkg.upbound.io/[crossplane/provider-aws",
+			repo:    "xpkg.upbound.io/crossplane/provider-aws",
+			want:    want{err: true},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := MatchSource(tc.pattern, tc.repo)
+			if diff := cmp.Diff(tc.want.err, err != nil); diff != "" {
+				t.Errorf("\n%s\nMatchSource(...): -want error, +got error:\n%s\n%v", tc.reason, diff, err)
+			}
+			if diff := cmp.Diff(tc.want.match, got); diff != "" {
+				t.Errorf("\n%s\nMatchSource(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestSourcePolicyAllows(t *testing.T) {
+	cases := map[string]struct {
+		reason string
+		policy SourcePolicy
+		repo   string
+		want   bool
+	}{
+		"NoPolicy": {
+			reason: "Every repository should be allowed by an empty policy.",
+			repo:   "xpkg.upbound.io/crossplane/provider-aws",
+			want:   true,
+		},
+		"Allowed": {
+			reason: "A repository that matches an allow pattern should be allowed.",
+			policy: SourcePolicy{Allow: []string{"xpkg.upbound.io/crossplane/*"}},
+			repo:   "xpkg.upbound.io/crossplane/provider-aws",
+			want:   true,
+		},
+		"NotAllowed": {
+			reason: "A repository that matches no allow pattern should not be allowed.",
+			policy: SourcePolicy{Allow: []string{"xpkg.upbound.io/crossplane/*"}},
+			repo:   "xpkg.upbound.io/example/provider-aws",
+			want:   false,
+		},
+		"Denied": {
+			reason: "A repository that matches a deny pattern should not be allowed.",
+			policy: SourcePolicy{Deny: []string{"*/example/*"}},
+			repo:   "xpkg.upbound.io/example/provider-aws",
+			want:   false,
+		},
+		"DenyTakesPrecedence": {
+			reason: "A repository that matches both an allow and a deny pattern should not be allowed.",
+			policy: SourcePolicy{Allow: []string{"xpkg.upbound.io/**"}, Deny: []string{"xpkg.upbound.io/example/*"}},
+			repo:   "xpkg.upbound.io/example/provider-aws",
+			want:   false,
+		},
+		"Canonical": {
+			reason: "Repositories should be matched in their canonical form.",
+			policy: SourcePolicy{Deny: []string{"index.docker.io/library/*"}},
+			repo:   "nginx",
+			want:   false,
+		},
+		"MalformedDeny": {
+			reason: "A malformed deny pattern should deny every repository.",
+			policy: SourcePolicy{Deny: []string{"["}},
+			repo:   "xpkg.upbound.io/crossplane/provider-aws",
+			want:   false,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := tc.policy.Allows(tc.repo)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nAllows(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestSourcePolicyValidate(t *testing.T) {
+	cases := map[string]struct {
+		reason string
+		policy SourcePolicy
+		err    bool
+	}{
+		"Valid": {
+			reason: "Well formed patterns should be valid.",
+			policy: SourcePolicy{Allow: []string{"xpkg.upbound.io/**"}, Deny: []string{"*/example/provider-[ab]*"}},
+		},
+		"MalformedAllow": {
+			reason: "A malformed allow pattern should be invalid.",
+			policy: SourcePolicy{Allow: []string{"xpkg.upbound.io/[/*"}},
+			err:    true,
+		},
+		"MalformedAfterDoubleWildcard": {
+			reason: "A malformed segment after a ** segment should be invalid.",
+			policy: SourcePolicy{Deny: []string{"**/["}},
+			err:    true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := tc.policy.Validate()
+			if diff := cmp.Diff(tc.err, err != nil); diff != "" {
+				t.Errorf("\n%s\nValidate(): -want error, +got error:\n%s\n%v", tc.reason, diff, err)
+			}
+		})
+	}
+}
+
+func TestResolveSourcePolicy(t *testing.T) {
+	errBoom := errors.New("boom")
+	dep := v1beta1.Dependency{Package: "xpkg.upbound.io/example/provider-aws", Type: v1beta1.ProviderPackageType, Constraints: ">=v0.2.0"}
+	pkgs := []v1beta1.LockPackage{{Source: "cool/config", Type: v1beta1.ConfigurationPackageType, Dependencies: []v1beta1.Dependency{dep}}}
+	ref, err := name.ParseReference(dep.Package)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type want struct {
+		source string
+		reason DependencyErrorReason
+		err    string
+	}
+
+	cases := map[string]struct {
+		reason  string
+		policy  SourcePolicy
+		fetcher Fetcher
+		want    want
+	}{
+		"Allowed": {
+			reason:  "We should install a dependency from a repository the policy allows.",
+			policy:  SourcePolicy{Allow: []string{"xpkg.upbound.io/**"}},
+			fetcher: &mockTagsFetcher{tags: []string{"v0.2.0"}},
+			want:    want{source: "xpkg.upbound.io/example/provider-aws:v0.2.0"},
+		},
+		"Blocked": {
+			reason:  "We should refuse to install a dependency from a repository the policy denies, without fetching its tags.",
+			policy:  SourcePolicy{Allow: []string{"xpkg.upbound.io/**"}, Deny: []string{"xpkg.upbound.io/example/*"}},
+			fetcher: &mockTagsFetcher{err: errBoom},
+			want: want{
+				reason: DependencyBlockedByPolicy,
+				err:    "dependency blocked by policy: xpkg.upbound.io/example/provider-aws",
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			r := NewResolver(WithSourcePolicy(tc.policy))
+			plan, _, err := r.Resolve(context.Background(), pkgs, tc.fetcher)
+			if err != nil {
+				t.Fatalf("\n%s\nResolve(...): %s", tc.reason, err)
+			}
+			if len(plan) != 1 {
+				t.Fatalf("\n%s\nResolve(...): want 1 planned install, got %d", tc.reason, len(plan))
+			}
+			p := plan[0]
+			if diff := cmp.Diff(tc.want.source, p.Source); diff != "" {
+				t.Errorf("\n%s\nResolve(...): -want source, +got source:\n%s", tc.reason, diff)
+			}
+			var reason DependencyErrorReason
+			var msg string
+			if p.Err != nil {
+				reason, msg = p.Err.Reason, p.Err.Error()
+			}
+			if diff := cmp.Diff(tc.want.reason, reason); diff != "" {
+				t.Errorf("\n%s\nResolve(...): -want reason, +got reason:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.err, msg); diff != "" {
+				t.Errorf("\n%s\nResolve(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+
+			// The policy applies to upgrades too.
+			_, _, err = r.Upgrade(context.Background(), tc.fetcher, ref, dep.Constraints)
+			reason = ""
+			var derr *DependencyError
+			if errors.As(err, &derr) {
+				reason = derr.Reason
+			}
+			if diff := cmp.Diff(tc.want.reason, reason); diff != "" {
+				t.Errorf("\n%s\nUpgrade(...): -want reason, +got reason:\n%s\n%v", tc.reason, diff, err)
+			}
+		})
+	}
+}
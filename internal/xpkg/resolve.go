@@ -0,0 +1,934 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package xpkg
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+	"github.com/crossplane/crossplane/internal/dag"
+	"github.com/crossplane/crossplane/internal/xpkg/canonical"
+)
+
+const (
+	// defaultMaxConcurrentFetches is the default maximum number of
+	// repositories whose tags are fetched at once.
+	defaultMaxConcurrentFetches = 4
+
+	packageTagFmt    = "%s:%s"
+	packageDigestFmt = "%s@%s"
+
+	digestPrefix = "sha256:"
+)
+
+const (
+	errBuildDAG                    = "cannot build DAG"
+	errSortDAG                     = "cannot sort DAG"
+	errMissingDependencyFmt        = "missing node (%s) is not a dependency"
+	errInvalidDependencyConstraint = "version constraint on dependency is invalid"
+	errInvalidDependency           = "dependency package is not valid"
+	errFetchTags                   = "cannot fetch dependency package tags"
+	errListVersions                = "cannot list dependency package versions"
+	errNoValidVersion              = "cannot find a valid version for package constraints"
+	errNoValidVersionFmt           = "dependency (%s) does not have version in constraints (%s)"
+	errHighestAvailableFmt         = "highest available version is %s"
+	errUnsatisfiableFmt            = "no version of dependency (%s) satisfies every package that depends on it: %s"
+	errNoOverlapFmt                = "allowed versions of dependency (%s) do not overlap: %s"
+	errNoAllowedVersionFmt         = "dependency (%s) has none of the allowed versions (%s)"
+	errFetchDigest                 = "cannot fetch dependency package digest"
+	errVerifyDigest                = "cannot verify dependency package digest"
+	errRecordedDigest              = "cannot get recorded dependency package digest"
+	errNoDescriptorFmt             = "registry returned no descriptor for %s"
+	errDigestMismatchFmt           = "tag %s of dependency (%s) points at digest %s, not %s recorded when it was published"
+	errInvalidPackageType          = "cannot create invalid package dependency type"
+	errUnsupportedPackageTypeFmt   = "unsupported package type %q; a dependency must be a Configuration, Provider, or Function"
+	errDependencyFmt               = "%s: dependency %s with constraints %q"
+)
+
+// A DependencyErrorReason is the reason a missing dependency cannot be
+// installed.
+type DependencyErrorReason string
+
+// Reasons a missing dependency cannot be installed.
+const (
+	// DependencyInvalid indicates the dependency's package is not valid.
+	DependencyInvalid DependencyErrorReason = "InvalidDependency"
+
+	// DependencyInvalidConstraint indicates the dependency's version
+	// constraint is not valid.
+	DependencyInvalidConstraint DependencyErrorReason = "InvalidConstraint"
+
+	// DependencyFetchTags indicates the tags of the dependency's repository
+	// could not be fetched, or its versions could not be listed. Fetching
+	// may succeed if retried.
+	DependencyFetchTags DependencyErrorReason = "FetchTags"
+
+	// DependencyNotListed indicates the Resolver's VersionSource doesn't
+	// list the dependency's repository, and the Resolver may not fall back
+	// to its tags. The repository may be listed if retried.
+	DependencyNotListed DependencyErrorReason = "NotListed"
+
+	// DependencyNoValidVersion indicates no tag of the dependency's
+	// repository satisfies its version constraint.
+	DependencyNoValidVersion DependencyErrorReason = "NoValidVersion"
+
+	// DependencyUnsupportedType indicates the dependency is a type of
+	// package that cannot be installed as a dependency.
+	DependencyUnsupportedType DependencyErrorReason = "UnsupportedType"
+
+	// DependencyFetchDigest indicates the digest of the dependency's
+	// selected tag could not be fetched, or the digest recorded when it was
+	// published could not be read. Fetching may succeed if retried.
+	DependencyFetchDigest DependencyErrorReason = "FetchDigest"
+
+	// DependencyDigestMismatch indicates the dependency's selected tag no
+	// longer points at the digest recorded when it was published.
+	DependencyDigestMismatch DependencyErrorReason = "DigestMismatch"
+
+	// DependencyVersionGroupConflict indicates the dependency is in a
+	// version group whose members have no major.minor version in common
+	// that satisfies all of their constraints.
+	DependencyVersionGroupConflict DependencyErrorReason = "VersionGroupConflict"
+
+	// DependencyBlockedByPolicy indicates the Resolver's source policy
+	// doesn't allow packages to be installed from the dependency's
+	// repository.
+	DependencyBlockedByPolicy DependencyErrorReason = "BlockedByPolicy"
+)
+
+// A DependencyError indicates why a missing dependency cannot be installed.
+type DependencyError struct {
+	Reason DependencyErrorReason
+	err    error
+}
+
+func (e *DependencyError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *DependencyError) Unwrap() error {
+	return e.err
+}
+
+// A SortError indicates the packages could not be sorted by their
+// dependencies, typically because the dependencies form a cycle. Use
+// errors.As to determine whether the underlying error is a *dag.CyclicError.
+type SortError struct {
+	err error
+}
+
+func (e *SortError) Error() string {
+	return errors.Wrap(e.err, errSortDAG).Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *SortError) Unwrap() error {
+	return e.err
+}
+
+// A PlannedInstall is a package that would be installed to satisfy a missing
+// dependency.
+type PlannedInstall struct {
+	// Dependency that is missing.
+	Dependency v1beta1.Dependency
+
+	// Name of the package that would be installed.
+	Name string
+
+	// Source of the package that would be installed, including its tag or
+	// digest. Empty if the package cannot be installed, or if versions were
+	// not selected.
+	Source string
+
+	// Version of the package that would be installed, i.e. the tag or digest
+	// it would be installed at. Empty whenever Source is.
+	Version string
+
+	// Digest the selected tag pointed at when the package was planned. Only
+	// set if the Resolver pins digests, in which case Source is pinned to
+	// the digest rather than the tag.
+	Digest string
+
+	// Pinned is true if the dependency is pinned to a digest or tag, rather
+	// than constraining its semantic version.
+	Pinned bool
+
+	// Candidates is the number of versions of the dependency's repository
+	// that Version was selected from. Zero if versions were not listed.
+	Candidates int
+
+	// Requirements of the packages that depend on the missing dependency,
+	// sorted by parent. The package that would be installed satisfies all
+	// of them.
+	Requirements []Requirement
+
+	// Override of the dependency's version that the package would be
+	// installed at, if any. See Resolver.WithOverrides.
+	Override string
+
+	// Violations are the requirements that the package that would be
+	// installed doesn't satisfy, because its version was overridden.
+	Violations []Requirement
+
+	// Depth of the missing dependency in the graph of installed packages,
+	// i.e. the length of the longest chain of packages that depend on it.
+	// The dependencies of a package that nothing depends on have depth 1.
+	// Zero if the depth is unknown.
+	Depth int
+
+	// Err indicates why the package cannot be installed, if it can't.
+	Err *DependencyError
+}
+
+// A TagOrder is the order in which a registry lists the tags of a repository.
+type TagOrder string
+
+// Tag orders.
+const (
+	// TagOrderUnknown indicates tags are listed in no particular order, so
+	// every tag must be fetched before a version can be selected.
+	TagOrderUnknown TagOrder = ""
+
+	// TagOrderAscending indicates tags are listed from lowest to highest
+	// semantic version.
+	TagOrderAscending TagOrder = "Ascending"
+
+	// TagOrderDescending indicates tags are listed from highest to lowest
+	// semantic version.
+	TagOrderDescending TagOrder = "Descending"
+)
+
+// A ResolverOption configures a Resolver.
+type ResolverOption func(*Resolver)
+
+// WithLogger specifies how the Resolver should log messages.
+func WithLogger(l logging.Logger) ResolverOption {
+	return func(r *Resolver) {
+		r.log = l
+	}
+}
+
+// WithNewDAGFn specifies how the Resolver should build its dependency graph.
+func WithNewDAGFn(fn dag.NewDAGFn) ResolverOption {
+	return func(r *Resolver) {
+		r.newDag = fn
+	}
+}
+
+// WithVersionSelection specifies which of the versions that satisfy a
+// dependency's constraint the Resolver should select.
+func WithVersionSelection(s VersionSelection) ResolverOption {
+	return func(r *Resolver) {
+		r.selection = s
+	}
+}
+
+// WithTagFilter specifies how the Resolver should filter the tags of a
+// dependency's repository before checking them against its constraint.
+func WithTagFilter(f *TagFilter) ResolverOption {
+	return func(r *Resolver) {
+		r.filter = f
+	}
+}
+
+// WithTagOrder specifies the order in which the registry lists the tags of a
+// dependency's repository. When tags are listed in the order the version
+// selection policy prefers the Resolver stops fetching pages of tags once it
+// has found a version that satisfies a dependency's constraint.
+func WithTagOrder(o TagOrder) ResolverOption {
+	return func(r *Resolver) {
+		r.order = o
+	}
+}
+
+// WithDefaultRegistry specifies the registry the Resolver should assume for
+// dependencies that don't specify one.
+func WithDefaultRegistry(registry string) ResolverOption {
+	return func(r *Resolver) {
+		r.registry = registry
+	}
+}
+
+// WithMaxConcurrentFetches specifies the maximum number of repositories the
+// Resolver should fetch the tags of at once.
+func WithMaxConcurrentFetches(n int) ResolverOption {
+	return func(r *Resolver) {
+		if n > 0 {
+			r.concurrency = n
+		}
+	}
+}
+
+// WithFetchTimeout specifies how long the Resolver should wait for the tags
+// of a dependency's repository to be fetched. A slow registry then delays
+// planning the dependencies it hosts, but not the others. A timeout of zero
+// means the Resolver waits as long as the supplied context allows.
+func WithFetchTimeout(d time.Duration) ResolverOption {
+	return func(r *Resolver) {
+		r.timeout = d
+	}
+}
+
+// WithVersionSource specifies where the Resolver should list the versions of
+// a dependency's repository. By default the Resolver lists its tags.
+func WithVersionSource(s VersionSource) ResolverOption {
+	return func(r *Resolver) {
+		r.source = s
+	}
+}
+
+// WithTagFallback specifies that the Resolver should list the tags of a
+// dependency's repository if its VersionSource doesn't list the repository.
+func WithTagFallback() ResolverOption {
+	return func(r *Resolver) {
+		r.fallback = true
+	}
+}
+
+// WithDigestPinning specifies that the Resolver should pin the source of a
+// package planned at a selected tag to the digest the tag points at. If the
+// Resolver's VersionSource is also a DigestSource the digest must match the
+// one recorded when the tag was published.
+func WithDigestPinning() ResolverOption {
+	return func(r *Resolver) {
+		r.digests = true
+	}
+}
+
+// WithVersionSelector specifies how the Resolver should parse constraints
+// and select versions. A VersionSelector may be shared by Resolvers, and
+// caches the constraints it parses.
+func WithVersionSelector(s *VersionSelector) ResolverOption {
+	return func(r *Resolver) {
+		r.selector = s
+	}
+}
+
+// A Resolver plans how to install the missing dependencies of packages. It
+// never installs anything itself.
+type Resolver struct {
+	log       logging.Logger
+	newDag    dag.NewDAGFn
+	selector  *VersionSelector
+	selection VersionSelection
+	filter    *TagFilter
+	order     TagOrder
+	registry  string
+	source    VersionSource
+	fallback  bool
+	digests   bool
+	denylist  Denylist
+	overrides Overrides
+	groups    map[string]string
+	stream    string
+	policy    SourcePolicy
+	tracer    trace.Tracer
+
+	concurrency int
+	timeout     time.Duration
+}
+
+// NewResolver returns a Resolver that selects the highest version that
+// satisfies each missing dependency's constraint, fetching the tags of up to
+// four repositories at once.
+func NewResolver(opts ...ResolverOption) *Resolver {
+	r := &Resolver{
+		log:       logging.NewNopLogger(),
+		newDag:    dag.NewMapDag,
+		selector:  NewVersionSelector(),
+		selection: VersionSelectionHighest,
+		filter:    NewTagFilter(),
+		tracer:    trace.NewNoopTracerProvider().Tracer(""),
+
+		concurrency: defaultMaxConcurrentFetches,
+	}
+	for _, o := range opts {
+		o(r)
+	}
+	return r
+}
+
+// WithSelection returns a copy of the Resolver that selects versions using
+// the supplied policy. The Resolver it was called on is not modified.
+func (r *Resolver) WithSelection(s VersionSelection) *Resolver {
+	c := *r
+	c.selection = s
+	return &c
+}
+
+// Resolve plans how to install the missing dependencies of the supplied
+// installed packages, using the supplied Fetcher to list the tags of their
+// repositories. It returns a PlannedInstall for each missing dependency,
+// sorted by identifier, and any installed package whose version
+// conflicts with the constraints of the packages that depend on it. A
+// PlannedInstall that cannot be installed reports why in its Err. Missing
+// dependencies in the same version group are planned at the same
+// major.minor version as each other and the group's installed packages. If
+// the Fetcher is nil versions are not selected, and only the missing
+// dependencies are planned.
+//
+// An error is returned if the packages' dependencies cannot be built into a
+// graph, or if they cannot be sorted, in which case it is a *SortError.
+func (r *Resolver) Resolve(ctx context.Context, pkgs []v1beta1.LockPackage, f Fetcher) ([]PlannedInstall, []Conflict, error) {
+	d := r.newDag()
+	implied, err := d.Init(v1beta1.ToNodes(pkgs...))
+	if err != nil {
+		return nil, nil, errors.Wrap(err, errBuildDAG)
+	}
+
+	// Make sure we don't have any cyclical imports. A cycle cannot be
+	// installed, so there is nothing to plan.
+	depths, err := dag.Depths(d)
+	if err != nil {
+		return nil, nil, &SortError{err: declared(err, pkgs)}
+	}
+
+	// Multiple packages may depend on the same missing package.
+	missing := make([]dag.ImpliedNode, 0, len(implied))
+	seen := map[string]bool{}
+	for _, n := range implied {
+		id := n.String()
+		if n.Node != nil {
+			id = n.Identifier()
+		}
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		missing = append(missing, n)
+	}
+
+	// Planning a missing dependency is dominated by fetching the tags of
+	// its repository, so we plan them concurrently. A dependency that can't
+	// be planned is reported in its PlannedInstall, and doesn't prevent us
+	// from planning the others.
+	plan := make([]PlannedInstall, len(missing))
+	sem := make(chan struct{}, r.concurrency)
+	g := &errgroup.Group{}
+	for i := range missing {
+		i := i
+		// The DAG doesn't know what type of node it implied. Anything
+		// other than a dependency is reported rather than planned.
+		dep, ok := missing[i].Node.(*v1beta1.Dependency)
+		if !ok {
+			err := errors.Wrap(errors.Errorf(errMissingDependencyFmt, missing[i]), errInvalidDependency)
+			plan[i] = PlannedInstall{Dependency: v1beta1.Dependency{Package: missing[i].String()}, Err: &DependencyError{Reason: DependencyInvalid, err: err}}
+			continue
+		}
+		reqs := requirements(d, dep)
+		g.Go(func() error {
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			plan[i] = r.tracedPlan(ctx, dep, reqs, f)
+			plan[i].Depth = depths[dep.Identifier()]
+			return nil
+		})
+	}
+	_ = g.Wait()
+
+	// Dependencies in the same version group can only be aligned once
+	// they have all been planned.
+	r.align(ctx, plan, pkgs, f)
+
+	return plan, conflicts(pkgs), nil
+}
+
+// requirements returns the constraint each package that depends on the
+// supplied missing dependency places on its version, sorted by parent. The
+// DAG implies a missing dependency from whichever package depends on it
+// first, so the dependency itself carries only that package's constraint.
+// Packages that depend on it optionally place no constraint on the version we
+// install.
+func requirements(d dag.DAG, dep *v1beta1.Dependency) []Requirement {
+	reqs := []Requirement{}
+	parents, err := d.NodeDependents(dep.Identifier())
+	if err != nil {
+		return []Requirement{{Constraint: dep.Constraints}}
+	}
+	for _, p := range parents {
+		for _, n := range p.Neighbors() {
+			if pd, ok := n.(*v1beta1.Dependency); ok && pd.Identifier() == dep.Identifier() && !pd.Optional {
+				reqs = append(reqs, Requirement{Parent: source(p), Constraint: pd.Constraints})
+				break
+			}
+		}
+	}
+	if len(reqs) == 0 {
+		return []Requirement{{Constraint: dep.Constraints}}
+	}
+	return reqs
+}
+
+// declared returns the supplied error, with the packages in any cycle it
+// reports identified by the sources they were declared with rather than
+// their canonical identifiers.
+func declared(err error, pkgs []v1beta1.LockPackage) error {
+	cerr := &dag.CyclicError{}
+	if !errors.As(err, &cerr) {
+		return err
+	}
+	sources := map[string]string{}
+	for _, p := range pkgs {
+		sources[p.Identifier()] = p.Source
+	}
+	cycle := make([]string, len(cerr.Cycle))
+	for i, id := range cerr.Cycle {
+		cycle[i] = id
+		if s, ok := sources[id]; ok {
+			cycle[i] = s
+		}
+	}
+	return &dag.CyclicError{Cycle: cycle}
+}
+
+// source returns the source of the supplied node as it was declared, rather
+// than its canonical identifier, so that messages refer to packages the way
+// users do.
+func source(n dag.Node) string {
+	switch t := n.(type) {
+	case *v1beta1.LockPackage:
+		return t.Source
+	case *v1beta1.Dependency:
+		return t.Package
+	}
+	return n.Identifier()
+}
+
+// plan how to install the supplied missing dependency such that it satisfies
+// the supplied requirements.
+func (r *Resolver) plan(ctx context.Context, dep *v1beta1.Dependency, reqs []Requirement, f Fetcher) PlannedInstall { // nolint:gocyclo
+	p := PlannedInstall{Dependency: *dep, Requirements: reqs}
+	failed := func(reason DependencyErrorReason, msg string, err error) PlannedInstall {
+		p.Err = &DependencyError{Reason: reason, err: errors.Wrapf(err, errDependencyFmt, msg, dep.Package, dep.Constraints)}
+		return p
+	}
+	unsatisfiable := func(format, nearest string, hint *ConflictHint) PlannedInstall {
+		rs := make([]string, len(reqs))
+		for i, rq := range reqs {
+			rs[i] = fmt.Sprintf(requiredByFmt, rq.Constraint, rq.Parent)
+		}
+		err := nearestMiss(errors.Errorf(format, dep.Package, strings.Join(rs, ", ")), nearest)
+		if hint != nil {
+			err = errors.Errorf("%s; %s", err, hint)
+		}
+		p.Err = &DependencyError{Reason: DependencyNoValidVersion, err: errors.Wrap(err, errNoValidVersion)}
+		return p
+	}
+
+	// There's no point fetching tags for a type of package this version of
+	// Crossplane can't install.
+	switch dep.Type {
+	case v1beta1.ConfigurationPackageType, v1beta1.ProviderPackageType, v1beta1.FunctionPackageType:
+	default:
+		return failed(DependencyUnsupportedType, errInvalidPackageType, errors.Errorf(errUnsupportedPackageTypeFmt, dep.Type))
+	}
+
+	// An override of the dependency's version takes the place of every
+	// constraint on it. We report which constraints it violates once we
+	// know the version it selects.
+	selecting := reqs
+	if o, ok := r.overrides.For(dep.Package); ok {
+		p.Override = o
+		selecting = []Requirement{{Constraint: o}}
+	}
+
+	// Packages that depend on the same missing dependency may constrain its
+	// version differently. The semantic version library can't intersect
+	// constraints, so we instead select a version that satisfies each of
+	// them. A digest or tag can't be intersected with anything else.
+	pin := ""
+	constraints := []string{}
+	seen := map[string]bool{}
+	for _, rq := range selecting {
+		if seen[rq.Constraint] {
+			continue
+		}
+		seen[rq.Constraint] = true
+		pf, err := r.pin(dep.Package, rq.Constraint)
+		if err != nil {
+			// Name the package that requires the invalid constraint, if
+			// we know it.
+			if rq.Parent != "" {
+				err = errors.Wrapf(err, requiredByFmt, rq.Constraint, rq.Parent)
+			}
+			return failed(DependencyInvalidConstraint, errInvalidDependencyConstraint, err)
+		}
+		if pf != "" {
+			pin = pf
+		}
+		constraints = append(constraints, rq.Constraint)
+	}
+	if pin != "" && len(constraints) > 1 {
+		return unsatisfiable(errUnsatisfiableFmt, "", nil)
+	}
+
+	// Constraints that enumerate the versions they allow may not have any
+	// in common, in which case there's no point fetching tags.
+	if pin == "" && !r.overlap(constraints) {
+		return unsatisfiable(errNoOverlapFmt, "", nil)
+	}
+	p.Pinned = pin != ""
+
+	ref, err := name.ParseReference(dep.Package, DefaultRegistryOptions(r.registry)...)
+	if err != nil {
+		return failed(DependencyInvalid, errInvalidDependency, err)
+	}
+	p.Name = PackageName(canonical.Source(ref.Context().Name()))
+
+	// There's no point fetching tags from a repository we may not install
+	// packages from.
+	if err := r.blocked(ref); err != nil {
+		p.Err = err
+		return p
+	}
+
+	// Packages are installed from their fully qualified repository if we
+	// have a default registry. Any tag or digest the dependency was
+	// declared with is replaced by the version we select.
+	repo := ParsePackageSourceFromReference(ref)
+	if r.registry != "" {
+		repo = ref.Context().Name()
+	}
+
+	tag, tracked := TrackedTag(constraints[0])
+	switch {
+	case tracked && f != nil:
+		d, err := r.digest(ctx, f, ref, tag)
+		var merr *digestMismatchError
+		switch {
+		case errors.As(err, &merr):
+			return failed(DependencyDigestMismatch, errVerifyDigest, err)
+		case err != nil:
+			return failed(DependencyFetchDigest, errFetchDigest, err)
+		}
+		p.Source = fmt.Sprintf(packageDigestFmt, repo, d)
+		p.Version = d
+		p.Digest = d
+	case tracked:
+		// We can't tell which digest a tracked tag points at without a
+		// Fetcher.
+	case pin != "":
+		p.Source = fmt.Sprintf(pin, repo, constraints[0])
+		p.Version = constraints[0]
+	case f != nil:
+		// The Fetcher presents any pull secrets it was configured with,
+		// e.g. the resolver's default pull secrets, when it lists tags.
+		denied := r.denied(ctx, ref)
+		enough := r.satisfied(constraints, denied)
+		if r.stream != "" {
+			// A version that satisfies the constraints may not be in
+			// the stream, so we need every version.
+			enough = nil
+		}
+		fctx, fetch := r.tracer.Start(ctx, SpanFetchVersions, trace.WithAttributes(AttributePackage.String(dep.Package)))
+		versions, err := r.versions(fctx, f, ref, enough)
+		RecordError(fetch, err)
+		fetch.End()
+		switch {
+		case IsNotListed(err):
+			return failed(DependencyNotListed, errListVersions, err)
+		case err != nil && r.source != nil:
+			return failed(DependencyFetchTags, errListVersions, err)
+		case err != nil:
+			return failed(DependencyFetchTags, errFetchTags, err)
+		}
+		versions, excluded := exclude(versions, denied)
+		if r.stream != "" {
+			versions = InStream(versions, r.stream)
+		}
+		p.Candidates = len(versions)
+		_, sel := r.tracer.Start(ctx, SpanSelectVersion, trace.WithAttributes(AttributeCandidates.Int(len(versions))))
+		v, err := r.selector.Select(versions, r.selection, constraints...)
+		sel.SetAttributes(AttributeVersion.String(v))
+		RecordError(sel, err)
+		sel.End()
+		nerr := &NoMatchError{}
+		if errors.As(err, &nerr) {
+			if derr := r.deniedMatches(dep.Package, excluded, constraints); derr != nil {
+				p.Err = &DependencyError{Reason: DependencyNoValidVersion, err: errors.Wrap(derr, errNoValidVersion)}
+				return p
+			}
+		}
+		switch {
+		case errors.As(err, &nerr) && len(constraints) > 1:
+			return unsatisfiable(errUnsatisfiableFmt, nerr.Nearest, r.hint(versions, reqs))
+		case errors.As(err, &nerr):
+			err := nearestMiss(errors.Errorf(errNoValidVersionFmt, dep.Package, constraints[0]), nerr.Nearest)
+			if allowed := AllowedVersions(constraints[0]); allowed != nil {
+				err = errors.Errorf(errNoAllowedVersionFmt, dep.Package, strings.Join(allowed, ", "))
+			}
+			p.Err = &DependencyError{Reason: DependencyNoValidVersion, err: errors.Wrap(err, errNoValidVersion)}
+			return p
+		case err != nil:
+			return failed(DependencyInvalidConstraint, errInvalidDependencyConstraint, err)
+		}
+		p.Source = fmt.Sprintf(packageTagFmt, repo, v)
+		p.Version = v
+		if r.digests {
+			d, err := r.digest(ctx, f, ref, v)
+			var merr *digestMismatchError
+			switch {
+			case errors.As(err, &merr):
+				return failed(DependencyDigestMismatch, errVerifyDigest, err)
+			case err != nil:
+				return failed(DependencyFetchDigest, errFetchDigest, err)
+			}
+			p.Source = fmt.Sprintf(packageDigestFmt, repo, d)
+			p.Digest = d
+		}
+	}
+
+	if p.Override != "" && p.Version != "" {
+		p.Violations = Violated(p.Version, reqs)
+	}
+
+	return p
+}
+
+// hint suggests how to resolve the conflict between the supplied
+// requirements, given the supplied available versions. It returns nil if the
+// requirements' constraints can't be parsed.
+func (r *Resolver) hint(versions []string, reqs []Requirement) *ConflictHint {
+	cs := make([]string, len(reqs))
+	for i, rq := range reqs {
+		cs[i] = rq.Constraint
+	}
+	sets, err := r.selector.Satisfying(versions, cs...)
+	if err != nil {
+		return nil
+	}
+	return AnalyzeConflict(reqs, sets, r.selection)
+}
+
+// nearestMiss returns the supplied error, noting the supplied highest
+// available version if there is one.
+func nearestMiss(err error, nearest string) error {
+	if nearest == "" {
+		return err
+	}
+	return errors.Errorf("%s: "+errHighestAvailableFmt, err, nearest)
+}
+
+// overlap returns false if the supplied constraints enumerate the versions
+// they allow, and none of the versions an enumeration allows satisfies every
+// constraint. Constraints that don't enumerate versions always overlap, so
+// that they can be checked against the repository's tags.
+func (r *Resolver) overlap(constraints []string) bool {
+	if len(constraints) < 2 {
+		return true
+	}
+	var allowed []string
+	for _, c := range constraints {
+		// The latest alias only allows the highest published version,
+		// which we don't know without fetching tags.
+		if IsLatestConstraint(c) {
+			return true
+		}
+		if allowed == nil {
+			allowed = AllowedVersions(c)
+		}
+	}
+	if allowed == nil {
+		return true
+	}
+	_, err := r.selector.Select(allowed, VersionSelectionHighest, constraints...)
+	return !errors.Is(err, ErrNoMatch)
+}
+
+// A digestMismatchError indicates a tag no longer points at the digest
+// recorded when it was published.
+type digestMismatchError struct {
+	tag, repo, digest, recorded string
+}
+
+func (e *digestMismatchError) Error() string {
+	return fmt.Sprintf(errDigestMismatchFmt, e.tag, e.repo, e.digest, e.recorded)
+}
+
+// digest returns the digest the supplied tag of the supplied reference's
+// repository points at, verifying that it matches the digest recorded when
+// the tag was published, if the Resolver's VersionSource records digests.
+func (r *Resolver) digest(ctx context.Context, f Fetcher, ref name.Reference, tag string) (string, error) {
+	t := ref.Context().Tag(tag)
+	desc, err := f.Head(ctx, t)
+	if err != nil {
+		return "", err
+	}
+	if desc == nil {
+		return "", errors.Errorf(errNoDescriptorFmt, t.String())
+	}
+	d := desc.Digest.String()
+
+	ds, ok := r.source.(DigestSource)
+	if !ok {
+		return d, nil
+	}
+	recorded, err := ds.RecordedDigest(ctx, ref, tag)
+	if err != nil {
+		return "", errors.Wrap(err, errRecordedDigest)
+	}
+	if recorded != "" && recorded != d {
+		return "", &digestMismatchError{tag: tag, repo: ref.Context().Name(), digest: d, recorded: recorded}
+	}
+	return d, nil
+}
+
+// Digest returns the digest the supplied tag of the supplied reference's
+// repository points at, fetched using the supplied Fetcher. Like the digests
+// the Resolver pins, it must match the digest recorded when the tag was
+// published, if the Resolver's VersionSource records digests. It returns a
+// *DependencyError with reason DependencyBlockedByPolicy if the Resolver's
+// source policy doesn't allow the reference's repository.
+func (r *Resolver) Digest(ctx context.Context, f Fetcher, ref name.Reference, tag string) (string, error) {
+	if err := r.blocked(ref); err != nil {
+		return "", err
+	}
+	return r.digest(ctx, f, ref, tag)
+}
+
+// Versions returns the versions of the supplied reference's repository that
+// may be checked against a version constraint. Versions are listed by the
+// Resolver's VersionSource if it has one, or are the repository's tags,
+// fetched using the supplied Fetcher.
+func (r *Resolver) Versions(ctx context.Context, f Fetcher, ref name.Reference) ([]string, error) {
+	return r.versions(ctx, f, ref, nil)
+}
+
+// versions lists the versions of the supplied reference's repository. If
+// enough is not nil and the versions are tags, fetching stops as soon as it
+// returns true for the tags fetched so far.
+func (r *Resolver) versions(ctx context.Context, f Fetcher, ref name.Reference, enough func(tags []string) bool) ([]string, error) {
+	if r.source == nil {
+		return r.tags(ctx, f, ref, enough)
+	}
+	v, err := r.source.ListVersions(ctx, ref)
+	if IsNotListed(err) && r.fallback {
+		r.log.Debug("Version source does not list repository, falling back to tags", "repository", ref.Context().Name())
+		return r.tags(ctx, f, ref, enough)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Versions are filtered like tags so that a version source can't
+	// select a version we couldn't parse.
+	kept, _ := r.filter.Filter(v)
+	return kept, nil
+}
+
+// tags fetches and filters the tags of the supplied reference's repository a
+// page at a time. If enough is not nil fetching stops as soon as it returns
+// true for the tags fetched so far.
+func (r *Resolver) tags(ctx context.Context, f Fetcher, ref name.Reference, enough func(tags []string) bool) ([]string, error) {
+	if r.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.timeout)
+		defer cancel()
+	}
+
+	kept := []string{}
+	skipped := map[TagSkipReason]int{}
+	err := PagedTags(ctx, f, ref, func(page []string) bool {
+		k, s := r.filter.Filter(page)
+		kept = append(kept, k...)
+		for reason, n := range s {
+			skipped[reason] += n
+		}
+		return enough == nil || !enough(kept)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Each page is filtered independently, so filter once more to remove
+	// versions that were duplicated across pages.
+	kept, s := r.filter.Filter(kept)
+	for reason, n := range s {
+		skipped[reason] += n
+	}
+	if len(skipped) > 0 {
+		r.log.Debug("Skipped repository tags", "repository", ref.Context().Name(), "kept", len(kept), "skipped", skipped)
+	}
+	return kept, nil
+}
+
+// satisfied returns a function that reports whether the supplied tags, which
+// are a prefix of all tags, are enough to select a version that satisfies the
+// supplied constraints and is not denied. It returns nil if all tags are
+// needed.
+func (r *Resolver) satisfied(constraints []string, denied func(version string) bool) func(tags []string) bool {
+	latest := false
+	for _, c := range constraints {
+		latest = latest || IsLatestConstraint(c)
+	}
+
+	// The latest alias selects the highest version regardless of policy.
+	switch {
+	case r.order == TagOrderDescending && (latest || r.selection == VersionSelectionHighest):
+	case r.order == TagOrderAscending && !latest && r.selection == VersionSelectionLowest:
+	default:
+		return nil
+	}
+	return func(tags []string) bool {
+		tags, _ = exclude(tags, denied)
+		_, err := r.selector.Select(tags, r.selection, constraints...)
+		return err == nil
+	}
+}
+
+// pin returns the format of the source of a package pinned to the digest or
+// tag of the supplied constraint, or an empty string if the constraint is a
+// semantic version constraint or an alias of one. A package that tracks a tag
+// is pinned to the digest it points at. It returns an error if the constraint
+// is none of these.
+func (r *Resolver) pin(pkg, constraint string) (string, error) {
+	_, err := r.selector.Parse(constraint)
+	if tag, ok := TrackedTag(constraint); ok {
+		if _, err := name.NewTag(VersionedSource(pkg, tag), DefaultRegistryOptions(r.registry)...); err != nil {
+			return "", err
+		}
+		return packageDigestFmt, nil
+	}
+	switch {
+	case strings.HasPrefix(constraint, digestPrefix):
+		if _, err := name.NewDigest(VersionedSource(pkg, constraint), DefaultRegistryOptions(r.registry)...); err != nil {
+			return "", err
+		}
+		return packageDigestFmt, nil
+	case err == nil:
+		return "", nil
+	}
+
+	// A constraint that is not a valid semantic version constraint may still
+	// be an exact tag, e.g. main.
+	// The caller reports that the constraint is invalid, so we return why.
+	if _, terr := name.NewTag(VersionedSource(pkg, constraint), DefaultRegistryOptions(r.registry)...); terr != nil {
+		return "", errors.Unwrap(err)
+	}
+	return packageTagFmt, nil
+}
@@ -0,0 +1,269 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package xpkg
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-containerregistry/pkg/name"
+
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+)
+
+// Seeds of randomly generated Locks that once violated an invariant, once
+// minimized. They're always checked, in addition to -resolve.seeds random
+// seeds.
+var resolveRegressions = []int64{}
+
+var (
+	resolveSeeds = flag.Int("resolve.seeds", 200, "number of random seeds TestResolveProperties checks")
+	resolveSeed  = flag.Int64("resolve.seed", 0, "check only this seed in TestResolveProperties")
+)
+
+const (
+	// Bounds on the size of a generated Lock.
+	maxGeneratedPackages     = 8
+	maxGeneratedRepositories = 10
+	maxGeneratedTags         = 12
+	maxGeneratedDependencies = 4
+
+	// resolveBound is how long resolving a generated Lock may take. The
+	// Fetcher never blocks, so resolution should be near instant.
+	resolveBound = 5 * time.Second
+)
+
+// A generatedLock is a randomly generated set of installed packages, and the
+// tags of the repositories they depend on.
+type generatedLock struct {
+	pkgs []v1beta1.LockPackage
+	tags map[string][]string
+}
+
+// generateLock generates a Lock from the supplied source of randomness. Each
+// installed Configuration may depend on Configurations installed before it,
+// so the Lock never contains a cycle, and on repositories that aren't
+// installed. Tags mix stable releases, prereleases, and tags that aren't
+// semantic versions, in a random order.
+func generateLock(rnd *rand.Rand) generatedLock {
+	g := generatedLock{tags: map[string][]string{}}
+
+	repos := make([]string, 1+rnd.Intn(maxGeneratedRepositories))
+	for i := range repos {
+		repos[i] = fmt.Sprintf("acme/repo-%d", i)
+		g.tags[repos[i]] = generateTags(rnd)
+	}
+
+	n := 1 + rnd.Intn(maxGeneratedPackages)
+	for i := 0; i < n; i++ {
+		p := v1beta1.LockPackage{
+			Name:    fmt.Sprintf("config-%d", i),
+			Type:    v1beta1.ConfigurationPackageType,
+			Source:  fmt.Sprintf("acme/config-%d", i),
+			Version: "v1.0.0",
+		}
+		seen := map[string]bool{}
+		for j := rnd.Intn(maxGeneratedDependencies + 1); j > 0; j-- {
+			d := v1beta1.Dependency{Type: v1beta1.ProviderPackageType, Constraints: generateConstraint(rnd)}
+			d.Package = repos[rnd.Intn(len(repos))]
+			if i > 0 && rnd.Intn(4) == 0 {
+				// An installed package depends on one installed
+				// before it.
+				d.Package = fmt.Sprintf("acme/config-%d", rnd.Intn(i))
+				d.Type = v1beta1.ConfigurationPackageType
+				d.Constraints = ">=v1.0.0"
+			}
+			if seen[d.Package] {
+				continue
+			}
+			seen[d.Package] = true
+			p.Dependencies = append(p.Dependencies, d)
+		}
+		g.pkgs = append(g.pkgs, p)
+	}
+	return g
+}
+
+func generateTags(rnd *rand.Rand) []string {
+	tags := []string{}
+	for i := rnd.Intn(maxGeneratedTags + 1); i > 0; i-- {
+		v := fmt.Sprintf("v%d.%d.%d", rnd.Intn(3), rnd.Intn(4), rnd.Intn(3))
+		switch rnd.Intn(6) {
+		case 0:
+			v += fmt.Sprintf("-rc.%d", rnd.Intn(3))
+		case 1:
+			v = v[1:]
+		case 2:
+			v = "latest"
+		}
+		tags = append(tags, v)
+	}
+	return tags
+}
+
+func generateConstraint(rnd *rand.Rand) string {
+	v := fmt.Sprintf("v%d.%d.%d", rnd.Intn(3), rnd.Intn(4), rnd.Intn(3))
+	switch rnd.Intn(8) {
+	case 0:
+		return "<" + v
+	case 1:
+		return "~" + v
+	case 2:
+		return "^" + v
+	case 3:
+		return v
+	case 4:
+		return "*"
+	case 5:
+		return ConstraintLatest
+	case 6:
+		return fmt.Sprintf(">=%s, <v%d.0.0", v, 1+rnd.Intn(3))
+	default:
+		return ">=" + v
+	}
+}
+
+// shuffled returns a copy of the supplied Lock with its packages, and the tags
+// of its repositories, in a different order.
+func (g generatedLock) shuffled(rnd *rand.Rand) generatedLock {
+	s := generatedLock{pkgs: make([]v1beta1.LockPackage, len(g.pkgs)), tags: map[string][]string{}}
+	for i, j := range rnd.Perm(len(g.pkgs)) {
+		s.pkgs[i] = g.pkgs[j]
+	}
+	for repo, tags := range g.tags {
+		s.tags[repo] = make([]string, len(tags))
+		for i, j := range rnd.Perm(len(tags)) {
+			s.tags[repo][i] = tags[j]
+		}
+	}
+	return s
+}
+
+// repositoryTagsFetcher returns the tags of each repository, keyed by its
+// repository path.
+type repositoryTagsFetcher struct {
+	NopFetcher
+	tags map[string][]string
+}
+
+func (f *repositoryTagsFetcher) Tags(_ context.Context, ref name.Reference, _ ...string) ([]string, error) {
+	return f.tags[ref.Context().RepositoryStr()], nil
+}
+
+// TestResolveProperties asserts invariants of the plans the Resolver makes for
+// randomly generated Locks. Run a failing seed alone with -resolve.seed, and
+// add it to resolveRegressions once it's fixed.
+func TestResolveProperties(t *testing.T) {
+	seeds := append([]int64{}, resolveRegressions...)
+	for i := 0; i < *resolveSeeds; i++ {
+		seeds = append(seeds, int64(i+1))
+	}
+	if *resolveSeed != 0 {
+		seeds = []int64{*resolveSeed}
+	}
+
+	for _, seed := range seeds {
+		g := generateLock(rand.New(rand.NewSource(seed)))
+		for _, policy := range []VersionSelection{VersionSelectionHighest, VersionSelectionLowest} {
+			if err := checkResolve(g, policy, rand.New(rand.NewSource(seed))); err != nil {
+				t.Errorf("seed %d, %s selection: %s\npackages: %+v\ntags: %v", seed, policy, err, g.pkgs, g.tags)
+			}
+		}
+	}
+}
+
+// checkResolve returns an error describing the first invariant the
+// Resolver's plan for the supplied Lock violates, if any.
+func checkResolve(g generatedLock, policy VersionSelection, rnd *rand.Rand) error { // nolint:gocyclo
+	resolve := func(g generatedLock) ([]PlannedInstall, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), resolveBound)
+		defer cancel()
+		start := time.Now()
+		plan, _, err := NewResolver(WithVersionSelection(policy)).Resolve(ctx, g.pkgs, &repositoryTagsFetcher{tags: g.tags})
+		if d := time.Since(start); d > resolveBound {
+			return nil, fmt.Errorf("resolution took %s, longer than %s", d, resolveBound)
+		}
+		return plan, err
+	}
+
+	plan, err := resolve(g)
+	if err != nil {
+		return fmt.Errorf("Resolve(...): %w", err)
+	}
+
+	installed := map[string]bool{}
+	for _, p := range g.pkgs {
+		installed[p.Identifier()] = true
+	}
+	planned := map[string]string{}
+	for _, p := range plan {
+		id := p.Dependency.Identifier()
+		if installed[id] {
+			return fmt.Errorf("planned to install %s, which is already installed", p.Dependency.Package)
+		}
+		if _, ok := planned[id]; ok {
+			return fmt.Errorf("planned to install %s more than once", p.Dependency.Package)
+		}
+		planned[id] = p.Version
+		if p.Err != nil {
+			continue
+		}
+
+		if !contains(g.tags[p.Dependency.Package], p.Version) {
+			return fmt.Errorf("planned to install %s at %q, which is not one of its tags", p.Dependency.Package, p.Version)
+		}
+		constraints := ConstraintsOn(g.pkgs, p.Dependency.Package)
+		if len(constraints) != len(p.Requirements) {
+			return fmt.Errorf("planned to install %s to satisfy %d requirements, but %d packages depend on it", p.Dependency.Package, len(p.Requirements), len(constraints))
+		}
+		reqs := make([]Requirement, len(constraints))
+		for i, c := range constraints {
+			reqs[i] = Requirement{Constraint: c}
+		}
+		if v := Violated(p.Version, reqs); len(v) > 0 {
+			return fmt.Errorf("planned to install %s at %q, which does not satisfy %q", p.Dependency.Package, p.Version, v[0].Constraint)
+		}
+	}
+
+	// Resolving the same Lock, in any order, must produce the same plan.
+	again, err := resolve(g.shuffled(rnd))
+	if err != nil {
+		return fmt.Errorf("Resolve(...) of the shuffled Lock: %w", err)
+	}
+	replanned := map[string]string{}
+	for _, p := range again {
+		replanned[p.Dependency.Identifier()] = p.Version
+	}
+	if diff := cmp.Diff(planned, replanned); diff != "" {
+		return fmt.Errorf("plan for the shuffled Lock differs: -want, +got:\n%s", diff)
+	}
+	return nil
+}
+
+func contains(ss []string, s string) bool {
+	for _, o := range ss {
+		if o == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,1098 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package xpkg
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+	"github.com/crossplane/crossplane/internal/dag"
+	fakedag "github.com/crossplane/crossplane/internal/dag/fake"
+)
+
+func TestResolve(t *testing.T) {
+	errBoom := errors.New("boom")
+	digest := "sha256:ecc25c121431dfc7058754427f97c034ecde26d4aafa0da16d6429d9f7d6cd9b"
+	tags := []string{"v0.1.0", "v0.2.0", "v0.3.0", "sha256-3b5d.sig"}
+
+	config := func(deps ...v1beta1.Dependency) v1beta1.LockPackage {
+		return v1beta1.LockPackage{Source: "cool/config", Type: v1beta1.ConfigurationPackageType, Dependencies: deps}
+	}
+	provider := func(constraints string) v1beta1.Dependency {
+		return v1beta1.Dependency{Package: "crossplane/provider-aws", Type: v1beta1.ProviderPackageType, Constraints: constraints}
+	}
+	dependencyErr := func(reason DependencyErrorReason, msg string, err error, dep v1beta1.Dependency) *DependencyError {
+		return &DependencyError{Reason: reason, err: errors.Wrapf(err, errDependencyFmt, msg, dep.Package, dep.Constraints)}
+	}
+
+	type args struct {
+		opts    []ResolverOption
+		pkgs    []v1beta1.LockPackage
+		fetcher Fetcher
+	}
+	type want struct {
+		plan      []PlannedInstall
+		conflicts []Conflict
+		err       error
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"NoMissing": {
+			reason: "We should not plan to install anything if no dependencies are missing.",
+			args: args{
+				pkgs: []v1beta1.LockPackage{
+					config(provider(">=v0.1.0")),
+					{Source: "crossplane/provider-aws", Version: "v0.2.0"},
+				},
+				fetcher: &mockTagsFetcher{tags: tags},
+			},
+			want: want{plan: []PlannedInstall{}},
+		},
+		"OptionalMissing": {
+			reason: "We should not plan to install a dependency that every package depending on it declared optional.",
+			args: args{
+				pkgs: []v1beta1.LockPackage{
+					config(v1beta1.Dependency{Package: "crossplane/provider-aws", Type: v1beta1.ProviderPackageType, Constraints: ">=v0.1.0", Optional: true}),
+				},
+				fetcher: &mockTagsFetcher{tags: tags},
+			},
+			want: want{plan: []PlannedInstall{}},
+		},
+		"OptionalViolated": {
+			reason: "An installed optional dependency that doesn't satisfy its constraint should not conflict.",
+			args: args{
+				pkgs: []v1beta1.LockPackage{
+					config(v1beta1.Dependency{Package: "crossplane/provider-aws", Type: v1beta1.ProviderPackageType, Constraints: ">=v0.3.0", Optional: true}),
+					{Source: "crossplane/provider-aws", Version: "v0.2.0"},
+				},
+				fetcher: &mockTagsFetcher{tags: tags},
+			},
+			want: want{plan: []PlannedInstall{}},
+		},
+		"InstalledAlias": {
+			reason: "We should not plan to install a dependency that is installed from an alias of the repository it was declared with.",
+			args: args{
+				pkgs: []v1beta1.LockPackage{
+					config(v1beta1.Dependency{Package: "index.docker.io/crossplane/provider-aws", Type: v1beta1.ProviderPackageType, Constraints: ">=v0.1.0"}),
+					{Source: "docker.io/crossplane/provider-aws", Version: "v0.2.0"},
+				},
+				fetcher: &mockTagsFetcher{tags: tags},
+			},
+			want: want{plan: []PlannedInstall{}},
+		},
+		"Highest": {
+			reason: "We should plan to install the highest version that satisfies a missing dependency's constraint.",
+			args: args{
+				pkgs:    []v1beta1.LockPackage{config(provider(">=v0.1.0"))},
+				fetcher: &mockTagsFetcher{tags: tags},
+			},
+			want: want{plan: []PlannedInstall{{
+				Dependency:   provider(">=v0.1.0"),
+				Candidates:   3,
+				Requirements: []Requirement{{Parent: "cool/config", Constraint: ">=v0.1.0"}},
+				Depth:        1,
+				Name:         "crossplane-provider-aws",
+				Source:       "crossplane/provider-aws:v0.3.0",
+				Version:      "v0.3.0",
+			}}},
+		},
+		"Lowest": {
+			reason: "We should plan to install the lowest version that satisfies a missing dependency's constraint if configured to.",
+			args: args{
+				opts:    []ResolverOption{WithVersionSelection(VersionSelectionLowest)},
+				pkgs:    []v1beta1.LockPackage{config(provider(">=v0.2.0"))},
+				fetcher: &mockTagsFetcher{tags: tags},
+			},
+			want: want{plan: []PlannedInstall{{
+				Dependency:   provider(">=v0.2.0"),
+				Candidates:   3,
+				Requirements: []Requirement{{Parent: "cool/config", Constraint: ">=v0.2.0"}},
+				Depth:        1,
+				Name:         "crossplane-provider-aws",
+				Source:       "crossplane/provider-aws:v0.2.0",
+				Version:      "v0.2.0",
+			}}},
+		},
+		"DeclaredWithTag": {
+			reason: "We should replace the tag a dependency was declared with by the version we select, rather than appending it.",
+			args: args{
+				pkgs:    []v1beta1.LockPackage{config(v1beta1.Dependency{Package: "xpkg.upbound.io/crossplane/provider-aws:v0.1.0", Type: v1beta1.ProviderPackageType, Constraints: ">=v0.1.0"})},
+				fetcher: &mockTagsFetcher{tags: tags},
+			},
+			want: want{plan: []PlannedInstall{{
+				Dependency:   v1beta1.Dependency{Package: "xpkg.upbound.io/crossplane/provider-aws:v0.1.0", Type: v1beta1.ProviderPackageType, Constraints: ">=v0.1.0"},
+				Candidates:   3,
+				Requirements: []Requirement{{Parent: "cool/config", Constraint: ">=v0.1.0"}},
+				Depth:        1,
+				Name:         "crossplane-provider-aws",
+				Source:       "xpkg.upbound.io/crossplane/provider-aws:v0.3.0",
+				Version:      "v0.3.0",
+			}}},
+		},
+		"DeclaredWithDigest": {
+			reason: "We should replace the digest a dependency was declared with by the version we select, rather than appending it.",
+			args: args{
+				pkgs:    []v1beta1.LockPackage{config(v1beta1.Dependency{Package: "crossplane/provider-aws@" + digest, Type: v1beta1.ProviderPackageType, Constraints: ">=v0.1.0"})},
+				fetcher: &mockTagsFetcher{tags: tags},
+			},
+			want: want{plan: []PlannedInstall{{
+				Dependency:   v1beta1.Dependency{Package: "crossplane/provider-aws@" + digest, Type: v1beta1.ProviderPackageType, Constraints: ">=v0.1.0"},
+				Candidates:   3,
+				Requirements: []Requirement{{Parent: "cool/config", Constraint: ">=v0.1.0"}},
+				Depth:        1,
+				Name:         "crossplane-provider-aws",
+				Source:       "crossplane/provider-aws:v0.3.0",
+				Version:      "v0.3.0",
+			}}},
+		},
+		"DeclaredWithPort": {
+			reason: "We should not mistake the port of a registry a dependency was declared with for a tag.",
+			args: args{
+				pkgs:    []v1beta1.LockPackage{config(v1beta1.Dependency{Package: "registry.example.org:5000/crossplane/provider-aws", Type: v1beta1.ProviderPackageType, Constraints: ">=v0.1.0"})},
+				fetcher: &mockTagsFetcher{tags: tags},
+			},
+			want: want{plan: []PlannedInstall{{
+				Dependency:   v1beta1.Dependency{Package: "registry.example.org:5000/crossplane/provider-aws", Type: v1beta1.ProviderPackageType, Constraints: ">=v0.1.0"},
+				Candidates:   3,
+				Requirements: []Requirement{{Parent: "cool/config", Constraint: ">=v0.1.0"}},
+				Depth:        1,
+				Name:         "crossplane-provider-aws",
+				Source:       "registry.example.org:5000/crossplane/provider-aws:v0.3.0",
+				Version:      "v0.3.0",
+			}}},
+		},
+		"VersionSource": {
+			reason: "We should plan to install the highest version listed by our version source, rather than the highest tag.",
+			args: args{
+				opts: []ResolverOption{WithVersionSource(VersionSourceFn(func(_ context.Context, _ name.Reference) ([]string, error) {
+					return []string{"v0.1.0", "v0.2.0", "latest"}, nil
+				}))},
+				pkgs:    []v1beta1.LockPackage{config(provider(">=v0.1.0"))},
+				fetcher: &mockTagsFetcher{tags: tags},
+			},
+			want: want{plan: []PlannedInstall{{
+				Dependency:   provider(">=v0.1.0"),
+				Candidates:   2,
+				Requirements: []Requirement{{Parent: "cool/config", Constraint: ">=v0.1.0"}},
+				Depth:        1,
+				Name:         "crossplane-provider-aws",
+				Source:       "crossplane/provider-aws:v0.2.0",
+				Version:      "v0.2.0",
+			}}},
+		},
+		"VersionSourceError": {
+			reason: "We should report that a dependency's versions could not be listed.",
+			args: args{
+				opts: []ResolverOption{WithVersionSource(VersionSourceFn(func(_ context.Context, _ name.Reference) ([]string, error) {
+					return nil, errBoom
+				}))},
+				pkgs:    []v1beta1.LockPackage{config(provider(">=v0.1.0"))},
+				fetcher: &mockTagsFetcher{tags: tags},
+			},
+			want: want{plan: []PlannedInstall{{
+				Dependency:   provider(">=v0.1.0"),
+				Requirements: []Requirement{{Parent: "cool/config", Constraint: ">=v0.1.0"}},
+				Depth:        1,
+				Name:         "crossplane-provider-aws",
+				Err:          dependencyErr(DependencyFetchTags, errListVersions, errBoom, provider(">=v0.1.0")),
+			}}},
+		},
+		"NotListed": {
+			reason: "We should report that a dependency is not listed by our version source if we may not fall back to tags.",
+			args: args{
+				opts: []ResolverOption{WithVersionSource(VersionSourceFn(func(_ context.Context, ref name.Reference) ([]string, error) {
+					return nil, &NotListedError{Repository: ref.Context().Name()}
+				}))},
+				pkgs:    []v1beta1.LockPackage{config(provider(">=v0.1.0"))},
+				fetcher: &mockTagsFetcher{tags: tags},
+			},
+			want: want{plan: []PlannedInstall{{
+				Dependency:   provider(">=v0.1.0"),
+				Requirements: []Requirement{{Parent: "cool/config", Constraint: ">=v0.1.0"}},
+				Depth:        1,
+				Name:         "crossplane-provider-aws",
+				Err:          dependencyErr(DependencyNotListed, errListVersions, &NotListedError{Repository: "index.docker.io/crossplane/provider-aws"}, provider(">=v0.1.0")),
+			}}},
+		},
+		"NotListedFallback": {
+			reason: "We should plan to install the highest tag of a dependency that is not listed by our version source if we may fall back to tags.",
+			args: args{
+				opts: []ResolverOption{
+					WithVersionSource(VersionSourceFn(func(_ context.Context, ref name.Reference) ([]string, error) {
+						return nil, &NotListedError{Repository: ref.Context().Name()}
+					})),
+					WithTagFallback(),
+				},
+				pkgs:    []v1beta1.LockPackage{config(provider(">=v0.1.0"))},
+				fetcher: &mockTagsFetcher{tags: tags},
+			},
+			want: want{plan: []PlannedInstall{{
+				Dependency:   provider(">=v0.1.0"),
+				Candidates:   3,
+				Requirements: []Requirement{{Parent: "cool/config", Constraint: ">=v0.1.0"}},
+				Depth:        1,
+				Name:         "crossplane-provider-aws",
+				Source:       "crossplane/provider-aws:v0.3.0",
+				Version:      "v0.3.0",
+			}}},
+		},
+		"DefaultRegistry": {
+			reason: "We should qualify the package we plan to install with the default registry.",
+			args: args{
+				opts:    []ResolverOption{WithDefaultRegistry("registry.upbound.io")},
+				pkgs:    []v1beta1.LockPackage{config(provider(">=v0.1.0"))},
+				fetcher: &mockTagsFetcher{tags: tags},
+			},
+			want: want{plan: []PlannedInstall{{
+				Dependency:   provider(">=v0.1.0"),
+				Candidates:   3,
+				Requirements: []Requirement{{Parent: "cool/config", Constraint: ">=v0.1.0"}},
+				Depth:        1,
+				Name:         "crossplane-provider-aws",
+				Source:       "registry.upbound.io/crossplane/provider-aws:v0.3.0",
+				Version:      "v0.3.0",
+			}}},
+		},
+		"SharedDependency": {
+			reason: "We should plan to install a dependency of multiple packages once.",
+			args: args{
+				pkgs: []v1beta1.LockPackage{
+					config(provider(">=v0.1.0")),
+					{Source: "cool/other-config", Dependencies: []v1beta1.Dependency{provider(">=v0.1.0")}},
+				},
+				fetcher: &mockTagsFetcher{tags: tags},
+			},
+			want: want{plan: []PlannedInstall{{
+				Dependency: provider(">=v0.1.0"),
+				Candidates: 3,
+				Requirements: []Requirement{
+					{Parent: "cool/config", Constraint: ">=v0.1.0"},
+					{Parent: "cool/other-config", Constraint: ">=v0.1.0"},
+				},
+				Depth:   1,
+				Name:    "crossplane-provider-aws",
+				Source:  "crossplane/provider-aws:v0.3.0",
+				Version: "v0.3.0",
+			}}},
+		},
+		"Depth": {
+			reason: "We should plan each missing dependency at the depth of the deepest package that depends on it, plus one.",
+			args: args{
+				pkgs: []v1beta1.LockPackage{
+					config(
+						v1beta1.Dependency{Package: "cool/config-base", Type: v1beta1.ConfigurationPackageType, Constraints: ">=v0.1.0"},
+						v1beta1.Dependency{Package: "cool/config-extra", Type: v1beta1.ConfigurationPackageType, Constraints: ">=v0.1.0"},
+					),
+					{Source: "cool/config-base", Type: v1beta1.ConfigurationPackageType, Dependencies: []v1beta1.Dependency{provider(">=v0.1.0")}},
+				},
+				fetcher: &mockTagsFetcher{tags: tags},
+			},
+			want: want{plan: []PlannedInstall{
+				{
+					Dependency:   v1beta1.Dependency{Package: "cool/config-extra", Type: v1beta1.ConfigurationPackageType, Constraints: ">=v0.1.0"},
+					Candidates:   3,
+					Requirements: []Requirement{{Parent: "cool/config", Constraint: ">=v0.1.0"}},
+					Depth:        1,
+					Name:         "cool-config-extra",
+					Source:       "cool/config-extra:v0.3.0",
+					Version:      "v0.3.0",
+				},
+				{
+					Dependency:   provider(">=v0.1.0"),
+					Candidates:   3,
+					Requirements: []Requirement{{Parent: "cool/config-base", Constraint: ">=v0.1.0"}},
+					Depth:        2,
+					Name:         "crossplane-provider-aws",
+					Source:       "crossplane/provider-aws:v0.3.0",
+					Version:      "v0.3.0",
+				},
+			}},
+		},
+		"SharedAliasedDependency": {
+			reason: "We should plan to install a dependency that packages declare with different aliases of its repository once.",
+			args: args{
+				pkgs: []v1beta1.LockPackage{
+					config(provider(">=v0.1.0")),
+					{Source: "cool/other-config", Dependencies: []v1beta1.Dependency{{Package: "docker.io/crossplane/provider-aws", Type: v1beta1.ProviderPackageType, Constraints: "<v0.3.0"}}},
+				},
+				fetcher: &mockTagsFetcher{tags: tags},
+			},
+			want: want{plan: []PlannedInstall{{
+				Dependency: provider(">=v0.1.0"),
+				Candidates: 3,
+				Requirements: []Requirement{
+					{Parent: "cool/config", Constraint: ">=v0.1.0"},
+					{Parent: "cool/other-config", Constraint: "<v0.3.0"},
+				},
+				Depth:   1,
+				Name:    "crossplane-provider-aws",
+				Source:  "crossplane/provider-aws:v0.2.0",
+				Version: "v0.2.0",
+			}}},
+		},
+		"Intersection": {
+			reason: "We should plan to install the highest version that satisfies the constraints of every package that depends on a missing dependency.",
+			args: args{
+				pkgs: []v1beta1.LockPackage{
+					{Source: "cool/other-config", Dependencies: []v1beta1.Dependency{provider(">=v0.1.0, <v0.3.0")}},
+					config(provider(">=v0.2.0")),
+				},
+				fetcher: &mockTagsFetcher{tags: tags},
+			},
+			want: want{plan: []PlannedInstall{{
+				Dependency: provider(">=v0.1.0, <v0.3.0"),
+				Name:       "crossplane-provider-aws",
+				Source:     "crossplane/provider-aws:v0.2.0",
+				Version:    "v0.2.0",
+				Candidates: 3,
+				Requirements: []Requirement{
+					{Parent: "cool/config", Constraint: ">=v0.2.0"},
+					{Parent: "cool/other-config", Constraint: ">=v0.1.0, <v0.3.0"},
+				},
+				Depth: 1,
+			}}},
+		},
+		"LowestIntersection": {
+			reason: "We should plan to install the lowest version that satisfies the constraints of every package that depends on a missing dependency if configured to.",
+			args: args{
+				opts: []ResolverOption{WithVersionSelection(VersionSelectionLowest)},
+				pkgs: []v1beta1.LockPackage{
+					config(provider(">=v0.1.0")),
+					{Source: "cool/other-config", Dependencies: []v1beta1.Dependency{provider(">=v0.2.0")}},
+				},
+				fetcher: &mockTagsFetcher{tags: tags},
+			},
+			want: want{plan: []PlannedInstall{{
+				Dependency: provider(">=v0.1.0"),
+				Name:       "crossplane-provider-aws",
+				Source:     "crossplane/provider-aws:v0.2.0",
+				Version:    "v0.2.0",
+				Candidates: 3,
+				Requirements: []Requirement{
+					{Parent: "cool/config", Constraint: ">=v0.1.0"},
+					{Parent: "cool/other-config", Constraint: ">=v0.2.0"},
+				},
+				Depth: 1,
+			}}},
+		},
+		"EmptyIntersection": {
+			reason: "We should report every package that depends on a missing dependency if no version satisfies all of their constraints.",
+			args: args{
+				pkgs: []v1beta1.LockPackage{
+					config(provider("<v0.2.0")),
+					{Source: "cool/other-config", Dependencies: []v1beta1.Dependency{provider(">=v0.3.0")}},
+				},
+				fetcher: &mockTagsFetcher{tags: tags},
+			},
+			want: want{plan: []PlannedInstall{{
+				Dependency: provider("<v0.2.0"),
+				Name:       "crossplane-provider-aws",
+				Candidates: 3,
+				Requirements: []Requirement{
+					{Parent: "cool/config", Constraint: "<v0.2.0"},
+					{Parent: "cool/other-config", Constraint: ">=v0.3.0"},
+				},
+				Depth: 1,
+				Err:   &DependencyError{Reason: DependencyNoValidVersion, err: errors.Wrap(errors.Errorf("%s; %s", nearestMiss(errors.Errorf(errUnsatisfiableFmt, "crossplane/provider-aws", `"<v0.2.0" required by cool/config, ">=v0.3.0" required by cool/other-config`), "v0.3.0"), `"<v0.2.0" allows only v0.1.0, ">=v0.3.0" allows only v0.3.0; changing the constraint of cool/config to allow v0.3.0, or of cool/other-config to allow v0.1.0 would be compatible with every other package that depends on it`), errNoValidVersion)},
+			}}},
+		},
+		"PinnedIntersection": {
+			reason: "We should report every package that depends on a missing dependency if one pins it and another constrains it differently.",
+			args: args{
+				pkgs: []v1beta1.LockPackage{
+					config(provider("main")),
+					{Source: "cool/other-config", Dependencies: []v1beta1.Dependency{provider(">=v0.1.0")}},
+				},
+				fetcher: &mockTagsFetcher{tags: tags},
+			},
+			want: want{plan: []PlannedInstall{{
+				Dependency: provider("main"),
+				Requirements: []Requirement{
+					{Parent: "cool/config", Constraint: "main"},
+					{Parent: "cool/other-config", Constraint: ">=v0.1.0"},
+				},
+				Depth: 1,
+				Err:   &DependencyError{Reason: DependencyNoValidVersion, err: errors.Wrap(errors.Errorf(errUnsatisfiableFmt, "crossplane/provider-aws", `"main" required by cool/config, ">=v0.1.0" required by cool/other-config`), errNoValidVersion)},
+			}}},
+		},
+		"SharedPin": {
+			reason: "We should plan to install a dependency that multiple packages pin to the same tag.",
+			args: args{
+				pkgs: []v1beta1.LockPackage{
+					config(provider("main")),
+					{Source: "cool/other-config", Dependencies: []v1beta1.Dependency{provider("main")}},
+				},
+				fetcher: &mockTagsFetcher{err: errBoom},
+			},
+			want: want{plan: []PlannedInstall{{
+				Dependency: provider("main"),
+				Name:       "crossplane-provider-aws",
+				Source:     "crossplane/provider-aws:main",
+				Version:    "main",
+				Pinned:     true,
+				Requirements: []Requirement{
+					{Parent: "cool/config", Constraint: "main"},
+					{Parent: "cool/other-config", Constraint: "main"},
+				},
+				Depth: 1,
+			}}},
+		},
+		"PinnedDigest": {
+			reason: "We should plan to install a dependency pinned to a digest without fetching tags.",
+			args: args{
+				pkgs:    []v1beta1.LockPackage{config(provider(digest))},
+				fetcher: &mockTagsFetcher{err: errBoom},
+			},
+			want: want{plan: []PlannedInstall{{
+				Dependency:   provider(digest),
+				Requirements: []Requirement{{Parent: "cool/config", Constraint: digest}},
+				Depth:        1,
+				Name:         "crossplane-provider-aws",
+				Source:       "crossplane/provider-aws@" + digest,
+				Version:      digest,
+				Pinned:       true,
+			}}},
+		},
+		"PinnedTag": {
+			reason: "We should plan to install a dependency pinned to a tag without fetching tags.",
+			args: args{
+				pkgs:    []v1beta1.LockPackage{config(provider("main"))},
+				fetcher: &mockTagsFetcher{err: errBoom},
+			},
+			want: want{plan: []PlannedInstall{{
+				Dependency:   provider("main"),
+				Requirements: []Requirement{{Parent: "cool/config", Constraint: "main"}},
+				Depth:        1,
+				Name:         "crossplane-provider-aws",
+				Source:       "crossplane/provider-aws:main",
+				Version:      "main",
+				Pinned:       true,
+			}}},
+		},
+		"LatestAlias": {
+			reason: "We should plan to install the highest stable version of a dependency constrained to the latest alias, rather than a tag named latest.",
+			args: args{
+				pkgs:    []v1beta1.LockPackage{config(provider("latest"))},
+				fetcher: &mockTagsFetcher{tags: tags},
+			},
+			want: want{plan: []PlannedInstall{{
+				Dependency:   provider("latest"),
+				Requirements: []Requirement{{Parent: "cool/config", Constraint: "latest"}},
+				Depth:        1,
+				Name:         "crossplane-provider-aws",
+				Source:       "crossplane/provider-aws:v0.3.0",
+				Version:      "v0.3.0",
+				Candidates:   3,
+			}}},
+		},
+		"Enumeration": {
+			reason: "We should plan to install the highest of the versions a missing dependency's constraint enumerates.",
+			args: args{
+				pkgs:    []v1beta1.LockPackage{config(provider("v0.1.0 || v0.2.0 || v0.4.0"))},
+				fetcher: &mockTagsFetcher{tags: tags},
+			},
+			want: want{plan: []PlannedInstall{{
+				Dependency:   provider("v0.1.0 || v0.2.0 || v0.4.0"),
+				Candidates:   3,
+				Requirements: []Requirement{{Parent: "cool/config", Constraint: "v0.1.0 || v0.2.0 || v0.4.0"}},
+				Depth:        1,
+				Name:         "crossplane-provider-aws",
+				Source:       "crossplane/provider-aws:v0.2.0",
+				Version:      "v0.2.0",
+			}}},
+		},
+		"EnumerationNoValidVersion": {
+			reason: "We should report which versions a missing dependency's constraint enumerates if none of them are published.",
+			args: args{
+				pkgs:    []v1beta1.LockPackage{config(provider("v0.4.0 || =v0.5.0"))},
+				fetcher: &mockTagsFetcher{tags: tags},
+			},
+			want: want{plan: []PlannedInstall{{
+				Dependency:   provider("v0.4.0 || =v0.5.0"),
+				Candidates:   3,
+				Requirements: []Requirement{{Parent: "cool/config", Constraint: "v0.4.0 || =v0.5.0"}},
+				Depth:        1,
+				Name:         "crossplane-provider-aws",
+				Err:          &DependencyError{Reason: DependencyNoValidVersion, err: errors.Wrap(errors.Errorf(errNoAllowedVersionFmt, "crossplane/provider-aws", "v0.4.0, v0.5.0"), errNoValidVersion)},
+			}}},
+		},
+		"EnumerationOverlap": {
+			reason: "We should plan to install a version that every package's enumeration of allowed versions includes.",
+			args: args{
+				pkgs: []v1beta1.LockPackage{
+					config(provider("v0.1.0 || v0.2.0")),
+					{Source: "cool/other-config", Dependencies: []v1beta1.Dependency{provider("v0.2.0 || v0.3.0")}},
+				},
+				fetcher: &mockTagsFetcher{tags: tags},
+			},
+			want: want{plan: []PlannedInstall{{
+				Dependency: provider("v0.1.0 || v0.2.0"),
+				Name:       "crossplane-provider-aws",
+				Source:     "crossplane/provider-aws:v0.2.0",
+				Version:    "v0.2.0",
+				Candidates: 3,
+				Requirements: []Requirement{
+					{Parent: "cool/config", Constraint: "v0.1.0 || v0.2.0"},
+					{Parent: "cool/other-config", Constraint: "v0.2.0 || v0.3.0"},
+				},
+				Depth: 1,
+			}}},
+		},
+		"EnumerationNoOverlap": {
+			reason: "We should report that the versions packages allow a missing dependency do not overlap without fetching its tags.",
+			args: args{
+				pkgs: []v1beta1.LockPackage{
+					config(provider("v0.1.0 || v0.2.0")),
+					{Source: "cool/other-config", Dependencies: []v1beta1.Dependency{provider(">=v0.3.0")}},
+				},
+				fetcher: &mockTagsFetcher{err: errBoom},
+			},
+			want: want{plan: []PlannedInstall{{
+				Dependency: provider("v0.1.0 || v0.2.0"),
+				Requirements: []Requirement{
+					{Parent: "cool/config", Constraint: "v0.1.0 || v0.2.0"},
+					{Parent: "cool/other-config", Constraint: ">=v0.3.0"},
+				},
+				Depth: 1,
+				Err:   &DependencyError{Reason: DependencyNoValidVersion, err: errors.Wrap(errors.Errorf(errNoOverlapFmt, "crossplane/provider-aws", `"v0.1.0 || v0.2.0" required by cool/config, ">=v0.3.0" required by cool/other-config`), errNoValidVersion)},
+			}}},
+		},
+		"DigestPinning": {
+			reason: "We should plan to install a dependency at the digest its selected tag points at if we pin digests.",
+			args: args{
+				opts:    []ResolverOption{WithDigestPinning()},
+				pkgs:    []v1beta1.LockPackage{config(provider(">=v0.1.0"))},
+				fetcher: &mockDigestFetcher{mockTagsFetcher: mockTagsFetcher{tags: tags}, digest: digest},
+			},
+			want: want{plan: []PlannedInstall{{
+				Dependency:   provider(">=v0.1.0"),
+				Candidates:   3,
+				Requirements: []Requirement{{Parent: "cool/config", Constraint: ">=v0.1.0"}},
+				Depth:        1,
+				Name:         "crossplane-provider-aws",
+				Source:       "crossplane/provider-aws@" + digest,
+				Version:      "v0.3.0",
+				Digest:       digest,
+			}}},
+		},
+		"DigestVerified": {
+			reason: "We should plan to install a dependency at the digest its selected tag points at if it matches the digest recorded when it was published.",
+			args: args{
+				opts: []ResolverOption{WithDigestPinning(), WithVersionSource(&mockDigestSource{
+					versions: []string{"v0.1.0", "v0.2.0"},
+					digests:  map[string]string{"v0.2.0": digest},
+				})},
+				pkgs:    []v1beta1.LockPackage{config(provider(">=v0.1.0"))},
+				fetcher: &mockDigestFetcher{mockTagsFetcher: mockTagsFetcher{tags: tags}, digest: digest},
+			},
+			want: want{plan: []PlannedInstall{{
+				Dependency:   provider(">=v0.1.0"),
+				Candidates:   2,
+				Requirements: []Requirement{{Parent: "cool/config", Constraint: ">=v0.1.0"}},
+				Depth:        1,
+				Name:         "crossplane-provider-aws",
+				Source:       "crossplane/provider-aws@" + digest,
+				Version:      "v0.2.0",
+				Digest:       digest,
+			}}},
+		},
+		"DigestMismatch": {
+			reason: "We should report a dependency whose selected tag no longer points at the digest recorded when it was published.",
+			args: args{
+				opts: []ResolverOption{WithDigestPinning(), WithVersionSource(&mockDigestSource{
+					versions: []string{"v0.1.0", "v0.2.0"},
+					digests:  map[string]string{"v0.2.0": "sha256:0000"},
+				})},
+				pkgs:    []v1beta1.LockPackage{config(provider(">=v0.1.0"))},
+				fetcher: &mockDigestFetcher{mockTagsFetcher: mockTagsFetcher{tags: tags}, digest: digest},
+			},
+			want: want{plan: []PlannedInstall{{
+				Dependency:   provider(">=v0.1.0"),
+				Candidates:   2,
+				Requirements: []Requirement{{Parent: "cool/config", Constraint: ">=v0.1.0"}},
+				Depth:        1,
+				Name:         "crossplane-provider-aws",
+				Source:       "crossplane/provider-aws:v0.2.0",
+				Version:      "v0.2.0",
+				Err:          dependencyErr(DependencyDigestMismatch, errVerifyDigest, &digestMismatchError{tag: "v0.2.0", repo: "index.docker.io/crossplane/provider-aws", digest: digest, recorded: "sha256:0000"}, provider(">=v0.1.0")),
+			}}},
+		},
+		"FetchDigest": {
+			reason: "We should report a dependency whose selected tag's digest cannot be fetched.",
+			args: args{
+				opts:    []ResolverOption{WithDigestPinning()},
+				pkgs:    []v1beta1.LockPackage{config(provider(">=v0.1.0"))},
+				fetcher: &mockDigestFetcher{mockTagsFetcher: mockTagsFetcher{tags: tags}, err: errBoom},
+			},
+			want: want{plan: []PlannedInstall{{
+				Dependency:   provider(">=v0.1.0"),
+				Candidates:   3,
+				Requirements: []Requirement{{Parent: "cool/config", Constraint: ">=v0.1.0"}},
+				Depth:        1,
+				Name:         "crossplane-provider-aws",
+				Source:       "crossplane/provider-aws:v0.3.0",
+				Version:      "v0.3.0",
+				Err:          dependencyErr(DependencyFetchDigest, errFetchDigest, errBoom, provider(">=v0.1.0")),
+			}}},
+		},
+		"TrackedTag": {
+			reason: "We should plan to install a dependency that tracks a tag at the digest the tag points at.",
+			args: args{
+				pkgs:    []v1beta1.LockPackage{config(provider("track:v1-stable"))},
+				fetcher: &mockDigestFetcher{mockTagsFetcher: mockTagsFetcher{tags: tags}, digest: digest},
+			},
+			want: want{plan: []PlannedInstall{{
+				Dependency:   provider("track:v1-stable"),
+				Requirements: []Requirement{{Parent: "cool/config", Constraint: "track:v1-stable"}},
+				Depth:        1,
+				Name:         "crossplane-provider-aws",
+				Source:       "crossplane/provider-aws@" + digest,
+				Version:      digest,
+				Digest:       digest,
+				Pinned:       true,
+			}}},
+		},
+		"TrackedTagFetchDigest": {
+			reason: "We should report a dependency that tracks a tag whose digest cannot be fetched.",
+			args: args{
+				pkgs:    []v1beta1.LockPackage{config(provider("track:v1-stable"))},
+				fetcher: &mockDigestFetcher{mockTagsFetcher: mockTagsFetcher{tags: tags}, err: errBoom},
+			},
+			want: want{plan: []PlannedInstall{{
+				Dependency:   provider("track:v1-stable"),
+				Requirements: []Requirement{{Parent: "cool/config", Constraint: "track:v1-stable"}},
+				Depth:        1,
+				Name:         "crossplane-provider-aws",
+				Pinned:       true,
+				Err:          dependencyErr(DependencyFetchDigest, errFetchDigest, errBoom, provider("track:v1-stable")),
+			}}},
+		},
+		"TrackedTagInstalled": {
+			reason: "We should consider a dependency that tracks a tag satisfied by whichever digest is installed.",
+			args: args{
+				pkgs: []v1beta1.LockPackage{
+					config(provider("track:v1-stable")),
+					{Source: "crossplane/provider-aws", Version: digest},
+				},
+				fetcher: &mockTagsFetcher{tags: tags},
+			},
+			want: want{plan: []PlannedInstall{}},
+		},
+		"NilFetcher": {
+			reason: "We should plan which dependencies are missing without selecting versions if there is no Fetcher.",
+			args: args{
+				pkgs: []v1beta1.LockPackage{config(provider(">=v0.1.0"))},
+			},
+			want: want{plan: []PlannedInstall{{
+				Dependency:   provider(">=v0.1.0"),
+				Requirements: []Requirement{{Parent: "cool/config", Constraint: ">=v0.1.0"}},
+				Depth:        1,
+				Name:         "crossplane-provider-aws",
+			}}},
+		},
+		"InvalidConstraint": {
+			reason: "We should report a dependency whose constraint is not valid.",
+			args: args{
+				pkgs:    []v1beta1.LockPackage{config(provider("not a constraint"))},
+				fetcher: &mockTagsFetcher{tags: tags},
+			},
+			want: want{plan: []PlannedInstall{{
+				Dependency:   provider("not a constraint"),
+				Requirements: []Requirement{{Parent: "cool/config", Constraint: "not a constraint"}},
+				Depth:        1,
+				Err:          dependencyErr(DependencyInvalidConstraint, errInvalidDependencyConstraint, errors.Wrapf(errors.New("improper constraint: not a constraint"), requiredByFmt, "not a constraint", "cool/config"), provider("not a constraint")),
+			}}},
+		},
+		"FetchTags": {
+			reason: "We should report a dependency whose tags cannot be fetched.",
+			args: args{
+				pkgs:    []v1beta1.LockPackage{config(provider(">=v0.1.0"))},
+				fetcher: &mockTagsFetcher{err: errBoom},
+			},
+			want: want{plan: []PlannedInstall{{
+				Dependency:   provider(">=v0.1.0"),
+				Requirements: []Requirement{{Parent: "cool/config", Constraint: ">=v0.1.0"}},
+				Depth:        1,
+				Name:         "crossplane-provider-aws",
+				Err:          dependencyErr(DependencyFetchTags, errFetchTags, errBoom, provider(">=v0.1.0")),
+			}}},
+		},
+		"NoValidVersion": {
+			reason: "We should report a dependency with no version that satisfies its constraint.",
+			args: args{
+				pkgs:    []v1beta1.LockPackage{config(provider(">=v1.0.0"))},
+				fetcher: &mockTagsFetcher{tags: tags},
+			},
+			want: want{plan: []PlannedInstall{{
+				Dependency:   provider(">=v1.0.0"),
+				Candidates:   3,
+				Requirements: []Requirement{{Parent: "cool/config", Constraint: ">=v1.0.0"}},
+				Depth:        1,
+				Name:         "crossplane-provider-aws",
+				Err:          &DependencyError{Reason: DependencyNoValidVersion, err: errors.Wrap(nearestMiss(errors.Errorf(errNoValidVersionFmt, "crossplane/provider-aws", ">=v1.0.0"), "v0.3.0"), errNoValidVersion)},
+			}}},
+		},
+		"Function": {
+			reason: "We should plan to install a dependency on a Function package.",
+			args: args{
+				pkgs:    []v1beta1.LockPackage{config(v1beta1.Dependency{Package: "crossplane/function-nop", Type: v1beta1.FunctionPackageType, Constraints: ">=v0.1.0"})},
+				fetcher: &mockTagsFetcher{tags: tags},
+			},
+			want: want{plan: []PlannedInstall{{
+				Dependency:   v1beta1.Dependency{Package: "crossplane/function-nop", Type: v1beta1.FunctionPackageType, Constraints: ">=v0.1.0"},
+				Candidates:   3,
+				Requirements: []Requirement{{Parent: "cool/config", Constraint: ">=v0.1.0"}},
+				Depth:        1,
+				Name:         "crossplane-function-nop",
+				Source:       "crossplane/function-nop:v0.3.0",
+				Version:      "v0.3.0",
+			}}},
+		},
+		"UnsupportedType": {
+			reason: "We should report a dependency on a type of package that cannot be a dependency without fetching its tags.",
+			args: args{
+				pkgs:    []v1beta1.LockPackage{config(v1beta1.Dependency{Package: "crossplane/composition-nop", Type: "Composition", Constraints: ">=v0.1.0"})},
+				fetcher: &mockTagsFetcher{err: errBoom},
+			},
+			want: want{plan: []PlannedInstall{{
+				Dependency:   v1beta1.Dependency{Package: "crossplane/composition-nop", Type: "Composition", Constraints: ">=v0.1.0"},
+				Requirements: []Requirement{{Parent: "cool/config", Constraint: ">=v0.1.0"}},
+				Depth:        1,
+				Err:          dependencyErr(DependencyUnsupportedType, errInvalidPackageType, errors.Errorf(errUnsupportedPackageTypeFmt, "Composition"), v1beta1.Dependency{Package: "crossplane/composition-nop", Constraints: ">=v0.1.0"}),
+			}}},
+		},
+		"Conflict": {
+			reason: "We should report an installed package that does not satisfy the constraints on it.",
+			args: args{
+				pkgs: []v1beta1.LockPackage{
+					config(provider(">=v0.3.0")),
+					{Source: "crossplane/provider-aws", Version: "v0.2.0"},
+				},
+				fetcher: &mockTagsFetcher{tags: tags},
+			},
+			want: want{
+				plan: []PlannedInstall{},
+				conflicts: []Conflict{{
+					Reason:       ConflictVersion,
+					Package:      "crossplane/provider-aws",
+					Version:      "v0.2.0",
+					Origin:       v1beta1.PackageOrigin{Type: v1beta1.OriginUser},
+					Requirements: []Requirement{{Parent: "cool/config", Constraint: ">=v0.3.0"}},
+				}},
+			},
+		},
+		"Cycle": {
+			reason: "We should return a SortError if the packages' dependencies form a cycle.",
+			args: args{
+				pkgs: []v1beta1.LockPackage{
+					{Source: "cool/config-a", Dependencies: []v1beta1.Dependency{{Package: "cool/config-b"}}},
+					{Source: "cool/config-b", Dependencies: []v1beta1.Dependency{{Package: "cool/config-a"}}},
+				},
+			},
+			want: want{err: &SortError{err: &dag.CyclicError{Cycle: []string{"cool/config-a", "cool/config-b", "cool/config-a"}}}},
+		},
+		"ErrBuildDAG": {
+			reason: "We should return an error if we cannot build the DAG.",
+			args: args{
+				opts: []ResolverOption{WithNewDAGFn(func() dag.DAG {
+					return &fakedag.MockDag{MockInit: func(_ []dag.Node, _ ...dag.NodeFn) ([]dag.ImpliedNode, error) { return nil, errBoom }}
+				})},
+			},
+			want: want{err: errors.Wrap(errBoom, errBuildDAG)},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			plan, conflicts, err := NewResolver(tc.args.opts...).Resolve(context.Background(), tc.args.pkgs, tc.args.fetcher)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nResolve(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.plan, plan, cmp.Comparer(func(a, b *DependencyError) bool {
+				if a == nil || b == nil {
+					return a == nil && b == nil
+				}
+				return a.Reason == b.Reason && a.Error() == b.Error()
+			})); diff != "" {
+				t.Errorf("\n%s\nResolve(...): -want plan, +got plan:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.conflicts, conflicts, cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("\n%s\nResolve(...): -want conflicts, +got conflicts:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+// mockDigestFetcher returns the same digest for every tag.
+type mockDigestFetcher struct {
+	mockTagsFetcher
+	digest string
+	err    error
+}
+
+func (f *mockDigestFetcher) Head(_ context.Context, _ name.Reference, _ ...string) (*v1.Descriptor, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	h, err := v1.NewHash(f.digest)
+	return &v1.Descriptor{Digest: h}, err
+}
+
+// mockDigestSource lists versions and the digests recorded for them.
+type mockDigestSource struct {
+	versions []string
+	digests  map[string]string
+}
+
+func (s *mockDigestSource) ListVersions(_ context.Context, _ name.Reference) ([]string, error) {
+	return s.versions, nil
+}
+
+func (s *mockDigestSource) RecordedDigest(_ context.Context, _ name.Reference, version string) (string, error) {
+	return s.digests[version], nil
+}
+
+// pagedFetcher serves tags a page at a time and counts the pages it serves.
+type pagedFetcher struct {
+	NopFetcher
+	tags  []string
+	size  int
+	pages int
+}
+
+func (f *pagedFetcher) TagsPaged(_ context.Context, _ name.Reference, fn TagPageFn, _ ...string) error {
+	for i := 0; i < len(f.tags); i += f.size {
+		f.pages++
+		end := i + f.size
+		if end > len(f.tags) {
+			end = len(f.tags)
+		}
+		if !fn(f.tags[i:end]) {
+			return nil
+		}
+	}
+	return nil
+}
+
+func TestResolveTagOrder(t *testing.T) {
+	// A repository with 10,000 tags, half of which are signatures, listed
+	// from lowest to highest version.
+	tags := make([]string, 0, 10000)
+	for i := 0; i < 5000; i++ {
+		tags = append(tags, fmt.Sprintf("v0.%d.0", i), fmt.Sprintf("sha256-%064x.sig", i))
+	}
+
+	type want struct {
+		pages  int
+		source string
+	}
+
+	cases := map[string]struct {
+		reason     string
+		order      TagOrder
+		selection  VersionSelection
+		constraint string
+		want       want
+	}{
+		"Unordered": {
+			reason:     "We should fetch every page if tags are not listed in order.",
+			selection:  VersionSelectionLowest,
+			constraint: ">=v0.10.0",
+			want:       want{pages: 10, source: "crossplane/provider-nightly:v0.10.0"},
+		},
+		"AscendingLowest": {
+			reason:     "We should stop once we find the lowest satisfying version if tags are listed in ascending order.",
+			order:      TagOrderAscending,
+			selection:  VersionSelectionLowest,
+			constraint: ">=v0.10.0",
+			want:       want{pages: 1, source: "crossplane/provider-nightly:v0.10.0"},
+		},
+		"AscendingLowestLaterPage": {
+			reason:     "We should keep fetching pages until we find a satisfying version.",
+			order:      TagOrderAscending,
+			selection:  VersionSelectionLowest,
+			constraint: ">=v0.1200.0",
+			want:       want{pages: 3, source: "crossplane/provider-nightly:v0.1200.0"},
+		},
+		"AscendingHighest": {
+			reason:     "We should fetch every page to find the highest version if tags are listed in ascending order.",
+			order:      TagOrderAscending,
+			selection:  VersionSelectionHighest,
+			constraint: ">=v0.10.0",
+			want:       want{pages: 10, source: "crossplane/provider-nightly:v0.4999.0"},
+		},
+		"Unsatisfiable": {
+			reason:     "We should fetch every page if no version satisfies the constraint.",
+			order:      TagOrderAscending,
+			selection:  VersionSelectionLowest,
+			constraint: ">=v1.0.0",
+			want:       want{pages: 10},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			f := &pagedFetcher{tags: tags, size: 1000}
+			r := NewResolver(WithTagOrder(tc.order), WithVersionSelection(tc.selection))
+			pkgs := []v1beta1.LockPackage{{
+				Source:       "cool/config",
+				Dependencies: []v1beta1.Dependency{{Package: "crossplane/provider-nightly", Type: v1beta1.ProviderPackageType, Constraints: tc.constraint}},
+			}}
+			plan, _, err := r.Resolve(context.Background(), pkgs, f)
+			if err != nil {
+				t.Fatalf("\n%s\nResolve(...): unexpected error: %s", tc.reason, err)
+			}
+			if diff := cmp.Diff(tc.want.pages, f.pages); diff != "" {
+				t.Errorf("\n%s\nResolve(...): -want pages, +got pages:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.source, plan[0].Source); diff != "" {
+				t.Errorf("\n%s\nResolve(...): -want source, +got source:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+// latencyFetcher fetches the tags of each repository after a delay, and
+// records the most fetches that were in flight at once. It blocks until the
+// context is done when fetching the tags of a slow repository.
+type latencyFetcher struct {
+	NopFetcher
+	delay time.Duration
+	slow  map[string]bool
+
+	mu       sync.Mutex
+	inflight int
+	max      int
+}
+
+func (f *latencyFetcher) Tags(ctx context.Context, ref name.Reference, _ ...string) ([]string, error) {
+	f.mu.Lock()
+	f.inflight++
+	if f.inflight > f.max {
+		f.max = f.inflight
+	}
+	f.mu.Unlock()
+	defer func() {
+		f.mu.Lock()
+		f.inflight--
+		f.mu.Unlock()
+	}()
+
+	if f.slow[ref.Context().RepositoryStr()] {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	select {
+	case <-time.After(f.delay):
+		return []string{"v1.0.0"}, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func TestResolveConcurrency(t *testing.T) {
+	deps := make([]v1beta1.Dependency, 8)
+	for i := range deps {
+		deps[i] = v1beta1.Dependency{Package: fmt.Sprintf("cool/provider-%d", i), Type: v1beta1.ProviderPackageType, Constraints: ">=v1.0.0"}
+	}
+	pkgs := []v1beta1.LockPackage{{Source: "cool/config", Dependencies: deps}}
+
+	type want struct {
+		max     int
+		planned int
+		failed  []string
+	}
+
+	cases := map[string]struct {
+		reason string
+		opts   []ResolverOption
+		slow   map[string]bool
+		want   want
+	}{
+		"Default": {
+			reason: "We should fetch the tags of at most four repositories at once by default.",
+			want:   want{max: 4, planned: 8},
+		},
+		"Limited": {
+			reason: "We should fetch the tags of at most the configured number of repositories at once.",
+			opts:   []ResolverOption{WithMaxConcurrentFetches(3)},
+			want:   want{max: 3, planned: 8},
+		},
+		"Serial": {
+			reason: "We should fetch the tags of one repository at a time if configured to.",
+			opts:   []ResolverOption{WithMaxConcurrentFetches(1)},
+			want:   want{max: 1, planned: 8},
+		},
+		"SlowRegistry": {
+			reason: "A slow registry should not prevent us from planning the dependencies whose tags we could fetch.",
+			opts:   []ResolverOption{WithFetchTimeout(100 * time.Millisecond)},
+			slow:   map[string]bool{"cool/provider-0": true, "cool/provider-5": true},
+			want:   want{max: 4, planned: 6, failed: []string{"cool/provider-0", "cool/provider-5"}},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			f := &latencyFetcher{delay: 20 * time.Millisecond, slow: tc.slow}
+			plan, _, err := NewResolver(tc.opts...).Resolve(context.Background(), pkgs, f)
+			if err != nil {
+				t.Fatalf("\n%s\nResolve(...): unexpected error: %s", tc.reason, err)
+			}
+			if diff := cmp.Diff(tc.want.max, f.max); diff != "" {
+				t.Errorf("\n%s\nResolve(...): -want most concurrent fetches, +got most concurrent fetches:\n%s", tc.reason, diff)
+			}
+
+			planned := 0
+			var failed []string
+			for i, p := range plan {
+				if diff := cmp.Diff(deps[i], p.Dependency); diff != "" {
+					t.Errorf("\n%s\nResolve(...): -want dependency, +got dependency:\n%s", tc.reason, diff)
+				}
+				if p.Err != nil {
+					if p.Err.Reason != DependencyFetchTags {
+						t.Errorf("\n%s\nResolve(...): want reason %s, got %s", tc.reason, DependencyFetchTags, p.Err.Reason)
+					}
+					failed = append(failed, p.Dependency.Package)
+					continue
+				}
+				planned++
+			}
+			if diff := cmp.Diff(tc.want.planned, planned); diff != "" {
+				t.Errorf("\n%s\nResolve(...): -want planned, +got planned:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.failed, failed); diff != "" {
+				t.Errorf("\n%s\nResolve(...): -want failed, +got failed:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
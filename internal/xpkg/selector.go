@@ -0,0 +1,286 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package xpkg
+
+import (
+	"container/list"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/Masterminds/semver"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+)
+
+const (
+	// defaultConstraintCacheSize is the number of parsed constraints a
+	// VersionSelector caches by default.
+	defaultConstraintCacheSize = 256
+
+	errNoMatchingVersion = "no version satisfies constraints"
+	errNoMatchFmt        = "no version satisfies constraints %s"
+	errNearestMissFmt    = "highest available version is %s but constraints require %s"
+)
+
+var (
+	// ErrInvalidConstraint is matched by the error a VersionSelector
+	// returns when a version constraint can't be parsed.
+	ErrInvalidConstraint = errors.New(errInvalidConstraint)
+
+	// ErrNoMatch is matched by the error a VersionSelector returns when no
+	// version satisfies the supplied constraints.
+	ErrNoMatch = errors.New(errNoMatchingVersion)
+)
+
+// An InvalidConstraintError indicates a version constraint can't be parsed.
+// It matches ErrInvalidConstraint.
+type InvalidConstraintError struct {
+	// Constraint that can't be parsed.
+	Constraint string
+
+	err error
+}
+
+func (e *InvalidConstraintError) Error() string {
+	return errInvalidConstraint + ": " + e.err.Error()
+}
+
+// Unwrap returns the error parsing the constraint.
+func (e *InvalidConstraintError) Unwrap() error {
+	return e.err
+}
+
+// Is returns true if the supplied error is ErrInvalidConstraint.
+func (e *InvalidConstraintError) Is(target error) bool {
+	return target == ErrInvalidConstraint
+}
+
+// A NoMatchError indicates no version satisfies a set of constraints. It
+// matches ErrNoMatch.
+type NoMatchError struct {
+	// Constraints no version satisfies.
+	Constraints []string
+
+	// Nearest is the highest version that was considered, if any. Most
+	// constraints require a minimum version, so this is usually the
+	// version that came closest to satisfying them.
+	Nearest string
+}
+
+func (e *NoMatchError) Error() string {
+	cs := strings.Join(e.Constraints, ", ")
+	if e.Nearest == "" {
+		return fmt.Sprintf(errNoMatchFmt, cs)
+	}
+	return fmt.Sprintf(errNearestMissFmt, e.Nearest, cs)
+}
+
+// Is returns true if the supplied error is ErrNoMatch.
+func (e *NoMatchError) Is(target error) bool {
+	return target == ErrNoMatch
+}
+
+// A VersionSelectorOption configures a VersionSelector.
+type VersionSelectorOption func(s *VersionSelector)
+
+// WithConstraintCacheSize specifies how many parsed constraints a
+// VersionSelector caches. Zero disables caching.
+func WithConstraintCacheSize(n int) VersionSelectorOption {
+	return func(s *VersionSelector) {
+		s.size = n
+	}
+}
+
+// A VersionSelector selects versions of a package from its tags. Parsed
+// constraints are cached, so that selecting versions for the same
+// dependencies repeatedly doesn't parse the same constraints repeatedly. The
+// zero value doesn't cache constraints. A VersionSelector is safe for
+// concurrent use.
+type VersionSelector struct {
+	size int
+
+	mu    sync.Mutex
+	order *list.List
+	cache map[string]*list.Element
+}
+
+type cachedConstraint struct {
+	constraint string
+	parsed     *semver.Constraints
+}
+
+// NewVersionSelector returns a VersionSelector that caches parsed
+// constraints.
+func NewVersionSelector(o ...VersionSelectorOption) *VersionSelector {
+	s := &VersionSelector{size: defaultConstraintCacheSize}
+	for _, fn := range o {
+		fn(s)
+	}
+	return s
+}
+
+// Parse the supplied semantic version constraint, which may be an alias.
+// Any stable version satisfies the constraint of an alias; only a selected
+// version is limited to the highest. It returns an *InvalidConstraintError
+// if the constraint can't be parsed.
+func (s *VersionSelector) Parse(constraint string) (*semver.Constraints, error) {
+	if c, ok := s.cached(constraint); ok {
+		return c, nil
+	}
+	c, err := NewConstraint(constraint)
+	if err != nil {
+		return nil, &InvalidConstraintError{Constraint: constraint, err: err}
+	}
+	s.store(constraint, c)
+	return c, nil
+}
+
+// cached returns the parsed constraint, if it is cached. The constraint
+// becomes the most recently used.
+func (s *VersionSelector) cached(constraint string) (*semver.Constraints, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.cache[constraint]
+	if !ok {
+		return nil, false
+	}
+	s.order.MoveToFront(e)
+	return e.Value.(*cachedConstraint).parsed, true
+}
+
+// store the supplied parsed constraint, evicting the least recently used
+// constraint if the cache is full.
+func (s *VersionSelector) store(constraint string, c *semver.Constraints) {
+	if s.size <= 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cache == nil {
+		s.order = list.New()
+		s.cache = map[string]*list.Element{}
+	}
+	if e, ok := s.cache[constraint]; ok {
+		s.order.MoveToFront(e)
+		return
+	}
+	s.cache[constraint] = s.order.PushFront(&cachedConstraint{constraint: constraint, parsed: c})
+	for s.order.Len() > s.size {
+		e := s.order.Back()
+		s.order.Remove(e)
+		delete(s.cache, e.Value.(*cachedConstraint).constraint)
+	}
+}
+
+// Select returns the tag selected by the supplied policy from those that
+// satisfy all of the supplied semantic version constraints. Tags that are not
+// semantic versions are ignored. Tags with a prerelease component (e.g.
+// v1.2.0-rc.1) are only considered if every constraint includes one (e.g.
+// >=1.2.0-0). If any constraint is an alias of latest only the highest stable
+// tag is selected, regardless of policy, and only if it satisfies the other
+// constraints. When several tags are the same version, e.g. 1.0.0 and
+// v1.0.0, the tag that sorts last is selected. It returns an
+// *InvalidConstraintError if any constraint can't be parsed, and a
+// *NoMatchError if no tag satisfies every constraint.
+func (s *VersionSelector) Select(tags []string, policy VersionSelection, constraints ...string) (string, error) {
+	vs, highest, err := s.matching(constraints, tags)
+	if err != nil {
+		return "", err
+	}
+	if len(vs) == 0 {
+		e := &NoMatchError{Constraints: constraints}
+		if highest != nil {
+			e.Nearest = highest.Original()
+		}
+		return "", e
+	}
+	if policy == VersionSelectionLowest {
+		return lowest(vs), nil
+	}
+	return vs[len(vs)-1].Original(), nil
+}
+
+// Satisfying returns, for each of the supplied semantic version constraints,
+// the tags that satisfy it alone, from lowest to highest. Tags are considered
+// as they are by Select. It returns an *InvalidConstraintError if any
+// constraint can't be parsed.
+func (s *VersionSelector) Satisfying(tags []string, constraints ...string) ([][]string, error) {
+	sets := make([][]string, len(constraints))
+	for i, c := range constraints {
+		vs, _, err := s.matching([]string{c}, tags)
+		if err != nil {
+			return nil, err
+		}
+		sets[i] = make([]string, len(vs))
+		for j, v := range vs {
+			sets[i][j] = v.Original()
+		}
+	}
+	return sets, nil
+}
+
+// Streams returns the version streams, i.e. the major.minor versions, of the
+// tags that satisfy all of the supplied semantic version constraints, from
+// lowest to highest. Tags are considered as they are by Select. It returns an
+// *InvalidConstraintError if any constraint can't be parsed.
+func (s *VersionSelector) Streams(tags []string, constraints ...string) ([]string, error) {
+	vs, _, err := s.matching(constraints, tags)
+	if err != nil {
+		return nil, err
+	}
+	streams := []string{}
+	for _, v := range vs {
+		st := stream(v)
+		if len(streams) == 0 || streams[len(streams)-1] != st {
+			streams = append(streams, st)
+		}
+	}
+	return streams, nil
+}
+
+// IntersectStreams returns the version streams that appear in every one of
+// the supplied sets of streams, each of which must be sorted from lowest to
+// highest as returned by Streams. The result is sorted the same way.
+func IntersectStreams(sets ...[]string) []string {
+	return intersect(sets...)
+}
+
+// Stream returns the version stream, i.e. the major.minor version, of the
+// supplied tag. It returns false if the tag isn't a semantic version.
+func Stream(tag string) (string, bool) {
+	v, err := semver.NewVersion(tag)
+	if err != nil {
+		return "", false
+	}
+	return stream(v), true
+}
+
+func stream(v *semver.Version) string {
+	return fmt.Sprintf("%d.%d", v.Major(), v.Minor())
+}
+
+// InStream returns the supplied tags that are in the supplied version stream.
+func InStream(tags []string, st string) []string {
+	kept := []string{}
+	for _, t := range tags {
+		if s, ok := Stream(t); ok && s == st {
+			kept = append(kept, t)
+		}
+	}
+	return kept
+}
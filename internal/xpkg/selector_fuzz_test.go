@@ -0,0 +1,106 @@
+//go:build go1.18
+// +build go1.18
+
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package xpkg
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Masterminds/semver"
+)
+
+// FuzzSelect asserts that whatever VersionSelector.Select selects is one of
+// the supplied tags, satisfies the supplied constraint, is the best tag that
+// does according to the selection policy, and doesn't depend on the order of
+// the tags. Tags are supplied as a comma separated list. Run it with:
+//
+//	go test ./internal/xpkg -run '^$' -fuzz FuzzSelect
+func FuzzSelect(f *testing.F) {
+	// Regressions. Each was once selected incorrectly, so keep them here
+	// even if the fuzzer finds them quickly.
+	f.Add("v1.0.0-rc.1,v1.0.0,v0.9.0", ">=v0.9.0", false)
+	f.Add("v1.0.0,v1.0.0-rc.1,v0.9.0", ">=v1.0.0-0", false)
+	f.Add("v1.0.0-rc.2,v1.0.0-rc.10", ">=v1.0.0-rc.1", false)
+	f.Add("v0.2.0,0.2.0,v0.1.0", "<=v0.2.0", true)
+	f.Add("v0.2.0+build.2,v0.2.0+build.1", "v0.2.0", false)
+
+	// Aliases and common constraint forms.
+	f.Add("v0.1.0,v0.2.0,v0.3.0-rc.1,latest", "latest", false)
+	f.Add("v0.1.0,v0.2.0,v0.3.0", "*", true)
+	f.Add("v0.1.0,v0.2.0,v0.3.0", "~v0.2", false)
+	f.Add("v1.2.3,v1.9.0,v2.0.0", "^v1.2", false)
+	f.Add("v0.1.0,v0.2.0", "v0.1.0 || v0.2.0", true)
+	f.Add("0,010", "", true)
+
+	f.Fuzz(func(t *testing.T, list, constraint string, low bool) {
+		policy := VersionSelectionHighest
+		if low {
+			policy = VersionSelectionLowest
+		}
+		tags := strings.Split(list, ",")
+
+		s := NewVersionSelector()
+		got, err := s.Select(tags, policy, constraint)
+		if err != nil {
+			return
+		}
+
+		if !contains(tags, got) {
+			t.Fatalf("Select(%q, %s, %q): selected %q, which is not one of the tags", tags, policy, constraint, got)
+		}
+		c, err := s.Parse(constraint)
+		if err != nil {
+			t.Fatalf("Select(%q, %s, %q): selected %q, but the constraint is invalid: %s", tags, policy, constraint, got, err)
+		}
+		v, err := semver.NewVersion(got)
+		if err != nil {
+			t.Fatalf("Select(%q, %s, %q): selected %q, which is not a semantic version", tags, policy, constraint, got)
+		}
+		if !c.Check(v) {
+			t.Fatalf("Select(%q, %s, %q): selected %q, which does not satisfy the constraint", tags, policy, constraint, got)
+		}
+
+		// Every tag the selector would select on its own must be no
+		// better than the one it selected from all of them. Only the
+		// highest version satisfies an alias of latest, regardless of
+		// policy.
+		if IsLatestConstraint(constraint) {
+			policy = VersionSelectionHighest
+		}
+		for _, tag := range tags {
+			alone, err := s.Select([]string{tag}, policy, constraint)
+			if err != nil {
+				continue
+			}
+			o, _ := semver.NewVersion(alone)
+			if (policy == VersionSelectionHighest && o.GreaterThan(v)) || (policy == VersionSelectionLowest && o.LessThan(v)) {
+				t.Fatalf("Select(%q, %s, %q): selected %q, but %q is better", tags, policy, constraint, got, alone)
+			}
+		}
+
+		reversed := make([]string, len(tags))
+		for i, tag := range tags {
+			reversed[len(tags)-1-i] = tag
+		}
+		if again, err := s.Select(reversed, policy, constraint); err != nil || again != got {
+			t.Fatalf("Select(%q, %s, %q): selected %q, but selected %q (error %v) from the same tags in reverse order", tags, policy, constraint, got, again, err)
+		}
+	})
+}
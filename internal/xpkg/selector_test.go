@@ -0,0 +1,544 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package xpkg
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+)
+
+func TestVersionSelectorSelect(t *testing.T) {
+	type args struct {
+		tags        []string
+		policy      VersionSelection
+		constraints []string
+	}
+	type want struct {
+		version string
+		err     error
+		is      error
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"InvalidConstraint": {
+			reason: "We should return an error matching ErrInvalidConstraint if a constraint is invalid.",
+			args: args{
+				tags:        []string{"v1.0.0"},
+				constraints: []string{">=v1.0.0", "not a constraint"},
+			},
+			want: want{
+				err: &InvalidConstraintError{Constraint: "not a constraint", err: errors.New("improper constraint: not a constraint")},
+				is:  ErrInvalidConstraint,
+			},
+		},
+		"Highest": {
+			reason: "We should select the highest version that satisfies every constraint.",
+			args: args{
+				tags:        []string{"v1.0.0", "v1.1.0", "v1.2.0", "v2.0.0"},
+				policy:      VersionSelectionHighest,
+				constraints: []string{">=v1.0.0", "<v2.0.0"},
+			},
+			want: want{
+				version: "v1.2.0",
+			},
+		},
+		"Lowest": {
+			reason: "We should select the lowest version that satisfies every constraint.",
+			args: args{
+				tags:        []string{"v1.2.0", "v1.1.0", "v0.9.0"},
+				policy:      VersionSelectionLowest,
+				constraints: []string{">=v1.0.0"},
+			},
+			want: want{
+				version: "v1.1.0",
+			},
+		},
+		"NearestMiss": {
+			reason: "We should report the highest available version if no version satisfies the constraints.",
+			args: args{
+				tags:        []string{"latest", "v0.18.0", "v0.17.0"},
+				constraints: []string{">=v1.0.0"},
+			},
+			want: want{
+				err: &NoMatchError{Constraints: []string{">=v1.0.0"}, Nearest: "v0.18.0"},
+				is:  ErrNoMatch,
+			},
+		},
+		"NoSemanticVersions": {
+			reason: "We should not report a nearest miss if no tag is a semantic version.",
+			args: args{
+				tags:        []string{"latest", "main"},
+				constraints: []string{"*"},
+			},
+			want: want{
+				err: &NoMatchError{Constraints: []string{"*"}},
+				is:  ErrNoMatch,
+			},
+		},
+		"StableOnly": {
+			reason: "We should select the highest stable version that satisfies the constraint.",
+			args: args{
+				tags:        []string{"v1.2.0", "v0.9.0", "v1.10.0", "v1.1.0"},
+				policy:      VersionSelectionHighest,
+				constraints: []string{">=v1.0.0"},
+			},
+			want: want{
+				version: "v1.10.0",
+			},
+		},
+		"SkipInvalidTags": {
+			reason: "We should ignore tags that are not semantic versions.",
+			args: args{
+				tags:        []string{"latest", "v1.1.0", "main"},
+				policy:      VersionSelectionHighest,
+				constraints: []string{">=v1.0.0"},
+			},
+			want: want{
+				version: "v1.1.0",
+			},
+		},
+		"MixedExcludesPrerelease": {
+			reason: "We should not select a prerelease if the constraint does not include one.",
+			args: args{
+				tags:        []string{"v1.0.0", "v1.1.0", "v1.2.0-rc.1"},
+				policy:      VersionSelectionHighest,
+				constraints: []string{">=v1.0.0"},
+			},
+			want: want{
+				version: "v1.1.0",
+			},
+		},
+		"OnlyPrerelease": {
+			reason: "We should not select a prerelease even if it is the only tag when the constraint does not include one.",
+			args: args{
+				tags:        []string{"v1.2.0-rc.1"},
+				policy:      VersionSelectionHighest,
+				constraints: []string{"*"},
+			},
+			want: want{
+				err: &NoMatchError{Constraints: []string{"*"}},
+				is:  ErrNoMatch,
+			},
+		},
+		"PrereleaseAllowed": {
+			reason: "We should select the highest matching prerelease if the constraint includes one.",
+			args: args{
+				tags:        []string{"v1.1.0", "v1.2.0-rc.1", "v1.2.0-rc.2"},
+				policy:      VersionSelectionHighest,
+				constraints: []string{">=1.2.0-0"},
+			},
+			want: want{
+				version: "v1.2.0-rc.2",
+			},
+		},
+		"PrereleaseAllowedStableHigher": {
+			reason: "We should select a stable version over a prerelease if it is higher.",
+			args: args{
+				tags:        []string{"v1.2.0-rc.1", "v1.2.0", "v1.1.0"},
+				policy:      VersionSelectionHighest,
+				constraints: []string{">=1.2.0-0"},
+			},
+			want: want{
+				version: "v1.2.0",
+			},
+		},
+		"RangeIsNotPrerelease": {
+			reason: "We should not treat the hyphen of a range as a prerelease.",
+			args: args{
+				tags:        []string{"v1.1.0", "v1.2.0-rc.1"},
+				policy:      VersionSelectionHighest,
+				constraints: []string{"1.0 - 1.2"},
+			},
+			want: want{
+				version: "v1.1.0",
+			},
+		},
+		"LowestIntersection": {
+			reason: "We should select the lowest version that satisfies every constraint.",
+			args: args{
+				tags:        []string{"v0.20.0", "v0.22.0", "v0.21.0", "v1.0.0", "v0.21.0-rc.1"},
+				policy:      VersionSelectionLowest,
+				constraints: []string{">=v0.21.0", "<v1.0.0", ">=v0.20.0"},
+			},
+			want: want{
+				version: "v0.21.0",
+			},
+		},
+		"NoIntersection": {
+			reason: "We should return an error if no tag satisfies every constraint.",
+			args: args{
+				tags:        []string{"v0.9.0", "v1.0.0"},
+				policy:      VersionSelectionLowest,
+				constraints: []string{">=v1.0.0", "<v1.0.0"},
+			},
+			want: want{
+				err: &NoMatchError{Constraints: []string{">=v1.0.0", "<v1.0.0"}, Nearest: "v1.0.0"},
+				is:  ErrNoMatch,
+			},
+		},
+		"PrereleaseExcluded": {
+			reason: "We should not consider prereleases if any constraint does not include one.",
+			args: args{
+				tags:        []string{"v1.1.0", "v1.2.0-rc.1", "v1.2.0"},
+				policy:      VersionSelectionLowest,
+				constraints: []string{">=v1.2.0-0", "<v2.0.0"},
+			},
+			want: want{
+				version: "v1.2.0",
+			},
+		},
+		"PrereleaseIntersection": {
+			reason: "We should consider prereleases if every constraint includes one.",
+			args: args{
+				tags:        []string{"v1.1.0", "v1.2.0-rc.1", "v1.2.0"},
+				policy:      VersionSelectionLowest,
+				constraints: []string{">=v1.2.0-0", "<v2.0.0-0"},
+			},
+			want: want{
+				version: "v1.2.0-rc.1",
+			},
+		},
+		"LowestRange": {
+			reason: "We should select the lowest version within a range.",
+			args: args{
+				tags:        []string{"v0.19.0", "v0.20.0", "v0.20.1", "v0.21.0-rc.0", "v0.21.0", "v0.22.0", "v0.35.0", "v0.36.0-rc.1", "latest", "main", "sha256-abc123.sig"},
+				policy:      VersionSelectionLowest,
+				constraints: []string{"~0.20.1"},
+			},
+			want: want{
+				version: "v0.20.1",
+			},
+		},
+		"HighestPrerelease": {
+			reason: "We should select the highest version, including prereleases, if the constraint includes a prerelease.",
+			args: args{
+				tags:        []string{"v0.19.0", "v0.20.0", "v0.20.1", "v0.21.0-rc.0", "v0.21.0", "v0.22.0", "v0.35.0", "v0.36.0-rc.1", "latest", "main", "sha256-abc123.sig"},
+				policy:      VersionSelectionHighest,
+				constraints: []string{">=v0.21.0-0"},
+			},
+			want: want{
+				version: "v0.36.0-rc.1",
+			},
+		},
+		"LowestPrerelease": {
+			reason: "We should select the lowest version, including prereleases, if the constraint includes a prerelease.",
+			args: args{
+				tags:        []string{"v0.19.0", "v0.20.0", "v0.20.1", "v0.21.0-rc.0", "v0.21.0", "v0.22.0", "v0.35.0", "v0.36.0-rc.1", "latest", "main", "sha256-abc123.sig"},
+				policy:      VersionSelectionLowest,
+				constraints: []string{">=v0.21.0-0"},
+			},
+			want: want{
+				version: "v0.21.0-rc.0",
+			},
+		},
+		"LowestExcludesPrerelease": {
+			reason: "We should not select a prerelease if the constraint does not include one, even if it is the lowest.",
+			args: args{
+				tags:        []string{"v0.19.0", "v0.20.0", "v0.20.1", "v0.21.0-rc.0", "v0.21.0", "v0.22.0", "v0.35.0", "v0.36.0-rc.1", "latest", "main", "sha256-abc123.sig"},
+				policy:      VersionSelectionLowest,
+				constraints: []string{">v0.20.1"},
+			},
+			want: want{
+				version: "v0.21.0",
+			},
+		},
+		"HighestTie": {
+			reason: "We should consistently select the tag that sorts last when several tags are the highest version.",
+			args: args{
+				tags:        []string{"v1.1.0", "1.1.0", "1.0.0"},
+				policy:      VersionSelectionHighest,
+				constraints: []string{">=1.0.0"},
+			},
+			want: want{
+				version: "v1.1.0",
+			},
+		},
+		"LowestTie": {
+			reason: "We should consistently select the tag that sorts last when several tags are the lowest version.",
+			args: args{
+				tags:        []string{"v1.1.0", "v1.0.0", "1.0.0"},
+				policy:      VersionSelectionLowest,
+				constraints: []string{">=1.0.0"},
+			},
+			want: want{
+				version: "v1.0.0",
+			},
+		},
+		"LatestAlias": {
+			reason: "We should select the highest stable version for the latest alias, regardless of policy.",
+			args: args{
+				tags:        []string{"v0.15.0", "v0.17.0", "v0.18.0", "v0.19.0", "v0.19.1", "v0.20.0", "v0.25.0", "latest"},
+				policy:      VersionSelectionLowest,
+				constraints: []string{ConstraintLatest},
+			},
+			want: want{
+				version: "v0.25.0",
+			},
+		},
+		"StableAlias": {
+			reason: "We should select the highest stable version for the stable alias, regardless of policy.",
+			args: args{
+				tags:        []string{"v0.15.0", "v0.17.0", "v0.18.0", "v0.19.0", "v0.19.1", "v0.20.0", "v0.25.0", "latest"},
+				policy:      VersionSelectionLowest,
+				constraints: []string{ConstraintStable},
+			},
+			want: want{
+				version: "v0.25.0",
+			},
+		},
+		"EmptyConstraint": {
+			reason: "We should treat an empty constraint as the latest alias.",
+			args: args{
+				tags:        []string{"v0.15.0", "v0.17.0", "v0.18.0", "v0.19.0", "v0.19.1", "v0.20.0", "v0.25.0", "latest"},
+				policy:      VersionSelectionLowest,
+				constraints: []string{""},
+			},
+			want: want{
+				version: "v0.25.0",
+			},
+		},
+		"AnyAlias": {
+			reason: "We should select any stable version according to policy for the any alias.",
+			args: args{
+				tags:        []string{"v0.15.0", "v0.17.0", "v0.18.0", "v0.19.0", "v0.19.1", "v0.20.0", "v0.25.0", "latest"},
+				policy:      VersionSelectionLowest,
+				constraints: []string{ConstraintAny},
+			},
+			want: want{
+				version: "v0.15.0",
+			},
+		},
+		"LatestIntersection": {
+			reason: "We should select the highest stable version for the latest alias if it satisfies every other constraint.",
+			args: args{
+				tags:        []string{"v0.15.0", "v0.17.0", "v0.18.0", "v0.19.0", "v0.19.1", "v0.20.0", "v0.25.0", "latest"},
+				policy:      VersionSelectionHighest,
+				constraints: []string{ConstraintLatest, ">=0.20.0"},
+			},
+			want: want{
+				version: "v0.25.0",
+			},
+		},
+		"LatestEmptyIntersection": {
+			reason: "We should return an error if the highest stable version doesn't satisfy every other constraint.",
+			args: args{
+				tags:        []string{"v0.15.0", "v0.17.0", "v0.18.0", "v0.19.0", "v0.19.1", "v0.20.0", "v0.25.0", "latest"},
+				policy:      VersionSelectionHighest,
+				constraints: []string{ConstraintLatest, "<0.20.0"},
+			},
+			want: want{
+				err: &NoMatchError{Constraints: []string{ConstraintLatest, "<0.20.0"}, Nearest: "v0.25.0"},
+				is:  ErrNoMatch,
+			},
+		},
+		"InvalidAlias": {
+			reason: "We should return an error for a constraint that is not a recognized alias.",
+			args: args{
+				tags:        []string{"v0.15.0", "v0.17.0", "v0.18.0", "v0.19.0", "v0.19.1", "v0.20.0", "v0.25.0", "latest"},
+				policy:      VersionSelectionHighest,
+				constraints: []string{"newest"},
+			},
+			want: want{
+				err: &InvalidConstraintError{Constraint: "newest", err: errors.New("improper constraint: newest")},
+				is:  ErrInvalidConstraint,
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			v, err := NewVersionSelector().Select(tc.args.tags, tc.args.policy, tc.args.constraints...)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nSelect(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if tc.want.is != nil && !errors.Is(err, tc.want.is) {
+				t.Errorf("\n%s\nSelect(...): want error matching %q, got %q", tc.reason, tc.want.is, err)
+			}
+			if diff := cmp.Diff(tc.want.version, v); diff != "" {
+				t.Errorf("\n%s\nSelect(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestVersionSelectorStreams(t *testing.T) {
+	tags := []string{"v1.16.1", "v1.14.0", "latest", "v1.14.2", "v1.15.0", "v2.0.0", "v1.16.0-rc.1"}
+
+	cases := map[string]struct {
+		reason      string
+		constraints []string
+		want        []string
+	}{
+		"Satisfying": {
+			reason:      "We should return the distinct major.minor streams of the satisfying versions, from lowest to highest.",
+			constraints: []string{">=v1.14.1", "<v2.0.0"},
+			want:        []string{"1.14", "1.15", "1.16"},
+		},
+		"None": {
+			reason:      "We should return no streams if no version satisfies the constraints.",
+			constraints: []string{">=v3.0.0"},
+			want:        []string{},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := NewVersionSelector().Streams(tags, tc.constraints...)
+			if err != nil {
+				t.Fatalf("\n%s\nStreams(...): %s", tc.reason, err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nStreams(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestVersionSelectorSatisfying(t *testing.T) {
+	tags := []string{"v1.16.1", "v1.14.0", "latest", "v1.15.0", "v2.0.0"}
+
+	cases := map[string]struct {
+		reason      string
+		constraints []string
+		want        [][]string
+		err         error
+	}{
+		"PerConstraint": {
+			reason:      "We should return the versions that satisfy each constraint alone, from lowest to highest.",
+			constraints: []string{"<v1.16.0", ">=v1.15.0", ">=v3.0.0"},
+			want:        [][]string{{"v1.14.0", "v1.15.0"}, {"v1.15.0", "v1.16.1", "v2.0.0"}, {}},
+		},
+		"InvalidConstraint": {
+			reason:      "We should return an error if a constraint can't be parsed.",
+			constraints: []string{">=v1.15.0", "not a constraint"},
+			err:         &InvalidConstraintError{Constraint: "not a constraint", err: errors.New("improper constraint: not a constraint")},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := NewVersionSelector().Satisfying(tags, tc.constraints...)
+			if diff := cmp.Diff(tc.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nSatisfying(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nSatisfying(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestIntersectStreams(t *testing.T) {
+	cases := map[string]struct {
+		reason string
+		sets   [][]string
+		want   []string
+	}{
+		"Overlapping": {
+			reason: "We should return the streams every set has in common, in order.",
+			sets:   [][]string{{"1.14", "1.15", "1.16"}, {"1.15", "1.16", "1.17"}, {"1.13", "1.15", "1.16"}},
+			want:   []string{"1.15", "1.16"},
+		},
+		"Disjoint": {
+			reason: "We should return no streams if the sets have none in common.",
+			sets:   [][]string{{"1.14"}, {"1.16"}},
+			want:   []string{},
+		},
+		"NoSets": {
+			reason: "We should return no streams if there are no sets.",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if diff := cmp.Diff(tc.want, IntersectStreams(tc.sets...)); diff != "" {
+				t.Errorf("\n%s\nIntersectStreams(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestVersionSelectorParse(t *testing.T) {
+	type want struct {
+		cached  bool
+		evicted string
+	}
+
+	cases := map[string]struct {
+		reason string
+		s      *VersionSelector
+		parse  []string
+		want   want
+	}{
+		"Cached": {
+			reason: "We should return the same parsed constraint when it is parsed again.",
+			s:      NewVersionSelector(),
+			parse:  []string{">=v1.0.0"},
+			want:   want{cached: true},
+		},
+		"CacheDisabled": {
+			reason: "We should not cache constraints if the cache size is zero.",
+			s:      NewVersionSelector(WithConstraintCacheSize(0)),
+			parse:  []string{">=v1.0.0"},
+			want:   want{cached: false},
+		},
+		"ZeroValue": {
+			reason: "We should not cache constraints using the zero value.",
+			s:      &VersionSelector{},
+			parse:  []string{">=v1.0.0"},
+			want:   want{cached: false},
+		},
+		"EvictLeastRecentlyUsed": {
+			reason: "We should evict the least recently used constraint when the cache is full.",
+			s:      NewVersionSelector(WithConstraintCacheSize(2)),
+			parse:  []string{">=v1.0.0", ">=v2.0.0", ">=v1.0.0", ">=v3.0.0"},
+			want:   want{cached: true, evicted: ">=v2.0.0"},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			for _, c := range tc.parse {
+				if _, err := tc.s.Parse(c); err != nil {
+					t.Fatalf("\n%s\nParse(%q): %v", tc.reason, c, err)
+				}
+			}
+
+			first := tc.parse[0]
+			a, _ := tc.s.Parse(first)
+			b, _ := tc.s.Parse(first)
+			if got := a == b; got != tc.want.cached {
+				t.Errorf("\n%s\nParse(%q): want cached %t, got %t", tc.reason, first, tc.want.cached, got)
+			}
+
+			if tc.want.evicted == "" {
+				return
+			}
+			if _, ok := tc.s.cached(tc.want.evicted); ok {
+				t.Errorf("\n%s\nParse(...): want %q evicted, but it is cached", tc.reason, tc.want.evicted)
+			}
+		})
+	}
+}
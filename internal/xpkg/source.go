@@ -0,0 +1,69 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package xpkg
+
+import (
+	"context"
+
+	"github.com/google/go-containerregistry/pkg/name"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+)
+
+const errNotListedFmt = "repository %s is not listed by version source"
+
+// A VersionSource lists the versions a package repository may be installed
+// at, e.g. the tags of the repository, or the versions an organization has
+// blessed.
+type VersionSource interface {
+	// ListVersions returns the versions of the supplied reference's
+	// repository. It returns a NotListedError if the source doesn't know
+	// about the repository.
+	ListVersions(ctx context.Context, ref name.Reference) ([]string, error)
+}
+
+// A VersionSourceFn is a function that satisfies VersionSource.
+type VersionSourceFn func(ctx context.Context, ref name.Reference) ([]string, error)
+
+// ListVersions returns the versions of the supplied reference's repository.
+func (fn VersionSourceFn) ListVersions(ctx context.Context, ref name.Reference) ([]string, error) {
+	return fn(ctx, ref)
+}
+
+// A DigestSource records the digest each version of a package repository
+// pointed at when it was published.
+type DigestSource interface {
+	// RecordedDigest returns the digest recorded for the supplied version of
+	// the supplied reference's repository, or an empty string if none was.
+	RecordedDigest(ctx context.Context, ref name.Reference, version string) (string, error)
+}
+
+// A NotListedError indicates a VersionSource doesn't know about a repository.
+type NotListedError struct {
+	Repository string
+}
+
+func (e *NotListedError) Error() string {
+	return errors.Errorf(errNotListedFmt, e.Repository).Error()
+}
+
+// IsNotListed returns true if the supplied error indicates a VersionSource
+// doesn't know about a repository.
+func IsNotListed(err error) bool {
+	var nerr *NotListedError
+	return errors.As(err, &nerr)
+}
@@ -0,0 +1,199 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package xpkg
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+)
+
+const (
+	errVersionGroupConflictFmt = "dependencies in version group %s have no major.minor version in common: %s"
+
+	memberFmt          = "%s (%s)"
+	installedMemberFmt = "%s (installed at %s)"
+)
+
+// WithVersionGroups specifies groups of repositories whose packages must all
+// be installed at the same major.minor version, for example because the
+// packages of a provider family share a runtime. Each key is a repository
+// prefix, e.g. xpkg.upbound.io/upbound/provider-aws-, and each value names the
+// group of the repositories it prefixes. The longest matching prefix wins.
+func WithVersionGroups(groups map[string]string) ResolverOption {
+	return func(r *Resolver) {
+		r.groups = groups
+	}
+}
+
+// inStream returns a copy of the Resolver that only selects versions in the
+// supplied version stream.
+func (r *Resolver) inStream(st string) *Resolver {
+	c := *r
+	c.stream = st
+	return &c
+}
+
+// group returns the version group of the supplied package's repository, or
+// an empty string if it isn't in one.
+func (r *Resolver) group(pkg string) string {
+	repo := pkg
+	if ref, err := name.ParseReference(pkg, DefaultRegistryOptions(r.registry)...); err == nil {
+		repo = ref.Context().Name()
+	}
+	group, longest := "", 0
+	for prefix, g := range r.groups {
+		if pr, err := name.NewRepository(prefix, DefaultRegistryOptions(r.registry)...); err == nil {
+			prefix = pr.Name()
+		}
+		if strings.HasPrefix(repo, prefix) && len(prefix) > longest {
+			group, longest = g, len(prefix)
+		}
+	}
+	return group
+}
+
+// align the versions of the planned dependencies in each version group, such
+// that they and the group's installed packages share a major.minor version
+// stream. The stream is selected by the Resolver's selection policy from
+// those in which every planned dependency has a version that satisfies its
+// constraints. Dependencies that can't be aligned report why in their Err.
+func (r *Resolver) align(ctx context.Context, plan []PlannedInstall, pkgs []v1beta1.LockPackage, f Fetcher) { // nolint:gocyclo
+	if len(r.groups) == 0 || f == nil {
+		return
+	}
+
+	// Dependencies whose version wasn't selected, e.g. because they track
+	// a digest, can't be aligned. Nor can those whose version is
+	// overridden.
+	planned := map[string][]int{}
+	for i, p := range plan {
+		if _, ok := Stream(p.Version); !ok || p.Err != nil || p.Override != "" {
+			continue
+		}
+		if g := r.group(p.Dependency.Package); g != "" {
+			planned[g] = append(planned[g], i)
+		}
+	}
+	installed := map[string][]v1beta1.LockPackage{}
+	for _, pkg := range pkgs {
+		if _, ok := Stream(pkg.Version); !ok {
+			continue
+		}
+		if g := r.group(pkg.Source); g != "" {
+			installed[g] = append(installed[g], pkg)
+		}
+	}
+
+	groups := make([]string, 0, len(planned))
+	for g := range planned {
+		groups = append(groups, g)
+	}
+	sort.Strings(groups)
+
+	for _, g := range groups {
+		if len(planned[g])+len(installed[g]) < 2 {
+			continue
+		}
+
+		sets := [][]string{}
+		members := []string{}
+		for _, pkg := range installed[g] {
+			st, _ := Stream(pkg.Version)
+			sets = append(sets, []string{st})
+			members = append(members, fmt.Sprintf(installedMemberFmt, pkg.Source, pkg.Version))
+		}
+		aligned := []int{}
+		for _, i := range planned[g] {
+			streams, err := r.streams(ctx, f, plan[i])
+			if err != nil {
+				dep := plan[i].Dependency
+				plan[i].Err = &DependencyError{Reason: DependencyFetchTags, err: errors.Wrapf(err, errDependencyFmt, errFetchTags, dep.Package, dep.Constraints)}
+				plan[i].Source, plan[i].Version, plan[i].Digest = "", "", ""
+				continue
+			}
+			sets = append(sets, streams)
+			members = append(members, fmt.Sprintf(memberFmt, plan[i].Dependency.Package, strings.Join(requirementConstraints(plan[i].Requirements), ", ")))
+			aligned = append(aligned, i)
+		}
+
+		common := IntersectStreams(sets...)
+		if len(common) == 0 {
+			err := errors.Errorf(errVersionGroupConflictFmt, g, strings.Join(members, ", "))
+			for _, i := range aligned {
+				plan[i].Err = &DependencyError{Reason: DependencyVersionGroupConflict, err: err}
+				plan[i].Source, plan[i].Version, plan[i].Digest = "", "", ""
+			}
+			continue
+		}
+
+		st := common[len(common)-1]
+		if r.selection == VersionSelectionLowest {
+			st = common[0]
+		}
+		for _, i := range aligned {
+			if s, _ := Stream(plan[i].Version); s == st {
+				continue
+			}
+			p := plan[i]
+			plan[i] = r.inStream(st).tracedPlan(ctx, &p.Dependency, p.Requirements, f)
+			plan[i].Depth = p.Depth
+		}
+	}
+}
+
+// streams returns the version streams in which the supplied planned
+// dependency has a version that satisfies its constraints, from lowest to
+// highest. A pinned dependency has only the stream of its pinned version.
+func (r *Resolver) streams(ctx context.Context, f Fetcher, p PlannedInstall) ([]string, error) {
+	if p.Pinned {
+		st, _ := Stream(p.Version)
+		return []string{st}, nil
+	}
+	ref, err := name.ParseReference(p.Dependency.Package, DefaultRegistryOptions(r.registry)...)
+	if err != nil {
+		return nil, err
+	}
+	versions, err := r.versions(ctx, f, ref, nil)
+	if err != nil {
+		return nil, err
+	}
+	versions, _ = exclude(versions, r.denied(ctx, ref))
+	return r.selector.Streams(versions, requirementConstraints(p.Requirements)...)
+}
+
+// requirementConstraints returns the distinct constraints of the supplied
+// requirements.
+func requirementConstraints(reqs []Requirement) []string {
+	constraints := []string{}
+	seen := map[string]bool{}
+	for _, rq := range reqs {
+		if seen[rq.Constraint] {
+			continue
+		}
+		seen[rq.Constraint] = true
+		constraints = append(constraints, rq.Constraint)
+	}
+	return constraints
+}
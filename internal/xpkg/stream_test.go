@@ -0,0 +1,119 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package xpkg
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-containerregistry/pkg/name"
+
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+)
+
+// A repoTagsFetcher returns the tags of each repository.
+type repoTagsFetcher struct {
+	NopFetcher
+	tags map[string][]string
+}
+
+func (f *repoTagsFetcher) Tags(_ context.Context, ref name.Reference, _ ...string) ([]string, error) {
+	return f.tags[ref.Context().RepositoryStr()], nil
+}
+
+func TestResolveVersionGroups(t *testing.T) {
+	f := &repoTagsFetcher{tags: map[string][]string{
+		"crossplane/provider-aws-s3":  {"v1.14.0", "v1.14.3", "v1.15.0", "v1.16.0"},
+		"crossplane/provider-aws-ec2": {"v1.14.1", "v1.15.2"},
+	}}
+	groups := map[string]string{"crossplane/provider-aws-": "aws"}
+	config := func(s3, ec2 string) v1beta1.LockPackage {
+		return v1beta1.LockPackage{Source: "cool/config", Type: v1beta1.ConfigurationPackageType, Dependencies: []v1beta1.Dependency{
+			{Package: "crossplane/provider-aws-s3", Type: v1beta1.ProviderPackageType, Constraints: s3},
+			{Package: "crossplane/provider-aws-ec2", Type: v1beta1.ProviderPackageType, Constraints: ec2},
+		}}
+	}
+
+	// Each planned package's source, or its error if it can't be installed.
+	type want map[string]string
+
+	cases := map[string]struct {
+		reason string
+		groups map[string]string
+		pkgs   []v1beta1.LockPackage
+		want   want
+	}{
+		"Ungrouped": {
+			reason: "We should select each dependency's version independently if it isn't in a version group.",
+			pkgs:   []v1beta1.LockPackage{config(">=v1.14.0", ">=v1.14.0")},
+			want: want{
+				"crossplane/provider-aws-s3":  "crossplane/provider-aws-s3:v1.16.0",
+				"crossplane/provider-aws-ec2": "crossplane/provider-aws-ec2:v1.15.2",
+			},
+		},
+		"OverlappingConstraints": {
+			reason: "We should select versions of grouped dependencies in the highest stream every one of them has a satisfying version in.",
+			groups: groups,
+			pkgs:   []v1beta1.LockPackage{config(">=v1.14.0", ">=v1.14.0")},
+			want: want{
+				"crossplane/provider-aws-s3":  "crossplane/provider-aws-s3:v1.15.0",
+				"crossplane/provider-aws-ec2": "crossplane/provider-aws-ec2:v1.15.2",
+			},
+		},
+		"InstalledSibling": {
+			reason: "We should select versions of grouped dependencies in the stream of an installed package in the same group.",
+			groups: groups,
+			pkgs: []v1beta1.LockPackage{
+				config(">=v1.14.0", ">=v1.14.0"),
+				{Source: "crossplane/provider-aws-iam", Type: v1beta1.ProviderPackageType, Version: "v1.14.0"},
+			},
+			want: want{
+				"crossplane/provider-aws-s3":  "crossplane/provider-aws-s3:v1.14.3",
+				"crossplane/provider-aws-ec2": "crossplane/provider-aws-ec2:v1.14.1",
+			},
+		},
+		"DisjointConstraints": {
+			reason: "We should report a conflict naming every grouped dependency and its constraints if they have no stream in common.",
+			groups: groups,
+			pkgs:   []v1beta1.LockPackage{config(">=v1.16.0", "<v1.15.0")},
+			want: want{
+				"crossplane/provider-aws-s3":  "dependencies in version group aws have no major.minor version in common: crossplane/provider-aws-ec2 (<v1.15.0), crossplane/provider-aws-s3 (>=v1.16.0)",
+				"crossplane/provider-aws-ec2": "dependencies in version group aws have no major.minor version in common: crossplane/provider-aws-ec2 (<v1.15.0), crossplane/provider-aws-s3 (>=v1.16.0)",
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			plan, _, err := NewResolver(WithVersionGroups(tc.groups)).Resolve(context.Background(), tc.pkgs, f)
+			if err != nil {
+				t.Fatalf("\n%s\nResolve(...): %s", tc.reason, err)
+			}
+			got := want{}
+			for _, p := range plan {
+				got[p.Dependency.Package] = p.Source
+				if p.Err != nil {
+					got[p.Dependency.Package] = p.Err.Error()
+				}
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nResolve(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,162 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package xpkg
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/Masterminds/semver"
+)
+
+// LatestTag is the tag conventionally pointing to the most recently published
+// version of a package.
+const LatestTag = "latest"
+
+// A TagSkipReason is the reason a TagFilter skipped a tag.
+type TagSkipReason string
+
+// Reasons a tag may be skipped.
+const (
+	// TagSkipSignature indicates the tag is a cosign signature, attestation,
+	// or SBOM, e.g. sha256-3b5d....sig.
+	TagSkipSignature TagSkipReason = "Signature"
+
+	// TagSkipNotSemver indicates the tag is not a semantic version, e.g.
+	// main.
+	TagSkipNotSemver TagSkipReason = "NotSemver"
+
+	// TagSkipDuplicate indicates another tag is the same semantic version,
+	// e.g. 1.0.0 and v1.0.0. The v-prefixed tag is kept.
+	TagSkipDuplicate TagSkipReason = "Duplicate"
+
+	// TagSkipLatest indicates the tag is latest, which is considered an
+	// alias of the highest semantic version tag.
+	TagSkipLatest TagSkipReason = "Latest"
+)
+
+// signatureTag matches the tags cosign uses to store signatures, attestations,
+// and SBOMs alongside the image they refer to.
+var signatureTag = regexp.MustCompile(`^sha256-[0-9a-f]+\.(sig|att|sbom)$`)
+
+// A TagSkipFn returns the reason the supplied tag should be skipped, or an
+// empty reason if it should be kept.
+type TagSkipFn func(tag string) TagSkipReason
+
+// SkipSignatures skips cosign signature, attestation, and SBOM tags.
+func SkipSignatures(tag string) TagSkipReason {
+	if signatureTag.MatchString(tag) {
+		return TagSkipSignature
+	}
+	return ""
+}
+
+// A TagFilter filters the tags of a package repository before they are
+// checked against a version constraint. It only keeps tags that are semantic
+// versions.
+type TagFilter struct {
+	skip   []TagSkipFn
+	latest bool
+}
+
+// A TagFilterOption configures a TagFilter.
+type TagFilterOption func(*TagFilter)
+
+// WithTagSkipFns specifies additional functions a TagFilter should use to
+// skip tags. They are called in order, after the default functions.
+func WithTagSkipFns(fns ...TagSkipFn) TagFilterOption {
+	return func(f *TagFilter) {
+		f.skip = append(f.skip, fns...)
+	}
+}
+
+// WithLatestAsHighest specifies that a TagFilter should treat the latest tag
+// as an alias of the highest semantic version tag. The latest tag is then
+// reported as such, rather than as a tag that is not a semantic version.
+func WithLatestAsHighest() TagFilterOption {
+	return func(f *TagFilter) {
+		f.latest = true
+	}
+}
+
+// NewTagFilter returns a TagFilter that skips signature tags, tags that are
+// not semantic versions, and duplicate semantic versions.
+func NewTagFilter(opts ...TagFilterOption) *TagFilter {
+	f := &TagFilter{skip: []TagSkipFn{SkipSignatures}}
+	for _, o := range opts {
+		o(f)
+	}
+	return f
+}
+
+// Filter returns the supplied tags that should be checked against a version
+// constraint, in their original order, and the number of tags skipped for
+// each reason.
+func (f *TagFilter) Filter(tags []string) ([]string, map[TagSkipReason]int) { // nolint:gocyclo
+	skipped := map[TagSkipReason]int{}
+	kept := make([]string, 0, len(tags))
+
+	// The index in kept of the tag of each version, so we can replace a
+	// duplicate with its v-prefixed form.
+	seen := map[string]int{}
+
+	hasLatest := false
+	for _, t := range tags {
+		if reason := f.skipReason(t); reason != "" {
+			skipped[reason]++
+			continue
+		}
+		if f.latest && t == LatestTag {
+			hasLatest = true
+			continue
+		}
+		v, err := semver.NewVersion(t)
+		if err != nil {
+			skipped[TagSkipNotSemver]++
+			continue
+		}
+		key := v.String()
+		if i, ok := seen[key]; ok {
+			skipped[TagSkipDuplicate]++
+			if strings.HasPrefix(t, "v") {
+				kept[i] = t
+			}
+			continue
+		}
+		seen[key] = len(kept)
+		kept = append(kept, t)
+	}
+
+	// latest is only an alias if there is a version for it to alias.
+	if hasLatest {
+		reason := TagSkipNotSemver
+		if len(kept) > 0 {
+			reason = TagSkipLatest
+		}
+		skipped[reason]++
+	}
+	return kept, skipped
+}
+
+func (f *TagFilter) skipReason(tag string) TagSkipReason {
+	for _, fn := range f.skip {
+		if reason := fn(tag); reason != "" {
+			return reason
+		}
+	}
+	return ""
+}
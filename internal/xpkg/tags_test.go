@@ -0,0 +1,119 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package xpkg
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestTagFilter(t *testing.T) {
+	sig := "sha256-" + strings.Repeat("3b5d", 16)
+
+	type want struct {
+		tags    []string
+		skipped map[TagSkipReason]int
+	}
+
+	cases := map[string]struct {
+		reason string
+		opts   []TagFilterOption
+		tags   []string
+		want   want
+	}{
+		"Empty": {
+			reason: "We should handle a repository without tags.",
+			want: want{
+				tags:    []string{},
+				skipped: map[TagSkipReason]int{},
+			},
+		},
+		"Messy": {
+			reason: "We should keep only semantic versions from a real-world tag list.",
+			tags: []string{
+				"latest", "main", "v0.20.0", sig + ".sig", "v0.21.0-rc.0", sig + ".att",
+				"v1.2.3-rc.1-amd64", "master-3b5d", sig + ".sbom", "v0.21.0",
+			},
+			want: want{
+				tags:    []string{"v0.20.0", "v0.21.0-rc.0", "v1.2.3-rc.1-amd64", "v0.21.0"},
+				skipped: map[TagSkipReason]int{TagSkipSignature: 3, TagSkipNotSemver: 3},
+			},
+		},
+		"NotASignature": {
+			reason: "We should not mistake a tag that merely resembles a signature for one.",
+			tags:   []string{"sha256-xyz.sig", sig},
+			want: want{
+				tags:    []string{},
+				skipped: map[TagSkipReason]int{TagSkipNotSemver: 2},
+			},
+		},
+		"Duplicates": {
+			reason: "We should keep the v-prefixed form of a version that is tagged both with and without the prefix.",
+			tags:   []string{"1.0.0", "v1.0.0", "v1.1.0", "1.1.0", "2.0.0"},
+			want: want{
+				tags:    []string{"v1.0.0", "v1.1.0", "2.0.0"},
+				skipped: map[TagSkipReason]int{TagSkipDuplicate: 2},
+			},
+		},
+		"LatestAsHighest": {
+			reason: "We should report latest as an alias of the highest version if configured to.",
+			opts:   []TagFilterOption{WithLatestAsHighest()},
+			tags:   []string{"latest", "v1.0.0", "main"},
+			want: want{
+				tags:    []string{"v1.0.0"},
+				skipped: map[TagSkipReason]int{TagSkipLatest: 1, TagSkipNotSemver: 1},
+			},
+		},
+		"LatestWithoutVersions": {
+			reason: "We should not report latest as an alias if there is no version for it to alias.",
+			opts:   []TagFilterOption{WithLatestAsHighest()},
+			tags:   []string{"latest", "main"},
+			want: want{
+				tags:    []string{},
+				skipped: map[TagSkipReason]int{TagSkipNotSemver: 2},
+			},
+		},
+		"CustomSkipFn": {
+			reason: "We should skip tags using any additional functions.",
+			opts: []TagFilterOption{WithTagSkipFns(func(tag string) TagSkipReason {
+				if strings.HasSuffix(tag, "-amd64") {
+					return "Architecture"
+				}
+				return ""
+			})},
+			tags: []string{"v1.0.0-amd64", "v1.0.0"},
+			want: want{
+				tags:    []string{"v1.0.0"},
+				skipped: map[TagSkipReason]int{"Architecture": 1},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			tags, skipped := NewTagFilter(tc.opts...).Filter(tc.tags)
+			if diff := cmp.Diff(tc.want.tags, tags); diff != "" {
+				t.Errorf("\n%s\nFilter(...): -want tags, +got tags:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.skipped, skipped); diff != "" {
+				t.Errorf("\n%s\nFilter(...): -want skipped, +got skipped:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
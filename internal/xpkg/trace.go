@@ -0,0 +1,108 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package xpkg
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+)
+
+// Names of the spans a Resolver records.
+const (
+	// SpanResolveDependency covers planning how to install a missing
+	// dependency, including listing and selecting its versions.
+	SpanResolveDependency = "ResolveDependency"
+
+	// SpanFetchVersions covers listing the versions of a missing
+	// dependency's repository.
+	SpanFetchVersions = "FetchVersions"
+
+	// SpanSelectVersion covers selecting the version of a missing
+	// dependency that satisfies its constraints.
+	SpanSelectVersion = "SelectVersion"
+)
+
+// Attributes of the spans a Resolver records.
+const (
+	// AttributePackage is the package a dependency is on.
+	AttributePackage = attribute.Key("crossplane.dependency.package")
+
+	// AttributeConstraints are the constraints the packages that depend on
+	// a dependency place on its version.
+	AttributeConstraints = attribute.Key("crossplane.dependency.constraints")
+
+	// AttributeVersion is the version selected to satisfy a dependency.
+	AttributeVersion = attribute.Key("crossplane.dependency.version")
+
+	// AttributeCandidates is the number of versions a dependency's version
+	// was selected from.
+	AttributeCandidates = attribute.Key("crossplane.dependency.candidates")
+
+	// AttributeOutcome is what became of a dependency.
+	AttributeOutcome = attribute.Key("crossplane.dependency.outcome")
+)
+
+// OutcomePlanned is the outcome of a dependency the Resolver could plan how to
+// install.
+const OutcomePlanned = "Planned"
+
+// WithTracer specifies the tracer the Resolver uses to record how long it
+// spends planning each missing dependency. By default no spans are recorded.
+func WithTracer(t trace.Tracer) ResolverOption {
+	return func(r *Resolver) {
+		r.tracer = t
+	}
+}
+
+// tracedPlan plans how to install the supplied missing dependency, recording
+// a span that describes the plan.
+func (r *Resolver) tracedPlan(ctx context.Context, dep *v1beta1.Dependency, reqs []Requirement, f Fetcher) PlannedInstall {
+	constraints := make([]string, len(reqs))
+	for i, rq := range reqs {
+		constraints[i] = rq.Constraint
+	}
+	ctx, span := r.tracer.Start(ctx, SpanResolveDependency, trace.WithAttributes(
+		AttributePackage.String(dep.Package),
+		AttributeConstraints.StringSlice(constraints),
+	))
+	defer span.End()
+
+	p := r.plan(ctx, dep, reqs, f)
+	span.SetAttributes(AttributeVersion.String(p.Version), AttributeCandidates.Int(p.Candidates))
+	if p.Err != nil {
+		span.SetAttributes(AttributeOutcome.String(string(p.Err.Reason)))
+		RecordError(span, p.Err)
+		return p
+	}
+	span.SetAttributes(AttributeOutcome.String(OutcomePlanned))
+	return p
+}
+
+// RecordError records the supplied error, if any, against the supplied span
+// and marks the span as failed.
+func RecordError(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
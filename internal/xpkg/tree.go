@@ -0,0 +1,250 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package xpkg
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+	"github.com/crossplane/crossplane/internal/dag"
+)
+
+const (
+	errBuildTree  = "cannot build dependency tree"
+	errRenderTree = "cannot render dependency tree"
+)
+
+// HealthUnknown is the health of every node in a tree built from a Lock. The
+// Lock does not record whether a package is healthy, so callers that can
+// observe package revisions may fill it in.
+const HealthUnknown = "Unknown"
+
+// A Tree is the dependency tree of the packages in a Lock.
+type Tree struct {
+	// Roots are the packages in the Lock that no other package depends on,
+	// sorted by source. Packages in a dependency cycle that no root reaches
+	// are roots too, so that every package appears in the tree.
+	Roots []*TreeNode `json:"roots"`
+}
+
+// A TreeNode is a package in a dependency tree.
+type TreeNode struct {
+	// Package is the source of the package.
+	Package string `json:"package"`
+
+	// Type is the type of the package.
+	Type v1beta1.PackageType `json:"type,omitempty"`
+
+	// Version is the installed tag or digest of the package. It is empty if
+	// the package is a dependency that is not installed.
+	Version string `json:"version,omitempty"`
+
+	// Constraint is the constraint the parent of this node places on its
+	// version. Roots have no constraint.
+	Constraint string `json:"constraint,omitempty"`
+
+	// Health of the package. Always HealthUnknown when built from a Lock.
+	Health string `json:"health"`
+
+	// Missing is true if the package is a dependency that is not installed.
+	Missing bool `json:"missing,omitempty"`
+
+	// Shared is true if more than one package depends on this package, i.e.
+	// the node is reachable via multiple parents.
+	Shared bool `json:"shared,omitempty"`
+
+	// Repeated is true if the node's dependencies are omitted because they
+	// were listed where the node first appeared in the tree.
+	Repeated bool `json:"repeated,omitempty"`
+
+	// Cycle is true if the node's dependencies are omitted because the node
+	// is one of its own ancestors.
+	Cycle bool `json:"cycle,omitempty"`
+
+	// Dependencies of the package, in the order the package declares them.
+	Dependencies []*TreeNode `json:"dependencies,omitempty"`
+}
+
+// BuildTree builds the dependency tree of the packages in the supplied Lock.
+// A package that is reachable via multiple parents appears under each of
+// them, but its dependencies are only listed the first time it appears.
+func BuildTree(lock *v1beta1.Lock) (*Tree, error) {
+	d := dag.NewMapDag()
+	if _, err := d.Init(v1beta1.ToNodes(lock.Packages...)); err != nil {
+		return nil, errors.Wrap(err, errBuildTree)
+	}
+
+	b := &treeBuilder{dag: d, expanded: map[string]bool{}, path: map[string]bool{}}
+	t := &Tree{Roots: []*TreeNode{}}
+
+	pkgs := make([]v1beta1.LockPackage, len(lock.Packages))
+	copy(pkgs, lock.Packages)
+	sort.SliceStable(pkgs, func(i, j int) bool { return pkgs[i].Source < pkgs[j].Source })
+
+	for i := range pkgs {
+		if b.dependents(pkgs[i].Identifier()) > 0 {
+			continue
+		}
+		t.Roots = append(t.Roots, b.node(&pkgs[i], ""))
+	}
+
+	// Packages in a cycle may not be reachable from any root.
+	for i := range pkgs {
+		if b.expanded[pkgs[i].Identifier()] {
+			continue
+		}
+		t.Roots = append(t.Roots, b.node(&pkgs[i], ""))
+	}
+
+	return t, nil
+}
+
+type treeBuilder struct {
+	dag dag.DAG
+
+	// expanded tracks the packages whose dependencies have been listed.
+	expanded map[string]bool
+
+	// path tracks the packages between the current node and its root.
+	path map[string]bool
+}
+
+func (b *treeBuilder) dependents(id string) int {
+	// The identifier is always a node in the DAG.
+	dependents, _ := b.dag.NodeDependents(id)
+	return len(dependents)
+}
+
+func (b *treeBuilder) node(pkg *v1beta1.LockPackage, constraint string) *TreeNode {
+	id := pkg.Identifier()
+	n := &TreeNode{
+		Package:    pkg.Source,
+		Type:       pkg.Type,
+		Version:    pkg.Version,
+		Constraint: constraint,
+		Health:     HealthUnknown,
+		Shared:     b.dependents(id) > 1,
+	}
+
+	switch {
+	case b.path[id]:
+		n.Cycle = true
+		return n
+	case b.expanded[id]:
+		n.Repeated = len(pkg.Dependencies) > 0
+		return n
+	}
+
+	b.expanded[id] = true
+	b.path[id] = true
+	defer delete(b.path, id)
+
+	for _, dep := range pkg.Dependencies {
+		dn, err := b.dag.GetNode(dep.Identifier())
+		if err != nil {
+			// Every dependency is added to the DAG when it's initialized.
+			continue
+		}
+		lp, ok := dn.(*v1beta1.LockPackage)
+		if !ok {
+			n.Dependencies = append(n.Dependencies, &TreeNode{
+				Package:    dep.Package,
+				Type:       dep.Type,
+				Constraint: dep.Constraints,
+				Health:     HealthUnknown,
+				Missing:    true,
+				Shared:     b.dependents(dep.Identifier()) > 1,
+			})
+			continue
+		}
+		n.Dependencies = append(n.Dependencies, b.node(lp, dep.Constraints))
+	}
+	return n
+}
+
+// RenderText writes the tree to the supplied writer as indented text, one
+// package per line.
+func (t *Tree) RenderText(w io.Writer) error {
+	for _, r := range t.Roots {
+		if _, err := fmt.Fprintln(w, r.describe()); err != nil {
+			return errors.Wrap(err, errRenderTree)
+		}
+		if err := renderText(w, r.Dependencies, ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func renderText(w io.Writer, nodes []*TreeNode, indent string) error {
+	for i, n := range nodes {
+		branch, next := "├── ", "│   "
+		if i == len(nodes)-1 {
+			branch, next = "└── ", "    "
+		}
+		if _, err := fmt.Fprintln(w, indent+branch+n.describe()); err != nil {
+			return errors.Wrap(err, errRenderTree)
+		}
+		if err := renderText(w, n.Dependencies, indent+next); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// describe returns a one line description of the node, e.g.
+// crossplane/provider-aws v0.20.0 (Provider, constraint >=v0.20.0, health Unknown, shared)
+func (n *TreeNode) describe() string {
+	version := n.Version
+	if n.Missing {
+		version = "<missing>"
+	}
+
+	details := []string{}
+	if n.Type != "" {
+		details = append(details, string(n.Type))
+	}
+	if n.Constraint != "" {
+		details = append(details, "constraint "+n.Constraint)
+	}
+	details = append(details, "health "+n.Health)
+	if n.Shared {
+		details = append(details, "shared")
+	}
+	if n.Repeated {
+		details = append(details, "dependencies listed above")
+	}
+	if n.Cycle {
+		details = append(details, "cycle")
+	}
+
+	return fmt.Sprintf("%s %s (%s)", n.Package, version, strings.Join(details, ", "))
+}
+
+// RenderJSON writes the tree to the supplied writer as indented JSON.
+func (t *Tree) RenderJSON(w io.Writer) error {
+	e := json.NewEncoder(w)
+	e.SetEscapeHTML(false)
+	e.SetIndent("", "  ")
+	return errors.Wrap(e.Encode(t), errRenderTree)
+}
@@ -0,0 +1,128 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package xpkg
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/crossplane/crossplane/apis/pkg/v1beta1"
+)
+
+// Run go test ./internal/xpkg -run TestTree -update to regenerate the golden
+// files after intentionally changing how trees are rendered.
+var update = flag.Bool("update", false, "update golden files")
+
+func TestTree(t *testing.T) {
+	cases := map[string]struct {
+		reason string
+		pkgs   []v1beta1.LockPackage
+	}{
+		"Empty": {
+			reason: "An empty Lock should render an empty tree.",
+		},
+		"Simple": {
+			reason: "We should render each root's dependencies beneath it, including those that are missing.",
+			pkgs: []v1beta1.LockPackage{
+				{Source: "cool/config-a", Type: v1beta1.ConfigurationPackageType, Version: "v1.0.0", Dependencies: []v1beta1.Dependency{
+					{Package: "crossplane/provider-aws", Type: v1beta1.ProviderPackageType, Constraints: ">=v0.20.0"},
+					{Package: "crossplane/provider-gcp", Type: v1beta1.ProviderPackageType, Constraints: ">=v0.15.0"},
+				}},
+				{Source: "crossplane/provider-aws", Type: v1beta1.ProviderPackageType, Version: "v0.21.0"},
+			},
+		},
+		"Diamond": {
+			reason: "We should mark a package reachable via multiple parents as shared, and list its dependencies only once.",
+			pkgs: []v1beta1.LockPackage{
+				{Source: "cool/platform", Type: v1beta1.ConfigurationPackageType, Version: "v2.0.0", Dependencies: []v1beta1.Dependency{
+					{Package: "cool/config-a", Type: v1beta1.ConfigurationPackageType, Constraints: ">=v1.0.0"},
+					{Package: "cool/config-b", Type: v1beta1.ConfigurationPackageType, Constraints: ">=v1.0.0"},
+				}},
+				{Source: "cool/config-a", Type: v1beta1.ConfigurationPackageType, Version: "v1.0.0", Dependencies: []v1beta1.Dependency{
+					{Package: "cool/config-base", Type: v1beta1.ConfigurationPackageType, Constraints: ">=v0.1.0"},
+				}},
+				{Source: "cool/config-b", Type: v1beta1.ConfigurationPackageType, Version: "v1.1.0", Dependencies: []v1beta1.Dependency{
+					{Package: "cool/config-base", Type: v1beta1.ConfigurationPackageType, Constraints: "<v1.0.0"},
+				}},
+				{Source: "cool/config-base", Type: v1beta1.ConfigurationPackageType, Version: "v0.3.0", Dependencies: []v1beta1.Dependency{
+					{Package: "crossplane/provider-aws", Type: v1beta1.ProviderPackageType, Constraints: ">=v0.20.0"},
+				}},
+				{Source: "crossplane/provider-aws", Type: v1beta1.ProviderPackageType, Version: "v0.21.0"},
+			},
+		},
+		"Cycle": {
+			reason: "We should render a dependency cycle without recursing forever.",
+			pkgs: []v1beta1.LockPackage{
+				{Source: "cool/config-a", Type: v1beta1.ConfigurationPackageType, Version: "v1.0.0", Dependencies: []v1beta1.Dependency{
+					{Package: "cool/config-b", Type: v1beta1.ConfigurationPackageType, Constraints: ">=v1.0.0"},
+				}},
+				{Source: "cool/config-b", Type: v1beta1.ConfigurationPackageType, Version: "v1.0.0", Dependencies: []v1beta1.Dependency{
+					{Package: "cool/config-a", Type: v1beta1.ConfigurationPackageType, Constraints: ">=v1.0.0"},
+				}},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			tree, err := BuildTree(&v1beta1.Lock{Packages: tc.pkgs})
+			if err != nil {
+				t.Fatalf("\n%s\nBuildTree(...): %s", tc.reason, err)
+			}
+
+			renderers := map[string]func(*bytes.Buffer) error{
+				".txt":  func(b *bytes.Buffer) error { return tree.RenderText(b) },
+				".json": func(b *bytes.Buffer) error { return tree.RenderJSON(b) },
+			}
+			for ext, render := range renderers {
+				got := &bytes.Buffer{}
+				if err := render(got); err != nil {
+					t.Fatalf("\n%s\nRender(...): %s", tc.reason, err)
+				}
+
+				golden := filepath.Join("testdata", "tree", name+ext)
+				if *update {
+					if err := ioutil.WriteFile(golden, got.Bytes(), 0600); err != nil {
+						t.Fatalf("cannot update golden file %s: %s", golden, err)
+					}
+				}
+				want, err := ioutil.ReadFile(golden) // nolint:gosec
+				if err != nil {
+					t.Fatalf("cannot read golden file %s: %s", golden, err)
+				}
+				if diff := cmp.Diff(string(want), got.String()); diff != "" {
+					t.Errorf("\n%s\nRender(...) %s: -want, +got:\n%s", tc.reason, golden, diff)
+				}
+			}
+		})
+	}
+}
+
+func TestBuildTreeError(t *testing.T) {
+	lock := &v1beta1.Lock{Packages: []v1beta1.LockPackage{
+		{Source: "cool/config-a"},
+		{Source: "cool/config-a"},
+	}}
+	if _, err := BuildTree(lock); err == nil {
+		t.Errorf("BuildTree(...): expected an error building a tree from a Lock with duplicate packages")
+	}
+}
@@ -0,0 +1,220 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package xpkg
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/semver"
+)
+
+const (
+	errInvalidConstraint = "invalid version constraint"
+)
+
+// Version constraint aliases.
+const (
+	// ConstraintLatest is satisfied only by the highest stable version.
+	ConstraintLatest = "latest"
+
+	// ConstraintStable is an alias of ConstraintLatest.
+	ConstraintStable = "stable"
+
+	// ConstraintAny is satisfied by any stable version.
+	ConstraintAny = "*"
+)
+
+// ConstraintTrackPrefix prefixes a constraint that tracks a moving tag rather
+// than constraining a semantic version, e.g. track:v1-stable. A dependency
+// that tracks a tag is installed at the digest the tag points at, and moves
+// with the tag.
+const ConstraintTrackPrefix = "track:"
+
+// TrackedTag returns the tag the supplied constraint tracks, and true, or
+// false if it doesn't track a tag.
+func TrackedTag(constraint string) (string, bool) {
+	if !strings.HasPrefix(constraint, ConstraintTrackPrefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(constraint, ConstraintTrackPrefix), true
+}
+
+// IsLatestConstraint returns true if the supplied constraint is satisfied
+// only by the highest stable version. An empty constraint is treated as
+// ConstraintLatest.
+func IsLatestConstraint(constraint string) bool {
+	return constraint == ConstraintLatest || constraint == ConstraintStable || constraint == ""
+}
+
+// IsConstraintAlias returns true if the supplied constraint is an alias
+// rather than a semantic version constraint.
+func IsConstraintAlias(constraint string) bool {
+	return IsLatestConstraint(constraint) || constraint == ConstraintAny
+}
+
+// IsDowngrade returns true if changing from the supplied installed version to
+// the supplied selected version would install a lower semantic version. A v
+// prefix and build metadata are ignored. Changing from or to a version that is
+// not a semantic version, e.g. a digest, is never a downgrade because the
+// versions can't be compared.
+func IsDowngrade(installed, selected string) bool {
+	from, err := semver.NewVersion(installed)
+	if err != nil {
+		return false
+	}
+	to, err := semver.NewVersion(selected)
+	if err != nil {
+		return false
+	}
+	return to.LessThan(from)
+}
+
+// NewConstraint parses the supplied semantic version constraint, which may be
+// an alias. Any stable version satisfies the constraint of an alias; only a
+// selected version is limited to the highest.
+func NewConstraint(constraint string) (*semver.Constraints, error) {
+	if IsConstraintAlias(constraint) {
+		return semver.NewConstraint(ConstraintAny)
+	}
+	return semver.NewConstraint(constraint)
+}
+
+// exactVersion matches a complete semantic version, optionally prefixed with
+// the = operator, e.g. 0.21.3, =v0.21.3 or 1.0.0-rc.1.
+var exactVersion = regexp.MustCompile(`^=?\s*v?[0-9]+\.[0-9]+\.[0-9]+(-[0-9A-Za-z.-]+)?(\+[0-9A-Za-z.-]+)?$`)
+
+// AllowedVersions returns the versions enumerated by the supplied constraint
+// if it is an enumeration of exact versions, e.g. 0.21.3 || 0.22.1. It returns
+// nil if the constraint is not an enumeration, including if it is a single
+// exact version.
+func AllowedVersions(constraint string) []string {
+	alts := strings.Split(constraint, "||")
+	if len(alts) < 2 {
+		return nil
+	}
+	vs := make([]string, len(alts))
+	for i, a := range alts {
+		a = strings.TrimSpace(a)
+		if !exactVersion.MatchString(a) {
+			return nil
+		}
+		vs[i] = strings.TrimSpace(strings.TrimPrefix(a, "="))
+	}
+	return vs
+}
+
+// A VersionSelection determines which of the versions that satisfy a
+// constraint is selected.
+type VersionSelection string
+
+// Version selection policies.
+const (
+	// VersionSelectionHighest selects the highest version that satisfies a
+	// constraint.
+	VersionSelectionHighest VersionSelection = "Highest"
+
+	// VersionSelectionLowest selects the lowest version that satisfies a
+	// constraint. The same version is selected regardless of what newer
+	// versions are published, making installs reproducible.
+	VersionSelectionLowest VersionSelection = "Lowest"
+)
+
+// prereleaseConstraint matches a version with a prerelease component, e.g.
+// 1.2.0-0 or v1.2-rc.1. The hyphen of a range (1.0 - 2.0) is surrounded by
+// whitespace, and is therefore not matched.
+var prereleaseConstraint = regexp.MustCompile(`[0-9]+(\.[0-9xX*]+)*-[0-9A-Za-z]`)
+
+// matching returns the supplied tags that satisfy all of the supplied
+// constraints, sorted in ascending order, and the highest version that was
+// considered.
+func (s *VersionSelector) matching(constraints []string, tags []string) ([]*semver.Version, *semver.Version, error) { // nolint:gocyclo
+	cs := make([]*semver.Constraints, len(constraints))
+	prerelease := len(constraints) > 0
+	latest := false
+	for i, constraint := range constraints {
+		c, err := s.Parse(constraint)
+		if err != nil {
+			return nil, nil, err
+		}
+		cs[i] = c
+		prerelease = prerelease && !IsConstraintAlias(constraint) && prereleaseConstraint.MatchString(constraint)
+		latest = latest || IsLatestConstraint(constraint)
+	}
+
+	vs := []*semver.Version{}
+	var highest *semver.Version
+	for _, t := range tags {
+		v, err := semver.NewVersion(t)
+		if err != nil {
+			// We skip any tags that are not valid semantic versions.
+			continue
+		}
+		if v.Prerelease() != "" && !prerelease {
+			continue
+		}
+		if highest == nil || v.GreaterThan(highest) {
+			highest = v
+		}
+		if satisfiesAll(cs, v) {
+			vs = append(vs, v)
+		}
+	}
+
+	// Only the highest stable version satisfies the latest alias.
+	if latest {
+		kept := []*semver.Version{}
+		for _, v := range vs {
+			if v.Equal(highest) {
+				kept = append(kept, v)
+			}
+		}
+		vs = kept
+	}
+
+	// Tags that are the same version are sorted by tag.
+	sort.Slice(vs, func(i, j int) bool {
+		if c := vs[i].Compare(vs[j]); c != 0 {
+			return c < 0
+		}
+		return vs[i].Original() < vs[j].Original()
+	})
+	return vs, highest, nil
+}
+
+// lowest returns the last tag of the lowest version in the supplied sorted
+// versions.
+func lowest(vs []*semver.Version) string {
+	v := vs[0]
+	for _, o := range vs[1:] {
+		if !o.Equal(v) {
+			break
+		}
+		v = o
+	}
+	return v.Original()
+}
+
+func satisfiesAll(cs []*semver.Constraints, v *semver.Version) bool {
+	for _, c := range cs {
+		if !c.Check(v) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,119 @@
+/*
+Copyright 2021 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package xpkg
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestAllowedVersions(t *testing.T) {
+	cases := map[string]struct {
+		reason     string
+		constraint string
+		want       []string
+	}{
+		"Enumeration": {
+			reason:     "We should return each version an enumeration of exact versions allows.",
+			constraint: "0.21.3 || =v0.22.1 ||0.24.0-rc.1",
+			want:       []string{"0.21.3", "v0.22.1", "0.24.0-rc.1"},
+		},
+		"SingleVersion": {
+			reason:     "A single exact version is not an enumeration.",
+			constraint: "0.21.3",
+		},
+		"Range": {
+			reason:     "A constraint that ORs ranges is not an enumeration.",
+			constraint: "0.21.3 || >=0.24.0",
+		},
+		"PartialVersion": {
+			reason:     "A partial version allows more than one version, so is not an enumeration.",
+			constraint: "0.21.3 || 0.22",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := AllowedVersions(tc.constraint)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nAllowedVersions(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestIsDowngrade(t *testing.T) {
+	cases := map[string]struct {
+		reason    string
+		installed string
+		selected  string
+		want      bool
+	}{
+		"Upgrade": {
+			reason:    "Selecting a higher version is not a downgrade.",
+			installed: "v0.20.0",
+			selected:  "v0.21.0",
+			want:      false,
+		},
+		"Same": {
+			reason:    "Selecting the installed version is not a downgrade.",
+			installed: "v0.20.0",
+			selected:  "v0.20.0",
+			want:      false,
+		},
+		"Downgrade": {
+			reason:    "Selecting a lower version is a downgrade.",
+			installed: "v0.21.0",
+			selected:  "v0.19.2",
+			want:      true,
+		},
+		"Prefix": {
+			reason:    "We should compare versions whether or not they have a v prefix.",
+			installed: "0.21.0",
+			selected:  "v0.19.2",
+			want:      true,
+		},
+		"BuildMetadata": {
+			reason:    "We should ignore build metadata when comparing versions.",
+			installed: "v0.20.0+build.2",
+			selected:  "v0.20.0+build.1",
+			want:      false,
+		},
+		"Prerelease": {
+			reason:    "A prerelease of the installed version is a downgrade.",
+			installed: "v0.20.0",
+			selected:  "v0.20.0-rc.1",
+			want:      true,
+		},
+		"Digest": {
+			reason:    "We can't compare a digest to a version.",
+			installed: "sha256:ecc25c121431dfc7058754427f97c034ecde26d4aafa0da16d6429d9f7d6cd9b",
+			selected:  "v0.19.0",
+			want:      false,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := IsDowngrade(tc.installed, tc.selected)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nIsDowngrade(%q, %q): -want, +got:\n%s", tc.reason, tc.installed, tc.selected, diff)
+			}
+		})
+	}
+}